@@ -3,7 +3,6 @@ package api
 import (
 	"encoding/xml"
 	"errors"
-	"io"
 	"net/http"
 
 	"github.com/kumasuke/jog/internal/storage"
@@ -101,16 +100,16 @@ func (h *Handler) PutBucketAcl(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse request body for explicit ACL
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket)
+	body, s3Err := readXMLBody(r, maxXMLBodySize)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
 		return
 	}
 
 	if len(body) > 0 {
 		var aclPolicy AccessControlPolicy
-		if err := xml.Unmarshal(body, &aclPolicy); err != nil {
-			WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket)
+		if s3Err := unmarshalXML(body, &aclPolicy); s3Err != nil {
+			WriteErrorWithResource(w, s3Err, "/"+bucket)
 			return
 		}
 
@@ -186,16 +185,16 @@ func (h *Handler) PutObjectAcl(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse request body for explicit ACL
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket+"/"+key)
+	body, s3Err := readXMLBody(r, maxXMLBodySize)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
 		return
 	}
 
 	if len(body) > 0 {
 		var aclPolicy AccessControlPolicy
-		if err := xml.Unmarshal(body, &aclPolicy); err != nil {
-			WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket+"/"+key)
+		if s3Err := unmarshalXML(body, &aclPolicy); s3Err != nil {
+			WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
 			return
 		}
 
@@ -36,6 +36,20 @@ type BucketInfo struct {
 	CreationDate string `xml:"CreationDate"`
 }
 
+// CreateBucketConfiguration is the optional request body of CreateBucket.
+type CreateBucketConfiguration struct {
+	XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+	LocationConstraint string   `xml:"LocationConstraint,omitempty"`
+	Location           struct {
+		Type string `xml:"Type,omitempty"`
+		Name string `xml:"Name,omitempty"`
+	} `xml:"Location"`
+	Bucket struct {
+		Type           string `xml:"Type,omitempty"`
+		DataRedundancy string `xml:"DataRedundancy,omitempty"`
+	} `xml:"Bucket"`
+}
+
 // Bucket name validation regex
 var bucketNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
 
@@ -60,15 +74,45 @@ func (h *Handler) CreateBucket(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
 	// Validate bucket name
-	if !ValidateBucketName(bucket) {
+	if IsDirectoryBucket(bucket) {
+		if !ValidateDirectoryBucketName(bucket) {
+			WriteErrorWithResource(w, ErrInvalidBucketName, "/"+bucket)
+			return
+		}
+	} else if !ValidateBucketName(bucket) {
 		WriteErrorWithResource(w, ErrInvalidBucketName, "/"+bucket)
 		return
 	}
 
-	err := h.storage.CreateBucket(r.Context(), bucket)
+	body, s3Err := readXMLBody(r, maxXMLBodySize)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
+	}
+	ctx := r.Context()
+	if len(body) > 0 {
+		var config CreateBucketConfiguration
+		if s3Err := unmarshalXML(body, &config); s3Err != nil {
+			WriteErrorWithResource(w, s3Err, "/"+bucket)
+			return
+		}
+		// Directory buckets are created with Bucket.Type Directory and must use
+		// the directory bucket naming convention
+		if config.Bucket.Type != "" && (config.Bucket.Type == "Directory") != IsDirectoryBucket(bucket) {
+			WriteErrorWithResource(w, ErrInvalidBucketName, "/"+bucket)
+			return
+		}
+		// The location constraint of the server region is not recorded, so
+		// such buckets follow the configured region
+		if config.LocationConstraint != "" && config.LocationConstraint != h.region {
+			ctx = storage.WithBucketRegion(ctx, config.LocationConstraint)
+		}
+	}
+
+	err := h.storage.CreateBucket(ctx, bucket)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketAlreadyExists) {
-			WriteErrorWithResource(w, ErrBucketAlreadyOwnedByYou, "/"+bucket)
+			WriteErrorWithResource(w, h.bucketExistsError(r, bucket), "/"+bucket)
 			return
 		}
 		WriteErrorWithResource(w, ErrInternalError, "/"+bucket)
@@ -94,10 +138,36 @@ func (h *Handler) CreateBucket(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// bucketExistsError returns the error for creating an existing bucket:
+// BucketAlreadyOwnedByYou if the caller owns it, BucketAlreadyExists otherwise.
+// Unowned buckets count as owned by every caller.
+func (h *Handler) bucketExistsError(r *http.Request, bucket string) *S3Error {
+	existing, err := h.storage.HeadBucket(r.Context(), bucket)
+	if err != nil {
+		return ErrBucketAlreadyExists
+	}
+	if existing.Owner == "" || existing.Owner == storage.OwnerFromContext(r.Context()) {
+		return ErrBucketAlreadyOwnedByYou
+	}
+	return ErrBucketAlreadyExists
+}
+
 // DeleteBucket handles DELETE /{bucket} - DeleteBucket.
+// With ?force=true or the x-minio-force-delete header, the bucket is emptied first.
 func (h *Handler) DeleteBucket(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
+	if isForceDelete(r) {
+		// Emptying the bucket deletes all of its objects
+		if !h.authorize(w, r, "s3:DeleteObject", bucket, "*") {
+			return
+		}
+		if s3Err := h.emptyBucket(r, bucket); s3Err != nil {
+			WriteErrorWithResource(w, s3Err, "/"+bucket)
+			return
+		}
+	}
+
 	err := h.storage.DeleteBucket(r.Context(), bucket)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
@@ -115,11 +185,44 @@ func (h *Handler) DeleteBucket(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// isForceDelete reports whether a DeleteBucket request asks to remove the bucket's contents.
+func isForceDelete(r *http.Request) bool {
+	return r.URL.Query().Get("force") == "true" || r.Header.Get("x-minio-force-delete") == "true"
+}
+
+// emptyBucket removes all objects, versions and pending uploads of a bucket.
+// Buckets with object lock enabled are never force-deleted.
+func (h *Handler) emptyBucket(r *http.Request, bucket string) *S3Error {
+	locked, err := h.storage.GetBucketObjectLockEnabled(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			return ErrNoSuchBucket
+		}
+		return ErrInternalError
+	}
+	if locked {
+		return ErrForceDeleteObjectLock
+	}
+
+	if err := storage.EmptyBucket(r.Context(), h.storage, bucket); err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			return ErrNoSuchBucket
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to empty bucket")
+		return ErrInternalError
+	}
+	return nil
+}
+
 // HeadBucket handles HEAD /{bucket} - HeadBucket.
+// Like S3, it reports the region of existing buckets in x-amz-bucket-region,
+// also with 301 for requests signed for another region and with 403 for
+// buckets the caller may not access, which SDKs use to find the region of a
+// bucket and to tell whether it exists.
 func (h *Handler) HeadBucket(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
-	_, err := h.storage.HeadBucket(r.Context(), bucket)
+	info, err := h.storage.HeadBucket(r.Context(), bucket)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			w.WriteHeader(http.StatusNotFound)
@@ -129,9 +232,34 @@ func (h *Handler) HeadBucket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	region := h.bucketRegion(info.Region)
+	w.Header().Set("x-amz-bucket-region", region)
+	// Buckets in the region of the server are served for any signing region,
+	// as clients are often configured with an arbitrary one
+	if requested := signingRegion(r); info.Region != "" && requested != "" && requested != region {
+		w.WriteHeader(http.StatusMovedPermanently)
+		return
+	}
+	if !info.Accessible {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
+// requestOwner returns the owner reported for resources listed by the caller.
+// Authenticated callers are identified by their access key.
+func requestOwner(r *http.Request) Owner {
+	if id := storage.OwnerFromContext(r.Context()); id != "" {
+		return Owner{ID: id, DisplayName: id}
+	}
+	return Owner{
+		ID:          "owner-id",
+		DisplayName: "owner",
+	}
+}
+
 // ListBuckets handles GET / - ListBuckets.
 func (h *Handler) ListBuckets(w http.ResponseWriter, r *http.Request) {
 	buckets, err := h.storage.ListBuckets(r.Context())
@@ -142,10 +270,7 @@ func (h *Handler) ListBuckets(w http.ResponseWriter, r *http.Request) {
 
 	result := ListAllMyBucketsResult{
 		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
-		Owner: Owner{
-			ID:          "owner-id",
-			DisplayName: "owner",
-		},
+		Owner: requestOwner(r),
 		Buckets: Buckets{
 			Bucket: make([]BucketInfo, len(buckets)),
 		},
@@ -177,7 +302,7 @@ func (h *Handler) GetBucketLocation(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
 	// Check if bucket exists
-	_, err := h.storage.HeadBucket(r.Context(), bucket)
+	info, err := h.storage.HeadBucket(r.Context(), bucket)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -189,10 +314,11 @@ func (h *Handler) GetBucketLocation(w http.ResponseWriter, r *http.Request) {
 
 	// S3 returns empty LocationConstraint for us-east-1
 	// For other regions, it returns the region name
-	// JOG always uses us-east-1 as default
 	result := LocationConstraint{
-		Xmlns:    "http://s3.amazonaws.com/doc/2006-03-01/",
-		Location: "", // Empty for us-east-1
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+	}
+	if region := h.bucketRegion(info.Region); region != "us-east-1" {
+		result.Location = region
 	}
 
 	w.Header().Set("Content-Type", "application/xml")
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// checksumAlgorithms are the supported checksum algorithms.
+var checksumAlgorithms = []string{"CRC32", "CRC32C", "SHA1", "SHA256"}
+
+// checksumHeader returns the x-amz-checksum-* header of a checksum algorithm.
+func checksumHeader(algorithm string) string {
+	return "x-amz-checksum-" + strings.ToLower(algorithm)
+}
+
+// checksumError returns an InvalidRequest error with the given message.
+func checksumError(message string) *S3Error {
+	s3Err := *ErrInvalidRequest
+	s3Err.Message = message
+	return &s3Err
+}
+
+// withChecksumAlgorithm parses the x-amz-checksum-algorithm header of
+// CreateMultipartUpload and returns r with the algorithm in its context.
+func withChecksumAlgorithm(r *http.Request) (*http.Request, *S3Error) {
+	algorithm := r.Header.Get("x-amz-checksum-algorithm")
+	if algorithm == "" {
+		return r, nil
+	}
+	if !storage.ValidChecksumAlgorithm(algorithm) {
+		return nil, checksumError("Checksum algorithm provided is unsupported. Please try again with any of the valid types: [CRC32, CRC32C, SHA1, SHA256]")
+	}
+	checksum := &storage.Checksum{Algorithm: strings.ToUpper(algorithm)}
+	return r.WithContext(storage.WithChecksum(r.Context(), checksum)), nil
+}
+
+// withPartChecksum parses the x-amz-checksum-* and x-amz-sdk-checksum-algorithm
+// headers of UploadPart and returns r with the checksum in its context. A
+// checksum algorithm without a checksum value has the checksum computed.
+func withPartChecksum(r *http.Request) (*http.Request, *S3Error) {
+	var checksum *storage.Checksum
+	for _, algorithm := range checksumAlgorithms {
+		value := r.Header.Get(checksumHeader(algorithm))
+		if value == "" {
+			continue
+		}
+		if checksum != nil {
+			return nil, checksumError("Expecting a single x-amz-checksum- header. Multiple checksum Types are not allowed.")
+		}
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(raw) != storage.NewChecksumHash(algorithm).Size() {
+			return nil, checksumError("Value for " + checksumHeader(algorithm) + " header is invalid.")
+		}
+		checksum = &storage.Checksum{Algorithm: algorithm, Value: value}
+	}
+
+	if algorithm := r.Header.Get("x-amz-sdk-checksum-algorithm"); algorithm != "" {
+		switch {
+		case !storage.ValidChecksumAlgorithm(algorithm):
+			return nil, checksumError("Checksum algorithm provided is unsupported. Please try again with any of the valid types: [CRC32, CRC32C, SHA1, SHA256]")
+		case checksum == nil:
+			checksum = &storage.Checksum{Algorithm: strings.ToUpper(algorithm)}
+		case !strings.EqualFold(algorithm, checksum.Algorithm):
+			return nil, checksumError("Value for x-amz-sdk-checksum-algorithm header is invalid.")
+		}
+	}
+
+	if checksum == nil {
+		return r, nil
+	}
+	return r.WithContext(storage.WithChecksum(r.Context(), checksum)), nil
+}
+
+// writeChecksumError writes the response to a part upload whose checksum was
+// rejected, and reports whether err was such a failure.
+func writeChecksumError(w http.ResponseWriter, err error, resource string) bool {
+	switch {
+	case errors.Is(err, storage.ErrBadDigest):
+		WriteErrorWithResource(w, ErrBadDigest, resource)
+	case errors.Is(err, storage.ErrChecksumAlgorithmMismatch):
+		WriteErrorWithResource(w, checksumError("Checksum Type mismatch occurred, the checksum type of the part does not match the checksum type of the upload."), resource)
+	default:
+		return false
+	}
+	return true
+}
+
+// setChecksumHeader sets the x-amz-checksum-* header of a checksum, if any.
+func setChecksumHeader(w http.ResponseWriter, algorithm, value string) {
+	if algorithm != "" && value != "" {
+		w.Header().Set(checksumHeader(algorithm), value)
+	}
+}
+
+// setObjectChecksumHeader sets the x-amz-checksum-* header of the composite
+// checksum of a multipart object when the request has x-amz-checksum-mode
+// set to ENABLED.
+func (h *Handler) setObjectChecksumHeader(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	if !strings.EqualFold(r.Header.Get("x-amz-checksum-mode"), "ENABLED") {
+		return
+	}
+	parts, err := h.storage.GetObjectParts(r.Context(), bucket, key)
+	if err != nil {
+		log.Warn().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to get object parts")
+		return
+	}
+	if checksum := objectChecksum(parts); checksum != nil {
+		algorithm, value := checksum.value()
+		setChecksumHeader(w, algorithm, value)
+	}
+}
+
+// newChecksum returns a Checksum with the field of algorithm set to value,
+// or nil if the value is empty or the algorithm unknown.
+func newChecksum(algorithm, value string) *Checksum {
+	if value == "" {
+		return nil
+	}
+	switch strings.ToUpper(algorithm) {
+	case "CRC32":
+		return &Checksum{ChecksumCRC32: value}
+	case "CRC32C":
+		return &Checksum{ChecksumCRC32C: value}
+	case "SHA1":
+		return &Checksum{ChecksumSHA1: value}
+	case "SHA256":
+		return &Checksum{ChecksumSHA256: value}
+	default:
+		return nil
+	}
+}
+
+// value returns the algorithm and value of the checksum field that is set.
+func (c Checksum) value() (algorithm, value string) {
+	switch {
+	case c.ChecksumCRC32 != "":
+		return "CRC32", c.ChecksumCRC32
+	case c.ChecksumCRC32C != "":
+		return "CRC32C", c.ChecksumCRC32C
+	case c.ChecksumSHA1 != "":
+		return "SHA1", c.ChecksumSHA1
+	case c.ChecksumSHA256 != "":
+		return "SHA256", c.ChecksumSHA256
+	default:
+		return "", ""
+	}
+}
+
+// objectChecksum returns the composite checksum of a multipart object: the
+// checksum of the concatenated part checksums followed by the number of parts.
+// It returns nil unless every part was uploaded with the same algorithm.
+func objectChecksum(parts []storage.ObjectPart) *Checksum {
+	if len(parts) == 0 {
+		return nil
+	}
+	algorithm := parts[0].ChecksumAlgorithm
+	h := storage.NewChecksumHash(algorithm)
+	if h == nil {
+		return nil
+	}
+	for _, part := range parts {
+		if part.ChecksumAlgorithm != algorithm {
+			return nil
+		}
+		raw, err := base64.StdEncoding.DecodeString(part.Checksum)
+		if err != nil || len(raw) == 0 {
+			return nil
+		}
+		h.Write(raw)
+	}
+	return newChecksum(algorithm, base64.StdEncoding.EncodeToString(h.Sum(nil))+"-"+strconv.Itoa(len(parts)))
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/kumasuke/jog/internal/storage"
+)
+
+func TestObjectChecksum_Composite(t *testing.T) {
+	sumA := sha256.Sum256([]byte("part-a"))
+	sumB := sha256.Sum256([]byte("part-b"))
+	parts := []storage.ObjectPart{
+		{PartNumber: 1, ChecksumAlgorithm: "SHA256", Checksum: base64.StdEncoding.EncodeToString(sumA[:])},
+		{PartNumber: 2, ChecksumAlgorithm: "SHA256", Checksum: base64.StdEncoding.EncodeToString(sumB[:])},
+	}
+
+	composite := sha256.Sum256(append(sumA[:], sumB[:]...))
+	expected := base64.StdEncoding.EncodeToString(composite[:]) + "-2"
+
+	checksum := objectChecksum(parts)
+	if checksum == nil {
+		t.Fatal("expected a checksum")
+	}
+	if checksum.ChecksumSHA256 != expected {
+		t.Errorf("expected %q, got %q", expected, checksum.ChecksumSHA256)
+	}
+}
+
+func TestObjectChecksum_MixedAlgorithms(t *testing.T) {
+	parts := []storage.ObjectPart{
+		{PartNumber: 1, ChecksumAlgorithm: "CRC32", Checksum: "AAAAAA=="},
+		{PartNumber: 2},
+	}
+	if checksum := objectChecksum(parts); checksum != nil {
+		t.Errorf("expected no checksum, got %+v", checksum)
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// CompressionConfigurationXML represents the XML format for the compression configuration (JOG extension).
+type CompressionConfigurationXML struct {
+	XMLName   xml.Name `xml:"CompressionConfiguration"`
+	Xmlns     string   `xml:"xmlns,attr,omitempty"`
+	Algorithm string   `xml:"Algorithm"`
+}
+
+// PutBucketCompression handles PUT /{bucket}?compression - PutBucketCompression.
+func (h *Handler) PutBucketCompression(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	var xmlConfig CompressionConfigurationXML
+	if s3Err := decodeXMLBody(r, &xmlConfig, maxXMLBodySize); s3Err != nil {
+		WriteError(w, s3Err)
+		return
+	}
+
+	if xmlConfig.Algorithm == "" {
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+		return
+	}
+	algorithm, err := storage.ParseCompressionAlgorithm(xmlConfig.Algorithm)
+	if err != nil {
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+		return
+	}
+
+	config := &storage.CompressionConfiguration{
+		Algorithm: algorithm,
+	}
+
+	err = h.storage.PutBucketCompression(r.Context(), bucket, config)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to put bucket compression")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketCompression handles GET /{bucket}?compression - GetBucketCompression.
+func (h *Handler) GetBucketCompression(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	config, err := h.storage.GetBucketCompression(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNoSuchCompressionConfiguration) {
+			WriteErrorWithResource(w, ErrNoSuchCompressionConfiguration, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket compression")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	xmlConfig := CompressionConfigurationXML{
+		Xmlns:     "http://s3.amazonaws.com/doc/2006-03-01/",
+		Algorithm: string(config.Algorithm),
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(xmlConfig); err != nil {
+		log.Error().Err(err).Msg("Failed to encode GetBucketCompression response")
+	}
+}
+
+// DeleteBucketCompression handles DELETE /{bucket}?compression - DeleteBucketCompression.
+func (h *Handler) DeleteBucketCompression(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	err := h.storage.DeleteBucketCompression(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to delete bucket compression")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// ContentTypeConfigurationXML represents the XML format for the content type inference rules (JOG extension).
+type ContentTypeConfigurationXML struct {
+	XMLName xml.Name             `xml:"ContentTypeConfiguration"`
+	Xmlns   string               `xml:"xmlns,attr,omitempty"`
+	Rules   []ContentTypeRuleXML `xml:"Rule"`
+}
+
+// ContentTypeRuleXML represents a content type inference rule in XML.
+type ContentTypeRuleXML struct {
+	Extension   string `xml:"Extension"`
+	ContentType string `xml:"ContentType"`
+}
+
+// SetContentTypes sets the server-wide table used to infer the content type
+// of objects uploaded without one. Bucket rules take precedence over it.
+func (h *Handler) SetContentTypes(table storage.ContentTypeTable) {
+	h.contentTypes = table
+}
+
+// objectContentType returns the Content-Type header of an upload or, if it
+// is missing, the content type inferred from the key.
+func (h *Handler) objectContentType(r *http.Request, bucket, key string) string {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		return contentType
+	}
+
+	var bucketTypes storage.ContentTypeTable
+	config, err := h.storage.GetBucketContentTypes(r.Context(), bucket)
+	if err == nil {
+		bucketTypes = config.Table()
+	} else if !errors.Is(err, storage.ErrNoSuchContentTypeConfiguration) && !errors.Is(err, storage.ErrBucketNotFound) {
+		log.Warn().Err(err).Str("bucket", bucket).Msg("Failed to get bucket content types")
+	}
+	return storage.InferContentType(key, bucketTypes, h.contentTypes)
+}
+
+// PutBucketContentTypes handles PUT /{bucket}?content-types - PutBucketContentTypes.
+func (h *Handler) PutBucketContentTypes(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	var xmlConfig ContentTypeConfigurationXML
+	if s3Err := decodeXMLBody(r, &xmlConfig, maxXMLBodySize); s3Err != nil {
+		WriteError(w, s3Err)
+		return
+	}
+	if len(xmlConfig.Rules) == 0 {
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+		return
+	}
+
+	config := &storage.ContentTypeConfiguration{}
+	for _, xmlRule := range xmlConfig.Rules {
+		rule, err := storage.NewContentTypeRule(xmlRule.Extension, xmlRule.ContentType)
+		if err != nil {
+			s3Err := *ErrInvalidArgument
+			s3Err.Message = err.Error()
+			WriteErrorWithResource(w, &s3Err, "/"+bucket)
+			return
+		}
+		config.Rules = append(config.Rules, rule)
+	}
+
+	err := h.storage.PutBucketContentTypes(r.Context(), bucket, config)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to put bucket content types")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketContentTypes handles GET /{bucket}?content-types - GetBucketContentTypes.
+func (h *Handler) GetBucketContentTypes(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	config, err := h.storage.GetBucketContentTypes(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNoSuchContentTypeConfiguration) {
+			WriteErrorWithResource(w, ErrNoSuchContentTypeConfiguration, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket content types")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	xmlConfig := ContentTypeConfigurationXML{
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+	}
+	for _, rule := range config.Rules {
+		xmlConfig.Rules = append(xmlConfig.Rules, ContentTypeRuleXML{
+			Extension:   rule.Extension,
+			ContentType: rule.ContentType,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(xmlConfig); err != nil {
+		log.Error().Err(err).Msg("Failed to encode GetBucketContentTypes response")
+	}
+}
+
+// DeleteBucketContentTypes handles DELETE /{bucket}?content-types - DeleteBucketContentTypes.
+func (h *Handler) DeleteBucketContentTypes(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	err := h.storage.DeleteBucketContentTypes(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to delete bucket content types")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
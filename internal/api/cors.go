@@ -3,7 +3,6 @@ package api
 import (
 	"encoding/xml"
 	"errors"
-	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -34,15 +33,9 @@ func (h *Handler) PutBucketCors(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket)
-		return
-	}
-
 	var corsConfig CORSConfiguration
-	if err := xml.Unmarshal(body, &corsConfig); err != nil {
-		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket)
+	if s3Err := decodeXMLBody(r, &corsConfig, maxXMLBodySize); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
 		return
 	}
 
@@ -63,7 +56,7 @@ func (h *Handler) PutBucketCors(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Store CORS configuration
-	err = h.storage.PutBucketCors(r.Context(), bucket, storageCors)
+	err := h.storage.PutBucketCors(r.Context(), bucket, storageCors)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
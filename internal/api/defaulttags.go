@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// withDefaultTags merges the default tags of a bucket into the tags requested
+// for a new object. Requested tags win over default tags with the same key,
+// and default tags are dropped once the object has the maximum number of tags.
+func (h *Handler) withDefaultTags(r *http.Request, bucket string, tags []storage.Tag) []storage.Tag {
+	defaults, err := h.storage.GetBucketDefaultTags(r.Context(), bucket)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNoSuchDefaultTagsConfiguration) && !errors.Is(err, storage.ErrBucketNotFound) {
+			log.Warn().Err(err).Str("bucket", bucket).Msg("Failed to get bucket default tags")
+		}
+		return tags
+	}
+
+	requested := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		requested[tag.Key] = true
+	}
+	merged := append([]storage.Tag{}, tags...)
+	for _, tag := range defaults {
+		if len(merged) >= maxTagsPerResource {
+			break
+		}
+		if !requested[tag.Key] {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// PutBucketDefaultTags handles PUT /{bucket}?default-tags - PutBucketDefaultTags.
+func (h *Handler) PutBucketDefaultTags(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	var tagging Tagging
+	if s3Err := decodeXMLBody(r, &tagging, maxXMLBodySize); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
+	}
+
+	tags := make([]storage.Tag, len(tagging.TagSet.Tags))
+	seen := make(map[string]bool, len(tags))
+	for i, t := range tagging.TagSet.Tags {
+		if t.Key == "" || seen[t.Key] {
+			WriteErrorWithResource(w, ErrInvalidTag, "/"+bucket)
+			return
+		}
+		seen[t.Key] = true
+		tags[i] = storage.Tag{Key: t.Key, Value: t.Value}
+	}
+	if len(tags) == 0 {
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+		return
+	}
+	if err := validateTags(tags); err != nil {
+		s3Err := *ErrInvalidTag
+		s3Err.Message = err.Error()
+		WriteErrorWithResource(w, &s3Err, "/"+bucket)
+		return
+	}
+
+	err := h.storage.PutBucketDefaultTags(r.Context(), bucket, tags)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to put bucket default tags")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketDefaultTags handles GET /{bucket}?default-tags - GetBucketDefaultTags.
+func (h *Handler) GetBucketDefaultTags(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	tags, err := h.storage.GetBucketDefaultTags(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNoSuchDefaultTagsConfiguration) {
+			WriteErrorWithResource(w, ErrNoSuchDefaultTagsConfiguration, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket default tags")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	tagging := Tagging{
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+	}
+	for _, tag := range tags {
+		tagging.TagSet.Tags = append(tagging.TagSet.Tags, TagXML{Key: tag.Key, Value: tag.Value})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(tagging); err != nil {
+		log.Error().Err(err).Msg("Failed to encode GetBucketDefaultTags response")
+	}
+}
+
+// DeleteBucketDefaultTags handles DELETE /{bucket}?default-tags - DeleteBucketDefaultTags.
+func (h *Handler) DeleteBucketDefaultTags(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	err := h.storage.DeleteBucketDefaultTags(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to delete bucket default tags")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
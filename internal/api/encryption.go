@@ -3,7 +3,6 @@ package api
 import (
 	"encoding/xml"
 	"errors"
-	"io"
 	"net/http"
 
 	"github.com/kumasuke/jog/internal/storage"
@@ -34,15 +33,9 @@ func (h *Handler) PutBucketEncryption(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket)
-		return
-	}
-
 	var encConfig ServerSideEncryptionConfiguration
-	if err := xml.Unmarshal(body, &encConfig); err != nil {
-		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket)
+	if s3Err := decodeXMLBody(r, &encConfig, maxXMLBodySize); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
 		return
 	}
 
@@ -67,7 +60,7 @@ func (h *Handler) PutBucketEncryption(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Store encryption configuration
-	err = h.storage.PutBucketEncryption(r.Context(), bucket, storageConfig)
+	err := h.storage.PutBucketEncryption(r.Context(), bucket, storageConfig)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -5,18 +5,31 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"net/http"
+	"os"
+	"strings"
 
+	"github.com/kumasuke/jog/internal/storage"
 	"github.com/rs/zerolog/log"
 )
 
+// RequestIDHeader is the response header holding the ID of a request, which
+// error responses repeat in their RequestId element.
+const RequestIDHeader = "x-amz-request-id"
+
 // S3Error represents an S3 error response.
 type S3Error struct {
-	XMLName   xml.Name `xml:"Error"`
-	Code      string   `xml:"Code"`
-	Message   string   `xml:"Message"`
-	Resource  string   `xml:"Resource,omitempty"`
-	RequestID string   `xml:"RequestId"`
+	XMLName    xml.Name `xml:"Error"`
+	Code       string   `xml:"Code"`
+	Message    string   `xml:"Message"`
+	BucketName string   `xml:"BucketName,omitempty"`
+	Key        string   `xml:"Key,omitempty"`
+	VersionID  string   `xml:"VersionId,omitempty"`
+	UploadID   string   `xml:"UploadId,omitempty"`
+	Region     string   `xml:"Region,omitempty"`
+	Resource   string   `xml:"Resource,omitempty"`
+	RequestID  string   `xml:"RequestId"`
 
 	HTTPStatus int `xml:"-"`
 }
@@ -25,217 +38,337 @@ func (e *S3Error) Error() string {
 	return e.Message
 }
 
-// Common S3 errors
-var (
-	ErrAccessDenied = &S3Error{
-		Code:       "AccessDenied",
-		Message:    "Access Denied",
-		HTTPStatus: http.StatusForbidden,
-	}
-
-	ErrBucketAlreadyExists = &S3Error{
-		Code:       "BucketAlreadyExists",
-		Message:    "The requested bucket name is not available. The bucket namespace is shared by all users of the system. Please select a different name and try again.",
-		HTTPStatus: http.StatusConflict,
-	}
-
-	ErrBucketAlreadyOwnedByYou = &S3Error{
-		Code:       "BucketAlreadyOwnedByYou",
-		Message:    "Your previous request to create the named bucket succeeded and you already own it.",
-		HTTPStatus: http.StatusConflict,
-	}
-
-	ErrBucketNotEmpty = &S3Error{
-		Code:       "BucketNotEmpty",
-		Message:    "The bucket you tried to delete is not empty.",
-		HTTPStatus: http.StatusConflict,
-	}
+// errorElements are the elements of an error response that identify the
+// resource of the error.
+type errorElements uint8
 
-	ErrInvalidBucketName = &S3Error{
-		Code:       "InvalidBucketName",
-		Message:    "The specified bucket is not valid.",
-		HTTPStatus: http.StatusBadRequest,
-	}
-
-	ErrNoSuchBucket = &S3Error{
-		Code:       "NoSuchBucket",
-		Message:    "The specified bucket does not exist.",
-		HTTPStatus: http.StatusNotFound,
-	}
-
-	ErrNoSuchKey = &S3Error{
-		Code:       "NoSuchKey",
-		Message:    "The specified key does not exist.",
-		HTTPStatus: http.StatusNotFound,
-	}
-
-	ErrInvalidAccessKeyId = &S3Error{
-		Code:       "InvalidAccessKeyId",
-		Message:    "The AWS Access Key Id you provided does not exist in our records.",
-		HTTPStatus: http.StatusForbidden,
-	}
-
-	ErrSignatureDoesNotMatch = &S3Error{
-		Code:       "SignatureDoesNotMatch",
-		Message:    "The request signature we calculated does not match the signature you provided.",
-		HTTPStatus: http.StatusForbidden,
-	}
-
-	ErrRequestTimeTooSkewed = &S3Error{
-		Code:       "RequestTimeTooSkewed",
-		Message:    "The difference between the request time and the server's time is too large.",
-		HTTPStatus: http.StatusForbidden,
-	}
-
-	ErrInvalidRequest = &S3Error{
-		Code:       "InvalidRequest",
-		Message:    "Invalid Request",
-		HTTPStatus: http.StatusBadRequest,
-	}
-
-	ErrMethodNotAllowed = &S3Error{
-		Code:       "MethodNotAllowed",
-		Message:    "The specified method is not allowed against this resource.",
-		HTTPStatus: http.StatusMethodNotAllowed,
-	}
-
-	ErrInternalError = &S3Error{
-		Code:       "InternalError",
-		Message:    "We encountered an internal error. Please try again.",
-		HTTPStatus: http.StatusInternalServerError,
-	}
-
-	ErrInvalidRange = &S3Error{
-		Code:       "InvalidRange",
-		Message:    "The requested range is not satisfiable.",
-		HTTPStatus: http.StatusRequestedRangeNotSatisfiable,
-	}
-
-	ErrMissingContentLength = &S3Error{
-		Code:       "MissingContentLength",
-		Message:    "You must provide the Content-Length HTTP header.",
-		HTTPStatus: http.StatusLengthRequired,
-	}
-
-	ErrNoSuchUpload = &S3Error{
-		Code:       "NoSuchUpload",
-		Message:    "The specified upload does not exist. The upload ID may be invalid, or the upload may have been aborted or completed.",
-		HTTPStatus: http.StatusNotFound,
-	}
-
-	ErrInvalidPart = &S3Error{
-		Code:       "InvalidPart",
-		Message:    "One or more of the specified parts could not be found. The part may not have been uploaded, or the specified entity tag may not match the part's entity tag.",
-		HTTPStatus: http.StatusBadRequest,
-	}
-
-	ErrInvalidPartOrder = &S3Error{
-		Code:       "InvalidPartOrder",
-		Message:    "The list of parts was not in ascending order. Parts must be ordered by part number.",
-		HTTPStatus: http.StatusBadRequest,
-	}
-
-	ErrEntityTooSmall = &S3Error{
-		Code:       "EntityTooSmall",
-		Message:    "Your proposed upload is smaller than the minimum allowed object size.",
-		HTTPStatus: http.StatusBadRequest,
-	}
+const (
+	elementBucketName errorElements = 1 << iota
+	elementKey
+	elementVersionID
+	elementUploadID
+)
 
-	ErrMalformedXML = &S3Error{
-		Code:       "MalformedXML",
-		Message:    "The XML you provided was not well-formed or did not validate against our published schema.",
-		HTTPStatus: http.StatusBadRequest,
-	}
+// errorCode is the registration of an error code.
+type errorCode struct {
+	status   int
+	elements errorElements
+}
 
-	ErrNoSuchTagSet = &S3Error{
-		Code:       "NoSuchTagSet",
-		Message:    "The TagSet does not exist.",
-		HTTPStatus: http.StatusNotFound,
-	}
+// errorCodes is the registry of the error codes JOG returns, with the HTTP
+// status of each code and the elements S3 includes in its error responses.
+// Every S3Error is created with NewError, which only accepts registered
+// codes, so the status of a code is the same in every response.
+var errorCodes = map[string]errorCode{
+	"AccessDenied":                         {status: http.StatusForbidden},
+	"AuthorizationHeaderMalformed":         {status: http.StatusBadRequest},
+	"AuthorizationQueryParametersError":    {status: http.StatusBadRequest},
+	"BadDigest":                            {status: http.StatusBadRequest},
+	"BucketAlreadyExists":                  {status: http.StatusConflict, elements: elementBucketName},
+	"BucketAlreadyOwnedByYou":              {status: http.StatusConflict, elements: elementBucketName},
+	"BucketNotEmpty":                       {status: http.StatusConflict, elements: elementBucketName},
+	"EntityTooSmall":                       {status: http.StatusBadRequest},
+	"ExpiredToken":                         {status: http.StatusBadRequest},
+	"IncompleteBody":                       {status: http.StatusBadRequest},
+	"InternalError":                        {status: http.StatusInternalServerError},
+	"InvalidAccessKeyId":                   {status: http.StatusForbidden},
+	"InvalidArgument":                      {status: http.StatusBadRequest},
+	"InvalidBucketName":                    {status: http.StatusBadRequest, elements: elementBucketName},
+	"InvalidBucketState":                   {status: http.StatusConflict},
+	"InvalidPart":                          {status: http.StatusBadRequest},
+	"InvalidPartNumber":                    {status: http.StatusRequestedRangeNotSatisfiable},
+	"InvalidPartOrder":                     {status: http.StatusBadRequest},
+	"InvalidRange":                         {status: http.StatusRequestedRangeNotSatisfiable},
+	"InvalidRequest":                       {status: http.StatusBadRequest},
+	"InvalidTag":                           {status: http.StatusBadRequest},
+	"InvalidToken":                         {status: http.StatusBadRequest},
+	"KMS.InvalidCiphertextException":       {status: http.StatusBadRequest},
+	"KMS.NotFoundException":                {status: http.StatusBadRequest},
+	"KeyTooLongError":                      {status: http.StatusBadRequest},
+	"MalformedPolicy":                      {status: http.StatusBadRequest},
+	"MalformedXML":                         {status: http.StatusBadRequest},
+	"MaxMessageLengthExceeded":             {status: http.StatusBadRequest},
+	"MetadataTooLarge":                     {status: http.StatusBadRequest},
+	"MethodNotAllowed":                     {status: http.StatusMethodNotAllowed},
+	"MissingContentLength":                 {status: http.StatusLengthRequired},
+	"NoSuchBucket":                         {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchBucketPolicy":                   {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchCORSConfiguration":              {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchKey":                            {status: http.StatusNotFound, elements: elementKey},
+	"NoSuchLifecycleConfiguration":         {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchObjectLockConfiguration":        {status: http.StatusNotFound},
+	"NoSuchTagSet":                         {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchUpload":                         {status: http.StatusNotFound, elements: elementUploadID},
+	"NoSuchVersion":                        {status: http.StatusNotFound, elements: elementKey | elementVersionID},
+	"NoSuchWebsiteConfiguration":           {status: http.StatusNotFound, elements: elementBucketName},
+	"NotImplemented":                       {status: http.StatusNotImplemented},
+	"ObjectLockConfigurationNotFoundError": {status: http.StatusNotFound, elements: elementBucketName},
+	"OperationAborted":                     {status: http.StatusConflict},
+	"RequestHeaderSectionTooLarge":         {status: http.StatusBadRequest},
+	"RequestTimeTooSkewed":                 {status: http.StatusForbidden},
+	"RequestTimeout":                       {status: http.StatusBadRequest},
+	"ServerSideEncryptionConfigurationNotFoundError": {status: http.StatusNotFound, elements: elementBucketName},
+	"ServiceUnavailable":                             {status: http.StatusServiceUnavailable},
+	"SignatureDoesNotMatch":                          {status: http.StatusForbidden},
+	"SlowDown":                                       {status: http.StatusServiceUnavailable},
+
+	// JOG extensions
+	"InvalidJobState":                     {status: http.StatusConflict},
+	"NoSuchAccessKey":                     {status: http.StatusNotFound},
+	"NoSuchCompressionConfiguration":      {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchContentTypeConfiguration":      {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchDefaultTagsConfiguration":      {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchJob":                           {status: http.StatusNotFound},
+	"NoSuchResponseHeaderConfiguration":   {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchServiceAccount":                {status: http.StatusNotFound},
+	"NoSuchTieringConfiguration":          {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchTrashConfiguration":            {status: http.StatusNotFound, elements: elementBucketName},
+	"NoSuchTrashEntry":                    {status: http.StatusNotFound},
+	"NoSuchVersionRetentionConfiguration": {status: http.StatusNotFound, elements: elementBucketName},
+	"ObjectAlreadyExists":                 {status: http.StatusConflict},
+	"ObjectNotAppendable":                 {status: http.StatusConflict},
+	"PartOffsetMismatch":                  {status: http.StatusConflict},
+	"PositionNotEqualToLength":            {status: http.StatusConflict},
+}
 
-	ErrNoSuchCORSConfiguration = &S3Error{
-		Code:       "NoSuchCORSConfiguration",
-		Message:    "The CORS configuration does not exist.",
-		HTTPStatus: http.StatusNotFound,
+// NewError returns an error with a registered code and the HTTP status of the
+// code. It panics if the code is not registered.
+func NewError(code, message string) *S3Error {
+	registered, ok := errorCodes[code]
+	if !ok {
+		panic("api: unregistered error code " + code)
 	}
+	return &S3Error{Code: code, Message: message, HTTPStatus: registered.status}
+}
 
-	ErrInvalidTag = &S3Error{
-		Code:       "InvalidTag",
-		Message:    "The tag does not comply with tag restrictions.",
-		HTTPStatus: http.StatusBadRequest,
-	}
+// Common S3 errors
+var (
+	ErrAccessDenied                                   = NewError("AccessDenied", "Access Denied")
+	ErrBucketAlreadyExists                            = NewError("BucketAlreadyExists", "The requested bucket name is not available. The bucket namespace is shared by all users of the system. Please select a different name and try again.")
+	ErrBucketAlreadyOwnedByYou                        = NewError("BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.")
+	ErrBucketNotEmpty                                 = NewError("BucketNotEmpty", "The bucket you tried to delete is not empty.")
+	ErrInvalidBucketName                              = NewError("InvalidBucketName", "The specified bucket is not valid.")
+	ErrNoSuchBucket                                   = NewError("NoSuchBucket", "The specified bucket does not exist.")
+	ErrNoSuchKey                                      = NewError("NoSuchKey", "The specified key does not exist.")
+	ErrNoSuchVersion                                  = NewError("NoSuchVersion", "The specified version does not exist.")
+	ErrInvalidAccessKeyId                             = NewError("InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records.")
+	ErrAuthorizationHeaderMalformed                   = NewError("AuthorizationHeaderMalformed", "The authorization header is malformed.")
+	ErrAuthorizationQueryParametersError              = NewError("AuthorizationQueryParametersError", "Error parsing the X-Amz-Credential parameter.")
+	ErrSignatureDoesNotMatch                          = NewError("SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.")
+	ErrExpiredToken                                   = NewError("ExpiredToken", "The provided token has expired.")
+	ErrInvalidToken                                   = NewError("InvalidToken", "The provided token is malformed or otherwise invalid.")
+	ErrRequestTimeTooSkewed                           = NewError("RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.")
+	ErrInvalidRequest                                 = NewError("InvalidRequest", "Invalid Request")
+	ErrForceDeleteObjectLock                          = NewError("InvalidRequest", "Force delete is not allowed on buckets with object lock enabled.")
+	ErrMethodNotAllowed                               = NewError("MethodNotAllowed", "The specified method is not allowed against this resource.")
+	ErrNotImplemented                                 = NewError("NotImplemented", "A header you provided implies functionality that is not implemented.")
+	ErrInternalError                                  = NewError("InternalError", "We encountered an internal error. Please try again.")
+	ErrServiceUnavailable                             = NewError("ServiceUnavailable", "The server is in maintenance mode and does not accept writes. Please try again later.")
+	ErrReadOnlyMode                                   = NewError("AccessDenied", "The server is in read-only mode.")
+	ErrSlowDown                                       = NewError("SlowDown", "Please reduce your request rate.")
+	ErrInvalidRange                                   = NewError("InvalidRange", "The requested range is not satisfiable.")
+	ErrInvalidPartNumber                              = NewError("InvalidPartNumber", "The requested partnumber is not satisfiable.")
+	ErrPartNumberWithRange                            = NewError("InvalidRequest", "Cannot specify both Range header and partNumber query parameter.")
+	ErrRequestTimeout                                 = NewError("RequestTimeout", "Your socket connection to the server was not read from or written to within the timeout period.")
+	ErrMissingContentLength                           = NewError("MissingContentLength", "You must provide the Content-Length HTTP header.")
+	ErrNoSuchUpload                                   = NewError("NoSuchUpload", "The specified upload does not exist. The upload ID may be invalid, or the upload may have been aborted or completed.")
+	ErrInvalidPart                                    = NewError("InvalidPart", "One or more of the specified parts could not be found. The part may not have been uploaded, or the specified entity tag may not match the part's entity tag.")
+	ErrInvalidPartOrder                               = NewError("InvalidPartOrder", "The list of parts was not in ascending order. Parts must be ordered by part number.")
+	ErrOperationAborted                               = NewError("OperationAborted", "A conflicting conditional operation is currently in progress against this resource. Try again.")
+	ErrBadDigest                                      = NewError("BadDigest", "The checksum you specified did not match the calculated checksum.")
+	ErrIncompleteBody                                 = NewError("IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.")
+	ErrEntityTooSmall                                 = NewError("EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.")
+	ErrMalformedXML                                   = NewError("MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.")
+	ErrNoSuchTagSet                                   = NewError("NoSuchTagSet", "The TagSet does not exist.")
+	ErrNoSuchCORSConfiguration                        = NewError("NoSuchCORSConfiguration", "The CORS configuration does not exist.")
+	ErrInvalidTag                                     = NewError("InvalidTag", "The tag does not comply with tag restrictions.")
+	ErrMaxMessageLengthExceeded                       = NewError("MaxMessageLengthExceeded", "Your request was too big.")
+	ErrMetadataTooLarge                               = NewError("MetadataTooLarge", "Your metadata headers exceed the maximum allowed metadata size.")
+	ErrRequestHeaderSectionTooLarge                   = NewError("RequestHeaderSectionTooLarge", "Your request header section exceeds the maximum allowed size.")
+	ErrInvalidArgument                                = NewError("InvalidArgument", "Invalid Argument")
+	ErrKeyTooLong                                     = NewError("KeyTooLongError", "Your key is too long.")
+	ErrKMSNotFound                                    = NewError("KMS.NotFoundException", "The specified KMS key does not exist.")
+	ErrKMSInvalidCiphertext                           = NewError("KMS.InvalidCiphertextException", "The data key of the object could not be decrypted with its KMS key.")
+	ErrServerSideEncryptionConfigurationNotFoundError = NewError("ServerSideEncryptionConfigurationNotFoundError", "The server side encryption configuration was not found.")
+	ErrNoSuchLifecycleConfiguration                   = NewError("NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist.")
+	ErrObjectLockConfigurationNotFoundError           = NewError("ObjectLockConfigurationNotFoundError", "Object Lock configuration does not exist for this bucket.")
+	ErrNoSuchObjectLockConfiguration                  = NewError("NoSuchObjectLockConfiguration", "The specified object does not have an ObjectLock configuration.")
+	ErrNoSuchBucketPolicy                             = NewError("NoSuchBucketPolicy", "The bucket policy does not exist.")
+	ErrNoSuchWebsiteConfiguration                     = NewError("NoSuchWebsiteConfiguration", "The specified bucket does not have a website configuration.")
+	ErrNoSuchTieringConfiguration                     = NewError("NoSuchTieringConfiguration", "The specified bucket does not have a tiering configuration.")
+	ErrNoSuchCompressionConfiguration                 = NewError("NoSuchCompressionConfiguration", "The specified bucket does not have a compression configuration.")
+	ErrNoSuchContentTypeConfiguration                 = NewError("NoSuchContentTypeConfiguration", "The specified bucket does not have a content type configuration.")
+	ErrNoSuchDefaultTagsConfiguration                 = NewError("NoSuchDefaultTagsConfiguration", "The specified bucket does not have default tags.")
+	ErrNoSuchResponseHeaderConfiguration              = NewError("NoSuchResponseHeaderConfiguration", "The specified bucket does not have a response header configuration.")
+	ErrNoSuchTrashConfiguration                       = NewError("NoSuchTrashConfiguration", "The specified bucket does not have a trash configuration.")
+	ErrNoSuchVersionRetentionConfiguration            = NewError("NoSuchVersionRetentionConfiguration", "The specified bucket does not have a version retention configuration.")
+	ErrInvalidBucketState                             = NewError("InvalidBucketState", "The request is not valid with the current state of the bucket.")
+	ErrMalformedPolicy                                = NewError("MalformedPolicy", "This policy contains invalid Json.")
+	ErrPositionNotEqualToLength                       = NewError("PositionNotEqualToLength", "The position of append does not equal the current length of the object.")
+	ErrObjectNotAppendable                            = NewError("ObjectNotAppendable", "The object you specified is not appendable.")
+	ErrObjectImmutable                                = NewError("AccessDenied", "The object is in a write-once bucket and cannot be overwritten or deleted.")
+	ErrPartOffsetMismatch                             = NewError("PartOffsetMismatch", "The range start does not equal the number of bytes of the part received so far.")
+)
 
-	ErrInvalidArgument = &S3Error{
-		Code:       "InvalidArgument",
-		Message:    "Invalid Argument",
-		HTTPStatus: http.StatusBadRequest,
-	}
+// storageErrors translates the errors of the storage package that have an S3
+// equivalent. Errors that are not listed are internal errors.
+var storageErrors = []struct {
+	err   error
+	s3Err *S3Error
+}{
+	{storage.ErrBucketNotFound, ErrNoSuchBucket},
+	{storage.ErrBucketAlreadyExists, ErrBucketAlreadyOwnedByYou},
+	{storage.ErrBucketNotEmpty, ErrBucketNotEmpty},
+	{storage.ErrInvalidBucketName, ErrInvalidBucketName},
+	{storage.ErrObjectNotFound, ErrNoSuchKey},
+	{storage.ErrInvalidKey, ErrInvalidArgument},
+	{storage.ErrKeyTooLong, ErrKeyTooLong},
+	{storage.ErrUploadNotFound, ErrNoSuchUpload},
+	{storage.ErrInvalidPart, ErrInvalidPart},
+	{storage.ErrInvalidPartOrder, ErrInvalidPartOrder},
+	{storage.ErrInvalidVersioningStatus, ErrMalformedXML},
+	{storage.ErrUploadCompleting, ErrOperationAborted},
+	{storage.ErrInvalidRange, ErrInvalidRange},
+	{storage.ErrBadDigest, ErrBadDigest},
+	{storage.ErrMalformedXML, ErrMalformedXML},
+	{storage.ErrNoSuchTagSet, ErrNoSuchTagSet},
+	{storage.ErrObjectNotAppendable, ErrObjectNotAppendable},
+	{storage.ErrPositionNotEqualToLength, ErrPositionNotEqualToLength},
+	{storage.ErrPartOffsetMismatch, ErrPartOffsetMismatch},
+	{storage.ErrObjectImmutable, ErrObjectImmutable},
+	{storage.ErrNotImplemented, ErrNotImplemented},
+}
 
-	ErrServerSideEncryptionConfigurationNotFoundError = &S3Error{
-		Code:       "ServerSideEncryptionConfigurationNotFoundError",
-		Message:    "The server side encryption configuration was not found.",
-		HTTPStatus: http.StatusNotFound,
+// translateError returns the S3 error of an error of a storage operation.
+// An *S3Error is returned as is.
+func translateError(err error) *S3Error {
+	var s3Err *S3Error
+	if errors.As(err, &s3Err) {
+		return s3Err
 	}
-
-	ErrNoSuchLifecycleConfiguration = &S3Error{
-		Code:       "NoSuchLifecycleConfiguration",
-		Message:    "The lifecycle configuration does not exist.",
-		HTTPStatus: http.StatusNotFound,
+	if isRequestTimeout(err) {
+		return ErrRequestTimeout
 	}
-
-	ErrObjectLockConfigurationNotFoundError = &S3Error{
-		Code:       "ObjectLockConfigurationNotFoundError",
-		Message:    "Object Lock configuration does not exist for this bucket.",
-		HTTPStatus: http.StatusNotFound,
+	for _, e := range storageErrors {
+		if errors.Is(err, e.err) {
+			return e.s3Err
+		}
 	}
+	return ErrInternalError
+}
 
-	ErrNoSuchObjectLockConfiguration = &S3Error{
-		Code:       "NoSuchObjectLockConfiguration",
-		Message:    "The specified object does not have an ObjectLock configuration.",
-		HTTPStatus: http.StatusNotFound,
-	}
+// writeStorageError writes the response to a request whose storage operation
+// failed. A missing object of a request for a specific version is reported as
+// NoSuchVersion, and a delete marker requested by its version as
+// MethodNotAllowed.
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	if writeKMSError(w, err, requestResource(r)) {
+		return
+	}
+	s3Err := translateError(err)
+	var marker *storage.DeleteMarkerError
+	if errors.As(err, &marker) {
+		setDeleteMarkerHeaders(w, r, marker)
+	}
+	switch {
+	case marker != nil && r.URL.Query().Get("versionId") != "":
+		s3Err = ErrMethodNotAllowed
+	case s3Err == ErrNoSuchKey && r.URL.Query().Get("versionId") != "":
+		s3Err = ErrNoSuchVersion
+	case s3Err == ErrInternalError:
+		log.Error().Err(err).Str("method", r.Method).Str("resource", requestResource(r)).Msg("Request failed")
+	}
+	writeRequestError(w, r, s3Err)
+}
 
-	ErrNoSuchBucketPolicy = &S3Error{
-		Code:       "NoSuchBucketPolicy",
-		Message:    "The bucket policy does not exist.",
-		HTTPStatus: http.StatusNotFound,
+// writeRequestError writes an S3 error response for the bucket, key, version
+// and upload of a request.
+func writeRequestError(w http.ResponseWriter, r *http.Request, err *S3Error) {
+	response := *err
+	response.Resource = requestResource(r)
+	setResourceElements(&response)
+	elements := errorCodes[response.Code].elements
+	if elements&elementVersionID != 0 {
+		response.VersionID = r.URL.Query().Get("versionId")
 	}
-
-	ErrNoSuchWebsiteConfiguration = &S3Error{
-		Code:       "NoSuchWebsiteConfiguration",
-		Message:    "The specified bucket does not have a website configuration.",
-		HTTPStatus: http.StatusNotFound,
+	if elements&elementUploadID != 0 {
+		response.UploadID = r.URL.Query().Get("uploadId")
 	}
+	writeErrorResponse(w, &response)
+}
 
-	ErrMalformedPolicy = &S3Error{
-		Code:       "MalformedPolicy",
-		Message:    "This policy contains invalid Json.",
-		HTTPStatus: http.StatusBadRequest,
+// requestResource returns the path of the bucket and key of a request.
+func requestResource(r *http.Request) string {
+	resource := "/" + GetBucket(r)
+	if key := GetKey(r); key != "" {
+		resource += "/" + key
 	}
-)
+	return resource
+}
 
 // WriteError writes an S3 error response.
 func WriteError(w http.ResponseWriter, err *S3Error) {
 	WriteErrorWithResource(w, err, "")
 }
 
-// WriteErrorWithResource writes an S3 error response with resource info.
+// WriteErrorWithResource writes an S3 error response with resource info. The
+// bucket name and key of a resource path are added to the response if S3
+// includes them for the error code.
 func WriteErrorWithResource(w http.ResponseWriter, err *S3Error, resource string) {
 	response := *err
 	response.Resource = resource
-	response.RequestID = generateRequestID()
+	setResourceElements(&response)
+	writeErrorResponse(w, &response)
+}
+
+// setResourceElements sets the BucketName and Key elements of an error
+// response from its resource path, as registered for its code.
+func setResourceElements(response *S3Error) {
+	elements := errorCodes[response.Code].elements
+	if elements&(elementBucketName|elementKey) == 0 || !strings.HasPrefix(response.Resource, "/") {
+		return
+	}
+	bucket, key, _ := strings.Cut(response.Resource[1:], "/")
+	if elements&elementBucketName != 0 {
+		response.BucketName = bucket
+	}
+	if elements&elementKey != 0 {
+		response.Key = key
+	}
+}
+
+// writeErrorResponse writes an error response with the ID of the request.
+func writeErrorResponse(w http.ResponseWriter, response *S3Error) {
+	response.RequestID = requestID(w)
 
 	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(err.HTTPStatus)
+	w.WriteHeader(response.HTTPStatus)
 
 	if err := xml.NewEncoder(w).Encode(response); err != nil {
 		log.Error().Err(err).Msg("Failed to encode error response")
 	}
 }
 
-func generateRequestID() string {
-	// Simple request ID generation
-	return randomHex(16)
+// isRequestTimeout reports whether err comes from a request body read that
+// hit the read deadline of the connection, e.g. a stalled upload.
+func isRequestTimeout(err error) bool {
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// NewRequestID returns a new request ID.
+func NewRequestID() string {
+	return strings.ToUpper(randomHex(16))
+}
+
+// requestID returns the ID of the request of a response, from the
+// x-amz-request-id header. A response without the header gets a new ID.
+func requestID(w http.ResponseWriter) string {
+	id := w.Header().Get(RequestIDHeader)
+	if id == "" {
+		id = NewRequestID()
+		w.Header().Set(RequestIDHeader, id)
+	}
+	return id
 }
 
 func randomHex(n int) string {
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kumasuke/jog/internal/storage"
+)
+
+func TestNewErrorUnregisteredCode(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewError with an unregistered code did not panic")
+		}
+	}()
+	NewError("NoSuchThing", "The thing does not exist.")
+}
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want *S3Error
+	}{
+		{storage.ErrBucketNotFound, ErrNoSuchBucket},
+		{fmt.Errorf("lookup failed: %w", storage.ErrObjectNotFound), ErrNoSuchKey},
+		{storage.ErrUploadNotFound, ErrNoSuchUpload},
+		{&storage.PartOffsetMismatchError{Offset: 5}, ErrPartOffsetMismatch},
+		{ErrInvalidTag, ErrInvalidTag},
+		{fmt.Errorf("disk full"), ErrInternalError},
+	}
+	for _, tt := range tests {
+		if got := translateError(tt.err); got != tt.want {
+			t.Errorf("translateError(%v) = %s, want %s", tt.err, got.Code, tt.want.Code)
+		}
+	}
+}
+
+func TestWriteRequestErrorElements(t *testing.T) {
+	tests := []struct {
+		target string
+		err    *S3Error
+		want   S3Error
+	}{
+		{"/bucket/dir/key", ErrNoSuchBucket, S3Error{BucketName: "bucket"}},
+		{"/bucket/dir/key", ErrNoSuchKey, S3Error{Key: "dir/key"}},
+		{"/bucket/key?versionId=v1", ErrNoSuchVersion, S3Error{Key: "key", VersionID: "v1"}},
+		{"/bucket/key?uploadId=u1", ErrNoSuchUpload, S3Error{UploadID: "u1"}},
+		{"/bucket/key?versionId=v1", ErrAccessDenied, S3Error{}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		r = WithBucket(r, "bucket")
+		if key := r.URL.Path[len("/bucket/"):]; key != "" {
+			r = WithKey(r, key)
+		}
+		w := httptest.NewRecorder()
+		writeRequestError(w, r, tt.err)
+
+		if w.Code != tt.err.HTTPStatus {
+			t.Errorf("%s %s: status = %d, want %d", tt.target, tt.err.Code, w.Code, tt.err.HTTPStatus)
+		}
+		var got S3Error
+		if err := xml.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s %s: %v", tt.target, tt.err.Code, err)
+		}
+		if got.BucketName != tt.want.BucketName || got.Key != tt.want.Key || got.VersionID != tt.want.VersionID || got.UploadID != tt.want.UploadID {
+			t.Errorf("%s %s: elements = %q %q %q %q, want %q %q %q %q", tt.target, tt.err.Code,
+				got.BucketName, got.Key, got.VersionID, got.UploadID,
+				tt.want.BucketName, tt.want.Key, tt.want.VersionID, tt.want.UploadID)
+		}
+		if got.RequestID == "" || got.RequestID != w.Header().Get(RequestIDHeader) {
+			t.Errorf("%s %s: RequestId = %q, header = %q", tt.target, tt.err.Code, got.RequestID, w.Header().Get(RequestIDHeader))
+		}
+	}
+}
+
+func TestWriteErrorKeepsRequestID(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set(RequestIDHeader, "4442587FB7D0A2F9")
+	WriteErrorWithResource(w, ErrNoSuchBucket, "/bucket")
+
+	var got S3Error
+	if err := xml.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.RequestID != "4442587FB7D0A2F9" {
+		t.Errorf("RequestId = %q, want the ID of the header", got.RequestID)
+	}
+	if got.BucketName != "bucket" {
+		t.Errorf("BucketName = %q, want bucket", got.BucketName)
+	}
+}
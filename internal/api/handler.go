@@ -2,23 +2,96 @@ package api
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"net/url"
 
+	"github.com/kumasuke/jog/internal/events"
+	"github.com/kumasuke/jog/internal/policy"
 	"github.com/kumasuke/jog/internal/storage"
 )
 
 // Handler handles S3 API requests.
 type Handler struct {
-	storage storage.Storage
+	storage  storage.Storage
+	events   *events.Broker
+	sessions *SessionStore
+	// contentTypes extends the system MIME types used to infer content types
+	contentTypes storage.ContentTypeTable
+	// mfa authenticates requests to buckets with MFA delete enabled
+	mfa *MFADevices
+	// validators inspect uploads before they are stored, which are spooled
+	// to spoolDir meanwhile
+	validators []UploadValidator
+	spoolDir   string
+	// region is the region of buckets created without a location constraint
+	region string
+	// authorizer evaluates identity and bucket policies
+	authorizer *policy.Authorizer
 }
 
 // NewHandler creates a new Handler.
 func NewHandler(storage storage.Storage) *Handler {
 	return &Handler{
-		storage: storage,
+		storage:    storage,
+		events:     events.NewBroker(),
+		sessions:   NewSessionStore(),
+		region:     defaultRegion,
+		authorizer: policy.NewAuthorizer(storage),
 	}
 }
 
+// Storage returns the storage backend of the handler.
+func (h *Handler) Storage() storage.Storage {
+	return h.storage
+}
+
+// Events returns the broker object events are published to.
+func (h *Handler) Events() *events.Broker {
+	return h.events
+}
+
+// Sessions returns the store of directory bucket sessions created by CreateSession.
+func (h *Handler) Sessions() *SessionStore {
+	return h.sessions
+}
+
+// publish publishes an object event for a request.
+func (h *Handler) publish(r *http.Request, eventType events.Type, bucket, key string, obj *storage.Object, versionID string) {
+	event := events.Event{
+		Type:      eventType,
+		Tenant:    storage.TenantFromContext(r.Context()),
+		Bucket:    bucket,
+		Key:       key,
+		VersionID: versionID,
+		Principal: storage.OwnerFromContext(r.Context()),
+		SourceIP:  r.RemoteAddr,
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		event.SourceIP = host
+	}
+	if obj != nil {
+		event.Size = obj.Size
+		event.ETag = obj.ETag
+	}
+	h.events.Publish(event)
+}
+
+// objectURL returns the absolute URL of an object as the client addressed
+// the server. Behind trusted proxies, the scheme and host are those of the
+// X-Forwarded-Proto and X-Forwarded-Host headers.
+func objectURL(r *http.Request, bucket, key string) string {
+	scheme := r.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: "/" + bucket + "/" + key}
+	return u.String()
+}
+
 // Context keys
 type contextKey string
 
@@ -3,13 +3,22 @@ package api
 import (
 	"encoding/xml"
 	"errors"
-	"io"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/kumasuke/jog/internal/storage"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// maxLifecycleRules is the maximum number of rules in a lifecycle
+	// configuration.
+	maxLifecycleRules = 1000
+	// maxLifecycleRuleIDLength is the maximum length of a lifecycle rule ID.
+	maxLifecycleRuleIDLength = 255
+)
+
 // BucketLifecycleConfiguration represents the XML structure for lifecycle configuration.
 type BucketLifecycleConfiguration struct {
 	XMLName xml.Name        `xml:"LifecycleConfiguration"`
@@ -21,6 +30,7 @@ type BucketLifecycleConfiguration struct {
 type LifecycleRule struct {
 	ID                             *string                         `xml:"ID,omitempty"`
 	Status                         string                          `xml:"Status"`
+	Prefix                         *string                         `xml:"Prefix,omitempty"`
 	Filter                         *LifecycleRuleFilter            `xml:"Filter,omitempty"`
 	Expiration                     *LifecycleExpiration            `xml:"Expiration,omitempty"`
 	Transitions                    []LifecycleTransition           `xml:"Transition,omitempty"`
@@ -31,10 +41,19 @@ type LifecycleRule struct {
 
 // LifecycleRuleFilter represents the filter for a lifecycle rule.
 type LifecycleRuleFilter struct {
-	Prefix                *string       `xml:"Prefix,omitempty"`
-	Tag                   *LifecycleTag `xml:"Tag,omitempty"`
-	ObjectSizeGreaterThan *int64        `xml:"ObjectSizeGreaterThan,omitempty"`
-	ObjectSizeLessThan    *int64        `xml:"ObjectSizeLessThan,omitempty"`
+	Prefix                *string                   `xml:"Prefix,omitempty"`
+	Tag                   *LifecycleTag             `xml:"Tag,omitempty"`
+	ObjectSizeGreaterThan *int64                    `xml:"ObjectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    *int64                    `xml:"ObjectSizeLessThan,omitempty"`
+	And                   *LifecycleRuleAndOperator `xml:"And,omitempty"`
+}
+
+// LifecycleRuleAndOperator combines the conditions of a lifecycle rule filter.
+type LifecycleRuleAndOperator struct {
+	Prefix                *string        `xml:"Prefix,omitempty"`
+	Tags                  []LifecycleTag `xml:"Tag,omitempty"`
+	ObjectSizeGreaterThan *int64         `xml:"ObjectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    *int64         `xml:"ObjectSizeLessThan,omitempty"`
 }
 
 // LifecycleTag represents a tag in lifecycle filter.
@@ -80,15 +99,13 @@ func (h *Handler) PutBucketLifecycleConfiguration(w http.ResponseWriter, r *http
 	bucket := GetBucket(r)
 
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket)
+	var lifecycleConfig BucketLifecycleConfiguration
+	if s3Err := decodeXMLBody(r, &lifecycleConfig, maxXMLBodySize); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
 		return
 	}
-
-	var lifecycleConfig BucketLifecycleConfiguration
-	if err := xml.Unmarshal(body, &lifecycleConfig); err != nil {
-		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket)
+	if s3Err := validateLifecycleConfiguration(&lifecycleConfig); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
 		return
 	}
 
@@ -103,6 +120,10 @@ func (h *Handler) PutBucketLifecycleConfiguration(w http.ResponseWriter, r *http
 		if rule.ID != nil {
 			storageRule.ID = *rule.ID
 		}
+		// The deprecated rule prefix is stored as a filter
+		if rule.Prefix != nil {
+			storageRule.Filter = &storage.LifecycleRuleFilter{Prefix: *rule.Prefix}
+		}
 		if rule.Filter != nil {
 			storageRule.Filter = &storage.LifecycleRuleFilter{}
 			if rule.Filter.Prefix != nil {
@@ -116,6 +137,18 @@ func (h *Handler) PutBucketLifecycleConfiguration(w http.ResponseWriter, r *http
 			}
 			storageRule.Filter.ObjectSizeGreaterThan = rule.Filter.ObjectSizeGreaterThan
 			storageRule.Filter.ObjectSizeLessThan = rule.Filter.ObjectSizeLessThan
+			if and := rule.Filter.And; and != nil {
+				storageRule.Filter.And = &storage.LifecycleRuleAndOperator{
+					ObjectSizeGreaterThan: and.ObjectSizeGreaterThan,
+					ObjectSizeLessThan:    and.ObjectSizeLessThan,
+				}
+				if and.Prefix != nil {
+					storageRule.Filter.And.Prefix = *and.Prefix
+				}
+				for _, tag := range and.Tags {
+					storageRule.Filter.And.Tags = append(storageRule.Filter.And.Tags, storage.Tag{Key: tag.Key, Value: tag.Value})
+				}
+			}
 		}
 		if rule.Expiration != nil {
 			storageRule.Expiration = &storage.LifecycleExpiration{
@@ -153,7 +186,7 @@ func (h *Handler) PutBucketLifecycleConfiguration(w http.ResponseWriter, r *http
 	}
 
 	// Store lifecycle configuration
-	err = h.storage.PutBucketLifecycleConfiguration(r.Context(), bucket, storageConfig)
+	err := h.storage.PutBucketLifecycleConfiguration(r.Context(), bucket, storageConfig)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -166,6 +199,172 @@ func (h *Handler) PutBucketLifecycleConfiguration(w http.ResponseWriter, r *http
 	w.WriteHeader(http.StatusOK)
 }
 
+// validateLifecycleConfiguration checks a lifecycle configuration the way S3
+// does, returning the error to report for an invalid one.
+func validateLifecycleConfiguration(config *BucketLifecycleConfiguration) *S3Error {
+	if len(config.Rules) == 0 || len(config.Rules) > maxLifecycleRules {
+		return ErrMalformedXML
+	}
+
+	ids := make(map[string]bool, len(config.Rules))
+	for _, rule := range config.Rules {
+		if rule.ID != nil {
+			if len(*rule.ID) > maxLifecycleRuleIDLength {
+				return lifecycleError(ErrInvalidArgument, fmt.Sprintf("ID length should not exceed allowed limit of %d", maxLifecycleRuleIDLength))
+			}
+			if ids[*rule.ID] {
+				return lifecycleError(ErrInvalidArgument, "Rule ID must be unique. Found same ID for more than one rule")
+			}
+			ids[*rule.ID] = true
+		}
+		if rule.Status != "Enabled" && rule.Status != "Disabled" {
+			return lifecycleError(ErrMalformedXML, "The Status of a rule must be Enabled or Disabled")
+		}
+		if (rule.Prefix == nil) == (rule.Filter == nil) {
+			return lifecycleError(ErrMalformedXML, "A rule must have exactly one of Filter or Prefix")
+		}
+		if s3Err := validateLifecycleFilter(rule.Filter); s3Err != nil {
+			return s3Err
+		}
+		if s3Err := validateLifecycleActions(&rule); s3Err != nil {
+			return s3Err
+		}
+	}
+	return nil
+}
+
+// validateLifecycleFilter checks the filter of a lifecycle rule, which holds
+// at most one condition unless they are combined with And.
+func validateLifecycleFilter(filter *LifecycleRuleFilter) *S3Error {
+	if filter == nil {
+		return nil
+	}
+	set := 0
+	for _, present := range []bool{filter.Prefix != nil, filter.Tag != nil, filter.ObjectSizeGreaterThan != nil,
+		filter.ObjectSizeLessThan != nil, filter.And != nil} {
+		if present {
+			set++
+		}
+	}
+	if set > 1 {
+		return lifecycleError(ErrMalformedXML, "Filter must specify at most one of Prefix, Tag, ObjectSizeGreaterThan, ObjectSizeLessThan or And")
+	}
+
+	greaterThan, lessThan := filter.ObjectSizeGreaterThan, filter.ObjectSizeLessThan
+	if and := filter.And; and != nil {
+		keys := make(map[string]bool, len(and.Tags))
+		for _, tag := range and.Tags {
+			if keys[tag.Key] {
+				return lifecycleError(ErrInvalidArgument, "Duplicate Tag Keys are not allowed.")
+			}
+			keys[tag.Key] = true
+		}
+		greaterThan, lessThan = and.ObjectSizeGreaterThan, and.ObjectSizeLessThan
+	}
+	if greaterThan != nil && *greaterThan < 0 || lessThan != nil && *lessThan < 0 {
+		return lifecycleError(ErrInvalidArgument, "Object size bounds must not be negative")
+	}
+	if greaterThan != nil && lessThan != nil && *greaterThan >= *lessThan {
+		return lifecycleError(ErrInvalidArgument, "ObjectSizeGreaterThan must be less than ObjectSizeLessThan")
+	}
+	return nil
+}
+
+// hasTags reports whether a lifecycle rule filter has tag conditions.
+func (f *LifecycleRuleFilter) hasTags() bool {
+	return f != nil && (f.Tag != nil || f.And != nil && len(f.And.Tags) > 0)
+}
+
+// validateLifecycleActions checks the actions of a lifecycle rule.
+func validateLifecycleActions(rule *LifecycleRule) *S3Error {
+	if rule.Expiration == nil && len(rule.Transitions) == 0 && rule.NoncurrentVersionExpiration == nil &&
+		len(rule.NoncurrentVersionTransitions) == 0 && rule.AbortIncompleteMultipartUpload == nil {
+		return lifecycleError(ErrInvalidRequest, "At least one action needs to be specified in a rule")
+	}
+
+	if exp := rule.Expiration; exp != nil {
+		set := 0
+		for _, present := range []bool{exp.Days != nil, exp.Date != nil, exp.ExpiredObjectDeleteMarker != nil} {
+			if present {
+				set++
+			}
+		}
+		if set != 1 {
+			return lifecycleError(ErrMalformedXML, "Expiration must specify exactly one of Days, Date or ExpiredObjectDeleteMarker")
+		}
+		if exp.Days != nil && *exp.Days <= 0 {
+			return lifecycleError(ErrInvalidArgument, "'Days' for Expiration action must be a positive integer")
+		}
+		if exp.Date != nil && !isLifecycleDate(*exp.Date) {
+			return lifecycleError(ErrInvalidArgument, "'Date' must be at midnight GMT")
+		}
+		if exp.ExpiredObjectDeleteMarker != nil && rule.Filter.hasTags() {
+			return lifecycleError(ErrInvalidRequest, "ExpiredObjectDeleteMarker cannot be specified with tag-based filters")
+		}
+	}
+
+	for _, transition := range rule.Transitions {
+		if (transition.Days == nil) == (transition.Date == nil) {
+			return lifecycleError(ErrMalformedXML, "Transition must specify exactly one of Days or Date")
+		}
+		if transition.Days != nil && *transition.Days < 0 {
+			return lifecycleError(ErrInvalidArgument, "'Days' for Transition action must be a nonnegative integer")
+		}
+		if transition.Date != nil && !isLifecycleDate(*transition.Date) {
+			return lifecycleError(ErrInvalidArgument, "'Date' must be at midnight GMT")
+		}
+		if transition.StorageClass == "" {
+			return lifecycleError(ErrMalformedXML, "Transition must specify a StorageClass")
+		}
+	}
+
+	if nve := rule.NoncurrentVersionExpiration; nve != nil {
+		if nve.NoncurrentDays == nil || *nve.NoncurrentDays <= 0 {
+			return lifecycleError(ErrInvalidArgument, "'NoncurrentDays' for NoncurrentVersionExpiration action must be a positive integer")
+		}
+	}
+	for _, nvt := range rule.NoncurrentVersionTransitions {
+		if nvt.NoncurrentDays == nil || *nvt.NoncurrentDays < 0 {
+			return lifecycleError(ErrInvalidArgument, "'NoncurrentDays' for NoncurrentVersionTransition action must be a nonnegative integer")
+		}
+		if nvt.StorageClass == "" {
+			return lifecycleError(ErrMalformedXML, "NoncurrentVersionTransition must specify a StorageClass")
+		}
+	}
+
+	if abort := rule.AbortIncompleteMultipartUpload; abort != nil {
+		if abort.DaysAfterInitiation == nil || *abort.DaysAfterInitiation <= 0 {
+			return lifecycleError(ErrInvalidArgument, "'DaysAfterInitiation' for AbortIncompleteMultipartUpload action must be a positive integer")
+		}
+		if rule.Filter.hasTags() {
+			return lifecycleError(ErrInvalidRequest, "AbortIncompleteMultipartUpload cannot be specified with Tags")
+		}
+	}
+	return nil
+}
+
+// isLifecycleDate reports whether a lifecycle date is an ISO 8601 date at
+// midnight UTC, e.g. 2026-01-01 or 2026-01-01T00:00:00Z.
+func isLifecycleDate(value string) bool {
+	if _, err := time.Parse(time.DateOnly, value); err == nil {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return false
+	}
+	_, offset := t.Zone()
+	return offset == 0 && t.Equal(t.Truncate(24*time.Hour))
+}
+
+// lifecycleError returns a copy of an error with the message of an invalid
+// lifecycle configuration.
+func lifecycleError(base *S3Error, message string) *S3Error {
+	s3Err := *base
+	s3Err.Message = message
+	return &s3Err
+}
+
 // GetBucketLifecycleConfiguration handles GET /{bucket}?lifecycle - GetBucketLifecycleConfiguration.
 func (h *Handler) GetBucketLifecycleConfiguration(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
@@ -211,6 +410,19 @@ func (h *Handler) GetBucketLifecycleConfiguration(w http.ResponseWriter, r *http
 			}
 			responseRule.Filter.ObjectSizeGreaterThan = rule.Filter.ObjectSizeGreaterThan
 			responseRule.Filter.ObjectSizeLessThan = rule.Filter.ObjectSizeLessThan
+			if and := rule.Filter.And; and != nil {
+				responseRule.Filter.And = &LifecycleRuleAndOperator{
+					ObjectSizeGreaterThan: and.ObjectSizeGreaterThan,
+					ObjectSizeLessThan:    and.ObjectSizeLessThan,
+				}
+				if and.Prefix != "" {
+					prefix := and.Prefix
+					responseRule.Filter.And.Prefix = &prefix
+				}
+				for _, tag := range and.Tags {
+					responseRule.Filter.And.Tags = append(responseRule.Filter.And.Tags, LifecycleTag{Key: tag.Key, Value: tag.Value})
+				}
+			}
 		}
 		if rule.Expiration != nil {
 			responseRule.Expiration = &LifecycleExpiration{
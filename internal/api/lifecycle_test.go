@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestValidateLifecycleConfiguration(t *testing.T) {
+	const expire = `<Expiration><Days>30</Days></Expiration>`
+	tests := []struct {
+		name  string
+		rules string
+		code  string
+	}{
+		{"valid", `<Rule><ID>a</ID><Status>Enabled</Status><Filter><Prefix>logs/</Prefix></Filter>` + expire + `</Rule>`, ""},
+		{"legacy prefix", `<Rule><Status>Disabled</Status><Prefix>logs/</Prefix>` + expire + `</Rule>`, ""},
+		{"date", `<Rule><Status>Enabled</Status><Filter></Filter><Expiration><Date>2026-01-01T00:00:00Z</Date></Expiration></Rule>`, ""},
+		{"no rules", ``, "MalformedXML"},
+		{"duplicate ID", `<Rule><ID>a</ID><Status>Enabled</Status><Filter></Filter>` + expire + `</Rule><Rule><ID>a</ID><Status>Enabled</Status><Filter></Filter>` + expire + `</Rule>`, "InvalidArgument"},
+		{"long ID", `<Rule><ID>` + strings.Repeat("x", 256) + `</ID><Status>Enabled</Status><Filter></Filter>` + expire + `</Rule>`, "InvalidArgument"},
+		{"bad status", `<Rule><Status>enabled</Status><Filter></Filter>` + expire + `</Rule>`, "MalformedXML"},
+		{"no filter", `<Rule><Status>Enabled</Status>` + expire + `</Rule>`, "MalformedXML"},
+		{"filter and prefix", `<Rule><Status>Enabled</Status><Prefix>a</Prefix><Filter></Filter>` + expire + `</Rule>`, "MalformedXML"},
+		{"no action", `<Rule><Status>Enabled</Status><Filter></Filter></Rule>`, "InvalidRequest"},
+		{"days and date", `<Rule><Status>Enabled</Status><Filter></Filter><Expiration><Days>1</Days><Date>2026-01-01</Date></Expiration></Rule>`, "MalformedXML"},
+		{"zero days", `<Rule><Status>Enabled</Status><Filter></Filter><Expiration><Days>0</Days></Expiration></Rule>`, "InvalidArgument"},
+		{"date not at midnight", `<Rule><Status>Enabled</Status><Filter></Filter><Expiration><Date>2026-01-01T12:00:00Z</Date></Expiration></Rule>`, "InvalidArgument"},
+		{"transition without days", `<Rule><Status>Enabled</Status><Filter></Filter><Transition><StorageClass>GLACIER</StorageClass></Transition></Rule>`, "MalformedXML"},
+		{"and", `<Rule><Status>Enabled</Status><Filter><And><Prefix>logs/</Prefix><Tag><Key>a</Key><Value>1</Value></Tag><Tag><Key>b</Key><Value>2</Value></Tag><ObjectSizeGreaterThan>10</ObjectSizeGreaterThan></And></Filter>` + expire + `</Rule>`, ""},
+		{"prefix and tag without and", `<Rule><Status>Enabled</Status><Filter><Prefix>logs/</Prefix><Tag><Key>k</Key><Value>v</Value></Tag></Filter>` + expire + `</Rule>`, "MalformedXML"},
+		{"duplicate tag keys", `<Rule><Status>Enabled</Status><Filter><And><Tag><Key>k</Key><Value>1</Value></Tag><Tag><Key>k</Key><Value>2</Value></Tag></And></Filter>` + expire + `</Rule>`, "InvalidArgument"},
+		{"empty size range", `<Rule><Status>Enabled</Status><Filter><And><ObjectSizeGreaterThan>10</ObjectSizeGreaterThan><ObjectSizeLessThan>10</ObjectSizeLessThan></And></Filter>` + expire + `</Rule>`, "InvalidArgument"},
+		{"delete marker with and tags", `<Rule><Status>Enabled</Status><Filter><And><Prefix>a</Prefix><Tag><Key>k</Key><Value>v</Value></Tag></And></Filter><Expiration><ExpiredObjectDeleteMarker>true</ExpiredObjectDeleteMarker></Expiration></Rule>`, "InvalidRequest"},
+		{"abort with tag", `<Rule><Status>Enabled</Status><Filter><Tag><Key>k</Key><Value>v</Value></Tag></Filter><AbortIncompleteMultipartUpload><DaysAfterInitiation>7</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule>`, "InvalidRequest"},
+	}
+	for _, tt := range tests {
+		var config BucketLifecycleConfiguration
+		if err := xml.Unmarshal([]byte(`<LifecycleConfiguration>`+tt.rules+`</LifecycleConfiguration>`), &config); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		s3Err := validateLifecycleConfiguration(&config)
+		if tt.code == "" && s3Err != nil {
+			t.Errorf("%s: %v, want valid", tt.name, s3Err.Message)
+		}
+		if tt.code != "" && (s3Err == nil || s3Err.Code != tt.code) {
+			t.Errorf("%s: %v, want %s", tt.name, s3Err, tt.code)
+		}
+	}
+
+	var config BucketLifecycleConfiguration
+	config.Rules = make([]LifecycleRule, maxLifecycleRules+1)
+	if s3Err := validateLifecycleConfiguration(&config); s3Err == nil || s3Err.Code != "MalformedXML" {
+		t.Errorf("%d rules: %v, want MalformedXML", len(config.Rules), s3Err)
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/kumasuke/jog/internal/storage"
+)
+
+// maxListKeys is the largest page size of a listing. Larger max-keys,
+// max-uploads and max-parts values are capped to it, as S3 does.
+const maxListKeys = 1000
+
+// parseListLimit parses the page size parameter name (max-keys, max-uploads
+// or max-parts) of a list request. Missing parameters default to maxListKeys;
+// negative and non-integer values are rejected with InvalidArgument.
+func parseListLimit(query url.Values, name string) (int32, *S3Error) {
+	if !query.Has(name) {
+		return maxListKeys, nil
+	}
+	n, err := strconv.ParseInt(query.Get(name), 10, 32)
+	if err != nil || n < 0 {
+		s3Err := *ErrInvalidArgument
+		s3Err.Message = "Provided " + name + " not an integer or within integer range"
+		return 0, &s3Err
+	}
+	return int32(min(n, maxListKeys)), nil
+}
+
+// parseListMarker parses a numeric marker parameter such as
+// part-number-marker of a list request, which must be a non-negative integer.
+func parseListMarker(query url.Values, name string) (int32, *S3Error) {
+	if !query.Has(name) {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(query.Get(name), 10, 32)
+	if err != nil || n < 0 {
+		s3Err := *ErrInvalidArgument
+		s3Err.Message = "Provided " + name + " not an integer or within integer range"
+		return 0, &s3Err
+	}
+	return int32(n), nil
+}
+
+// parseTagFilter parses the tag-key and tag-value parameters of a listing
+// restricted to tagged objects (JOG extension). A tag-key without tag-value
+// matches any value of the tag; tag-value requires tag-key.
+func parseTagFilter(query url.Values, input *storage.ListObjectsInput) *S3Error {
+	if query.Has("tag-value") && query.Get("tag-key") == "" {
+		s3Err := *ErrInvalidArgument
+		s3Err.Message = "tag-value requires tag-key"
+		return &s3Err
+	}
+	input.TagKey = query.Get("tag-key")
+	if query.Has("tag-value") {
+		value := query.Get("tag-value")
+		input.TagValue = &value
+	}
+	return nil
+}
+
+// EncodeContinuationToken returns the opaque ListObjectsV2 continuation token
+// resuming a listing after key.
+func EncodeContinuationToken(key string) string {
+	if key == "" {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// decodeContinuationToken returns the key a ListObjectsV2 continuation token
+// resumes the listing after. Tokens not issued by EncodeContinuationToken are
+// rejected with InvalidArgument.
+func decodeContinuationToken(token string) (string, *S3Error) {
+	if token == "" {
+		return "", nil
+	}
+	key, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(key) == 0 || !utf8.Valid(key) {
+		s3Err := *ErrInvalidArgument
+		s3Err.Message = "The continuation token provided is incorrect"
+		return "", &s3Err
+	}
+	return string(key), nil
+}
+
+// checkListBucket checks that the bucket of a list request with a page size
+// of 0 exists. Such requests get an empty, non-truncated page without
+// listing the bucket.
+func (h *Handler) checkListBucket(ctx context.Context, bucket string) error {
+	_, err := h.storage.HeadBucket(ctx, bucket)
+	return err
+}
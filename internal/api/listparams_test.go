@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseListLimit(t *testing.T) {
+	for raw, want := range map[string]int32{"": 1000, "max-keys=0": 0, "max-keys=10": 10, "max-keys=5000": 1000} {
+		query, _ := url.ParseQuery(raw)
+		got, s3Err := parseListLimit(query, "max-keys")
+		if s3Err != nil || got != want {
+			t.Errorf("parseListLimit(%q) = %d, %v, want %d", raw, got, s3Err, want)
+		}
+	}
+	for _, raw := range []string{"max-keys=-1", "max-keys=abc", "max-keys=", "max-keys=99999999999"} {
+		query, _ := url.ParseQuery(raw)
+		if _, s3Err := parseListLimit(query, "max-keys"); s3Err == nil || s3Err.Code != "InvalidArgument" {
+			t.Errorf("parseListLimit(%q) = %v, want InvalidArgument", raw, s3Err)
+		}
+	}
+}
+
+func TestContinuationToken(t *testing.T) {
+	for _, key := range []string{"a", "photos/2026/img 1.jpg", "日本語/キー"} {
+		got, s3Err := decodeContinuationToken(EncodeContinuationToken(key))
+		if s3Err != nil || got != key {
+			t.Errorf("decodeContinuationToken(EncodeContinuationToken(%q)) = %q, %v", key, got, s3Err)
+		}
+	}
+	for _, token := range []string{"not a token!", "%%%", "/w"} {
+		if _, s3Err := decodeContinuationToken(token); s3Err == nil {
+			t.Errorf("decodeContinuationToken(%q) succeeded", token)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kumasuke/jog/internal/storage"
+)
+
+const (
+	// totpStep is the time step of MFA tokens.
+	totpStep = 30 * time.Second
+	// totpSkew is the number of steps a token may be behind or ahead of the
+	// server clock.
+	totpSkew = 1
+)
+
+// MFADevices verifies the x-amz-mfa header of requests that need MFA
+// authentication, i.e. that change the versioning of or permanently delete
+// versions from buckets with MFA delete enabled. The header holds the serial
+// number of a device and its current token, separated by a space; tokens are
+// 6-digit TOTP codes (RFC 6238) of the device secret.
+type MFADevices struct {
+	secrets map[string][]byte
+	now     func() time.Time
+
+	mu sync.Mutex
+	// used is the time step of the last token accepted for each device, so
+	// that tokens cannot be replayed.
+	used map[string]int64
+}
+
+// NewMFADevices creates a verifier for devices given as a map of serial
+// numbers to base32 encoded secrets, as shown by authenticator apps.
+func NewMFADevices(secrets map[string]string) (*MFADevices, error) {
+	d := &MFADevices{
+		secrets: make(map[string][]byte, len(secrets)),
+		now:     time.Now,
+		used:    make(map[string]int64),
+	}
+	for serial, secret := range secrets {
+		if serial == "" || strings.ContainsAny(serial, " \t") {
+			return nil, fmt.Errorf("invalid MFA device serial number %q", serial)
+		}
+		key, err := decodeMFASecret(secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret of MFA device %q: %w", serial, err)
+		}
+		d.secrets[serial] = key
+	}
+	return d, nil
+}
+
+// decodeMFASecret decodes a base32 secret, ignoring case, spaces and padding.
+func decodeMFASecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("empty secret")
+	}
+	return key, nil
+}
+
+// Verify reports whether an x-amz-mfa header holds a valid token of a
+// configured device that was not used before.
+func (d *MFADevices) Verify(header string) bool {
+	if d == nil {
+		return false
+	}
+	serial, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok {
+		return false
+	}
+	secret, ok := d.secrets[serial]
+	token = strings.TrimSpace(token)
+	if !ok || len(token) != 6 {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	step := d.now().Unix() / int64(totpStep/time.Second)
+	for i := step - totpSkew; i <= step+totpSkew; i++ {
+		if i <= d.used[serial] {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totp(secret, i)), []byte(token)) == 1 {
+			d.used[serial] = i
+			return true
+		}
+	}
+	return false
+}
+
+// totp returns the 6-digit token of a secret for a time step (RFC 4226
+// HOTP with HMAC-SHA1).
+func totp(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1000000)
+}
+
+// SetMFADevices sets the devices that authenticate requests to buckets with
+// MFA delete enabled. Without devices such requests are always denied.
+func (h *Handler) SetMFADevices(devices *MFADevices) {
+	h.mfa = devices
+}
+
+// checkMFA checks the x-amz-mfa header of a request that needs MFA
+// authentication.
+func (h *Handler) checkMFA(r *http.Request) *S3Error {
+	header := r.Header.Get("x-amz-mfa")
+	if header == "" {
+		s3Err := *ErrAccessDenied
+		s3Err.Message = "Mfa Authentication must be used for this request"
+		return &s3Err
+	}
+	if !h.mfa.Verify(header) {
+		s3Err := *ErrAccessDenied
+		s3Err.Message = "The x-amz-mfa header does not hold a valid MFA token."
+		return &s3Err
+	}
+	return nil
+}
+
+// mfaDeleteEnabled reports whether a bucket has MFA delete enabled. Errors,
+// such as a missing bucket, are left to the operation to report.
+func (h *Handler) mfaDeleteEnabled(r *http.Request, bucket string) bool {
+	status, err := h.storage.GetBucketMfaDelete(r.Context(), bucket)
+	return err == nil && status == storage.MfaDeleteStatusEnabled
+}
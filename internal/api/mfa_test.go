@@ -0,0 +1,66 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 secret of the RFC 6238 test vectors, base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTP(t *testing.T) {
+	key, err := decodeMFASecret(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("decodeMFASecret: %v", err)
+	}
+	// The last 6 digits of the RFC 6238 SHA-1 test vectors
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := totp(key, tt.unix/30); got != tt.want {
+			t.Errorf("totp at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMFADevicesVerify(t *testing.T) {
+	devices, err := NewMFADevices(map[string]string{"device-1": "gezd gnbv gy3t qojq gezd gnbv gy3t qojq"})
+	if err != nil {
+		t.Fatalf("NewMFADevices: %v", err)
+	}
+	devices.now = func() time.Time { return time.Unix(1234567890, 0) }
+
+	for _, header := range []string{"", "device-1", "device-2 005924", "device-1 000000", "device-1 5924"} {
+		if devices.Verify(header) {
+			t.Errorf("Verify(%q) = true, want false", header)
+		}
+	}
+	if !devices.Verify("device-1 005924") {
+		t.Fatal("Verify of the current token = false, want true")
+	}
+	// Tokens cannot be replayed, nor can earlier ones be used after it
+	if devices.Verify("device-1 005924") {
+		t.Error("Verify of a used token = true, want false")
+	}
+
+	// Tokens of the previous and next time steps are accepted
+	devices.now = func() time.Time { return time.Unix(2000000000+30, 0) }
+	if !devices.Verify("device-1 279037") {
+		t.Error("Verify of the previous token = false, want true")
+	}
+
+	if _, err := NewMFADevices(map[string]string{"device-1": "not base32!"}); err == nil {
+		t.Error("NewMFADevices with an invalid secret succeeded")
+	}
+	var none *MFADevices
+	if none.Verify("device-1 005924") {
+		t.Error("Verify without devices = true, want false")
+	}
+}
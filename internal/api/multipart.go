@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"sort"
@@ -11,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/kumasuke/jog/internal/events"
 	"github.com/kumasuke/jog/internal/storage"
 	"github.com/rs/zerolog/log"
 )
@@ -32,6 +35,7 @@ type CompleteMultipartUploadResult struct {
 	Bucket   string   `xml:"Bucket"`
 	Key      string   `xml:"Key"`
 	ETag     string   `xml:"ETag"`
+	Checksum
 }
 
 // CompleteMultipartUploadRequest is the request body for CompleteMultipartUpload.
@@ -44,6 +48,7 @@ type CompleteMultipartUploadRequest struct {
 type CompletePart struct {
 	PartNumber int32  `xml:"PartNumber"`
 	ETag       string `xml:"ETag"`
+	Checksum
 }
 
 // ListPartsResult is the response for ListParts.
@@ -53,10 +58,14 @@ type ListPartsResult struct {
 	Bucket               string     `xml:"Bucket"`
 	Key                  string     `xml:"Key"`
 	UploadId             string     `xml:"UploadId"`
+	Initiator            Owner      `xml:"Initiator"`
+	Owner                Owner      `xml:"Owner"`
+	StorageClass         string     `xml:"StorageClass"`
 	PartNumberMarker     int32      `xml:"PartNumberMarker"`
 	NextPartNumberMarker int32      `xml:"NextPartNumberMarker,omitempty"`
 	MaxParts             int32      `xml:"MaxParts"`
 	IsTruncated          bool       `xml:"IsTruncated"`
+	ChecksumAlgorithm    string     `xml:"ChecksumAlgorithm,omitempty"`
 	Parts                []PartInfo `xml:"Part"`
 }
 
@@ -66,6 +75,7 @@ type PartInfo struct {
 	LastModified string `xml:"LastModified"`
 	ETag         string `xml:"ETag"`
 	Size         int64  `xml:"Size"`
+	Checksum
 }
 
 // CopyPartResult is the response for UploadPartCopy.
@@ -74,27 +84,35 @@ type CopyPartResult struct {
 	Xmlns        string   `xml:"xmlns,attr"`
 	LastModified string   `xml:"LastModified"`
 	ETag         string   `xml:"ETag"`
+	Checksum
 }
 
 // ListMultipartUploadsResult is the response for ListMultipartUploads.
 type ListMultipartUploadsResult struct {
-	XMLName            xml.Name     `xml:"ListMultipartUploadsResult"`
-	Xmlns              string       `xml:"xmlns,attr"`
-	Bucket             string       `xml:"Bucket"`
-	KeyMarker          string       `xml:"KeyMarker"`
-	UploadIdMarker     string       `xml:"UploadIdMarker"`
-	NextKeyMarker      string       `xml:"NextKeyMarker,omitempty"`
-	NextUploadIdMarker string       `xml:"NextUploadIdMarker,omitempty"`
-	MaxUploads         int32        `xml:"MaxUploads"`
-	IsTruncated        bool         `xml:"IsTruncated"`
-	Uploads            []UploadInfo `xml:"Upload"`
+	XMLName            xml.Name       `xml:"ListMultipartUploadsResult"`
+	Xmlns              string         `xml:"xmlns,attr"`
+	Bucket             string         `xml:"Bucket"`
+	KeyMarker          string         `xml:"KeyMarker"`
+	UploadIdMarker     string         `xml:"UploadIdMarker"`
+	NextKeyMarker      string         `xml:"NextKeyMarker,omitempty"`
+	NextUploadIdMarker string         `xml:"NextUploadIdMarker,omitempty"`
+	Prefix             string         `xml:"Prefix,omitempty"`
+	Delimiter          string         `xml:"Delimiter,omitempty"`
+	MaxUploads         int32          `xml:"MaxUploads"`
+	EncodingType       string         `xml:"EncodingType,omitempty"`
+	IsTruncated        bool           `xml:"IsTruncated"`
+	Uploads            []UploadInfo   `xml:"Upload"`
+	CommonPrefixes     []CommonPrefix `xml:"CommonPrefixes,omitempty"`
 }
 
 // UploadInfo represents an upload in ListMultipartUploads response.
 type UploadInfo struct {
-	Key       string `xml:"Key"`
-	UploadId  string `xml:"UploadId"`
-	Initiated string `xml:"Initiated"`
+	Key          string `xml:"Key"`
+	UploadId     string `xml:"UploadId"`
+	Initiator    Owner  `xml:"Initiator"`
+	Owner        Owner  `xml:"Owner"`
+	StorageClass string `xml:"StorageClass"`
+	Initiated    string `xml:"Initiated"`
 }
 
 // CreateMultipartUpload handles POST /{bucket}/{key}?uploads - CreateMultipartUpload.
@@ -102,18 +120,45 @@ func (h *Handler) CreateMultipartUpload(w http.ResponseWriter, r *http.Request)
 	bucket := GetBucket(r)
 	key := GetKey(r)
 
-	contentType := r.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "application/octet-stream"
-	}
+	contentType := h.objectContentType(r, bucket, key)
 
 	// Parse custom metadata
-	metadata := make(map[string]string)
-	for k, values := range r.Header {
-		if strings.HasPrefix(strings.ToLower(k), "x-amz-meta-") {
-			metaKey := strings.TrimPrefix(strings.ToLower(k), "x-amz-meta-")
-			metadata[metaKey] = values[0]
-		}
+	metadata, s3Err := parseUserMetadata(r)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
+	}
+
+	// The encryption is applied to the object on completion
+	r, s3Err = withServerSideEncryption(r)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
+	}
+	r, s3Err = withChecksumAlgorithm(r)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
+	}
+	// The lock is applied to the object on completion
+	lock, s3Err := h.objectLockFromHeaders(r, bucket)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
+	}
+	if lock != nil {
+		r = r.WithContext(storage.WithObjectLock(r.Context(), lock))
+	}
+
+	// Like the lock, the tags are applied to the object on completion, along
+	// with the default tags of the bucket
+	tags, err := ParseTaggingHeader(r.Header.Get("x-amz-tagging"))
+	if err != nil {
+		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket+"/"+key)
+		return
+	}
+	if tags = h.withDefaultTags(r, bucket, tags); len(tags) > 0 {
+		r = r.WithContext(storage.WithObjectTags(r.Context(), tags))
 	}
 
 	upload, err := h.storage.CreateMultipartUpload(r.Context(), bucket, key, contentType, metadata)
@@ -126,6 +171,9 @@ func (h *Handler) CreateMultipartUpload(w http.ResponseWriter, r *http.Request)
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
 			return
 		}
+		if writeKMSError(w, err, "/"+bucket+"/"+key) {
+			return
+		}
 		log.Error().Err(err).Msg("Failed to create multipart upload")
 		WriteError(w, ErrInternalError)
 		return
@@ -145,6 +193,9 @@ func (h *Handler) CreateMultipartUpload(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if upload.ChecksumAlgorithm != "" {
+		w.Header().Set("x-amz-checksum-algorithm", upload.ChecksumAlgorithm)
+	}
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(buf.Bytes())
@@ -171,22 +222,125 @@ func (h *Handler) UploadPart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Content-Range resumes an interrupted part upload (JOG extension)
+	if contentRange := r.Header.Get("Content-Range"); contentRange != "" {
+		h.uploadPartRange(w, r, uploadID, int32(partNumber), contentRange, contentLength)
+		return
+	}
+
+	r, s3Err := withPartChecksum(r)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
+	}
+
 	part, err := h.storage.UploadPart(r.Context(), bucket, key, uploadID, int32(partNumber), r.Body, contentLength)
 	if err != nil {
-		if errors.Is(err, storage.ErrUploadNotFound) {
-			WriteError(w, ErrNoSuchUpload)
+		if writeChecksumError(w, err, "/"+bucket+"/"+key) {
 			return
 		}
-		if errors.Is(err, storage.ErrBucketNotFound) {
-			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", "\""+part.ETag+"\"")
+	setChecksumHeader(w, part.ChecksumAlgorithm, part.Checksum)
+	w.WriteHeader(http.StatusOK)
+}
+
+// uploadPartRange handles UploadPart with a "Content-Range: bytes start-end/total"
+// header (JOG extension). The bytes are added to the part at start, which must
+// equal the number of bytes persisted so far. Until all total bytes are received
+// the response is 202 Accepted; the x-jog-part-offset header always reports the
+// persisted offset to resume from.
+func (h *Handler) uploadPartRange(w http.ResponseWriter, r *http.Request, uploadID string, partNumber int32, contentRange string, contentLength int64) {
+	bucket := GetBucket(r)
+	key := GetKey(r)
+
+	start, end, total, ok := parsePartContentRange(contentRange)
+	if !ok || end-start+1 != contentLength {
+		WriteError(w, ErrInvalidRange)
+		return
+	}
+
+	progress, err := h.storage.UploadPartRange(r.Context(), bucket, key, uploadID, partNumber, start, r.Body, contentLength, total)
+	if err != nil {
+		var offsetErr *storage.PartOffsetMismatchError
+		if errors.As(err, &offsetErr) {
+			w.Header().Set("x-jog-part-offset", strconv.FormatInt(offsetErr.Offset, 10))
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("x-jog-part-offset", strconv.FormatInt(progress.Offset, 10))
+	if progress.Part == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("ETag", "\""+progress.Part.ETag+"\"")
+	setChecksumHeader(w, progress.Part.ChecksumAlgorithm, progress.Part.Checksum)
+	w.WriteHeader(http.StatusOK)
+}
+
+// parsePartContentRange parses a "bytes start-end/total" Content-Range header.
+func parsePartContentRange(s string) (start, end, total int64, ok bool) {
+	rangeStr, found := strings.CutPrefix(s, "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+	rangeStr, totalStr, found := strings.Cut(rangeStr, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+	startStr, endStr, found := strings.Cut(rangeStr, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	var err error
+	if start, err = strconv.ParseInt(startStr, 10, 64); err != nil {
+		return 0, 0, 0, false
+	}
+	if end, err = strconv.ParseInt(endStr, 10, 64); err != nil {
+		return 0, 0, 0, false
+	}
+	if total, err = strconv.ParseInt(totalStr, 10, 64); err != nil {
+		return 0, 0, 0, false
+	}
+	if start < 0 || end < start || end >= total {
+		return 0, 0, 0, false
+	}
+	return start, end, total, true
+}
+
+// HeadPartUpload handles HEAD /{bucket}/{key}?partNumber={partNumber}&uploadId={uploadId}
+// (JOG extension). The x-jog-part-offset header reports the number of bytes of
+// a resumable part upload persisted so far.
+func (h *Handler) HeadPartUpload(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+	key := GetKey(r)
+
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+	partNumber, err := strconv.ParseInt(query.Get("partNumber"), 10, 32)
+	if err != nil || partNumber < 1 || partNumber > 10000 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	offset, err := h.storage.GetPartUploadOffset(r.Context(), bucket, key, uploadID, int32(partNumber))
+	if err != nil {
+		if errors.Is(err, storage.ErrUploadNotFound) {
+			w.WriteHeader(http.StatusNotFound)
 			return
 		}
-		log.Error().Err(err).Msg("Failed to upload part")
-		WriteError(w, ErrInternalError)
+		log.Error().Err(err).Msg("Failed to get part upload offset")
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("ETag", "\""+part.ETag+"\"")
+	w.Header().Set("x-jog-part-offset", strconv.FormatInt(offset, 10))
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -206,31 +360,15 @@ func (h *Handler) UploadPartCopy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse x-amz-copy-source header
-	copySource := r.Header.Get("x-amz-copy-source")
-	if copySource == "" {
+	srcBucket, srcKey, srcVersionID, ok := parseCopySource(r.Header.Get("x-amz-copy-source"))
+	if !ok {
 		WriteError(w, ErrInvalidRequest)
 		return
 	}
-
-	// URL decode the copy source (may contain URL-encoded characters)
-	copySource, err = url.QueryUnescape(copySource)
-	if err != nil {
-		WriteError(w, ErrInvalidRequest)
+	if !h.authorizeCopySource(w, r, srcBucket, srcKey, srcVersionID) {
 		return
 	}
 
-	// Remove leading slash if present
-	copySource = strings.TrimPrefix(copySource, "/")
-
-	// Parse source bucket and key
-	parts := strings.SplitN(copySource, "/", 2)
-	if len(parts) != 2 {
-		WriteError(w, ErrInvalidRequest)
-		return
-	}
-	srcBucket := parts[0]
-	srcKey := parts[1]
-
 	// Parse x-amz-copy-source-range header (optional)
 	var startByte, endByte *int64
 	copySourceRange := r.Header.Get("x-amz-copy-source-range")
@@ -261,7 +399,12 @@ func (h *Handler) UploadPartCopy(w http.ResponseWriter, r *http.Request) {
 		endByte = &end
 	}
 
-	part, err := h.storage.UploadPartCopy(r.Context(), bucket, key, uploadID, int32(partNumber), srcBucket, srcKey, startByte, endByte)
+	var part *storage.Part
+	if srcVersionID != "" {
+		part, err = h.uploadPartCopyVersion(r, bucket, key, uploadID, int32(partNumber), srcBucket, srcKey, srcVersionID, startByte, endByte)
+	} else {
+		part, err = h.storage.UploadPartCopy(r.Context(), bucket, key, uploadID, int32(partNumber), srcBucket, srcKey, startByte, endByte)
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrUploadNotFound) {
 			WriteError(w, ErrNoSuchUpload)
@@ -279,8 +422,7 @@ func (h *Handler) UploadPartCopy(w http.ResponseWriter, r *http.Request) {
 			WriteError(w, ErrInvalidRange)
 			return
 		}
-		log.Error().Err(err).Msg("Failed to upload part copy")
-		WriteError(w, ErrInternalError)
+		writeStorageError(w, r, err)
 		return
 	}
 
@@ -289,6 +431,9 @@ func (h *Handler) UploadPartCopy(w http.ResponseWriter, r *http.Request) {
 		LastModified: part.LastModified.Format(time.RFC3339),
 		ETag:         "\"" + part.ETag + "\"",
 	}
+	if checksum := newChecksum(part.ChecksumAlgorithm, part.Checksum); checksum != nil {
+		result.Checksum = *checksum
+	}
 
 	var buf bytes.Buffer
 	if err := xml.NewEncoder(&buf).Encode(result); err != nil {
@@ -297,11 +442,37 @@ func (h *Handler) UploadPartCopy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if srcVersionID != "" {
+		w.Header().Set("x-amz-copy-source-version-id", srcVersionID)
+	}
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(buf.Bytes())
 }
 
+// uploadPartCopyVersion copies a range of a version of an object to a part by
+// reading the version and uploading it as the part.
+func (h *Handler) uploadPartCopyVersion(r *http.Request, bucket, key, uploadID string, partNumber int32, srcBucket, srcKey, srcVersionID string, startByte, endByte *int64) (*storage.Part, error) {
+	src, err := h.storage.GetObjectVersioned(r.Context(), srcBucket, srcKey, srcVersionID)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Body.Close()
+
+	start, end := int64(0), src.Size-1
+	if startByte != nil && endByte != nil {
+		start, end = *startByte, *endByte
+		if start < 0 || end >= src.Size || start > end {
+			return nil, storage.ErrInvalidRange
+		}
+	}
+	if _, err := io.CopyN(io.Discard, src.Body, start); err != nil {
+		return nil, err
+	}
+
+	return h.storage.UploadPart(r.Context(), bucket, key, uploadID, partNumber, io.LimitReader(src.Body, end-start+1), end-start+1)
+}
+
 // CompleteMultipartUpload handles POST /{bucket}/{key}?uploadId={uploadId} - CompleteMultipartUpload.
 func (h *Handler) CompleteMultipartUpload(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
@@ -312,8 +483,8 @@ func (h *Handler) CompleteMultipartUpload(w http.ResponseWriter, r *http.Request
 
 	// Parse request body
 	var req CompleteMultipartUploadRequest
-	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteError(w, ErrInvalidRequest)
+	if s3Err := decodeXMLBody(r, &req, maxXMLListBodySize); s3Err != nil {
+		WriteError(w, s3Err)
 		return
 	}
 
@@ -323,9 +494,30 @@ func (h *Handler) CompleteMultipartUpload(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Validate parts are in order
-	for i := 1; i < len(req.Parts); i++ {
-		if req.Parts[i].PartNumber <= req.Parts[i-1].PartNumber {
+	// An upload has at most maxPartNumber parts, each listed once
+	if len(req.Parts) > maxPartNumber {
+		s3Err := *ErrInvalidArgument
+		s3Err.Message = fmt.Sprintf("The list of parts must not contain more than %d parts.", maxPartNumber)
+		WriteError(w, &s3Err)
+		return
+	}
+	for i, part := range req.Parts {
+		if part.PartNumber < 1 || part.PartNumber > maxPartNumber {
+			s3Err := *ErrInvalidArgument
+			s3Err.Message = fmt.Sprintf("Part number must be an integer between 1 and %d, inclusive.", maxPartNumber)
+			WriteError(w, &s3Err)
+			return
+		}
+		if i == 0 {
+			continue
+		}
+		if part.PartNumber == req.Parts[i-1].PartNumber {
+			s3Err := *ErrInvalidPartOrder
+			s3Err.Message = fmt.Sprintf("Part number %d is listed more than once.", part.PartNumber)
+			WriteError(w, &s3Err)
+			return
+		}
+		if part.PartNumber < req.Parts[i-1].PartNumber {
 			WriteError(w, ErrInvalidPartOrder)
 			return
 		}
@@ -334,9 +526,12 @@ func (h *Handler) CompleteMultipartUpload(w http.ResponseWriter, r *http.Request
 	// Convert to storage parts
 	parts := make([]storage.Part, len(req.Parts))
 	for i, p := range req.Parts {
+		algorithm, checksum := p.Checksum.value()
 		parts[i] = storage.Part{
-			PartNumber: p.PartNumber,
-			ETag:       p.ETag,
+			PartNumber:        p.PartNumber,
+			ETag:              p.ETag,
+			ChecksumAlgorithm: algorithm,
+			Checksum:          checksum,
 		}
 	}
 
@@ -347,30 +542,25 @@ func (h *Handler) CompleteMultipartUpload(w http.ResponseWriter, r *http.Request
 
 	obj, err := h.storage.CompleteMultipartUpload(r.Context(), bucket, key, uploadID, parts)
 	if err != nil {
-		if errors.Is(err, storage.ErrUploadNotFound) {
-			WriteError(w, ErrNoSuchUpload)
-			return
-		}
-		if errors.Is(err, storage.ErrInvalidPart) {
-			WriteError(w, ErrInvalidPart)
-			return
-		}
-		if errors.Is(err, storage.ErrBucketNotFound) {
-			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
-			return
-		}
-		log.Error().Err(err).Msg("Failed to complete multipart upload")
-		WriteError(w, ErrInternalError)
+		writeStorageError(w, r, err)
 		return
 	}
 
+	h.publish(r, events.ObjectCreatedCompleteMultipartUpload, bucket, key, obj, "")
+
 	result := CompleteMultipartUploadResult{
 		Xmlns:    "http://s3.amazonaws.com/doc/2006-03-01/",
-		Location: "/" + bucket + "/" + key,
+		Location: objectURL(r, bucket, key),
 		Bucket:   bucket,
 		Key:      key,
 		ETag:     "\"" + obj.ETag + "\"",
 	}
+	// The checksum of the part checksums, if the parts have checksums
+	if layout, err := h.storage.GetObjectParts(r.Context(), bucket, key); err != nil {
+		log.Warn().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to get object parts")
+	} else if checksum := objectChecksum(layout); checksum != nil {
+		result.Checksum = *checksum
+	}
 
 	var buf bytes.Buffer
 	if err := xml.NewEncoder(&buf).Encode(result); err != nil {
@@ -379,6 +569,7 @@ func (h *Handler) CompleteMultipartUpload(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	h.setEncryptionHeaders(w, r, bucket, key, "")
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(buf.Bytes())
@@ -394,16 +585,7 @@ func (h *Handler) AbortMultipartUpload(w http.ResponseWriter, r *http.Request) {
 
 	err := h.storage.AbortMultipartUpload(r.Context(), bucket, key, uploadID)
 	if err != nil {
-		if errors.Is(err, storage.ErrUploadNotFound) {
-			WriteError(w, ErrNoSuchUpload)
-			return
-		}
-		if errors.Is(err, storage.ErrBucketNotFound) {
-			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
-			return
-		}
-		log.Error().Err(err).Msg("Failed to abort multipart upload")
-		WriteError(w, ErrInternalError)
+		writeStorageError(w, r, err)
 		return
 	}
 
@@ -418,20 +600,15 @@ func (h *Handler) ListParts(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	uploadID := query.Get("uploadId")
 
-	maxPartsStr := query.Get("max-parts")
-	maxParts := int32(1000)
-	if maxPartsStr != "" {
-		if mp, err := strconv.ParseInt(maxPartsStr, 10, 32); err == nil && mp > 0 {
-			maxParts = int32(mp)
-		}
+	maxParts, s3Err := parseListLimit(query, "max-parts")
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
 	}
-
-	partNumberMarkerStr := query.Get("part-number-marker")
-	var partNumberMarker int32
-	if partNumberMarkerStr != "" {
-		if pnm, err := strconv.ParseInt(partNumberMarkerStr, 10, 32); err == nil {
-			partNumberMarker = int32(pnm)
-		}
+	partNumberMarker, s3Err := parseListMarker(query, "part-number-marker")
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
 	}
 
 	input := &storage.ListPartsInput{
@@ -444,28 +621,28 @@ func (h *Handler) ListParts(w http.ResponseWriter, r *http.Request) {
 
 	output, err := h.storage.ListParts(r.Context(), input)
 	if err != nil {
-		if errors.Is(err, storage.ErrUploadNotFound) {
-			WriteError(w, ErrNoSuchUpload)
-			return
-		}
-		if errors.Is(err, storage.ErrBucketNotFound) {
-			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
-			return
-		}
-		log.Error().Err(err).Msg("Failed to list parts")
-		WriteError(w, ErrInternalError)
+		writeStorageError(w, r, err)
 		return
 	}
+	if maxParts == 0 {
+		// The upload exists, but max-parts=0 asks for an empty page
+		output = &storage.ListPartsOutput{}
+	}
 
+	owner := requestOwner(r)
 	result := ListPartsResult{
-		Xmlns:            "http://s3.amazonaws.com/doc/2006-03-01/",
-		Bucket:           bucket,
-		Key:              key,
-		UploadId:         uploadID,
-		PartNumberMarker: partNumberMarker,
-		MaxParts:         maxParts,
-		IsTruncated:      output.IsTruncated,
-		Parts:            make([]PartInfo, len(output.Parts)),
+		Xmlns:             "http://s3.amazonaws.com/doc/2006-03-01/",
+		Bucket:            bucket,
+		Key:               key,
+		UploadId:          uploadID,
+		Initiator:         owner,
+		Owner:             owner,
+		StorageClass:      "STANDARD",
+		PartNumberMarker:  partNumberMarker,
+		MaxParts:          maxParts,
+		IsTruncated:       output.IsTruncated,
+		ChecksumAlgorithm: output.ChecksumAlgorithm,
+		Parts:             make([]PartInfo, len(output.Parts)),
 	}
 
 	if output.IsTruncated {
@@ -479,6 +656,9 @@ func (h *Handler) ListParts(w http.ResponseWriter, r *http.Request) {
 			ETag:         "\"" + part.ETag + "\"",
 			Size:         part.Size,
 		}
+		if checksum := newChecksum(part.ChecksumAlgorithm, part.Checksum); checksum != nil {
+			result.Parts[i].Checksum = *checksum
+		}
 	}
 
 	var buf bytes.Buffer
@@ -500,13 +680,18 @@ func (h *Handler) ListMultipartUploads(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 
 	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
 
-	maxUploadsStr := query.Get("max-uploads")
-	maxUploads := int32(1000)
-	if maxUploadsStr != "" {
-		if mu, err := strconv.ParseInt(maxUploadsStr, 10, 32); err == nil && mu > 0 {
-			maxUploads = int32(mu)
-		}
+	encodingType := query.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		WriteError(w, ErrInvalidArgument)
+		return
+	}
+
+	maxUploads, s3Err := parseListLimit(query, "max-uploads")
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
 	}
 
 	keyMarker := query.Get("key-marker")
@@ -515,12 +700,19 @@ func (h *Handler) ListMultipartUploads(w http.ResponseWriter, r *http.Request) {
 	input := &storage.ListMultipartUploadsInput{
 		Bucket:         bucket,
 		Prefix:         prefix,
+		Delimiter:      delimiter,
 		MaxUploads:     maxUploads,
 		KeyMarker:      keyMarker,
 		UploadIdMarker: uploadIdMarker,
 	}
 
-	output, err := h.storage.ListMultipartUploads(r.Context(), input)
+	output := &storage.ListMultipartUploadsOutput{}
+	var err error
+	if maxUploads > 0 {
+		output, err = h.storage.ListMultipartUploads(r.Context(), input)
+	} else {
+		err = h.checkListBucket(r.Context(), bucket)
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -534,25 +726,35 @@ func (h *Handler) ListMultipartUploads(w http.ResponseWriter, r *http.Request) {
 	result := ListMultipartUploadsResult{
 		Xmlns:          "http://s3.amazonaws.com/doc/2006-03-01/",
 		Bucket:         bucket,
-		KeyMarker:      keyMarker,
+		KeyMarker:      encodeListValue(keyMarker, encodingType),
 		UploadIdMarker: uploadIdMarker,
+		Prefix:         encodeListValue(prefix, encodingType),
+		Delimiter:      encodeListValue(delimiter, encodingType),
 		MaxUploads:     maxUploads,
+		EncodingType:   encodingType,
 		IsTruncated:    output.IsTruncated,
 		Uploads:        make([]UploadInfo, len(output.Uploads)),
 	}
 
 	if output.IsTruncated {
-		result.NextKeyMarker = output.NextKeyMarker
+		result.NextKeyMarker = encodeListValue(output.NextKeyMarker, encodingType)
 		result.NextUploadIdMarker = output.NextUploadIdMarker
 	}
 
+	owner := requestOwner(r)
 	for i, upload := range output.Uploads {
 		result.Uploads[i] = UploadInfo{
-			Key:       upload.Key,
-			UploadId:  upload.UploadID,
-			Initiated: upload.Initiated.Format(time.RFC3339),
+			Key:          encodeListValue(upload.Key, encodingType),
+			UploadId:     upload.UploadID,
+			Initiator:    owner,
+			Owner:        owner,
+			StorageClass: "STANDARD",
+			Initiated:    upload.Initiated.Format(time.RFC3339),
 		}
 	}
+	for _, commonPrefix := range output.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, CommonPrefix{Prefix: encodeListValue(commonPrefix, encodingType)})
+	}
 
 	var buf bytes.Buffer
 	if err := xml.NewEncoder(&buf).Encode(result); err != nil {
@@ -565,3 +767,12 @@ func (h *Handler) ListMultipartUploads(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(buf.Bytes())
 }
+
+// encodeListValue URL-encodes a key or prefix in a list response when the
+// client requested encoding-type=url. Slashes are kept as they are.
+func encodeListValue(value, encodingType string) string {
+	if encodingType != "url" {
+		return value
+	}
+	return strings.ReplaceAll(url.QueryEscape(value), "%2F", "/")
+}
@@ -10,17 +10,46 @@ import (
 	"strings"
 	"time"
 
+	"github.com/kumasuke/jog/internal/events"
 	"github.com/kumasuke/jog/internal/storage"
 	"github.com/rs/zerolog/log"
 )
 
 // GetObjectAttributesResponse is the response for GetObjectAttributes.
 type GetObjectAttributesResponse struct {
-	XMLName      xml.Name `xml:"GetObjectAttributesResponse"`
-	Xmlns        string   `xml:"xmlns,attr"`
-	ETag         string   `xml:"ETag,omitempty"`
-	StorageClass string   `xml:"StorageClass,omitempty"`
-	ObjectSize   *int64   `xml:"ObjectSize,omitempty"`
+	XMLName      xml.Name                  `xml:"GetObjectAttributesResponse"`
+	Xmlns        string                    `xml:"xmlns,attr"`
+	ETag         string                    `xml:"ETag,omitempty"`
+	Checksum     *Checksum                 `xml:"Checksum,omitempty"`
+	ObjectParts  *GetObjectAttributesParts `xml:"ObjectParts,omitempty"`
+	StorageClass string                    `xml:"StorageClass,omitempty"`
+	ObjectSize   *int64                    `xml:"ObjectSize,omitempty"`
+}
+
+// GetObjectAttributesParts is the ObjectParts section of GetObjectAttributes.
+type GetObjectAttributesParts struct {
+	TotalPartsCount      int32            `xml:"PartsCount"`
+	PartNumberMarker     int32            `xml:"PartNumberMarker"`
+	NextPartNumberMarker int32            `xml:"NextPartNumberMarker"`
+	MaxParts             int32            `xml:"MaxParts"`
+	IsTruncated          bool             `xml:"IsTruncated"`
+	Parts                []ObjectPartInfo `xml:"Part"`
+}
+
+// ObjectPartInfo represents a part in the ObjectParts section of GetObjectAttributes.
+type ObjectPartInfo struct {
+	PartNumber int32 `xml:"PartNumber"`
+	Size       int64 `xml:"Size"`
+	Checksum
+}
+
+// Checksum holds the checksum of an object or part. Only the field of the
+// algorithm used for the upload is set.
+type Checksum struct {
+	ChecksumCRC32  string `xml:"ChecksumCRC32,omitempty"`
+	ChecksumCRC32C string `xml:"ChecksumCRC32C,omitempty"`
+	ChecksumSHA1   string `xml:"ChecksumSHA1,omitempty"`
+	ChecksumSHA256 string `xml:"ChecksumSHA256,omitempty"`
 }
 
 // ListBucketResult is the response for ListObjectsV2.
@@ -99,10 +128,7 @@ func (h *Handler) PutObject(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 	key := GetKey(r)
 
-	contentType := r.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "application/octet-stream"
-	}
+	contentType := h.objectContentType(r, bucket, key)
 
 	// Get content length
 	contentLength := r.ContentLength
@@ -130,12 +156,10 @@ func (h *Handler) PutObject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse custom metadata
-	metadata := make(map[string]string)
-	for key, values := range r.Header {
-		if strings.HasPrefix(strings.ToLower(key), "x-amz-meta-") {
-			metaKey := strings.TrimPrefix(strings.ToLower(key), "x-amz-meta-")
-			metadata[metaKey] = values[0]
-		}
+	metadata, s3Err := parseUserMetadata(r)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
 	}
 
 	// Parse x-amz-tagging header
@@ -146,6 +170,30 @@ func (h *Handler) PutObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	r, s3Err = withServerSideEncryption(r)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
+	}
+	lock, s3Err := h.objectLockFromHeaders(r, bucket)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
+	}
+
+	// Validators see the whole upload before anything is stored
+	body, release, ok := h.validatedBody(w, r, &Upload{
+		Bucket:      bucket,
+		Key:         key,
+		ContentType: contentType,
+		Size:        contentLength,
+		Metadata:    metadata,
+	}, body)
+	if !ok {
+		return
+	}
+	defer release()
+
 	// Check if versioning is enabled
 	versioningStatus, _ := h.storage.GetBucketVersioning(r.Context(), bucket)
 
@@ -169,15 +217,35 @@ func (h *Handler) PutObject(w http.ResponseWriter, r *http.Request) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
 			return
 		}
+		if errors.Is(err, storage.ErrObjectImmutable) {
+			WriteErrorWithResource(w, ErrObjectImmutable, "/"+bucket+"/"+key)
+			return
+		}
+		if isRequestTimeout(err) {
+			WriteErrorWithResource(w, ErrRequestTimeout, "/"+bucket+"/"+key)
+			return
+		}
+		if writeKMSError(w, err, "/"+bucket+"/"+key) {
+			return
+		}
 		WriteError(w, ErrInternalError)
 		return
 	}
 
-	// Store tags if provided
+	// Unlike tags and ACLs, a requested lock that could not be applied fails
+	// the request, as the client relies on the object being protected
+	if err := h.putObjectLock(r.Context(), bucket, key, lock); err != nil {
+		log.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to set object lock")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	// Store tags if provided, along with the default tags of the bucket
 	// Note: Tag setting failure is logged but does not fail the request.
 	// This matches S3's behavior where the object creation is prioritized,
 	// and tag failures are treated as non-critical. The object is still
 	// usable without tags, and tags can be set separately via PutObjectTagging.
+	tags = h.withDefaultTags(r, bucket, tags)
 	if len(tags) > 0 {
 		if err := h.storage.PutObjectTagging(r.Context(), bucket, key, tags); err != nil {
 			log.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to set object tags")
@@ -202,10 +270,130 @@ func (h *Handler) PutObject(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	h.publish(r, events.ObjectCreatedPut, bucket, key, obj, versionID)
+
 	w.Header().Set("ETag", "\""+obj.ETag+"\"")
 	if versionID != "" {
 		w.Header().Set("x-amz-version-id", versionID)
 	}
+	h.setEncryptionHeaders(w, r, bucket, key, versionID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// AppendObject handles PUT /{bucket}/{key}?append&position={position} - AppendObject.
+// This is a JOG extension that appends the request body to an existing object.
+func (h *Handler) AppendObject(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+	key := GetKey(r)
+
+	position, err := strconv.ParseInt(r.URL.Query().Get("position"), 10, 64)
+	if err != nil || position < 0 {
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket+"/"+key)
+		return
+	}
+
+	contentType := h.objectContentType(r, bucket, key)
+
+	// Get content length
+	contentLength := r.ContentLength
+	if contentLength < 0 {
+		WriteError(w, ErrMissingContentLength)
+		return
+	}
+
+	// Check for aws-chunked encoding (streaming payload signature)
+	var body io.Reader = r.Body
+	if IsAWSChunked(r.Header.Get("Content-Encoding"), r.Header.Get("X-Amz-Content-Sha256")) {
+		if decodedLength, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64); err == nil {
+			contentLength = decodedLength
+		}
+		body = NewChunkedReader(r.Body)
+	}
+
+	// Parse custom metadata (only applied when the append creates the object)
+	metadata, s3Err := parseUserMetadata(r)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
+	}
+
+	r, s3Err = withServerSideEncryption(r)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+		return
+	}
+
+	// Appending to versioned objects is not supported
+	versioningStatus, _ := h.storage.GetBucketVersioning(r.Context(), bucket)
+	if versioningStatus == storage.VersioningStatusEnabled {
+		WriteErrorWithResource(w, ErrObjectNotAppendable, "/"+bucket+"/"+key)
+		return
+	}
+
+	// Validators see the appended data before anything is stored
+	body, release, ok := h.validatedBody(w, r, &Upload{
+		Bucket:      bucket,
+		Key:         key,
+		ContentType: contentType,
+		Size:        contentLength,
+		Metadata:    metadata,
+		Append:      true,
+		Position:    position,
+	}, body)
+	if !ok {
+		return
+	}
+	defer release()
+
+	obj, err := h.storage.AppendObject(r.Context(), bucket, key, position, body, contentLength, contentType, metadata)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidKey) {
+			WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket+"/"+key)
+			return
+		}
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		var positionErr *storage.PositionNotEqualToLengthError
+		if errors.As(err, &positionErr) {
+			w.Header().Set("x-jog-next-append-position", strconv.FormatInt(positionErr.Length, 10))
+			WriteErrorWithResource(w, ErrPositionNotEqualToLength, "/"+bucket+"/"+key)
+			return
+		}
+		if errors.Is(err, storage.ErrObjectNotAppendable) {
+			WriteErrorWithResource(w, ErrObjectNotAppendable, "/"+bucket+"/"+key)
+			return
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			WriteErrorWithResource(w, ErrIncompleteBody, "/"+bucket+"/"+key)
+			return
+		}
+		if errors.Is(err, storage.ErrObjectImmutable) {
+			WriteErrorWithResource(w, ErrObjectImmutable, "/"+bucket+"/"+key)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket+"/"+key)
+			return
+		}
+		if isRequestTimeout(err) {
+			WriteErrorWithResource(w, ErrRequestTimeout, "/"+bucket+"/"+key)
+			return
+		}
+		if writeKMSError(w, err, "/"+bucket+"/"+key) {
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to append object")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	h.publish(r, events.ObjectCreatedAppend, bucket, key, obj, "")
+
+	w.Header().Set("ETag", "\""+obj.ETag+"\"")
+	w.Header().Set("x-jog-next-append-position", strconv.FormatInt(obj.Size, 10))
+	h.setEncryptionHeaders(w, r, bucket, key, "")
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -219,11 +407,28 @@ func (h *Handler) GetObject(w http.ResponseWriter, r *http.Request) {
 
 	// Check for Range header
 	rangeHeader := r.Header.Get("Range")
-	if rangeHeader != "" && versionID == "" {
-		h.getObjectRange(w, r, bucket, key, rangeHeader)
+
+	// Check for partNumber query parameter
+	if partNumber := r.URL.Query().Get("partNumber"); partNumber != "" {
+		if rangeHeader != "" {
+			WriteError(w, ErrPartNumberWithRange)
+			return
+		}
+		if versionID != "" {
+			WriteError(w, ErrNotImplemented)
+			return
+		}
+		h.getObjectPart(w, r, bucket, key, partNumber)
 		return
 	}
 
+	if rangeHeader != "" && versionID == "" {
+		if spec, ok := parseRangeSpec(rangeHeader); ok {
+			h.getObjectRange(w, r, bucket, key, rangeHeader, spec)
+			return
+		}
+	}
+
 	var obj *storage.ObjectData
 	var err error
 
@@ -235,19 +440,7 @@ func (h *Handler) GetObject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		if errors.Is(err, storage.ErrInvalidKey) {
-			WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket+"/"+key)
-			return
-		}
-		if errors.Is(err, storage.ErrBucketNotFound) {
-			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
-			return
-		}
-		if errors.Is(err, storage.ErrObjectNotFound) {
-			WriteErrorWithResource(w, ErrNoSuchKey, "/"+bucket+"/"+key)
-			return
-		}
-		WriteError(w, ErrInternalError)
+		writeStorageError(w, r, err)
 		return
 	}
 	defer obj.Body.Close()
@@ -257,6 +450,7 @@ func (h *Handler) GetObject(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
 	w.Header().Set("ETag", "\""+obj.ETag+"\"")
 	w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	setAcceptRanges(w)
 
 	// Set version ID header if versioning was used
 	if versionID != "" {
@@ -267,6 +461,12 @@ func (h *Handler) GetObject(w http.ResponseWriter, r *http.Request) {
 	for k, v := range obj.Metadata {
 		w.Header().Set("x-amz-meta-"+k, v)
 	}
+	h.setEncryptionHeaders(w, r, bucket, key, versionID)
+	if versionID == "" {
+		h.setObjectChecksumHeader(w, r, bucket, key)
+		h.setObjectLockHeaders(w, r, bucket, key)
+	}
+	h.setPolicyHeaders(w, r, bucket, key)
 
 	w.WriteHeader(http.StatusOK)
 	if _, err := io.Copy(w, obj.Body); err != nil {
@@ -275,85 +475,23 @@ func (h *Handler) GetObject(w http.ResponseWriter, r *http.Request) {
 }
 
 // getObjectRange handles GET with Range header.
-func (h *Handler) getObjectRange(w http.ResponseWriter, r *http.Request, bucket, key, rangeHeader string) {
-	// Parse range header: bytes=start-end
-	if !strings.HasPrefix(rangeHeader, "bytes=") {
-		WriteError(w, ErrInvalidRange)
-		return
-	}
-
+func (h *Handler) getObjectRange(w http.ResponseWriter, r *http.Request, bucket, key, rangeHeader string, spec rangeSpec) {
 	// Get object metadata first
 	objMeta, err := h.storage.HeadObject(r.Context(), bucket, key)
 	if err != nil {
-		if errors.Is(err, storage.ErrInvalidKey) {
-			WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket+"/"+key)
-			return
-		}
-		if errors.Is(err, storage.ErrBucketNotFound) {
-			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
-			return
-		}
-		if errors.Is(err, storage.ErrObjectNotFound) {
-			WriteErrorWithResource(w, ErrNoSuchKey, "/"+bucket+"/"+key)
-			return
-		}
-		WriteError(w, ErrInternalError)
+		writeStorageError(w, r, err)
 		return
 	}
 
-	rangeSpec := strings.TrimPrefix(rangeHeader, "bytes=")
-	parts := strings.Split(rangeSpec, "-")
-	if len(parts) != 2 {
-		WriteError(w, ErrInvalidRange)
+	br, ok := spec.resolve(objMeta.Size)
+	if !ok {
+		writeRangeNotSatisfiable(w, rangeHeader, objMeta.Size, "/"+bucket+"/"+key)
 		return
 	}
 
-	var start, end int64
-
-	if parts[0] == "" {
-		// Suffix range: -500 means last 500 bytes
-		suffix, err := strconv.ParseInt(parts[1], 10, 64)
-		if err != nil {
-			WriteError(w, ErrInvalidRange)
-			return
-		}
-		start = objMeta.Size - suffix
-		end = objMeta.Size - 1
-	} else if parts[1] == "" {
-		// From start to end: 500-
-		start, err = strconv.ParseInt(parts[0], 10, 64)
-		if err != nil {
-			WriteError(w, ErrInvalidRange)
-			return
-		}
-		end = objMeta.Size - 1
-	} else {
-		// Explicit range: 0-499
-		start, err = strconv.ParseInt(parts[0], 10, 64)
-		if err != nil {
-			WriteError(w, ErrInvalidRange)
-			return
-		}
-		end, err = strconv.ParseInt(parts[1], 10, 64)
-		if err != nil {
-			WriteError(w, ErrInvalidRange)
-			return
-		}
-	}
-
-	// Validate range
-	if start < 0 || end >= objMeta.Size || start > end {
-		WriteError(w, ErrInvalidRange)
-		return
-	}
-
-	obj, err := h.storage.GetObjectRange(r.Context(), bucket, key, start, end)
+	obj, err := h.storage.GetObjectRange(r.Context(), bucket, key, br.start, br.end)
 	if err != nil {
-		if errors.Is(err, storage.ErrInvalidKey) {
-			WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket+"/"+key)
-			return
-		}
-		WriteError(w, ErrInternalError)
+		writeStorageError(w, r, err)
 		return
 	}
 	defer obj.Body.Close()
@@ -361,9 +499,13 @@ func (h *Handler) getObjectRange(w http.ResponseWriter, r *http.Request, bucket,
 	// Set response headers
 	w.Header().Set("Content-Type", obj.ContentType)
 	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
-	w.Header().Set("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10)+"/"+strconv.FormatInt(objMeta.Size, 10))
+	w.Header().Set("Content-Range", br.contentRange(objMeta.Size))
 	w.Header().Set("ETag", "\""+obj.ETag+"\"")
 	w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	setAcceptRanges(w)
+	h.setEncryptionHeaders(w, r, bucket, key, "")
+	h.setObjectLockHeaders(w, r, bucket, key)
+	h.setPolicyHeaders(w, r, bucket, key)
 
 	w.WriteHeader(http.StatusPartialContent)
 	if _, err := io.Copy(w, obj.Body); err != nil {
@@ -376,8 +518,29 @@ func (h *Handler) HeadObject(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 	key := GetKey(r)
 
-	obj, err := h.storage.HeadObject(r.Context(), bucket, key)
+	if partNumber := r.URL.Query().Get("partNumber"); partNumber != "" {
+		h.headObjectPart(w, r, bucket, key, partNumber)
+		return
+	}
+
+	versionID := r.URL.Query().Get("versionId")
+
+	var obj *storage.Object
+	var err error
+	if versionID != "" {
+		obj, err = h.storage.HeadObjectVersioned(r.Context(), bucket, key, versionID)
+	} else {
+		obj, err = h.storage.HeadObject(r.Context(), bucket, key)
+	}
 	if err != nil {
+		var marker *storage.DeleteMarkerError
+		if errors.As(err, &marker) {
+			setDeleteMarkerHeaders(w, r, marker)
+			if versionID != "" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+		}
 		if errors.Is(err, storage.ErrInvalidKey) {
 			w.WriteHeader(http.StatusBadRequest)
 			return
@@ -394,18 +557,45 @@ func (h *Handler) HeadObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A Range header gets the metadata of the would-be partial response
+	status := http.StatusOK
+	contentLength := obj.Size
+	if spec, ok := parseRangeSpec(r.Header.Get("Range")); ok {
+		br, ok := spec.resolve(obj.Size)
+		if !ok {
+			w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(obj.Size, 10))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", br.contentRange(obj.Size))
+		status = http.StatusPartialContent
+		contentLength = br.length()
+	}
+
 	// Set response headers
 	w.Header().Set("Content-Type", obj.ContentType)
-	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
 	w.Header().Set("ETag", "\""+obj.ETag+"\"")
 	w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	setAcceptRanges(w)
+	if versionID != "" {
+		w.Header().Set("x-amz-version-id", versionID)
+	}
 
 	// Set custom metadata headers
 	for k, v := range obj.Metadata {
 		w.Header().Set("x-amz-meta-"+k, v)
 	}
+	h.setEncryptionHeaders(w, r, bucket, key, versionID)
+	if versionID == "" {
+		if status == http.StatusOK {
+			h.setObjectChecksumHeader(w, r, bucket, key)
+		}
+		h.setObjectLockHeaders(w, r, bucket, key)
+	}
+	h.setPolicyHeaders(w, r, bucket, key)
 
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 }
 
 // DeleteObject handles DELETE /{bucket}/{key} - DeleteObject.
@@ -416,6 +606,15 @@ func (h *Handler) DeleteObject(w http.ResponseWriter, r *http.Request) {
 	// Check for versionId query parameter
 	versionID := r.URL.Query().Get("versionId")
 
+	// Permanently deleting a version of a bucket with MFA delete enabled
+	// needs MFA authentication
+	if versionID != "" && h.mfaDeleteEnabled(r, bucket) {
+		if s3Err := h.checkMFA(r); s3Err != nil {
+			WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
+			return
+		}
+	}
+
 	// Check if versioning is enabled
 	versioningStatus, _ := h.storage.GetBucketVersioning(r.Context(), bucket)
 
@@ -423,23 +622,21 @@ func (h *Handler) DeleteObject(w http.ResponseWriter, r *http.Request) {
 		// Use versioned delete
 		returnedVersionID, isDeleteMarker, err := h.storage.DeleteObjectVersioned(r.Context(), bucket, key, versionID)
 		if err != nil {
-			if errors.Is(err, storage.ErrInvalidKey) {
-				WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket+"/"+key)
-				return
-			}
-			if errors.Is(err, storage.ErrBucketNotFound) {
-				WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
-				return
-			}
 			if errors.Is(err, storage.ErrObjectNotFound) {
 				// S3 returns 204 even if version doesn't exist
 				w.WriteHeader(http.StatusNoContent)
 				return
 			}
-			WriteError(w, ErrInternalError)
+			writeStorageError(w, r, err)
 			return
 		}
 
+		if isDeleteMarker {
+			h.publish(r, events.ObjectRemovedDeleteMarkerCreated, bucket, key, nil, returnedVersionID)
+		} else {
+			h.publish(r, events.ObjectRemovedDelete, bucket, key, nil, returnedVersionID)
+		}
+
 		if returnedVersionID != "" {
 			w.Header().Set("x-amz-version-id", returnedVersionID)
 		}
@@ -461,7 +658,13 @@ func (h *Handler) DeleteObject(w http.ResponseWriter, r *http.Request) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
 			return
 		}
+		if errors.Is(err, storage.ErrObjectImmutable) {
+			WriteErrorWithResource(w, ErrObjectImmutable, "/"+bucket+"/"+key)
+			return
+		}
 		// S3 returns 204 even if object doesn't exist
+	} else {
+		h.publish(r, events.ObjectRemovedDelete, bucket, key, nil, "")
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -473,19 +676,32 @@ func (h *Handler) DeleteObjects(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var deleteReq DeleteRequest
-	if err := xml.NewDecoder(r.Body).Decode(&deleteReq); err != nil {
-		WriteError(w, ErrMalformedXML)
+	if s3Err := decodeXMLBody(r, &deleteReq, maxXMLListBodySize); s3Err != nil {
+		WriteError(w, s3Err)
 		return
 	}
 
-	// Extract keys from request
-	keys := make([]string, len(deleteReq.Objects))
-	for i, obj := range deleteReq.Objects {
-		keys[i] = obj.Key
+	// Extract keys from request, leaving out the keys the caller may not
+	// delete, which are reported as errors
+	keys := make([]string, 0, len(deleteReq.Objects))
+	var denied []storage.DeleteError
+	for _, obj := range deleteReq.Objects {
+		allowed, err := h.allowed(r, "s3:DeleteObject", bucket, obj.Key)
+		if err != nil {
+			log.Error().Err(err).Str("bucket", bucket).Msg("Failed to authorize DeleteObjects")
+			WriteError(w, ErrInternalError)
+			return
+		}
+		if !allowed {
+			denied = append(denied, storage.DeleteError{Key: obj.Key, Code: "AccessDenied", Message: "Access Denied"})
+			continue
+		}
+		keys = append(keys, obj.Key)
 	}
 
 	// Delete objects
 	deleted, errs, err := h.storage.DeleteObjects(r.Context(), bucket, keys)
+	errs = append(denied, errs...)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -495,6 +711,10 @@ func (h *Handler) DeleteObjects(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for _, d := range deleted {
+		h.publish(r, events.ObjectRemovedDelete, bucket, d.Key, nil, "")
+	}
+
 	// Build response
 	result := DeleteResult{
 		Xmlns:  "http://s3.amazonaws.com/doc/2006-03-01/",
@@ -526,34 +746,87 @@ func (h *Handler) DeleteObjects(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// CopyObject handles PUT /{bucket}/{key} with x-amz-copy-source header - CopyObject.
-func (h *Handler) CopyObject(w http.ResponseWriter, r *http.Request) {
-	dstBucket := GetBucket(r)
-	dstKey := GetKey(r)
-
-	// Get copy source from header
-	copySource := r.Header.Get("x-amz-copy-source")
+// parseCopySource parses an x-amz-copy-source header of the form
+// [/]bucket/key[?versionId=id] with a URL-encoded key.
+func parseCopySource(copySource string) (bucket, key, versionID string, ok bool) {
 	if copySource == "" {
-		WriteError(w, ErrInvalidRequest)
-		return
+		return "", "", "", false
+	}
+
+	copySource, rawQuery, _ := strings.Cut(copySource, "?")
+	if rawQuery != "" {
+		query, err := url.ParseQuery(rawQuery)
+		if err != nil || !query.Has("versionId") || query.Get("versionId") == "" {
+			return "", "", "", false
+		}
+		versionID = query.Get("versionId")
 	}
 
 	// URL decode the copy source (may contain URL-encoded characters)
 	copySource, err := url.QueryUnescape(copySource)
 	if err != nil {
-		WriteError(w, ErrInvalidRequest)
-		return
+		return "", "", "", false
 	}
 
-	// Parse copy source: /bucket/key or bucket/key
 	copySource = strings.TrimPrefix(copySource, "/")
-	parts := strings.SplitN(copySource, "/", 2)
-	if len(parts) != 2 {
+	bucket, key, found := strings.Cut(copySource, "/")
+	if !found || bucket == "" || key == "" {
+		return "", "", "", false
+	}
+	return bucket, key, versionID, true
+}
+
+// copyObjectVersion copies the current object or a version of it by reading
+// it and putting it as the destination. With versioned set the destination
+// gets a new version, whose ID is returned.
+func (h *Handler) copyObjectVersion(r *http.Request, srcBucket, srcKey, srcVersionID, dstBucket, dstKey string, metadata map[string]string, versioned bool) (*storage.Object, string, error) {
+	var src *storage.ObjectData
+	var err error
+	if srcVersionID != "" {
+		src, err = h.storage.GetObjectVersioned(r.Context(), srcBucket, srcKey, srcVersionID)
+	} else {
+		src, err = h.storage.GetObject(r.Context(), srcBucket, srcKey)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			return nil, "", &storage.BucketNotFoundError{Bucket: srcBucket}
+		}
+		return nil, "", err
+	}
+	defer src.Body.Close()
+
+	// COPY directive - preserve original metadata
+	if metadata == nil {
+		metadata = src.Metadata
+	}
+
+	var obj *storage.Object
+	var versionID string
+	if versioned {
+		obj, versionID, err = h.storage.PutObjectVersioned(r.Context(), dstBucket, dstKey, src.Body, src.Size, src.ContentType, metadata)
+	} else {
+		obj, err = h.storage.PutObject(r.Context(), dstBucket, dstKey, src.Body, src.Size, src.ContentType, metadata)
+	}
+	if errors.Is(err, storage.ErrBucketNotFound) {
+		return nil, "", &storage.BucketNotFoundError{Bucket: dstBucket}
+	}
+	return obj, versionID, err
+}
+
+// CopyObject handles PUT /{bucket}/{key} with x-amz-copy-source header - CopyObject.
+func (h *Handler) CopyObject(w http.ResponseWriter, r *http.Request) {
+	dstBucket := GetBucket(r)
+	dstKey := GetKey(r)
+
+	// Parse copy source: /bucket/key or bucket/key, optionally with ?versionId=
+	srcBucket, srcKey, srcVersionID, ok := parseCopySource(r.Header.Get("x-amz-copy-source"))
+	if !ok {
 		WriteError(w, ErrInvalidRequest)
 		return
 	}
-	srcBucket := parts[0]
-	srcKey := parts[1]
+	if !h.authorizeCopySource(w, r, srcBucket, srcKey, srcVersionID) {
+		return
+	}
 
 	// Get metadata directive (default is COPY)
 	metadataDirective := r.Header.Get("x-amz-metadata-directive")
@@ -561,20 +834,52 @@ func (h *Handler) CopyObject(w http.ResponseWriter, r *http.Request) {
 		metadataDirective = "COPY"
 	}
 
+	r, s3Err := withServerSideEncryption(r)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+dstBucket+"/"+dstKey)
+		return
+	}
+	// The lock of the source object is not copied
+	lock, s3Err := h.objectLockFromHeaders(r, dstBucket)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+dstBucket+"/"+dstKey)
+		return
+	}
+
+	// A copy of the current object onto itself must replace the metadata,
+	// which then updates it without rewriting the data, or change its
+	// encryption
+	if srcBucket == dstBucket && srcKey == dstKey && srcVersionID == "" && metadataDirective != "REPLACE" && r.Header.Get(headerSSE) == "" {
+		s3Err := *ErrInvalidRequest
+		s3Err.Message = "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes."
+		WriteErrorWithResource(w, &s3Err, "/"+dstBucket+"/"+dstKey)
+		return
+	}
+
 	var metadata map[string]string
 	if metadataDirective == "REPLACE" {
 		// Use new metadata from request headers
-		metadata = make(map[string]string)
-		for key, values := range r.Header {
-			if strings.HasPrefix(strings.ToLower(key), "x-amz-meta-") {
-				metaKey := strings.TrimPrefix(strings.ToLower(key), "x-amz-meta-")
-				metadata[metaKey] = values[0]
-			}
+		var s3Err *S3Error
+		metadata, s3Err = parseUserMetadata(r)
+		if s3Err != nil {
+			WriteErrorWithResource(w, s3Err, "/"+dstBucket+"/"+dstKey)
+			return
 		}
 	}
 	// If COPY, pass nil to preserve original metadata
 
-	obj, err := h.storage.CopyObject(r.Context(), srcBucket, srcKey, dstBucket, dstKey, metadata)
+	// Copies into buckets with versioning and copies of older versions are
+	// streamed through a put, other copies are done by the storage backend
+	versioningStatus, _ := h.storage.GetBucketVersioning(r.Context(), dstBucket)
+
+	var obj *storage.Object
+	var versionID string
+	var err error
+	if versioningStatus == storage.VersioningStatusEnabled || srcVersionID != "" {
+		obj, versionID, err = h.copyObjectVersion(r, srcBucket, srcKey, srcVersionID, dstBucket, dstKey, metadata, versioningStatus == storage.VersioningStatusEnabled)
+	} else {
+		obj, err = h.storage.CopyObject(r.Context(), srcBucket, srcKey, dstBucket, dstKey, metadata)
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrInvalidKey) {
 			WriteErrorWithResource(w, ErrInvalidArgument, "/"+dstBucket+"/"+dstKey)
@@ -589,9 +894,31 @@ func (h *Handler) CopyObject(w http.ResponseWriter, r *http.Request) {
 			WriteErrorWithResource(w, ErrNoSuchKey, "/"+srcBucket+"/"+srcKey)
 			return
 		}
+		if errors.Is(err, storage.ErrObjectImmutable) {
+			WriteErrorWithResource(w, ErrObjectImmutable, "/"+dstBucket+"/"+dstKey)
+			return
+		}
+		if writeKMSError(w, err, "/"+dstBucket+"/"+dstKey) {
+			return
+		}
 		WriteError(w, ErrInternalError)
 		return
 	}
+	if err := h.putObjectLock(r.Context(), dstBucket, dstKey, lock); err != nil {
+		log.Error().Err(err).Str("bucket", dstBucket).Str("key", dstKey).Msg("Failed to set object lock")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	h.publish(r, events.ObjectCreatedCopy, dstBucket, dstKey, obj, versionID)
+
+	if srcVersionID != "" {
+		w.Header().Set("x-amz-copy-source-version-id", srcVersionID)
+	}
+	if versionID != "" {
+		w.Header().Set("x-amz-version-id", versionID)
+	}
+	h.setEncryptionHeaders(w, r, dstBucket, dstKey, versionID)
 
 	result := CopyObjectResult{
 		Xmlns:        "http://s3.amazonaws.com/doc/2006-03-01/",
@@ -650,6 +977,25 @@ func (h *Handler) GetObjectAttributes(w http.ResponseWriter, r *http.Request) {
 	if len(requestedAttrs) == 0 || requestedAttrs["StorageClass"] {
 		result.StorageClass = "STANDARD"
 	}
+	if len(requestedAttrs) == 0 || requestedAttrs["ObjectParts"] || requestedAttrs["Checksum"] {
+		parts, err := h.storage.GetObjectParts(r.Context(), bucket, key)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get object parts")
+			WriteError(w, ErrInternalError)
+			return
+		}
+		if len(requestedAttrs) == 0 || requestedAttrs["ObjectParts"] {
+			objectParts, s3Err := objectAttributesParts(r, parts)
+			if s3Err != nil {
+				WriteError(w, s3Err)
+				return
+			}
+			result.ObjectParts = objectParts
+		}
+		if len(requestedAttrs) == 0 || requestedAttrs["Checksum"] {
+			result.Checksum = objectChecksum(parts)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/xml")
 	w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
@@ -682,14 +1028,12 @@ func (h *Handler) ListObjects(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	prefix := query.Get("prefix")
 	delimiter := query.Get("delimiter")
-	maxKeysStr := query.Get("max-keys")
 	marker := query.Get("marker")
 
-	maxKeys := int32(1000)
-	if maxKeysStr != "" {
-		if mk, err := strconv.ParseInt(maxKeysStr, 10, 32); err == nil {
-			maxKeys = int32(mk)
-		}
+	maxKeys, s3Err := parseListLimit(query, "max-keys")
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
 	}
 
 	// Use marker as start-after for the storage layer
@@ -700,8 +1044,18 @@ func (h *Handler) ListObjects(w http.ResponseWriter, r *http.Request) {
 		MaxKeys:    maxKeys,
 		StartAfter: marker,
 	}
+	if s3Err := parseTagFilter(query, input); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
+	}
 
-	output, err := h.storage.ListObjectsV2(r.Context(), input)
+	output := &storage.ListObjectsOutput{}
+	var err error
+	if maxKeys > 0 {
+		output, err = h.storage.ListObjectsV2(r.Context(), input)
+	} else {
+		err = h.checkListBucket(r.Context(), bucket)
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -733,9 +1087,9 @@ func (h *Handler) ListObjects(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Set NextMarker if truncated (use the last key in the result)
-	if output.IsTruncated && len(output.Objects) > 0 {
-		result.NextMarker = output.Objects[len(output.Objects)-1].Key
+	// Set NextMarker if truncated (the last key or common prefix in the result)
+	if output.IsTruncated {
+		result.NextMarker = output.NextContinuationToken
 	}
 
 	for _, prefix := range output.CommonPrefixes {
@@ -757,27 +1111,47 @@ func (h *Handler) ListObjectsV2(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	prefix := query.Get("prefix")
 	delimiter := query.Get("delimiter")
-	maxKeysStr := query.Get("max-keys")
 	continuationToken := query.Get("continuation-token")
 	startAfter := query.Get("start-after")
 
-	maxKeys := int32(1000)
-	if maxKeysStr != "" {
-		if mk, err := strconv.ParseInt(maxKeysStr, 10, 32); err == nil {
-			maxKeys = int32(mk)
+	if IsDirectoryBucket(bucket) {
+		if s3Err := validateDirectoryListing(prefix, delimiter); s3Err != nil {
+			WriteErrorWithResource(w, s3Err, "/"+bucket)
+			return
 		}
 	}
 
+	maxKeys, s3Err := parseListLimit(query, "max-keys")
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
+	}
+	continuationKey, s3Err := decodeContinuationToken(continuationToken)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
+	}
+
 	input := &storage.ListObjectsInput{
 		Bucket:            bucket,
 		Prefix:            prefix,
 		Delimiter:         delimiter,
 		MaxKeys:           maxKeys,
-		ContinuationToken: continuationToken,
+		ContinuationToken: continuationKey,
 		StartAfter:        startAfter,
 	}
+	if s3Err := parseTagFilter(query, input); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
+	}
 
-	output, err := h.storage.ListObjectsV2(r.Context(), input)
+	output := &storage.ListObjectsOutput{}
+	var err error
+	if maxKeys > 0 {
+		output, err = h.storage.ListObjectsV2(r.Context(), input)
+	} else {
+		err = h.checkListBucket(r.Context(), bucket)
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -796,7 +1170,7 @@ func (h *Handler) ListObjectsV2(w http.ResponseWriter, r *http.Request) {
 		IsTruncated:           output.IsTruncated,
 		KeyCount:              output.KeyCount,
 		ContinuationToken:     continuationToken,
-		NextContinuationToken: output.NextContinuationToken,
+		NextContinuationToken: EncodeContinuationToken(output.NextContinuationToken),
 		StartAfter:            startAfter,
 		Contents:              make([]ObjectInfo, len(output.Objects)),
 	}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// maxPartNumber is the highest part number of a multipart upload.
+const maxPartNumber = 10000
+
+// objectPart is the byte range of a single part of an object.
+type objectPart struct {
+	object *storage.Object
+	start  int64
+	end    int64
+	// count is the number of parts of a multipart object, or 0 for objects
+	// uploaded in a single request.
+	count int
+}
+
+// resolveObjectPart looks up the byte range of part partNumber of an object.
+// Objects uploaded in a single request consist of part 1 only.
+func (h *Handler) resolveObjectPart(r *http.Request, bucket, key, partNumber string) (*objectPart, *S3Error, string) {
+	number, err := strconv.Atoi(partNumber)
+	if err != nil || number < 1 || number > maxPartNumber {
+		return nil, ErrInvalidArgument, ""
+	}
+
+	obj, err := h.storage.HeadObject(r.Context(), bucket, key)
+	if err != nil {
+		s3Err, resource := objectLookupError(err, bucket, key)
+		return nil, s3Err, resource
+	}
+	parts, err := h.storage.GetObjectParts(r.Context(), bucket, key)
+	if err != nil {
+		s3Err, resource := objectLookupError(err, bucket, key)
+		return nil, s3Err, resource
+	}
+
+	if len(parts) == 0 {
+		if number != 1 {
+			return nil, ErrInvalidPartNumber, ""
+		}
+		return &objectPart{object: obj, start: 0, end: obj.Size - 1}, nil, ""
+	}
+
+	for _, part := range parts {
+		if int(part.PartNumber) == number {
+			return &objectPart{object: obj, start: part.Offset, end: part.Offset + part.Size - 1, count: len(parts)}, nil, ""
+		}
+	}
+	return nil, ErrInvalidPartNumber, ""
+}
+
+// objectLookupError maps a storage error of an object lookup to an S3 error and resource.
+func objectLookupError(err error, bucket, key string) (*S3Error, string) {
+	switch {
+	case errors.Is(err, storage.ErrInvalidKey):
+		return ErrInvalidArgument, "/" + bucket + "/" + key
+	case errors.Is(err, storage.ErrBucketNotFound):
+		return ErrNoSuchBucket, "/" + bucket
+	case errors.Is(err, storage.ErrObjectNotFound):
+		return ErrNoSuchKey, "/" + bucket + "/" + key
+	default:
+		return ErrInternalError, ""
+	}
+}
+
+// setObjectPartHeaders sets the response headers of a part-level GET or HEAD.
+func setObjectPartHeaders(w http.ResponseWriter, part *objectPart) {
+	obj := part.object
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(part.end-part.start+1, 10))
+	if obj.Size > 0 {
+		w.Header().Set("Content-Range", "bytes "+strconv.FormatInt(part.start, 10)+"-"+strconv.FormatInt(part.end, 10)+"/"+strconv.FormatInt(obj.Size, 10))
+	}
+	w.Header().Set("ETag", "\""+obj.ETag+"\"")
+	w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	setAcceptRanges(w)
+	if part.count > 0 {
+		w.Header().Set("x-amz-mp-parts-count", strconv.Itoa(part.count))
+	}
+	for k, v := range obj.Metadata {
+		w.Header().Set("x-amz-meta-"+k, v)
+	}
+}
+
+// getObjectPart handles GET /{bucket}/{key}?partNumber={n}.
+func (h *Handler) getObjectPart(w http.ResponseWriter, r *http.Request, bucket, key, partNumber string) {
+	part, s3Err, resource := h.resolveObjectPart(r, bucket, key, partNumber)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, resource)
+		return
+	}
+
+	var body io.ReadCloser = http.NoBody
+	if part.object.Size > 0 {
+		obj, err := h.storage.GetObjectRange(r.Context(), bucket, key, part.start, part.end)
+		if err != nil {
+			s3Err, resource := objectLookupError(err, bucket, key)
+			WriteErrorWithResource(w, s3Err, resource)
+			return
+		}
+		body = obj.Body
+	}
+	defer body.Close()
+
+	setObjectPartHeaders(w, part)
+	h.setPolicyHeaders(w, r, bucket, key)
+	w.WriteHeader(http.StatusPartialContent)
+	if _, err := io.Copy(w, body); err != nil {
+		log.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to write object part")
+	}
+}
+
+// headObjectPart handles HEAD /{bucket}/{key}?partNumber={n}.
+func (h *Handler) headObjectPart(w http.ResponseWriter, r *http.Request, bucket, key, partNumber string) {
+	part, s3Err, _ := h.resolveObjectPart(r, bucket, key, partNumber)
+	if s3Err != nil {
+		w.WriteHeader(s3Err.HTTPStatus)
+		return
+	}
+
+	setObjectPartHeaders(w, part)
+	h.setPolicyHeaders(w, r, bucket, key)
+	w.WriteHeader(http.StatusPartialContent)
+}
+
+// objectAttributesParts builds the ObjectParts section of GetObjectAttributes,
+// paginated by the x-amz-max-parts and x-amz-part-number-marker headers. It
+// returns nil for objects uploaded in a single request.
+func objectAttributesParts(r *http.Request, parts []storage.ObjectPart) (*GetObjectAttributesParts, *S3Error) {
+	maxParts := int32(1000)
+	if v := r.Header.Get("x-amz-max-parts"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidArgument
+		}
+		maxParts = int32(n)
+	}
+	var marker int32
+	if v := r.Header.Get("x-amz-part-number-marker"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidArgument
+		}
+		marker = int32(n)
+	}
+
+	if len(parts) == 0 {
+		return nil, nil
+	}
+
+	result := &GetObjectAttributesParts{
+		TotalPartsCount:  int32(len(parts)),
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+	}
+	for _, part := range parts {
+		if part.PartNumber <= marker {
+			continue
+		}
+		if int32(len(result.Parts)) == maxParts {
+			result.IsTruncated = true
+			break
+		}
+		info := ObjectPartInfo{PartNumber: part.PartNumber, Size: part.Size}
+		if checksum := newChecksum(part.ChecksumAlgorithm, part.Checksum); checksum != nil {
+			info.Checksum = *checksum
+		}
+		result.Parts = append(result.Parts, info)
+		result.NextPartNumberMarker = part.PartNumber
+	}
+	return result, nil
+}
@@ -1,9 +1,9 @@
 package api
 
 import (
+	"context"
 	"encoding/xml"
 	"errors"
-	"io"
 	"net/http"
 	"time"
 
@@ -11,6 +11,13 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Object lock headers of object writes and responses.
+const (
+	headerObjectLockMode            = "x-amz-object-lock-mode"
+	headerObjectLockRetainUntilDate = "x-amz-object-lock-retain-until-date"
+	headerObjectLockLegalHold       = "x-amz-object-lock-legal-hold"
+)
+
 // ObjectLockConfiguration represents the XML structure for object lock configuration.
 type ObjectLockConfiguration struct {
 	XMLName           xml.Name             `xml:"ObjectLockConfiguration"`
@@ -51,15 +58,9 @@ func (h *Handler) PutObjectLockConfiguration(w http.ResponseWriter, r *http.Requ
 	bucket := GetBucket(r)
 
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket)
-		return
-	}
-
 	var config ObjectLockConfiguration
-	if err := xml.Unmarshal(body, &config); err != nil {
-		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket)
+	if s3Err := decodeXMLBody(r, &config, maxXMLBodySize); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
 		return
 	}
 
@@ -79,7 +80,7 @@ func (h *Handler) PutObjectLockConfiguration(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Store object lock configuration
-	err = h.storage.PutObjectLockConfiguration(r.Context(), bucket, storageConfig)
+	err := h.storage.PutObjectLockConfiguration(r.Context(), bucket, storageConfig)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -148,15 +149,9 @@ func (h *Handler) PutObjectRetention(w http.ResponseWriter, r *http.Request) {
 	key := GetKey(r)
 
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket+"/"+key)
-		return
-	}
-
 	var retention ObjectLockRetention
-	if err := xml.Unmarshal(body, &retention); err != nil {
-		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket+"/"+key)
+	if s3Err := decodeXMLBody(r, &retention, maxXMLBodySize); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
 		return
 	}
 
@@ -167,7 +162,7 @@ func (h *Handler) PutObjectRetention(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Store object retention
-	err = h.storage.PutObjectRetention(r.Context(), bucket, key, storageRetention)
+	err := h.storage.PutObjectRetention(r.Context(), bucket, key, storageRetention)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket+"/"+key)
@@ -233,15 +228,9 @@ func (h *Handler) PutObjectLegalHold(w http.ResponseWriter, r *http.Request) {
 	key := GetKey(r)
 
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket+"/"+key)
-		return
-	}
-
 	var legalHold ObjectLockLegalHold
-	if err := xml.Unmarshal(body, &legalHold); err != nil {
-		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket+"/"+key)
+	if s3Err := decodeXMLBody(r, &legalHold, maxXMLBodySize); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
 		return
 	}
 
@@ -251,7 +240,7 @@ func (h *Handler) PutObjectLegalHold(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Store object legal hold
-	err = h.storage.PutObjectLegalHold(r.Context(), bucket, key, storageLegalHold)
+	err := h.storage.PutObjectLegalHold(r.Context(), bucket, key, storageLegalHold)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket+"/"+key)
@@ -309,3 +298,105 @@ func (h *Handler) GetObjectLegalHold(w http.ResponseWriter, r *http.Request) {
 		log.Error().Err(err).Msg("Failed to encode GetObjectLegalHold response")
 	}
 }
+
+// objectLockFromHeaders parses the object lock headers of an object write. It
+// returns nil if none were sent. The headers are only accepted for buckets
+// with object lock enabled.
+func (h *Handler) objectLockFromHeaders(r *http.Request, bucket string) (*storage.ObjectLock, *S3Error) {
+	mode := r.Header.Get(headerObjectLockMode)
+	retainUntil := r.Header.Get(headerObjectLockRetainUntilDate)
+	legalHold := r.Header.Get(headerObjectLockLegalHold)
+	if mode == "" && retainUntil == "" && legalHold == "" {
+		return nil, nil
+	}
+
+	lock := &storage.ObjectLock{}
+	if mode != "" || retainUntil != "" {
+		if mode == "" || retainUntil == "" {
+			return nil, objectLockError("x-amz-object-lock-retain-until-date and x-amz-object-lock-mode must both be supplied")
+		}
+		switch storage.ObjectLockRetentionMode(mode) {
+		case storage.ObjectLockRetentionModeGovernance, storage.ObjectLockRetentionModeCompliance:
+		default:
+			return nil, objectLockError("Unknown wormMode directive.")
+		}
+		until, err := time.Parse(time.RFC3339, retainUntil)
+		if err != nil {
+			return nil, objectLockError("The retain until date must be provided in ISO 8601 format")
+		}
+		if !until.After(time.Now()) {
+			return nil, objectLockError("The retain until date must be in the future!")
+		}
+		until = until.UTC()
+		lock.Retention = &storage.ObjectRetention{Mode: storage.ObjectLockRetentionMode(mode), RetainUntilDate: &until}
+	}
+	if legalHold != "" {
+		switch storage.ObjectLegalHoldStatus(legalHold) {
+		case storage.ObjectLegalHoldStatusOn, storage.ObjectLegalHoldStatusOff:
+		default:
+			return nil, objectLockError("Legal Hold must be either of 'ON' or 'OFF'")
+		}
+		lock.LegalHold = &storage.ObjectLegalHold{Status: storage.ObjectLegalHoldStatus(legalHold)}
+	}
+
+	// Writes to missing buckets fail with NoSuchBucket later on
+	enabled, err := h.storage.GetBucketObjectLockEnabled(r.Context(), bucket)
+	if err != nil && !errors.Is(err, storage.ErrBucketNotFound) {
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket object lock")
+		return nil, ErrInternalError
+	}
+	if err == nil && !enabled {
+		s3Err := *ErrInvalidRequest
+		s3Err.Message = "Bucket is missing Object Lock Configuration"
+		return nil, &s3Err
+	}
+	return lock, nil
+}
+
+// objectLockError returns an InvalidArgument error with the given message.
+func objectLockError(message string) *S3Error {
+	s3Err := *ErrInvalidArgument
+	s3Err.Message = message
+	return &s3Err
+}
+
+// putObjectLock applies the retention and legal hold requested with a write
+// to the object written.
+func (h *Handler) putObjectLock(ctx context.Context, bucket, key string, lock *storage.ObjectLock) error {
+	if lock == nil {
+		return nil
+	}
+	if lock.Retention != nil {
+		if err := h.storage.PutObjectRetention(ctx, bucket, key, lock.Retention); err != nil {
+			return err
+		}
+	}
+	if lock.LegalHold != nil {
+		if err := h.storage.PutObjectLegalHold(ctx, bucket, key, lock.LegalHold); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setObjectLockHeaders sets the object lock headers of the current version of
+// an object in a GET or HEAD response.
+func (h *Handler) setObjectLockHeaders(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	// Only buckets with object lock enabled have locked objects
+	if enabled, err := h.storage.GetBucketObjectLockEnabled(r.Context(), bucket); err != nil || !enabled {
+		return
+	}
+	retention, err := h.storage.GetObjectRetention(r.Context(), bucket, key)
+	if err == nil {
+		w.Header().Set(headerObjectLockMode, string(retention.Mode))
+		w.Header().Set(headerObjectLockRetainUntilDate, retention.RetainUntilDate.UTC().Format(time.RFC3339))
+	} else if !errors.Is(err, storage.ErrNoSuchObjectLockConfiguration) {
+		log.Warn().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to get object retention")
+	}
+	legalHold, err := h.storage.GetObjectLegalHold(r.Context(), bucket, key)
+	if err == nil {
+		w.Header().Set(headerObjectLockLegalHold, string(legalHold.Status))
+	} else if !errors.Is(err, storage.ErrNoSuchObjectLockConfiguration) {
+		log.Warn().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to get object legal hold")
+	}
+}
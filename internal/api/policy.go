@@ -1,11 +1,11 @@
 package api
 
 import (
-	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 
+	"github.com/kumasuke/jog/internal/policy"
 	"github.com/kumasuke/jog/internal/storage"
 	"github.com/rs/zerolog/log"
 )
@@ -31,28 +31,16 @@ func (h *Handler) PutBucketPolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	policy := string(body)
-
-	// Validate JSON format
-	if !json.Valid(body) {
-		WriteError(w, ErrMalformedPolicy)
-		return
-	}
-
-	// Validate policy structure (must have at least Version and Statement)
-	var policyDoc map[string]interface{}
-	if err := json.Unmarshal(body, &policyDoc); err != nil {
-		WriteError(w, ErrMalformedPolicy)
+	// Validate the policy as it is evaluated, so that it cannot silently
+	// allow or deny less than intended
+	if _, err := policy.Parse(body); err != nil {
+		s3Err := *ErrMalformedPolicy
+		s3Err.Message = err.Error()
+		WriteError(w, &s3Err)
 		return
 	}
 
-	// Check for required fields
-	if _, hasStatement := policyDoc["Statement"]; !hasStatement {
-		WriteError(w, ErrMalformedPolicy)
-		return
-	}
-
-	err = h.storage.PutBucketPolicy(r.Context(), bucket, policy)
+	err = h.storage.PutBucketPolicy(r.Context(), bucket, string(body))
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -70,7 +58,7 @@ func (h *Handler) PutBucketPolicy(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetBucketPolicy(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
-	policy, err := h.storage.GetBucketPolicy(r.Context(), bucket)
+	doc, err := h.storage.GetBucketPolicy(r.Context(), bucket)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -87,7 +75,7 @@ func (h *Handler) GetBucketPolicy(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write([]byte(policy)); err != nil {
+	if _, err := w.Write([]byte(doc)); err != nil {
 		log.Error().Err(err).Msg("Failed to write GetBucketPolicy response")
 	}
 }
@@ -109,3 +97,45 @@ func (h *Handler) DeleteBucketPolicy(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Authorizer returns the authorizer of identity and bucket policies, to
+// which identity policies of access keys are attached.
+func (h *Handler) Authorizer() *policy.Authorizer {
+	return h.authorizer
+}
+
+// allowed reports whether the caller of a request may perform an action on a
+// bucket or object. Requests without an authenticated access key are always
+// allowed.
+func (h *Handler) allowed(r *http.Request, action, bucket, key string) (bool, error) {
+	principal := storage.OwnerFromContext(r.Context())
+	if principal == "" {
+		return true, nil
+	}
+	return h.authorizer.Authorize(r.Context(), policy.NewRequest(r, principal, action, bucket, key))
+}
+
+// authorize checks that the caller of a request may perform an action,
+// writing AccessDenied or InternalError otherwise.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, action, bucket, key string) bool {
+	allowed, err := h.allowed(r, action, bucket, key)
+	if err != nil {
+		log.Error().Err(err).Str("bucket", bucket).Str("action", action).Msg("Failed to authorize request")
+		WriteError(w, ErrInternalError)
+		return false
+	}
+	if !allowed {
+		WriteErrorWithResource(w, ErrAccessDenied, r.URL.Path)
+		return false
+	}
+	return true
+}
+
+// authorizeCopySource checks that the caller of a copy may read its source.
+func (h *Handler) authorizeCopySource(w http.ResponseWriter, r *http.Request, bucket, key, versionID string) bool {
+	action := "s3:GetObject"
+	if versionID != "" {
+		action = "s3:GetObjectVersion"
+	}
+	return h.authorize(w, r, action, bucket, key)
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// rangeSpec is a parsed single-range Range header. first is -1 for suffix
+// ranges ("bytes=-500") and last is -1 for open-ended ranges ("bytes=500-").
+type rangeSpec struct {
+	first int64
+	last  int64
+}
+
+// byteRange is an inclusive byte range of an object.
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+// length returns the number of bytes in the range.
+func (br byteRange) length() int64 {
+	return br.end - br.start + 1
+}
+
+// contentRange returns the Content-Range header value of the range of an
+// object of the given size.
+func (br byteRange) contentRange(size int64) string {
+	return "bytes " + strconv.FormatInt(br.start, 10) + "-" + strconv.FormatInt(br.end, 10) + "/" + strconv.FormatInt(size, 10)
+}
+
+// parseRangeSpec parses a single-range Range header ("bytes=0-499",
+// "bytes=500-" or "bytes=-500"). It returns false for malformed headers and
+// multiple ranges, which are ignored like S3 does, serving the whole object
+// (RFC 7233 section 3.1).
+func parseRangeSpec(header string) (rangeSpec, bool) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return rangeSpec{}, false
+	}
+	first, last, found := strings.Cut(strings.TrimPrefix(header, "bytes="), "-")
+	if !found || (first == "" && last == "") {
+		return rangeSpec{}, false
+	}
+
+	spec := rangeSpec{first: -1, last: -1}
+	var err error
+	if first != "" {
+		if spec.first, err = parseRangePos(first); err != nil {
+			return rangeSpec{}, false
+		}
+	}
+	if last != "" {
+		if spec.last, err = parseRangePos(last); err != nil {
+			return rangeSpec{}, false
+		}
+	}
+	if spec.first >= 0 && spec.last >= 0 && spec.first > spec.last {
+		return rangeSpec{}, false
+	}
+	return spec, true
+}
+
+// parseRangePos parses a byte position of a Range header, which consists of
+// digits only.
+func parseRangePos(s string) (int64, error) {
+	if strings.TrimLeft(s, "0123456789") != "" {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// resolve returns the range of an object of the given size, clamping the last
+// position and suffix lengths to the object. It returns false if the range is
+// not satisfiable: it starts at or beyond the end of the object, or is a
+// suffix range of zero length or of an empty object.
+func (spec rangeSpec) resolve(size int64) (byteRange, bool) {
+	if spec.first < 0 {
+		// Suffix range: -500 means last 500 bytes
+		if spec.last == 0 || size == 0 {
+			return byteRange{}, false
+		}
+		return byteRange{start: max(size-spec.last, 0), end: size - 1}, true
+	}
+	if spec.first >= size {
+		return byteRange{}, false
+	}
+	if spec.last < 0 || spec.last >= size {
+		return byteRange{start: spec.first, end: size - 1}, true
+	}
+	return byteRange{start: spec.first, end: spec.last}, true
+}
+
+// setAcceptRanges advertises byte range support on object responses.
+func setAcceptRanges(w http.ResponseWriter) {
+	w.Header().Set("Accept-Ranges", "bytes")
+}
+
+// invalidRangeError is the error response of an unsatisfiable range, which
+// S3 extends with the requested range and the size of the object.
+type invalidRangeError struct {
+	XMLName          xml.Name `xml:"Error"`
+	Code             string   `xml:"Code"`
+	Message          string   `xml:"Message"`
+	RangeRequested   string   `xml:"RangeRequested"`
+	ActualObjectSize int64    `xml:"ActualObjectSize"`
+	Resource         string   `xml:"Resource,omitempty"`
+	RequestID        string   `xml:"RequestId"`
+}
+
+// writeRangeNotSatisfiable writes the 416 response of an unsatisfiable range
+// of an object of the given size, with the Content-Range: bytes */size header
+// of RFC 7233 section 4.4.
+func writeRangeNotSatisfiable(w http.ResponseWriter, rangeHeader string, size int64, resource string) {
+	response := invalidRangeError{
+		Code:             ErrInvalidRange.Code,
+		Message:          ErrInvalidRange.Message,
+		RangeRequested:   rangeHeader,
+		ActualObjectSize: size,
+		Resource:         resource,
+		RequestID:        requestID(w),
+	}
+
+	w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(ErrInvalidRange.HTTPStatus)
+	if err := xml.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("Failed to encode error response")
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import "testing"
+
+func TestParseRangeSpec_Malformed(t *testing.T) {
+	for _, header := range []string{"", "bytes", "items=0-1", "bytes=-", "bytes=abc-", "bytes=5-2", "bytes=0-1,3-4", "bytes=+1-2", "bytes= 0-1"} {
+		if spec, ok := parseRangeSpec(header); ok {
+			t.Errorf("parseRangeSpec(%q) = %+v, want malformed", header, spec)
+		}
+	}
+}
+
+func TestRangeSpecResolve(t *testing.T) {
+	tests := []struct {
+		header string
+		size   int64
+		want   byteRange
+		ok     bool
+	}{
+		{"bytes=0-4", 16, byteRange{0, 4}, true},
+		{"bytes=10-", 16, byteRange{10, 15}, true},
+		{"bytes=10-100", 16, byteRange{10, 15}, true},
+		{"bytes=-4", 16, byteRange{12, 15}, true},
+		{"bytes=-100", 16, byteRange{0, 15}, true},
+		{"bytes=15-15", 16, byteRange{15, 15}, true},
+		{"bytes=16-", 16, byteRange{}, false},
+		{"bytes=20-30", 16, byteRange{}, false},
+		{"bytes=-0", 16, byteRange{}, false},
+		{"bytes=0-", 0, byteRange{}, false},
+		{"bytes=-1", 0, byteRange{}, false},
+	}
+	for _, tt := range tests {
+		spec, ok := parseRangeSpec(tt.header)
+		if !ok {
+			t.Fatalf("parseRangeSpec(%q) failed", tt.header)
+		}
+		got, ok := spec.resolve(tt.size)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("resolve(%q, %d) = %+v, %v, want %+v, %v", tt.header, tt.size, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// defaultRegion is the region of the server unless configured otherwise.
+const defaultRegion = "us-east-1"
+
+// SetRegion sets the region of the server, which buckets created without a
+// location constraint are in.
+func (h *Handler) SetRegion(region string) {
+	if region == "" {
+		region = defaultRegion
+	}
+	h.region = region
+}
+
+// bucketRegion returns the region a bucket with the given location
+// constraint is in.
+func (h *Handler) bucketRegion(constraint string) string {
+	if constraint != "" {
+		return constraint
+	}
+	return h.region
+}
+
+// signingRegion returns the region of the SigV4 credential scope of a
+// request, from its Authorization header or presigned query, or "" for
+// requests that are not signed with SigV4.
+func signingRegion(r *http.Request) string {
+	credential := r.URL.Query().Get("X-Amz-Credential")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		for _, field := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(field), "Credential="); ok {
+				credential = value
+			}
+		}
+	}
+	// <access key>/<date>/<region>/<service>/aws4_request
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 {
+		return ""
+	}
+	return parts[2]
+}
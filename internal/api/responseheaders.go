@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// ResponseHeaderConfigurationXML represents the XML format for the response header policy (JOG extension).
+type ResponseHeaderConfigurationXML struct {
+	XMLName xml.Name                `xml:"ResponseHeaderConfiguration"`
+	Xmlns   string                  `xml:"xmlns,attr,omitempty"`
+	Rules   []ResponseHeaderRuleXML `xml:"Rule"`
+}
+
+// ResponseHeaderRuleXML represents a response header rule in XML.
+type ResponseHeaderRuleXML struct {
+	Prefix  string              `xml:"Prefix"`
+	Headers []ResponseHeaderXML `xml:"Header"`
+}
+
+// ResponseHeaderXML represents a header of a response header rule in XML.
+type ResponseHeaderXML struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+// setPolicyHeaders sets the headers of the bucket's response header policy
+// matching key on an object response.
+func (h *Handler) setPolicyHeaders(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	config, err := h.storage.GetBucketResponseHeaders(r.Context(), bucket)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNoSuchResponseHeaderConfiguration) && !errors.Is(err, storage.ErrBucketNotFound) {
+			log.Warn().Err(err).Str("bucket", bucket).Msg("Failed to get bucket response headers")
+		}
+		return
+	}
+	for _, header := range config.Headers(key) {
+		w.Header().Set(header.Name, header.Value)
+	}
+}
+
+// PutBucketResponseHeaders handles PUT /{bucket}?response-headers - PutBucketResponseHeaders.
+func (h *Handler) PutBucketResponseHeaders(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	var xmlConfig ResponseHeaderConfigurationXML
+	if s3Err := decodeXMLBody(r, &xmlConfig, maxXMLBodySize); s3Err != nil {
+		WriteError(w, s3Err)
+		return
+	}
+	if len(xmlConfig.Rules) == 0 {
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+		return
+	}
+
+	config := &storage.ResponseHeaderConfiguration{}
+	for _, xmlRule := range xmlConfig.Rules {
+		if len(xmlRule.Headers) == 0 {
+			WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+			return
+		}
+		rule := storage.ResponseHeaderRule{Prefix: xmlRule.Prefix}
+		for _, xmlHeader := range xmlRule.Headers {
+			header, err := storage.NewResponseHeader(xmlHeader.Name, xmlHeader.Value)
+			if err != nil {
+				s3Err := *ErrInvalidArgument
+				s3Err.Message = err.Error()
+				WriteErrorWithResource(w, &s3Err, "/"+bucket)
+				return
+			}
+			rule.Headers = append(rule.Headers, header)
+		}
+		config.Rules = append(config.Rules, rule)
+	}
+
+	err := h.storage.PutBucketResponseHeaders(r.Context(), bucket, config)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to put bucket response headers")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketResponseHeaders handles GET /{bucket}?response-headers - GetBucketResponseHeaders.
+func (h *Handler) GetBucketResponseHeaders(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	config, err := h.storage.GetBucketResponseHeaders(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNoSuchResponseHeaderConfiguration) {
+			WriteErrorWithResource(w, ErrNoSuchResponseHeaderConfiguration, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket response headers")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	xmlConfig := ResponseHeaderConfigurationXML{
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+	}
+	for _, rule := range config.Rules {
+		xmlRule := ResponseHeaderRuleXML{Prefix: rule.Prefix}
+		for _, header := range rule.Headers {
+			xmlRule.Headers = append(xmlRule.Headers, ResponseHeaderXML{
+				Name:  header.Name,
+				Value: header.Value,
+			})
+		}
+		xmlConfig.Rules = append(xmlConfig.Rules, xmlRule)
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(xmlConfig); err != nil {
+		log.Error().Err(err).Msg("Failed to encode GetBucketResponseHeaders response")
+	}
+}
+
+// DeleteBucketResponseHeaders handles DELETE /{bucket}?response-headers - DeleteBucketResponseHeaders.
+func (h *Handler) DeleteBucketResponseHeaders(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	err := h.storage.DeleteBucketResponseHeaders(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to delete bucket response headers")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
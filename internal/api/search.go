@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// SearchResult is the response of SearchObjects (JOG extension).
+type SearchResult struct {
+	XMLName               xml.Name           `xml:"SearchResult"`
+	Xmlns                 string             `xml:"xmlns,attr"`
+	Name                  string             `xml:"Name"`
+	Prefix                string             `xml:"Prefix"`
+	ContentType           string             `xml:"ContentType,omitempty"`
+	MaxKeys               int32              `xml:"MaxKeys"`
+	KeyCount              int32              `xml:"KeyCount"`
+	IsTruncated           bool               `xml:"IsTruncated"`
+	ContinuationToken     string             `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string             `xml:"NextContinuationToken,omitempty"`
+	Contents              []SearchObjectInfo `xml:"Contents"`
+}
+
+// SearchObjectInfo is an object found by SearchObjects, with its user metadata.
+type SearchObjectInfo struct {
+	Key          string          `xml:"Key"`
+	LastModified string          `xml:"LastModified"`
+	ETag         string          `xml:"ETag"`
+	Size         int64           `xml:"Size"`
+	ContentType  string          `xml:"ContentType"`
+	Metadata     []MetadataEntry `xml:"Metadata"`
+}
+
+// MetadataEntry is a user metadata value, named without the x-amz-meta- prefix.
+type MetadataEntry struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+// SearchObjects handles GET /{bucket}?search - SearchObjects (JOG extension).
+// Objects are matched by x-amz-meta-{name}={value} parameters, which must all
+// match, and by a content-type parameter such as "application/zip" or
+// "image/*". Results are paged like ListObjectsV2.
+func (h *Handler) SearchObjects(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+	query := r.URL.Query()
+
+	input := &storage.SearchObjectsInput{
+		Bucket:      bucket,
+		Prefix:      query.Get("prefix"),
+		Metadata:    make(map[string]string),
+		ContentType: query.Get("content-type"),
+	}
+	for name := range query {
+		if metaKey, ok := strings.CutPrefix(strings.ToLower(name), "x-amz-meta-"); ok && metaKey != "" {
+			input.Metadata[metaKey] = query.Get(name)
+		}
+	}
+	if len(input.Metadata) == 0 && input.ContentType == "" {
+		s3Err := *ErrInvalidArgument
+		s3Err.Message = "A search requires an x-amz-meta-* or content-type parameter"
+		WriteErrorWithResource(w, &s3Err, "/"+bucket)
+		return
+	}
+
+	maxKeys, s3Err := parseListLimit(query, "max-keys")
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
+	}
+	continuationToken := query.Get("continuation-token")
+	startAfter, s3Err := decodeContinuationToken(continuationToken)
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
+	}
+	input.MaxKeys = maxKeys
+	input.StartAfter = startAfter
+
+	output := &storage.SearchObjectsOutput{}
+	var err error
+	if maxKeys > 0 {
+		output, err = h.storage.SearchObjects(r.Context(), input)
+	} else {
+		err = h.checkListBucket(r.Context(), bucket)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to search objects")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	result := SearchResult{
+		Xmlns:                 "http://s3.amazonaws.com/doc/2006-03-01/",
+		Name:                  bucket,
+		Prefix:                input.Prefix,
+		ContentType:           input.ContentType,
+		MaxKeys:               maxKeys,
+		KeyCount:              int32(len(output.Objects)),
+		IsTruncated:           output.IsTruncated,
+		ContinuationToken:     continuationToken,
+		NextContinuationToken: EncodeContinuationToken(output.NextStartAfter),
+		Contents:              make([]SearchObjectInfo, len(output.Objects)),
+	}
+	for i, obj := range output.Objects {
+		info := SearchObjectInfo{
+			Key:          obj.Key,
+			LastModified: obj.LastModified.Format(time.RFC3339),
+			ETag:         "\"" + obj.ETag + "\"",
+			Size:         obj.Size,
+			ContentType:  obj.ContentType,
+		}
+		for _, name := range slices.Sorted(maps.Keys(obj.Metadata)) {
+			info.Metadata = append(info.Metadata, MetadataEntry{Name: name, Value: obj.Metadata[name]})
+		}
+		result.Contents[i] = info
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(result); err != nil {
+		log.Error().Err(err).Msg("Failed to encode SearchObjects response")
+	}
+}
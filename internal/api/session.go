@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// SessionDuration is how long credentials returned by CreateSession are valid.
+const SessionDuration = 5 * time.Minute
+
+// directoryBucketNameRegex matches S3 Express One Zone directory bucket names,
+// base-name--zone-id--x-s3, e.g. "logs--usw2-az1--x-s3".
+var directoryBucketNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*[a-z0-9]--[a-z0-9]+(-[a-z0-9]+)*-az[0-9]+--x-s3$`)
+
+// IsDirectoryBucket reports whether a bucket name follows the directory bucket
+// naming convention. Directory buckets are served like general purpose buckets,
+// with the session authentication and listing rules of S3 Express One Zone.
+func IsDirectoryBucket(name string) bool {
+	return strings.HasSuffix(name, "--x-s3")
+}
+
+// ValidateDirectoryBucketName validates a directory bucket name.
+func ValidateDirectoryBucketName(name string) bool {
+	return len(name) >= 3 && len(name) <= 63 && directoryBucketNameRegex.MatchString(name)
+}
+
+// Session modes of CreateSession.
+const (
+	SessionModeReadWrite = "ReadWrite"
+	SessionModeReadOnly  = "ReadOnly"
+)
+
+// Session is a temporary credential for one directory bucket.
+type Session struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Token           string
+	Bucket          string
+	Mode            string
+	Expiration      time.Time
+	// Owner and Tenant are the identity of the caller that created the session.
+	Owner  string
+	Tenant string
+}
+
+// SessionStore holds the sessions created by CreateSession.
+type SessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewSessionStore creates an empty session store.
+func NewSessionStore() *SessionStore {
+	return &SessionStore{sessions: make(map[string]*Session)}
+}
+
+// Create creates a session for a bucket, valid for SessionDuration.
+func (s *SessionStore) Create(bucket, mode, owner, tenant string) *Session {
+	session := &Session{
+		AccessKeyID:     "JOGS" + strings.ToUpper(randomHex(16)),
+		SecretAccessKey: randomHex(40),
+		Token:           randomHex(64),
+		Bucket:          bucket,
+		Mode:            mode,
+		Expiration:      time.Now().Add(SessionDuration).UTC().Truncate(time.Second),
+		Owner:           owner,
+		Tenant:          tenant,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Drop expired sessions so the store does not grow without bound
+	now := time.Now()
+	for key, existing := range s.sessions {
+		if now.After(existing.Expiration) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[session.AccessKeyID] = session
+	return session
+}
+
+// Get returns the session with the given access key ID, or nil if there is none.
+// Expired sessions are returned until they are dropped; callers check Expiration.
+func (s *SessionStore) Get(accessKeyID string) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[accessKeyID]
+}
+
+// CreateSessionResult is the response for CreateSession.
+type CreateSessionResult struct {
+	XMLName     xml.Name           `xml:"CreateSessionResult"`
+	Xmlns       string             `xml:"xmlns,attr"`
+	Credentials SessionCredentials `xml:"Credentials"`
+}
+
+// SessionCredentials is the temporary credential of a session.
+type SessionCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+	Expiration      string `xml:"Expiration"`
+}
+
+// CreateSession handles GET /{bucket}?session - CreateSession.
+func (h *Handler) CreateSession(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	if !IsDirectoryBucket(bucket) {
+		s3Err := *ErrInvalidRequest
+		s3Err.Message = "CreateSession is only supported for directory buckets."
+		WriteErrorWithResource(w, &s3Err, "/"+bucket)
+		return
+	}
+
+	mode := r.Header.Get("x-amz-create-session-mode")
+	if mode == "" {
+		mode = SessionModeReadWrite
+	}
+	if mode != SessionModeReadWrite && mode != SessionModeReadOnly {
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+		return
+	}
+
+	// The bucket need not exist yet: with a custom endpoint the AWS SDKs sign
+	// CreateBucket with session credentials instead of using the S3 Express
+	// control endpoint.
+	session := h.sessions.Create(bucket, mode, storage.OwnerFromContext(r.Context()), storage.TenantFromContext(r.Context()))
+
+	result := CreateSessionResult{
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+		Credentials: SessionCredentials{
+			AccessKeyID:     session.AccessKeyID,
+			SecretAccessKey: session.SecretAccessKey,
+			SessionToken:    session.Token,
+			Expiration:      session.Expiration.Format(time.RFC3339),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(result); err != nil {
+		log.Error().Err(err).Msg("Failed to encode CreateSession response")
+	}
+}
+
+// validateDirectoryListing checks the ListObjectsV2 parameters supported by
+// directory buckets: "/" is the only delimiter, and prefixes used with it must
+// name a directory, i.e. end in "/".
+func validateDirectoryListing(prefix, delimiter string) *S3Error {
+	if delimiter != "" && delimiter != "/" {
+		s3Err := *ErrInvalidArgument
+		s3Err.Message = "For directory buckets, / is the only supported delimiter."
+		return &s3Err
+	}
+	if delimiter != "" && prefix != "" && !strings.HasSuffix(prefix, "/") {
+		s3Err := *ErrInvalidArgument
+		s3Err.Message = "For directory buckets, only prefixes that end in a delimiter (/) are supported."
+		return &s3Err
+	}
+	return nil
+}
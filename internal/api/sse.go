@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/kms"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// Server-side encryption headers of object writes and responses.
+const (
+	headerSSE         = "x-amz-server-side-encryption"
+	headerSSEKMSKeyID = "x-amz-server-side-encryption-aws-kms-key-id"
+	headerSSEContext  = "x-amz-server-side-encryption-context"
+)
+
+// withServerSideEncryption parses the server-side encryption headers of an
+// object write and returns r with the requested encryption in its context.
+func withServerSideEncryption(r *http.Request) (*http.Request, *S3Error) {
+	algorithm := storage.SSEAlgorithm(r.Header.Get(headerSSE))
+	keyID := r.Header.Get(headerSSEKMSKeyID)
+	encCtx := r.Header.Get(headerSSEContext)
+
+	switch algorithm {
+	case "":
+		if keyID != "" || encCtx != "" {
+			return nil, sseError("Server Side Encryption with KMS managed key requires HTTP header x-amz-server-side-encryption : aws:kms")
+		}
+		return r, nil
+	case storage.SSEAlgorithmAES256:
+		if keyID != "" || encCtx != "" {
+			return nil, sseError("Server Side Encryption with AWS KMS managed key requires HTTP header x-amz-server-side-encryption : aws:kms")
+		}
+	case storage.SSEAlgorithmKMS, storage.SSEAlgorithmKMSDSSE:
+	default:
+		return nil, sseError("The encryption method specified is not supported")
+	}
+
+	sse := &storage.ServerSideEncryption{Algorithm: algorithm, KMSKeyID: keyID}
+	if encCtx != "" {
+		// The context is base64-encoded JSON with string values
+		data, err := base64.StdEncoding.DecodeString(encCtx)
+		if err != nil || json.Unmarshal(data, &sse.Context) != nil {
+			return nil, sseError("Invalid encryption context")
+		}
+	}
+	return r.WithContext(storage.WithServerSideEncryption(r.Context(), sse)), nil
+}
+
+// sseError returns an InvalidArgument error with the given message.
+func sseError(message string) *S3Error {
+	s3Err := *ErrInvalidArgument
+	s3Err.Message = message
+	return &s3Err
+}
+
+// writeKMSError writes the response to an object request that failed to
+// wrap or unwrap a data key, and reports whether err was such a failure.
+func writeKMSError(w http.ResponseWriter, err error, resource string) bool {
+	switch {
+	case errors.Is(err, storage.ErrKMSNotConfigured):
+		WriteErrorWithResource(w, sseError("Server-side encryption with KMS keys is not configured on this server"), resource)
+	case errors.Is(err, kms.ErrKeyNotFound):
+		WriteErrorWithResource(w, ErrKMSNotFound, resource)
+	case errors.Is(err, kms.ErrInvalidCiphertext):
+		log.Error().Err(err).Str("resource", resource).Msg("Failed to unwrap object data key")
+		WriteErrorWithResource(w, ErrKMSInvalidCiphertext, resource)
+	default:
+		return false
+	}
+	return true
+}
+
+// setEncryptionHeaders sets the server-side encryption headers of an object
+// or version encrypted at rest.
+func (h *Handler) setEncryptionHeaders(w http.ResponseWriter, r *http.Request, bucket, key, versionID string) {
+	enc, err := h.storage.GetObjectEncryption(r.Context(), bucket, key, versionID)
+	if err != nil {
+		log.Warn().Err(err).Str("bucket", bucket).Str("key", key).Msg("Failed to get object encryption")
+		return
+	}
+	if enc == nil {
+		return
+	}
+	w.Header().Set(headerSSE, string(enc.Algorithm))
+	if enc.Algorithm != storage.SSEAlgorithmAES256 {
+		w.Header().Set(headerSSEKMSKeyID, enc.KMSKeyID)
+	}
+}
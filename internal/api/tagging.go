@@ -4,7 +4,6 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -59,15 +58,9 @@ func (h *Handler) PutObjectTagging(w http.ResponseWriter, r *http.Request) {
 	key := GetKey(r)
 
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket+"/"+key)
-		return
-	}
-
 	var tagging Tagging
-	if err := xml.Unmarshal(body, &tagging); err != nil {
-		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket+"/"+key)
+	if s3Err := decodeXMLBody(r, &tagging, maxXMLBodySize); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket+"/"+key)
 		return
 	}
 
@@ -84,7 +77,7 @@ func (h *Handler) PutObjectTagging(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Store tags
-	err = h.storage.PutObjectTagging(r.Context(), bucket, key, tags)
+	err := h.storage.PutObjectTagging(r.Context(), bucket, key, tags)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -165,15 +158,9 @@ func (h *Handler) PutBucketTagging(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket)
-		return
-	}
-
 	var tagging Tagging
-	if err := xml.Unmarshal(body, &tagging); err != nil {
-		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket)
+	if s3Err := decodeXMLBody(r, &tagging, maxXMLBodySize); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
 		return
 	}
 
@@ -190,7 +177,7 @@ func (h *Handler) PutBucketTagging(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Store tags
-	err = h.storage.PutBucketTagging(r.Context(), bucket, tags)
+	err := h.storage.PutBucketTagging(r.Context(), bucket, tags)
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// TieringConfigurationXML represents the XML format for the tiering configuration (JOG extension).
+type TieringConfigurationXML struct {
+	XMLName       xml.Name `xml:"TieringConfiguration"`
+	Xmlns         string   `xml:"xmlns,attr,omitempty"`
+	SizeThreshold int64    `xml:"SizeThreshold"`
+	ColdAfterDays int32    `xml:"ColdAfterDays"`
+}
+
+// PutBucketTiering handles PUT /{bucket}?tiering - PutBucketTiering.
+func (h *Handler) PutBucketTiering(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	var xmlConfig TieringConfigurationXML
+	if s3Err := decodeXMLBody(r, &xmlConfig, maxXMLBodySize); s3Err != nil {
+		WriteError(w, s3Err)
+		return
+	}
+
+	if xmlConfig.SizeThreshold < 0 || xmlConfig.ColdAfterDays < 0 {
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+		return
+	}
+
+	config := &storage.TieringConfiguration{
+		SizeThreshold: xmlConfig.SizeThreshold,
+		ColdAfterDays: xmlConfig.ColdAfterDays,
+	}
+
+	err := h.storage.PutBucketTiering(r.Context(), bucket, config)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to put bucket tiering")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketTiering handles GET /{bucket}?tiering - GetBucketTiering.
+func (h *Handler) GetBucketTiering(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	config, err := h.storage.GetBucketTiering(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNoSuchTieringConfiguration) {
+			WriteErrorWithResource(w, ErrNoSuchTieringConfiguration, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket tiering")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	xmlConfig := TieringConfigurationXML{
+		Xmlns:         "http://s3.amazonaws.com/doc/2006-03-01/",
+		SizeThreshold: config.SizeThreshold,
+		ColdAfterDays: config.ColdAfterDays,
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(xmlConfig); err != nil {
+		log.Error().Err(err).Msg("Failed to encode GetBucketTiering response")
+	}
+}
+
+// DeleteBucketTiering handles DELETE /{bucket}?tiering - DeleteBucketTiering.
+func (h *Handler) DeleteBucketTiering(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	err := h.storage.DeleteBucketTiering(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to delete bucket tiering")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
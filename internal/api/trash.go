@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// TrashConfigurationXML represents the XML format for the trash configuration (JOG extension).
+type TrashConfigurationXML struct {
+	XMLName xml.Name `xml:"TrashConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Status  string   `xml:"Status"`
+	Days    int      `xml:"Days"`
+}
+
+// PutBucketTrash handles PUT /{bucket}?trash - PutBucketTrash.
+func (h *Handler) PutBucketTrash(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	var xmlConfig TrashConfigurationXML
+	if s3Err := decodeXMLBody(r, &xmlConfig, maxXMLBodySize); s3Err != nil {
+		WriteError(w, s3Err)
+		return
+	}
+
+	var err error
+	switch xmlConfig.Status {
+	case "Enabled":
+		if xmlConfig.Days <= 0 {
+			WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+			return
+		}
+		err = h.storage.PutBucketTrash(r.Context(), bucket, &storage.TrashConfiguration{Days: xmlConfig.Days})
+	case "Disabled":
+		err = h.storage.DeleteBucketTrash(r.Context(), bucket)
+	default:
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrTrashVersionedBucket) {
+			WriteErrorWithResource(w, ErrInvalidBucketState, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to put bucket trash")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketTrash handles GET /{bucket}?trash - GetBucketTrash.
+func (h *Handler) GetBucketTrash(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	config, err := h.storage.GetBucketTrash(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNoSuchTrashConfiguration) {
+			WriteErrorWithResource(w, ErrNoSuchTrashConfiguration, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket trash")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	xmlConfig := TrashConfigurationXML{
+		Xmlns:  "http://s3.amazonaws.com/doc/2006-03-01/",
+		Status: "Enabled",
+		Days:   config.Days,
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(xmlConfig); err != nil {
+		log.Error().Err(err).Msg("Failed to encode GetBucketTrash response")
+	}
+}
+
+// DeleteBucketTrash handles DELETE /{bucket}?trash - DeleteBucketTrash.
+// Objects already in the trash are kept until their retention ends.
+func (h *Handler) DeleteBucketTrash(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	err := h.storage.DeleteBucketTrash(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to delete bucket trash")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
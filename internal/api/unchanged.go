@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// SkipUnchangedConfigurationXML represents the XML format for skipping unchanged overwrites (JOG extension).
+type SkipUnchangedConfigurationXML struct {
+	XMLName xml.Name `xml:"SkipUnchangedConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Status  string   `xml:"Status"`
+}
+
+// PutBucketSkipUnchanged handles PUT /{bucket}?skip-unchanged - PutBucketSkipUnchanged.
+func (h *Handler) PutBucketSkipUnchanged(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	var xmlConfig SkipUnchangedConfigurationXML
+	if s3Err := decodeXMLBody(r, &xmlConfig, maxXMLBodySize); s3Err != nil {
+		WriteError(w, s3Err)
+		return
+	}
+	if xmlConfig.Status != "Enabled" && xmlConfig.Status != "Disabled" {
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+		return
+	}
+
+	err := h.storage.PutBucketSkipUnchanged(r.Context(), bucket, xmlConfig.Status == "Enabled")
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to put bucket skip-unchanged setting")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketSkipUnchanged handles GET /{bucket}?skip-unchanged - GetBucketSkipUnchanged.
+func (h *Handler) GetBucketSkipUnchanged(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	enabled, err := h.storage.GetBucketSkipUnchanged(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket skip-unchanged setting")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	xmlConfig := SkipUnchangedConfigurationXML{
+		Xmlns:  "http://s3.amazonaws.com/doc/2006-03-01/",
+		Status: "Disabled",
+	}
+	if enabled {
+		xmlConfig.Status = "Enabled"
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(xmlConfig); err != nil {
+		log.Error().Err(err).Msg("Failed to encode GetBucketSkipUnchanged response")
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// maxUserMetadataSize is the S3 limit of the user-defined metadata of an
+// object: the sum of the UTF-8 bytes of the names, without the x-amz-meta-
+// prefix, and values.
+const maxUserMetadataSize = 2 << 10
+
+// parseUserMetadata returns the x-amz-meta-* headers of a request, named
+// without the prefix. It fails with MetadataTooLarge if they exceed the S3
+// limit.
+func parseUserMetadata(r *http.Request) (map[string]string, *S3Error) {
+	metadata := make(map[string]string)
+	size := 0
+	for name, values := range r.Header {
+		metaKey, ok := strings.CutPrefix(strings.ToLower(name), "x-amz-meta-")
+		if !ok {
+			continue
+		}
+		metadata[metaKey] = values[0]
+		size += len(metaKey) + len(values[0])
+	}
+	if size > maxUserMetadataSize {
+		return nil, ErrMetadataTooLarge
+	}
+	return metadata, nil
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseUserMetadata(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/bucket/key", nil)
+	r.Header.Set("X-Amz-Meta-Owner", "alice")
+	r.Header.Set("X-Amz-Meta-Build-Id", "1234")
+	r.Header.Set("Content-Type", "text/plain")
+	metadata, s3Err := parseUserMetadata(r)
+	if s3Err != nil {
+		t.Fatalf("parseUserMetadata() = %s", s3Err.Code)
+	}
+	if len(metadata) != 2 || metadata["owner"] != "alice" || metadata["build-id"] != "1234" {
+		t.Errorf("parseUserMetadata() = %v", metadata)
+	}
+
+	// Names without the prefix and values count towards the limit
+	r = httptest.NewRequest("PUT", "/bucket/key", nil)
+	r.Header.Set("X-Amz-Meta-A", strings.Repeat("x", maxUserMetadataSize-1))
+	if _, s3Err := parseUserMetadata(r); s3Err != nil {
+		t.Errorf("parseUserMetadata() of %d bytes = %s, want success", maxUserMetadataSize, s3Err.Code)
+	}
+	r.Header.Set("X-Amz-Meta-B", "")
+	if _, s3Err := parseUserMetadata(r); s3Err != ErrMetadataTooLarge {
+		t.Errorf("parseUserMetadata() of %d bytes = %v, want MetadataTooLarge", maxUserMetadataSize+1, s3Err)
+	}
+}
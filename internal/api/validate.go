@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// uploadHeadSize is the number of leading bytes of an upload passed to
+// validators in Upload.Head, enough for content sniffing.
+const uploadHeadSize = 512
+
+// Upload is an object being uploaded by PutObject, or data being appended
+// by AppendObject, passed to upload validators before it is stored.
+type Upload struct {
+	Bucket      string
+	Key         string
+	ContentType string
+	Size        int64
+	// Append is set for AppendObject, which stores the data at Position of
+	// the object, creating it if Position is 0.
+	Append   bool
+	Position int64
+	// Metadata holds the x-amz-meta-* headers, without the prefix.
+	Metadata map[string]string
+	// Head holds the first bytes of the data, e.g. to check magic numbers.
+	Head []byte
+
+	file *os.File
+}
+
+// Open returns a reader of the whole data of the upload. Each call reads the
+// data from the beginning.
+func (u *Upload) Open() io.Reader {
+	return io.NewSectionReader(u.file, 0, u.Size)
+}
+
+// UploadValidator inspects uploads before they are stored, e.g. to enforce
+// size limits, allowed content types or antivirus scans.
+type UploadValidator interface {
+	// ValidateUpload returns a non-nil error to reject the upload. An
+	// *S3Error is returned to the client as is; any other error is reported
+	// as AccessDenied with the error text as the message.
+	ValidateUpload(ctx context.Context, upload *Upload) error
+}
+
+// UploadValidatorFunc adapts a function to the UploadValidator interface.
+type UploadValidatorFunc func(ctx context.Context, upload *Upload) error
+
+// ValidateUpload calls f(ctx, upload).
+func (f UploadValidatorFunc) ValidateUpload(ctx context.Context, upload *Upload) error {
+	return f(ctx, upload)
+}
+
+// AddUploadValidator adds a validator that can reject PutObject and
+// AppendObject requests.
+// Validators run in the order they were added, after the request has been
+// authenticated and before the object is stored. With validators, uploads
+// are spooled to a temporary file in spoolDir first, or in the system
+// temporary directory if it is empty.
+func (h *Handler) AddUploadValidator(validator UploadValidator) {
+	h.validators = append(h.validators, validator)
+}
+
+// SetUploadSpoolDir sets the directory uploads are spooled to while they are
+// validated.
+func (h *Handler) SetUploadSpoolDir(dir string) {
+	h.spoolDir = dir
+}
+
+// validateUpload spools an upload to a temporary file and runs the upload
+// validators on it. On success it returns the spooled upload, whose Open
+// method reads the data to store; the caller must call closeUpload.
+func (h *Handler) validateUpload(ctx context.Context, upload *Upload, body io.Reader) (*Upload, error) {
+	file, err := os.CreateTemp(h.spoolDir, "jog-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	upload.file = file
+
+	n, err := io.Copy(file, body)
+	if err != nil {
+		closeUpload(upload)
+		return nil, err
+	}
+	if n != upload.Size {
+		closeUpload(upload)
+		return nil, io.ErrUnexpectedEOF
+	}
+	head := make([]byte, min(n, uploadHeadSize))
+	if _, err := file.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) {
+		closeUpload(upload)
+		return nil, err
+	}
+	upload.Head = head
+
+	for _, validator := range h.validators {
+		if err := validator.ValidateUpload(ctx, upload); err != nil {
+			closeUpload(upload)
+			return nil, &uploadRejectedError{err: err}
+		}
+	}
+	return upload, nil
+}
+
+// validatedBody runs the upload validators, if any, on the data of a request
+// read from body. It returns the reader of the data to store and a function
+// that must be called once it was stored. If the upload is rejected, the
+// error is written and ok is false.
+func (h *Handler) validatedBody(w http.ResponseWriter, r *http.Request, upload *Upload, body io.Reader) (io.Reader, func(), bool) {
+	if len(h.validators) == 0 {
+		return body, func() {}, true
+	}
+	resource := "/" + upload.Bucket + "/" + upload.Key
+	spooled, err := h.validateUpload(r.Context(), upload, body)
+	if err != nil {
+		var rejected *uploadRejectedError
+		switch {
+		case errors.As(err, &rejected):
+			WriteErrorWithResource(w, rejected.s3Error(), resource)
+		case isRequestTimeout(err):
+			WriteErrorWithResource(w, ErrRequestTimeout, resource)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			WriteErrorWithResource(w, ErrIncompleteBody, resource)
+		default:
+			log.Error().Err(err).Str("bucket", upload.Bucket).Str("key", upload.Key).Msg("Failed to validate upload")
+			WriteError(w, ErrInternalError)
+		}
+		return nil, nil, false
+	}
+	return spooled.Open(), func() { closeUpload(spooled) }, true
+}
+
+// closeUpload removes the spool file of an upload.
+func closeUpload(upload *Upload) {
+	upload.file.Close()
+	os.Remove(upload.file.Name())
+}
+
+// uploadRejectedError is an error of an upload validator.
+type uploadRejectedError struct {
+	err error
+}
+
+func (e *uploadRejectedError) Error() string {
+	return "upload rejected: " + e.err.Error()
+}
+
+func (e *uploadRejectedError) Unwrap() error {
+	return e.err
+}
+
+// s3Error returns the error reported to the client for a rejected upload.
+func (e *uploadRejectedError) s3Error() *S3Error {
+	var s3Err *S3Error
+	if errors.As(e.err, &s3Err) {
+		return s3Err
+	}
+	denied := *ErrAccessDenied
+	denied.Message = e.err.Error()
+	return &denied
+}
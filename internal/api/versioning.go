@@ -3,9 +3,7 @@ package api
 import (
 	"encoding/xml"
 	"errors"
-	"io"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/kumasuke/jog/internal/storage"
@@ -14,9 +12,10 @@ import (
 
 // VersioningConfiguration represents the XML structure for bucket versioning.
 type VersioningConfiguration struct {
-	XMLName xml.Name `xml:"VersioningConfiguration"`
-	Xmlns   string   `xml:"xmlns,attr,omitempty"`
-	Status  string   `xml:"Status,omitempty"`
+	XMLName   xml.Name `xml:"VersioningConfiguration"`
+	Xmlns     string   `xml:"xmlns,attr,omitempty"`
+	Status    string   `xml:"Status,omitempty"`
+	MfaDelete string   `xml:"MfaDelete,omitempty"`
 }
 
 // ListVersionsResult represents the response for ListObjectVersions.
@@ -60,15 +59,9 @@ func (h *Handler) PutBucketVersioning(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteErrorWithResource(w, ErrInvalidRequest, "/"+bucket)
-		return
-	}
-
 	var versioningConfig VersioningConfiguration
-	if err := xml.Unmarshal(body, &versioningConfig); err != nil {
-		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket)
+	if s3Err := decodeXMLBody(r, &versioningConfig, maxXMLBodySize); s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
 		return
 	}
 
@@ -78,13 +71,34 @@ func (h *Handler) PutBucketVersioning(w http.ResponseWriter, r *http.Request) {
 		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket)
 		return
 	}
+	mfaDelete := storage.MfaDeleteStatus(versioningConfig.MfaDelete)
+	if mfaDelete != "" && mfaDelete != storage.MfaDeleteStatusEnabled && mfaDelete != storage.MfaDeleteStatusDisabled {
+		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket)
+		return
+	}
 
-	err = h.storage.PutBucketVersioning(r.Context(), bucket, status)
+	// Setting MFA delete, and any change to a bucket with MFA delete
+	// enabled, needs MFA authentication
+	if mfaDelete != "" || h.mfaDeleteEnabled(r, bucket) {
+		if s3Err := h.checkMFA(r); s3Err != nil {
+			WriteErrorWithResource(w, s3Err, "/"+bucket)
+			return
+		}
+	}
+
+	err := h.storage.PutBucketVersioning(r.Context(), bucket, status)
+	if err == nil && mfaDelete != "" {
+		err = h.storage.PutBucketMfaDelete(r.Context(), bucket, mfaDelete)
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
 			return
 		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
 		WriteErrorWithResource(w, ErrInternalError, "/"+bucket)
 		return
 	}
@@ -96,19 +110,20 @@ func (h *Handler) PutBucketVersioning(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetBucketVersioning(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
-	status, err := h.storage.GetBucketVersioning(r.Context(), bucket)
+	config, err := h.storage.GetBucketVersioningConfiguration(r.Context(), bucket)
 	if err != nil {
-		if errors.Is(err, storage.ErrBucketNotFound) {
-			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
-			return
-		}
-		WriteErrorWithResource(w, ErrInternalError, "/"+bucket)
+		writeStorageError(w, r, err)
 		return
 	}
 
+	// Buckets whose versioning was never configured have neither a Status
+	// nor an MfaDelete element
 	response := VersioningConfiguration{
-		Xmlns:  "http://s3.amazonaws.com/doc/2006-03-01/",
-		Status: string(status),
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+	}
+	if config.Status != storage.VersioningStatusUnversioned {
+		response.Status = string(config.Status)
+		response.MfaDelete = string(config.MfaDelete)
 	}
 
 	w.Header().Set("Content-Type", "application/xml")
@@ -128,13 +143,11 @@ func (h *Handler) ListObjectVersions(w http.ResponseWriter, r *http.Request) {
 	delimiter := query.Get("delimiter")
 	keyMarker := query.Get("key-marker")
 	versionIdMarker := query.Get("version-id-marker")
-	maxKeysStr := query.Get("max-keys")
 
-	maxKeys := int32(1000)
-	if maxKeysStr != "" {
-		if mk, err := strconv.ParseInt(maxKeysStr, 10, 32); err == nil {
-			maxKeys = int32(mk)
-		}
+	maxKeys, s3Err := parseListLimit(query, "max-keys")
+	if s3Err != nil {
+		WriteErrorWithResource(w, s3Err, "/"+bucket)
+		return
 	}
 
 	input := &storage.ListObjectVersionsInput{
@@ -146,7 +159,13 @@ func (h *Handler) ListObjectVersions(w http.ResponseWriter, r *http.Request) {
 		VersionIdMarker: versionIdMarker,
 	}
 
-	output, err := h.storage.ListObjectVersions(r.Context(), input)
+	output := &storage.ListObjectVersionsOutput{}
+	var err error
+	if maxKeys > 0 {
+		output, err = h.storage.ListObjectVersions(r.Context(), input)
+	} else {
+		err = h.checkListBucket(r.Context(), bucket)
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrBucketNotFound) {
 			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
@@ -202,3 +221,16 @@ func (h *Handler) ListObjectVersions(w http.ResponseWriter, r *http.Request) {
 		log.Error().Err(err).Msg("Failed to encode ListObjectVersions response")
 	}
 }
+
+// setDeleteMarkerHeaders sets the headers of the error response to a request
+// of a key whose latest version is a delete marker, or of a delete marker by
+// its version. Like S3, the latter also get the time of the marker and the
+// methods allowed on it.
+func setDeleteMarkerHeaders(w http.ResponseWriter, r *http.Request, marker *storage.DeleteMarkerError) {
+	w.Header().Set("x-amz-delete-marker", "true")
+	w.Header().Set("x-amz-version-id", marker.VersionID)
+	if r.URL.Query().Get("versionId") != "" {
+		w.Header().Set("Last-Modified", marker.LastModified.Format(http.TimeFormat))
+		w.Header().Set("Allow", "DELETE")
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// VersionRetentionConfigurationXML represents the XML format for the version retention of a bucket (JOG extension).
+type VersionRetentionConfigurationXML struct {
+	XMLName               xml.Name `xml:"VersionRetentionConfiguration"`
+	Xmlns                 string   `xml:"xmlns,attr,omitempty"`
+	MaxNoncurrentVersions *int     `xml:"MaxNoncurrentVersions"`
+}
+
+// PutBucketVersionRetention handles PUT /{bucket}?version-retention - PutBucketVersionRetention.
+func (h *Handler) PutBucketVersionRetention(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	var xmlConfig VersionRetentionConfigurationXML
+	if s3Err := decodeXMLBody(r, &xmlConfig, maxXMLBodySize); s3Err != nil {
+		WriteError(w, s3Err)
+		return
+	}
+	if xmlConfig.MaxNoncurrentVersions == nil {
+		WriteErrorWithResource(w, ErrMalformedXML, "/"+bucket)
+		return
+	}
+
+	config := &storage.VersionRetentionConfiguration{MaxNoncurrentVersions: *xmlConfig.MaxNoncurrentVersions}
+	err := h.storage.PutBucketVersionRetention(r.Context(), bucket, config)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrInvalidVersionRetention) {
+			WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to put bucket version retention")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketVersionRetention handles GET /{bucket}?version-retention - GetBucketVersionRetention.
+func (h *Handler) GetBucketVersionRetention(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	config, err := h.storage.GetBucketVersionRetention(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNoSuchVersionRetentionConfiguration) {
+			WriteErrorWithResource(w, ErrNoSuchVersionRetentionConfiguration, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket version retention")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	xmlConfig := VersionRetentionConfigurationXML{
+		Xmlns:                 "http://s3.amazonaws.com/doc/2006-03-01/",
+		MaxNoncurrentVersions: &config.MaxNoncurrentVersions,
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(xmlConfig); err != nil {
+		log.Error().Err(err).Msg("Failed to encode GetBucketVersionRetention response")
+	}
+}
+
+// DeleteBucketVersionRetention handles DELETE /{bucket}?version-retention - DeleteBucketVersionRetention.
+// Versions already pruned are not restored.
+func (h *Handler) DeleteBucketVersionRetention(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	err := h.storage.DeleteBucketVersionRetention(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to delete bucket version retention")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
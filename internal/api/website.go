@@ -66,8 +66,8 @@ func (h *Handler) PutBucketWebsite(w http.ResponseWriter, r *http.Request) {
 	bucket := GetBucket(r)
 
 	var xmlConfig WebsiteConfigurationXML
-	if err := xml.NewDecoder(r.Body).Decode(&xmlConfig); err != nil {
-		WriteError(w, ErrMalformedXML)
+	if s3Err := decodeXMLBody(r, &xmlConfig, maxXMLBodySize); s3Err != nil {
+		WriteError(w, s3Err)
 		return
 	}
 
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// WormConfigurationXML represents the XML format for write-once buckets (JOG extension).
+type WormConfigurationXML struct {
+	XMLName xml.Name `xml:"WormConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Status  string   `xml:"Status"`
+}
+
+// PutBucketWorm handles PUT /{bucket}?worm - PutBucketWorm.
+func (h *Handler) PutBucketWorm(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	var xmlConfig WormConfigurationXML
+	if s3Err := decodeXMLBody(r, &xmlConfig, maxXMLBodySize); s3Err != nil {
+		WriteError(w, s3Err)
+		return
+	}
+	if xmlConfig.Status != "Enabled" && xmlConfig.Status != "Disabled" {
+		WriteErrorWithResource(w, ErrInvalidArgument, "/"+bucket)
+		return
+	}
+
+	err := h.storage.PutBucketWorm(r.Context(), bucket, xmlConfig.Status == "Enabled")
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrWormPermanent) {
+			s3Err := *ErrInvalidBucketState
+			s3Err.Message = "Write-once mode cannot be disabled once it is enabled."
+			WriteErrorWithResource(w, &s3Err, "/"+bucket)
+			return
+		}
+		if errors.Is(err, storage.ErrNotImplemented) {
+			WriteErrorWithResource(w, ErrNotImplemented, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to put bucket write-once setting")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketWorm handles GET /{bucket}?worm - GetBucketWorm.
+func (h *Handler) GetBucketWorm(w http.ResponseWriter, r *http.Request) {
+	bucket := GetBucket(r)
+
+	enabled, err := h.storage.GetBucketWorm(r.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			WriteErrorWithResource(w, ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket write-once setting")
+		WriteError(w, ErrInternalError)
+		return
+	}
+
+	xmlConfig := WormConfigurationXML{
+		Xmlns:  "http://s3.amazonaws.com/doc/2006-03-01/",
+		Status: "Disabled",
+	}
+	if enabled {
+		xmlConfig.Status = "Enabled"
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(xmlConfig); err != nil {
+		log.Error().Err(err).Msg("Failed to encode GetBucketWorm response")
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+)
+
+const (
+	// maxXMLBodySize bounds XML configuration bodies, such as CORS, lifecycle
+	// or tagging configurations.
+	maxXMLBodySize = 1 << 20
+	// maxXMLListBodySize bounds XML bodies listing objects or parts, i.e. the
+	// up to 1000 keys of DeleteObjects and the up to 10000 parts of
+	// CompleteMultipartUpload.
+	maxXMLListBodySize = 16 << 20
+)
+
+// readXMLBody reads an XML request body of at most limit bytes.
+func readXMLBody(r *http.Request, limit int64) ([]byte, *S3Error) {
+	if r.ContentLength > limit {
+		return nil, ErrMaxMessageLengthExceeded
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		if isRequestTimeout(err) {
+			return nil, ErrRequestTimeout
+		}
+		return nil, ErrIncompleteBody
+	}
+	if int64(len(body)) > limit {
+		return nil, ErrMaxMessageLengthExceeded
+	}
+	return body, nil
+}
+
+// decodeXMLBody reads an XML request body of at most limit bytes and
+// decodes it into v.
+func decodeXMLBody(r *http.Request, v any, limit int64) *S3Error {
+	body, s3Err := readXMLBody(r, limit)
+	if s3Err != nil {
+		return s3Err
+	}
+	return unmarshalXML(body, v)
+}
+
+// unmarshalXML strictly decodes an XML document into v. Unlike xml.Unmarshal,
+// it rejects empty documents, document type declarations and anything but
+// comments and whitespace after the root element, and it reports every
+// failure as MalformedXML. The root element must match the XMLName of v, if
+// it has one.
+func unmarshalXML(body []byte, v any) *S3Error {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = true
+
+	root, err := nextXMLElement(dec)
+	if err != nil {
+		return ErrMalformedXML
+	}
+	if err := dec.DecodeElement(v, root); err != nil {
+		return ErrMalformedXML
+	}
+	if _, err := nextXMLElement(dec); !errors.Is(err, io.EOF) {
+		return ErrMalformedXML
+	}
+	return nil
+}
+
+// nextXMLElement returns the next start element of a document, skipping
+// comments, processing instructions and whitespace. It fails on directives
+// such as <!DOCTYPE> and on text outside of elements, and returns io.EOF at
+// the end of the document.
+func nextXMLElement(dec *xml.Decoder) (*xml.StartElement, error) {
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			return &t, nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) != 0 {
+				return nil, errors.New("text outside of the root element")
+			}
+		case xml.Directive:
+			return nil, errors.New("unexpected directive")
+		case xml.EndElement:
+			return nil, errors.New("unexpected end element")
+		}
+	}
+}
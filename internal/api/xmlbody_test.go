@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalXML(t *testing.T) {
+	valid := []string{
+		`<Tagging><TagSet></TagSet></Tagging>`,
+		"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<Tagging/>\n",
+		`<!-- tags --><Tagging></Tagging><!-- end -->`,
+	}
+	for _, body := range valid {
+		var tagging Tagging
+		if s3Err := unmarshalXML([]byte(body), &tagging); s3Err != nil {
+			t.Errorf("unmarshalXML(%q) = %v, want success", body, s3Err.Code)
+		}
+	}
+
+	malformed := []string{
+		``,
+		`   `,
+		`<Tagging>`,
+		`<Other></Other>`,
+		`<Tagging></Tagging><Tagging></Tagging>`,
+		`<Tagging></Tagging>trailing`,
+		`text<Tagging></Tagging>`,
+		`<!DOCTYPE Tagging><Tagging></Tagging>`,
+		`<Tagging><TagSet><Tag><Key>&x;</Key></Tag></TagSet></Tagging>`,
+	}
+	for _, body := range malformed {
+		var tagging Tagging
+		if s3Err := unmarshalXML([]byte(body), &tagging); s3Err == nil || s3Err.Code != "MalformedXML" {
+			t.Errorf("unmarshalXML(%q) = %v, want MalformedXML", body, s3Err)
+		}
+	}
+}
+
+func TestReadXMLBody(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/bucket?tagging", strings.NewReader("12345"))
+	if body, s3Err := readXMLBody(r, 5); s3Err != nil || string(body) != "12345" {
+		t.Errorf("readXMLBody at the limit = %q, %v", body, s3Err)
+	}
+
+	r = httptest.NewRequest("PUT", "/bucket?tagging", strings.NewReader("123456"))
+	if _, s3Err := readXMLBody(r, 5); s3Err != ErrMaxMessageLengthExceeded {
+		t.Errorf("readXMLBody over the limit = %v, want MaxMessageLengthExceeded", s3Err)
+	}
+
+	// Bodies without a Content-Length are limited while they are read
+	r = httptest.NewRequest("PUT", "/bucket?tagging", strings.NewReader("123456"))
+	r.ContentLength = -1
+	if _, s3Err := readXMLBody(r, 5); s3Err != ErrMaxMessageLengthExceeded {
+		t.Errorf("readXMLBody of a chunked body over the limit = %v, want MaxMessageLengthExceeded", s3Err)
+	}
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/policy"
+)
+
+// temporaryKeyPrefix starts the access keys of temporary credentials.
+const temporaryKeyPrefix = "JOGT"
+
+// TemporaryCredentials are credentials issued to a federated user. Requests
+// signed with them carry the session token in X-Amz-Security-Token.
+type TemporaryCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// sessionClaims are the claims of the session token of temporary
+// credentials. The token is signed with the secret key of the default
+// namespace, so temporary credentials need not be stored.
+type sessionClaims struct {
+	AccessKey string `json:"ak"`
+	// Subject is the federated user the credentials were issued to.
+	Subject  string   `json:"sub"`
+	Policies []string `json:"pol"`
+	// Expiration is in unix seconds.
+	Expiration int64 `json:"exp"`
+}
+
+// SetFederation accepts temporary credentials limited to the named policies.
+// Federated users act as the access key of the default namespace within
+// their policies.
+func (m *Middleware) SetFederation(policies map[string]*policy.Document) {
+	m.federation = policies
+}
+
+// IssueTemporaryCredentials returns credentials of a federated user limited to
+// the named policies, valid for a duration. Changing the secret key of the
+// default namespace invalidates them, once the previous secret key of a
+// rotation expires.
+func (m *Middleware) IssueTemporaryCredentials(subject string, policies []string, duration time.Duration) (*TemporaryCredentials, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	claims := sessionClaims{
+		AccessKey:  temporaryKeyPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b),
+		Subject:    subject,
+		Policies:   policies,
+		Expiration: time.Now().Add(duration).Unix(),
+	}
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	root, _ := m.credential(m.root)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return &TemporaryCredentials{
+		AccessKeyID:     claims.AccessKey,
+		SecretAccessKey: temporarySecret(root.secretKey, payload),
+		SessionToken:    payload + "." + base64.RawURLEncoding.EncodeToString(tokenSignature(root.secretKey, payload)),
+		Expiration:      time.Unix(claims.Expiration, 0).UTC(),
+	}, nil
+}
+
+// tokenSignature signs the payload of a session token.
+func tokenSignature(rootSecret, payload string) []byte {
+	return hmacSHA256([]byte(rootSecret), "jog-session-token\n"+payload)
+}
+
+// temporarySecret derives the secret key of temporary credentials from the
+// payload of their session token.
+func temporarySecret(rootSecret, payload string) string {
+	return base64.RawURLEncoding.EncodeToString(hmacSHA256([]byte(rootSecret), "jog-session-secret\n"+payload))
+}
+
+// securityToken returns the session token of a request signed with
+// temporary credentials, or "".
+func securityToken(r *http.Request) string {
+	if token := r.Header.Get("X-Amz-Security-Token"); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("X-Amz-Security-Token")
+}
+
+// lookupTemporaryCredential returns the identity of temporary credentials
+// with their session token.
+func (m *Middleware) lookupTemporaryCredential(accessKey, token string) (*identity, *api.S3Error) {
+	root, _ := m.credential(m.root)
+	payload, sig, ok := strings.Cut(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(sig)
+	if !ok || err != nil || !m.matchSecret(&root, func(rootSecret string) bool {
+		return hmac.Equal(signature, tokenSignature(rootSecret, payload))
+	}) {
+		return nil, api.ErrInvalidToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, api.ErrInvalidToken
+	}
+	var claims sessionClaims
+	if err := json.Unmarshal(data, &claims); err != nil || claims.AccessKey != accessKey {
+		return nil, api.ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.Expiration {
+		return nil, api.ErrExpiredToken
+	}
+
+	// Policies removed from the configuration no longer apply
+	var docs []*policy.Document
+	for _, name := range claims.Policies {
+		if doc, ok := m.federation[name]; ok {
+			docs = append(docs, doc)
+		}
+	}
+	if len(docs) == 0 {
+		return nil, api.ErrAccessDenied
+	}
+
+	return &identity{
+		accessKey:    accessKey,
+		secretKey:    temporarySecret(root.secretKey, payload),
+		owner:        root.accessKey,
+		session:      policy.Merge(docs...),
+		usesPrevious: root.usesPrevious,
+	}, nil
+}
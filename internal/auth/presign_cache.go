@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kumasuke/jog/internal/metrics"
+)
+
+var (
+	presignCacheHits = metrics.NewCounter("jog_presign_cache_hits_total",
+		"Presigned URL requests whose signature was verified from the cache.")
+	presignCacheMisses = metrics.NewCounter("jog_presign_cache_misses_total",
+		"Presigned URL requests whose signature was computed.")
+)
+
+// presignCache remembers presigned URLs whose signature was verified, so
+// repeated requests for popular URLs skip canonicalization and signing. An
+// entry is only reused for a request with the same method, path, query and
+// signed header values, which have the same signature.
+type presignCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]presignCacheEntry
+}
+
+// presignCacheEntry is a verified presigned URL.
+type presignCacheEntry struct {
+	id identity
+	// until is when the URL expires or the entry is verified again,
+	// whichever comes first.
+	until time.Time
+}
+
+func newPresignCache(size int, ttl time.Duration) *presignCache {
+	return &presignCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[[sha256.Size]byte]presignCacheEntry),
+	}
+}
+
+// presignCacheKey returns the cache key of a presigned request: a hash of
+// the method, path, query including the signature, and the values of the
+// signed headers.
+func presignCacheKey(r *http.Request, signedHeaders string) [sha256.Size]byte {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteString("\n")
+	b.WriteString(r.URL.EscapedPath())
+	b.WriteString("\n")
+	b.WriteString(r.URL.RawQuery)
+	headers := strings.Split(signedHeaders, ";")
+	sort.Strings(headers)
+	for _, h := range headers {
+		h = strings.ToLower(h)
+		value := r.Host
+		if h != "host" {
+			value = headerValues(r.Header.Values(h))
+		}
+		b.WriteString("\n")
+		b.WriteString(h)
+		b.WriteString(":")
+		b.WriteString(value)
+	}
+	return sha256.Sum256([]byte(b.String()))
+}
+
+// get returns the identity of a verified URL that has not expired.
+func (c *presignCache) get(key [sha256.Size]byte, now time.Time) (identity, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return identity{}, false
+	}
+	if !now.Before(entry.until) {
+		delete(c.entries, key)
+		return identity{}, false
+	}
+	return entry.id, true
+}
+
+// put caches a verified URL until it expires, at most for the TTL of the
+// cache. When the cache is full, expired entries are dropped, and all
+// entries if none has expired.
+func (c *presignCache) put(key [sha256.Size]byte, id identity, now, expires time.Time) {
+	until := now.Add(c.ttl)
+	if !expires.IsZero() && expires.Before(until) {
+		until = expires
+	}
+	if !now.Before(until) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.size {
+		for k, entry := range c.entries {
+			if !now.Before(entry.until) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.size {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = presignCacheEntry{id: id, until: until}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/kumasuke/jog/internal/storage"
+)
+
+// RootAccessKey returns the access key of the default namespace.
+func (m *Middleware) RootAccessKey() string {
+	return m.root
+}
+
+// SetSecrets replaces the secret keys of a configured access key, as rotated
+// through the admin API. Requests signed with the previous secret key are
+// accepted until it expires. It reports false for unknown access keys.
+func (m *Middleware) SetSecrets(secrets *storage.AccessKeySecrets) bool {
+	m.credMu.Lock()
+	defer m.credMu.Unlock()
+	cred, ok := m.credentials[secrets.AccessKey]
+	if !ok {
+		return false
+	}
+	if secrets.PreviousSecretKey != cred.previousSecretKey {
+		delete(m.previousUsed, secrets.AccessKey)
+	}
+	cred.secretKey = secrets.SecretKey
+	cred.previousSecretKey = secrets.PreviousSecretKey
+	cred.previousExpires = secrets.PreviousExpires
+	m.credentials[secrets.AccessKey] = cred
+	return true
+}
+
+// RestoreSecrets applies secret keys stored by an earlier rotation at
+// startup. They are ignored if the secret key of the access key was changed
+// in the configuration since, to neither of them.
+func (m *Middleware) RestoreSecrets(secrets *storage.AccessKeySecrets) bool {
+	cred, ok := m.credential(secrets.AccessKey)
+	if !ok || (cred.secretKey != secrets.SecretKey && cred.secretKey != secrets.PreviousSecretKey) {
+		return false
+	}
+	return m.SetSecrets(secrets)
+}
+
+// Secrets returns the secret keys of a configured access key, and when its
+// previous secret key last signed a request, zero if it did not since the
+// rotation.
+func (m *Middleware) Secrets(accessKey string) (*storage.AccessKeySecrets, time.Time, bool) {
+	m.credMu.RLock()
+	defer m.credMu.RUnlock()
+	cred, ok := m.credentials[accessKey]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	secrets := &storage.AccessKeySecrets{
+		AccessKey:         accessKey,
+		SecretKey:         cred.secretKey,
+		PreviousSecretKey: cred.previousSecretKey,
+		PreviousExpires:   cred.previousExpires,
+	}
+	return secrets, m.previousUsed[accessKey], true
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/policy"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// ServiceAccountStore looks up service accounts by access key.
+type ServiceAccountStore interface {
+	GetServiceAccount(ctx context.Context, accessKey string) (*storage.ServiceAccount, error)
+}
+
+// SetServiceAccounts accepts the credentials of service accounts stored in a
+// store.
+func (m *Middleware) SetServiceAccounts(store ServiceAccountStore) {
+	m.serviceAccounts = store
+}
+
+// ServiceAccountSecret returns the secret key of a service account minted by
+// a parent access key. Secret keys are derived from the secret key of the
+// parent, so they need not be stored to verify signatures; changing the
+// secret key of the parent invalidates its service accounts, once the
+// previous secret key of a rotation expires.
+func (m *Middleware) ServiceAccountSecret(parent, accessKey string) (string, bool) {
+	cred, ok := m.credential(parent)
+	if !ok {
+		return "", false
+	}
+	return serviceAccountSecret(cred.secretKey, accessKey), true
+}
+
+// HashSecret returns the hex encoded SHA-256 hash of a secret key, as stored
+// for service accounts.
+func HashSecret(secretKey string) string {
+	sum := sha256.Sum256([]byte(secretKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// serviceAccountSecret derives the secret key of a service account.
+func serviceAccountSecret(parentSecret, accessKey string) string {
+	mac := hmac.New(sha256.New, []byte(parentSecret))
+	mac.Write([]byte("jog-service-account\n" + accessKey))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// lookupServiceAccount returns the identity of a service account. It acts as
+// its parent, limited to the bucket, prefix and actions of the account.
+func (m *Middleware) lookupServiceAccount(ctx context.Context, accessKey string) (*identity, *api.S3Error) {
+	if m.serviceAccounts == nil {
+		return nil, api.ErrInvalidAccessKeyId
+	}
+	account, err := m.serviceAccounts.GetServiceAccount(ctx, accessKey)
+	if errors.Is(err, storage.ErrNoSuchServiceAccount) {
+		return nil, api.ErrInvalidAccessKeyId
+	}
+	if err != nil {
+		log.Error().Err(err).Str("access_key", accessKey).Msg("Failed to look up service account")
+		return nil, api.ErrInternalError
+	}
+	if account.Expired(time.Now()) {
+		return nil, api.ErrExpiredToken
+	}
+
+	parent, ok := m.credential(account.Parent)
+	if !ok {
+		return nil, api.ErrInvalidAccessKeyId
+	}
+	// Accounts minted before the parent was rotated keep working while the
+	// previous secret key of the parent is accepted
+	var secretKey string
+	if !m.matchSecret(&parent, func(parentSecret string) bool {
+		secretKey = serviceAccountSecret(parentSecret, accessKey)
+		return hmac.Equal([]byte(HashSecret(secretKey)), []byte(account.SecretHash))
+	}) {
+		// The secret key of the parent changed since the account was minted
+		return nil, api.ErrInvalidAccessKeyId
+	}
+
+	return &identity{
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		tenant:       parent.tenant,
+		owner:        parent.accessKey,
+		session:      policy.ScopeDocument(account.Bucket, account.Prefix, account.Actions),
+		usesPrevious: parent.usesPrevious,
+	}, nil
+}
@@ -6,25 +6,233 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"net/http"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/policy"
+	"github.com/kumasuke/jog/internal/storage"
 )
 
+// maxSigningKeys bounds the signing key cache. The cache is cleared when it
+// is full; keys of current dates are derived again on the next requests.
+const maxSigningKeys = 1024
+
 // Middleware handles AWS Signature V4 authentication.
 type Middleware struct {
+	// credMu guards credentials and previousUsed, as secret keys may be
+	// rotated while requests are served.
+	credMu      sync.RWMutex
+	credentials map[string]identity
+	// previousUsed is when the previous secret key of an access key last
+	// signed a request.
+	previousUsed map[string]time.Time
+	// root is the access key of the default namespace.
+	root     string
+	sessions *api.SessionStore
+	// regions and services restrict the credential scopes of requests;
+	// empty lists accept any region or service.
+	regions  []string
+	services []string
+
+	mu          sync.Mutex
+	signingKeys map[signingScope][]byte
+
+	// presignCache is nil when verified presigned URLs are not cached.
+	presignCache *presignCache
+
+	// serviceAccounts is nil when service accounts are not accepted.
+	serviceAccounts ServiceAccountStore
+
+	// federation holds the policies temporary credentials may be limited to
+	// by name; nil when temporary credentials are not accepted.
+	federation map[string]*policy.Document
+}
+
+// signingScope identifies a derived signing key.
+type signingScope struct {
+	secretKey string
+	date      string
+	region    string
+	service   string
+}
+
+// identity is the secret key and tenant of an access key.
+type identity struct {
 	accessKey string
 	secretKey string
+	// tenant is empty for the default namespace.
+	tenant string
+	// owner is the access key the identity acts as, if not its own.
+	owner string
+	// session limits what the identity may do, if set.
+	session *policy.Document
+	// previousSecretKey is accepted as well until previousExpires while
+	// the access key is rotated.
+	previousSecretKey string
+	previousExpires   time.Time
+	// usesPrevious is the access key whose previous secret key the secret
+	// key of the identity is, or is derived from, once it was matched.
+	usesPrevious string
+}
+
+// secretKeys returns the secret keys of an identity accepted at a time: its
+// secret key and, until it expires, its previous secret key.
+func (id *identity) secretKeys(t time.Time) []string {
+	if id.previousSecretKey != "" && t.Before(id.previousExpires) {
+		return []string{id.secretKey, id.previousSecretKey}
+	}
+	return []string{id.secretKey}
 }
 
 // NewMiddleware creates a new authentication middleware.
 func NewMiddleware(accessKey, secretKey string) *Middleware {
 	return &Middleware{
-		accessKey: accessKey,
-		secretKey: secretKey,
+		credentials: map[string]identity{
+			accessKey: {accessKey: accessKey, secretKey: secretKey},
+		},
+		previousUsed: make(map[string]time.Time),
+		root:         accessKey,
+		signingKeys:  make(map[signingScope][]byte),
+	}
+}
+
+// SetCredentialScope restricts the regions and services requests may be
+// signed for. Requests signed for others are rejected with
+// AuthorizationHeaderMalformed, or AuthorizationQueryParametersError for
+// presigned URLs, naming the first allowed region or service. Empty lists
+// accept any region or service.
+func (m *Middleware) SetCredentialScope(regions, services []string) {
+	m.regions = regions
+	m.services = services
+}
+
+// SetPresignCache caches up to size verified presigned URLs until they
+// expire, at most for ttl. A size of zero disables the cache.
+func (m *Middleware) SetPresignCache(size int, ttl time.Duration) {
+	if size <= 0 || ttl <= 0 {
+		m.presignCache = nil
+		return
+	}
+	m.presignCache = newPresignCache(size, ttl)
+}
+
+// AddTenantCredential registers an access key whose requests are served from
+// the given tenant namespace.
+func (m *Middleware) AddTenantCredential(accessKey, secretKey, tenant string) {
+	m.credMu.Lock()
+	defer m.credMu.Unlock()
+	m.credentials[accessKey] = identity{accessKey: accessKey, secretKey: secretKey, tenant: tenant}
+}
+
+// credential returns the identity of a configured access key.
+func (m *Middleware) credential(accessKey string) (identity, bool) {
+	m.credMu.RLock()
+	defer m.credMu.RUnlock()
+	cred, ok := m.credentials[accessKey]
+	return cred, ok
+}
+
+// matchSecret finds the secret key a request of an identity was signed with,
+// trying its previous secret key after its secret key. The identity is
+// updated to the matching secret key, so that derived keys and cached
+// presigned URLs use it. The use of a previous secret key is only recorded
+// by verified, once the signature of the request matched as well.
+func (m *Middleware) matchSecret(id *identity, signedWith func(secretKey string) bool) bool {
+	for i, secretKey := range id.secretKeys(time.Now()) {
+		if !signedWith(secretKey) {
+			continue
+		}
+		if i > 0 {
+			id.secretKey = secretKey
+			id.usesPrevious = id.accessKey
+		}
+		return true
+	}
+	return false
+}
+
+// verified records that the previous secret key of an access key signed a
+// request, directly or through a key derived from it, once the signature
+// of the request was verified.
+func (m *Middleware) verified(id *identity) {
+	if id.usesPrevious != "" {
+		m.usedPrevious(id.usesPrevious, time.Now())
+	}
+}
+
+// usedPrevious records that the previous secret key of an access key signed
+// a request.
+func (m *Middleware) usedPrevious(accessKey string, t time.Time) {
+	m.credMu.Lock()
+	m.previousUsed[accessKey] = t
+	m.credMu.Unlock()
+}
+
+// Authenticate checks the signature of a call of the gRPC control service
+// with the secret keys of a configured access key; signedWith reports
+// whether the call was signed with a secret key. It returns the tenant of
+// the access key.
+func (m *Middleware) Authenticate(accessKey string, signedWith func(secretKey string) bool) (string, bool) {
+	cred, ok := m.credential(accessKey)
+	if !ok || !m.matchSecret(&cred, signedWith) {
+		return "", false
+	}
+	m.verified(&cred)
+	return cred.tenant, true
+}
+
+// SetSessionStore accepts the credentials of directory bucket sessions created
+// by CreateSession, together with their x-amz-s3session-token.
+func (m *Middleware) SetSessionStore(sessions *api.SessionStore) {
+	m.sessions = sessions
+}
+
+// lookupCredential returns the identity of an access key. Requests with an
+// X-Amz-Security-Token are signed with temporary credentials of federated
+// users. Session access keys are only valid with the session token, for
+// requests to the session's bucket that its mode allows, until the session
+// expires. They act as the caller that created the session. Other unknown
+// access keys are looked up as service accounts.
+func (m *Middleware) lookupCredential(r *http.Request, accessKey string) (*identity, *api.S3Error) {
+	if cred, ok := m.credential(accessKey); ok {
+		return &cred, nil
+	}
+	if token := securityToken(r); token != "" && m.federation != nil {
+		return m.lookupTemporaryCredential(accessKey, token)
+	}
+
+	var session *api.Session
+	if m.sessions != nil {
+		session = m.sessions.Get(accessKey)
+	}
+	if session == nil {
+		return m.lookupServiceAccount(r.Context(), accessKey)
+	}
+
+	token := r.Header.Get("x-amz-s3session-token")
+	if token == "" {
+		token = r.URL.Query().Get("X-Amz-S3session-Token")
+	}
+	if !hmac.Equal([]byte(token), []byte(session.Token)) {
+		return nil, api.ErrAccessDenied
 	}
+	if time.Now().After(session.Expiration) {
+		return nil, api.ErrExpiredToken
+	}
+
+	bucket, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != session.Bucket {
+		return nil, api.ErrAccessDenied
+	}
+	if session.Mode == api.SessionModeReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return nil, api.ErrAccessDenied
+	}
+
+	return &identity{accessKey: session.Owner, secretKey: session.SecretAccessKey, tenant: session.Tenant}, nil
 }
 
 // Wrap wraps an HTTP handler with authentication.
@@ -35,11 +243,12 @@ func (m *Middleware) Wrap(next http.Handler) http.Handler {
 		if auth == "" {
 			// Check for query string auth (presigned URL)
 			if r.URL.Query().Get("X-Amz-Algorithm") != "" {
-				if err := m.verifyPresignedURL(r); err != nil {
+				id, err := m.verifyPresignedURL(r)
+				if err != nil {
 					api.WriteError(w, err)
 					return
 				}
-				next.ServeHTTP(w, r)
+				next.ServeHTTP(w, withIdentity(r, id))
 				return
 			}
 			api.WriteError(w, api.ErrAccessDenied)
@@ -47,21 +256,22 @@ func (m *Middleware) Wrap(next http.Handler) http.Handler {
 		}
 
 		// Parse and verify AWS Signature V4
-		if err := m.verifySignatureV4(r, auth); err != nil {
+		id, err := m.verifySignatureV4(r, auth)
+		if err != nil {
 			api.WriteError(w, err)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, withIdentity(r, id))
 	})
 }
 
 // verifySignatureV4 verifies AWS Signature V4 authentication.
-func (m *Middleware) verifySignatureV4(r *http.Request, auth string) *api.S3Error {
+func (m *Middleware) verifySignatureV4(r *http.Request, auth string) (*identity, *api.S3Error) {
 	// Parse Authorization header
 	// Format: AWS4-HMAC-SHA256 Credential=ACCESS_KEY/DATE/REGION/s3/aws4_request, SignedHeaders=..., Signature=...
 	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
-		return api.ErrAccessDenied
+		return nil, api.ErrAccessDenied
 	}
 
 	// Parse components
@@ -86,13 +296,13 @@ func (m *Middleware) verifySignatureV4(r *http.Request, auth string) *api.S3Erro
 	providedSignature := authParams["Signature"]
 
 	if credential == "" || signedHeaders == "" || providedSignature == "" {
-		return api.ErrAccessDenied
+		return nil, api.ErrAccessDenied
 	}
 
 	// Parse credential: ACCESS_KEY/DATE/REGION/SERVICE/aws4_request
 	credParts := strings.Split(credential, "/")
 	if len(credParts) != 5 {
-		return api.ErrAccessDenied
+		return nil, api.ErrAccessDenied
 	}
 
 	accessKey := credParts[0]
@@ -100,9 +310,14 @@ func (m *Middleware) verifySignatureV4(r *http.Request, auth string) *api.S3Erro
 	region := credParts[2]
 	service := credParts[3]
 
+	if s3Err := m.checkScope(region, service, api.ErrAuthorizationHeaderMalformed, "The authorization header is malformed; "); s3Err != nil {
+		return nil, s3Err
+	}
+
 	// Verify access key
-	if accessKey != m.accessKey {
-		return api.ErrInvalidAccessKeyId
+	cred, s3Err := m.lookupCredential(r, accessKey)
+	if s3Err != nil {
+		return nil, s3Err
 	}
 
 	// Get request date
@@ -120,27 +335,29 @@ func (m *Middleware) verifySignatureV4(r *http.Request, auth string) *api.S3Erro
 		reqTime, err = time.Parse(time.RFC1123, amzDate)
 	}
 	if err != nil {
-		return api.ErrAccessDenied
+		return nil, api.ErrAccessDenied
 	}
 
 	// Check if request is within 15 minutes
 	if time.Since(reqTime).Abs() > 15*time.Minute {
-		return api.ErrRequestTimeTooSkewed
+		return nil, api.ErrRequestTimeTooSkewed
 	}
 
-	// Calculate expected signature
-	expectedSignature := m.calculateSignature(r, date, region, service, signedHeaders)
-
-	// Compare signatures
-	if !hmac.Equal([]byte(expectedSignature), []byte(providedSignature)) {
-		return api.ErrSignatureDoesNotMatch
+	// Compare signatures, with the previous secret key of a rotated access
+	// key as well
+	if !m.matchSecret(cred, func(secretKey string) bool {
+		expectedSignature := m.calculateSignature(r, secretKey, date, region, service, signedHeaders)
+		return hmac.Equal([]byte(expectedSignature), []byte(providedSignature))
+	}) {
+		return nil, api.ErrSignatureDoesNotMatch
 	}
+	m.verified(cred)
 
-	return nil
+	return cred, nil
 }
 
 // calculateSignature calculates AWS Signature V4.
-func (m *Middleware) calculateSignature(r *http.Request, date, region, service, signedHeaders string) string {
+func (m *Middleware) calculateSignature(r *http.Request, secretKey, date, region, service, signedHeaders string) string {
 	// Create canonical request
 	canonicalRequest := m.createCanonicalRequest(r, signedHeaders)
 	canonicalRequestHash := sha256Hash(canonicalRequest)
@@ -155,7 +372,7 @@ func (m *Middleware) calculateSignature(r *http.Request, date, region, service,
 	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + canonicalRequestHash
 
 	// Calculate signing key
-	signingKey := m.getSigningKey(date, region, service)
+	signingKey := m.getSigningKey(secretKey, date, region, service)
 
 	// Calculate signature
 	signature := hmacSHA256(signingKey, stringToSign)
@@ -188,7 +405,7 @@ func (m *Middleware) createCanonicalRequest(r *http.Request, signedHeaders strin
 		if h == "host" {
 			value = r.Host
 		} else {
-			value = r.Header.Get(h)
+			value = headerValues(r.Header.Values(h))
 		}
 		canonicalHeaders.WriteString(h)
 		canonicalHeaders.WriteString(":")
@@ -236,22 +453,55 @@ func (m *Middleware) canonicalQueryString(r *http.Request) string {
 	return strings.Join(parts, "&")
 }
 
-// getSigningKey derives the signing key.
-func (m *Middleware) getSigningKey(date, region, service string) []byte {
-	kDate := hmacSHA256([]byte("AWS4"+m.secretKey), date)
+// checkScope checks the region and service of a credential scope. Mismatches
+// are reported as err, with a message starting with prefix, as S3 does.
+func (m *Middleware) checkScope(region, service string, err *api.S3Error, prefix string) *api.S3Error {
+	if len(m.regions) > 0 && !slices.Contains(m.regions, region) {
+		s3Err := *err
+		s3Err.Message = prefix + "the region '" + region + "' is wrong; expecting '" + m.regions[0] + "'"
+		s3Err.Region = m.regions[0]
+		return &s3Err
+	}
+	if len(m.services) > 0 && !slices.Contains(m.services, service) {
+		s3Err := *err
+		s3Err.Message = prefix + "incorrect service '" + service + "'. This endpoint belongs to '" + m.services[0] + "'."
+		return &s3Err
+	}
+	return nil
+}
+
+// getSigningKey returns the signing key of a credential scope. Derived keys
+// are cached, since a client signs all requests of a day with the same key.
+func (m *Middleware) getSigningKey(secretKey, date, region, service string) []byte {
+	scope := signingScope{secretKey: secretKey, date: date, region: region, service: service}
+	m.mu.Lock()
+	key, ok := m.signingKeys[scope]
+	m.mu.Unlock()
+	if ok {
+		return key
+	}
+
+	kDate := hmacSHA256([]byte("AWS4"+secretKey), date)
 	kRegion := hmacSHA256(kDate, region)
 	kService := hmacSHA256(kRegion, service)
 	kSigning := hmacSHA256(kService, "aws4_request")
+
+	m.mu.Lock()
+	if len(m.signingKeys) >= maxSigningKeys {
+		clear(m.signingKeys)
+	}
+	m.signingKeys[scope] = kSigning
+	m.mu.Unlock()
 	return kSigning
 }
 
 // verifyPresignedURL verifies a presigned URL.
-func (m *Middleware) verifyPresignedURL(r *http.Request) *api.S3Error {
+func (m *Middleware) verifyPresignedURL(r *http.Request) (*identity, *api.S3Error) {
 	query := r.URL.Query()
 
 	algorithm := query.Get("X-Amz-Algorithm")
 	if algorithm != "AWS4-HMAC-SHA256" {
-		return api.ErrAccessDenied
+		return nil, api.ErrAccessDenied
 	}
 
 	credential := query.Get("X-Amz-Credential")
@@ -261,13 +511,13 @@ func (m *Middleware) verifyPresignedURL(r *http.Request) *api.S3Error {
 	expires := query.Get("X-Amz-Expires")
 
 	if credential == "" || signedHeaders == "" || signature == "" || amzDate == "" {
-		return api.ErrAccessDenied
+		return nil, api.ErrAccessDenied
 	}
 
 	// Parse credential
 	credParts := strings.Split(credential, "/")
 	if len(credParts) != 5 {
-		return api.ErrAccessDenied
+		return nil, api.ErrAccessDenied
 	}
 
 	accessKey := credParts[0]
@@ -275,42 +525,86 @@ func (m *Middleware) verifyPresignedURL(r *http.Request) *api.S3Error {
 	region := credParts[2]
 	service := credParts[3]
 
-	if accessKey != m.accessKey {
-		return api.ErrInvalidAccessKeyId
+	if s3Err := m.checkScope(region, service, api.ErrAuthorizationQueryParametersError, "Error parsing the X-Amz-Credential parameter; "); s3Err != nil {
+		return nil, s3Err
+	}
+
+	// Requests for a URL verified before are served from the cache while
+	// the secret key of the URL is still accepted. The identity is taken
+	// from the configured access key, not the cache, so that requests get
+	// its current tenant and secret keys
+	var cacheKey [sha256.Size]byte
+	if m.presignCache != nil {
+		cacheKey = presignCacheKey(r, signedHeaders)
+		now := time.Now()
+		if id, ok := m.presignCache.get(cacheKey, now); ok {
+			if cred, ok := m.credential(id.accessKey); ok && slices.Contains(cred.secretKeys(now), id.secretKey) {
+				if id.secretKey != cred.secretKey {
+					cred.secretKey = id.secretKey
+					cred.usesPrevious = cred.accessKey
+				}
+				m.verified(&cred)
+				presignCacheHits.Inc()
+				removeSignature(r)
+				return &cred, nil
+			}
+		}
+		presignCacheMisses.Inc()
+	}
+
+	cred, s3Err := m.lookupCredential(r, accessKey)
+	if s3Err != nil {
+		return nil, s3Err
 	}
 
 	// Check expiration
 	reqTime, err := time.Parse("20060102T150405Z", amzDate)
 	if err != nil {
-		return api.ErrAccessDenied
+		return nil, api.ErrAccessDenied
 	}
 
+	var expiresAt time.Time
 	if expires != "" {
 		expiresSec, err := time.ParseDuration(expires + "s")
 		if err == nil {
 			if time.Since(reqTime) > expiresSec {
-				return api.ErrRequestTimeTooSkewed
+				return nil, api.ErrRequestTimeTooSkewed
 			}
+			expiresAt = reqTime.Add(expiresSec)
 		}
 	}
 
 	// Create canonical request for presigned URL
 	// Remove signature from query for verification
-	cleanQuery := r.URL.Query()
-	cleanQuery.Del("X-Amz-Signature")
-	r.URL.RawQuery = cleanQuery.Encode()
+	removeSignature(r)
 
-	expectedSignature := m.calculatePresignedSignature(r, date, region, service, signedHeaders, amzDate)
+	if !m.matchSecret(cred, func(secretKey string) bool {
+		expectedSignature := m.calculatePresignedSignature(r, secretKey, date, region, service, signedHeaders, amzDate)
+		return hmac.Equal([]byte(expectedSignature), []byte(signature))
+	}) {
+		return nil, api.ErrSignatureDoesNotMatch
+	}
+	m.verified(cred)
 
-	if !hmac.Equal([]byte(expectedSignature), []byte(signature)) {
-		return api.ErrSignatureDoesNotMatch
+	// Session credentials are checked against their bucket and mode on
+	// every request, so only URLs of static credentials are cached
+	if _, static := m.credential(accessKey); static && m.presignCache != nil {
+		m.presignCache.put(cacheKey, *cred, time.Now(), expiresAt)
 	}
 
-	return nil
+	return cred, nil
+}
+
+// removeSignature removes the signature from the query of a presigned
+// request, which is signed without it.
+func removeSignature(r *http.Request) {
+	cleanQuery := r.URL.Query()
+	cleanQuery.Del("X-Amz-Signature")
+	r.URL.RawQuery = cleanQuery.Encode()
 }
 
 // calculatePresignedSignature calculates signature for presigned URL.
-func (m *Middleware) calculatePresignedSignature(r *http.Request, date, region, service, signedHeaders, amzDate string) string {
+func (m *Middleware) calculatePresignedSignature(r *http.Request, secretKey, date, region, service, signedHeaders, amzDate string) string {
 	// Create canonical request
 	method := r.Method
 	// Use EscapedPath to match AWS SDK's signature calculation for presigned URLs
@@ -331,7 +625,7 @@ func (m *Middleware) calculatePresignedSignature(r *http.Request, date, region,
 		if h == "host" {
 			value = r.Host
 		} else {
-			value = r.Header.Get(h)
+			value = headerValues(r.Header.Values(h))
 		}
 		canonicalHeaders.WriteString(h)
 		canonicalHeaders.WriteString(":")
@@ -355,13 +649,24 @@ func (m *Middleware) calculatePresignedSignature(r *http.Request, date, region,
 	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + canonicalRequestHash
 
 	// Signing key
-	signingKey := m.getSigningKey(date, region, service)
+	signingKey := m.getSigningKey(secretKey, date, region, service)
 
 	// Signature
 	signature := hmacSHA256(signingKey, stringToSign)
 	return hex.EncodeToString(signature)
 }
 
+// headerValues returns the canonical value of a signed header. Headers sent
+// more than once, such as x-amz-object-attributes, are signed as their
+// trimmed values joined by commas.
+func headerValues(values []string) string {
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ",")
+}
+
 func sha256Hash(data string) string {
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
@@ -394,6 +699,24 @@ func isUnreserved(c byte) bool {
 		c == '-' || c == '_' || c == '.' || c == '~'
 }
 
+// withIdentity attaches the caller and tenant of an authenticated request to its
+// context. The access key, or the access key a service account acts as, is
+// used as the canonical owner ID.
+func withIdentity(r *http.Request, id *identity) *http.Request {
+	owner := id.accessKey
+	if id.owner != "" {
+		owner = id.owner
+	}
+	ctx := storage.WithOwner(r.Context(), owner)
+	if id.tenant != "" {
+		ctx = storage.WithTenant(ctx, id.tenant)
+	}
+	if id.session != nil {
+		ctx = policy.WithSessionPolicy(ctx, id.session)
+	}
+	return r.WithContext(ctx)
+}
+
 // DisabledMiddleware is a middleware that skips authentication (for testing).
 type DisabledMiddleware struct{}
 
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/conformance"
+	"github.com/spf13/cobra"
+)
+
+var (
+	conformanceEndpoint string
+	conformanceRegion   string
+	conformanceFormat   string
+)
+
+// NewConformanceCmd creates the conformance command.
+func NewConformanceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "conformance",
+		Short: "Describe the S3 operations of JOG and check endpoints against them",
+	}
+	cmd.AddCommand(newConformanceDescribeCmd())
+	cmd.AddCommand(newConformanceRunCmd())
+	return cmd
+}
+
+func newConformanceDescribeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "describe",
+		Short: "Print the S3 operations and parameters JOG implements as JSON",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return conformance.Describe(os.Stdout)
+		},
+	}
+}
+
+func newConformanceRunCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Check which S3 operations an endpoint supports",
+		Long: "Run the S3 operations JOG implements against an endpoint, in a temporary bucket\n" +
+			"that is deleted afterwards, and print a gap report of the operations that failed\n" +
+			"or are not supported. The endpoint can be JOG or any other S3 service. The command\n" +
+			"fails when an operation did not pass.",
+		Example: "  jog conformance run -c config.yaml\n" +
+			"  jog conformance run --endpoint http://localhost:9000 --access-key minioadmin --secret-key minioadmin --format json",
+		Args: cobra.NoArgs,
+		RunE: runConformance,
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	cmd.Flags().StringVar(&accessKey, "access-key", "", "access key (default from config)")
+	cmd.Flags().StringVar(&secretKey, "secret-key", "", "secret key (default from config)")
+	cmd.Flags().StringVar(&conformanceEndpoint, "endpoint", "", "endpoint URL (default http://localhost:<server.port>)")
+	cmd.Flags().StringVar(&conformanceRegion, "region", "us-east-1", "region of the endpoint")
+	cmd.Flags().StringVar(&conformanceFormat, "format", "text", "report format: text or json")
+
+	return cmd
+}
+
+func runConformance(cmd *cobra.Command, args []string) error {
+	if conformanceFormat != "text" && conformanceFormat != "json" {
+		return fmt.Errorf("unknown format %q, expected text or json", conformanceFormat)
+	}
+
+	var cfg *config.Config
+	var err error
+	if configFile != "" {
+		cfg, err = config.LoadFromFile(configFile)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if accessKey != "" {
+		cfg.Auth.AccessKey = accessKey
+	}
+	if secretKey != "" {
+		cfg.Auth.SecretKey = secretKey
+	}
+	endpoint := conformanceEndpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint(cfg.Server)
+	}
+
+	client := s3.New(s3.Options{
+		Region:       conformanceRegion,
+		BaseEndpoint: aws.String(strings.TrimSuffix(endpoint, "/")),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(cfg.Auth.AccessKey, cfg.Auth.SecretKey, ""),
+	})
+	report := conformance.Run(context.Background(), client)
+	report.Endpoint = endpoint
+
+	if conformanceFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if gaps := len(report.Gaps()); gaps > 0 {
+		return fmt.Errorf("%d of %d operations did not pass", gaps, len(report.Results))
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// inspectTimeFormat is the time format of ls and stat output.
+const inspectTimeFormat = "2006-01-02 15:04:05"
+
+// maxDeleteBatch is the number of keys rm --recursive deletes at a time.
+const maxDeleteBatch = 1000
+
+var inspectRecursive bool
+
+// inspectLong is the shared description of the inspection commands.
+const inspectLong = "It works directly on the data directory and metadata database, without going through\n" +
+	"the HTTP server, for debugging when the server is down. Run it while the server is\n" +
+	"stopped or in read-only mode."
+
+// NewLsCmd creates the ls command.
+func NewLsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ls [s3://bucket/prefix]",
+		Short: "List buckets or the objects of a bucket",
+		Long: "List the buckets, or the objects and common prefixes below a bucket prefix.\n" +
+			inspectLong,
+		Example: "  jog ls\n" +
+			"  jog ls s3://my-bucket/logs/ --recursive",
+		Args: cobra.MaximumNArgs(1),
+		RunE: runLs,
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	cmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data directory")
+	cmd.Flags().BoolVarP(&inspectRecursive, "recursive", "r", false, "list all objects below the prefix instead of grouping by /")
+
+	return cmd
+}
+
+// NewStatCmd creates the stat command.
+func NewStatCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stat s3://bucket/key",
+		Short: "Show the metadata of an object",
+		Long: "Show the size, ETag, content type, user metadata and tags of an object.\n" +
+			inspectLong,
+		Args: cobra.ExactArgs(1),
+		RunE: runStat,
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	cmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data directory")
+
+	return cmd
+}
+
+// NewRmCmd creates the rm command.
+func NewRmCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rm s3://bucket/key",
+		Short: "Delete an object",
+		Long: "Delete an object, or with --recursive all objects below a prefix.\n" +
+			inspectLong,
+		Example: "  jog rm s3://my-bucket/broken.bin\n" +
+			"  jog rm s3://my-bucket/tmp/ --recursive",
+		Args: cobra.ExactArgs(1),
+		RunE: runRm,
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	cmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data directory")
+	cmd.Flags().BoolVarP(&inspectRecursive, "recursive", "r", false, "delete all objects below the prefix")
+
+	return cmd
+}
+
+// NewCatCmd creates the cat command.
+func NewCatCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cat s3://bucket/key",
+		Short: "Write the data of an object to stdout",
+		Long: "Write the data of an object to stdout.\n" +
+			inspectLong,
+		Args: cobra.ExactArgs(1),
+		RunE: runCat,
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	cmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data directory")
+
+	return cmd
+}
+
+// NewDuCmd creates the du command.
+func NewDuCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "du [s3://bucket]",
+		Short: "Show the storage used by buckets",
+		Long: "Show the number of objects, logical bytes, version bytes and pending multipart\n" +
+			"upload bytes of a bucket, or of every bucket. The numbers are read from usage\n" +
+			"counters of the metadata database, without scanning the buckets.\n" +
+			inspectLong,
+		Example: "  jog du\n" +
+			"  jog du s3://my-bucket",
+		Args: cobra.MaximumNArgs(1),
+		RunE: runDu,
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	cmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data directory")
+
+	return cmd
+}
+
+func runLs(cmd *cobra.Command, args []string) error {
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if len(args) == 0 {
+		buckets, err := store.ListBuckets(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list buckets: %w", err)
+		}
+		for _, b := range buckets {
+			fmt.Printf("%s %s\n", b.CreationDate.Local().Format(inspectTimeFormat), b.Name)
+		}
+		return nil
+	}
+
+	bucket, prefix, ok := parseBucketURL(args[0])
+	if !ok || bucket == "" {
+		return fmt.Errorf("expected a bucket URL (s3://bucket/prefix), got %q", args[0])
+	}
+	delimiter := "/"
+	if inspectRecursive {
+		delimiter = ""
+	}
+	return listAll(ctx, store, bucket, prefix, delimiter, func(obj *storage.Object, commonPrefix string) error {
+		if obj == nil {
+			fmt.Printf("%*s %s\n", len(inspectTimeFormat)+11, "PRE", commonPrefix)
+			return nil
+		}
+		fmt.Printf("%s %10d %s\n", obj.LastModified.Local().Format(inspectTimeFormat), obj.Size, obj.Key)
+		return nil
+	})
+}
+
+func runStat(cmd *cobra.Command, args []string) error {
+	bucket, key, err := parseObjectURL(args[0])
+	if err != nil {
+		return err
+	}
+
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	obj, err := store.HeadObject(ctx, bucket, key)
+	if err != nil {
+		return fmt.Errorf("failed to stat s3://%s/%s: %w", bucket, key, err)
+	}
+	tags, err := store.GetObjectTagging(ctx, bucket, key)
+	if err != nil {
+		return fmt.Errorf("failed to get tags of s3://%s/%s: %w", bucket, key, err)
+	}
+
+	fmt.Printf("Key:           %s\n", obj.Key)
+	fmt.Printf("Size:          %d\n", obj.Size)
+	fmt.Printf("ETag:          %s\n", obj.ETag)
+	fmt.Printf("Content-Type:  %s\n", obj.ContentType)
+	fmt.Printf("Last-Modified: %s\n", obj.LastModified.UTC().Format(time.RFC3339))
+	for _, name := range sortedKeys(obj.Metadata) {
+		fmt.Printf("Metadata:      %s=%s\n", name, obj.Metadata[name])
+	}
+	for _, tag := range tags {
+		fmt.Printf("Tag:           %s=%s\n", tag.Key, tag.Value)
+	}
+	return nil
+}
+
+func runRm(cmd *cobra.Command, args []string) error {
+	bucket, key, ok := parseBucketURL(args[0])
+	if !ok || bucket == "" || (key == "" && !inspectRecursive) {
+		return fmt.Errorf("expected an object URL (s3://bucket/key), got %q", args[0])
+	}
+
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if !inspectRecursive {
+		if err := store.DeleteObject(ctx, bucket, key); err != nil {
+			return fmt.Errorf("failed to delete s3://%s/%s: %w", bucket, key, err)
+		}
+		fmt.Printf("delete: s3://%s/%s\n", bucket, key)
+		return nil
+	}
+
+	// Collect the keys first, so deleting does not disturb the listing
+	var keys []string
+	err = listAll(ctx, store, bucket, key, "", func(obj *storage.Object, _ string) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), maxDeleteBatch)]
+		keys = keys[len(batch):]
+		deleted, errs, err := store.DeleteObjects(ctx, bucket, batch)
+		if err != nil {
+			return fmt.Errorf("failed to delete objects: %w", err)
+		}
+		for _, d := range deleted {
+			fmt.Printf("delete: s3://%s/%s\n", bucket, d.Key)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("failed to delete s3://%s/%s: %s", bucket, errs[0].Key, errs[0].Message)
+		}
+	}
+	return nil
+}
+
+func runCat(cmd *cobra.Command, args []string) error {
+	bucket, key, err := parseObjectURL(args[0])
+	if err != nil {
+		return err
+	}
+
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	obj, err := store.GetObject(context.Background(), bucket, key)
+	if err != nil {
+		return fmt.Errorf("failed to read s3://%s/%s: %w", bucket, key, err)
+	}
+	defer obj.Body.Close()
+
+	_, err = io.Copy(os.Stdout, obj.Body)
+	return err
+}
+
+func runDu(cmd *cobra.Command, args []string) error {
+	var buckets []string
+	if len(args) > 0 {
+		bucket, key, ok := parseBucketURL(args[0])
+		if !ok || bucket == "" || key != "" {
+			return fmt.Errorf("expected a bucket URL (s3://bucket), got %q", args[0])
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if len(buckets) == 0 {
+		list, err := store.ListBuckets(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list buckets: %w", err)
+		}
+		for _, b := range list {
+			buckets = append(buckets, b.Name)
+		}
+	}
+
+	fmt.Printf("%10s %15s %15s %8s %15s %s\n", "OBJECTS", "BYTES", "VERSION BYTES", "UPLOADS", "UPLOAD BYTES", "BUCKET")
+	for _, bucket := range buckets {
+		usage, err := store.GetBucketUsage(ctx, bucket)
+		if err != nil {
+			return fmt.Errorf("failed to get the usage of s3://%s: %w", bucket, err)
+		}
+		fmt.Printf("%10d %15d %15d %8d %15d %s\n", usage.Objects, usage.Bytes, usage.VersionBytes,
+			usage.MultipartUploads, usage.MultipartBytes, bucket)
+	}
+	return nil
+}
+
+// parseObjectURL splits an s3://bucket/key URL naming an object.
+func parseObjectURL(arg string) (bucket, key string, err error) {
+	bucket, key, ok := parseBucketURL(arg)
+	if !ok || bucket == "" || key == "" {
+		return "", "", fmt.Errorf("expected an object URL (s3://bucket/key), got %q", arg)
+	}
+	return bucket, key, nil
+}
+
+// listAll calls fn for every object (with an empty prefix) and common prefix
+// (with a nil object) below prefix, in key order.
+func listAll(ctx context.Context, store storage.Storage, bucket, prefix, delimiter string, fn func(obj *storage.Object, commonPrefix string) error) error {
+	input := &storage.ListObjectsInput{
+		Bucket:    bucket,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		MaxKeys:   1000,
+	}
+	for {
+		output, err := store.ListObjectsV2(ctx, input)
+		if err != nil {
+			return fmt.Errorf("failed to list s3://%s/%s: %w", bucket, prefix, err)
+		}
+
+		// Merge objects and common prefixes in key order
+		objects, prefixes := output.Objects, output.CommonPrefixes
+		for len(objects) > 0 || len(prefixes) > 0 {
+			if len(prefixes) == 0 || (len(objects) > 0 && objects[0].Key < prefixes[0]) {
+				if err := fn(&objects[0], ""); err != nil {
+					return err
+				}
+				objects = objects[1:]
+			} else {
+				if err := fn(nil, prefixes[0]); err != nil {
+					return err
+				}
+				prefixes = prefixes[1:]
+			}
+		}
+
+		if !output.IsTruncated {
+			return nil
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rewrapBucket  string
+	rewrapKeyID   string
+	rewrapToKeyID string
+	rewrapVerbose bool
+)
+
+// NewKMSCmd creates the kms command.
+func NewKMSCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "kms",
+		Short: "Manage the keys of objects encrypted at rest",
+	}
+	cmd.AddCommand(newKMSRewrapCmd())
+	return cmd
+}
+
+func newKMSRewrapCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rewrap",
+		Short: "Wrap the data keys of encrypted objects again",
+		Long: "Unwrap the data keys of encrypted objects and wrap them again with the current\n" +
+			"version of their KMS key, after the key was rotated, or with another key given\n" +
+			"by --to-key-id. Object data is not rewritten. Old key versions can be retired\n" +
+			"once all objects are rewrapped.\n" +
+			inspectLong,
+		Example: "  jog kms rewrap -c config.yaml\n" +
+			"  jog kms rewrap -c config.yaml --bucket my-bucket --key-id old-key --to-key-id new-key",
+		Args: cobra.NoArgs,
+		RunE: runKMSRewrap,
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	cmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data directory")
+	cmd.Flags().StringVar(&rewrapBucket, "bucket", "", "only rewrap objects of this bucket")
+	cmd.Flags().StringVar(&rewrapKeyID, "key-id", "", "only rewrap data keys wrapped with this KMS key")
+	cmd.Flags().StringVar(&rewrapToKeyID, "to-key-id", "", "KMS key to wrap the data keys with instead of their own key")
+	cmd.Flags().BoolVarP(&rewrapVerbose, "verbose", "v", false, "print every rewrapped object")
+
+	return cmd
+}
+
+func runKMSRewrap(cmd *cobra.Command, args []string) error {
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	fs, ok := store.(*storage.FileSystem)
+	if !ok {
+		return fmt.Errorf("encryption at rest requires the filesystem storage backend")
+	}
+
+	var progress func(bucket, key, versionID string)
+	if rewrapVerbose {
+		progress = func(bucket, key, versionID string) {
+			if versionID != "" {
+				fmt.Printf("s3://%s/%s (version %s)\n", bucket, key, versionID)
+			} else {
+				fmt.Printf("s3://%s/%s\n", bucket, key)
+			}
+		}
+	}
+
+	filter := storage.RewrapFilter{Bucket: rewrapBucket, KMSKeyID: rewrapKeyID}
+	count, err := fs.RewrapObjectKeys(context.Background(), filter, rewrapToKeyID, progress)
+	if errors.Is(err, storage.ErrKMSNotConfigured) {
+		return fmt.Errorf("no KMS provider configured (storage.kms.provider)")
+	}
+	if err != nil {
+		return fmt.Errorf("rewrap failed after %d objects: %w", count, err)
+	}
+
+	fmt.Printf("Rewrapped %d data keys\n", count)
+	return nil
+}
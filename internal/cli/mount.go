@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/kumasuke/jog/internal/mount"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mountReadOnly   bool
+	mountAllowOther bool
+)
+
+// NewMountCmd creates the mount command.
+func NewMountCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mount <bucket> <mountpoint>",
+		Short: "Mount a bucket as a FUSE filesystem",
+		Long: "Mount a bucket as a FUSE filesystem backed directly by the storage layer.\n" +
+			"Keys are mapped to paths, and user metadata is exposed as user.* extended attributes.\n" +
+			"The filesystem is unmounted on SIGINT or SIGTERM.",
+		Args: cobra.ExactArgs(2),
+		RunE: runMount,
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	cmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data directory")
+	cmd.Flags().BoolVar(&mountReadOnly, "read-only", false, "mount the bucket read-only")
+	cmd.Flags().BoolVar(&mountAllowOther, "allow-other", false, "allow other users to access the filesystem")
+
+	return cmd
+}
+
+func runMount(cmd *cobra.Command, args []string) error {
+	bucket, mountpoint := args[0], args[1]
+
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	fs, err := mount.NewFS(context.Background(), store, bucket, mount.Options{
+		ReadOnly:   mountReadOnly,
+		AllowOther: mountAllowOther,
+		UID:        uint32(os.Getuid()),
+		GID:        uint32(os.Getgid()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open bucket %s: %w", bucket, err)
+	}
+
+	conn, err := mount.Mount(fs, mountpoint)
+	if err != nil {
+		return fmt.Errorf("failed to mount %s: %w", mountpoint, err)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		if err := conn.Unmount(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to unmount %s: %v\n", mountpoint, err)
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "Mounted %s at %s\n", bucket, mountpoint)
+	return conn.Serve()
+}
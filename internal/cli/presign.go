@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// maxPresignExpires is the longest validity of a Signature V4 presigned URL.
+const maxPresignExpires = 7 * 24 * time.Hour
+
+var (
+	presignEndpoint string
+	presignRegion   string
+	presignExpires  time.Duration
+	presignCurl     bool
+)
+
+// NewPresignCmd creates the presign command.
+func NewPresignCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "presign <get|put> s3://bucket/key",
+		Short: "Generate a presigned URL for an object",
+		Long: "Generate a presigned GET or PUT URL for an object, signed with the credentials of\n" +
+			"the config file (or --access-key and --secret-key). The server does not need to be\n" +
+			"reachable; the URL is valid for --expires, at most 7 days.",
+		Example: "  jog presign get s3://my-bucket/report.pdf --expires 1h\n" +
+			"  jog presign put s3://my-bucket/upload.bin --curl",
+		Args: cobra.ExactArgs(2),
+		RunE: runPresign,
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	cmd.Flags().StringVar(&accessKey, "access-key", "", "access key (default from config)")
+	cmd.Flags().StringVar(&secretKey, "secret-key", "", "secret key (default from config)")
+	cmd.Flags().StringVar(&presignEndpoint, "endpoint", "", "server URL (default http://localhost:<server.port>)")
+	cmd.Flags().StringVar(&presignRegion, "region", "us-east-1", "region of the signature")
+	cmd.Flags().DurationVar(&presignExpires, "expires", time.Hour, "validity of the URL")
+	cmd.Flags().BoolVar(&presignCurl, "curl", false, "print a curl command using the URL instead of the URL")
+
+	return cmd
+}
+
+func runPresign(cmd *cobra.Command, args []string) error {
+	method := strings.ToUpper(args[0])
+	if method != http.MethodGet && method != http.MethodPut {
+		return fmt.Errorf("unknown presign method %q, expected get or put", args[0])
+	}
+	bucket, key, ok := parseBucketURL(args[1])
+	if !ok || bucket == "" || key == "" {
+		return fmt.Errorf("expected an object URL (s3://bucket/key), got %q", args[1])
+	}
+	if presignExpires < time.Second || presignExpires > maxPresignExpires {
+		return fmt.Errorf("--expires must be between 1s and %s", maxPresignExpires)
+	}
+
+	var cfg *config.Config
+	var err error
+	if configFile != "" {
+		cfg, err = config.LoadFromFile(configFile)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if accessKey != "" {
+		cfg.Auth.AccessKey = accessKey
+	}
+	if secretKey != "" {
+		cfg.Auth.SecretKey = secretKey
+	}
+	endpoint := presignEndpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint(cfg.Server)
+	}
+
+	req, err := presignObject(context.Background(), method, endpoint, presignRegion, cfg.Auth.AccessKey, cfg.Auth.SecretKey, bucket, key, presignExpires)
+	if err != nil {
+		return fmt.Errorf("failed to presign: %w", err)
+	}
+
+	if presignCurl {
+		fmt.Println(curlCommand(req, path.Base(key)))
+	} else {
+		fmt.Println(req.URL)
+	}
+	return nil
+}
+
+// defaultEndpoint returns the URL of a server listening with the given
+// settings, using localhost for wildcard addresses.
+func defaultEndpoint(server config.ServerConfig) string {
+	host := server.Address
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(server.Port))
+}
+
+// presignObject presigns a path-style GetObject or PutObject request.
+func presignObject(ctx context.Context, method, endpoint, region, accessKey, secretKey, bucket, key string, expires time.Duration) (*v4.PresignedHTTPRequest, error) {
+	client := s3.New(s3.Options{
+		Region:       region,
+		BaseEndpoint: aws.String(strings.TrimSuffix(endpoint, "/")),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
+	})
+	presigner := s3.NewPresignClient(client, s3.WithPresignExpires(expires))
+
+	if method == http.MethodPut {
+		return presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+	}
+	return presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+}
+
+// curlCommand returns a curl command line downloading a presigned GET into
+// file or uploading file with a presigned PUT, including the signed headers
+// the request must carry.
+func curlCommand(req *v4.PresignedHTTPRequest, file string) string {
+	names := make([]string, 0, len(req.SignedHeader))
+	for name := range req.SignedHeader {
+		if !strings.EqualFold(name, "Host") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	args := []string{"curl", "-f"}
+	for _, name := range names {
+		for _, value := range req.SignedHeader[name] {
+			args = append(args, "-H", shellQuote(name+": "+value))
+		}
+	}
+	if req.Method == http.MethodPut {
+		args = append(args, "-T", shellQuote(file))
+	} else {
+		args = append(args, "-o", shellQuote(file))
+	}
+	args = append(args, shellQuote(req.URL))
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
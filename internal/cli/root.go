@@ -22,6 +22,22 @@ func NewRootCmd() *cobra.Command {
 
 	rootCmd.AddCommand(NewServerCmd())
 	rootCmd.AddCommand(NewVersionCmd())
+	rootCmd.AddCommand(NewBackupCmd())
+	rootCmd.AddCommand(NewRestoreCmd())
+	rootCmd.AddCommand(NewExportCmd())
+	rootCmd.AddCommand(NewImportCmd())
+	rootCmd.AddCommand(NewSyncCmd())
+	rootCmd.AddCommand(NewIngestCmd())
+	rootCmd.AddCommand(NewSeedCmd())
+	rootCmd.AddCommand(NewMountCmd())
+	rootCmd.AddCommand(NewPresignCmd())
+	rootCmd.AddCommand(NewConformanceCmd())
+	rootCmd.AddCommand(NewLsCmd())
+	rootCmd.AddCommand(NewStatCmd())
+	rootCmd.AddCommand(NewRmCmd())
+	rootCmd.AddCommand(NewCatCmd())
+	rootCmd.AddCommand(NewDuCmd())
+	rootCmd.AddCommand(NewKMSCmd())
 
 	return rootCmd
 }
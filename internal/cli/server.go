@@ -20,6 +20,7 @@ var (
 	accessKey  string
 	secretKey  string
 	logLevel   string
+	mode       string
 )
 
 // NewServerCmd creates the server command.
@@ -37,6 +38,7 @@ func NewServerCmd() *cobra.Command {
 	cmd.Flags().StringVar(&accessKey, "access-key", "", "access key")
 	cmd.Flags().StringVar(&secretKey, "secret-key", "", "secret key")
 	cmd.Flags().StringVar(&logLevel, "log-level", "", "log level (debug, info, warn, error)")
+	cmd.Flags().StringVar(&mode, "mode", "", "server mode (normal, read-only, maintenance)")
 
 	return cmd
 }
@@ -71,6 +73,9 @@ func runServer(cmd *cobra.Command, args []string) error {
 	if logLevel != "" {
 		cfg.Logging.Level = logLevel
 	}
+	if mode != "" {
+		cfg.Server.Mode = mode
+	}
 
 	// Setup logging
 	setupLogging(cfg.Logging)
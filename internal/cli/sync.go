@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var syncOptions storage.SyncOptions
+
+// NewSyncCmd creates the sync command.
+func NewSyncCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync <source> <destination>",
+		Short: "Sync a local directory with a bucket",
+		Long: "Copy files between a local directory and a bucket through the storage layer,\n" +
+			"without going through the S3 API. One side must be a bucket URL (s3://bucket/prefix).\n" +
+			"Files whose size and checksum already match are skipped.",
+		Example: "  jog sync ./seed s3://my-bucket/data\n" +
+			"  jog sync s3://my-bucket/data ./restore --exclude '*.tmp'",
+		Args: cobra.ExactArgs(2),
+		RunE: runSync,
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	cmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data directory")
+	cmd.Flags().IntVarP(&syncOptions.Parallel, "parallel", "p", 4, "number of files transferred concurrently")
+	cmd.Flags().StringArrayVar(&syncOptions.Include, "include", nil, "only sync paths matching this pattern (repeatable)")
+	cmd.Flags().StringArrayVar(&syncOptions.Exclude, "exclude", nil, "skip paths matching this pattern (repeatable)")
+	cmd.Flags().BoolVar(&syncOptions.DryRun, "dry-run", false, "show what would be copied without copying")
+
+	return cmd
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	srcBucket, srcPrefix, srcIsBucket := parseBucketURL(args[0])
+	dstBucket, dstPrefix, dstIsBucket := parseBucketURL(args[1])
+	if srcIsBucket == dstIsBucket {
+		return fmt.Errorf("exactly one of source and destination must be a bucket URL (s3://bucket/prefix)")
+	}
+
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	var result *storage.SyncResult
+	if dstIsBucket {
+		result, err = storage.SyncToBucket(ctx, store, args[0], dstBucket, dstPrefix, syncOptions)
+	} else {
+		result, err = storage.SyncFromBucket(ctx, store, srcBucket, srcPrefix, args[1], syncOptions)
+	}
+	if err != nil {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+
+	verb := "Copied"
+	if syncOptions.DryRun {
+		verb = "Would copy"
+	}
+	fmt.Printf("%s %d files (%d bytes), skipped %d unchanged\n", verb, result.Copied, result.Bytes, result.Skipped)
+	return nil
+}
+
+// parseBucketURL splits an s3://bucket/prefix URL. ok is false for local paths.
+func parseBucketURL(arg string) (bucket, prefix string, ok bool) {
+	rest, ok := strings.CutPrefix(arg, "s3://")
+	if !ok {
+		return "", "", false
+	}
+	bucket, prefix, _ = strings.Cut(rest, "/")
+	return bucket, prefix, true
+}
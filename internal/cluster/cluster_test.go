@@ -0,0 +1,575 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+)
+
+func TestRing(t *testing.T) {
+	ring := NewRing([]string{"a", "b", "c"}, 128)
+	owners := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := ObjectKey("bucket", fmt.Sprintf("key-%d", i))
+		nodes := ring.Nodes(key)
+		if len(nodes) != 3 || nodes[0] != ring.Owner(key) {
+			t.Fatalf("Nodes(%q) = %v", key, nodes)
+		}
+		owners[key] = nodes[0]
+		counts[nodes[0]]++
+	}
+	for node, n := range counts {
+		if n < 500 {
+			t.Errorf("node %s owns %d of 3000 keys", node, n)
+		}
+	}
+
+	// Keys only move to a joining node
+	grown := NewRing([]string{"a", "b", "c", "d"}, 128)
+	moved := 0
+	for key, owner := range owners {
+		if now := grown.Owner(key); now != owner {
+			if now != "d" {
+				t.Fatalf("key %q moved from %s to %s", key, owner, now)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 1200 {
+		t.Errorf("%d of 3000 keys moved to the new node", moved)
+	}
+
+	if owner := NewRing(nil, 128).Owner("key"); owner != "" {
+		t.Errorf("Owner on empty ring = %q", owner)
+	}
+}
+
+// testNode is a cluster node served on a test server. Local requests are
+// served by store.
+type testNode struct {
+	node   *Node
+	server *httptest.Server
+	store  *fakeStore
+}
+
+func startNodes(t *testing.T, ids ...string) []*testNode {
+	t.Helper()
+	var nodes []*testNode
+	for _, id := range ids {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var seeds []string
+		if len(nodes) > 0 {
+			seeds = []string{nodes[0].server.URL}
+		}
+		node, err := NewNode(Config{ID: id, URL: "http://" + ln.Addr().String(), Seeds: seeds, Secret: "secret", GossipInterval: time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tn := &testNode{node: node, store: newFakeStore()}
+		tn.server = &httptest.Server{Listener: ln, Config: &http.Server{Handler: NewHandler(node, tn.store)}}
+		tn.server.Start()
+		t.Cleanup(tn.server.Close)
+		nodes = append(nodes, tn)
+	}
+
+	// Every node learns of every other node within a few rounds
+	for round := 0; round < 3; round++ {
+		for _, tn := range nodes {
+			tn.node.gossip()
+		}
+	}
+	for _, tn := range nodes {
+		if up := tn.node.Up(); len(up) != len(ids) {
+			t.Fatalf("node %s sees %v up", tn.node.ID(), up)
+		}
+	}
+	return nodes
+}
+
+func TestGossip(t *testing.T) {
+	nodes := startNodes(t, "a", "b", "c")
+	members := nodes[2].node.Members()
+	if len(members) != 3 || members[0].ID != "a" || !members[0].Up || members[0].URL != nodes[0].server.URL {
+		t.Errorf("Members = %+v", members)
+	}
+
+	// Gossip signed with another secret is rejected
+	resp, err := http.Post(nodes[0].server.URL+GossipPath, "application/json", strings.NewReader(`{"members":[{"id":"x","url":"http://x"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || slices.Contains(nodes[0].node.Up(), "x") {
+		t.Errorf("unsigned gossip: status %d, up %v", resp.StatusCode, nodes[0].node.Up())
+	}
+
+	// A node that is not heard of is removed from the ring
+	nodes[1].node.cfg.FailureTimeout = time.Nanosecond
+	nodes[1].node.expire()
+	if up := nodes[1].node.Up(); len(up) != 1 || up[0] != "b" {
+		t.Errorf("Up after failure timeout = %v", up)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	nodes := startNodes(t, "a", "b")
+	a, b := nodes[0], nodes[1]
+
+	do := func(method, url string, body string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	// Bucket requests are applied on every node
+	if status, _ := do(http.MethodPut, a.server.URL+"/photos", ""); status != http.StatusOK {
+		t.Fatalf("CreateBucket status = %d", status)
+	}
+	if !a.store.buckets["photos"] || !b.store.buckets["photos"] {
+		t.Fatalf("bucket not created on every node")
+	}
+
+	// Objects are stored on their owner and readable through any node
+	var keys []string
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("img/%02d.jpg", i)
+		keys = append(keys, key)
+		if status, _ := do(http.MethodPut, b.server.URL+"/photos/"+key, "data-"+key); status != http.StatusOK {
+			t.Fatalf("PutObject(%s) status = %d", key, status)
+		}
+		owner := a.node.Ring().Owner(ObjectKey("photos", key))
+		for _, tn := range nodes {
+			if _, ok := tn.store.object("photos/" + key); ok != (tn.node.ID() == owner) {
+				t.Errorf("object %s on node %s: %v, owner %s", key, tn.node.ID(), ok, owner)
+			}
+		}
+	}
+	if a.store.count() == 0 || b.store.count() == 0 {
+		t.Fatalf("objects not spread over the nodes: %d, %d", a.store.count(), b.store.count())
+	}
+	for _, key := range keys {
+		if status, body := do(http.MethodGet, a.server.URL+"/photos/"+key, ""); status != http.StatusOK || body != "data-"+key {
+			t.Errorf("GetObject(%s) = %d %q", key, status, body)
+		}
+	}
+	if status, _ := do(http.MethodGet, a.server.URL+"/photos/missing", ""); status != http.StatusNotFound {
+		t.Errorf("GetObject(missing) status = %d", status)
+	}
+
+	// Objects on a node that no longer owns them are still found
+	a.store.put("photos/moved.jpg", "moved")
+	b.store.put("photos/moved2.jpg", "moved")
+	for _, key := range []string{"moved.jpg", "moved2.jpg"} {
+		for _, tn := range nodes {
+			if status, _ := do(http.MethodGet, tn.server.URL+"/photos/"+key, ""); status != http.StatusOK {
+				t.Errorf("GetObject(%s) through %s status = %d", key, tn.node.ID(), status)
+			}
+		}
+	}
+	keys = append(keys, "moved.jpg", "moved2.jpg")
+	slices.Sort(keys)
+
+	// Listings merge the objects of every node
+	var listed []string
+	token := ""
+	for page := 0; ; page++ {
+		_, body := do(http.MethodGet, b.server.URL+"/photos?list-type=2&max-keys=5&continuation-token="+token, "")
+		var result api.ListBucketResult
+		if err := xml.Unmarshal([]byte(body), &result); err != nil {
+			t.Fatalf("invalid listing %q: %v", body, err)
+		}
+		if len(result.Contents) > 5 || int(result.KeyCount) != len(result.Contents)+len(result.CommonPrefixes) {
+			t.Fatalf("page %d has %d objects, KeyCount %d", page, len(result.Contents), result.KeyCount)
+		}
+		for _, obj := range result.Contents {
+			listed = append(listed, obj.Key)
+		}
+		if !result.IsTruncated {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	if !slices.Equal(listed, keys) {
+		t.Errorf("listed %v, want %v", listed, keys)
+	}
+
+	_, body := do(http.MethodGet, a.server.URL+"/photos?delimiter=/", "")
+	var v1 api.ListBucketResultV1
+	if err := xml.Unmarshal([]byte(body), &v1); err != nil {
+		t.Fatal(err)
+	}
+	if len(v1.CommonPrefixes) != 1 || v1.CommonPrefixes[0].Prefix != "img/" || len(v1.Contents) != 2 {
+		t.Errorf("ListObjects with delimiter = %+v", v1)
+	}
+
+	// Deletes are applied on every node
+	a.store.put("photos/img/00.jpg", "stale")
+	b.store.put("photos/img/00.jpg", "stale")
+	if status, _ := do(http.MethodDelete, a.server.URL+"/photos/img/00.jpg", ""); status != http.StatusNoContent {
+		t.Errorf("DeleteObject status = %d", status)
+	}
+	for _, tn := range nodes {
+		if _, ok := tn.store.object("photos/img/00.jpg"); ok {
+			t.Errorf("object not deleted on node %s", tn.node.ID())
+		}
+	}
+}
+
+// doRequest sends a request and returns the response status and body.
+func doRequest(t *testing.T, method, url string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestReplicationFailures(t *testing.T) {
+	nodes := startNodes(t, "a", "b")
+	a, b := nodes[0], nodes[1]
+
+	// A bucket change fails unless every node applies it
+	b.store.failBuckets = true
+	if status, _ := doRequest(t, http.MethodPut, a.server.URL+"/photos"); status != http.StatusInternalServerError {
+		t.Errorf("CreateBucket failing on another node: status %d", status)
+	}
+	if !a.store.buckets["photos"] || b.store.buckets["photos"] {
+		t.Fatalf("bucket created on a: %v, b: %v", a.store.buckets["photos"], b.store.buckets["photos"])
+	}
+
+	// A retry applies it on the rest of the nodes
+	b.store.failBuckets = false
+	if status, body := doRequest(t, http.MethodPut, a.server.URL+"/photos"); status != http.StatusConflict || !strings.Contains(body, "BucketAlreadyOwnedByYou") {
+		t.Errorf("CreateBucket retry = %d %q", status, body)
+	}
+	if !b.store.buckets["photos"] {
+		t.Error("retried CreateBucket not applied on b")
+	}
+
+	// A node that cannot be reached fails the request
+	b.server.Close()
+	if status, _ := doRequest(t, http.MethodPut, a.server.URL+"/videos"); status != http.StatusBadGateway {
+		t.Errorf("CreateBucket with an unreachable node: status %d", status)
+	}
+
+	// Nothing is applied while a node is down
+	a.node.mu.Lock()
+	a.node.members["b"].seen = time.Now().Add(-2 * a.node.cfg.FailureTimeout)
+	a.node.mu.Unlock()
+	if status, _ := doRequest(t, http.MethodPut, a.server.URL+"/music"); status != http.StatusServiceUnavailable {
+		t.Errorf("CreateBucket with a node down: status %d", status)
+	}
+	if a.store.buckets["music"] {
+		t.Error("bucket created while a node is down")
+	}
+}
+
+func TestVersionAndUploadListings(t *testing.T) {
+	nodes := startNodes(t, "a", "b")
+	a, b := nodes[0], nodes[1]
+
+	// The versions of doc were written on both nodes
+	a.store.versions["photos"] = []api.VersionInfo{
+		{Key: "doc", VersionId: "1", LastModified: "2026-01-01T00:00:01Z"},
+		{Key: "doc", VersionId: "3", LastModified: "2026-01-01T00:00:03Z"},
+		{Key: "img", VersionId: "5", LastModified: "2026-01-01T00:00:05Z"},
+	}
+	b.store.versions["photos"] = []api.VersionInfo{
+		{Key: "doc", VersionId: "2", LastModified: "2026-01-01T00:00:02Z"},
+		{Key: "fig", VersionId: "4", LastModified: "2026-01-01T00:00:04Z"},
+	}
+	_, body := doRequest(t, http.MethodGet, b.server.URL+"/photos?versions")
+	var versions api.ListVersionsResult
+	if err := xml.Unmarshal([]byte(body), &versions); err != nil {
+		t.Fatalf("invalid listing %q: %v", body, err)
+	}
+	var listed []string
+	for _, v := range versions.Versions {
+		listed = append(listed, fmt.Sprintf("%s/%s/%v", v.Key, v.VersionId, v.IsLatest))
+	}
+	if want := []string{"doc/3/true", "doc/2/false", "doc/1/false", "fig/4/true", "img/5/true"}; !slices.Equal(listed, want) || versions.IsTruncated {
+		t.Errorf("ListObjectVersions = %v, truncated %v, want %v", listed, versions.IsTruncated, want)
+	}
+
+	// Pages continue after the last listed version
+	_, body = doRequest(t, http.MethodGet, a.server.URL+"/photos?versions&max-keys=4")
+	versions = api.ListVersionsResult{}
+	if err := xml.Unmarshal([]byte(body), &versions); err != nil {
+		t.Fatal(err)
+	}
+	if len(versions.Versions) != 4 || !versions.IsTruncated || versions.NextKeyMarker != "fig" || versions.NextVersionIdMarker != "4" {
+		t.Errorf("first page = %+v", versions)
+	}
+	_, body = doRequest(t, http.MethodGet, a.server.URL+"/photos?versions&max-keys=4&key-marker=fig&version-id-marker=4")
+	versions = api.ListVersionsResult{}
+	if err := xml.Unmarshal([]byte(body), &versions); err != nil {
+		t.Fatal(err)
+	}
+	if len(versions.Versions) != 1 || versions.Versions[0].Key != "img" || versions.IsTruncated {
+		t.Errorf("second page = %+v", versions)
+	}
+
+	// Uploads of every node are listed in key and upload ID order
+	var want []string
+	for i := 0; i < 7; i++ {
+		upload := api.UploadInfo{Key: fmt.Sprintf("big-%d.bin", i%3), UploadId: fmt.Sprintf("upload-%d", i)}
+		want = append(want, upload.Key+"/"+upload.UploadId)
+		nodes[i%2].store.uploads["photos"] = append(nodes[i%2].store.uploads["photos"], upload)
+	}
+	slices.Sort(want)
+	var uploads []string
+	keyMarker, uploadMarker := "", ""
+	for page := 0; ; page++ {
+		_, body := doRequest(t, http.MethodGet, a.server.URL+"/photos?uploads&max-uploads=3&key-marker="+keyMarker+"&upload-id-marker="+uploadMarker)
+		var result api.ListMultipartUploadsResult
+		if err := xml.Unmarshal([]byte(body), &result); err != nil {
+			t.Fatalf("invalid listing %q: %v", body, err)
+		}
+		if len(result.Uploads) > 3 {
+			t.Fatalf("page %d has %d uploads", page, len(result.Uploads))
+		}
+		for _, u := range result.Uploads {
+			uploads = append(uploads, u.Key+"/"+u.UploadId)
+		}
+		if !result.IsTruncated {
+			break
+		}
+		keyMarker, uploadMarker = result.NextKeyMarker, result.NextUploadIdMarker
+	}
+	if !slices.Equal(uploads, want) {
+		t.Errorf("listed uploads %v, want %v", uploads, want)
+	}
+}
+
+func TestParseCopySource(t *testing.T) {
+	tests := []struct {
+		source, bucket, key string
+		ok                  bool
+	}{
+		{"/photos/a%20b.jpg", "photos", "a b.jpg", true},
+		{"photos/dir/a.jpg?versionId=1", "photos", "dir/a.jpg", true},
+		{"photos", "", "", false},
+	}
+	for _, tt := range tests {
+		bucket, key, ok := parseCopySource(tt.source)
+		if ok != tt.ok || (ok && (bucket != tt.bucket || key != tt.key)) {
+			t.Errorf("parseCopySource(%q) = %q, %q, %v", tt.source, bucket, key, ok)
+		}
+	}
+}
+
+// fakeStore serves buckets, objects and their listings from memory.
+type fakeStore struct {
+	mu       sync.Mutex
+	buckets  map[string]bool
+	objects  map[string]string
+	versions map[string][]api.VersionInfo
+	uploads  map[string][]api.UploadInfo
+	// failBuckets makes bucket changes fail
+	failBuckets bool
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		buckets:  make(map[string]bool),
+		objects:  make(map[string]string),
+		versions: make(map[string][]api.VersionInfo),
+		uploads:  make(map[string][]api.UploadInfo),
+	}
+}
+
+func (s *fakeStore) put(key, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = data
+}
+
+func (s *fakeStore) object(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	return data, ok
+}
+
+func (s *fakeStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.objects)
+}
+
+func (s *fakeStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case key == "" && r.Method == http.MethodPut:
+		if s.failBuckets {
+			http.Error(w, "InternalError", http.StatusInternalServerError)
+			return
+		}
+		if s.buckets[bucket] {
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, "<Error><Code>BucketAlreadyOwnedByYou</Code></Error>")
+			return
+		}
+		s.buckets[bucket] = true
+	case key == "" && r.Method == http.MethodGet && r.URL.Query().Has("versions"):
+		s.listVersions(w, r, bucket)
+	case key == "" && r.Method == http.MethodGet && r.URL.Query().Has("uploads"):
+		s.listUploads(w, r, bucket)
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r, bucket)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[bucket+"/"+key] = string(data)
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[bucket+"/"+key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, data)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, bucket+"/"+key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *fakeStore) list(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	maxKeys := 1000
+	fmt.Sscan(query.Get("max-keys"), &maxKeys)
+	token, _ := base64.RawURLEncoding.DecodeString(query.Get("continuation-token"))
+	after := string(token) + query.Get("marker")
+	delimiter := query.Get("delimiter")
+
+	var keys []string
+	for name := range s.objects {
+		if key, ok := strings.CutPrefix(name, bucket+"/"); ok && key > after {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var contents []api.ObjectInfo
+	var prefixes []api.CommonPrefix
+	truncated := false
+	for _, key := range keys {
+		var prefix api.CommonPrefix
+		if i := strings.Index(key, delimiter); delimiter != "" && i >= 0 {
+			prefix = api.CommonPrefix{Prefix: key[:i+1]}
+			if slices.Contains(prefixes, prefix) {
+				continue
+			}
+		}
+		if len(contents)+len(prefixes) == maxKeys {
+			truncated = true
+			break
+		}
+		if prefix.Prefix != "" {
+			prefixes = append(prefixes, prefix)
+		} else {
+			contents = append(contents, api.ObjectInfo{Key: key})
+		}
+	}
+
+	var buf bytes.Buffer
+	if query.Get("list-type") == "2" {
+		xml.NewEncoder(&buf).Encode(api.ListBucketResult{Name: bucket, IsTruncated: truncated, KeyCount: int32(len(contents) + len(prefixes)), Contents: contents, CommonPrefixes: prefixes})
+	} else {
+		xml.NewEncoder(&buf).Encode(api.ListBucketResultV1{Name: bucket, IsTruncated: truncated, Contents: contents, CommonPrefixes: prefixes})
+	}
+	w.Write(buf.Bytes())
+}
+
+// listVersions lists versions by key and newest first. Like the metadata
+// database, it continues after the version marker, or after its key if the
+// marker is not one of its versions.
+func (s *fakeStore) listVersions(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	maxKeys := 1000
+	fmt.Sscan(query.Get("max-keys"), &maxKeys)
+	versions := slices.Clone(s.versions[bucket])
+	slices.SortFunc(versions, func(a, b api.VersionInfo) int {
+		return compareVersions(versionEntry{key: a.Key, lastModified: a.LastModified, versionID: a.VersionId},
+			versionEntry{key: b.Key, lastModified: b.LastModified, versionID: b.VersionId})
+	})
+	keyMarker, versionMarker := query.Get("key-marker"), query.Get("version-id-marker")
+	markerAt := slices.IndexFunc(versions, func(v api.VersionInfo) bool {
+		return versionMarker != "" && v.Key == keyMarker && v.VersionId == versionMarker
+	})
+
+	result := api.ListVersionsResult{Name: bucket}
+	for i, v := range versions {
+		v.IsLatest = i == 0 || versions[i-1].Key != v.Key
+		if v.Key < keyMarker || (v.Key == keyMarker && (markerAt < 0 || i <= markerAt)) {
+			continue
+		}
+		if len(result.Versions) == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		result.Versions = append(result.Versions, v)
+	}
+	var buf bytes.Buffer
+	xml.NewEncoder(&buf).Encode(result)
+	w.Write(buf.Bytes())
+}
+
+// listUploads lists uploads by key and upload ID.
+func (s *fakeStore) listUploads(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	maxUploads := 1000
+	fmt.Sscan(query.Get("max-uploads"), &maxUploads)
+	keyMarker, uploadMarker := query.Get("key-marker"), query.Get("upload-id-marker")
+
+	var uploads []api.UploadInfo
+	for _, u := range s.uploads[bucket] {
+		if u.Key > keyMarker || (u.Key == keyMarker && u.UploadId > uploadMarker) {
+			uploads = append(uploads, u)
+		}
+	}
+	slices.SortFunc(uploads, func(a, b api.UploadInfo) int {
+		return compareUploads(uploadEntry{key: a.Key, uploadID: a.UploadId}, uploadEntry{key: b.Key, uploadID: b.UploadId})
+	})
+	result := api.ListMultipartUploadsResult{Bucket: bucket, Uploads: uploads}
+	if len(uploads) > maxUploads {
+		result.Uploads, result.IsTruncated = uploads[:maxUploads], true
+	}
+	var buf bytes.Buffer
+	xml.NewEncoder(&buf).Encode(result)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,340 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ForwardedHeader marks requests forwarded by another node, which are
+	// served locally. It holds the ID of the forwarding node.
+	ForwardedHeader = "X-Jog-Cluster-Forwarded"
+	// maxReplicatedBody bounds the body of bucket requests replayed on every node.
+	maxReplicatedBody = 16 << 20
+)
+
+// hopHeaders are connection-specific headers that are not forwarded.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Handler routes S3 requests to the nodes of a cluster:
+//
+//   - object requests are served by the node owning the bucket and key on the
+//     ring. Reads fall back to the other nodes if the owner does not have the
+//     object, e.g. because it was written before a node joined;
+//   - CopyObject is served by the owner of the source object;
+//   - DeleteObject and requests changing a bucket, including DeleteObjects,
+//     are applied on every node and fail unless they succeed on every node;
+//   - ListObjects, ListObjectsV2, ListObjectVersions and ListMultipartUploads
+//     list every node and merge the results;
+//   - other requests are served locally.
+//
+// Requests are forwarded as is, so the receiving node verifies the client's
+// signature again.
+type Handler struct {
+	node   *Node
+	next   http.Handler
+	client *http.Client
+}
+
+// NewHandler creates a Handler serving local requests with next.
+func NewHandler(node *Node, next http.Handler) *Handler {
+	return &Handler{
+		node: node,
+		next: next,
+		client: &http.Client{
+			// Redirects are returned to the client
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// ServeHTTP routes a request.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == GossipPath {
+		h.node.ServeGossip(w, r)
+		return
+	}
+	if r.Header.Get(ForwardedHeader) != "" || strings.HasPrefix(r.URL.Path, "/_jog/") {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	switch {
+	case bucket == "":
+		h.next.ServeHTTP(w, r)
+	case key == "":
+		if r.Method == http.MethodGet && isListObjects(query) {
+			h.listObjects(w, r, query)
+		} else if r.Method == http.MethodGet && isListing(query, "versions", versionListParams) {
+			h.listObjectVersions(w, r, query)
+		} else if r.Method == http.MethodGet && isListing(query, "uploads", uploadListParams) {
+			h.listMultipartUploads(w, r, query)
+		} else if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			h.next.ServeHTTP(w, r)
+		} else {
+			h.replicate(w, r)
+		}
+	case r.Method == http.MethodDelete && !query.Has("uploadId") && !query.Has("tagging"):
+		h.replicate(w, r)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		h.read(w, r, h.node.Ring().Nodes(ObjectKey(bucket, key)))
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "" && !query.Has("uploadId"):
+		owner := h.node.Ring().Owner(ObjectKey(bucket, key))
+		if srcBucket, srcKey, ok := parseCopySource(r.Header.Get("X-Amz-Copy-Source")); ok {
+			owner = h.node.Ring().Owner(ObjectKey(srcBucket, srcKey))
+		}
+		h.serveOn(w, r, owner)
+	default:
+		h.serveOn(w, r, h.node.Ring().Owner(ObjectKey(bucket, key)))
+	}
+}
+
+// serveOn serves a request on a node.
+func (h *Handler) serveOn(w http.ResponseWriter, r *http.Request, id string) {
+	if id == h.node.ID() {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	resp, err := h.forward(r, id, r.Body)
+	if err != nil {
+		log.Error().Err(err).Str("node", id).Msg("Failed to forward request")
+		http.Error(w, "cluster node unavailable", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	writeResponse(w, resp)
+}
+
+// read serves a read request on the first of the nodes that has the object.
+func (h *Handler) read(w http.ResponseWriter, r *http.Request, nodes []string) {
+	for i, id := range nodes {
+		last := i == len(nodes)-1
+		if id == h.node.ID() {
+			if last {
+				h.next.ServeHTTP(w, r)
+				return
+			}
+			local := newBufferedResponse()
+			h.next.ServeHTTP(local, r)
+			if local.status != http.StatusNotFound {
+				local.writeTo(w)
+				return
+			}
+			continue
+		}
+
+		resp, err := h.forward(r, id, nil)
+		if err != nil {
+			log.Warn().Err(err).Str("node", id).Msg("Failed to forward read request")
+			if last {
+				http.Error(w, "cluster node unavailable", http.StatusBadGateway)
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusNotFound || last {
+			writeResponse(w, resp)
+			resp.Body.Close()
+			return
+		}
+		resp.Body.Close()
+	}
+}
+
+// replicate applies a request locally and then on every other known node,
+// so that the buckets of the nodes do not diverge. Nodes that are down are
+// known until they are forgotten, and the request is rejected while one is,
+// rather than applied on the other nodes only. The client receives the local
+// response if the request is applied on every node, and otherwise the
+// response of the first node it failed on. A request that failed on another
+// node stays applied on the nodes it succeeded on; retrying it applies it on
+// the rest.
+func (h *Handler) replicate(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReplicatedBody+1))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxReplicatedBody {
+		http.Error(w, "request body too large to replicate", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var others []string
+	for _, member := range h.node.Members() {
+		if !member.Up {
+			log.Error().Str("node", member.ID).Str("path", r.URL.Path).Msg("Cannot replicate request while a node is down")
+			http.Error(w, "cluster node "+member.ID+" is down", http.StatusServiceUnavailable)
+			return
+		}
+		if member.ID != h.node.ID() {
+			others = append(others, member.ID)
+		}
+	}
+
+	local := newBufferedResponse()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	h.next.ServeHTTP(local, r)
+	if !applied(r, local) {
+		local.writeTo(w)
+		return
+	}
+
+	responses := make([]*bufferedResponse, len(others))
+	var wg sync.WaitGroup
+	for i, id := range others {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = h.fetch(r, id, bytes.NewReader(body))
+		}()
+	}
+	wg.Wait()
+	for i, resp := range responses {
+		if !applied(r, resp) {
+			log.Error().Str("node", others[i]).Str("path", r.URL.Path).Int("status", resp.status).Msg("Replicated request failed")
+			resp.writeTo(w)
+			return
+		}
+	}
+	local.writeTo(w)
+}
+
+// applied reports whether the response of a node to a replicated request
+// means that the change is in place on the node: the request succeeded, a
+// DELETE found nothing to delete, or CreateBucket found the bucket, as when a
+// failed request is retried.
+func applied(r *http.Request, resp *bufferedResponse) bool {
+	switch {
+	case resp.status < http.StatusMultipleChoices:
+		return true
+	case resp.status == http.StatusNotFound:
+		return r.Method == http.MethodDelete
+	case resp.status == http.StatusConflict:
+		return r.Method == http.MethodPut && r.URL.RawQuery == "" &&
+			bytes.Contains(resp.body.Bytes(), []byte("<Code>BucketAlreadyOwnedByYou</Code>"))
+	}
+	return false
+}
+
+// forward sends a request to another node with the given body.
+func (h *Handler) forward(r *http.Request, id string, body io.Reader) (*http.Response, error) {
+	base := h.node.URL(id)
+	if base == "" {
+		return nil, fmt.Errorf("unknown cluster node %s", id)
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, base+r.URL.RequestURI(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	for _, name := range hopHeaders {
+		req.Header.Del(name)
+	}
+	// The signature covers the host the client sent the request to
+	req.Host = r.Host
+	if body == r.Body {
+		req.ContentLength = r.ContentLength
+	}
+	req.Header.Set(ForwardedHeader, h.node.ID())
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		req.Header.Set("X-Forwarded-For", ip)
+	}
+	return h.client.Do(req)
+}
+
+// fetch sends a request to another node with the given body and records the
+// response. A node that cannot be reached gets a 502 response.
+func (h *Handler) fetch(r *http.Request, id string, body io.Reader) *bufferedResponse {
+	resp := newBufferedResponse()
+	remote, err := h.forward(r, id, body)
+	if err != nil {
+		log.Error().Err(err).Str("node", id).Str("path", r.URL.Path).Msg("Failed to forward request")
+		resp.status = http.StatusBadGateway
+		resp.body.WriteString("cluster node unavailable\n")
+		return resp
+	}
+	defer remote.Body.Close()
+	resp.header = remote.Header.Clone()
+	resp.status = remote.StatusCode
+	if _, err := io.Copy(&resp.body, remote.Body); err != nil {
+		resp.status = http.StatusBadGateway
+		resp.body = bytes.Buffer{}
+	}
+	return resp
+}
+
+// writeResponse copies the response of another node to the client.
+func writeResponse(w http.ResponseWriter, resp *http.Response) {
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	for _, name := range hopHeaders {
+		w.Header().Del(name)
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// parseCopySource returns the bucket and key of an x-amz-copy-source header.
+func parseCopySource(source string) (string, string, bool) {
+	source, _, _ = strings.Cut(source, "?")
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return "", "", false
+	}
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	return bucket, key, ok && bucket != "" && key != ""
+}
+
+// bufferedResponse records the response of a local request, so it can be
+// inspected before it is sent to the client.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header)}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	// Informational responses, such as 100 Continue, are not recorded
+	if b.status == 0 && status >= 200 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// writeTo sends the recorded response.
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
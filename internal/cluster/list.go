@@ -0,0 +1,421 @@
+package cluster
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/rs/zerolog/log"
+)
+
+// listParams are the query parameters of ListObjects and ListObjectsV2.
+var listParams = []string{
+	"list-type", "prefix", "delimiter", "max-keys", "marker",
+	"continuation-token", "start-after", "encoding-type", "fetch-owner",
+	"tag-key", "tag-value",
+}
+
+// isListObjects reports whether a bucket GET request is ListObjects or
+// ListObjectsV2 rather than a bucket subresource.
+func isListObjects(query url.Values) bool {
+	for name := range query {
+		if !slices.Contains(listParams, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// versionListParams are the query parameters of ListObjectVersions.
+var versionListParams = []string{
+	"versions", "prefix", "delimiter", "max-keys", "key-marker",
+	"version-id-marker", "encoding-type",
+}
+
+// uploadListParams are the query parameters of ListMultipartUploads.
+var uploadListParams = []string{
+	"uploads", "prefix", "delimiter", "max-uploads", "key-marker",
+	"upload-id-marker", "encoding-type",
+}
+
+// isListing reports whether a bucket GET request is the listing of a
+// subresource, such as ?versions, rather than another bucket subresource.
+func isListing(query url.Values, subresource string, params []string) bool {
+	if !query.Has(subresource) {
+		return false
+	}
+	for name := range query {
+		if !slices.Contains(params, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// listPage is the listing of one node.
+type listPage struct {
+	contents  []api.ObjectInfo
+	prefixes  []api.CommonPrefix
+	truncated bool
+}
+
+// listNodes sends a listing request to every node and decodes the listings
+// into pages of type T. If a node fails, its response is sent to the client
+// and ok is false.
+func listNodes[T any](h *Handler, w http.ResponseWriter, r *http.Request) (pages []T, ok bool) {
+	nodes := h.node.Up()
+	responses := make([]*bufferedResponse, len(nodes))
+	var wg sync.WaitGroup
+	for i, id := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = h.list(r, id)
+		}()
+	}
+	wg.Wait()
+
+	for _, resp := range responses {
+		if resp.status != http.StatusOK {
+			resp.writeTo(w)
+			return nil, false
+		}
+	}
+	pages = make([]T, len(responses))
+	for i, resp := range responses {
+		if err := xml.Unmarshal(resp.body.Bytes(), &pages[i]); err != nil {
+			log.Error().Err(err).Str("node", nodes[i]).Msg("Failed to decode cluster listing")
+			http.Error(w, "invalid cluster listing", http.StatusBadGateway)
+			return nil, false
+		}
+	}
+	return pages, true
+}
+
+// listLimit returns the page size of a listing. Nodes validated the
+// parameter and cap it like S3.
+func listLimit(query url.Values, name string) int {
+	limit := 1000
+	if n, err := strconv.Atoi(query.Get(name)); err == nil {
+		limit = min(n, 1000)
+	}
+	return limit
+}
+
+// writeListing sends a merged listing to the client.
+func writeListing(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(result); err != nil {
+		log.Error().Err(err).Msg("Failed to encode cluster listing")
+	}
+}
+
+// listObjects sends a listing request to every node and merges the results.
+func (h *Handler) listObjects(w http.ResponseWriter, r *http.Request, query url.Values) {
+	maxKeys := listLimit(query, "max-keys")
+	if query.Get("list-type") == "2" {
+		pages, ok := listNodes[api.ListBucketResult](h, w, r)
+		if !ok {
+			return
+		}
+		nodePages := make([]listPage, len(pages))
+		for i, page := range pages {
+			nodePages[i] = listPage{contents: page.Contents, prefixes: page.CommonPrefixes, truncated: page.IsTruncated}
+		}
+		merged := pages[len(pages)-1]
+		merged.Contents, merged.CommonPrefixes, merged.IsTruncated = mergePages(nodePages, maxKeys)
+		merged.KeyCount = int32(len(merged.Contents) + len(merged.CommonPrefixes))
+		merged.NextContinuationToken = ""
+		if merged.IsTruncated {
+			merged.NextContinuationToken = api.EncodeContinuationToken(lastKey(merged.Contents, merged.CommonPrefixes))
+		}
+		writeListing(w, merged)
+		return
+	}
+
+	pages, ok := listNodes[api.ListBucketResultV1](h, w, r)
+	if !ok {
+		return
+	}
+	nodePages := make([]listPage, len(pages))
+	for i, page := range pages {
+		nodePages[i] = listPage{contents: page.Contents, prefixes: page.CommonPrefixes, truncated: page.IsTruncated}
+	}
+	merged := pages[len(pages)-1]
+	merged.Contents, merged.CommonPrefixes, merged.IsTruncated = mergePages(nodePages, maxKeys)
+	merged.NextMarker = ""
+	if merged.IsTruncated {
+		merged.NextMarker = lastKey(merged.Contents, merged.CommonPrefixes)
+	}
+	writeListing(w, merged)
+}
+
+// list runs a listing request on a node and records the response.
+func (h *Handler) list(r *http.Request, id string) *bufferedResponse {
+	if id == h.node.ID() {
+		resp := newBufferedResponse()
+		h.next.ServeHTTP(resp, r)
+		if resp.status == 0 {
+			resp.status = http.StatusOK
+		}
+		return resp
+	}
+	return h.fetch(r, id, nil)
+}
+
+// mergePages merges the listings of the nodes into a single page of at most
+// maxKeys entries, counting objects and common prefixes alike as S3 does. The
+// page is truncated if any node had more entries or the nodes returned more
+// than maxKeys entries together.
+func mergePages(pages []listPage, maxKeys int) ([]api.ObjectInfo, []api.CommonPrefix, bool) {
+	var contents []api.ObjectInfo
+	var prefixes []api.CommonPrefix
+	seenKeys := make(map[string]bool)
+	seenPrefixes := make(map[string]bool)
+	truncated := false
+	// bound is the smallest last entry of the truncated node pages; entries
+	// after it may be missing and are listed on the next page
+	bound, bounded := "", false
+	for _, page := range pages {
+		if page.truncated {
+			truncated = true
+			if last := lastKey(page.contents, page.prefixes); !bounded || last < bound {
+				bound, bounded = last, true
+			}
+		}
+		for _, obj := range page.contents {
+			if !seenKeys[obj.Key] {
+				seenKeys[obj.Key] = true
+				contents = append(contents, obj)
+			}
+		}
+		for _, prefix := range page.prefixes {
+			if !seenPrefixes[prefix.Prefix] {
+				seenPrefixes[prefix.Prefix] = true
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+	slices.SortFunc(contents, func(a, b api.ObjectInfo) int { return strings.Compare(a.Key, b.Key) })
+	slices.SortFunc(prefixes, func(a, b api.CommonPrefix) int { return strings.Compare(a.Prefix, b.Prefix) })
+
+	if bounded {
+		contents = slices.DeleteFunc(contents, func(obj api.ObjectInfo) bool { return obj.Key > bound })
+		prefixes = slices.DeleteFunc(prefixes, func(p api.CommonPrefix) bool { return p.Prefix > bound })
+	}
+	if len(contents)+len(prefixes) > maxKeys {
+		// Keep the first maxKeys entries in key order
+		i, j := 0, 0
+		for i+j < max(maxKeys, 0) {
+			if j == len(prefixes) || (i < len(contents) && contents[i].Key < prefixes[j].Prefix) {
+				i++
+			} else {
+				j++
+			}
+		}
+		contents, prefixes = contents[:i], prefixes[:j]
+		truncated = true
+	}
+	return contents, prefixes, truncated
+}
+
+// lastKey returns the key the next page of a truncated listing starts after.
+func lastKey(contents []api.ObjectInfo, prefixes []api.CommonPrefix) string {
+	last := ""
+	if len(contents) > 0 {
+		last = contents[len(contents)-1].Key
+	}
+	if len(prefixes) > 0 && prefixes[len(prefixes)-1].Prefix > last {
+		last = prefixes[len(prefixes)-1].Prefix
+	}
+	return last
+}
+
+// mergeSorted merges the sorted entries of the listings of the nodes into a
+// single page of at most limit entries, like mergePages. Entries that compare
+// equal, such as a common prefix listed by several nodes, are listed once.
+func mergeSorted[E any](pages [][]E, truncated []bool, compare func(a, b E) int, limit int) ([]E, bool) {
+	var merged []E
+	isTruncated := false
+	var bound E
+	bounded := false
+	for i, page := range pages {
+		merged = append(merged, page...)
+		if !truncated[i] {
+			continue
+		}
+		isTruncated = true
+		if len(page) > 0 {
+			if last := page[len(page)-1]; !bounded || compare(last, bound) < 0 {
+				bound, bounded = last, true
+			}
+		}
+	}
+	slices.SortStableFunc(merged, compare)
+	merged = slices.CompactFunc(merged, func(a, b E) bool { return compare(a, b) == 0 })
+	if bounded {
+		merged = slices.DeleteFunc(merged, func(e E) bool { return compare(e, bound) > 0 })
+	}
+	if len(merged) > limit {
+		merged = merged[:max(limit, 0)]
+		isTruncated = true
+	}
+	return merged, isTruncated
+}
+
+// listedKey returns the key of a listing entry as stored, decoding it if the
+// listing was requested with encoding-type=url.
+func listedKey(key, encodingType string) string {
+	if encodingType != "url" {
+		return key
+	}
+	if decoded, err := url.QueryUnescape(key); err == nil {
+		return decoded
+	}
+	return key
+}
+
+// versionEntry is a version, delete marker or common prefix of a
+// ListObjectVersions page.
+type versionEntry struct {
+	key, lastModified, versionID string
+	version                      *api.VersionInfo
+	marker                       *api.DeleteMarkerInfo
+	prefix                       *api.CommonPrefix
+}
+
+// compareVersions orders versions like the nodes do: by key and then newest
+// first.
+func compareVersions(a, b versionEntry) int {
+	if c := strings.Compare(a.key, b.key); c != 0 {
+		return c
+	}
+	if c := strings.Compare(b.lastModified, a.lastModified); c != 0 {
+		return c
+	}
+	return strings.Compare(b.versionID, a.versionID)
+}
+
+// listObjectVersions sends a ListObjectVersions request to every node and
+// merges the results. The versions of a key may be spread over several nodes
+// if it was written before and after its owner changed.
+func (h *Handler) listObjectVersions(w http.ResponseWriter, r *http.Request, query url.Values) {
+	pages, ok := listNodes[api.ListVersionsResult](h, w, r)
+	if !ok {
+		return
+	}
+
+	entries := make([][]versionEntry, len(pages))
+	truncated := make([]bool, len(pages))
+	for i, page := range pages {
+		for j := range page.Versions {
+			v := &page.Versions[j]
+			entries[i] = append(entries[i], versionEntry{key: v.Key, lastModified: v.LastModified, versionID: v.VersionId, version: v})
+		}
+		for j := range page.DeleteMarkers {
+			m := &page.DeleteMarkers[j]
+			entries[i] = append(entries[i], versionEntry{key: m.Key, lastModified: m.LastModified, versionID: m.VersionId, marker: m})
+		}
+		for j := range page.CommonPrefixes {
+			p := &page.CommonPrefixes[j]
+			entries[i] = append(entries[i], versionEntry{key: p.Prefix, prefix: p})
+		}
+		slices.SortStableFunc(entries[i], compareVersions)
+		truncated[i] = page.IsTruncated
+	}
+	listed, isTruncated := mergeSorted(entries, truncated, compareVersions, listLimit(query, "max-keys"))
+
+	merged := pages[len(pages)-1]
+	merged.Versions, merged.DeleteMarkers, merged.CommonPrefixes = nil, nil, nil
+	merged.IsTruncated = isTruncated
+	merged.NextKeyMarker, merged.NextVersionIdMarker = "", ""
+	if isTruncated && len(listed) > 0 {
+		last := listed[len(listed)-1]
+		merged.NextKeyMarker, merged.NextVersionIdMarker = last.key, last.versionID
+	}
+	// Only the newest version of a key is its latest version, even if other
+	// nodes have versions of the key too
+	seen := make(map[string]bool)
+	for _, e := range listed {
+		switch {
+		case e.version != nil:
+			e.version.IsLatest = e.version.IsLatest && !seen[e.key]
+			merged.Versions = append(merged.Versions, *e.version)
+		case e.marker != nil:
+			e.marker.IsLatest = e.marker.IsLatest && !seen[e.key]
+			merged.DeleteMarkers = append(merged.DeleteMarkers, *e.marker)
+		default:
+			merged.CommonPrefixes = append(merged.CommonPrefixes, *e.prefix)
+		}
+		seen[e.key] = true
+	}
+	writeListing(w, merged)
+}
+
+// uploadEntry is an upload or common prefix of a ListMultipartUploads page.
+type uploadEntry struct {
+	key, uploadID string
+	upload        *api.UploadInfo
+	prefix        *api.CommonPrefix
+}
+
+// compareUploads orders uploads like the nodes do: by key and upload ID.
+func compareUploads(a, b uploadEntry) int {
+	if c := strings.Compare(a.key, b.key); c != 0 {
+		return c
+	}
+	return strings.Compare(a.uploadID, b.uploadID)
+}
+
+// listMultipartUploads sends a ListMultipartUploads request to every node
+// and merges the results.
+func (h *Handler) listMultipartUploads(w http.ResponseWriter, r *http.Request, query url.Values) {
+	pages, ok := listNodes[api.ListMultipartUploadsResult](h, w, r)
+	if !ok {
+		return
+	}
+
+	encodingType := query.Get("encoding-type")
+	entries := make([][]uploadEntry, len(pages))
+	truncated := make([]bool, len(pages))
+	for i, page := range pages {
+		for j := range page.Uploads {
+			u := &page.Uploads[j]
+			entries[i] = append(entries[i], uploadEntry{key: listedKey(u.Key, encodingType), uploadID: u.UploadId, upload: u})
+		}
+		for j := range page.CommonPrefixes {
+			p := &page.CommonPrefixes[j]
+			entries[i] = append(entries[i], uploadEntry{key: listedKey(p.Prefix, encodingType), prefix: p})
+		}
+		slices.SortStableFunc(entries[i], compareUploads)
+		truncated[i] = page.IsTruncated
+	}
+	listed, isTruncated := mergeSorted(entries, truncated, compareUploads, listLimit(query, "max-uploads"))
+
+	merged := pages[len(pages)-1]
+	merged.Uploads, merged.CommonPrefixes = []api.UploadInfo{}, nil
+	merged.IsTruncated = isTruncated
+	merged.NextKeyMarker, merged.NextUploadIdMarker = "", ""
+	if isTruncated && len(listed) > 0 {
+		last := listed[len(listed)-1]
+		merged.NextKeyMarker = last.key
+		if encodingType == "url" {
+			merged.NextKeyMarker = strings.ReplaceAll(url.QueryEscape(last.key), "%2F", "/")
+		}
+		merged.NextUploadIdMarker = last.uploadID
+	}
+	for _, e := range listed {
+		if e.upload != nil {
+			merged.Uploads = append(merged.Uploads, *e.upload)
+		} else {
+			merged.CommonPrefixes = append(merged.CommonPrefixes, *e.prefix)
+		}
+	}
+	writeListing(w, merged)
+}
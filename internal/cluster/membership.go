@@ -0,0 +1,396 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// GossipPath is the endpoint nodes exchange their member lists on.
+	GossipPath = "/_jog/cluster/gossip"
+	// signatureHeader carries the HMAC-SHA256 of a gossip message body.
+	signatureHeader = "X-Jog-Cluster-Signature"
+	// maxGossipSize bounds the size of gossip messages.
+	maxGossipSize = 1 << 20
+)
+
+// Config configures the node of a cluster.
+type Config struct {
+	// ID identifies the node; it must be unique in the cluster.
+	ID string
+	// URL is the base URL other nodes reach this node's S3 API on.
+	URL string
+	// Seeds are URLs of nodes contacted to join the cluster.
+	Seeds []string
+	// Secret authenticates gossip between the nodes.
+	Secret string
+	// GossipInterval is the time between gossip rounds.
+	GossipInterval time.Duration
+	// FailureTimeout is how long a node may not be heard of before it is
+	// considered down and removed from the ring.
+	FailureTimeout time.Duration
+	// VirtualNodes is the number of ring points per node.
+	VirtualNodes int
+}
+
+// Member is a node as seen by the membership protocol.
+type Member struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Heartbeat grows while the node is running. It starts at the node's
+	// start time, so a restarted node is not mistaken for its old self.
+	Heartbeat uint64 `json:"heartbeat"`
+}
+
+// MemberStatus is a member and whether it is considered up.
+type MemberStatus struct {
+	Member
+	Up       bool      `json:"up"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// gossipMessage is the body of gossip requests and responses.
+type gossipMessage struct {
+	Members []Member `json:"members"`
+}
+
+// memberState is a member and when its heartbeat last increased.
+type memberState struct {
+	Member
+	seen time.Time
+}
+
+// Node is the local member of a cluster. It gossips with the other nodes to
+// learn which nodes are up and keeps the ring of those nodes.
+type Node struct {
+	cfg    Config
+	client *http.Client
+
+	mu      sync.RWMutex
+	self    Member
+	members map[string]*memberState
+	ring    *Ring
+	up      []string
+
+	started atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewNode creates the local node of a cluster. It is alone in the ring until
+// it has gossiped with the seeds.
+func NewNode(cfg Config) (*Node, error) {
+	if cfg.ID == "" {
+		return nil, fmt.Errorf("cluster node ID must be set")
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("cluster advertise URL must be set")
+	}
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("cluster secret must be set")
+	}
+	if cfg.GossipInterval <= 0 {
+		cfg.GossipInterval = time.Second
+	}
+	if cfg.FailureTimeout <= 0 {
+		cfg.FailureTimeout = 10 * cfg.GossipInterval
+	}
+
+	n := &Node{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.GossipInterval * 2},
+		self:    Member{ID: cfg.ID, URL: cfg.URL, Heartbeat: uint64(time.Now().UnixNano())},
+		members: make(map[string]*memberState),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	n.updateRing()
+	return n, nil
+}
+
+// ID returns the ID of the local node.
+func (n *Node) ID() string {
+	return n.cfg.ID
+}
+
+// Start starts gossiping in the background.
+func (n *Node) Start() {
+	n.started.Store(true)
+	go n.run()
+}
+
+// Stop stops gossiping.
+func (n *Node) Stop() {
+	close(n.stop)
+	if n.started.Load() {
+		<-n.done
+	}
+}
+
+func (n *Node) run() {
+	defer close(n.done)
+	ticker := time.NewTicker(n.cfg.GossipInterval)
+	defer ticker.Stop()
+
+	n.gossip()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+			n.gossip()
+		}
+	}
+}
+
+// gossip runs a gossip round: it exchanges member lists with a random node
+// that is up, and with a random seed while no other node is known to be up.
+func (n *Node) gossip() {
+	n.mu.Lock()
+	n.self.Heartbeat++
+	n.mu.Unlock()
+	n.expire()
+
+	var targets []string
+	n.mu.RLock()
+	var peers []string
+	for _, id := range n.up {
+		if id != n.self.ID {
+			peers = append(peers, n.members[id].URL)
+		}
+	}
+	n.mu.RUnlock()
+	if len(peers) > 0 {
+		targets = append(targets, peers[rand.IntN(len(peers))])
+	}
+	if len(peers) == 0 && len(n.cfg.Seeds) > 0 {
+		seed := n.cfg.Seeds[rand.IntN(len(n.cfg.Seeds))]
+		if seed != n.cfg.URL {
+			targets = append(targets, seed)
+		}
+	}
+
+	for _, target := range targets {
+		if err := n.exchange(target); err != nil {
+			log.Debug().Err(err).Str("node", target).Msg("Cluster gossip failed")
+		}
+	}
+}
+
+// exchange sends the member list to a node and merges the list it returns.
+func (n *Node) exchange(url string) error {
+	body, err := json.Marshal(gossipMessage{Members: n.memberList()})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), n.cfg.GossipInterval*2)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+GossipPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signatureHeader, n.sign(body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	msg, err := n.readMessage(resp.Body, resp.Header.Get(signatureHeader))
+	if err != nil {
+		return err
+	}
+	n.merge(msg.Members)
+	return nil
+}
+
+// ServeGossip handles gossip requests of other nodes.
+func (n *Node) ServeGossip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	msg, err := n.readMessage(r.Body, r.Header.Get(signatureHeader))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	n.merge(msg.Members)
+
+	body, err := json.Marshal(gossipMessage{Members: n.memberList()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(signatureHeader, n.sign(body))
+	w.Write(body)
+}
+
+// readMessage reads and authenticates a gossip message.
+func (n *Node) readMessage(r io.Reader, signature string) (gossipMessage, error) {
+	var msg gossipMessage
+	body, err := io.ReadAll(io.LimitReader(r, maxGossipSize))
+	if err != nil {
+		return msg, err
+	}
+	if !hmac.Equal([]byte(signature), []byte(n.sign(body))) {
+		return msg, fmt.Errorf("invalid gossip signature")
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return msg, fmt.Errorf("invalid gossip message: %w", err)
+	}
+	return msg, nil
+}
+
+func (n *Node) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(n.cfg.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// memberList returns the local node and the members that are up.
+func (n *Node) memberList() []Member {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	list := []Member{n.self}
+	for _, id := range n.up {
+		if id != n.self.ID {
+			list = append(list, n.members[id].Member)
+		}
+	}
+	return list
+}
+
+// merge takes the newer heartbeats of a received member list.
+func (n *Node) merge(members []Member) {
+	now := time.Now()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, m := range members {
+		if m.ID == "" || m.ID == n.self.ID {
+			continue
+		}
+		state, ok := n.members[m.ID]
+		if !ok {
+			n.members[m.ID] = &memberState{Member: m, seen: now}
+			continue
+		}
+		if m.Heartbeat > state.Heartbeat {
+			state.Member = m
+			state.seen = now
+		}
+	}
+	n.updateRingLocked(now)
+}
+
+// expire removes nodes from the ring that have not been heard of within the
+// failure timeout, and forgets them entirely after three timeouts.
+func (n *Node) expire() {
+	now := time.Now()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for id, state := range n.members {
+		if now.Sub(state.seen) > 3*n.cfg.FailureTimeout {
+			delete(n.members, id)
+		}
+	}
+	n.updateRingLocked(now)
+}
+
+func (n *Node) updateRing() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.updateRingLocked(time.Now())
+}
+
+// updateRingLocked rebuilds the ring if the set of nodes that are up changed.
+func (n *Node) updateRingLocked(now time.Time) {
+	up := []string{n.self.ID}
+	for id, state := range n.members {
+		if now.Sub(state.seen) <= n.cfg.FailureTimeout {
+			up = append(up, id)
+		}
+	}
+	slices.Sort(up)
+	if n.ring != nil && slices.Equal(up, n.up) {
+		return
+	}
+
+	for _, id := range up {
+		if !slices.Contains(n.up, id) && id != n.self.ID {
+			log.Info().Str("node", id).Msg("Cluster node joined")
+		}
+	}
+	for _, id := range n.up {
+		if !slices.Contains(up, id) {
+			log.Warn().Str("node", id).Msg("Cluster node left")
+		}
+	}
+	n.up = up
+	n.ring = NewRing(up, n.cfg.VirtualNodes)
+}
+
+// Ring returns the ring of the nodes that are up.
+func (n *Node) Ring() *Ring {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.ring
+}
+
+// URL returns the base URL of a node that is up, or "" if it is unknown.
+func (n *Node) URL(id string) string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if id == n.self.ID {
+		return n.self.URL
+	}
+	if state, ok := n.members[id]; ok {
+		return state.URL
+	}
+	return ""
+}
+
+// Up returns the IDs of the nodes that are up, including the local node.
+func (n *Node) Up() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return slices.Clone(n.up)
+}
+
+// Members returns the local node and all known members.
+func (n *Node) Members() []MemberStatus {
+	now := time.Now()
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	list := []MemberStatus{{Member: n.self, Up: true, LastSeen: now}}
+	for _, state := range n.members {
+		list = append(list, MemberStatus{
+			Member:   state.Member,
+			Up:       now.Sub(state.seen) <= n.cfg.FailureTimeout,
+			LastSeen: state.seen,
+		})
+	}
+	slices.SortFunc(list, func(a, b MemberStatus) int { return strings.Compare(a.ID, b.ID) })
+	return list
+}
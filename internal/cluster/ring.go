@@ -0,0 +1,83 @@
+// Package cluster runs several Jog servers as one S3 endpoint. The nodes learn
+// of each other by gossip, place object keys on a consistent hash ring and
+// route every request to the node storing the object.
+package cluster
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+	"strconv"
+)
+
+// Ring assigns keys to nodes by consistent hashing. Each node is placed on the
+// ring at several virtual points, so keys move only between the affected nodes
+// when a node joins or leaves.
+type Ring struct {
+	points []ringPoint
+}
+
+// ringPoint is a virtual point of a node on the ring.
+type ringPoint struct {
+	hash uint64
+	node string
+}
+
+// NewRing creates a ring of the given node IDs with virtualNodes points each.
+func NewRing(nodes []string, virtualNodes int) *Ring {
+	if virtualNodes <= 0 {
+		virtualNodes = 1
+	}
+	r := &Ring{points: make([]ringPoint, 0, len(nodes)*virtualNodes)}
+	for _, node := range nodes {
+		for i := 0; i < virtualNodes; i++ {
+			r.points = append(r.points, ringPoint{hash: hashKey(node + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	slices.SortFunc(r.points, func(a, b ringPoint) int {
+		// Collisions are resolved deterministically on every node
+		return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(a.node, b.node))
+	})
+	return r
+}
+
+// Nodes returns the distinct nodes responsible for a key, the owner first and
+// then its successors on the ring. It returns nil for an empty ring.
+func (r *Ring) Nodes(key string) []string {
+	if len(r.points) == 0 {
+		return nil
+	}
+	i, _ := slices.BinarySearchFunc(r.points, hashKey(key), func(p ringPoint, h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+
+	var nodes []string
+	for n := 0; n < len(r.points); n++ {
+		node := r.points[(i+n)%len(r.points)].node
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Owner returns the node owning a key, or "" for an empty ring.
+func (r *Ring) Owner(key string) string {
+	if nodes := r.Nodes(key); len(nodes) > 0 {
+		return nodes[0]
+	}
+	return ""
+}
+
+// ObjectKey is the ring key of an object.
+func ObjectKey(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+// hashKey places a key on the ring. Similar keys, e.g. numbered object
+// names, must spread over the ring, which rules out CRC checksums.
+func hashKey(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -3,36 +3,646 @@ package config
 
 import (
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config holds the server configuration.
 type Config struct {
-	Server  ServerConfig  `mapstructure:"server"`
-	Storage StorageConfig `mapstructure:"storage"`
-	Auth    AuthConfig    `mapstructure:"auth"`
-	Logging LoggingConfig `mapstructure:"logging"`
+	Server        ServerConfig        `mapstructure:"server"`
+	Storage       StorageConfig       `mapstructure:"storage"`
+	Auth          AuthConfig          `mapstructure:"auth"`
+	Logging       LoggingConfig       `mapstructure:"logging"`
+	Notifications NotificationsConfig `mapstructure:"notifications"`
+	Cluster       ClusterConfig       `mapstructure:"cluster"`
+	Replication   ReplicationConfig   `mapstructure:"replication"`
+	HA            HAConfig            `mapstructure:"ha"`
+	Shadow        ShadowConfig        `mapstructure:"shadow"`
+	Hooks         HooksConfig         `mapstructure:"hooks"`
+	RecordReplay  RecordReplayConfig  `mapstructure:"record_replay"`
 }
 
 // ServerConfig holds HTTP server settings.
 type ServerConfig struct {
 	Port    int    `mapstructure:"port"`
 	Address string `mapstructure:"address"`
+	// Mode is "normal", "read-only" or "maintenance".
+	Mode string `mapstructure:"mode"`
+	// GRPCPort is the port of the gRPC control service. Zero disables it.
+	GRPCPort int `mapstructure:"grpc_port"`
+	// Role is "primary" or "replica". A replica serves reads from a copy of a
+	// primary's data directory and metadata database.
+	Role string `mapstructure:"role"`
+	// ReadHeaderTimeout limits reading the request line and headers.
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	// ReadTimeout and WriteTimeout limit reading a whole request and writing
+	// a whole response. Zero means no limit, so that large transfers are not
+	// cut off; BodyIdleTimeout protects against stalled clients instead.
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// IdleTimeout limits how long keep-alive connections wait for the next
+	// request.
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// MaxHeaderBytes limits the size of the request line and headers.
+	MaxHeaderBytes int `mapstructure:"max_header_bytes"`
+	// MaxHeaderCount limits the number of request header fields. Zero means
+	// no limit.
+	MaxHeaderCount int `mapstructure:"max_header_count"`
+	// BodyTimeout limits reading the body of a single request. Zero means no
+	// limit.
+	BodyTimeout time.Duration `mapstructure:"body_timeout"`
+	// BodyIdleTimeout aborts requests whose body receives no data for this
+	// long, e.g. an upload of a stalled client. Zero disables it.
+	BodyIdleTimeout time.Duration `mapstructure:"body_idle_timeout"`
+	// TrustedProxies are the addresses and CIDR prefixes of reverse proxies
+	// whose X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host headers
+	// are applied to requests.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// DisableKeepAlives closes HTTP/1.1 connections after every request.
+	DisableKeepAlives bool `mapstructure:"disable_keep_alives"`
+	// TLS serves the S3 API over HTTPS.
+	TLS ServerTLSConfig `mapstructure:"tls"`
+	// HTTP2 configures HTTP/2 of the S3 API.
+	HTTP2 HTTP2Config `mapstructure:"http2"`
+	// Region is the region reported for buckets created without a location
+	// constraint.
+	Region string `mapstructure:"region"`
+	// BucketLimits limits the requests to each bucket, so that the workload
+	// of one bucket cannot starve the others. The limits can be changed at
+	// runtime with the admin API.
+	BucketLimits BucketLimitsConfig `mapstructure:"bucket_limits"`
+	// Faults injects latency and errors into S3 requests, so that the retry
+	// behavior of applications can be tested. It must not be enabled in
+	// production.
+	Faults FaultsConfig `mapstructure:"faults"`
+}
+
+// BucketLimitsConfig holds the request limits of buckets.
+type BucketLimitsConfig struct {
+	// Default applies to buckets without limits of their own.
+	Default BucketLimitConfig `mapstructure:"default"`
+	// Buckets holds the limits of buckets by name.
+	Buckets map[string]BucketLimitConfig `mapstructure:"buckets"`
+}
+
+// BucketLimitConfig holds the request limits of a bucket. Zero means no
+// limit.
+type BucketLimitConfig struct {
+	// MaxConcurrentRequests limits the requests in progress.
+	MaxConcurrentRequests int `mapstructure:"max_concurrent_requests"`
+	// RequestsPerSecond limits the rate of requests, with bursts of up to
+	// Burst requests. Burst defaults to the rate rounded up.
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+// FaultsConfig holds the fault injection settings. The rules can be changed
+// at runtime with the admin API.
+type FaultsConfig struct {
+	Enabled bool              `mapstructure:"enabled"`
+	Rules   []FaultRuleConfig `mapstructure:"rules"`
+}
+
+// FaultRuleConfig holds the faults injected into matching requests.
+type FaultRuleConfig struct {
+	// Operations are the S3 operations the rule applies to, such as
+	// "GetObject". Empty means all requests.
+	Operations []string `mapstructure:"operations"`
+	// Buckets are the buckets the rule applies to. Empty means all buckets.
+	Buckets []string `mapstructure:"buckets"`
+	// Latency delays requests, plus a random delay of up to Jitter.
+	Latency time.Duration `mapstructure:"latency"`
+	Jitter  time.Duration `mapstructure:"jitter"`
+	// ErrorRate is the fraction of requests failed with ErrorCode:
+	// "InternalError" (the default), "ServiceUnavailable" or "SlowDown".
+	ErrorRate float64 `mapstructure:"error_rate"`
+	ErrorCode string  `mapstructure:"error_code"`
+	// BodyBytesPerSecond limits the rate response bodies are sent at. Zero
+	// means no limit.
+	BodyBytesPerSecond int `mapstructure:"body_bytes_per_second"`
+}
+
+// ServerTLSConfig holds the certificate of the S3 API server. TLS is enabled
+// when both files are set.
+type ServerTLSConfig struct {
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+}
+
+// HTTP2Config holds HTTP/2 settings of the S3 API server.
+type HTTP2Config struct {
+	// Enabled negotiates HTTP/2 with TLS clients.
+	Enabled bool `mapstructure:"enabled"`
+	// H2C accepts HTTP/2 without TLS from clients with prior knowledge, for
+	// plaintext deployments behind trusted proxies.
+	H2C bool `mapstructure:"h2c"`
+	// MaxConcurrentStreams limits the requests in flight on a connection.
+	MaxConcurrentStreams int `mapstructure:"max_concurrent_streams"`
+	// PingInterval is the idle time after which a connection is pinged to
+	// detect dead peers, which are dropped after PingTimeout. Zero disables
+	// pings.
+	PingInterval time.Duration `mapstructure:"ping_interval"`
+	PingTimeout  time.Duration `mapstructure:"ping_timeout"`
 }
 
 // StorageConfig holds storage backend settings.
 type StorageConfig struct {
-	DataDir    string `mapstructure:"data_dir"`
-	MetadataDB string `mapstructure:"metadata_db"`
+	Backend    string          `mapstructure:"backend"`
+	DataDir    string          `mapstructure:"data_dir"`
+	MetadataDB string          `mapstructure:"metadata_db"`
+	Azure      AzureConfig     `mapstructure:"azure"`
+	GCS        GCSConfig       `mapstructure:"gcs"`
+	S3         S3Config        `mapstructure:"s3"`
+	Cache      CacheConfig     `mapstructure:"cache"`
+	Tiered     TieredConfig    `mapstructure:"tiered"`
+	Erasure    ErasureConfig   `mapstructure:"erasure"`
+	Dedup      DedupConfig     `mapstructure:"dedup"`
+	Multipart  MultipartConfig `mapstructure:"multipart"`
+	Scrub      ScrubConfig     `mapstructure:"scrub"`
+	Trash      TrashConfig     `mapstructure:"trash"`
+	EmptyDirs  EmptyDirsConfig `mapstructure:"empty_dirs"`
+	TempFiles  TempFilesConfig `mapstructure:"temp_files"`
+	Versions   VersionsConfig  `mapstructure:"versions"`
+	Watch      WatchConfig     `mapstructure:"watch"`
+	// UsageHistory records the usage of buckets daily for capacity trends.
+	UsageHistory UsageHistoryConfig `mapstructure:"usage_history"`
+	// Compression is applied by the filesystem backend only.
+	Compression CompressionConfig `mapstructure:"compression"`
+	// EncryptedETags is "md5" or "random" and selects the ETags of objects in
+	// buckets with default encryption.
+	EncryptedETags string `mapstructure:"encrypted_etags"`
+	// PathEncoding is "plain" or "portable" and selects how the filesystem
+	// backend maps object keys to file paths. It must not change once the
+	// data directory holds objects.
+	PathEncoding string `mapstructure:"path_encoding"`
+	// Layout is "v1" or "v2" and selects the layout of object files of the
+	// filesystem backend. Layout v2 stores keys with segments longer than a
+	// file name under hashed names.
+	Layout string `mapstructure:"layout"`
+	// VersionIDFormat is "ulid" or "uuid" and selects the format of new
+	// version IDs. ULIDs sort in the order the versions were created.
+	VersionIDFormat string `mapstructure:"version_id_format"`
+	// ContentTypes maps key extensions, without the leading dot, to the
+	// content types of objects uploaded without one. It extends and overrides
+	// the system MIME types.
+	ContentTypes map[string]string `mapstructure:"content_types"`
+	// SlowQueryThreshold is the latency from which metadata statements are
+	// logged as slow. Zero disables the slow query log.
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold"`
+	// KMS wraps the data keys of objects encrypted at rest by the filesystem
+	// backend.
+	KMS KMSConfig `mapstructure:"kms"`
+}
+
+// KMSConfig holds the key management service of server-side encryption.
+type KMSConfig struct {
+	// Provider is "local", "vault" or "aws". Empty disables encryption at
+	// rest; encryption settings are then only recorded.
+	Provider string `mapstructure:"provider"`
+	// DefaultKeyID wraps the data keys of SSE-S3 (AES256) objects and of
+	// SSE-KMS objects without a key ID.
+	DefaultKeyID string         `mapstructure:"default_key_id"`
+	Local        LocalKMSConfig `mapstructure:"local"`
+	Vault        VaultKMSConfig `mapstructure:"vault"`
+	AWS          AWSKMSConfig   `mapstructure:"aws"`
+}
+
+// LocalKMSConfig holds the master keys of the local KMS provider.
+type LocalKMSConfig struct {
+	Keys []LocalKMSKey `mapstructure:"keys"`
+}
+
+// LocalKMSKey is a master key of the local KMS provider.
+type LocalKMSKey struct {
+	ID string `mapstructure:"id"`
+	// Versions are base64 encoded 256-bit keys, oldest first. New data keys
+	// are wrapped with the last version; add a version to rotate the key.
+	Versions []string `mapstructure:"versions"`
+}
+
+// VaultKMSConfig holds settings of the HashiCorp Vault transit KMS provider.
+type VaultKMSConfig struct {
+	// Address and Token default to $VAULT_ADDR and $VAULT_TOKEN.
+	Address string `mapstructure:"address"`
+	Token   string `mapstructure:"token"`
+	// Mount is the path of the transit secrets engine.
+	Mount string `mapstructure:"mount"`
+	// Derived passes the encryption context to transit keys created with
+	// derived=true.
+	Derived bool `mapstructure:"derived"`
+}
+
+// AWSKMSConfig holds settings of the AWS KMS provider. Without an access key,
+// credentials are taken from the default AWS credential chain.
+type AWSKMSConfig struct {
+	Region    string `mapstructure:"region"`
+	Endpoint  string `mapstructure:"endpoint"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+}
+
+// AzureConfig holds Azure Blob Storage backend settings.
+type AzureConfig struct {
+	AccountName string `mapstructure:"account_name"`
+	AccountKey  string `mapstructure:"account_key"`
+	Container   string `mapstructure:"container"`
+	Endpoint    string `mapstructure:"endpoint"`
+}
+
+// GCSConfig holds Google Cloud Storage backend settings.
+type GCSConfig struct {
+	Bucket          string `mapstructure:"bucket"`
+	CredentialsFile string `mapstructure:"credentials_file"`
+	Endpoint        string `mapstructure:"endpoint"`
+}
+
+// S3Config holds upstream S3 gateway backend settings.
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+}
+
+// CacheConfig holds local object cache settings for remote backends.
+type CacheConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Dir     string `mapstructure:"dir"`
+	MaxSize int64  `mapstructure:"max_size"`
+}
+
+// TieredConfig holds tiered (local plus remote) backend settings.
+// The policy values are defaults for buckets without a tiering configuration.
+type TieredConfig struct {
+	Remote          string        `mapstructure:"remote"`
+	SizeThreshold   int64         `mapstructure:"size_threshold"`
+	ColdAfterDays   int32         `mapstructure:"cold_after_days"`
+	MigrateInterval time.Duration `mapstructure:"migrate_interval"`
+}
+
+// ErasureConfig holds erasure-coded multi-disk backend settings.
+type ErasureConfig struct {
+	Dirs           []string      `mapstructure:"dirs"`
+	DataShards     int           `mapstructure:"data_shards"`
+	ParityShards   int           `mapstructure:"parity_shards"`
+	RepairInterval time.Duration `mapstructure:"repair_interval"`
+}
+
+// DedupConfig holds deduplicating backend settings.
+type DedupConfig struct {
+	// Dir holds the chunk store. Empty uses <data_dir>/.dedup.
+	Dir string `mapstructure:"dir"`
+	// Chunking is "cdc" (content-defined) or "fixed".
+	Chunking string `mapstructure:"chunking"`
+	// ChunkSize is the fixed chunk size, or the average content-defined chunk size.
+	ChunkSize int `mapstructure:"chunk_size"`
+}
+
+// MultipartConfig holds multipart upload settings.
+type MultipartConfig struct {
+	// AbortAfterDays aborts uploads initiated more than this many days ago,
+	// regardless of bucket lifecycle rules. Zero disables the cleanup.
+	AbortAfterDays  int32         `mapstructure:"abort_after_days"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+}
+
+// ScrubConfig holds integrity scrubbing settings.
+type ScrubConfig struct {
+	// Interval is the time between scrub runs. Zero disables scrubbing.
+	Interval time.Duration `mapstructure:"interval"`
+	// MaxObjects bounds the number of objects verified per run.
+	MaxObjects int `mapstructure:"max_objects"`
+	// ReverifyAfter is how long an object is not verified again after a check.
+	ReverifyAfter time.Duration `mapstructure:"reverify_after"`
+	// BytesPerSecond bounds the read rate of the scrubber. Zero is unlimited.
+	BytesPerSecond int64 `mapstructure:"bytes_per_second"`
+}
+
+// TrashConfig holds settings of the trash of buckets in trash mode.
+type TrashConfig struct {
+	// PurgeInterval is the time between deletions of objects whose trash
+	// retention ended. Zero disables purging.
+	PurgeInterval time.Duration `mapstructure:"purge_interval"`
+}
+
+// EmptyDirsConfig holds settings of the removal of empty directories that
+// deleted objects leave behind in buckets.
+type EmptyDirsConfig struct {
+	// PruneInterval is the time between sweeps for empty directories that
+	// deletes did not remove. Zero disables the sweeps.
+	PruneInterval time.Duration `mapstructure:"prune_interval"`
+}
+
+// VersionsConfig holds settings of the pruning of noncurrent versions in
+// buckets with a version retention.
+type VersionsConfig struct {
+	// PruneInterval is the time between deletions of the noncurrent versions
+	// beyond the retention of their buckets. Zero disables pruning.
+	PruneInterval time.Duration `mapstructure:"prune_interval"`
+}
+
+// UsageHistoryConfig holds settings of the daily records of the usage of
+// buckets.
+type UsageHistoryConfig struct {
+	// Interval is the time between records after the record at startup. A
+	// record replaces the record of the same UTC day. Zero disables
+	// recording.
+	Interval time.Duration `mapstructure:"interval"`
+	// KeepDays is the number of days records are kept. Zero keeps them
+	// forever.
+	KeepDays int `mapstructure:"keep_days"`
+}
+
+// TempFilesConfig holds settings of the removal of temporary files that
+// writes interrupted by a crash leave behind in the data directory.
+type TempFilesConfig struct {
+	// MaxAge is the time since their last write after which temporary files
+	// are removed. Zero disables the removal.
+	MaxAge time.Duration `mapstructure:"max_age"`
+	// ScanInterval is the time between scans after the scan at startup.
+	// Zero scans at startup only.
+	ScanInterval time.Duration `mapstructure:"scan_interval"`
+	// MaxFiles is the maximum number of files a scan removes. Zero removes
+	// all stale files.
+	MaxFiles int `mapstructure:"max_files"`
+}
+
+// WatchConfig holds settings of watching the data directory for files changed
+// outside JOG.
+type WatchConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Debounce is how long a file must be left alone before its metadata is
+	// updated.
+	Debounce time.Duration `mapstructure:"debounce"`
+}
+
+// CompressionConfig holds at-rest compression settings.
+type CompressionConfig struct {
+	// Algorithm is "none" or "gzip".
+	Algorithm string `mapstructure:"algorithm"`
+	// ContentTypes lists the content types compressed in buckets without a
+	// compression configuration. "type/*" matches all subtypes.
+	ContentTypes []string `mapstructure:"content_types"`
+	// MinSize is the size in bytes below which objects are not compressed.
+	MinSize int64 `mapstructure:"min_size"`
 }
 
 // AuthConfig holds authentication settings.
+// AccessKey and SecretKey belong to the default namespace.
 type AuthConfig struct {
+	AccessKey string         `mapstructure:"access_key"`
+	SecretKey string         `mapstructure:"secret_key"`
+	Tenants   []TenantConfig `mapstructure:"tenants"`
+	// MFADevices authenticate requests to buckets with MFA delete enabled.
+	MFADevices []MFADeviceConfig `mapstructure:"mfa_devices"`
+	// Regions are the regions requests may be signed for. Empty accepts any
+	// region.
+	Regions []string `mapstructure:"regions"`
+	// Services are the service names requests may be signed for. Empty
+	// accepts any service.
+	Services []string `mapstructure:"services"`
+	// PresignCache caches verified presigned URLs.
+	PresignCache PresignCacheConfig `mapstructure:"presign_cache"`
+	// Policies are named identity policies that users and tenants refer to.
+	Policies []PolicyConfig `mapstructure:"policies"`
+	// Users are additional credentials of the default namespace.
+	Users []UserConfig `mapstructure:"users"`
+	// OIDC exchanges ID tokens of an OpenID Connect provider for temporary
+	// credentials.
+	OIDC OIDCConfig `mapstructure:"oidc"`
+}
+
+// OIDCConfig holds settings of the OpenID Connect provider whose ID tokens
+// are exchanged for temporary credentials with AssumeRoleWithWebIdentity.
+type OIDCConfig struct {
+	// Issuer is the issuer URL of the provider. Empty disables federation.
+	Issuer string `mapstructure:"issuer"`
+	// ClientID is the audience ID tokens must be issued for.
+	ClientID string `mapstructure:"client_id"`
+	// JWKSURL is the URL of the signing keys of the provider. Empty uses
+	// the jwks_uri of its discovery document.
+	JWKSURL string `mapstructure:"jwks_url"`
+	// PolicyClaim is the claim naming the policies of auth.policies that
+	// temporary credentials are limited to.
+	PolicyClaim string `mapstructure:"policy_claim"`
+	// MaxDuration is the longest time temporary credentials are valid.
+	MaxDuration time.Duration `mapstructure:"max_duration"`
+}
+
+// PolicyConfig is a named IAM-style policy document.
+type PolicyConfig struct {
+	Name string `mapstructure:"name"`
+	// Document is the policy document in JSON.
+	Document string `mapstructure:"document"`
+}
+
+// UserConfig is an access key of the default namespace. Keys with policies
+// may only do what the policies allow; keys without have full access.
+type UserConfig struct {
+	AccessKey string   `mapstructure:"access_key"`
+	SecretKey string   `mapstructure:"secret_key"`
+	Policies  []string `mapstructure:"policies"`
+}
+
+// PresignCacheConfig holds settings of the cache of verified presigned URLs.
+type PresignCacheConfig struct {
+	// Size is the most URLs cached. Zero disables the cache.
+	Size int `mapstructure:"size"`
+	// TTL is the longest time a URL is cached; URLs are never cached beyond
+	// their expiration.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// MFADeviceConfig is a TOTP device whose tokens are sent in the x-amz-mfa
+// header.
+type MFADeviceConfig struct {
+	Serial string `mapstructure:"serial"`
+	// Secret is the base32 encoded TOTP secret of the device.
+	Secret string `mapstructure:"secret"`
+}
+
+// TenantConfig maps credentials to an isolated tenant namespace.
+// Several credentials may share a tenant name.
+type TenantConfig struct {
+	Name      string `mapstructure:"name"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	// Policies are the names of the identity policies of the access key.
+	Policies []string `mapstructure:"policies"`
+}
+
+// NotificationsConfig holds event notification settings.
+type NotificationsConfig struct {
+	Targets []NotificationTargetConfig `mapstructure:"targets"`
+}
+
+// NotificationTargetConfig configures a target object events are published to.
+type NotificationTargetConfig struct {
+	Name string `mapstructure:"name"`
+	// Type is "nats", "kafka" or "sqs".
+	Type string `mapstructure:"type"`
+	// Bucket, Prefix and Events select the published events. Events are event
+	// types such as "s3:ObjectCreated:*". Empty values match all events.
+	Bucket string      `mapstructure:"bucket"`
+	Prefix string      `mapstructure:"prefix"`
+	Events []string    `mapstructure:"events"`
+	TLS    TLSConfig   `mapstructure:"tls"`
+	NATS   NATSConfig  `mapstructure:"nats"`
+	Kafka  KafkaConfig `mapstructure:"kafka"`
+	SQS    SQSConfig   `mapstructure:"sqs"`
+}
+
+// TLSConfig holds client TLS settings.
+type TLSConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+// NATSConfig holds NATS notification target settings.
+type NATSConfig struct {
+	URL string `mapstructure:"url"`
+	// Subject is a text/template executed with the event, e.g. "jog.{{.Bucket}}".
+	Subject string `mapstructure:"subject"`
+	// JetStream waits for the stream to acknowledge each event.
+	JetStream bool   `mapstructure:"jetstream"`
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
+	Token     string `mapstructure:"token"`
+}
+
+// KafkaConfig holds Kafka notification target settings.
+type KafkaConfig struct {
+	Brokers []string `mapstructure:"brokers"`
+	// Topic is a text/template executed with the event, e.g. "jog-{{.Bucket}}".
+	Topic string     `mapstructure:"topic"`
+	SASL  SASLConfig `mapstructure:"sasl"`
+}
+
+// SQSConfig holds SQS notification target settings.
+// Without an access key, credentials are read from the AWS environment.
+type SQSConfig struct {
+	QueueURL string `mapstructure:"queue_url"`
+	// Region defaults to the region in the queue URL, or us-east-1.
+	Region    string `mapstructure:"region"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+}
+
+// SASLConfig holds SASL authentication settings.
+type SASLConfig struct {
+	// Mechanism is "plain"; empty disables SASL.
+	Mechanism string `mapstructure:"mechanism"`
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
+}
+
+// ClusterConfig holds cluster mode settings.
+type ClusterConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// NodeID identifies the node in the cluster; it defaults to the hostname.
+	NodeID string `mapstructure:"node_id"`
+	// AdvertiseURL is the base URL other nodes reach this node on, e.g.
+	// "http://10.0.0.1:9000".
+	AdvertiseURL string `mapstructure:"advertise_url"`
+	// Seeds are the URLs of nodes contacted to join the cluster.
+	Seeds []string `mapstructure:"seeds"`
+	// Secret authenticates gossip between the nodes.
+	Secret         string        `mapstructure:"secret"`
+	GossipInterval time.Duration `mapstructure:"gossip_interval"`
+	// FailureTimeout is how long a node may not be heard of before its keys
+	// move to the other nodes.
+	FailureTimeout time.Duration `mapstructure:"failure_timeout"`
+	VirtualNodes   int           `mapstructure:"virtual_nodes"`
+}
+
+// ReplicationConfig holds settings of primaries and read replicas.
+type ReplicationConfig struct {
+	// HeartbeatInterval is how often a primary records a heartbeat in its
+	// metadata database. Zero disables heartbeats.
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+	// MaxLag is the age of the latest heartbeat above which a replica reports
+	// not ready. Zero disables the check.
+	MaxLag time.Duration `mapstructure:"max_lag"`
+}
+
+// HAConfig holds settings of active-passive instances sharing a data volume.
+type HAConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// NodeID identifies the instance in the lease; it defaults to the hostname.
+	NodeID string `mapstructure:"node_id"`
+	// LeaseTTL is how long the leader holds the lease without renewing it,
+	// i.e. how long a failover takes at most.
+	LeaseTTL time.Duration `mapstructure:"lease_ttl"`
+	// RenewInterval is how often the lease is renewed or, by a standby,
+	// attempted to be acquired.
+	RenewInterval time.Duration `mapstructure:"renew_interval"`
+}
+
+// ShadowConfig holds settings of mirroring writes to a secondary S3 endpoint,
+// e.g. the system being migrated from.
+type ShadowConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Endpoint is the URL of the secondary; it defaults to AWS S3 in Region.
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	// CompareReads compares the objects returned by GET and HEAD requests
+	// with the secondary.
+	CompareReads bool `mapstructure:"compare_reads"`
+	// Workers is the number of objects mirrored concurrently.
+	Workers int `mapstructure:"workers"`
+	// QueueSize is the number of writes waiting to be mirrored above which
+	// further writes are reported as not mirrored.
+	QueueSize int `mapstructure:"queue_size"`
+	// ReportFile is a file divergences are appended to as JSON lines.
+	ReportFile string `mapstructure:"report_file"`
+}
+
+// RecordReplayConfig holds settings of the record/replay mode, in which S3
+// requests are proxied to a real S3 endpoint while the exchanges are recorded,
+// or answered offline with the recorded responses.
+type RecordReplayConfig struct {
+	// Mode is "record", "replay" or empty to serve requests from storage.
+	Mode string `mapstructure:"mode"`
+	// File holds the recorded exchanges as JSON lines. Recording appends to
+	// it.
+	File string `mapstructure:"file"`
+	// Endpoint is the URL of the S3 endpoint requests are recorded from; it
+	// defaults to AWS S3 in Region. Requests are signed with AccessKey and
+	// SecretKey, or sent unsigned without them.
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
 	AccessKey string `mapstructure:"access_key"`
 	SecretKey string `mapstructure:"secret_key"`
 }
 
+// HooksConfig holds settings of external services called while handling
+// requests.
+type HooksConfig struct {
+	PrePut PrePutHookConfig `mapstructure:"pre_put"`
+}
+
+// PrePutHookConfig holds settings of the webhook validating PutObject uploads
+// before they are stored, e.g. an antivirus scanner.
+type PrePutHookConfig struct {
+	// URL receives the data of each upload in a POST request. Empty disables
+	// the hook.
+	URL     string        `mapstructure:"url"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// FailOpen accepts uploads while the hook cannot be reached or fails
+	// with a 5xx response; otherwise they are rejected with 503.
+	FailOpen bool `mapstructure:"fail_open"`
+	// SpoolDir holds uploads while they are validated. Empty uses the system
+	// temporary directory.
+	SpoolDir string `mapstructure:"spool_dir"`
+}
+
 // LoggingConfig holds logging settings.
 type LoggingConfig struct {
 	Level  string `mapstructure:"level"`
@@ -43,21 +653,144 @@ type LoggingConfig struct {
 func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port:    9000,
-			Address: "0.0.0.0",
+			Port:              9000,
+			Address:           "0.0.0.0",
+			Mode:              "normal",
+			Role:              "primary",
+			ReadHeaderTimeout: 30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			MaxHeaderCount:    256,
+			BodyIdleTimeout:   time.Minute,
+			Region:            "us-east-1",
+			HTTP2: HTTP2Config{
+				Enabled:              true,
+				MaxConcurrentStreams: 250,
+				PingTimeout:          15 * time.Second,
+			},
 		},
 		Storage: StorageConfig{
+			Backend:    "filesystem",
 			DataDir:    "./data",
 			MetadataDB: "./data/metadata.db",
+			Tiered: TieredConfig{
+				Remote:          "s3",
+				SizeThreshold:   64 * 1024 * 1024,
+				MigrateInterval: time.Hour,
+			},
+			Erasure: ErasureConfig{
+				Dirs:           []string{},
+				DataShards:     2,
+				ParityShards:   1,
+				RepairInterval: 24 * time.Hour,
+			},
+			Dedup: DedupConfig{
+				Chunking:  "cdc",
+				ChunkSize: 1024 * 1024,
+			},
+			Multipart: MultipartConfig{
+				AbortAfterDays:  7,
+				CleanupInterval: time.Hour,
+			},
+			Scrub: ScrubConfig{
+				Interval:       time.Hour,
+				MaxObjects:     1000,
+				ReverifyAfter:  30 * 24 * time.Hour,
+				BytesPerSecond: 10 * 1024 * 1024,
+			},
+			Trash: TrashConfig{
+				PurgeInterval: time.Hour,
+			},
+			EmptyDirs: EmptyDirsConfig{
+				PruneInterval: 24 * time.Hour,
+			},
+			TempFiles: TempFilesConfig{
+				MaxAge:       time.Hour,
+				ScanInterval: 6 * time.Hour,
+				MaxFiles:     10000,
+			},
+			Versions: VersionsConfig{
+				PruneInterval: time.Hour,
+			},
+			UsageHistory: UsageHistoryConfig{
+				Interval: 24 * time.Hour,
+				KeepDays: 365,
+			},
+			Watch: WatchConfig{
+				Debounce: 2 * time.Second,
+			},
+			Compression: CompressionConfig{
+				Algorithm: "none",
+				ContentTypes: []string{
+					"text/*",
+					"application/json",
+					"application/xml",
+					"application/javascript",
+					"image/svg+xml",
+				},
+				MinSize: 1024,
+			},
+			EncryptedETags:     "md5",
+			PathEncoding:       "plain",
+			Layout:             "v1",
+			VersionIDFormat:    "ulid",
+			SlowQueryThreshold: 500 * time.Millisecond,
+			KMS: KMSConfig{
+				Vault: VaultKMSConfig{Mount: "transit"},
+				AWS:   AWSKMSConfig{Region: "us-east-1"},
+			},
 		},
 		Auth: AuthConfig{
-			AccessKey: "minioadmin",
-			SecretKey: "minioadmin",
+			AccessKey:  "minioadmin",
+			SecretKey:  "minioadmin",
+			Tenants:    []TenantConfig{},
+			MFADevices: []MFADeviceConfig{},
+			Regions:    []string{},
+			Services:   []string{"s3", "s3express"},
+			PresignCache: PresignCacheConfig{
+				Size: 10000,
+				TTL:  5 * time.Minute,
+			},
+			Policies: []PolicyConfig{},
+			Users:    []UserConfig{},
+			OIDC: OIDCConfig{
+				PolicyClaim: "policy",
+				MaxDuration: 12 * time.Hour,
+			},
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
 			Format: "json",
 		},
+		Notifications: NotificationsConfig{
+			Targets: []NotificationTargetConfig{},
+		},
+		Cluster: ClusterConfig{
+			Seeds:          []string{},
+			GossipInterval: time.Second,
+			FailureTimeout: 10 * time.Second,
+			VirtualNodes:   128,
+		},
+		Replication: ReplicationConfig{
+			HeartbeatInterval: 5 * time.Second,
+			MaxLag:            time.Minute,
+		},
+		HA: HAConfig{
+			LeaseTTL:      15 * time.Second,
+			RenewInterval: 5 * time.Second,
+		},
+		Shadow: ShadowConfig{
+			Workers:   4,
+			QueueSize: 10000,
+		},
+		Hooks: HooksConfig{
+			PrePut: PrePutHookConfig{
+				Timeout: 30 * time.Second,
+			},
+		},
+		RecordReplay: RecordReplayConfig{
+			Region: "us-east-1",
+		},
 	}
 }
 
@@ -70,12 +803,140 @@ func Load() (*Config, error) {
 	// Set defaults
 	v.SetDefault("server.port", cfg.Server.Port)
 	v.SetDefault("server.address", cfg.Server.Address)
+	v.SetDefault("server.mode", cfg.Server.Mode)
+	v.SetDefault("server.grpc_port", cfg.Server.GRPCPort)
+	v.SetDefault("server.role", cfg.Server.Role)
+	v.SetDefault("server.region", cfg.Server.Region)
+	v.SetDefault("server.bucket_limits.default.max_concurrent_requests", cfg.Server.BucketLimits.Default.MaxConcurrentRequests)
+	v.SetDefault("server.bucket_limits.default.requests_per_second", cfg.Server.BucketLimits.Default.RequestsPerSecond)
+	v.SetDefault("server.bucket_limits.default.burst", cfg.Server.BucketLimits.Default.Burst)
+	v.SetDefault("server.faults.enabled", cfg.Server.Faults.Enabled)
+	v.SetDefault("server.read_header_timeout", cfg.Server.ReadHeaderTimeout)
+	v.SetDefault("server.read_timeout", cfg.Server.ReadTimeout)
+	v.SetDefault("server.write_timeout", cfg.Server.WriteTimeout)
+	v.SetDefault("server.idle_timeout", cfg.Server.IdleTimeout)
+	v.SetDefault("server.max_header_bytes", cfg.Server.MaxHeaderBytes)
+	v.SetDefault("server.max_header_count", cfg.Server.MaxHeaderCount)
+	v.SetDefault("server.body_timeout", cfg.Server.BodyTimeout)
+	v.SetDefault("server.body_idle_timeout", cfg.Server.BodyIdleTimeout)
+	v.SetDefault("server.trusted_proxies", cfg.Server.TrustedProxies)
+	v.SetDefault("server.disable_keep_alives", cfg.Server.DisableKeepAlives)
+	v.SetDefault("server.tls.cert_file", cfg.Server.TLS.CertFile)
+	v.SetDefault("server.tls.key_file", cfg.Server.TLS.KeyFile)
+	v.SetDefault("server.http2.enabled", cfg.Server.HTTP2.Enabled)
+	v.SetDefault("server.http2.h2c", cfg.Server.HTTP2.H2C)
+	v.SetDefault("server.http2.max_concurrent_streams", cfg.Server.HTTP2.MaxConcurrentStreams)
+	v.SetDefault("server.http2.ping_interval", cfg.Server.HTTP2.PingInterval)
+	v.SetDefault("server.http2.ping_timeout", cfg.Server.HTTP2.PingTimeout)
+	v.SetDefault("storage.backend", cfg.Storage.Backend)
 	v.SetDefault("storage.data_dir", cfg.Storage.DataDir)
 	v.SetDefault("storage.metadata_db", cfg.Storage.MetadataDB)
+	v.SetDefault("storage.azure.account_name", cfg.Storage.Azure.AccountName)
+	v.SetDefault("storage.azure.account_key", cfg.Storage.Azure.AccountKey)
+	v.SetDefault("storage.azure.container", cfg.Storage.Azure.Container)
+	v.SetDefault("storage.azure.endpoint", cfg.Storage.Azure.Endpoint)
+	v.SetDefault("storage.gcs.bucket", cfg.Storage.GCS.Bucket)
+	v.SetDefault("storage.gcs.credentials_file", cfg.Storage.GCS.CredentialsFile)
+	v.SetDefault("storage.gcs.endpoint", cfg.Storage.GCS.Endpoint)
+	v.SetDefault("storage.s3.endpoint", cfg.Storage.S3.Endpoint)
+	v.SetDefault("storage.s3.region", cfg.Storage.S3.Region)
+	v.SetDefault("storage.s3.bucket", cfg.Storage.S3.Bucket)
+	v.SetDefault("storage.s3.access_key", cfg.Storage.S3.AccessKey)
+	v.SetDefault("storage.s3.secret_key", cfg.Storage.S3.SecretKey)
+	v.SetDefault("storage.cache.enabled", cfg.Storage.Cache.Enabled)
+	v.SetDefault("storage.cache.dir", cfg.Storage.Cache.Dir)
+	v.SetDefault("storage.cache.max_size", cfg.Storage.Cache.MaxSize)
+	v.SetDefault("storage.tiered.remote", cfg.Storage.Tiered.Remote)
+	v.SetDefault("storage.tiered.size_threshold", cfg.Storage.Tiered.SizeThreshold)
+	v.SetDefault("storage.tiered.cold_after_days", cfg.Storage.Tiered.ColdAfterDays)
+	v.SetDefault("storage.tiered.migrate_interval", cfg.Storage.Tiered.MigrateInterval)
+	v.SetDefault("storage.erasure.dirs", cfg.Storage.Erasure.Dirs)
+	v.SetDefault("storage.erasure.data_shards", cfg.Storage.Erasure.DataShards)
+	v.SetDefault("storage.erasure.parity_shards", cfg.Storage.Erasure.ParityShards)
+	v.SetDefault("storage.erasure.repair_interval", cfg.Storage.Erasure.RepairInterval)
+	v.SetDefault("storage.dedup.dir", cfg.Storage.Dedup.Dir)
+	v.SetDefault("storage.dedup.chunking", cfg.Storage.Dedup.Chunking)
+	v.SetDefault("storage.dedup.chunk_size", cfg.Storage.Dedup.ChunkSize)
+	v.SetDefault("storage.multipart.abort_after_days", cfg.Storage.Multipart.AbortAfterDays)
+	v.SetDefault("storage.multipart.cleanup_interval", cfg.Storage.Multipart.CleanupInterval)
+	v.SetDefault("storage.scrub.interval", cfg.Storage.Scrub.Interval)
+	v.SetDefault("storage.scrub.max_objects", cfg.Storage.Scrub.MaxObjects)
+	v.SetDefault("storage.scrub.reverify_after", cfg.Storage.Scrub.ReverifyAfter)
+	v.SetDefault("storage.scrub.bytes_per_second", cfg.Storage.Scrub.BytesPerSecond)
+	v.SetDefault("storage.trash.purge_interval", cfg.Storage.Trash.PurgeInterval)
+	v.SetDefault("storage.empty_dirs.prune_interval", cfg.Storage.EmptyDirs.PruneInterval)
+	v.SetDefault("storage.temp_files.max_age", cfg.Storage.TempFiles.MaxAge)
+	v.SetDefault("storage.temp_files.scan_interval", cfg.Storage.TempFiles.ScanInterval)
+	v.SetDefault("storage.temp_files.max_files", cfg.Storage.TempFiles.MaxFiles)
+	v.SetDefault("storage.versions.prune_interval", cfg.Storage.Versions.PruneInterval)
+	v.SetDefault("storage.usage_history.interval", cfg.Storage.UsageHistory.Interval)
+	v.SetDefault("storage.usage_history.keep_days", cfg.Storage.UsageHistory.KeepDays)
+	v.SetDefault("storage.watch.enabled", cfg.Storage.Watch.Enabled)
+	v.SetDefault("storage.watch.debounce", cfg.Storage.Watch.Debounce)
+	v.SetDefault("storage.compression.algorithm", cfg.Storage.Compression.Algorithm)
+	v.SetDefault("storage.compression.content_types", cfg.Storage.Compression.ContentTypes)
+	v.SetDefault("storage.compression.min_size", cfg.Storage.Compression.MinSize)
+	v.SetDefault("storage.encrypted_etags", cfg.Storage.EncryptedETags)
+	v.SetDefault("storage.path_encoding", cfg.Storage.PathEncoding)
+	v.SetDefault("storage.layout", cfg.Storage.Layout)
+	v.SetDefault("storage.version_id_format", cfg.Storage.VersionIDFormat)
+	v.SetDefault("storage.content_types", cfg.Storage.ContentTypes)
+	v.SetDefault("storage.slow_query_threshold", cfg.Storage.SlowQueryThreshold)
+	v.SetDefault("storage.kms.provider", cfg.Storage.KMS.Provider)
+	v.SetDefault("storage.kms.default_key_id", cfg.Storage.KMS.DefaultKeyID)
+	v.SetDefault("storage.kms.vault.mount", cfg.Storage.KMS.Vault.Mount)
+	v.SetDefault("storage.kms.aws.region", cfg.Storage.KMS.AWS.Region)
 	v.SetDefault("auth.access_key", cfg.Auth.AccessKey)
 	v.SetDefault("auth.secret_key", cfg.Auth.SecretKey)
+	v.SetDefault("auth.tenants", cfg.Auth.Tenants)
+	v.SetDefault("auth.mfa_devices", cfg.Auth.MFADevices)
+	v.SetDefault("auth.regions", cfg.Auth.Regions)
+	v.SetDefault("auth.services", cfg.Auth.Services)
+	v.SetDefault("auth.presign_cache.size", cfg.Auth.PresignCache.Size)
+	v.SetDefault("auth.presign_cache.ttl", cfg.Auth.PresignCache.TTL)
+	v.SetDefault("auth.policies", cfg.Auth.Policies)
+	v.SetDefault("auth.users", cfg.Auth.Users)
+	v.SetDefault("auth.oidc.issuer", cfg.Auth.OIDC.Issuer)
+	v.SetDefault("auth.oidc.client_id", cfg.Auth.OIDC.ClientID)
+	v.SetDefault("auth.oidc.jwks_url", cfg.Auth.OIDC.JWKSURL)
+	v.SetDefault("auth.oidc.policy_claim", cfg.Auth.OIDC.PolicyClaim)
+	v.SetDefault("auth.oidc.max_duration", cfg.Auth.OIDC.MaxDuration)
 	v.SetDefault("logging.level", cfg.Logging.Level)
 	v.SetDefault("logging.format", cfg.Logging.Format)
+	v.SetDefault("notifications.targets", cfg.Notifications.Targets)
+	v.SetDefault("cluster.enabled", cfg.Cluster.Enabled)
+	v.SetDefault("cluster.node_id", cfg.Cluster.NodeID)
+	v.SetDefault("cluster.advertise_url", cfg.Cluster.AdvertiseURL)
+	v.SetDefault("cluster.seeds", cfg.Cluster.Seeds)
+	v.SetDefault("cluster.secret", cfg.Cluster.Secret)
+	v.SetDefault("cluster.gossip_interval", cfg.Cluster.GossipInterval)
+	v.SetDefault("cluster.failure_timeout", cfg.Cluster.FailureTimeout)
+	v.SetDefault("cluster.virtual_nodes", cfg.Cluster.VirtualNodes)
+	v.SetDefault("replication.heartbeat_interval", cfg.Replication.HeartbeatInterval)
+	v.SetDefault("replication.max_lag", cfg.Replication.MaxLag)
+	v.SetDefault("ha.enabled", cfg.HA.Enabled)
+	v.SetDefault("ha.node_id", cfg.HA.NodeID)
+	v.SetDefault("ha.lease_ttl", cfg.HA.LeaseTTL)
+	v.SetDefault("ha.renew_interval", cfg.HA.RenewInterval)
+	v.SetDefault("shadow.enabled", cfg.Shadow.Enabled)
+	v.SetDefault("shadow.endpoint", cfg.Shadow.Endpoint)
+	v.SetDefault("shadow.region", cfg.Shadow.Region)
+	v.SetDefault("shadow.access_key", cfg.Shadow.AccessKey)
+	v.SetDefault("shadow.secret_key", cfg.Shadow.SecretKey)
+	v.SetDefault("shadow.compare_reads", cfg.Shadow.CompareReads)
+	v.SetDefault("shadow.workers", cfg.Shadow.Workers)
+	v.SetDefault("shadow.queue_size", cfg.Shadow.QueueSize)
+	v.SetDefault("shadow.report_file", cfg.Shadow.ReportFile)
+	v.SetDefault("record_replay.mode", cfg.RecordReplay.Mode)
+	v.SetDefault("record_replay.file", cfg.RecordReplay.File)
+	v.SetDefault("record_replay.endpoint", cfg.RecordReplay.Endpoint)
+	v.SetDefault("record_replay.region", cfg.RecordReplay.Region)
+	v.SetDefault("record_replay.access_key", cfg.RecordReplay.AccessKey)
+	v.SetDefault("record_replay.secret_key", cfg.RecordReplay.SecretKey)
+	v.SetDefault("hooks.pre_put.url", cfg.Hooks.PrePut.URL)
+	v.SetDefault("hooks.pre_put.timeout", cfg.Hooks.PrePut.Timeout)
+	v.SetDefault("hooks.pre_put.fail_open", cfg.Hooks.PrePut.FailOpen)
+	v.SetDefault("hooks.pre_put.spool_dir", cfg.Hooks.PrePut.SpoolDir)
 
 	// Enable environment variables
 	v.SetEnvPrefix("JOG")
@@ -0,0 +1,600 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	checkKey     = "conformance/object.txt"
+	checkCopyKey = "conformance/copy.txt"
+	checkMPUKey  = "conformance/multipart.bin"
+	checkContent = "JOG conformance check\n"
+)
+
+// checker runs the checks against a bucket it owns.
+type checker struct {
+	client *s3.Client
+	bucket string
+}
+
+// check is the check of an operation. It returns an error when the
+// operation fails or does not behave as S3 does.
+type check struct {
+	operation string
+	run       func(ctx context.Context, c *checker) error
+}
+
+// checks are run in order against a new bucket. Later checks rely on the
+// objects and configuration created by earlier ones; CreateBucket must come
+// first and DeleteBucket last.
+var checks = []check{
+	{"CreateBucket", func(ctx context.Context, c *checker) error {
+		_, err := c.client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"HeadBucket", func(ctx context.Context, c *checker) error {
+		_, err := c.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"ListBuckets", func(ctx context.Context, c *checker) error {
+		out, err := c.client.ListBuckets(ctx, &s3.ListBucketsInput{})
+		if err != nil {
+			return err
+		}
+		for _, b := range out.Buckets {
+			if aws.ToString(b.Name) == c.bucket {
+				return nil
+			}
+		}
+		return fmt.Errorf("bucket %s is not listed", c.bucket)
+	}},
+	{"GetBucketLocation", func(ctx context.Context, c *checker) error {
+		_, err := c.client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"PutObject", func(ctx context.Context, c *checker) error {
+		_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      &c.bucket,
+			Key:         aws.String(checkKey),
+			Body:        strings.NewReader(checkContent),
+			ContentType: aws.String("text/plain"),
+			Metadata:    map[string]string{"purpose": "conformance"},
+		})
+		return err
+	}},
+	{"HeadObject", func(ctx context.Context, c *checker) error {
+		out, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &c.bucket, Key: aws.String(checkKey)})
+		if err != nil {
+			return err
+		}
+		if size := aws.ToInt64(out.ContentLength); size != int64(len(checkContent)) {
+			return fmt.Errorf("content length is %d, want %d", size, len(checkContent))
+		}
+		if out.Metadata["purpose"] != "conformance" {
+			return fmt.Errorf("user metadata is %v", out.Metadata)
+		}
+		return nil
+	}},
+	{"GetObject", func(ctx context.Context, c *checker) error {
+		return c.expectContent(ctx, checkKey, "", checkContent)
+	}},
+	{"CopyObject", func(ctx context.Context, c *checker) error {
+		_, err := c.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     &c.bucket,
+			Key:        aws.String(checkCopyKey),
+			CopySource: aws.String(c.bucket + "/" + checkKey),
+		})
+		if err != nil {
+			return err
+		}
+		return c.expectContent(ctx, checkCopyKey, "", checkContent)
+	}},
+	{"ListObjectsV2", func(ctx context.Context, c *checker) error {
+		out, err := c.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: &c.bucket, Prefix: aws.String("conformance/")})
+		if err != nil {
+			return err
+		}
+		return expectKeys(out.Contents, checkCopyKey, checkKey)
+	}},
+	{"ListObjects", func(ctx context.Context, c *checker) error {
+		out, err := c.client.ListObjects(ctx, &s3.ListObjectsInput{Bucket: &c.bucket, Prefix: aws.String("conformance/")})
+		if err != nil {
+			return err
+		}
+		return expectKeys(out.Contents, checkCopyKey, checkKey)
+	}},
+	{"GetObjectAttributes", func(ctx context.Context, c *checker) error {
+		out, err := c.client.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+			Bucket:           &c.bucket,
+			Key:              aws.String(checkKey),
+			ObjectAttributes: []types.ObjectAttributes{types.ObjectAttributesEtag, types.ObjectAttributesObjectSize},
+		})
+		if err != nil {
+			return err
+		}
+		if size := aws.ToInt64(out.ObjectSize); size != int64(len(checkContent)) {
+			return fmt.Errorf("object size is %d, want %d", size, len(checkContent))
+		}
+		return nil
+	}},
+	{"PutObjectTagging", func(ctx context.Context, c *checker) error {
+		_, err := c.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+			Bucket:  &c.bucket,
+			Key:     aws.String(checkKey),
+			Tagging: &types.Tagging{TagSet: []types.Tag{{Key: aws.String("check"), Value: aws.String("tagging")}}},
+		})
+		return err
+	}},
+	{"GetObjectTagging", func(ctx context.Context, c *checker) error {
+		out, err := c.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: &c.bucket, Key: aws.String(checkKey)})
+		if err != nil {
+			return err
+		}
+		return expectTag(out.TagSet, "check", "tagging")
+	}},
+	{"DeleteObjectTagging", func(ctx context.Context, c *checker) error {
+		_, err := c.client.DeleteObjectTagging(ctx, &s3.DeleteObjectTaggingInput{Bucket: &c.bucket, Key: aws.String(checkKey)})
+		return err
+	}},
+	{"PutBucketTagging", func(ctx context.Context, c *checker) error {
+		_, err := c.client.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{
+			Bucket:  &c.bucket,
+			Tagging: &types.Tagging{TagSet: []types.Tag{{Key: aws.String("check"), Value: aws.String("tagging")}}},
+		})
+		return err
+	}},
+	{"GetBucketTagging", func(ctx context.Context, c *checker) error {
+		out, err := c.client.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{Bucket: &c.bucket})
+		if err != nil {
+			return err
+		}
+		return expectTag(out.TagSet, "check", "tagging")
+	}},
+	{"DeleteBucketTagging", func(ctx context.Context, c *checker) error {
+		_, err := c.client.DeleteBucketTagging(ctx, &s3.DeleteBucketTaggingInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"GetBucketAcl", func(ctx context.Context, c *checker) error {
+		_, err := c.client.GetBucketAcl(ctx, &s3.GetBucketAclInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"PutBucketAcl", func(ctx context.Context, c *checker) error {
+		_, err := c.client.PutBucketAcl(ctx, &s3.PutBucketAclInput{Bucket: &c.bucket, ACL: types.BucketCannedACLPrivate})
+		return err
+	}},
+	{"GetObjectAcl", func(ctx context.Context, c *checker) error {
+		_, err := c.client.GetObjectAcl(ctx, &s3.GetObjectAclInput{Bucket: &c.bucket, Key: aws.String(checkKey)})
+		return err
+	}},
+	{"PutObjectAcl", func(ctx context.Context, c *checker) error {
+		_, err := c.client.PutObjectAcl(ctx, &s3.PutObjectAclInput{Bucket: &c.bucket, Key: aws.String(checkKey), ACL: types.ObjectCannedACLPrivate})
+		return err
+	}},
+	{"PutBucketPolicy", func(ctx context.Context, c *checker) error {
+		// A deny statement is accepted by endpoints blocking public access
+		policy := `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:PutObject",` +
+			`"Resource":"arn:aws:s3:::` + c.bucket + `/conformance-denied/*"}]}`
+		_, err := c.client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{Bucket: &c.bucket, Policy: &policy})
+		return err
+	}},
+	{"GetBucketPolicy", func(ctx context.Context, c *checker) error {
+		out, err := c.client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: &c.bucket})
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(aws.ToString(out.Policy), "conformance-denied") {
+			return fmt.Errorf("policy is %q", aws.ToString(out.Policy))
+		}
+		return nil
+	}},
+	{"DeleteBucketPolicy", func(ctx context.Context, c *checker) error {
+		_, err := c.client.DeleteBucketPolicy(ctx, &s3.DeleteBucketPolicyInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"PutBucketCors", func(ctx context.Context, c *checker) error {
+		_, err := c.client.PutBucketCors(ctx, &s3.PutBucketCorsInput{
+			Bucket: &c.bucket,
+			CORSConfiguration: &types.CORSConfiguration{CORSRules: []types.CORSRule{{
+				AllowedMethods: []string{"GET"},
+				AllowedOrigins: []string{"https://example.com"},
+			}}},
+		})
+		return err
+	}},
+	{"GetBucketCors", func(ctx context.Context, c *checker) error {
+		out, err := c.client.GetBucketCors(ctx, &s3.GetBucketCorsInput{Bucket: &c.bucket})
+		if err != nil {
+			return err
+		}
+		if len(out.CORSRules) != 1 {
+			return fmt.Errorf("got %d CORS rules, want 1", len(out.CORSRules))
+		}
+		return nil
+	}},
+	{"DeleteBucketCors", func(ctx context.Context, c *checker) error {
+		_, err := c.client.DeleteBucketCors(ctx, &s3.DeleteBucketCorsInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"PutBucketEncryption", func(ctx context.Context, c *checker) error {
+		_, err := c.client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+			Bucket: &c.bucket,
+			ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{Rules: []types.ServerSideEncryptionRule{{
+				ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{SSEAlgorithm: types.ServerSideEncryptionAes256},
+			}}},
+		})
+		return err
+	}},
+	{"GetBucketEncryption", func(ctx context.Context, c *checker) error {
+		_, err := c.client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"DeleteBucketEncryption", func(ctx context.Context, c *checker) error {
+		_, err := c.client.DeleteBucketEncryption(ctx, &s3.DeleteBucketEncryptionInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"PutBucketLifecycleConfiguration", func(ctx context.Context, c *checker) error {
+		_, err := c.client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+			Bucket: &c.bucket,
+			LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: []types.LifecycleRule{{
+				ID:         aws.String("conformance"),
+				Status:     types.ExpirationStatusEnabled,
+				Filter:     &types.LifecycleRuleFilter{Prefix: aws.String("expiring/")},
+				Expiration: &types.LifecycleExpiration{Days: aws.Int32(30)},
+			}}},
+		})
+		return err
+	}},
+	{"GetBucketLifecycleConfiguration", func(ctx context.Context, c *checker) error {
+		out, err := c.client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: &c.bucket})
+		if err != nil {
+			return err
+		}
+		if len(out.Rules) != 1 {
+			return fmt.Errorf("got %d lifecycle rules, want 1", len(out.Rules))
+		}
+		return nil
+	}},
+	{"DeleteBucketLifecycle", func(ctx context.Context, c *checker) error {
+		_, err := c.client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"PutBucketWebsite", func(ctx context.Context, c *checker) error {
+		_, err := c.client.PutBucketWebsite(ctx, &s3.PutBucketWebsiteInput{
+			Bucket: &c.bucket,
+			WebsiteConfiguration: &types.WebsiteConfiguration{
+				IndexDocument: &types.IndexDocument{Suffix: aws.String("index.html")},
+			},
+		})
+		return err
+	}},
+	{"GetBucketWebsite", func(ctx context.Context, c *checker) error {
+		out, err := c.client.GetBucketWebsite(ctx, &s3.GetBucketWebsiteInput{Bucket: &c.bucket})
+		if err != nil {
+			return err
+		}
+		if out.IndexDocument == nil || aws.ToString(out.IndexDocument.Suffix) != "index.html" {
+			return errors.New("index document is not index.html")
+		}
+		return nil
+	}},
+	{"DeleteBucketWebsite", func(ctx context.Context, c *checker) error {
+		_, err := c.client.DeleteBucketWebsite(ctx, &s3.DeleteBucketWebsiteInput{Bucket: &c.bucket})
+		return err
+	}},
+	{"CreateMultipartUpload", func(ctx context.Context, c *checker) error {
+		// The upload is continued by the checks below, which find it by key
+		_, err := c.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: &c.bucket, Key: aws.String(checkMPUKey)})
+		return err
+	}},
+	{"ListMultipartUploads", func(ctx context.Context, c *checker) error {
+		_, err := c.uploadID(ctx)
+		return err
+	}},
+	{"UploadPart", func(ctx context.Context, c *checker) error {
+		uploadID, err := c.uploadID(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = c.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     &c.bucket,
+			Key:        aws.String(checkMPUKey),
+			UploadId:   &uploadID,
+			PartNumber: aws.Int32(1),
+			Body:       bytes.NewReader([]byte(checkContent)),
+		})
+		return err
+	}},
+	{"ListParts", func(ctx context.Context, c *checker) error {
+		uploadID, err := c.uploadID(ctx)
+		if err != nil {
+			return err
+		}
+		out, err := c.client.ListParts(ctx, &s3.ListPartsInput{Bucket: &c.bucket, Key: aws.String(checkMPUKey), UploadId: &uploadID})
+		if err != nil {
+			return err
+		}
+		if len(out.Parts) != 1 {
+			return fmt.Errorf("got %d parts, want 1", len(out.Parts))
+		}
+		return nil
+	}},
+	{"CompleteMultipartUpload", func(ctx context.Context, c *checker) error {
+		uploadID, err := c.uploadID(ctx)
+		if err != nil {
+			return err
+		}
+		parts, err := c.client.ListParts(ctx, &s3.ListPartsInput{Bucket: &c.bucket, Key: aws.String(checkMPUKey), UploadId: &uploadID})
+		if err != nil {
+			return err
+		}
+		var completed []types.CompletedPart
+		for _, part := range parts.Parts {
+			completed = append(completed, types.CompletedPart{ETag: part.ETag, PartNumber: part.PartNumber})
+		}
+		_, err = c.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          &c.bucket,
+			Key:             aws.String(checkMPUKey),
+			UploadId:        &uploadID,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+		})
+		if err != nil {
+			return err
+		}
+		return c.expectContent(ctx, checkMPUKey, "", checkContent)
+	}},
+	{"UploadPartCopy", func(ctx context.Context, c *checker) error {
+		out, err := c.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: &c.bucket, Key: aws.String(checkMPUKey)})
+		if err != nil {
+			return err
+		}
+		_, err = c.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:     &c.bucket,
+			Key:        aws.String(checkMPUKey),
+			UploadId:   out.UploadId,
+			PartNumber: aws.Int32(1),
+			CopySource: aws.String(c.bucket + "/" + checkKey),
+		})
+		return err
+	}},
+	{"AbortMultipartUpload", func(ctx context.Context, c *checker) error {
+		uploadID, err := c.uploadID(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = c.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: &c.bucket, Key: aws.String(checkMPUKey), UploadId: &uploadID})
+		return err
+	}},
+	{"DeleteObjects", func(ctx context.Context, c *checker) error {
+		out, err := c.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: &c.bucket,
+			Delete: &types.Delete{Objects: []types.ObjectIdentifier{{Key: aws.String(checkCopyKey)}, {Key: aws.String(checkMPUKey)}}},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("%d objects were not deleted: %s", len(out.Errors), aws.ToString(out.Errors[0].Message))
+		}
+		return nil
+	}},
+	{"DeleteObject", func(ctx context.Context, c *checker) error {
+		if _, err := c.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &c.bucket, Key: aws.String(checkKey)}); err != nil {
+			return err
+		}
+		_, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &c.bucket, Key: aws.String(checkKey)})
+		if err == nil {
+			return errors.New("object still exists")
+		}
+		return nil
+	}},
+	{"PutBucketVersioning", func(ctx context.Context, c *checker) error {
+		_, err := c.client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+			Bucket:                  &c.bucket,
+			VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+		})
+		return err
+	}},
+	{"GetBucketVersioning", func(ctx context.Context, c *checker) error {
+		out, err := c.client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: &c.bucket})
+		if err != nil {
+			return err
+		}
+		if out.Status != types.BucketVersioningStatusEnabled {
+			return fmt.Errorf("versioning status is %q", out.Status)
+		}
+		return nil
+	}},
+	{"ListObjectVersions", func(ctx context.Context, c *checker) error {
+		var versionIDs []string
+		for _, content := range []string{"first\n", "second\n"} {
+			out, err := c.client.PutObject(ctx, &s3.PutObjectInput{Bucket: &c.bucket, Key: aws.String(checkKey), Body: strings.NewReader(content)})
+			if err != nil {
+				return err
+			}
+			versionIDs = append(versionIDs, aws.ToString(out.VersionId))
+		}
+		out, err := c.client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: &c.bucket, Prefix: aws.String(checkKey)})
+		if err != nil {
+			return err
+		}
+		if len(out.Versions) != 2 {
+			return fmt.Errorf("got %d versions, want 2", len(out.Versions))
+		}
+		// An older version is still readable
+		return c.expectContent(ctx, checkKey, versionIDs[0], "first\n")
+	}},
+	{"DeleteBucket", func(ctx context.Context, c *checker) error {
+		if err := c.empty(ctx); err != nil {
+			return err
+		}
+		_, err := c.client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: &c.bucket})
+		return err
+	}},
+}
+
+// Run checks the S3 operations of the endpoint of a client. The checks use a
+// new bucket named jog-conformance-<random>, which is deleted afterwards.
+func Run(ctx context.Context, client *s3.Client) *Report {
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	c := &checker{client: client, bucket: "jog-conformance-" + hex.EncodeToString(suffix)}
+	report := &Report{Bucket: c.bucket}
+
+	checked := make(map[string]bool)
+	created, deleted := false, false
+	for _, chk := range checks {
+		checked[chk.operation] = true
+		result := Result{Operation: chk.operation}
+		if chk.operation != "CreateBucket" && !created {
+			result.Status = StatusSkipped
+			result.Error = "CreateBucket failed"
+			report.Results = append(report.Results, result)
+			continue
+		}
+		err := chk.run(ctx, c)
+		result.Status = classify(err)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		switch {
+		case chk.operation == "CreateBucket":
+			created = err == nil
+		case chk.operation == "DeleteBucket":
+			deleted = err == nil
+		}
+		report.Results = append(report.Results, result)
+	}
+	if created && !deleted {
+		// Leave nothing behind on the endpoint, even when checks failed
+		if c.empty(ctx) == nil {
+			c.client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: &c.bucket})
+		}
+	}
+
+	for _, op := range Operations {
+		if op.Implemented && !checked[op.Name] {
+			report.Unchecked = append(report.Unchecked, op.Name)
+		}
+	}
+	return report
+}
+
+// classify returns the status of a check from its error. Endpoints reject
+// operations they do not implement with NotImplemented or 405 Method Not
+// Allowed.
+func classify(err error) Status {
+	if err == nil {
+		return StatusPass
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented" {
+		return StatusUnsupported
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusNotImplemented, http.StatusMethodNotAllowed:
+			return StatusUnsupported
+		}
+	}
+	return StatusFail
+}
+
+// expectContent reads an object, or a version of it, and compares its data.
+func (c *checker) expectContent(ctx context.Context, key, versionID, want string) error {
+	input := &s3.GetObjectInput{Bucket: &c.bucket, Key: &key}
+	if versionID != "" {
+		input.VersionId = &versionID
+	}
+	out, err := c.client.GetObject(ctx, input)
+	if err != nil {
+		return err
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return err
+	}
+	if string(data) != want {
+		return fmt.Errorf("object %s has data %q, want %q", key, data, want)
+	}
+	return nil
+}
+
+// uploadID finds the multipart upload of the check key.
+func (c *checker) uploadID(ctx context.Context) (string, error) {
+	out, err := c.client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: &c.bucket})
+	if err != nil {
+		return "", err
+	}
+	for _, upload := range out.Uploads {
+		if aws.ToString(upload.Key) == checkMPUKey {
+			return aws.ToString(upload.UploadId), nil
+		}
+	}
+	return "", fmt.Errorf("upload of %s is not listed", checkMPUKey)
+}
+
+// empty deletes the objects, versions and multipart uploads of the bucket.
+func (c *checker) empty(ctx context.Context) error {
+	uploads, err := c.client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: &c.bucket})
+	if err != nil {
+		return err
+	}
+	for _, upload := range uploads.Uploads {
+		if _, err := c.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: &c.bucket, Key: upload.Key, UploadId: upload.UploadId}); err != nil {
+			return err
+		}
+	}
+
+	versions, err := c.client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: &c.bucket})
+	if err != nil {
+		return err
+	}
+	var objects []types.ObjectIdentifier
+	for _, v := range versions.Versions {
+		objects = append(objects, types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+	}
+	for _, m := range versions.DeleteMarkers {
+		objects = append(objects, types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	_, err = c.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{Bucket: &c.bucket, Delete: &types.Delete{Objects: objects}})
+	return err
+}
+
+func expectKeys(objects []types.Object, want ...string) error {
+	var keys []string
+	for _, obj := range objects {
+		keys = append(keys, aws.ToString(obj.Key))
+	}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		return fmt.Errorf("listed keys %v, want %v", keys, want)
+	}
+	return nil
+}
+
+func expectTag(tags []types.Tag, key, value string) error {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key && aws.ToString(tag.Value) == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("tag %s=%s is missing from %d tags", key, value, len(tags))
+}
@@ -0,0 +1,177 @@
+// Package conformance describes the S3 operations JOG implements and checks
+// which of them an S3 endpoint supports, so users can judge whether JOG, or
+// another endpoint, is a drop-in replacement for the S3 calls they make.
+package conformance
+
+import (
+	"encoding/json"
+	"io"
+)
+
+//go:generate sh -c "go run ../../cmd/jog conformance describe > ../../docs/s3-operations.json"
+
+// Operation describes an S3 operation and how requests select it.
+type Operation struct {
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	// Path is "/", "/{bucket}" or "/{bucket}/{key}".
+	Path string `json:"path"`
+	// Query are the query parameters selecting the operation, such as
+	// "tagging" or "list-type=2".
+	Query []string `json:"query,omitempty"`
+	// Header is a request header selecting the operation.
+	Header string `json:"header,omitempty"`
+	// Parameters are the optional query parameters and headers JOG
+	// supports, where they matter to clients.
+	Parameters []string `json:"parameters,omitempty"`
+	// Extension marks operations JOG adds to the S3 API.
+	Extension   bool `json:"extension,omitempty"`
+	Implemented bool `json:"implemented"`
+}
+
+// Operations is the S3 surface of JOG: the operations it routes to handlers
+// and the S3 operations it rejects with NotImplemented. It must be kept in
+// sync with the router.
+var Operations = []Operation{
+	// Buckets
+	{Name: "ListBuckets", Method: "GET", Path: "/", Implemented: true},
+	{Name: "CreateBucket", Method: "PUT", Path: "/{bucket}", Parameters: []string{"x-amz-acl", "x-amz-bucket-object-lock-enabled"}, Implemented: true},
+	{Name: "DeleteBucket", Method: "DELETE", Path: "/{bucket}", Parameters: []string{"force", "x-minio-force-delete"}, Implemented: true},
+	{Name: "HeadBucket", Method: "HEAD", Path: "/{bucket}", Implemented: true},
+	{Name: "GetBucketLocation", Method: "GET", Path: "/{bucket}", Query: []string{"location"}, Implemented: true},
+	{Name: "CreateSession", Method: "GET", Path: "/{bucket}", Query: []string{"session"}, Parameters: []string{"x-amz-create-session-mode"}, Implemented: true},
+
+	// Listing
+	{Name: "ListObjects", Method: "GET", Path: "/{bucket}", Parameters: []string{"prefix", "delimiter", "marker", "max-keys"}, Implemented: true},
+	{Name: "ListObjectsV2", Method: "GET", Path: "/{bucket}", Query: []string{"list-type=2"}, Parameters: []string{"prefix", "delimiter", "max-keys", "continuation-token", "start-after", "encoding-type", "tag-key", "tag-value"}, Implemented: true},
+	{Name: "ListObjectVersions", Method: "GET", Path: "/{bucket}", Query: []string{"versions"}, Parameters: []string{"prefix", "delimiter", "key-marker", "version-id-marker", "max-keys"}, Implemented: true},
+	{Name: "ListMultipartUploads", Method: "GET", Path: "/{bucket}", Query: []string{"uploads"}, Parameters: []string{"prefix", "delimiter", "key-marker", "upload-id-marker", "max-uploads"}, Implemented: true},
+	{Name: "SearchObjects", Method: "GET", Path: "/{bucket}", Query: []string{"search"}, Extension: true, Implemented: true},
+
+	// Objects
+	{Name: "GetObject", Method: "GET", Path: "/{bucket}/{key}", Parameters: []string{"versionId", "partNumber", "Range", "x-amz-checksum-mode"}, Implemented: true},
+	{Name: "HeadObject", Method: "HEAD", Path: "/{bucket}/{key}", Parameters: []string{"versionId", "partNumber", "Range", "x-amz-checksum-mode"}, Implemented: true},
+	{Name: "PutObject", Method: "PUT", Path: "/{bucket}/{key}", Parameters: []string{
+		"x-amz-meta-*", "x-amz-tagging", "x-amz-acl", "x-amz-checksum-*",
+		"x-amz-server-side-encryption", "x-amz-server-side-encryption-aws-kms-key-id",
+		"x-amz-object-lock-mode", "x-amz-object-lock-retain-until-date", "x-amz-object-lock-legal-hold",
+	}, Implemented: true},
+	{Name: "CopyObject", Method: "PUT", Path: "/{bucket}/{key}", Header: "x-amz-copy-source", Parameters: []string{"x-amz-copy-source-version-id", "x-amz-metadata-directive"}, Implemented: true},
+	{Name: "DeleteObject", Method: "DELETE", Path: "/{bucket}/{key}", Parameters: []string{"versionId", "x-amz-mfa"}, Implemented: true},
+	{Name: "DeleteObjects", Method: "POST", Path: "/{bucket}", Query: []string{"delete"}, Implemented: true},
+	{Name: "GetObjectAttributes", Method: "GET", Path: "/{bucket}/{key}", Query: []string{"attributes"}, Parameters: []string{"x-amz-object-attributes", "x-amz-max-parts", "x-amz-part-number-marker"}, Implemented: true},
+	{Name: "AppendObject", Method: "PUT", Path: "/{bucket}/{key}", Query: []string{"append"}, Parameters: []string{"position"}, Extension: true, Implemented: true},
+
+	// Multipart uploads
+	{Name: "CreateMultipartUpload", Method: "POST", Path: "/{bucket}/{key}", Query: []string{"uploads"}, Parameters: []string{"x-amz-meta-*", "x-amz-tagging", "x-amz-checksum-algorithm", "x-amz-server-side-encryption"}, Implemented: true},
+	{Name: "UploadPart", Method: "PUT", Path: "/{bucket}/{key}", Query: []string{"partNumber", "uploadId"}, Parameters: []string{"Content-Range"}, Implemented: true},
+	{Name: "UploadPartCopy", Method: "PUT", Path: "/{bucket}/{key}", Query: []string{"partNumber", "uploadId"}, Header: "x-amz-copy-source", Parameters: []string{"x-amz-copy-source-range"}, Implemented: true},
+	{Name: "HeadPartUpload", Method: "HEAD", Path: "/{bucket}/{key}", Query: []string{"partNumber", "uploadId"}, Extension: true, Implemented: true},
+	{Name: "CompleteMultipartUpload", Method: "POST", Path: "/{bucket}/{key}", Query: []string{"uploadId"}, Implemented: true},
+	{Name: "AbortMultipartUpload", Method: "DELETE", Path: "/{bucket}/{key}", Query: []string{"uploadId"}, Implemented: true},
+	{Name: "ListParts", Method: "GET", Path: "/{bucket}/{key}", Query: []string{"uploadId"}, Parameters: []string{"max-parts", "part-number-marker"}, Implemented: true},
+
+	// Tagging
+	{Name: "GetObjectTagging", Method: "GET", Path: "/{bucket}/{key}", Query: []string{"tagging"}, Implemented: true},
+	{Name: "PutObjectTagging", Method: "PUT", Path: "/{bucket}/{key}", Query: []string{"tagging"}, Implemented: true},
+	{Name: "DeleteObjectTagging", Method: "DELETE", Path: "/{bucket}/{key}", Query: []string{"tagging"}, Implemented: true},
+	{Name: "GetBucketTagging", Method: "GET", Path: "/{bucket}", Query: []string{"tagging"}, Implemented: true},
+	{Name: "PutBucketTagging", Method: "PUT", Path: "/{bucket}", Query: []string{"tagging"}, Implemented: true},
+	{Name: "DeleteBucketTagging", Method: "DELETE", Path: "/{bucket}", Query: []string{"tagging"}, Implemented: true},
+
+	// Access control
+	{Name: "GetBucketAcl", Method: "GET", Path: "/{bucket}", Query: []string{"acl"}, Implemented: true},
+	{Name: "PutBucketAcl", Method: "PUT", Path: "/{bucket}", Query: []string{"acl"}, Implemented: true},
+	{Name: "GetObjectAcl", Method: "GET", Path: "/{bucket}/{key}", Query: []string{"acl"}, Implemented: true},
+	{Name: "PutObjectAcl", Method: "PUT", Path: "/{bucket}/{key}", Query: []string{"acl"}, Implemented: true},
+	{Name: "GetBucketPolicy", Method: "GET", Path: "/{bucket}", Query: []string{"policy"}, Implemented: true},
+	{Name: "PutBucketPolicy", Method: "PUT", Path: "/{bucket}", Query: []string{"policy"}, Implemented: true},
+	{Name: "DeleteBucketPolicy", Method: "DELETE", Path: "/{bucket}", Query: []string{"policy"}, Implemented: true},
+	{Name: "GetBucketCors", Method: "GET", Path: "/{bucket}", Query: []string{"cors"}, Implemented: true},
+	{Name: "PutBucketCors", Method: "PUT", Path: "/{bucket}", Query: []string{"cors"}, Implemented: true},
+	{Name: "DeleteBucketCors", Method: "DELETE", Path: "/{bucket}", Query: []string{"cors"}, Implemented: true},
+
+	// Bucket configuration
+	{Name: "GetBucketVersioning", Method: "GET", Path: "/{bucket}", Query: []string{"versioning"}, Implemented: true},
+	{Name: "PutBucketVersioning", Method: "PUT", Path: "/{bucket}", Query: []string{"versioning"}, Parameters: []string{"x-amz-mfa"}, Implemented: true},
+	{Name: "GetBucketEncryption", Method: "GET", Path: "/{bucket}", Query: []string{"encryption"}, Implemented: true},
+	{Name: "PutBucketEncryption", Method: "PUT", Path: "/{bucket}", Query: []string{"encryption"}, Implemented: true},
+	{Name: "DeleteBucketEncryption", Method: "DELETE", Path: "/{bucket}", Query: []string{"encryption"}, Implemented: true},
+	{Name: "GetBucketLifecycleConfiguration", Method: "GET", Path: "/{bucket}", Query: []string{"lifecycle"}, Implemented: true},
+	{Name: "PutBucketLifecycleConfiguration", Method: "PUT", Path: "/{bucket}", Query: []string{"lifecycle"}, Implemented: true},
+	{Name: "DeleteBucketLifecycle", Method: "DELETE", Path: "/{bucket}", Query: []string{"lifecycle"}, Implemented: true},
+	{Name: "GetBucketWebsite", Method: "GET", Path: "/{bucket}", Query: []string{"website"}, Implemented: true},
+	{Name: "PutBucketWebsite", Method: "PUT", Path: "/{bucket}", Query: []string{"website"}, Implemented: true},
+	{Name: "DeleteBucketWebsite", Method: "DELETE", Path: "/{bucket}", Query: []string{"website"}, Implemented: true},
+
+	// Object lock
+	{Name: "GetObjectLockConfiguration", Method: "GET", Path: "/{bucket}", Query: []string{"object-lock"}, Implemented: true},
+	{Name: "PutObjectLockConfiguration", Method: "PUT", Path: "/{bucket}", Query: []string{"object-lock"}, Implemented: true},
+	{Name: "GetObjectRetention", Method: "GET", Path: "/{bucket}/{key}", Query: []string{"retention"}, Implemented: true},
+	{Name: "PutObjectRetention", Method: "PUT", Path: "/{bucket}/{key}", Query: []string{"retention"}, Implemented: true},
+	{Name: "GetObjectLegalHold", Method: "GET", Path: "/{bucket}/{key}", Query: []string{"legal-hold"}, Implemented: true},
+	{Name: "PutObjectLegalHold", Method: "PUT", Path: "/{bucket}/{key}", Query: []string{"legal-hold"}, Implemented: true},
+
+	// JOG bucket configuration
+	{Name: "GetBucketTiering", Method: "GET", Path: "/{bucket}", Query: []string{"tiering"}, Extension: true, Implemented: true},
+	{Name: "PutBucketTiering", Method: "PUT", Path: "/{bucket}", Query: []string{"tiering"}, Extension: true, Implemented: true},
+	{Name: "DeleteBucketTiering", Method: "DELETE", Path: "/{bucket}", Query: []string{"tiering"}, Extension: true, Implemented: true},
+	{Name: "GetBucketCompression", Method: "GET", Path: "/{bucket}", Query: []string{"compression"}, Extension: true, Implemented: true},
+	{Name: "PutBucketCompression", Method: "PUT", Path: "/{bucket}", Query: []string{"compression"}, Extension: true, Implemented: true},
+	{Name: "DeleteBucketCompression", Method: "DELETE", Path: "/{bucket}", Query: []string{"compression"}, Extension: true, Implemented: true},
+	{Name: "GetBucketTrash", Method: "GET", Path: "/{bucket}", Query: []string{"trash"}, Extension: true, Implemented: true},
+	{Name: "PutBucketTrash", Method: "PUT", Path: "/{bucket}", Query: []string{"trash"}, Extension: true, Implemented: true},
+	{Name: "DeleteBucketTrash", Method: "DELETE", Path: "/{bucket}", Query: []string{"trash"}, Extension: true, Implemented: true},
+	{Name: "GetBucketVersionRetention", Method: "GET", Path: "/{bucket}", Query: []string{"version-retention"}, Extension: true, Implemented: true},
+	{Name: "PutBucketVersionRetention", Method: "PUT", Path: "/{bucket}", Query: []string{"version-retention"}, Extension: true, Implemented: true},
+	{Name: "DeleteBucketVersionRetention", Method: "DELETE", Path: "/{bucket}", Query: []string{"version-retention"}, Extension: true, Implemented: true},
+	{Name: "GetBucketContentTypes", Method: "GET", Path: "/{bucket}", Query: []string{"content-types"}, Extension: true, Implemented: true},
+	{Name: "PutBucketContentTypes", Method: "PUT", Path: "/{bucket}", Query: []string{"content-types"}, Extension: true, Implemented: true},
+	{Name: "DeleteBucketContentTypes", Method: "DELETE", Path: "/{bucket}", Query: []string{"content-types"}, Extension: true, Implemented: true},
+	{Name: "GetBucketDefaultTags", Method: "GET", Path: "/{bucket}", Query: []string{"default-tags"}, Extension: true, Implemented: true},
+	{Name: "PutBucketDefaultTags", Method: "PUT", Path: "/{bucket}", Query: []string{"default-tags"}, Extension: true, Implemented: true},
+	{Name: "DeleteBucketDefaultTags", Method: "DELETE", Path: "/{bucket}", Query: []string{"default-tags"}, Extension: true, Implemented: true},
+	{Name: "GetBucketResponseHeaders", Method: "GET", Path: "/{bucket}", Query: []string{"response-headers"}, Extension: true, Implemented: true},
+	{Name: "PutBucketResponseHeaders", Method: "PUT", Path: "/{bucket}", Query: []string{"response-headers"}, Extension: true, Implemented: true},
+	{Name: "DeleteBucketResponseHeaders", Method: "DELETE", Path: "/{bucket}", Query: []string{"response-headers"}, Extension: true, Implemented: true},
+	{Name: "GetBucketSkipUnchanged", Method: "GET", Path: "/{bucket}", Query: []string{"skip-unchanged"}, Extension: true, Implemented: true},
+	{Name: "PutBucketSkipUnchanged", Method: "PUT", Path: "/{bucket}", Query: []string{"skip-unchanged"}, Extension: true, Implemented: true},
+	{Name: "GetBucketWorm", Method: "GET", Path: "/{bucket}", Query: []string{"worm"}, Extension: true, Implemented: true},
+	{Name: "PutBucketWorm", Method: "PUT", Path: "/{bucket}", Query: []string{"worm"}, Extension: true, Implemented: true},
+
+	// Not implemented
+	{Name: "GetBucketAccelerateConfiguration", Method: "GET", Path: "/{bucket}", Query: []string{"accelerate"}},
+	{Name: "PutBucketAccelerateConfiguration", Method: "PUT", Path: "/{bucket}", Query: []string{"accelerate"}},
+	{Name: "GetBucketAnalyticsConfiguration", Method: "GET", Path: "/{bucket}", Query: []string{"analytics"}},
+	{Name: "GetBucketIntelligentTieringConfiguration", Method: "GET", Path: "/{bucket}", Query: []string{"intelligent-tiering"}},
+	{Name: "GetBucketInventoryConfiguration", Method: "GET", Path: "/{bucket}", Query: []string{"inventory"}},
+	{Name: "GetBucketLogging", Method: "GET", Path: "/{bucket}", Query: []string{"logging"}},
+	{Name: "PutBucketLogging", Method: "PUT", Path: "/{bucket}", Query: []string{"logging"}},
+	{Name: "GetBucketMetricsConfiguration", Method: "GET", Path: "/{bucket}", Query: []string{"metrics"}},
+	{Name: "GetBucketNotificationConfiguration", Method: "GET", Path: "/{bucket}", Query: []string{"notification"}},
+	{Name: "PutBucketNotificationConfiguration", Method: "PUT", Path: "/{bucket}", Query: []string{"notification"}},
+	{Name: "GetBucketOwnershipControls", Method: "GET", Path: "/{bucket}", Query: []string{"ownershipControls"}},
+	{Name: "PutBucketOwnershipControls", Method: "PUT", Path: "/{bucket}", Query: []string{"ownershipControls"}},
+	{Name: "GetBucketPolicyStatus", Method: "GET", Path: "/{bucket}", Query: []string{"policyStatus"}},
+	{Name: "GetPublicAccessBlock", Method: "GET", Path: "/{bucket}", Query: []string{"publicAccessBlock"}},
+	{Name: "PutPublicAccessBlock", Method: "PUT", Path: "/{bucket}", Query: []string{"publicAccessBlock"}},
+	{Name: "GetBucketReplication", Method: "GET", Path: "/{bucket}", Query: []string{"replication"}},
+	{Name: "PutBucketReplication", Method: "PUT", Path: "/{bucket}", Query: []string{"replication"}},
+	{Name: "GetBucketRequestPayment", Method: "GET", Path: "/{bucket}", Query: []string{"requestPayment"}},
+	{Name: "RestoreObject", Method: "POST", Path: "/{bucket}/{key}", Query: []string{"restore"}},
+	{Name: "SelectObjectContent", Method: "POST", Path: "/{bucket}/{key}", Query: []string{"select", "select-type=2"}},
+	{Name: "GetObjectTorrent", Method: "GET", Path: "/{bucket}/{key}", Query: []string{"torrent"}},
+}
+
+// Description is the machine-readable description of the S3 surface of JOG.
+type Description struct {
+	Service    string      `json:"service"`
+	Operations []Operation `json:"operations"`
+}
+
+// Describe writes the description of the S3 surface of JOG as indented JSON.
+func Describe(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Description{Service: "s3", Operations: Operations})
+}
@@ -0,0 +1,40 @@
+package conformance
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestDescribeMatchesDocs(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Describe(&buf); err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	want, err := os.ReadFile("../../docs/s3-operations.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Error("docs/s3-operations.json is outdated, run go generate ./internal/conformance")
+	}
+}
+
+func TestOperations(t *testing.T) {
+	names := make(map[string]bool)
+	for _, op := range Operations {
+		if names[op.Name] {
+			t.Errorf("operation %s is described twice", op.Name)
+		}
+		names[op.Name] = true
+		if op.Extension && !op.Implemented {
+			t.Errorf("extension %s is not implemented", op.Name)
+		}
+	}
+	// Every check is of a described, implemented operation
+	for _, chk := range checks {
+		if !names[chk.operation] {
+			t.Errorf("check of undescribed operation %s", chk.operation)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Status is the outcome of checking an operation.
+type Status string
+
+const (
+	// StatusPass means the operation behaved as S3 does.
+	StatusPass Status = "pass"
+	// StatusFail means the operation failed or returned unexpected data.
+	StatusFail Status = "fail"
+	// StatusUnsupported means the endpoint rejected the operation as not
+	// implemented.
+	StatusUnsupported Status = "unsupported"
+	// StatusSkipped means the operation was not checked because a check it
+	// depends on failed.
+	StatusSkipped Status = "skipped"
+)
+
+// Result is the outcome of checking one operation.
+type Result struct {
+	Operation string `json:"operation"`
+	Status    Status `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report is the conformance report of an endpoint.
+type Report struct {
+	Endpoint string   `json:"endpoint,omitempty"`
+	Bucket   string   `json:"bucket"`
+	Results  []Result `json:"results"`
+	// Unchecked are the operations JOG implements that have no check, such
+	// as JOG extensions and object lock operations.
+	Unchecked []string `json:"unchecked"`
+}
+
+// Count returns the number of results with a status.
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Gaps returns the results of the operations that did not pass.
+func (r *Report) Gaps() []Result {
+	var gaps []Result
+	for _, result := range r.Results {
+		if result.Status != StatusPass {
+			gaps = append(gaps, result)
+		}
+	}
+	return gaps
+}
+
+// WriteText writes a report as a table of the checked operations followed by
+// the gaps, the operations that did not pass.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPERATION\tSTATUS")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\n", result.Operation, result.Status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if r.Endpoint != "" {
+		fmt.Fprintf(w, "\nEndpoint: %s\n", r.Endpoint)
+	} else {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Passed: %d of %d operations (%d failed, %d unsupported, %d skipped)\n",
+		r.Count(StatusPass), len(r.Results), r.Count(StatusFail), r.Count(StatusUnsupported), r.Count(StatusSkipped))
+
+	if gaps := r.Gaps(); len(gaps) > 0 {
+		fmt.Fprintln(w, "\nGaps:")
+		for _, gap := range gaps {
+			fmt.Fprintf(w, "  %s (%s): %s\n", gap.Operation, gap.Status, gap.Error)
+		}
+	}
+	if len(r.Unchecked) > 0 {
+		fmt.Fprintf(w, "\nNot checked: %s\n", strings.Join(r.Unchecked, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package control
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/kumasuke/jog/internal/events"
+	controlv1 "github.com/kumasuke/jog/proto/jog/control/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Client calls the control service of a JOG server.
+type Client struct {
+	conn *grpc.ClientConn
+	rpc  controlv1.ControlClient
+}
+
+// NewClient creates a client for the control service listening on addr
+// ("host:port"), connecting with the TLS settings of tlsConfig.
+func NewClient(addr, accessKey, secretKey string, tlsConfig *tls.Config) (*Client, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithPerRPCCredentials(signer{accessKey: accessKey, secretKey: secretKey}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, rpc: controlv1.NewControlClient(conn)}, nil
+}
+
+// Close closes the connection of the client.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// GetMode returns the server mode.
+func (c *Client) GetMode(ctx context.Context) (string, error) {
+	resp, err := c.rpc.GetMode(ctx, &controlv1.GetModeRequest{})
+	return resp.GetMode(), err
+}
+
+// SetMode changes the server mode and returns the new mode.
+func (c *Client) SetMode(ctx context.Context, mode string) (string, error) {
+	resp, err := c.rpc.SetMode(ctx, &controlv1.SetModeRequest{Mode: mode})
+	return resp.GetMode(), err
+}
+
+// ListUsers lists the configured access keys.
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	resp, err := c.rpc.ListUsers(ctx, &controlv1.ListUsersRequest{})
+	if err != nil {
+		return nil, err
+	}
+	users := make([]User, 0, len(resp.GetUsers()))
+	for _, u := range resp.GetUsers() {
+		users = append(users, User{AccessKey: u.GetAccessKey(), Tenant: u.GetTenant()})
+	}
+	return users, nil
+}
+
+// GetBucketStats counts the objects and bytes of a bucket.
+func (c *Client) GetBucketStats(ctx context.Context, bucket string) (BucketStats, error) {
+	resp, err := c.rpc.GetBucketStats(ctx, &controlv1.GetBucketStatsRequest{Bucket: bucket})
+	if err != nil {
+		return BucketStats{}, err
+	}
+	return BucketStats{
+		Bucket:           resp.GetBucket(),
+		Objects:          resp.GetObjects(),
+		Bytes:            resp.GetBytes(),
+		MultipartUploads: resp.GetMultipartUploads(),
+		VersionBytes:     resp.GetVersionBytes(),
+		MultipartBytes:   resp.GetMultipartBytes(),
+	}, nil
+}
+
+// RunMaintenance runs a maintenance task and returns the number of items it processed.
+func (c *Client) RunMaintenance(ctx context.Context, task string) (int64, error) {
+	resp, err := c.rpc.RunMaintenance(ctx, &controlv1.RunMaintenanceRequest{Task: task})
+	return resp.GetProcessed(), err
+}
+
+// EventStream receives the events of a SubscribeEvents call.
+type EventStream struct {
+	stream grpc.ServerStreamingClient[controlv1.Event]
+	cancel context.CancelFunc
+}
+
+// Recv returns the next event. It returns io.EOF when the server ends the stream.
+func (s *EventStream) Recv() (events.Event, error) {
+	e, err := s.stream.Recv()
+	if err != nil {
+		return events.Event{}, err
+	}
+	return events.Event{
+		Type:      events.Type(e.GetType()),
+		Time:      time.Unix(0, e.GetTimeUnixNano()).UTC(),
+		Tenant:    e.GetTenant(),
+		Bucket:    e.GetBucket(),
+		Key:       e.GetKey(),
+		Size:      e.GetSize(),
+		ETag:      e.GetEtag(),
+		VersionID: e.GetVersionId(),
+		Principal: e.GetPrincipal(),
+		SourceIP:  e.GetSourceIp(),
+		Detail:    e.GetDetail(),
+	}, nil
+}
+
+// Close ends the stream.
+func (s *EventStream) Close() error {
+	s.cancel()
+	return nil
+}
+
+// SubscribeEvents streams object events of a bucket, or of all buckets if
+// bucket is empty, until ctx is cancelled or the stream is closed. It returns
+// once the subscription started.
+func (c *Client) SubscribeEvents(ctx context.Context, bucket, prefix string) (*EventStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := c.rpc.SubscribeEvents(ctx, &controlv1.SubscribeEventsRequest{Bucket: bucket, Prefix: prefix})
+	if err == nil {
+		_, err = stream.Header()
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &EventStream{stream: stream, cancel: cancel}, nil
+}
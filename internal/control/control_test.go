@@ -0,0 +1,263 @@
+package control
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kumasuke/jog/internal/events"
+	"github.com/kumasuke/jog/internal/storage"
+	controlv1 "github.com/kumasuke/jog/proto/jog/control/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeBackend serves fixed users and a single bucket "photos".
+type fakeBackend struct {
+	mode   string
+	broker *events.Broker
+	tasks  []string
+}
+
+func (b *fakeBackend) Authenticate(accessKey string, signedWith func(string) bool) (string, bool) {
+	switch {
+	case accessKey == "admin" && signedWith("admin-secret"):
+		return "", true
+	case accessKey == "tenant" && signedWith("tenant-secret"):
+		return "acme", true
+	default:
+		return "", false
+	}
+}
+
+func (b *fakeBackend) Mode() string { return b.mode }
+
+func (b *fakeBackend) SetMode(mode string) error {
+	if mode != "normal" && mode != "read-only" {
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidArgument, mode)
+	}
+	b.mode = mode
+	return nil
+}
+
+func (b *fakeBackend) Users() []User {
+	return []User{{AccessKey: "admin"}, {AccessKey: "tenant", Tenant: "acme"}}
+}
+
+func (b *fakeBackend) BucketStats(ctx context.Context, bucket string) (BucketStats, error) {
+	if bucket != "photos" {
+		return BucketStats{}, storage.ErrBucketNotFound
+	}
+	return BucketStats{Bucket: bucket, Objects: 3, Bytes: 1 << 40, MultipartUploads: int64(len(storage.TenantFromContext(ctx))), VersionBytes: 1 << 41, MultipartBytes: 5}, nil
+}
+
+func (b *fakeBackend) RunMaintenance(ctx context.Context, task string) (int, error) {
+	b.tasks = append(b.tasks, task)
+	return 7, nil
+}
+
+func (b *fakeBackend) Events() *events.Broker { return b.broker }
+
+// testCertificate returns the TLS settings of a server with a self-signed
+// certificate for 127.0.0.1 and of clients trusting it.
+func testCertificate(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		&tls.Config{RootCAs: pool}
+}
+
+// startServer serves the control service on a random port and returns its
+// address and the TLS settings of clients.
+func startServer(t *testing.T) (string, *tls.Config, *fakeBackend) {
+	t.Helper()
+	backend := &fakeBackend{mode: "normal", broker: events.NewBroker()}
+
+	serverTLS, clientTLS := testCertificate(t)
+	srv := NewServer(backend, grpc.Creds(credentials.NewTLS(serverTLS)))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() {
+		backend.broker.Close()
+		srv.Stop()
+	})
+	return ln.Addr().String(), clientTLS, backend
+}
+
+// newClient creates a client of the control service.
+func newClient(t *testing.T, addr string, tlsConfig *tls.Config, accessKey, secretKey string) *Client {
+	t.Helper()
+	client, err := NewClient(addr, accessKey, secretKey, tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// statusCode returns the gRPC status code of a call error.
+func statusCode(err error) codes.Code {
+	return status.Code(err)
+}
+
+func TestUnaryCalls(t *testing.T) {
+	addr, clientTLS, backend := startServer(t)
+	client := newClient(t, addr, clientTLS, "admin", "admin-secret")
+	ctx := context.Background()
+
+	mode, err := client.SetMode(ctx, "read-only")
+	if err != nil || mode != "read-only" || backend.mode != "read-only" {
+		t.Fatalf("SetMode = %q, %v", mode, err)
+	}
+	if mode, err := client.GetMode(ctx); err != nil || mode != "read-only" {
+		t.Errorf("GetMode = %q, %v", mode, err)
+	}
+	if _, err := client.SetMode(ctx, "bogus"); statusCode(err) != codes.InvalidArgument {
+		t.Errorf("SetMode(bogus) error = %v", err)
+	}
+
+	users, err := client.ListUsers(ctx)
+	if err != nil || len(users) != 2 || users[1] != (User{AccessKey: "tenant", Tenant: "acme"}) {
+		t.Errorf("ListUsers = %v, %v", users, err)
+	}
+
+	stats, err := client.GetBucketStats(ctx, "photos")
+	if err != nil || stats != (BucketStats{Bucket: "photos", Objects: 3, Bytes: 1 << 40, VersionBytes: 1 << 41, MultipartBytes: 5}) {
+		t.Errorf("GetBucketStats = %+v, %v", stats, err)
+	}
+	if _, err := client.GetBucketStats(ctx, "missing"); statusCode(err) != codes.NotFound {
+		t.Errorf("GetBucketStats(missing) error = %v", err)
+	}
+
+	n, err := client.RunMaintenance(ctx, TaskScrubObjects)
+	if err != nil || n != 7 || len(backend.tasks) != 1 || backend.tasks[0] != TaskScrubObjects {
+		t.Errorf("RunMaintenance = %d, %v, tasks %v", n, err, backend.tasks)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	addr, clientTLS, _ := startServer(t)
+	ctx := context.Background()
+
+	bad := newClient(t, addr, clientTLS, "admin", "wrong")
+	if _, err := bad.GetMode(ctx); statusCode(err) != codes.Unauthenticated {
+		t.Errorf("GetMode with invalid credentials error = %v", err)
+	}
+
+	// Signatures are bound to the time and method of the call
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	rpc := controlv1.NewControlClient(conn)
+	stale := time.Now().Add(-time.Hour).UTC().Format(dateFormat)
+	for name, md := range map[string]metadata.MD{
+		"missing signature": {},
+		"secret key": metadata.Pairs(dateMetadata, time.Now().UTC().Format(dateFormat),
+			"authorization", signatureAlgorithm+" Credential=admin, Signature=admin-secret"),
+		"other method": metadata.Pairs(dateMetadata, time.Now().UTC().Format(dateFormat),
+			"authorization", signatureAlgorithm+" Credential=admin, Signature="+signature("admin-secret", time.Now().UTC().Format(dateFormat), controlv1.Control_GetBucketStats_FullMethodName)),
+		"stale date": metadata.Pairs(dateMetadata, stale,
+			"authorization", signatureAlgorithm+" Credential=admin, Signature="+signature("admin-secret", stale, controlv1.Control_GetMode_FullMethodName)),
+	} {
+		if _, err := rpc.GetMode(metadata.NewOutgoingContext(ctx, md), &controlv1.GetModeRequest{}); statusCode(err) != codes.Unauthenticated {
+			t.Errorf("GetMode with %s error = %v", name, err)
+		}
+	}
+
+	tenant := newClient(t, addr, clientTLS, "tenant", "tenant-secret")
+	if _, err := tenant.SetMode(ctx, "normal"); statusCode(err) != codes.PermissionDenied {
+		t.Errorf("SetMode as tenant error = %v", err)
+	}
+	if _, err := tenant.ListUsers(ctx); statusCode(err) != codes.PermissionDenied {
+		t.Errorf("ListUsers as tenant error = %v", err)
+	}
+	// Tenant calls run in the tenant namespace
+	stats, err := tenant.GetBucketStats(ctx, "photos")
+	if err != nil || stats.MultipartUploads != int64(len("acme")) {
+		t.Errorf("GetBucketStats as tenant = %+v, %v", stats, err)
+	}
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	addr, clientTLS, backend := startServer(t)
+	client := newClient(t, addr, clientTLS, "tenant", "tenant-secret")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.SubscribeEvents(ctx, "photos", "2024/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	now := time.Now().UTC()
+	backend.broker.Publish(events.Event{Type: events.ObjectCreatedPut, Time: now, Tenant: "acme", Bucket: "photos", Key: "2023/skipped.jpg"})
+	backend.broker.Publish(events.Event{Type: events.ObjectCreatedPut, Time: now, Tenant: "other", Bucket: "photos", Key: "2024/other-tenant.jpg"})
+	backend.broker.Publish(events.Event{Type: events.ObjectCreatedPut, Time: now, Tenant: "acme", Bucket: "photos", Key: "2024/a.jpg", Size: 42, ETag: `"abc"`})
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := events.Event{Type: events.ObjectCreatedPut, Time: now, Tenant: "acme", Bucket: "photos", Key: "2024/a.jpg", Size: 42, ETag: `"abc"`}
+	if event != want {
+		t.Errorf("Recv = %+v, want %+v", event, want)
+	}
+}
+
+func TestPlaintextConnectionsRejected(t *testing.T) {
+	addr, _, _ := startServer(t)
+
+	// Clients refuse to send signatures without TLS, and the server does
+	// not accept plaintext connections
+	if _, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(signer{accessKey: "admin", secretKey: "admin-secret"})); err == nil {
+		t.Error("NewClient without TLS succeeded")
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := controlv1.NewControlClient(conn).GetMode(ctx, &controlv1.GetModeRequest{}); statusCode(err) != codes.Unavailable {
+		t.Errorf("GetMode without TLS error = %v", err)
+	}
+}
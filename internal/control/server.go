@@ -0,0 +1,212 @@
+// Package control serves the control-plane API of JOG over gRPC.
+//
+// The service is defined in proto/jog/control/v1/control.proto, from which
+// the messages and stubs in proto/jog/control/v1 and clients in other
+// languages are generated. Calls are served over TLS and authenticated with a
+// signature of the call, so that secret keys are never sent.
+package control
+
+//go:generate protoc -I ../../proto --go_out=../../proto --go_opt=paths=source_relative --go-grpc_out=../../proto --go-grpc_opt=paths=source_relative jog/control/v1/control.proto
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kumasuke/jog/internal/events"
+	"github.com/kumasuke/jog/internal/storage"
+	controlv1 "github.com/kumasuke/jog/proto/jog/control/v1"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Maintenance tasks that can be run with RunMaintenance.
+const (
+	TaskAbortStaleUploads = "abort-stale-uploads"
+	TaskScrubObjects      = "scrub-objects"
+)
+
+// ErrInvalidArgument is returned by a Backend for invalid request values.
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// ErrUnavailable is returned by a Backend that cannot serve a call right now,
+// e.g. a standby asked to write.
+var ErrUnavailable = errors.New("unavailable")
+
+// Backend implements the calls of the control service.
+type Backend interface {
+	// Authenticate returns the tenant of an access key if signedWith reports
+	// that the call was signed with one of its secret keys; "" is the
+	// default namespace.
+	Authenticate(accessKey string, signedWith func(secretKey string) bool) (tenant string, ok bool)
+	Mode() string
+	SetMode(mode string) error
+	Users() []User
+	// BucketStats counts the objects of a bucket of the tenant in ctx.
+	BucketStats(ctx context.Context, bucket string) (BucketStats, error)
+	// RunMaintenance runs a task and returns the number of items it processed.
+	RunMaintenance(ctx context.Context, task string) (int, error)
+	Events() *events.Broker
+}
+
+// User is an access key and the tenant it belongs to.
+type User struct {
+	AccessKey string
+	// Tenant is empty for the default namespace.
+	Tenant string
+}
+
+// BucketStats is the number of objects, bytes and incomplete multipart uploads
+// of a bucket.
+type BucketStats struct {
+	Bucket           string
+	Objects          int64
+	Bytes            int64
+	MultipartUploads int64
+	VersionBytes     int64
+	MultipartBytes   int64
+}
+
+// Server implements the calls of the control service.
+type Server struct {
+	controlv1.UnimplementedControlServer
+	backend Backend
+}
+
+// NewServer creates a gRPC server of the control service for the backend.
+// opts should include the TLS credentials of the server.
+func NewServer(backend Backend, opts ...grpc.ServerOption) *grpc.Server {
+	s := &Server{backend: backend}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.authenticateUnary),
+		grpc.ChainStreamInterceptor(s.authenticateStream),
+	)
+	srv := grpc.NewServer(opts...)
+	controlv1.RegisterControlServer(srv, s)
+	return srv
+}
+
+// GetMode returns the server mode.
+func (s *Server) GetMode(ctx context.Context, _ *controlv1.GetModeRequest) (*controlv1.ModeResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return &controlv1.ModeResponse{Mode: s.backend.Mode()}, nil
+}
+
+// SetMode changes the server mode.
+func (s *Server) SetMode(ctx context.Context, req *controlv1.SetModeRequest) (*controlv1.ModeResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.backend.SetMode(req.GetMode()); err != nil {
+		return nil, statusFromError(err)
+	}
+	return &controlv1.ModeResponse{Mode: s.backend.Mode()}, nil
+}
+
+// ListUsers lists the configured access keys.
+func (s *Server) ListUsers(ctx context.Context, _ *controlv1.ListUsersRequest) (*controlv1.ListUsersResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	resp := &controlv1.ListUsersResponse{}
+	for _, u := range s.backend.Users() {
+		resp.Users = append(resp.Users, &controlv1.User{AccessKey: u.AccessKey, Tenant: u.Tenant})
+	}
+	return resp, nil
+}
+
+// GetBucketStats counts the objects of a bucket of the caller's tenant.
+func (s *Server) GetBucketStats(ctx context.Context, req *controlv1.GetBucketStatsRequest) (*controlv1.BucketStats, error) {
+	stats, err := s.backend.BucketStats(ctx, req.GetBucket())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &controlv1.BucketStats{
+		Bucket:           stats.Bucket,
+		Objects:          stats.Objects,
+		Bytes:            stats.Bytes,
+		MultipartUploads: stats.MultipartUploads,
+		VersionBytes:     stats.VersionBytes,
+		MultipartBytes:   stats.MultipartBytes,
+	}, nil
+}
+
+// RunMaintenance runs a maintenance task.
+func (s *Server) RunMaintenance(ctx context.Context, req *controlv1.RunMaintenanceRequest) (*controlv1.RunMaintenanceResponse, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	n, err := s.backend.RunMaintenance(ctx, req.GetTask())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &controlv1.RunMaintenanceResponse{Processed: int64(n)}, nil
+}
+
+// SubscribeEvents streams events of the caller's tenant until the call ends.
+func (s *Server) SubscribeEvents(req *controlv1.SubscribeEventsRequest, stream grpc.ServerStreamingServer[controlv1.Event]) error {
+	ctx := stream.Context()
+	sub := s.backend.Events().Subscribe(events.Filter{
+		Tenant: storage.TenantFromContext(ctx),
+		Bucket: req.GetBucket(),
+		Prefix: req.GetPrefix(),
+	})
+	defer sub.Close()
+
+	// Send the headers, so that the client knows the subscription started
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return status.Error(codes.Canceled, "call cancelled")
+		case event, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(&controlv1.Event{
+				Type:         string(event.Type),
+				TimeUnixNano: event.Time.UnixNano(),
+				Tenant:       event.Tenant,
+				Bucket:       event.Bucket,
+				Key:          event.Key,
+				Size:         event.Size,
+				Etag:         event.ETag,
+				VersionId:    event.VersionID,
+				Principal:    event.Principal,
+				SourceIp:     event.SourceIP,
+				Detail:       event.Detail,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// requireAdmin rejects calls with tenant credentials. Only the credentials of
+// the default namespace manage the server.
+func requireAdmin(ctx context.Context) error {
+	if storage.TenantFromContext(ctx) != "" {
+		return status.Error(codes.PermissionDenied, "tenant credentials cannot manage the server")
+	}
+	return nil
+}
+
+// statusFromError converts a backend error to a gRPC status.
+func statusFromError(err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidArgument):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storage.ErrBucketNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		log.Error().Err(err).Msg("Control call failed")
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,141 @@
+package control
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Calls are signed like S3 requests with Signature V4, but only the time and
+// the method of the call are signed: the payload is protected by TLS.
+const (
+	// signatureAlgorithm prefixes the authorization metadata entry and the
+	// string to sign.
+	signatureAlgorithm = "JOG-HMAC-SHA256"
+	// dateMetadata is the metadata entry holding the time of the call.
+	dateMetadata = "x-jog-date"
+	// dateFormat is the format of the time of the call.
+	dateFormat = "20060102T150405Z"
+	// maxClockSkew bounds the difference between the time of a call and the
+	// server time, which limits the replay of captured signatures.
+	maxClockSkew = 15 * time.Minute
+)
+
+// signature signs the call of a method at a time with a secret key.
+func signature(secretKey, date, method string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(signatureAlgorithm + "\n" + date + "\n" + method))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// authenticateUnary authenticates unary calls.
+func (s *Server) authenticateUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticateStream authenticates streaming calls.
+func (s *Server) authenticateStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream is a server stream with the tenant of its caller.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context { return s.ctx }
+
+// authenticate verifies the signature of a call and returns the context of
+// the call in the tenant of its access key.
+func (s *Server) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := first(md.Get("authorization"))
+	date := first(md.Get(dateMetadata))
+	params, ok := strings.CutPrefix(auth, signatureAlgorithm+" ")
+	if !ok || date == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing signature")
+	}
+
+	var accessKey, provided string
+	for _, part := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "Credential":
+			accessKey = v
+		case "Signature":
+			provided = v
+		}
+	}
+	if accessKey == "" || provided == "" {
+		return nil, status.Error(codes.Unauthenticated, "malformed authorization")
+	}
+
+	t, err := time.Parse(dateFormat, date)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "malformed "+dateMetadata)
+	}
+	if time.Since(t).Abs() > maxClockSkew {
+		return nil, status.Error(codes.Unauthenticated, "the difference between the call time and the server time is too large")
+	}
+
+	tenant, ok := s.backend.Authenticate(accessKey, func(secretKey string) bool {
+		return hmac.Equal([]byte(signature(secretKey, date, method)), []byte(provided))
+	})
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	if tenant != "" {
+		ctx = storage.WithTenant(ctx, tenant)
+	}
+	return ctx, nil
+}
+
+// first returns the first value of a metadata entry.
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// signer signs the calls of a client.
+type signer struct {
+	accessKey string
+	secretKey string
+}
+
+// GetRequestMetadata returns the signature metadata of a call.
+func (s signer) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	info, ok := credentials.RequestInfoFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "missing request info")
+	}
+	date := time.Now().UTC().Format(dateFormat)
+	return map[string]string{
+		dateMetadata: date,
+		"authorization": signatureAlgorithm + " Credential=" + s.accessKey +
+			", Signature=" + signature(s.secretKey, date, info.Method),
+	}, nil
+}
+
+// RequireTransportSecurity reports that signatures are only sent over TLS.
+func (signer) RequireTransportSecurity() bool { return true }
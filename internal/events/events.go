@@ -0,0 +1,153 @@
+// Package events publishes bucket activity to in-process subscribers.
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type is an event type, named like S3 event notifications.
+type Type string
+
+const (
+	// ObjectCreatedPut is published when an object is written by PutObject.
+	ObjectCreatedPut Type = "s3:ObjectCreated:Put"
+	// ObjectCreatedCopy is published when an object is written by CopyObject.
+	ObjectCreatedCopy Type = "s3:ObjectCreated:Copy"
+	// ObjectCreatedCompleteMultipartUpload is published when a multipart upload completes.
+	ObjectCreatedCompleteMultipartUpload Type = "s3:ObjectCreated:CompleteMultipartUpload"
+	// ObjectCreatedAppend is published when data is appended to an object (JOG extension).
+	ObjectCreatedAppend Type = "s3:ObjectCreated:Append"
+	// ObjectRemovedDelete is published when an object or object version is deleted.
+	ObjectRemovedDelete Type = "s3:ObjectRemoved:Delete"
+	// ObjectRemovedDeleteMarkerCreated is published when a delete marker is created in a versioned bucket.
+	ObjectRemovedDeleteMarkerCreated Type = "s3:ObjectRemoved:DeleteMarkerCreated"
+	// ObjectIntegrityCorrupted is published when the scrubber finds an object that
+	// does not match its checksums (JOG extension).
+	ObjectIntegrityCorrupted Type = "s3:ObjectIntegrity:Corrupted"
+)
+
+// subscriberBuffer is the number of events buffered per subscriber.
+const subscriberBuffer = 256
+
+// Event describes a change to an object.
+type Event struct {
+	Type      Type      `json:"type"`
+	Time      time.Time `json:"time"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	Size      int64     `json:"size,omitempty"`
+	ETag      string    `json:"etag,omitempty"`
+	VersionID string    `json:"versionId,omitempty"`
+	// Principal is the access key of the caller, empty for unauthenticated requests.
+	Principal string `json:"principal,omitempty"`
+	SourceIP  string `json:"sourceIp,omitempty"`
+	// Detail describes the problem found for ObjectIntegrityCorrupted events.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Filter selects the events a subscriber receives.
+type Filter struct {
+	// Tenant matches events of one tenant; "" is the default namespace.
+	Tenant string
+	// AllTenants matches events of every tenant, ignoring Tenant.
+	AllTenants bool
+	// Bucket matches events of one bucket. Empty matches all buckets.
+	Bucket string
+	// Prefix matches events for keys starting with it.
+	Prefix string
+}
+
+// Match reports whether the event passes the filter.
+func (f Filter) Match(e Event) bool {
+	return (f.AllTenants || e.Tenant == f.Tenant) &&
+		(f.Bucket == "" || e.Bucket == f.Bucket) &&
+		strings.HasPrefix(e.Key, f.Prefix)
+}
+
+// Subscription receives the events matching its filter.
+type Subscription struct {
+	// C delivers events. It is closed when the subscription is closed.
+	C <-chan Event
+
+	c      chan Event
+	filter Filter
+	broker *Broker
+}
+
+// Close stops the subscription. It is safe to call Close more than once.
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+// Broker fans events out to subscribers. It is safe for concurrent use.
+// Publish never blocks: events are dropped for subscribers that do not keep up.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBroker creates a Broker without subscribers.
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription to the events matching filter.
+// The caller must Close it.
+func (b *Broker) Subscribe(filter Filter) *Subscription {
+	c := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, filter: filter, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+		return sub
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Close closes all subscriptions, e.g. to end event streams on shutdown.
+// Subscriptions created afterwards are closed immediately.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.c)
+	}
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.c)
+	}
+}
+
+// Publish delivers an event to the matching subscribers.
+// A zero Time is set to the current time.
+func (b *Broker) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+		}
+	}
+}
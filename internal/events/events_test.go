@@ -0,0 +1,64 @@
+package events
+
+import "testing"
+
+func TestBrokerFilter(t *testing.T) {
+	b := NewBroker()
+	all := b.Subscribe(Filter{})
+	defer all.Close()
+	photos := b.Subscribe(Filter{Bucket: "bucket", Prefix: "photos/"})
+	defer photos.Close()
+
+	b.Publish(Event{Type: ObjectCreatedPut, Bucket: "bucket", Key: "photos/a.jpg"})
+	b.Publish(Event{Type: ObjectCreatedPut, Bucket: "bucket", Key: "docs/a.txt"})
+	b.Publish(Event{Type: ObjectRemovedDelete, Bucket: "other", Key: "photos/b.jpg"})
+	b.Publish(Event{Type: ObjectCreatedPut, Tenant: "acme", Bucket: "bucket", Key: "photos/c.jpg"})
+
+	if got := len(all.C); got != 3 {
+		t.Errorf("unfiltered subscriber got %d events, want 3", got)
+	}
+	if got := len(photos.C); got != 1 {
+		t.Fatalf("filtered subscriber got %d events, want 1", got)
+	}
+	e := <-photos.C
+	if e.Key != "photos/a.jpg" || e.Time.IsZero() {
+		t.Errorf("event = %+v, want photos/a.jpg with a time", e)
+	}
+}
+
+func TestBrokerSlowSubscriber(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe(Filter{})
+
+	for i := 0; i < subscriberBuffer+10; i++ {
+		b.Publish(Event{Type: ObjectCreatedPut, Bucket: "bucket", Key: "key"})
+	}
+	if got := len(sub.C); got != subscriberBuffer {
+		t.Errorf("buffered %d events, want %d", got, subscriberBuffer)
+	}
+
+	sub.Close()
+	sub.Close()
+	for range sub.C {
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe(Filter{})
+	b.Publish(Event{Type: ObjectCreatedPut, Bucket: "bucket", Key: "key"})
+
+	b.Close()
+	if _, ok := <-sub.C; !ok {
+		t.Error("buffered event was dropped by Close")
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription is still open after Close")
+	}
+	sub.Close()
+
+	late := b.Subscribe(Filter{})
+	if _, ok := <-late.C; ok {
+		t.Error("subscription created after Close is open")
+	}
+}
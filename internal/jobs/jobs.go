@@ -0,0 +1,515 @@
+// Package jobs runs batch operations on lists of objects, modeled on S3 Batch Operations.
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultConcurrency is the number of tasks a job runs in parallel unless set.
+	DefaultConcurrency = 4
+	// MaxConcurrency bounds the parallelism a job may request.
+	MaxConcurrency = 64
+	// maxFailureReasons is the number of task errors kept in the job status.
+	maxFailureReasons = 10
+)
+
+// Operation is the operation a job applies to each object of its manifest.
+type Operation string
+
+const (
+	// OperationCopy copies objects to a target bucket.
+	OperationCopy Operation = "copy"
+	// OperationTag replaces the tag set of objects.
+	OperationTag Operation = "tag"
+	// OperationACL applies a canned ACL to objects.
+	OperationACL Operation = "acl"
+	// OperationDelete deletes objects or object versions.
+	OperationDelete Operation = "delete"
+	// OperationRestore makes an object version the current version again.
+	OperationRestore Operation = "restore"
+)
+
+// Status is the state of a job.
+type Status string
+
+const (
+	// StatusActive is the status of a running job.
+	StatusActive Status = "Active"
+	// StatusComplete is the status of a job that processed all tasks, even if some failed.
+	StatusComplete Status = "Complete"
+	// StatusCancelled is the status of a job stopped before processing all tasks.
+	StatusCancelled Status = "Cancelled"
+	// StatusFailed is the status of a job whose completion report could not be written.
+	StatusFailed Status = "Failed"
+)
+
+// Errors returned by Manager.
+var (
+	ErrJobNotFound   = errors.New("job not found")
+	ErrInvalidSpec   = errors.New("invalid job")
+	ErrJobNotRunning = errors.New("job is not running")
+)
+
+// Location is an object in JOG.
+type Location struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+// PrefixLocation selects the current objects of a bucket whose keys start
+// with a prefix.
+type PrefixLocation struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// ReportLocation is where the completion report of a job is written.
+type ReportLocation struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix"`
+}
+
+// CopySpec holds the parameters of the copy operation.
+type CopySpec struct {
+	TargetBucket string `json:"targetBucket"`
+	// TargetKeyPrefix is prepended to the keys of the copies.
+	TargetKeyPrefix string `json:"targetKeyPrefix,omitempty"`
+}
+
+// TagMode is how the tag operation applies its tags.
+type TagMode string
+
+const (
+	// TagModeReplace replaces the tag set of objects.
+	TagModeReplace TagMode = "replace"
+	// TagModeMerge adds the tags to the tag set of objects, overwriting the
+	// values of tags with the same keys.
+	TagModeMerge TagMode = "merge"
+)
+
+// TagSpec holds the parameters of the tag operation.
+type TagSpec struct {
+	Tags []TagSpecTag `json:"tags"`
+	// Mode defaults to TagModeReplace.
+	Mode TagMode `json:"mode,omitempty"`
+}
+
+// TagSpecTag is a tag set by the tag operation.
+type TagSpecTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ACLSpec holds the parameters of the ACL operation.
+type ACLSpec struct {
+	CannedACL string `json:"cannedAcl"`
+}
+
+// Spec describes a job to submit.
+type Spec struct {
+	Operation Operation `json:"operation"`
+	// Manifest is a CSV object listing bucket, URL-encoded key and an optional
+	// version ID per row, like an S3 Batch Operations CSV manifest.
+	Manifest Location `json:"manifest"`
+	// Objects selects the objects by prefix instead of a manifest; they are
+	// listed when the job is submitted.
+	Objects     *PrefixLocation `json:"objects,omitempty"`
+	Copy        *CopySpec       `json:"copy,omitempty"`
+	Tagging     *TagSpec        `json:"tagging,omitempty"`
+	ACL         *ACLSpec        `json:"acl,omitempty"`
+	Report      *ReportLocation `json:"report,omitempty"`
+	Concurrency int             `json:"concurrency,omitempty"`
+}
+
+// validate checks the operation parameters of a spec.
+func (s *Spec) validate() error {
+	if s.Objects != nil {
+		if s.Manifest != (Location{}) {
+			return fmt.Errorf("%w: manifest and objects are mutually exclusive", ErrInvalidSpec)
+		}
+		if s.Objects.Bucket == "" {
+			return fmt.Errorf("%w: objects bucket is required", ErrInvalidSpec)
+		}
+	} else if s.Manifest.Bucket == "" || s.Manifest.Key == "" {
+		return fmt.Errorf("%w: manifest bucket and key are required", ErrInvalidSpec)
+	}
+	if s.Concurrency < 0 || s.Concurrency > MaxConcurrency {
+		return fmt.Errorf("%w: concurrency must be between 1 and %d", ErrInvalidSpec, MaxConcurrency)
+	}
+
+	switch s.Operation {
+	case OperationCopy:
+		if s.Copy == nil || s.Copy.TargetBucket == "" {
+			return fmt.Errorf("%w: copy requires a target bucket", ErrInvalidSpec)
+		}
+	case OperationTag:
+		if s.Tagging == nil {
+			return fmt.Errorf("%w: tag requires a tag set", ErrInvalidSpec)
+		}
+		switch s.Tagging.Mode {
+		case "", TagModeReplace, TagModeMerge:
+		default:
+			return fmt.Errorf("%w: unknown tag mode %q", ErrInvalidSpec, s.Tagging.Mode)
+		}
+	case OperationACL:
+		if s.ACL == nil {
+			return fmt.Errorf("%w: acl requires a canned ACL", ErrInvalidSpec)
+		}
+		switch storage.CannedACL(s.ACL.CannedACL) {
+		case storage.CannedACLPrivate, storage.CannedACLPublicRead, storage.CannedACLPublicReadWrite,
+			storage.CannedACLAuthenticatedRead, storage.CannedACLBucketOwnerRead, storage.CannedACLBucketOwnerFC:
+		default:
+			return fmt.Errorf("%w: unknown canned ACL %q", ErrInvalidSpec, s.ACL.CannedACL)
+		}
+	case OperationDelete, OperationRestore:
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidSpec, s.Operation)
+	}
+	return nil
+}
+
+// Job is the status of a submitted job.
+type Job struct {
+	ID             string     `json:"id"`
+	Operation      Operation  `json:"operation"`
+	Status         Status     `json:"status"`
+	TotalTasks     int64      `json:"totalTasks"`
+	SucceededTasks int64      `json:"succeededTasks"`
+	FailedTasks    int64      `json:"failedTasks"`
+	FailureReasons []string   `json:"failureReasons,omitempty"`
+	Report         *Location  `json:"report,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+}
+
+// job is a running or finished job.
+type job struct {
+	spec   Spec
+	tenant string
+	tasks  []task
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	succeeded atomic.Int64
+	failed    atomic.Int64
+
+	mu     sync.Mutex
+	status Job
+}
+
+// snapshot returns the current status of the job.
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := j.status
+	status.SucceededTasks = j.succeeded.Load()
+	status.FailedTasks = j.failed.Load()
+	status.FailureReasons = append([]string(nil), j.status.FailureReasons...)
+	return status
+}
+
+// fail records a failed task.
+func (j *job) fail(t task, err error) {
+	j.failed.Add(1)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.status.FailureReasons) < maxFailureReasons {
+		j.status.FailureReasons = append(j.status.FailureReasons, fmt.Sprintf("%s/%s: %v", t.Bucket, t.Key, err))
+	}
+}
+
+// Manager submits and tracks jobs. Jobs are kept in memory and do not survive a restart.
+type Manager struct {
+	store storage.Storage
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewManager creates a Manager running jobs against store.
+func NewManager(store storage.Storage) *Manager {
+	return &Manager{
+		store: store,
+		jobs:  make(map[string]*job),
+	}
+}
+
+// Submit reads the manifest of a job, or lists its objects, and starts it. ctx carries the tenant
+// and owner the job runs as; it only needs to live until Submit returns.
+func (m *Manager) Submit(ctx context.Context, spec Spec) (Job, error) {
+	if err := spec.validate(); err != nil {
+		return Job{}, err
+	}
+	if spec.Concurrency == 0 {
+		spec.Concurrency = DefaultConcurrency
+	}
+
+	var tasks []task
+	var err error
+	if spec.Objects != nil {
+		tasks, err = m.listObjects(ctx, *spec.Objects)
+	} else {
+		tasks, err = m.readManifest(ctx, spec.Manifest)
+	}
+	if err != nil {
+		return Job{}, err
+	}
+
+	// Jobs outlive the request, so only the identity is taken from its context
+	tenant := storage.TenantFromContext(ctx)
+	jobCtx := storage.WithOwner(context.Background(), storage.OwnerFromContext(ctx))
+	if tenant != "" {
+		jobCtx = storage.WithTenant(jobCtx, tenant)
+	}
+	jobCtx, cancel := context.WithCancel(jobCtx)
+
+	j := &job{
+		spec:   spec,
+		tenant: tenant,
+		tasks:  tasks,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: Job{
+			ID:         uuid.New().String(),
+			Operation:  spec.Operation,
+			Status:     StatusActive,
+			TotalTasks: int64(len(tasks)),
+			CreatedAt:  time.Now().UTC(),
+		},
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		cancel()
+		return Job{}, errors.New("job manager is closed")
+	}
+	m.jobs[j.status.ID] = j
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	log.Info().Str("job", j.status.ID).Str("operation", string(spec.Operation)).Int("tasks", len(tasks)).Msg("Batch job started")
+	go m.run(jobCtx, j)
+	return j.snapshot(), nil
+}
+
+// readManifest reads the tasks of a CSV manifest object.
+func (m *Manager) readManifest(ctx context.Context, manifest Location) ([]task, error) {
+	data, err := m.store.GetObject(ctx, manifest.Bucket, manifest.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read manifest: %v", ErrInvalidSpec, err)
+	}
+	defer data.Body.Close()
+
+	tasks, err := parseManifest(data.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+	}
+	return tasks, nil
+}
+
+// listObjects returns a task for each current object under a prefix.
+func (m *Manager) listObjects(ctx context.Context, objects PrefixLocation) ([]task, error) {
+	var tasks []task
+	input := &storage.ListObjectsInput{Bucket: objects.Bucket, Prefix: objects.Prefix, MaxKeys: 1000}
+	for {
+		output, err := m.store.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to list objects: %v", ErrInvalidSpec, err)
+		}
+		for _, obj := range output.Objects {
+			tasks = append(tasks, task{Bucket: objects.Bucket, Key: obj.Key})
+		}
+		if len(tasks) > maxManifestTasks {
+			return nil, fmt.Errorf("%w: prefix has more than %d objects", ErrInvalidSpec, maxManifestTasks)
+		}
+		if !output.IsTruncated {
+			break
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("%w: no objects under prefix", ErrInvalidSpec)
+	}
+	return tasks, nil
+}
+
+// run executes the tasks of a job with bounded concurrency and writes its report.
+func (m *Manager) run(ctx context.Context, j *job) {
+	defer m.wg.Done()
+	defer close(j.done)
+	defer j.cancel()
+
+	results := make([]error, len(j.tasks))
+	processed := make([]bool, len(j.tasks))
+	queue := make(chan int)
+	var workers sync.WaitGroup
+	for i := 0; i < j.spec.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range queue {
+				t := j.tasks[i]
+				processed[i] = true
+				if err := m.execute(ctx, j.spec, t); err != nil {
+					results[i] = err
+					j.fail(t, err)
+				} else {
+					j.succeeded.Add(1)
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range j.tasks {
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	workers.Wait()
+
+	status := StatusComplete
+	if ctx.Err() != nil {
+		status = StatusCancelled
+	}
+
+	var report *Location
+	if j.spec.Report != nil {
+		// The report is written even for cancelled jobs, so use a fresh context
+		reportCtx := storage.WithOwner(context.Background(), storage.OwnerFromContext(ctx))
+		if j.tenant != "" {
+			reportCtx = storage.WithTenant(reportCtx, j.tenant)
+		}
+		loc, err := m.writeReport(reportCtx, j, results, processed)
+		if err != nil {
+			log.Error().Err(err).Str("job", j.status.ID).Msg("Failed to write batch job report")
+			status = StatusFailed
+		} else {
+			report = &loc
+		}
+	}
+
+	now := time.Now().UTC()
+	j.mu.Lock()
+	j.status.Status = status
+	j.status.Report = report
+	j.status.FinishedAt = &now
+	j.mu.Unlock()
+
+	log.Info().Str("job", j.status.ID).
+		Str("status", string(status)).
+		Int64("succeeded", j.succeeded.Load()).
+		Int64("failed", j.failed.Load()).
+		Msg("Batch job finished")
+}
+
+// writeReport writes a CSV completion report with one row per processed task:
+// bucket, URL-encoded key, version ID, status and error. Tasks skipped by
+// cancellation are left out.
+func (m *Manager) writeReport(ctx context.Context, j *job, results []error, processed []bool) (Location, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for i, t := range j.tasks {
+		if !processed[i] {
+			continue
+		}
+		status, message := "succeeded", ""
+		if results[i] != nil {
+			status, message = "failed", results[i].Error()
+		}
+		if err := w.Write([]string{t.Bucket, encodeKey(t.Key), t.VersionID, status, message}); err != nil {
+			return Location{}, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return Location{}, err
+	}
+
+	loc := Location{
+		Bucket: j.spec.Report.Bucket,
+		Key:    j.spec.Report.Prefix + "job-" + j.status.ID + ".csv",
+	}
+	_, err := m.store.PutObject(ctx, loc.Bucket, loc.Key, &buf, int64(buf.Len()), "text/csv", map[string]string{
+		"job-id":          j.status.ID,
+		"succeeded-tasks": strconv.FormatInt(j.succeeded.Load(), 10),
+		"failed-tasks":    strconv.FormatInt(j.failed.Load(), 10),
+	})
+	return loc, err
+}
+
+// Get returns the status of a job of the tenant.
+func (m *Manager) Get(tenant, id string) (Job, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok || j.tenant != tenant {
+		return Job{}, ErrJobNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// List returns the jobs of the tenant, newest first.
+func (m *Manager) List(tenant string) []Job {
+	m.mu.Lock()
+	var list []Job
+	for _, j := range m.jobs {
+		if j.tenant == tenant {
+			list = append(list, j.snapshot())
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].CreatedAt.After(list[b].CreatedAt)
+	})
+	return list
+}
+
+// Cancel stops a running job of the tenant. Tasks in progress finish first.
+func (m *Manager) Cancel(tenant, id string) (Job, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok || j.tenant != tenant {
+		return Job{}, ErrJobNotFound
+	}
+	if j.snapshot().Status != StatusActive {
+		return Job{}, ErrJobNotRunning
+	}
+
+	j.cancel()
+	<-j.done
+	return j.snapshot(), nil
+}
+
+// Close cancels all running jobs and waits for them to stop.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	for _, j := range m.jobs {
+		j.cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
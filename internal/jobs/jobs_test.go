@@ -0,0 +1,298 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kumasuke/jog/internal/storage"
+)
+
+func newTestStore(t *testing.T) *storage.FileSystem {
+	t.Helper()
+	dir := t.TempDir()
+	fs, err := storage.NewFileSystem(dir, filepath.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("failed to create filesystem: %v", err)
+	}
+	t.Cleanup(func() { fs.Close() })
+	return fs
+}
+
+func putString(t *testing.T, store storage.Storage, bucket, key, content string) {
+	t.Helper()
+	if _, err := store.PutObject(context.Background(), bucket, key, strings.NewReader(content), int64(len(content)), "text/plain", nil); err != nil {
+		t.Fatalf("PutObject %s/%s: %v", bucket, key, err)
+	}
+}
+
+func getString(t *testing.T, store storage.Storage, bucket, key string) string {
+	t.Helper()
+	data, err := store.GetObject(context.Background(), bucket, key)
+	if err != nil {
+		t.Fatalf("GetObject %s/%s: %v", bucket, key, err)
+	}
+	defer data.Body.Close()
+	b, err := io.ReadAll(data.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func waitForJob(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get("", id)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if job.Status != StatusActive {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+func TestParseManifest(t *testing.T) {
+	tasks, err := parseManifest(strings.NewReader("bucket,a.txt\nbucket,dir/my+file%26.txt,v1\n"))
+	if err != nil {
+		t.Fatalf("parseManifest: %v", err)
+	}
+	want := []task{
+		{Bucket: "bucket", Key: "a.txt"},
+		{Bucket: "bucket", Key: "dir/my file&.txt", VersionID: "v1"},
+	}
+	if len(tasks) != len(want) {
+		t.Fatalf("got %d tasks, want %d", len(tasks), len(want))
+	}
+	for i := range want {
+		if tasks[i] != want[i] {
+			t.Errorf("task %d = %+v, want %+v", i, tasks[i], want[i])
+		}
+	}
+
+	for _, manifest := range []string{"", "bucket\n", "bucket,key,v1,extra\n", ",key\n", "bucket,%zz\n"} {
+		if _, err := parseManifest(strings.NewReader(manifest)); err == nil {
+			t.Errorf("parseManifest(%q) succeeded, want error", manifest)
+		}
+	}
+
+	if got := encodeKey("dir/my file&.txt"); got != "dir/my+file%26.txt" {
+		t.Errorf("encodeKey = %q", got)
+	}
+}
+
+func TestSubmitValidation(t *testing.T) {
+	store := newTestStore(t)
+	m := NewManager(store)
+	defer m.Close()
+
+	specs := []Spec{
+		{Operation: OperationDelete},
+		{Operation: "rename", Manifest: Location{Bucket: "b", Key: "m.csv"}},
+		{Operation: OperationCopy, Manifest: Location{Bucket: "b", Key: "m.csv"}},
+		{Operation: OperationACL, Manifest: Location{Bucket: "b", Key: "m.csv"}, ACL: &ACLSpec{CannedACL: "everyone"}},
+		{Operation: OperationDelete, Manifest: Location{Bucket: "b", Key: "m.csv"}, Concurrency: MaxConcurrency + 1},
+		{Operation: OperationDelete, Manifest: Location{Bucket: "missing", Key: "m.csv"}},
+		{Operation: OperationDelete, Manifest: Location{Bucket: "b", Key: "m.csv"}, Objects: &PrefixLocation{Bucket: "b"}},
+		{Operation: OperationTag, Objects: &PrefixLocation{Bucket: "b"}, Tagging: &TagSpec{Mode: "append"}},
+		{Operation: OperationDelete, Objects: &PrefixLocation{Bucket: "missing"}},
+	}
+	for _, spec := range specs {
+		if _, err := m.Submit(context.Background(), spec); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("Submit(%+v) error = %v, want ErrInvalidSpec", spec, err)
+		}
+	}
+}
+
+func TestCopyJobWithReport(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	for _, bucket := range []string{"source", "target", "manifests"} {
+		if err := store.CreateBucket(ctx, bucket); err != nil {
+			t.Fatalf("CreateBucket: %v", err)
+		}
+	}
+	putString(t, store, "source", "a.txt", "alpha")
+	putString(t, store, "source", "dir/b c.txt", "bravo")
+	putString(t, store, "manifests", "copy.csv", "source,a.txt\nsource,dir/b+c.txt\nsource,missing.txt\n")
+
+	m := NewManager(store)
+	defer m.Close()
+	job, err := m.Submit(ctx, Spec{
+		Operation:   OperationCopy,
+		Manifest:    Location{Bucket: "manifests", Key: "copy.csv"},
+		Copy:        &CopySpec{TargetBucket: "target", TargetKeyPrefix: "copied/"},
+		Report:      &ReportLocation{Bucket: "manifests", Prefix: "reports/"},
+		Concurrency: 2,
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if job.TotalTasks != 3 {
+		t.Errorf("TotalTasks = %d, want 3", job.TotalTasks)
+	}
+
+	job = waitForJob(t, m, job.ID)
+	if job.Status != StatusComplete || job.SucceededTasks != 2 || job.FailedTasks != 1 {
+		t.Errorf("job = %+v, want Complete with 2 succeeded and 1 failed", job)
+	}
+	if len(job.FailureReasons) != 1 || !strings.HasPrefix(job.FailureReasons[0], "source/missing.txt") {
+		t.Errorf("FailureReasons = %v", job.FailureReasons)
+	}
+	if got := getString(t, store, "target", "copied/dir/b c.txt"); got != "bravo" {
+		t.Errorf("copied object = %q, want bravo", got)
+	}
+
+	if job.Report == nil {
+		t.Fatal("job has no report")
+	}
+	report := getString(t, store, job.Report.Bucket, job.Report.Key)
+	for _, line := range []string{"source,a.txt,,succeeded,", "source,dir/b+c.txt,,succeeded,", "source,missing.txt,,failed,"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("report does not contain %q:\n%s", line, report)
+		}
+	}
+}
+
+func TestTagAndDeleteJobs(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	if err := store.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	putString(t, store, "bucket", "a.txt", "alpha")
+	putString(t, store, "bucket", "b.txt", "bravo")
+	putString(t, store, "bucket", "manifest.csv", "bucket,a.txt\nbucket,b.txt\n")
+
+	m := NewManager(store)
+	defer m.Close()
+
+	job, err := m.Submit(ctx, Spec{
+		Operation: OperationTag,
+		Manifest:  Location{Bucket: "bucket", Key: "manifest.csv"},
+		Tagging:   &TagSpec{Tags: []TagSpecTag{{Key: "team", Value: "data"}}},
+	})
+	if err != nil {
+		t.Fatalf("Submit tag: %v", err)
+	}
+	if job = waitForJob(t, m, job.ID); job.SucceededTasks != 2 {
+		t.Fatalf("tag job = %+v", job)
+	}
+	tags, err := store.GetObjectTagging(ctx, "bucket", "b.txt")
+	if err != nil || len(tags) != 1 || tags[0].Key != "team" || tags[0].Value != "data" {
+		t.Errorf("tags = %v, %v", tags, err)
+	}
+
+	job, err = m.Submit(ctx, Spec{
+		Operation: OperationDelete,
+		Manifest:  Location{Bucket: "bucket", Key: "manifest.csv"},
+	})
+	if err != nil {
+		t.Fatalf("Submit delete: %v", err)
+	}
+	if job = waitForJob(t, m, job.ID); job.SucceededTasks != 2 {
+		t.Fatalf("delete job = %+v", job)
+	}
+	if _, err := store.HeadObject(ctx, "bucket", "a.txt"); !errors.Is(err, storage.ErrObjectNotFound) {
+		t.Errorf("HeadObject after delete: %v", err)
+	}
+
+	if list := m.List(""); len(list) != 2 || list[0].Operation != OperationDelete {
+		t.Errorf("List = %+v, want the delete job first", list)
+	}
+	if _, err := m.Cancel("", job.ID); !errors.Is(err, ErrJobNotRunning) {
+		t.Errorf("Cancel of finished job: %v", err)
+	}
+	if _, err := m.Get("tenant", job.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Get from other tenant: %v", err)
+	}
+}
+
+func TestTagPrefixJob(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	if err := store.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	putString(t, store, "bucket", "logs/a.txt", "alpha")
+	putString(t, store, "bucket", "logs/b.txt", "bravo")
+	putString(t, store, "bucket", "other.txt", "other")
+	if err := store.PutObjectTagging(ctx, "bucket", "logs/a.txt", []storage.Tag{{Key: "team", Value: "web"}, {Key: "env", Value: "prod"}}); err != nil {
+		t.Fatalf("PutObjectTagging: %v", err)
+	}
+
+	m := NewManager(store)
+	defer m.Close()
+
+	job, err := m.Submit(ctx, Spec{
+		Operation: OperationTag,
+		Objects:   &PrefixLocation{Bucket: "bucket", Prefix: "logs/"},
+		Tagging:   &TagSpec{Tags: []TagSpecTag{{Key: "team", Value: "data"}}, Mode: TagModeMerge},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if job.TotalTasks != 2 {
+		t.Errorf("TotalTasks = %d, want 2", job.TotalTasks)
+	}
+	if job = waitForJob(t, m, job.ID); job.SucceededTasks != 2 {
+		t.Fatalf("tag job = %+v", job)
+	}
+
+	tags, err := store.GetObjectTagging(ctx, "bucket", "logs/a.txt")
+	want := []storage.Tag{{Key: "team", Value: "data"}, {Key: "env", Value: "prod"}}
+	if err != nil || len(tags) != 2 || !slices.Contains(tags, want[0]) || !slices.Contains(tags, want[1]) {
+		t.Errorf("merged tags = %v, %v, want %v", tags, err, want)
+	}
+	tags, err = store.GetObjectTagging(ctx, "bucket", "logs/b.txt")
+	if err != nil || len(tags) != 1 || tags[0] != want[0] {
+		t.Errorf("tags = %v, %v, want %v", tags, err, want[:1])
+	}
+	if tags, err := store.GetObjectTagging(ctx, "bucket", "other.txt"); err == nil && len(tags) != 0 {
+		t.Errorf("object outside the prefix was tagged: %v", tags)
+	}
+}
+
+func TestRestoreJob(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	if err := store.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := store.PutBucketVersioning(ctx, "bucket", storage.VersioningStatusEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	putString(t, store, "bucket", "manifest.csv", "bucket,a.txt\n")
+	if _, _, err := store.PutObjectVersioned(ctx, "bucket", "a.txt", strings.NewReader("alpha"), 5, "text/plain", nil); err != nil {
+		t.Fatalf("PutObjectVersioned: %v", err)
+	}
+	if _, _, err := store.DeleteObjectVersioned(ctx, "bucket", "a.txt", ""); err != nil {
+		t.Fatalf("DeleteObjectVersioned: %v", err)
+	}
+
+	m := NewManager(store)
+	defer m.Close()
+	job, err := m.Submit(ctx, Spec{
+		Operation: OperationRestore,
+		Manifest:  Location{Bucket: "bucket", Key: "manifest.csv"},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if job = waitForJob(t, m, job.ID); job.SucceededTasks != 1 {
+		t.Fatalf("restore job = %+v", job)
+	}
+	if got := getString(t, store, "bucket", "a.txt"); got != "alpha" {
+		t.Errorf("restored object = %q, want alpha", got)
+	}
+}
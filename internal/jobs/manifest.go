@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// maxManifestTasks bounds the number of objects in a manifest.
+const maxManifestTasks = 1_000_000
+
+// task is an object a job operates on.
+type task struct {
+	Bucket    string
+	Key       string
+	VersionID string
+}
+
+// parseManifest parses a CSV manifest. Each row holds a bucket, a URL-encoded
+// key and an optional version ID.
+func parseManifest(r io.Reader) ([]task, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var tasks []task
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("invalid manifest line %d: want bucket,key[,versionId]", line)
+		}
+
+		key, err := url.QueryUnescape(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest line %d: key is not URL-encoded: %w", line, err)
+		}
+		t := task{Bucket: record[0], Key: key}
+		if len(record) == 3 {
+			t.VersionID = record[2]
+		}
+		if t.Bucket == "" || t.Key == "" {
+			return nil, fmt.Errorf("invalid manifest line %d: bucket and key are required", line)
+		}
+
+		tasks = append(tasks, t)
+		if len(tasks) > maxManifestTasks {
+			return nil, fmt.Errorf("manifest has more than %d objects", maxManifestTasks)
+		}
+	}
+	if len(tasks) == 0 {
+		return nil, errors.New("manifest is empty")
+	}
+	return tasks, nil
+}
+
+// encodeKey URL-encodes a key for manifests and reports, keeping slashes.
+func encodeKey(key string) string {
+	return strings.ReplaceAll(url.QueryEscape(key), "%2F", "/")
+}
@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/kumasuke/jog/internal/storage"
+)
+
+// errVersionNotSupported is returned for manifest rows with a version ID when
+// the operation only applies to current versions.
+var errVersionNotSupported = errors.New("operation does not support version IDs")
+
+// errMfaDelete is returned for deletes of versions in buckets with MFA delete
+// enabled, which jobs cannot authenticate.
+var errMfaDelete = errors.New("bucket requires MFA to delete versions")
+
+// maxObjectTags is the number of tags S3 allows per object.
+const maxObjectTags = 10
+
+// execute applies the operation of a job to one object.
+func (m *Manager) execute(ctx context.Context, spec Spec, t task) error {
+	switch spec.Operation {
+	case OperationCopy:
+		return m.copyObject(ctx, spec.Copy, t)
+	case OperationTag:
+		if t.VersionID != "" {
+			return errVersionNotSupported
+		}
+		return m.tagObject(ctx, spec.Tagging, t)
+	case OperationACL:
+		if t.VersionID != "" {
+			return errVersionNotSupported
+		}
+		owner := storage.OwnerFromContext(ctx)
+		if owner == "" {
+			owner = storage.DefaultOwnerID
+		}
+		acl := storage.CannedACLToACL(storage.CannedACL(spec.ACL.CannedACL), owner, owner)
+		return m.store.PutObjectACL(ctx, t.Bucket, t.Key, acl)
+	case OperationDelete:
+		return m.deleteObject(ctx, t)
+	case OperationRestore:
+		return m.restoreObject(ctx, t)
+	default:
+		return fmt.Errorf("unknown operation %q", spec.Operation)
+	}
+}
+
+// copyObject copies an object, or a version of it, to the target bucket.
+func (m *Manager) copyObject(ctx context.Context, spec *CopySpec, t task) error {
+	dstKey := spec.TargetKeyPrefix + t.Key
+	if t.VersionID == "" {
+		_, err := m.store.CopyObject(ctx, t.Bucket, t.Key, spec.TargetBucket, dstKey, nil)
+		return err
+	}
+
+	data, err := m.store.GetObjectVersioned(ctx, t.Bucket, t.Key, t.VersionID)
+	if err != nil {
+		return err
+	}
+	defer data.Body.Close()
+	return m.put(ctx, spec.TargetBucket, dstKey, data)
+}
+
+// tagObject replaces the tag set of an object, or merges the tags into it.
+func (m *Manager) tagObject(ctx context.Context, spec *TagSpec, t task) error {
+	var tags []storage.Tag
+	if spec.Mode == TagModeMerge {
+		current, err := m.store.GetObjectTagging(ctx, t.Bucket, t.Key)
+		if err != nil && !errors.Is(err, storage.ErrNoSuchTagSet) {
+			return err
+		}
+		tags = current
+	}
+	for _, tag := range spec.Tags {
+		i := slices.IndexFunc(tags, func(current storage.Tag) bool { return current.Key == tag.Key })
+		if i >= 0 {
+			tags[i].Value = tag.Value
+			continue
+		}
+		tags = append(tags, storage.Tag{Key: tag.Key, Value: tag.Value})
+	}
+	if len(tags) > maxObjectTags {
+		return fmt.Errorf("object would have more than %d tags", maxObjectTags)
+	}
+	return m.store.PutObjectTagging(ctx, t.Bucket, t.Key, tags)
+}
+
+// deleteObject deletes an object like DeleteObject does: a version ID deletes
+// that version, and in versioned buckets a delete marker is created otherwise.
+func (m *Manager) deleteObject(ctx context.Context, t task) error {
+	status, err := m.store.GetBucketVersioning(ctx, t.Bucket)
+	if err != nil {
+		return err
+	}
+	if t.VersionID != "" {
+		mfaDelete, err := m.store.GetBucketMfaDelete(ctx, t.Bucket)
+		if err != nil {
+			return err
+		}
+		if mfaDelete == storage.MfaDeleteStatusEnabled {
+			return errMfaDelete
+		}
+	}
+	if t.VersionID != "" || status == storage.VersioningStatusEnabled {
+		_, _, err := m.store.DeleteObjectVersioned(ctx, t.Bucket, t.Key, t.VersionID)
+		return err
+	}
+	return m.store.DeleteObject(ctx, t.Bucket, t.Key)
+}
+
+// restoreObject makes a version the current version of its object by copying
+// it over the object. Without a version ID, the newest version that is not a
+// delete marker is restored, which undoes deletes in versioned buckets.
+func (m *Manager) restoreObject(ctx context.Context, t task) error {
+	versionID := t.VersionID
+	if versionID == "" {
+		var err error
+		if versionID, err = m.latestVersion(ctx, t.Bucket, t.Key); err != nil {
+			return err
+		}
+	}
+
+	data, err := m.store.GetObjectVersioned(ctx, t.Bucket, t.Key, versionID)
+	if err != nil {
+		return err
+	}
+	defer data.Body.Close()
+	return m.put(ctx, t.Bucket, t.Key, data)
+}
+
+// latestVersion returns the newest version of an object that is not a delete marker.
+func (m *Manager) latestVersion(ctx context.Context, bucket, key string) (string, error) {
+	input := &storage.ListObjectVersionsInput{Bucket: bucket, Prefix: key, MaxKeys: 1000}
+	for {
+		out, err := m.store.ListObjectVersions(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		// Versions are listed newest first
+		for _, v := range out.Versions {
+			if v.Key == key {
+				return v.VersionID, nil
+			}
+		}
+		if !out.IsTruncated {
+			return "", storage.ErrObjectNotFound
+		}
+		input.KeyMarker = out.NextKeyMarker
+		input.VersionIdMarker = out.NextVersionIdMarker
+	}
+}
+
+// put writes object data to a key, as a new version if the bucket is versioned.
+func (m *Manager) put(ctx context.Context, bucket, key string, data *storage.ObjectData) error {
+	status, err := m.store.GetBucketVersioning(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if status == storage.VersioningStatusEnabled {
+		_, _, err = m.store.PutObjectVersioned(ctx, bucket, key, data.Body, data.Size, data.ContentType, data.Metadata)
+		return err
+	}
+	_, err = m.store.PutObject(ctx, bucket, key, data.Body, data.Size, data.ContentType, data.Metadata)
+	return err
+}
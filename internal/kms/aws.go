@@ -0,0 +1,167 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/kumasuke/jog/internal/config"
+)
+
+// awsProvider wraps data keys with AWS KMS, using its JSON API. AWS KMS
+// keeps the key versions of rotated keys and binds the encryption context
+// to the ciphertext itself.
+type awsProvider struct {
+	endpoint    string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+// awsPlaintext is the request of Encrypt and the response of Decrypt.
+type awsPlaintext struct {
+	KeyID             string  `json:"KeyId"`
+	Plaintext         []byte  `json:"Plaintext,omitempty"`
+	EncryptionContext Context `json:"EncryptionContext,omitempty"`
+}
+
+// awsCiphertext is the request of Decrypt and the response of Encrypt.
+type awsCiphertext struct {
+	KeyID             string  `json:"KeyId"`
+	CiphertextBlob    []byte  `json:"CiphertextBlob,omitempty"`
+	EncryptionContext Context `json:"EncryptionContext,omitempty"`
+}
+
+// awsErrorResponse is the error response of the AWS KMS API.
+type awsErrorResponse struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func newAWSProvider(cfg config.AWSKMSConfig) (*awsProvider, error) {
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid aws kms endpoint: %q", endpoint)
+	}
+
+	var provider aws.CredentialsProvider
+	if cfg.AccessKey != "" {
+		provider = credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion(region))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load aws credentials: %w", err)
+		}
+		provider = awsCfg.Credentials
+	}
+
+	return &awsProvider{
+		endpoint:    strings.TrimSuffix(endpoint, "/") + "/",
+		region:      region,
+		credentials: aws.NewCredentialsCache(provider),
+		signer:      v4.NewSigner(),
+		client:      &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+func (p *awsProvider) Encrypt(ctx context.Context, keyID string, plaintext []byte, encCtx Context) ([]byte, error) {
+	var result awsCiphertext
+	err := p.call(ctx, "Encrypt", keyID, awsPlaintext{
+		KeyID:             keyID,
+		Plaintext:         plaintext,
+		EncryptionContext: encCtx,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.CiphertextBlob, nil
+}
+
+func (p *awsProvider) Decrypt(ctx context.Context, keyID string, ciphertext []byte, encCtx Context) ([]byte, error) {
+	var result awsPlaintext
+	err := p.call(ctx, "Decrypt", keyID, awsCiphertext{
+		KeyID:             keyID,
+		CiphertextBlob:    ciphertext,
+		EncryptionContext: encCtx,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Plaintext, nil
+}
+
+// call sends a signed request to an action of the AWS KMS API.
+func (p *awsProvider) call(ctx context.Context, action, keyID string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+
+	creds, err := p.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("aws kms: failed to retrieve credentials: %w", err)
+	}
+	bodyHash := sha256.Sum256(body)
+	if err := p.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(bodyHash[:]), "kms", p.region, time.Now()); err != nil {
+		return fmt.Errorf("aws kms: failed to sign request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("aws kms: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("aws kms: failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp awsErrorResponse
+		json.Unmarshal(respBody, &errResp)
+		// Error types may be qualified, e.g. "com.amazonaws.kms#NotFoundException"
+		errType := errResp.Type[strings.LastIndex(errResp.Type, "#")+1:]
+		switch errType {
+		case "NotFoundException":
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+		case "InvalidCiphertextException", "IncorrectKeyException":
+			return fmt.Errorf("%w: %s", ErrInvalidCiphertext, errResp.Message)
+		case "":
+			return fmt.Errorf("aws kms: unexpected status %s", resp.Status)
+		default:
+			return fmt.Errorf("aws kms: %s: %s", errType, errResp.Message)
+		}
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("aws kms: invalid %s response: %w", action, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package kms wraps the data keys of encrypted objects with master keys held
+// by a key management service.
+package kms
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kumasuke/jog/internal/config"
+)
+
+// DataKeySize is the size in bytes of the data keys objects are encrypted with.
+const DataKeySize = 32
+
+var (
+	// ErrKeyNotFound is returned for unknown master key IDs.
+	ErrKeyNotFound = errors.New("kms: key not found")
+	// ErrInvalidCiphertext is returned for wrapped keys that cannot be
+	// unwrapped with the given key ID and encryption context.
+	ErrInvalidCiphertext = errors.New("kms: invalid ciphertext")
+)
+
+// Context is an encryption context: non-secret key-value pairs bound to a
+// wrapped key, which must be presented again to unwrap it.
+type Context map[string]string
+
+// Provider wraps and unwraps data keys with master keys.
+type Provider interface {
+	// Encrypt wraps plaintext with the current version of master key keyID.
+	Encrypt(ctx context.Context, keyID string, plaintext []byte, encCtx Context) ([]byte, error)
+	// Decrypt unwraps a ciphertext returned by Encrypt for the same key ID
+	// and encryption context, whichever key version wrapped it.
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte, encCtx Context) ([]byte, error)
+}
+
+// New creates the provider selected by cfg, or returns nil if no provider is
+// configured.
+func New(cfg config.KMSConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "local":
+		return newLocalProvider(cfg.Local)
+	case "vault":
+		return newVaultProvider(cfg.Vault)
+	case "aws":
+		return newAWSProvider(cfg.AWS)
+	default:
+		return nil, fmt.Errorf("unknown kms provider: %q", cfg.Provider)
+	}
+}
+
+// GenerateDataKey returns a new random data key and the key wrapped with
+// master key keyID.
+func GenerateDataKey(ctx context.Context, p Provider, keyID string, encCtx Context) (plaintext, ciphertext []byte, err error) {
+	plaintext = make([]byte, DataKeySize)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, err
+	}
+	ciphertext, err = p.Encrypt(ctx, keyID, plaintext, encCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintext, ciphertext, nil
+}
+
+// keyName returns the name of a key given as an AWS key or alias ARN
+// ("arn:aws:kms:region:account:key/name"), so that keys of the local and
+// Vault providers can be addressed like AWS keys.
+func keyName(keyID string) string {
+	if !strings.HasPrefix(keyID, "arn:") {
+		return keyID
+	}
+	resource := keyID[strings.LastIndex(keyID, ":")+1:]
+	if _, name, ok := strings.Cut(resource, "/"); ok {
+		return name
+	}
+	return resource
+}
+
+// canonical returns the encryption context as JSON with sorted keys.
+func (c Context) canonical() []byte {
+	if len(c) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(map[string]string(c))
+	return data
+}
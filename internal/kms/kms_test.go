@@ -0,0 +1,189 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kumasuke/jog/internal/config"
+)
+
+// testKey returns a base64 encoded 32-byte key filled with b.
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestKeyName(t *testing.T) {
+	tests := map[string]string{
+		"my-key": "my-key",
+		"arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab": "1234abcd-12ab-34cd-56ef-1234567890ab",
+		"arn:aws:kms:us-east-1:111122223333:alias/my-key":                             "my-key",
+	}
+	for keyID, want := range tests {
+		if got := keyName(keyID); got != want {
+			t.Errorf("keyName(%q) = %q, want %q", keyID, got, want)
+		}
+	}
+}
+
+func TestLocalProvider(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.LocalKMSConfig{Keys: []config.LocalKMSKey{{ID: "key", Versions: []string{testKey(1)}}}}
+	p, err := newLocalProvider(cfg)
+	if err != nil {
+		t.Fatalf("newLocalProvider: %v", err)
+	}
+
+	encCtx := Context{"aws:s3:arn": "arn:aws:s3:::bucket/object"}
+	plaintext, wrapped, err := GenerateDataKey(ctx, p, "key", encCtx)
+	if err != nil {
+		t.Fatalf("GenerateDataKey: %v", err)
+	}
+	if len(plaintext) != DataKeySize {
+		t.Fatalf("data key size = %d, want %d", len(plaintext), DataKeySize)
+	}
+
+	got, err := p.Decrypt(ctx, "arn:aws:kms:us-east-1:111122223333:key/key", wrapped, encCtx)
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("Decrypt = %x, %v, want %x", got, err, plaintext)
+	}
+	if _, err := p.Decrypt(ctx, "key", wrapped, Context{"aws:s3:arn": "arn:aws:s3:::bucket/other"}); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Decrypt with another context: err = %v, want ErrInvalidCiphertext", err)
+	}
+	if _, err := p.Encrypt(ctx, "missing", plaintext, nil); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Encrypt with unknown key: err = %v, want ErrKeyNotFound", err)
+	}
+
+	// After a rotation, new keys are wrapped with the new version and old
+	// keys can still be unwrapped
+	cfg.Keys[0].Versions = append(cfg.Keys[0].Versions, testKey(2))
+	rotated, err := newLocalProvider(cfg)
+	if err != nil {
+		t.Fatalf("newLocalProvider: %v", err)
+	}
+	if got, err := rotated.Decrypt(ctx, "key", wrapped, encCtx); err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("Decrypt after rotation = %x, %v, want %x", got, err, plaintext)
+	}
+	rewrapped, err := rotated.Encrypt(ctx, "key", plaintext, encCtx)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if _, err := p.Decrypt(ctx, "key", rewrapped, encCtx); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Decrypt of a newer version: err = %v, want ErrInvalidCiphertext", err)
+	}
+}
+
+func TestNewLocalProviderInvalidKeys(t *testing.T) {
+	for name, keys := range map[string][]config.LocalKMSKey{
+		"no versions": {{ID: "key"}},
+		"short key":   {{ID: "key", Versions: []string{base64.StdEncoding.EncodeToString([]byte("short"))}}},
+		"duplicate":   {{ID: "key", Versions: []string{testKey(1)}}, {ID: "key", Versions: []string{testKey(2)}}},
+	} {
+		if _, err := newLocalProvider(config.LocalKMSConfig{Keys: keys}); err == nil {
+			t.Errorf("%s: newLocalProvider succeeded", name)
+		}
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["context"] == "" {
+			t.Errorf("%s: missing derivation context", r.URL.Path)
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/missing"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["encryption key not found"]}`))
+		case strings.Contains(r.URL.Path, "/encrypt/"):
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"ciphertext": "vault:v1:" + body["plaintext"]}})
+		case strings.Contains(r.URL.Path, "/decrypt/"):
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"plaintext": strings.TrimPrefix(body["ciphertext"], "vault:v1:")}})
+		}
+	}))
+	defer srv.Close()
+
+	p, err := newVaultProvider(config.VaultKMSConfig{Address: srv.URL, Token: "token", Derived: true})
+	if err != nil {
+		t.Fatalf("newVaultProvider: %v", err)
+	}
+	ctx := context.Background()
+	encCtx := Context{"aws:s3:arn": "arn:aws:s3:::bucket/object"}
+
+	wrapped, err := p.Encrypt(ctx, "key", []byte("data key"), encCtx)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(string(wrapped), "vault:v1:") {
+		t.Errorf("ciphertext = %q", wrapped)
+	}
+	plaintext, err := p.Decrypt(ctx, "key", wrapped, encCtx)
+	if err != nil || string(plaintext) != "data key" {
+		t.Errorf("Decrypt = %q, %v", plaintext, err)
+	}
+	if _, err := p.Encrypt(ctx, "missing", []byte("data key"), encCtx); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Encrypt with unknown key: err = %v, want ErrKeyNotFound", err)
+	}
+	if paths[0] != "/v1/transit/encrypt/key" || paths[1] != "/v1/transit/decrypt/key" {
+		t.Errorf("paths = %v", paths)
+	}
+}
+
+func TestAWSProvider(t *testing.T) {
+	var targets []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targets = append(targets, r.Header.Get("X-Amz-Target"))
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/") {
+			t.Errorf("request not signed: %q", r.Header.Get("Authorization"))
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["KeyId"] == "missing" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.kms#NotFoundException","message":"Key 'missing' does not exist"}`))
+			return
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			json.NewEncoder(w).Encode(map[string]any{"KeyId": body["KeyId"], "CiphertextBlob": body["Plaintext"]})
+		case "TrentService.Decrypt":
+			json.NewEncoder(w).Encode(map[string]any{"KeyId": body["KeyId"], "Plaintext": body["CiphertextBlob"]})
+		}
+	}))
+	defer srv.Close()
+
+	p, err := newAWSProvider(config.AWSKMSConfig{Endpoint: srv.URL, Region: "eu-west-1", AccessKey: "AKID", SecretKey: "secret"})
+	if err != nil {
+		t.Fatalf("newAWSProvider: %v", err)
+	}
+	ctx := context.Background()
+
+	wrapped, err := p.Encrypt(ctx, "key", []byte("data key"), Context{"purpose": "test"})
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	plaintext, err := p.Decrypt(ctx, "key", wrapped, Context{"purpose": "test"})
+	if err != nil || string(plaintext) != "data key" {
+		t.Errorf("Decrypt = %q, %v", plaintext, err)
+	}
+	if _, err := p.Encrypt(ctx, "missing", []byte("data key"), nil); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Encrypt with unknown key: err = %v, want ErrKeyNotFound", err)
+	}
+	if targets[0] != "TrentService.Encrypt" || targets[1] != "TrentService.Decrypt" {
+		t.Errorf("targets = %v", targets)
+	}
+}
@@ -0,0 +1,97 @@
+package kms
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/kumasuke/jog/internal/config"
+)
+
+// localProvider wraps data keys with master keys from the configuration,
+// using AES-256-GCM. Ciphertexts start with the 4-byte key version, so that
+// keys wrapped with older versions can still be unwrapped after a rotation.
+type localProvider struct {
+	// keys maps key names to their versions, oldest first.
+	keys map[string][]cipher.AEAD
+}
+
+func newLocalProvider(cfg config.LocalKMSConfig) (*localProvider, error) {
+	p := &localProvider{keys: make(map[string][]cipher.AEAD)}
+	for _, key := range cfg.Keys {
+		if key.ID == "" || len(key.Versions) == 0 {
+			return nil, fmt.Errorf("local kms key requires an id and at least one version")
+		}
+		if _, ok := p.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate local kms key %q", key.ID)
+		}
+		for i, version := range key.Versions {
+			material, err := base64.StdEncoding.DecodeString(version)
+			if err != nil || len(material) != 32 {
+				return nil, fmt.Errorf("local kms key %q version %d must be 32 bytes, base64 encoded", key.ID, i+1)
+			}
+			block, err := aes.NewCipher(material)
+			if err != nil {
+				return nil, err
+			}
+			aead, err := cipher.NewGCM(block)
+			if err != nil {
+				return nil, err
+			}
+			p.keys[key.ID] = append(p.keys[key.ID], aead)
+		}
+	}
+	return p, nil
+}
+
+func (p *localProvider) Encrypt(ctx context.Context, keyID string, plaintext []byte, encCtx Context) ([]byte, error) {
+	name := keyName(keyID)
+	versions, ok := p.keys[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	version := len(versions)
+	aead := versions[version-1]
+
+	out := make([]byte, 4+aead.NonceSize(), 4+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	binary.BigEndian.PutUint32(out, uint32(version))
+	if _, err := rand.Read(out[4:]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out[4:], plaintext, localAAD(name, encCtx)), nil
+}
+
+func (p *localProvider) Decrypt(ctx context.Context, keyID string, ciphertext []byte, encCtx Context) ([]byte, error) {
+	name := keyName(keyID)
+	versions, ok := p.keys[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	if len(ciphertext) < 4 {
+		return nil, ErrInvalidCiphertext
+	}
+	version := int(binary.BigEndian.Uint32(ciphertext))
+	if version < 1 || version > len(versions) {
+		return nil, fmt.Errorf("%w: unknown version %d of key %s", ErrInvalidCiphertext, version, keyID)
+	}
+	aead := versions[version-1]
+	if len(ciphertext) < 4+aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	nonce := ciphertext[4 : 4+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[4+aead.NonceSize():], localAAD(name, encCtx))
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+// localAAD binds a wrapped key to its key name and encryption context.
+func localAAD(name string, encCtx Context) []byte {
+	return append([]byte(name+"\x00"), encCtx.canonical()...)
+}
@@ -0,0 +1,153 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kumasuke/jog/internal/config"
+)
+
+// requestTimeout bounds a single request to a key management service.
+const requestTimeout = 10 * time.Second
+
+// vaultProvider wraps data keys with the transit secrets engine of HashiCorp
+// Vault. Vault keeps the key versions: ciphertexts name the version that
+// wrapped them ("vault:v2:..."), and rotated keys still unwrap older ones.
+type vaultProvider struct {
+	address string
+	token   string
+	mount   string
+	derived bool
+	client  *http.Client
+}
+
+// vaultResponse is the response of the transit encrypt and decrypt endpoints.
+type vaultResponse struct {
+	Data struct {
+		Ciphertext string `json:"ciphertext"`
+		Plaintext  string `json:"plaintext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func newVaultProvider(cfg config.VaultKMSConfig) (*vaultProvider, error) {
+	address := cfg.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid vault address: %q", address)
+	}
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		return nil, errors.New("vault token is required")
+	}
+	mount := strings.Trim(cfg.Mount, "/")
+	if mount == "" {
+		mount = "transit"
+	}
+
+	return &vaultProvider{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		mount:   mount,
+		derived: cfg.Derived,
+		client:  &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+func (p *vaultProvider) Encrypt(ctx context.Context, keyID string, plaintext []byte, encCtx Context) ([]byte, error) {
+	body := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	p.setContext(body, encCtx)
+
+	resp, err := p.call(ctx, "encrypt", keyID, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data.Ciphertext == "" {
+		return nil, errors.New("vault: empty ciphertext")
+	}
+	return []byte(resp.Data.Ciphertext), nil
+}
+
+func (p *vaultProvider) Decrypt(ctx context.Context, keyID string, ciphertext []byte, encCtx Context) ([]byte, error) {
+	body := map[string]string{"ciphertext": string(ciphertext)}
+	p.setContext(body, encCtx)
+
+	resp, err := p.call(ctx, "decrypt", keyID, body)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("vault: invalid plaintext: %w", err)
+	}
+	return plaintext, nil
+}
+
+// setContext passes the encryption context as the key derivation context of
+// keys created with derived=true. Other keys do not accept a context.
+func (p *vaultProvider) setContext(body map[string]string, encCtx Context) {
+	if p.derived {
+		body["context"] = base64.StdEncoding.EncodeToString(encCtx.canonical())
+	}
+}
+
+// call sends a request to a transit endpoint of a key.
+func (p *vaultProvider) call(ctx context.Context, operation, keyID string, body map[string]string) (*vaultResponse, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := p.address + "/v1/" + p.mount + "/" + operation + "/" + url.PathEscape(keyName(keyID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("vault: failed to read response: %w", err)
+	}
+
+	var result vaultResponse
+	if err := json.Unmarshal(respBody, &result); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("vault: invalid %s response: %w", operation, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := strings.Join(result.Errors, "; ")
+		if message == "" {
+			message = resp.Status
+		}
+		// Unknown keys are reported as 400 "encryption key not found"
+		if strings.Contains(message, "not found") {
+			return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+		}
+		if operation == "decrypt" && resp.StatusCode == http.StatusBadRequest {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCiphertext, message)
+		}
+		return nil, fmt.Errorf("vault: %s", message)
+	}
+	return &result, nil
+}
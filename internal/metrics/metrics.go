@@ -0,0 +1,199 @@
+// Package metrics provides process-wide counters and gauges exposed in the
+// Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricType is the Prometheus type of a metric.
+type metricType string
+
+const (
+	typeCounter metricType = "counter"
+	typeGauge   metricType = "gauge"
+	typeSummary metricType = "summary"
+)
+
+// metric is a registered counter, gauge or summary. Metrics of the same name
+// with different labels form a family.
+type metric struct {
+	name   string
+	labels string
+	help   string
+	typ    metricType
+	value  atomic.Int64
+	// sum is the total of the observations of a summary, in nanoseconds.
+	sum atomic.Int64
+}
+
+var (
+	mu       sync.Mutex
+	registry = make(map[string]*metric)
+	types    = make(map[string]metricType)
+)
+
+// register returns the metric with the given name and labels, creating it if
+// needed. labels are name and value pairs.
+func register(name, help string, typ metricType, labels []string) *metric {
+	formatted := formatLabels(labels)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if t, ok := types[name]; ok && t != typ {
+		panic(fmt.Sprintf("metrics: %s registered as %s and %s", name, t, typ))
+	}
+	if m, ok := registry[name+formatted]; ok {
+		return m
+	}
+	m := &metric{name: name, labels: formatted, help: help, typ: typ}
+	registry[name+formatted] = m
+	types[name] = typ
+	return m
+}
+
+// labelValueEscaper escapes label values as the text format requires.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats name and value pairs as {name="value",...}.
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	if len(labels)%2 != 0 {
+		panic(fmt.Sprintf("metrics: odd number of label names and values %q", labels))
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i] + `="` + labelValueEscaper.Replace(labels[i+1]) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	m *metric
+}
+
+// NewCounter registers a counter with optional label name and value pairs.
+// Registering the same name and labels twice returns the same counter.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{m: register(name, help, typeCounter, labels)}
+}
+
+// Add increases the counter by n.
+func (c *Counter) Add(n int64) {
+	c.m.value.Add(n)
+}
+
+// Inc increases the counter by one.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Value returns the current value.
+func (c *Counter) Value() int64 {
+	return c.m.value.Load()
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	m *metric
+}
+
+// NewGauge registers a gauge with optional label name and value pairs.
+// Registering the same name and labels twice returns the same gauge.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{m: register(name, help, typeGauge, labels)}
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v int64) {
+	g.m.value.Store(v)
+}
+
+// Add changes the gauge by n.
+func (g *Gauge) Add(n int64) {
+	g.m.value.Add(n)
+}
+
+// Value returns the current value.
+func (g *Gauge) Value() int64 {
+	return g.m.value.Load()
+}
+
+// Summary tracks the number and total duration of observations, exposed as
+// a Prometheus summary without quantiles (name_count and name_sum in seconds).
+type Summary struct {
+	m *metric
+}
+
+// NewSummary registers a summary with optional label name and value pairs.
+// Registering the same name and labels twice returns the same summary.
+func NewSummary(name, help string, labels ...string) *Summary {
+	return &Summary{m: register(name, help, typeSummary, labels)}
+}
+
+// Observe records a duration.
+func (s *Summary) Observe(d time.Duration) {
+	s.m.value.Add(1)
+	s.m.sum.Add(int64(d))
+}
+
+// Count returns the number of observations.
+func (s *Summary) Count() int64 {
+	return s.m.value.Load()
+}
+
+// Sum returns the total of the observations.
+func (s *Summary) Sum() time.Duration {
+	return time.Duration(s.m.sum.Load())
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format,
+// sorted by name and labels.
+func WriteText(w io.Writer) error {
+	mu.Lock()
+	metrics := make([]*metric, 0, len(registry))
+	for _, m := range registry {
+		metrics = append(metrics, m)
+	}
+	mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].name != metrics[j].name {
+			return metrics[i].name < metrics[j].name
+		}
+		return metrics[i].labels < metrics[j].labels
+	})
+	for i, m := range metrics {
+		if i == 0 || metrics[i-1].name != m.name {
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ); err != nil {
+				return err
+			}
+		}
+		var err error
+		if m.typ == typeSummary {
+			_, err = fmt.Fprintf(w, "%s_sum%s %g\n%s_count%s %d\n",
+				m.name, m.labels, time.Duration(m.sum.Load()).Seconds(), m.name, m.labels, m.value.Load())
+		} else {
+			_, err = fmt.Fprintf(w, "%s%s %d\n", m.name, m.labels, m.value.Load())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteText(t *testing.T) {
+	counter := NewCounter("jog_test_requests_total", "Test requests.")
+	counter.Inc()
+	counter.Add(2)
+	gauge := NewGauge("jog_test_in_flight", "Test requests in flight.")
+	gauge.Set(5)
+	gauge.Add(-1)
+
+	if again := NewCounter("jog_test_requests_total", "Test requests."); again.Value() != 3 {
+		t.Errorf("re-registered counter value = %d, want 3", again.Value())
+	}
+
+	var out strings.Builder
+	if err := WriteText(&out); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	text := out.String()
+
+	for _, want := range []string{
+		"# TYPE jog_test_requests_total counter\njog_test_requests_total 3\n",
+		"# HELP jog_test_in_flight Test requests in flight.\n# TYPE jog_test_in_flight gauge\njog_test_in_flight 4\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "jog_test_in_flight") > strings.Index(text, "jog_test_requests_total") {
+		t.Error("metrics are not sorted by name")
+	}
+}
+
+func TestRegisterTypeMismatch(t *testing.T) {
+	NewCounter("jog_test_mismatch", "Mismatch.")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic when registering a counter name as gauge")
+		}
+	}()
+	NewGauge("jog_test_mismatch", "Mismatch.")
+}
+
+func TestWriteTextLabelsAndSummary(t *testing.T) {
+	NewCounter("jog_test_labeled_total", "Labeled.", "op", "b").Add(2)
+	NewCounter("jog_test_labeled_total", "Labeled.", "op", `a"1`).Inc()
+	summary := NewSummary("jog_test_duration_seconds", "Durations.", "op", "get")
+	summary.Observe(250 * time.Millisecond)
+	summary.Observe(time.Second)
+
+	var out strings.Builder
+	if err := WriteText(&out); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	text := out.String()
+
+	for _, want := range []string{
+		"# TYPE jog_test_labeled_total counter\njog_test_labeled_total{op=\"a\\\"1\"} 1\njog_test_labeled_total{op=\"b\"} 2\n",
+		"# TYPE jog_test_duration_seconds summary\njog_test_duration_seconds_sum{op=\"get\"} 1.25\njog_test_duration_seconds_count{op=\"get\"} 2\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Count(text, "# HELP jog_test_labeled_total") != 1 {
+		t.Errorf("labeled family written more than once:\n%s", text)
+	}
+}
@@ -0,0 +1,984 @@
+// Package mount exposes a bucket as a FUSE filesystem backed directly by the
+// storage layer.
+//
+// Object keys map to paths, with "/" separating directories. Directories exist
+// while objects below them exist; directories created with mkdir are kept in
+// memory until an object is written below them or the filesystem is unmounted.
+// Files are written to a temporary file and stored as an object when they are
+// closed or synced. User metadata maps to "user.<name>" extended attributes and
+// the content type to "user.mime_type".
+package mount
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kumasuke/jog/internal/storage"
+)
+
+// Options configures a mounted bucket.
+type Options struct {
+	// ReadOnly rejects changes to the bucket.
+	ReadOnly bool
+	// AllowOther lets users other than the mounting user access the filesystem.
+	AllowOther bool
+	// UID and GID own all files and directories.
+	UID uint32
+	GID uint32
+}
+
+const (
+	// rootID is the node ID of the mount point.
+	rootID = 1
+
+	// modeDir and modeRegular are the file type bits of directories and files.
+	modeDir     = 0o040000
+	modeRegular = 0o100000
+
+	// mimeTypeAttr is the extended attribute holding the content type.
+	mimeTypeAttr = "user.mime_type"
+	// userAttrPrefix prefixes the extended attributes holding user metadata.
+	userAttrPrefix = "user."
+
+	// maxKeyLength is the maximum length of an object key.
+	maxKeyLength = 1024
+
+	// Linux open(2) flags passed to Open by the kernel.
+	openAccessMode = 0o3
+	openReadOnly   = 0o0
+	openTruncate   = 0o1000
+)
+
+// node is a file or directory the kernel knows about.
+type node struct {
+	id uint64
+	// key is the object key of a file, or the key prefix ending in "/" of a
+	// directory. It is "" for the root.
+	key string
+	dir bool
+	// lookups counts the references the kernel holds.
+	lookups uint64
+	// writer is the handle with unsaved changes to a file, if any.
+	writer *handle
+}
+
+// handle is an open file or directory.
+type handle struct {
+	node *node
+	// size is the size of the object when the file was opened.
+	size int64
+	// file holds the contents of a file being written, nil while it is unchanged.
+	file  *os.File
+	dirty bool
+	// entries is the listing of an open directory.
+	entries []dirEntry
+}
+
+// dirEntry is an entry of a directory listing.
+type dirEntry struct {
+	name string
+	dir  bool
+}
+
+// attr is the attributes of a node.
+type attr struct {
+	ino   uint64
+	size  int64
+	mtime time.Time
+	mode  uint32
+	nlink uint32
+}
+
+// FS is a bucket exposed as a filesystem. Its methods implement the FUSE
+// operations and return 0 or the errno to report.
+type FS struct {
+	store  storage.Storage
+	bucket string
+	opts   Options
+	// created is the creation time of the bucket, used as directory mtime.
+	created time.Time
+
+	mu         sync.Mutex
+	nodes      map[uint64]*node
+	keys       map[string]*node
+	nextID     uint64
+	handles    map[uint64]*handle
+	nextHandle uint64
+	// dirs holds directories created with mkdir that contain no objects yet.
+	dirs map[string]bool
+}
+
+// NewFS returns the filesystem of a bucket.
+func NewFS(ctx context.Context, store storage.Storage, bucket string, opts Options) (*FS, error) {
+	b, err := store.HeadBucket(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &node{id: rootID, dir: true, lookups: 1}
+	return &FS{
+		store:   store,
+		bucket:  bucket,
+		opts:    opts,
+		created: b.CreationDate,
+		nodes:   map[uint64]*node{rootID: root},
+		keys:    map[string]*node{"": root},
+		nextID:  rootID + 1,
+		handles: make(map[uint64]*handle),
+		dirs:    make(map[string]bool),
+	}, nil
+}
+
+// errno maps a storage error to the errno reported to the kernel.
+func errno(err error) syscall.Errno {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, storage.ErrObjectNotFound), errors.Is(err, storage.ErrBucketNotFound):
+		return syscall.ENOENT
+	case errors.Is(err, storage.ErrInvalidKey):
+		return syscall.EINVAL
+	default:
+		return syscall.EIO
+	}
+}
+
+// node returns the node with the given ID, or nil if the kernel forgot it.
+func (fs *FS) node(id uint64) *node {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.nodes[id]
+}
+
+// childKey returns the key of the named entry of a directory.
+func childKey(parent *node, name string, dir bool) (string, syscall.Errno) {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return "", syscall.EINVAL
+	}
+	key := parent.key + name
+	if dir {
+		key += "/"
+	}
+	if len(key) > maxKeyLength {
+		return "", syscall.ENAMETOOLONG
+	}
+	return key, 0
+}
+
+// addNode returns the node of a key, creating it if needed, and counts a
+// kernel reference to it.
+func (fs *FS) addNode(key string, dir bool) *node {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	n := fs.keys[key]
+	if n == nil {
+		n = &node{id: fs.nextID, key: key, dir: dir}
+		fs.nextID++
+		fs.nodes[n.id] = n
+		fs.keys[key] = n
+	}
+	n.lookups++
+	return n
+}
+
+// Forget drops n kernel references to a node.
+func (fs *FS) Forget(id, n uint64) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	nd := fs.nodes[id]
+	if nd == nil || id == rootID {
+		return
+	}
+	if n >= nd.lookups {
+		delete(fs.nodes, id)
+		if fs.keys[nd.key] == nd {
+			delete(fs.keys, nd.key)
+		}
+		return
+	}
+	nd.lookups -= n
+}
+
+// dirExists reports whether a directory exists: objects exist below it or it
+// was created with mkdir.
+func (fs *FS) dirExists(ctx context.Context, prefix string) (bool, error) {
+	fs.mu.Lock()
+	local := fs.dirs[prefix]
+	fs.mu.Unlock()
+	if local {
+		return true, nil
+	}
+	out, err := fs.store.ListObjectsV2(ctx, &storage.ListObjectsInput{
+		Bucket:  fs.bucket,
+		Prefix:  prefix,
+		MaxKeys: 1,
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(out.Objects) > 0, nil
+}
+
+// Lookup looks up the named entry of a directory. Files take precedence over
+// directories of the same name.
+func (fs *FS) Lookup(ctx context.Context, parentID uint64, name string) (*node, attr, syscall.Errno) {
+	parent := fs.node(parentID)
+	if parent == nil {
+		return nil, attr{}, syscall.ENOENT
+	}
+	key, e := childKey(parent, name, false)
+	if e != 0 {
+		return nil, attr{}, syscall.ENOENT
+	}
+
+	obj, err := fs.store.HeadObject(ctx, fs.bucket, key)
+	if err == nil {
+		n := fs.addNode(key, false)
+		return n, fs.fileAttr(n, obj), 0
+	}
+	if !errors.Is(err, storage.ErrObjectNotFound) && !errors.Is(err, storage.ErrInvalidKey) {
+		return nil, attr{}, errno(err)
+	}
+
+	exists, err := fs.dirExists(ctx, key+"/")
+	if err != nil {
+		return nil, attr{}, errno(err)
+	}
+	if !exists {
+		return nil, attr{}, syscall.ENOENT
+	}
+	n := fs.addNode(key+"/", true)
+	return n, fs.dirAttr(n), 0
+}
+
+// dirAttr returns the attributes of a directory.
+func (fs *FS) dirAttr(n *node) attr {
+	return attr{ino: n.id, mtime: fs.created, mode: modeDir | 0o755, nlink: 2}
+}
+
+// fileAttr returns the attributes of a file stored as obj, or of its unsaved
+// contents if it is being written.
+func (fs *FS) fileAttr(n *node, obj *storage.Object) attr {
+	a := attr{ino: n.id, size: obj.Size, mtime: obj.LastModified, mode: modeRegular | 0o644, nlink: 1}
+	fs.mu.Lock()
+	writer := n.writer
+	fs.mu.Unlock()
+	if writer != nil && writer.file != nil {
+		if info, err := writer.file.Stat(); err == nil {
+			a.size = info.Size()
+			a.mtime = info.ModTime()
+		}
+	}
+	return a
+}
+
+// GetAttr returns the attributes of a node.
+func (fs *FS) GetAttr(ctx context.Context, id uint64) (attr, syscall.Errno) {
+	n := fs.node(id)
+	if n == nil {
+		return attr{}, syscall.ENOENT
+	}
+	if n.dir {
+		return fs.dirAttr(n), 0
+	}
+	obj, err := fs.store.HeadObject(ctx, fs.bucket, n.key)
+	if err != nil {
+		return attr{}, errno(err)
+	}
+	return fs.fileAttr(n, obj), 0
+}
+
+// addHandle registers an open file or directory and returns its handle ID.
+func (fs *FS) addHandle(h *handle) uint64 {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.nextHandle++
+	fs.handles[fs.nextHandle] = h
+	return fs.nextHandle
+}
+
+// handle returns the open file or directory with the given handle ID.
+func (fs *FS) handle(fh uint64) *handle {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.handles[fh]
+}
+
+// OpenDir opens a directory and lists its entries.
+func (fs *FS) OpenDir(ctx context.Context, id uint64) (uint64, syscall.Errno) {
+	n := fs.node(id)
+	if n == nil {
+		return 0, syscall.ENOENT
+	}
+	if !n.dir {
+		return 0, syscall.ENOTDIR
+	}
+
+	seen := make(map[string]bool)
+	var entries []dirEntry
+	add := func(name string, dir bool) {
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		entries = append(entries, dirEntry{name: name, dir: dir})
+	}
+
+	input := &storage.ListObjectsInput{Bucket: fs.bucket, Prefix: n.key, Delimiter: "/", MaxKeys: 1000}
+	for {
+		out, err := fs.store.ListObjectsV2(ctx, input)
+		if err != nil {
+			return 0, errno(err)
+		}
+		for _, obj := range out.Objects {
+			add(strings.TrimPrefix(obj.Key, n.key), false)
+		}
+		for _, prefix := range out.CommonPrefixes {
+			add(strings.TrimSuffix(strings.TrimPrefix(prefix, n.key), "/"), true)
+		}
+		if !out.IsTruncated {
+			break
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+
+	fs.mu.Lock()
+	for dir := range fs.dirs {
+		if rest, ok := strings.CutPrefix(dir, n.key); ok && strings.Count(rest, "/") == 1 {
+			add(strings.TrimSuffix(rest, "/"), true)
+		}
+	}
+	fs.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	entries = append([]dirEntry{{name: ".", dir: true}, {name: "..", dir: true}}, entries...)
+	return fs.addHandle(&handle{node: n, entries: entries}), 0
+}
+
+// ReadDir returns the entries of an open directory from the given index.
+func (fs *FS) ReadDir(fh uint64, offset uint64) ([]dirEntry, syscall.Errno) {
+	h := fs.handle(fh)
+	if h == nil {
+		return nil, syscall.EBADF
+	}
+	if offset >= uint64(len(h.entries)) {
+		return nil, 0
+	}
+	return h.entries[offset:], 0
+}
+
+// Open opens a file. flags are the open(2) flags.
+func (fs *FS) Open(ctx context.Context, id uint64, flags uint32) (uint64, syscall.Errno) {
+	n := fs.node(id)
+	if n == nil {
+		return 0, syscall.ENOENT
+	}
+	if n.dir {
+		return 0, syscall.EISDIR
+	}
+	write := flags&openAccessMode != openReadOnly
+	if write && fs.opts.ReadOnly {
+		return 0, syscall.EROFS
+	}
+
+	obj, err := fs.store.HeadObject(ctx, fs.bucket, n.key)
+	if err != nil {
+		return 0, errno(err)
+	}
+	h := &handle{node: n, size: obj.Size}
+	if write && flags&openTruncate != 0 {
+		if e := fs.startWrite(ctx, h, true); e != 0 {
+			return 0, e
+		}
+		h.dirty = true
+	}
+	return fs.addHandle(h), 0
+}
+
+// Create creates and opens a file. The empty object is stored right away so
+// that the file is visible before it is closed.
+func (fs *FS) Create(ctx context.Context, parentID uint64, name string) (*node, attr, uint64, syscall.Errno) {
+	if fs.opts.ReadOnly {
+		return nil, attr{}, 0, syscall.EROFS
+	}
+	parent := fs.node(parentID)
+	if parent == nil {
+		return nil, attr{}, 0, syscall.ENOENT
+	}
+	key, e := childKey(parent, name, false)
+	if e != 0 {
+		return nil, attr{}, 0, e
+	}
+
+	obj, err := fs.store.PutObject(ctx, fs.bucket, key, strings.NewReader(""), 0, contentType(key), nil)
+	if err != nil {
+		return nil, attr{}, 0, errno(err)
+	}
+	fs.dirCreated(key)
+
+	n := fs.addNode(key, false)
+	h := &handle{node: n}
+	if e := fs.startWrite(ctx, h, true); e != 0 {
+		return nil, attr{}, 0, e
+	}
+	return n, fs.fileAttr(n, obj), fs.addHandle(h), 0
+}
+
+// contentType returns the content type of new files, guessed from the extension.
+func contentType(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// dirCreated forgets the mkdir directories containing key, which now exist
+// through the object.
+func (fs *FS) dirCreated(key string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for dir := range fs.dirs {
+		if strings.HasPrefix(key, dir) {
+			delete(fs.dirs, dir)
+		}
+	}
+}
+
+// startWrite prepares a handle for changes by copying the file contents to a
+// temporary file, or starting with an empty one if truncate is set.
+func (fs *FS) startWrite(ctx context.Context, h *handle, truncate bool) syscall.Errno {
+	if h.file != nil {
+		return 0
+	}
+	file, err := os.CreateTemp("", "jog-mount-*")
+	if err != nil {
+		return syscall.EIO
+	}
+	os.Remove(file.Name())
+
+	if !truncate {
+		obj, err := fs.store.GetObject(ctx, fs.bucket, h.node.key)
+		if err != nil {
+			file.Close()
+			return errno(err)
+		}
+		_, err = io.Copy(file, obj.Body)
+		obj.Body.Close()
+		if err != nil {
+			file.Close()
+			return syscall.EIO
+		}
+	}
+
+	h.file = file
+	fs.mu.Lock()
+	h.node.writer = h
+	fs.mu.Unlock()
+	return 0
+}
+
+// Read reads up to size bytes of an open file at offset.
+func (fs *FS) Read(ctx context.Context, fh uint64, offset int64, size int) ([]byte, syscall.Errno) {
+	h := fs.handle(fh)
+	if h == nil {
+		return nil, syscall.EBADF
+	}
+
+	if h.file != nil {
+		buf := make([]byte, size)
+		n, err := h.file.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, syscall.EIO
+		}
+		return buf[:n], 0
+	}
+
+	if offset >= h.size || size == 0 {
+		return nil, 0
+	}
+	end := min(offset+int64(size), h.size) - 1
+	obj, err := fs.store.GetObjectRange(ctx, fs.bucket, h.node.key, offset, end)
+	if err != nil {
+		return nil, errno(err)
+	}
+	defer obj.Body.Close()
+	buf := make([]byte, end-offset+1)
+	n, err := io.ReadFull(obj.Body, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, syscall.EIO
+	}
+	return buf[:n], 0
+}
+
+// Write writes data to an open file at offset.
+func (fs *FS) Write(ctx context.Context, fh uint64, offset int64, data []byte) (int, syscall.Errno) {
+	h := fs.handle(fh)
+	if h == nil {
+		return 0, syscall.EBADF
+	}
+	if e := fs.startWrite(ctx, h, false); e != 0 {
+		return 0, e
+	}
+	n, err := h.file.WriteAt(data, offset)
+	if err != nil {
+		return n, syscall.EIO
+	}
+	h.dirty = true
+	return n, 0
+}
+
+// Flush stores the changes of an open file as the object. The content type and
+// user metadata of the object are kept.
+func (fs *FS) Flush(ctx context.Context, fh uint64) syscall.Errno {
+	h := fs.handle(fh)
+	if h == nil {
+		return syscall.EBADF
+	}
+	return fs.flush(ctx, h)
+}
+
+func (fs *FS) flush(ctx context.Context, h *handle) syscall.Errno {
+	if !h.dirty {
+		return 0
+	}
+
+	ct := contentType(h.node.key)
+	var metadata map[string]string
+	if obj, err := fs.store.HeadObject(ctx, fs.bucket, h.node.key); err == nil {
+		ct = obj.ContentType
+		metadata = obj.Metadata
+	} else if !errors.Is(err, storage.ErrObjectNotFound) {
+		return errno(err)
+	}
+
+	info, err := h.file.Stat()
+	if err != nil {
+		return syscall.EIO
+	}
+	body := io.NewSectionReader(h.file, 0, info.Size())
+	obj, err := fs.store.PutObject(ctx, fs.bucket, h.node.key, body, info.Size(), ct, metadata)
+	if err != nil {
+		return errno(err)
+	}
+	h.size = obj.Size
+	h.dirty = false
+	fs.dirCreated(h.node.key)
+	return 0
+}
+
+// Release closes an open file or directory, storing unsaved changes.
+func (fs *FS) Release(ctx context.Context, fh uint64) syscall.Errno {
+	h := fs.handle(fh)
+	if h == nil {
+		return syscall.EBADF
+	}
+	e := fs.flush(ctx, h)
+
+	fs.mu.Lock()
+	delete(fs.handles, fh)
+	if h.node.writer == h {
+		h.node.writer = nil
+	}
+	fs.mu.Unlock()
+	if h.file != nil {
+		h.file.Close()
+	}
+	return e
+}
+
+// Truncate changes the size of a file, through an open handle if fh is not 0.
+func (fs *FS) Truncate(ctx context.Context, id, fh uint64, size int64) syscall.Errno {
+	if fs.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	n := fs.node(id)
+	if n == nil {
+		return syscall.ENOENT
+	}
+	if n.dir {
+		return syscall.EISDIR
+	}
+
+	h := fs.handle(fh)
+	if h == nil {
+		// truncate(2) on a path: change the stored object right away
+		h = &handle{node: n}
+		defer func() {
+			if h.file != nil {
+				h.file.Close()
+			}
+			fs.mu.Lock()
+			if n.writer == h {
+				n.writer = nil
+			}
+			fs.mu.Unlock()
+		}()
+		if e := fs.startWrite(ctx, h, size == 0); e != 0 {
+			return e
+		}
+		if err := h.file.Truncate(size); err != nil {
+			return syscall.EIO
+		}
+		h.dirty = true
+		return fs.flush(ctx, h)
+	}
+
+	if e := fs.startWrite(ctx, h, size == 0); e != 0 {
+		return e
+	}
+	if err := h.file.Truncate(size); err != nil {
+		return syscall.EIO
+	}
+	h.dirty = true
+	return 0
+}
+
+// Mkdir creates a directory. It is kept in memory until an object is written
+// below it.
+func (fs *FS) Mkdir(ctx context.Context, parentID uint64, name string) (*node, attr, syscall.Errno) {
+	if fs.opts.ReadOnly {
+		return nil, attr{}, syscall.EROFS
+	}
+	parent := fs.node(parentID)
+	if parent == nil {
+		return nil, attr{}, syscall.ENOENT
+	}
+	key, e := childKey(parent, name, false)
+	if e != 0 {
+		return nil, attr{}, e
+	}
+
+	if _, err := fs.store.HeadObject(ctx, fs.bucket, key); err == nil {
+		return nil, attr{}, syscall.EEXIST
+	} else if !errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, attr{}, errno(err)
+	}
+	exists, err := fs.dirExists(ctx, key+"/")
+	if err != nil {
+		return nil, attr{}, errno(err)
+	}
+	if exists {
+		return nil, attr{}, syscall.EEXIST
+	}
+
+	fs.mu.Lock()
+	fs.dirs[key+"/"] = true
+	fs.mu.Unlock()
+	n := fs.addNode(key+"/", true)
+	return n, fs.dirAttr(n), 0
+}
+
+// Unlink deletes a file.
+func (fs *FS) Unlink(ctx context.Context, parentID uint64, name string) syscall.Errno {
+	if fs.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	parent := fs.node(parentID)
+	if parent == nil {
+		return syscall.ENOENT
+	}
+	key, e := childKey(parent, name, false)
+	if e != 0 {
+		return e
+	}
+
+	if _, err := fs.store.HeadObject(ctx, fs.bucket, key); err != nil {
+		return errno(err)
+	}
+	if err := fs.store.DeleteObject(ctx, fs.bucket, key); err != nil {
+		return errno(err)
+	}
+	fs.unlinkKey(key)
+	return 0
+}
+
+// unlinkKey detaches the node of a removed key, so that a new file or
+// directory of the same name gets a new node.
+func (fs *FS) unlinkKey(key string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	delete(fs.keys, key)
+}
+
+// Rmdir deletes an empty directory.
+func (fs *FS) Rmdir(ctx context.Context, parentID uint64, name string) syscall.Errno {
+	if fs.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	parent := fs.node(parentID)
+	if parent == nil {
+		return syscall.ENOENT
+	}
+	key, e := childKey(parent, name, true)
+	if e != 0 {
+		return e
+	}
+
+	out, err := fs.store.ListObjectsV2(ctx, &storage.ListObjectsInput{Bucket: fs.bucket, Prefix: key, MaxKeys: 1})
+	if err != nil {
+		return errno(err)
+	}
+	if len(out.Objects) > 0 {
+		return syscall.ENOTEMPTY
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if !fs.dirs[key] {
+		return syscall.ENOENT
+	}
+	for dir := range fs.dirs {
+		if dir != key && strings.HasPrefix(dir, key) {
+			return syscall.ENOTEMPTY
+		}
+	}
+	delete(fs.dirs, key)
+	delete(fs.keys, key)
+	return 0
+}
+
+// Rename moves a file, or a directory with all objects below it. Objects are
+// copied to their new keys and then deleted.
+func (fs *FS) Rename(ctx context.Context, oldParentID uint64, oldName string, newParentID uint64, newName string) syscall.Errno {
+	if fs.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	oldParent, newParent := fs.node(oldParentID), fs.node(newParentID)
+	if oldParent == nil || newParent == nil {
+		return syscall.ENOENT
+	}
+	oldKey, e := childKey(oldParent, oldName, false)
+	if e != 0 {
+		return e
+	}
+	newKey, e := childKey(newParent, newName, false)
+	if e != 0 {
+		return e
+	}
+	if oldKey == newKey {
+		return 0
+	}
+
+	if _, err := fs.store.HeadObject(ctx, fs.bucket, oldKey); err == nil {
+		if exists, err := fs.dirExists(ctx, newKey+"/"); err != nil {
+			return errno(err)
+		} else if exists {
+			return syscall.EISDIR
+		}
+		if _, err := fs.store.CopyObject(ctx, fs.bucket, oldKey, fs.bucket, newKey, nil); err != nil {
+			return errno(err)
+		}
+		if err := fs.store.DeleteObject(ctx, fs.bucket, oldKey); err != nil {
+			return errno(err)
+		}
+		fs.renameKey(oldKey, newKey)
+		fs.dirCreated(newKey)
+		return 0
+	} else if !errors.Is(err, storage.ErrObjectNotFound) {
+		return errno(err)
+	}
+
+	oldPrefix, newPrefix := oldKey+"/", newKey+"/"
+	if strings.HasPrefix(newPrefix, oldPrefix) {
+		return syscall.EINVAL
+	}
+	exists, err := fs.dirExists(ctx, oldPrefix)
+	if err != nil {
+		return errno(err)
+	}
+	if !exists {
+		return syscall.ENOENT
+	}
+	if _, err := fs.store.HeadObject(ctx, fs.bucket, newKey); err == nil {
+		return syscall.ENOTDIR
+	}
+	if exists, err := fs.dirExists(ctx, newPrefix); err != nil {
+		return errno(err)
+	} else if exists {
+		return syscall.ENOTEMPTY
+	}
+
+	input := &storage.ListObjectsInput{Bucket: fs.bucket, Prefix: oldPrefix, MaxKeys: 1000}
+	for {
+		out, err := fs.store.ListObjectsV2(ctx, input)
+		if err != nil {
+			return errno(err)
+		}
+		for _, obj := range out.Objects {
+			dst := newPrefix + strings.TrimPrefix(obj.Key, oldPrefix)
+			if _, err := fs.store.CopyObject(ctx, fs.bucket, obj.Key, fs.bucket, dst, nil); err != nil {
+				return errno(err)
+			}
+			if err := fs.store.DeleteObject(ctx, fs.bucket, obj.Key); err != nil {
+				return errno(err)
+			}
+		}
+		if !out.IsTruncated {
+			break
+		}
+		// Listed objects were deleted, so the next page starts at the beginning
+		input.ContinuationToken = ""
+	}
+
+	fs.mu.Lock()
+	for dir := range fs.dirs {
+		if rest, ok := strings.CutPrefix(dir, oldPrefix); ok {
+			delete(fs.dirs, dir)
+			fs.dirs[newPrefix+rest] = true
+		}
+	}
+	fs.mu.Unlock()
+	fs.renameKey(oldPrefix, newPrefix)
+	fs.dirCreated(newPrefix)
+	return 0
+}
+
+// renameKey moves the nodes of a key, or of a key prefix and all keys below it.
+func (fs *FS) renameKey(oldKey, newKey string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	delete(fs.keys, newKey)
+	for key, n := range fs.keys {
+		if key == oldKey || (strings.HasSuffix(oldKey, "/") && strings.HasPrefix(key, oldKey)) {
+			delete(fs.keys, key)
+			n.key = newKey + strings.TrimPrefix(key, oldKey)
+			fs.keys[n.key] = n
+		}
+	}
+}
+
+// GetXattr returns the value of an extended attribute of a file.
+func (fs *FS) GetXattr(ctx context.Context, id uint64, name string) ([]byte, syscall.Errno) {
+	obj, e := fs.xattrObject(ctx, id)
+	if e != 0 {
+		return nil, e
+	}
+	if name == mimeTypeAttr {
+		return []byte(obj.ContentType), 0
+	}
+	if key, ok := strings.CutPrefix(name, userAttrPrefix); ok {
+		if value, ok := obj.Metadata[strings.ToLower(key)]; ok {
+			return []byte(value), 0
+		}
+	}
+	return nil, syscall.ENODATA
+}
+
+// ListXattr returns the names of the extended attributes of a file.
+func (fs *FS) ListXattr(ctx context.Context, id uint64) ([]string, syscall.Errno) {
+	n := fs.node(id)
+	if n == nil {
+		return nil, syscall.ENOENT
+	}
+	if n.dir {
+		return nil, 0
+	}
+	obj, e := fs.xattrObject(ctx, id)
+	if e != 0 {
+		return nil, e
+	}
+	names := []string{mimeTypeAttr}
+	for key := range obj.Metadata {
+		names = append(names, userAttrPrefix+key)
+	}
+	sort.Strings(names[1:])
+	return names, 0
+}
+
+// xattrObject returns the object of a file for extended attribute operations.
+// Directories have no extended attributes.
+func (fs *FS) xattrObject(ctx context.Context, id uint64) (*storage.Object, syscall.Errno) {
+	n := fs.node(id)
+	if n == nil {
+		return nil, syscall.ENOENT
+	}
+	if n.dir {
+		return nil, syscall.ENODATA
+	}
+	obj, err := fs.store.HeadObject(ctx, fs.bucket, n.key)
+	if err != nil {
+		return nil, errno(err)
+	}
+	return obj, 0
+}
+
+// SetXattr sets an extended attribute of a file. Setting the content type
+// rewrites the object; user metadata is replaced in place.
+func (fs *FS) SetXattr(ctx context.Context, id uint64, name string, value []byte) syscall.Errno {
+	if fs.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	obj, e := fs.xattrObject(ctx, id)
+	if e != 0 {
+		if e == syscall.ENODATA {
+			return syscall.EOPNOTSUPP
+		}
+		return e
+	}
+
+	if name == mimeTypeAttr {
+		return fs.setContentType(ctx, obj, string(value))
+	}
+	key, ok := strings.CutPrefix(name, userAttrPrefix)
+	if !ok || key == "" {
+		return syscall.EOPNOTSUPP
+	}
+	metadata := make(map[string]string, len(obj.Metadata)+1)
+	for k, v := range obj.Metadata {
+		metadata[k] = v
+	}
+	metadata[strings.ToLower(key)] = string(value)
+	return fs.replaceMetadata(ctx, obj, metadata)
+}
+
+// RemoveXattr removes an extended attribute of a file.
+func (fs *FS) RemoveXattr(ctx context.Context, id uint64, name string) syscall.Errno {
+	if fs.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	obj, e := fs.xattrObject(ctx, id)
+	if e != 0 {
+		return e
+	}
+	key, ok := strings.CutPrefix(name, userAttrPrefix)
+	if !ok || name == mimeTypeAttr {
+		return syscall.EOPNOTSUPP
+	}
+	key = strings.ToLower(key)
+	if _, ok := obj.Metadata[key]; !ok {
+		return syscall.ENODATA
+	}
+	metadata := make(map[string]string, len(obj.Metadata))
+	for k, v := range obj.Metadata {
+		if k != key {
+			metadata[k] = v
+		}
+	}
+	return fs.replaceMetadata(ctx, obj, metadata)
+}
+
+// replaceMetadata replaces the user metadata of an object.
+func (fs *FS) replaceMetadata(ctx context.Context, obj *storage.Object, metadata map[string]string) syscall.Errno {
+	_, err := fs.store.CopyObject(ctx, fs.bucket, obj.Key, fs.bucket, obj.Key, metadata)
+	return errno(err)
+}
+
+// setContentType stores an object again with a new content type.
+func (fs *FS) setContentType(ctx context.Context, obj *storage.Object, ct string) syscall.Errno {
+	data, err := fs.store.GetObject(ctx, fs.bucket, obj.Key)
+	if err != nil {
+		return errno(err)
+	}
+	defer data.Body.Close()
+	_, err = fs.store.PutObject(ctx, fs.bucket, obj.Key, data.Body, data.Size, ct, data.Metadata)
+	return errno(err)
+}
@@ -0,0 +1,320 @@
+package mount
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/kumasuke/jog/internal/storage"
+)
+
+// newTestFS returns the filesystem of a new bucket holding the given objects.
+func newTestFS(t *testing.T, opts Options, objects map[string]string) (*FS, storage.Storage) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.NewFileSystem(dir, filepath.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	ctx := context.Background()
+	if err := store.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	for key, body := range objects {
+		if _, err := store.PutObject(ctx, "bucket", key, strings.NewReader(body), int64(len(body)), "text/plain", map[string]string{"origin": "test"}); err != nil {
+			t.Fatalf("PutObject(%s): %v", key, err)
+		}
+	}
+
+	fs, err := NewFS(ctx, store, "bucket", opts)
+	if err != nil {
+		t.Fatalf("NewFS: %v", err)
+	}
+	return fs, store
+}
+
+// lookupPath looks up a slash-separated path from the root.
+func lookupPath(t *testing.T, fs *FS, p string) (*node, syscall.Errno) {
+	t.Helper()
+	n := fs.node(rootID)
+	for _, name := range strings.Split(p, "/") {
+		var e syscall.Errno
+		if n, _, e = fs.Lookup(context.Background(), n.id, name); e != 0 {
+			return nil, e
+		}
+	}
+	return n, 0
+}
+
+func readObject(t *testing.T, store storage.Storage, key string) (string, *storage.Object) {
+	t.Helper()
+	obj, err := store.GetObject(context.Background(), "bucket", key)
+	if err != nil {
+		t.Fatalf("GetObject(%s): %v", key, err)
+	}
+	defer obj.Body.Close()
+	data, _ := io.ReadAll(obj.Body)
+	return string(data), &obj.Object
+}
+
+func TestFSLookupAndReadDir(t *testing.T) {
+	fs, _ := newTestFS(t, Options{}, map[string]string{
+		"readme.txt":      "hello",
+		"logs/2024/a.log": "a",
+		"logs/2024/b.log": "b",
+		"logs/top.log":    "top",
+	})
+	ctx := context.Background()
+
+	file, e := lookupPath(t, fs, "logs/2024/a.log")
+	if e != 0 || file.dir {
+		t.Fatalf("lookup file = %v, %v", file, e)
+	}
+	dir, e := lookupPath(t, fs, "logs/2024")
+	if e != 0 || !dir.dir {
+		t.Fatalf("lookup dir = %v, %v", dir, e)
+	}
+	if _, e := lookupPath(t, fs, "missing"); e != syscall.ENOENT {
+		t.Errorf("lookup missing = %v, want ENOENT", e)
+	}
+
+	logs, _ := lookupPath(t, fs, "logs")
+	fh, e := fs.OpenDir(ctx, logs.id)
+	if e != 0 {
+		t.Fatalf("OpenDir: %v", e)
+	}
+	entries, _ := fs.ReadDir(fh, 0)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.name)
+	}
+	if got := strings.Join(names, ","); got != ".,..,2024,top.log" {
+		t.Errorf("entries = %s", got)
+	}
+	if rest, _ := fs.ReadDir(fh, 3); len(rest) != 1 || rest[0].name != "top.log" {
+		t.Errorf("entries from offset 3 = %v", rest)
+	}
+	fs.Release(ctx, fh)
+}
+
+func TestFSReadWrite(t *testing.T) {
+	fs, store := newTestFS(t, Options{}, map[string]string{"notes.txt": "hello world"})
+	ctx := context.Background()
+
+	n, _ := lookupPath(t, fs, "notes.txt")
+	fh, e := fs.Open(ctx, n.id, syscall.O_RDWR)
+	if e != 0 {
+		t.Fatalf("Open: %v", e)
+	}
+	data, e := fs.Read(ctx, fh, 6, 100)
+	if e != 0 || string(data) != "world" {
+		t.Fatalf("Read = %q, %v", data, e)
+	}
+
+	if _, e := fs.Write(ctx, fh, 6, []byte("there!")); e != 0 {
+		t.Fatalf("Write: %v", e)
+	}
+	// Unsaved changes are visible through the handle and the attributes
+	if data, _ := fs.Read(ctx, fh, 0, 100); string(data) != "hello there!" {
+		t.Errorf("Read after write = %q", data)
+	}
+	if a, _ := fs.GetAttr(ctx, n.id); a.size != 12 {
+		t.Errorf("size = %d, want 12", a.size)
+	}
+	if body, _ := readObject(t, store, "notes.txt"); body != "hello world" {
+		t.Errorf("object changed before flush: %q", body)
+	}
+
+	if e := fs.Release(ctx, fh); e != 0 {
+		t.Fatalf("Release: %v", e)
+	}
+	body, obj := readObject(t, store, "notes.txt")
+	if body != "hello there!" {
+		t.Errorf("object = %q", body)
+	}
+	// The content type and user metadata are kept
+	if obj.ContentType != "text/plain" || obj.Metadata["origin"] != "test" {
+		t.Errorf("content type %q, metadata %v", obj.ContentType, obj.Metadata)
+	}
+}
+
+func TestFSCreateTruncateUnlink(t *testing.T) {
+	fs, store := newTestFS(t, Options{}, nil)
+	ctx := context.Background()
+
+	n, _, fh, e := fs.Create(ctx, rootID, "data.json")
+	if e != 0 {
+		t.Fatalf("Create: %v", e)
+	}
+	// The file exists before it is closed
+	if _, err := store.HeadObject(ctx, "bucket", "data.json"); err != nil {
+		t.Fatalf("HeadObject after create: %v", err)
+	}
+	fs.Write(ctx, fh, 0, []byte(`{"a":1}`))
+	fs.Release(ctx, fh)
+	if body, obj := readObject(t, store, "data.json"); body != `{"a":1}` || obj.ContentType != "application/json" {
+		t.Errorf("object = %q (%s)", body, obj.ContentType)
+	}
+
+	if e := fs.Truncate(ctx, n.id, 0, 4); e != 0 {
+		t.Fatalf("Truncate: %v", e)
+	}
+	if body, _ := readObject(t, store, "data.json"); body != `{"a"` {
+		t.Errorf("object after truncate = %q", body)
+	}
+
+	if e := fs.Unlink(ctx, rootID, "data.json"); e != 0 {
+		t.Fatalf("Unlink: %v", e)
+	}
+	if _, err := store.HeadObject(ctx, "bucket", "data.json"); err == nil {
+		t.Error("object exists after unlink")
+	}
+	if e := fs.Unlink(ctx, rootID, "data.json"); e != syscall.ENOENT {
+		t.Errorf("second Unlink = %v, want ENOENT", e)
+	}
+}
+
+func TestFSMkdirRmdir(t *testing.T) {
+	fs, store := newTestFS(t, Options{}, map[string]string{"full/file": "x"})
+	ctx := context.Background()
+
+	dir, _, e := fs.Mkdir(ctx, rootID, "empty")
+	if e != 0 {
+		t.Fatalf("Mkdir: %v", e)
+	}
+	if _, _, e := fs.Mkdir(ctx, rootID, "empty"); e != syscall.EEXIST {
+		t.Errorf("second Mkdir = %v, want EEXIST", e)
+	}
+	if _, e := lookupPath(t, fs, "empty"); e != 0 {
+		t.Errorf("lookup of created directory: %v", e)
+	}
+
+	if e := fs.Rmdir(ctx, rootID, "full"); e != syscall.ENOTEMPTY {
+		t.Errorf("Rmdir of non-empty directory = %v, want ENOTEMPTY", e)
+	}
+	if e := fs.Rmdir(ctx, rootID, "empty"); e != 0 {
+		t.Fatalf("Rmdir: %v", e)
+	}
+	if _, e := lookupPath(t, fs, "empty"); e != syscall.ENOENT {
+		t.Errorf("lookup after rmdir = %v, want ENOENT", e)
+	}
+
+	// A directory with a file in it exists through the object
+	dir, _, _ = fs.Mkdir(ctx, rootID, "new")
+	_, _, fh, _ := fs.Create(ctx, dir.id, "file")
+	fs.Release(ctx, fh)
+	if _, err := store.HeadObject(ctx, "bucket", "new/file"); err != nil {
+		t.Errorf("HeadObject(new/file): %v", err)
+	}
+	if len(fs.dirs) != 0 {
+		t.Errorf("directories kept in memory: %v", fs.dirs)
+	}
+}
+
+func TestFSRename(t *testing.T) {
+	fs, store := newTestFS(t, Options{}, map[string]string{
+		"a.txt":       "a",
+		"dir/one":     "1",
+		"dir/sub/two": "2",
+	})
+	ctx := context.Background()
+
+	file, _ := lookupPath(t, fs, "a.txt")
+	if e := fs.Rename(ctx, rootID, "a.txt", rootID, "b.txt"); e != 0 {
+		t.Fatalf("Rename file: %v", e)
+	}
+	if body, obj := readObject(t, store, "b.txt"); body != "a" || obj.Metadata["origin"] != "test" {
+		t.Errorf("renamed object = %q, %v", body, obj.Metadata)
+	}
+	if _, err := store.HeadObject(ctx, "bucket", "a.txt"); err == nil {
+		t.Error("source object exists after rename")
+	}
+	if file.key != "b.txt" {
+		t.Errorf("node key = %q, want b.txt", file.key)
+	}
+
+	sub, _ := lookupPath(t, fs, "dir/sub")
+	if e := fs.Rename(ctx, rootID, "dir", rootID, "moved"); e != 0 {
+		t.Fatalf("Rename dir: %v", e)
+	}
+	for key, want := range map[string]string{"moved/one": "1", "moved/sub/two": "2"} {
+		if body, _ := readObject(t, store, key); body != want {
+			t.Errorf("%s = %q, want %q", key, body, want)
+		}
+	}
+	if sub.key != "moved/sub/" {
+		t.Errorf("node key = %q, want moved/sub/", sub.key)
+	}
+	if e := fs.Rename(ctx, rootID, "moved", sub.id, "inside"); e != syscall.EINVAL {
+		t.Errorf("Rename into itself = %v, want EINVAL", e)
+	}
+}
+
+func TestFSXattr(t *testing.T) {
+	fs, store := newTestFS(t, Options{}, map[string]string{"doc": "content"})
+	ctx := context.Background()
+	n, _ := lookupPath(t, fs, "doc")
+
+	if value, e := fs.GetXattr(ctx, n.id, "user.origin"); e != 0 || string(value) != "test" {
+		t.Errorf("GetXattr(user.origin) = %q, %v", value, e)
+	}
+	if value, e := fs.GetXattr(ctx, n.id, mimeTypeAttr); e != 0 || string(value) != "text/plain" {
+		t.Errorf("GetXattr(mime_type) = %q, %v", value, e)
+	}
+	if _, e := fs.GetXattr(ctx, n.id, "user.missing"); e != syscall.ENODATA {
+		t.Errorf("GetXattr(missing) = %v, want ENODATA", e)
+	}
+
+	if e := fs.SetXattr(ctx, n.id, "user.Owner", []byte("alice")); e != 0 {
+		t.Fatalf("SetXattr: %v", e)
+	}
+	if e := fs.SetXattr(ctx, n.id, mimeTypeAttr, []byte("text/markdown")); e != 0 {
+		t.Fatalf("SetXattr(mime_type): %v", e)
+	}
+	if e := fs.RemoveXattr(ctx, n.id, "user.origin"); e != 0 {
+		t.Fatalf("RemoveXattr: %v", e)
+	}
+	if e := fs.SetXattr(ctx, n.id, "trusted.x", []byte("y")); e != syscall.EOPNOTSUPP {
+		t.Errorf("SetXattr(trusted.x) = %v, want EOPNOTSUPP", e)
+	}
+
+	body, obj := readObject(t, store, "doc")
+	if body != "content" || obj.ContentType != "text/markdown" {
+		t.Errorf("object = %q (%s)", body, obj.ContentType)
+	}
+	if len(obj.Metadata) != 1 || obj.Metadata["owner"] != "alice" {
+		t.Errorf("metadata = %v", obj.Metadata)
+	}
+	names, _ := fs.ListXattr(ctx, n.id)
+	if got := strings.Join(names, ","); got != "user.mime_type,user.owner" {
+		t.Errorf("ListXattr = %s", got)
+	}
+}
+
+func TestFSReadOnly(t *testing.T) {
+	fs, _ := newTestFS(t, Options{ReadOnly: true}, map[string]string{"file": "x"})
+	ctx := context.Background()
+	n, _ := lookupPath(t, fs, "file")
+
+	if _, e := fs.Open(ctx, n.id, syscall.O_WRONLY); e != syscall.EROFS {
+		t.Errorf("Open for writing = %v, want EROFS", e)
+	}
+	if _, _, _, e := fs.Create(ctx, rootID, "new"); e != syscall.EROFS {
+		t.Errorf("Create = %v, want EROFS", e)
+	}
+	if e := fs.Unlink(ctx, rootID, "file"); e != syscall.EROFS {
+		t.Errorf("Unlink = %v, want EROFS", e)
+	}
+	fh, e := fs.Open(ctx, n.id, syscall.O_RDONLY)
+	if e != 0 {
+		t.Fatalf("Open for reading: %v", e)
+	}
+	if data, _ := fs.Read(ctx, fh, 0, 10); string(data) != "x" {
+		t.Errorf("Read = %q", data)
+	}
+}
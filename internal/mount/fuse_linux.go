@@ -0,0 +1,758 @@
+package mount
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// FUSE kernel protocol version implemented by the server.
+const (
+	protocolMajor = 7
+	protocolMinor = 31
+)
+
+// FUSE opcodes, see include/uapi/linux/fuse.h.
+const (
+	opLookup      = 1
+	opForget      = 2
+	opGetattr     = 3
+	opSetattr     = 4
+	opMkdir       = 9
+	opUnlink      = 10
+	opRmdir       = 11
+	opRename      = 12
+	opOpen        = 14
+	opRead        = 15
+	opWrite       = 16
+	opStatfs      = 17
+	opRelease     = 18
+	opFsync       = 20
+	opSetxattr    = 21
+	opGetxattr    = 22
+	opListxattr   = 23
+	opRemovexattr = 24
+	opFlush       = 25
+	opInit        = 26
+	opOpendir     = 27
+	opReaddir     = 28
+	opReleasedir  = 29
+	opFsyncdir    = 30
+	opCreate      = 35
+	opInterrupt   = 36
+	opDestroy     = 38
+	opBatchForget = 42
+)
+
+const (
+	// initBigWrites lets the kernel send writes larger than a page.
+	initBigWrites = 1 << 5
+	// setattrSize and setattrFh are the setattr_in valid bits of the size and handle.
+	setattrSize = 1 << 3
+	setattrFh   = 1 << 6
+
+	// maxWrite is the largest write the kernel sends.
+	maxWrite = 128 * 1024
+	// attrTimeout is how long the kernel caches attributes and entries.
+	attrTimeout = time.Second
+
+	// unknownIno is reported for directory entries the kernel has not looked up.
+	unknownIno = 0xffffffff
+)
+
+// FUSE protocol structures, see include/uapi/linux/fuse.h.
+type (
+	inHeader struct {
+		Len         uint32
+		Opcode      uint32
+		Unique      uint64
+		NodeID      uint64
+		UID         uint32
+		GID         uint32
+		PID         uint32
+		TotalExtlen uint16
+		Padding     uint16
+	}
+	outHeader struct {
+		Len    uint32
+		Error  int32
+		Unique uint64
+	}
+	fuseAttr struct {
+		Ino       uint64
+		Size      uint64
+		Blocks    uint64
+		Atime     uint64
+		Mtime     uint64
+		Ctime     uint64
+		Atimensec uint32
+		Mtimensec uint32
+		Ctimensec uint32
+		Mode      uint32
+		Nlink     uint32
+		UID       uint32
+		GID       uint32
+		Rdev      uint32
+		Blksize   uint32
+		Flags     uint32
+	}
+	entryOut struct {
+		NodeID         uint64
+		Generation     uint64
+		EntryValid     uint64
+		AttrValid      uint64
+		EntryValidNsec uint32
+		AttrValidNsec  uint32
+		Attr           fuseAttr
+	}
+	attrOut struct {
+		AttrValid     uint64
+		AttrValidNsec uint32
+		Dummy         uint32
+		Attr          fuseAttr
+	}
+	initIn struct {
+		Major        uint32
+		Minor        uint32
+		MaxReadahead uint32
+		Flags        uint32
+	}
+	initOut struct {
+		Major               uint32
+		Minor               uint32
+		MaxReadahead        uint32
+		Flags               uint32
+		MaxBackground       uint16
+		CongestionThreshold uint16
+		MaxWrite            uint32
+		TimeGran            uint32
+		MaxPages            uint16
+		MapAlignment        uint16
+		Flags2              uint32
+		Unused              [7]uint32
+	}
+	openIn struct {
+		Flags     uint32
+		OpenFlags uint32
+	}
+	openOut struct {
+		Fh        uint64
+		OpenFlags uint32
+		Padding   uint32
+	}
+	createIn struct {
+		Flags     uint32
+		Mode      uint32
+		Umask     uint32
+		OpenFlags uint32
+	}
+	mkdirIn struct {
+		Mode  uint32
+		Umask uint32
+	}
+	readIn struct {
+		Fh        uint64
+		Offset    uint64
+		Size      uint32
+		ReadFlags uint32
+		LockOwner uint64
+		Flags     uint32
+		Padding   uint32
+	}
+	writeOut struct {
+		Size    uint32
+		Padding uint32
+	}
+	fhIn struct {
+		Fh uint64
+	}
+	forgetIn struct {
+		Nlookup uint64
+	}
+	batchForgetIn struct {
+		Count uint32
+		Dummy uint32
+	}
+	forgetOne struct {
+		NodeID  uint64
+		Nlookup uint64
+	}
+	renameIn struct {
+		Newdir uint64
+	}
+	setattrIn struct {
+		Valid     uint32
+		Padding   uint32
+		Fh        uint64
+		Size      uint64
+		LockOwner uint64
+		Atime     uint64
+		Mtime     uint64
+		Ctime     uint64
+		Atimensec uint32
+		Mtimensec uint32
+		Ctimensec uint32
+		Mode      uint32
+		Unused4   uint32
+		UID       uint32
+		GID       uint32
+		Unused5   uint32
+	}
+	xattrIn struct {
+		Size  uint32
+		Flags uint32
+	}
+	xattrOut struct {
+		Size    uint32
+		Padding uint32
+	}
+	statfsOut struct {
+		Blocks  uint64
+		Bfree   uint64
+		Bavail  uint64
+		Files   uint64
+		Ffree   uint64
+		Bsize   uint32
+		Namelen uint32
+		Frsize  uint32
+		Padding uint32
+		Spare   [6]uint32
+	}
+	direntHeader struct {
+		Ino     uint64
+		Off     uint64
+		Namelen uint32
+		Type    uint32
+	}
+)
+
+// Conn is a mounted filesystem.
+type Conn struct {
+	fs  *FS
+	dir string
+	fd  int
+	// fusermount is the helper that mounted the filesystem, "" if it was
+	// mounted with mount(2) directly.
+	fusermount string
+}
+
+// Mount mounts a filesystem at dir. Root mounts with mount(2), other users
+// with the fusermount3 or fusermount helper. Call Serve to handle requests.
+func Mount(fs *FS, dir string) (*Conn, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("mount point %s is not a directory", dir)
+	}
+
+	c := &Conn{fs: fs, dir: dir}
+	if os.Geteuid() == 0 {
+		err = c.mountDirect()
+	} else {
+		err = c.mountHelper()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// mountOptions returns the options common to both mount methods.
+func (c *Conn) mountOptions() []string {
+	opts := []string{"default_permissions"}
+	if c.fs.opts.AllowOther {
+		opts = append(opts, "allow_other")
+	}
+	return opts
+}
+
+// mountDirect mounts the filesystem with mount(2).
+func (c *Conn) mountDirect() error {
+	fd, err := unix.Open("/dev/fuse", unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open /dev/fuse: %w", err)
+	}
+
+	opts := append([]string{
+		fmt.Sprintf("fd=%d", fd),
+		"rootmode=40000",
+		fmt.Sprintf("user_id=%d", os.Getuid()),
+		fmt.Sprintf("group_id=%d", os.Getgid()),
+	}, c.mountOptions()...)
+	flags := uintptr(unix.MS_NOSUID | unix.MS_NODEV)
+	if c.fs.opts.ReadOnly {
+		flags |= unix.MS_RDONLY
+	}
+	if err := unix.Mount("jog", c.dir, "fuse.jog", flags, strings.Join(opts, ",")); err != nil {
+		unix.Close(fd)
+		return fmt.Errorf("failed to mount %s: %w", c.dir, err)
+	}
+	c.fd = fd
+	return nil
+}
+
+// mountHelper mounts the filesystem with fusermount, which passes the opened
+// /dev/fuse back over a socket.
+func (c *Conn) mountHelper() error {
+	helper, err := exec.LookPath("fusermount3")
+	if err != nil {
+		if helper, err = exec.LookPath("fusermount"); err != nil {
+			return errors.New("mounting as a regular user requires fusermount3 or fusermount")
+		}
+	}
+
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to create socket pair: %w", err)
+	}
+	local := os.NewFile(uintptr(fds[0]), "fusermount")
+	remote := os.NewFile(uintptr(fds[1]), "fusermount")
+	defer local.Close()
+	defer remote.Close()
+
+	opts := append([]string{"fsname=jog", "subtype=jog"}, c.mountOptions()...)
+	if c.fs.opts.ReadOnly {
+		opts = append(opts, "ro")
+	}
+	cmd := exec.Command(helper, "-o", strings.Join(opts, ","), "--", c.dir)
+	cmd.Env = append(os.Environ(), "_FUSE_COMMFD=3")
+	cmd.ExtraFiles = []*os.File{remote}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", helper, err)
+	}
+
+	buf := make([]byte, 4)
+	oob := make([]byte, unix.CmsgSpace(4))
+	_, oobn, _, _, err := unix.Recvmsg(fds[0], buf, oob, 0)
+	if err != nil {
+		return fmt.Errorf("failed to receive /dev/fuse from %s: %w", helper, err)
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		return fmt.Errorf("failed to receive /dev/fuse from %s", helper)
+	}
+	rights, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil || len(rights) != 1 {
+		return fmt.Errorf("failed to receive /dev/fuse from %s", helper)
+	}
+	c.fd = rights[0]
+	c.fusermount = helper
+	return nil
+}
+
+// Unmount unmounts the filesystem. Serve returns once the kernel released it.
+func (c *Conn) Unmount() error {
+	if c.fusermount != "" {
+		out, err := exec.Command(c.fusermount, "-u", "-z", c.dir).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s -u failed: %s", c.fusermount, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+	return unix.Unmount(c.dir, unix.MNT_DETACH)
+}
+
+// Serve handles requests of the kernel until the filesystem is unmounted.
+// Requests are handled one at a time.
+func (c *Conn) Serve() error {
+	defer unix.Close(c.fd)
+
+	buf := make([]byte, maxWrite+64*1024)
+	for {
+		n, err := unix.Read(c.fd, buf)
+		if err != nil {
+			switch err {
+			case unix.EINTR, unix.EAGAIN, unix.ENOENT:
+				// Interrupted, or the request was aborted before it was read
+				continue
+			case unix.ENODEV:
+				// Unmounted
+				return nil
+			}
+			return fmt.Errorf("failed to read request: %w", err)
+		}
+
+		var hdr inHeader
+		if n < binary.Size(hdr) {
+			continue
+		}
+		if _, err := binary.Decode(buf, binary.NativeEndian, &hdr); err != nil {
+			continue
+		}
+		body := buf[binary.Size(hdr):n]
+		if hdr.Opcode == opDestroy {
+			c.reply(hdr, 0)
+			return nil
+		}
+		c.handle(hdr, body)
+	}
+}
+
+// reply sends the reply to a request: the errno, or the encoded payloads.
+func (c *Conn) reply(hdr inHeader, e syscall.Errno, payload ...any) {
+	var data bytes.Buffer
+	if e == 0 {
+		for _, p := range payload {
+			if b, ok := p.([]byte); ok {
+				data.Write(b)
+			} else {
+				binary.Write(&data, binary.NativeEndian, p)
+			}
+		}
+	}
+
+	out := outHeader{Unique: hdr.Unique, Error: -int32(e)}
+	out.Len = uint32(binary.Size(out) + data.Len())
+	msg := make([]byte, 0, out.Len)
+	msg, _ = binary.Append(msg, binary.NativeEndian, out)
+	msg = append(msg, data.Bytes()...)
+	// The write fails with ENOENT if the request was interrupted meanwhile
+	unix.Write(c.fd, msg)
+}
+
+// decode decodes the fixed-size request structure at the start of body and
+// returns the rest.
+func decode(body []byte, v any) ([]byte, bool) {
+	n, err := binary.Decode(body, binary.NativeEndian, v)
+	if err != nil {
+		return nil, false
+	}
+	return body[n:], true
+}
+
+// cstrings splits NUL-terminated names.
+func cstrings(body []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(body), "\x00"), "\x00")
+}
+
+// handle handles a request.
+func (c *Conn) handle(hdr inHeader, body []byte) {
+	ctx := context.Background()
+	fs := c.fs
+
+	switch hdr.Opcode {
+	case opInit:
+		var in initIn
+		if _, ok := decode(body, &in); !ok || in.Major != protocolMajor {
+			c.reply(hdr, syscall.EPROTO)
+			return
+		}
+		c.reply(hdr, 0, initOut{
+			Major:        protocolMajor,
+			Minor:        min(in.Minor, protocolMinor),
+			MaxReadahead: in.MaxReadahead,
+			Flags:        in.Flags & initBigWrites,
+			MaxWrite:     maxWrite,
+			TimeGran:     1,
+		})
+
+	case opLookup:
+		n, a, e := fs.Lookup(ctx, hdr.NodeID, cstrings(body)[0])
+		c.replyEntry(hdr, n, a, e)
+
+	case opForget:
+		var in forgetIn
+		if _, ok := decode(body, &in); ok {
+			fs.Forget(hdr.NodeID, in.Nlookup)
+		}
+
+	case opBatchForget:
+		var in batchForgetIn
+		rest, ok := decode(body, &in)
+		for i := uint32(0); ok && i < in.Count; i++ {
+			var one forgetOne
+			if rest, ok = decode(rest, &one); ok {
+				fs.Forget(one.NodeID, one.Nlookup)
+			}
+		}
+
+	case opGetattr:
+		a, e := fs.GetAttr(ctx, hdr.NodeID)
+		c.replyAttr(hdr, a, e)
+
+	case opSetattr:
+		var in setattrIn
+		if _, ok := decode(body, &in); !ok {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		// Modes, owners and times are fixed; only the size can change
+		if in.Valid&setattrSize != 0 {
+			var fh uint64
+			if in.Valid&setattrFh != 0 {
+				fh = in.Fh
+			}
+			if e := fs.Truncate(ctx, hdr.NodeID, fh, int64(in.Size)); e != 0 {
+				c.reply(hdr, e)
+				return
+			}
+		}
+		a, e := fs.GetAttr(ctx, hdr.NodeID)
+		c.replyAttr(hdr, a, e)
+
+	case opMkdir:
+		var in mkdirIn
+		rest, ok := decode(body, &in)
+		if !ok {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		n, a, e := fs.Mkdir(ctx, hdr.NodeID, cstrings(rest)[0])
+		c.replyEntry(hdr, n, a, e)
+
+	case opUnlink:
+		c.reply(hdr, fs.Unlink(ctx, hdr.NodeID, cstrings(body)[0]))
+
+	case opRmdir:
+		c.reply(hdr, fs.Rmdir(ctx, hdr.NodeID, cstrings(body)[0]))
+
+	case opRename:
+		var in renameIn
+		rest, ok := decode(body, &in)
+		names := cstrings(rest)
+		if !ok || len(names) != 2 {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		c.reply(hdr, fs.Rename(ctx, hdr.NodeID, names[0], in.Newdir, names[1]))
+
+	case opOpen:
+		var in openIn
+		if _, ok := decode(body, &in); !ok {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		fh, e := fs.Open(ctx, hdr.NodeID, in.Flags)
+		c.reply(hdr, e, openOut{Fh: fh})
+
+	case opCreate:
+		var in createIn
+		rest, ok := decode(body, &in)
+		if !ok {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		n, a, fh, e := fs.Create(ctx, hdr.NodeID, cstrings(rest)[0])
+		if e != 0 {
+			c.reply(hdr, e)
+			return
+		}
+		c.reply(hdr, 0, c.entry(n, a), openOut{Fh: fh})
+
+	case opRead:
+		var in readIn
+		if _, ok := decode(body, &in); !ok {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		data, e := fs.Read(ctx, in.Fh, int64(in.Offset), int(in.Size))
+		c.reply(hdr, e, data)
+
+	case opWrite:
+		var in readIn
+		rest, ok := decode(body, &in)
+		if !ok || uint32(len(rest)) < in.Size {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		n, e := fs.Write(ctx, in.Fh, int64(in.Offset), rest[:in.Size])
+		c.reply(hdr, e, writeOut{Size: uint32(n)})
+
+	case opFlush, opFsync:
+		var in fhIn
+		if _, ok := decode(body, &in); !ok {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		c.reply(hdr, fs.Flush(ctx, in.Fh))
+
+	case opRelease, opReleasedir:
+		var in fhIn
+		if _, ok := decode(body, &in); !ok {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		c.reply(hdr, fs.Release(ctx, in.Fh))
+
+	case opOpendir:
+		fh, e := fs.OpenDir(ctx, hdr.NodeID)
+		c.reply(hdr, e, openOut{Fh: fh})
+
+	case opReaddir:
+		var in readIn
+		if _, ok := decode(body, &in); !ok {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		entries, e := fs.ReadDir(in.Fh, in.Offset)
+		c.reply(hdr, e, c.dirents(entries, in.Offset, int(in.Size)))
+
+	case opFsyncdir:
+		c.reply(hdr, 0)
+
+	case opStatfs:
+		c.reply(hdr, 0, statfsOut{
+			Blocks:  1 << 40,
+			Bfree:   1 << 40,
+			Bavail:  1 << 40,
+			Files:   1 << 40,
+			Ffree:   1 << 40,
+			Bsize:   4096,
+			Namelen: 255,
+			Frsize:  4096,
+		})
+
+	case opGetxattr:
+		var in xattrIn
+		rest, ok := decode(body, &in)
+		if !ok {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		value, e := fs.GetXattr(ctx, hdr.NodeID, cstrings(rest)[0])
+		c.replyXattr(hdr, in.Size, value, e)
+
+	case opListxattr:
+		var in xattrIn
+		if _, ok := decode(body, &in); !ok {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		names, e := fs.ListXattr(ctx, hdr.NodeID)
+		var list []byte
+		for _, name := range names {
+			list = append(append(list, name...), 0)
+		}
+		c.replyXattr(hdr, in.Size, list, e)
+
+	case opSetxattr:
+		var in xattrIn
+		rest, ok := decode(body, &in)
+		name, value, found := bytes.Cut(rest, []byte{0})
+		if !ok || !found || uint32(len(value)) < in.Size {
+			c.reply(hdr, syscall.EINVAL)
+			return
+		}
+		c.reply(hdr, fs.SetXattr(ctx, hdr.NodeID, string(name), value[:in.Size]))
+
+	case opRemovexattr:
+		c.reply(hdr, fs.RemoveXattr(ctx, hdr.NodeID, cstrings(body)[0]))
+
+	case opInterrupt:
+		// Requests are handled synchronously, so there is nothing to interrupt
+
+	default:
+		c.reply(hdr, syscall.ENOSYS)
+	}
+}
+
+// toFuseAttr converts node attributes.
+func (c *Conn) toFuseAttr(a attr) fuseAttr {
+	mtime := a.mtime.Unix()
+	nsec := uint32(a.mtime.Nanosecond())
+	return fuseAttr{
+		Ino:       a.ino,
+		Size:      uint64(a.size),
+		Blocks:    uint64((a.size + 511) / 512),
+		Atime:     uint64(mtime),
+		Mtime:     uint64(mtime),
+		Ctime:     uint64(mtime),
+		Atimensec: nsec,
+		Mtimensec: nsec,
+		Ctimensec: nsec,
+		Mode:      a.mode,
+		Nlink:     a.nlink,
+		UID:       c.fs.opts.UID,
+		GID:       c.fs.opts.GID,
+		Blksize:   4096,
+	}
+}
+
+// entry returns the entry of a looked up or created node.
+func (c *Conn) entry(n *node, a attr) entryOut {
+	return entryOut{
+		NodeID:         n.id,
+		EntryValid:     uint64(attrTimeout / time.Second),
+		AttrValid:      uint64(attrTimeout / time.Second),
+		EntryValidNsec: uint32(attrTimeout % time.Second),
+		AttrValidNsec:  uint32(attrTimeout % time.Second),
+		Attr:           c.toFuseAttr(a),
+	}
+}
+
+func (c *Conn) replyEntry(hdr inHeader, n *node, a attr, e syscall.Errno) {
+	if e != 0 {
+		c.reply(hdr, e)
+		return
+	}
+	c.reply(hdr, 0, c.entry(n, a))
+}
+
+func (c *Conn) replyAttr(hdr inHeader, a attr, e syscall.Errno) {
+	if e != 0 {
+		c.reply(hdr, e)
+		return
+	}
+	c.reply(hdr, 0, attrOut{
+		AttrValid:     uint64(attrTimeout / time.Second),
+		AttrValidNsec: uint32(attrTimeout % time.Second),
+		Attr:          c.toFuseAttr(a),
+	})
+}
+
+// replyXattr replies with the size of an attribute value if size is 0, or the
+// value if it fits.
+func (c *Conn) replyXattr(hdr inHeader, size uint32, value []byte, e syscall.Errno) {
+	switch {
+	case e != 0:
+		c.reply(hdr, e)
+	case size == 0:
+		c.reply(hdr, 0, xattrOut{Size: uint32(len(value))})
+	case uint32(len(value)) > size:
+		c.reply(hdr, syscall.ERANGE)
+	default:
+		c.reply(hdr, 0, value)
+	}
+}
+
+// dirents encodes directory entries starting at index offset into at most
+// size bytes. The offset of each entry is the index of the entry after it.
+func (c *Conn) dirents(entries []dirEntry, offset uint64, size int) []byte {
+	var buf []byte
+	for i, entry := range entries {
+		typ := uint32(unix.DT_REG)
+		if entry.dir {
+			typ = unix.DT_DIR
+		}
+		hdr := direntHeader{
+			Ino:     unknownIno,
+			Off:     offset + uint64(i) + 1,
+			Namelen: uint32(len(entry.name)),
+			Type:    typ,
+		}
+		// Entries are padded to 8 bytes
+		length := (binary.Size(hdr) + len(entry.name) + 7) &^ 7
+		if len(buf)+length > size {
+			break
+		}
+		buf, _ = binary.Append(buf, binary.NativeEndian, hdr)
+		buf = append(buf, entry.name...)
+		buf = append(buf, make([]byte, length-binary.Size(hdr)-len(entry.name))...)
+	}
+	return buf
+}
@@ -0,0 +1,109 @@
+package mount
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestMount(t *testing.T) {
+	fs, store := newTestFS(t, Options{UID: uint32(os.Getuid()), GID: uint32(os.Getgid())}, map[string]string{
+		"hello.txt":   "hello",
+		"dir/nested":  "nested",
+		"dir/another": "another",
+	})
+
+	dir := t.TempDir()
+	conn, err := Mount(fs, dir)
+	if err != nil {
+		t.Skipf("cannot mount FUSE filesystems here: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- conn.Serve() }()
+	defer func() {
+		if err := conn.Unmount(); err != nil {
+			t.Errorf("Unmount: %v", err)
+		}
+		if err := <-served; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	}()
+
+	disablePoll(t, filepath.Join(dir, "hello.txt"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "hello.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("ReadFile = %q, %v", data, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "dir"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !slices.Equal(names, []string{"another", "nested"}) {
+		t.Errorf("ReadDir = %v", names)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "new", "deep"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new", "deep", "file.txt"), []byte("written"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if body, _ := readObject(t, store, "new/deep/file.txt"); body != "written" {
+		t.Errorf("object = %q", body)
+	}
+
+	if err := os.Rename(filepath.Join(dir, "hello.txt"), filepath.Join(dir, "new", "hello.txt")); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if body, _ := readObject(t, store, "new/hello.txt"); body != "hello" {
+		t.Errorf("renamed object = %q", body)
+	}
+
+	if err := unix.Setxattr(filepath.Join(dir, "new", "hello.txt"), "user.color", []byte("blue"), 0); err != nil {
+		t.Fatalf("Setxattr: %v", err)
+	}
+	obj, err := store.HeadObject(context.Background(), "bucket", "new/hello.txt")
+	if err != nil || obj.Metadata["color"] != "blue" {
+		t.Errorf("metadata = %v, %v", obj, err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "dir", "nested")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dir", "nested")); !os.IsNotExist(err) {
+		t.Errorf("Stat after remove = %v", err)
+	}
+}
+
+// disablePoll makes the kernel learn that the filesystem does not support
+// FUSE_POLL. The os package registers opened files with the runtime poller,
+// and the resulting POLL request would deadlock a process that serves the
+// filesystem itself, since the runtime keeps its processor while registering.
+// A blocking system call releases it, so the request is answered here first.
+func disablePoll(t *testing.T, path string) {
+	t.Helper()
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer unix.Close(fd)
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		t.Fatalf("EpollCreate1: %v", err)
+	}
+	defer unix.Close(epfd)
+	// unix.EpollCtl is a raw system call, which would keep the processor too.
+	event := unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(fd)}
+	unix.Syscall6(unix.SYS_EPOLL_CTL, uintptr(epfd), unix.EPOLL_CTL_ADD, uintptr(fd), uintptr(unsafe.Pointer(&event)), 0, 0)
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package mount
+
+import "errors"
+
+// Conn is a mounted filesystem.
+type Conn struct{}
+
+// Mount mounts a filesystem at dir. FUSE mounts are only supported on Linux.
+func Mount(fs *FS, dir string) (*Conn, error) {
+	return nil, errors.New("mounting buckets is only supported on Linux")
+}
+
+// Unmount unmounts the filesystem.
+func (c *Conn) Unmount() error {
+	return nil
+}
+
+// Serve handles requests of the kernel until the filesystem is unmounted.
+func (c *Conn) Serve() error {
+	return nil
+}
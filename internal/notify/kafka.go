@@ -0,0 +1,544 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+)
+
+const (
+	// defaultKafkaTopic is the topic of events without a configured topic template.
+	defaultKafkaTopic = "jog-events"
+	// kafkaDialTimeout bounds connecting to a Kafka broker.
+	kafkaDialTimeout = 5 * time.Second
+	// kafkaClientID identifies JOG in broker logs and quotas.
+	kafkaClientID = "jog"
+)
+
+// Kafka API keys and the versions used for them.
+const (
+	kafkaAPIProduce          int16 = 0
+	kafkaAPIMetadata         int16 = 3
+	kafkaAPISaslHandshake    int16 = 17
+	kafkaAPISaslAuthenticate int16 = 36
+
+	kafkaProduceVersion          int16 = 3
+	kafkaMetadataVersion         int16 = 4
+	kafkaSaslHandshakeVersion    int16 = 1
+	kafkaSaslAuthenticateVersion int16 = 0
+)
+
+// crc32c is the checksum table of Kafka record batches.
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaTarget produces events to a Kafka topic with acks from all in-sync
+// replicas. Events are keyed by bucket and object key, so events of one object
+// land in the same partition and keep their order.
+//
+// It speaks the Kafka protocol directly and supports the subset needed to
+// produce single records: metadata lookup, produce and SASL/PLAIN.
+type kafkaTarget struct {
+	bootstrap []string
+	tlsConfig *tls.Config
+	topic     *template.Template
+	sasl      config.SASLConfig
+
+	mu      sync.Mutex
+	conns   map[string]*kafkaConn
+	brokers map[int32]string
+	topics  map[string][]kafkaPartition
+}
+
+// kafkaPartition is a partition of a topic and the broker leading it.
+type kafkaPartition struct {
+	ID     int32
+	Leader int32
+}
+
+// kafkaError is an error code returned by a Kafka broker.
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	return "kafka error code " + strconv.Itoa(int(e))
+}
+
+func newKafkaTarget(cfg config.KafkaConfig, tlsConfig *tls.Config) (*kafkaTarget, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("kafka brokers are required")
+	}
+	switch strings.ToLower(cfg.SASL.Mechanism) {
+	case "", "plain":
+	default:
+		return nil, fmt.Errorf("unsupported kafka sasl mechanism: %q", cfg.SASL.Mechanism)
+	}
+
+	topic, err := parseNameTemplate("topic", cfg.Topic, defaultKafkaTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaTarget{
+		bootstrap: cfg.Brokers,
+		tlsConfig: tlsConfig,
+		topic:     topic,
+		sasl:      cfg.SASL,
+		conns:     make(map[string]*kafkaConn),
+		brokers:   make(map[int32]string),
+		topics:    make(map[string][]kafkaPartition),
+	}, nil
+}
+
+// Send produces an event to the leader of its partition.
+func (t *kafkaTarget) Send(ctx context.Context, event events.Event, payload []byte) error {
+	topic, err := executeNameTemplate(t.topic, event)
+	if err != nil {
+		return err
+	}
+	key := []byte(event.Bucket + "/" + event.Key)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	partitions, err := t.partitions(ctx, topic)
+	if err != nil {
+		return err
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	partition := partitions[h.Sum32()%uint32(len(partitions))]
+
+	address, ok := t.brokers[partition.Leader]
+	if !ok {
+		delete(t.topics, topic)
+		return fmt.Errorf("kafka: no broker for leader %d of %s/%d", partition.Leader, topic, partition.ID)
+	}
+	conn, err := t.conn(ctx, address)
+	if err != nil {
+		delete(t.topics, topic)
+		return err
+	}
+
+	batch := encodeRecordBatch(key, payload, event.Time)
+	if err := conn.produce(ctx, topic, partition.ID, batch); err != nil {
+		// Leadership may have moved; look it up again on the next attempt
+		delete(t.topics, topic)
+		var kafkaErr kafkaError
+		if !errors.As(err, &kafkaErr) {
+			t.closeConn(address)
+		}
+		return fmt.Errorf("kafka: failed to produce to %s/%d: %w", topic, partition.ID, err)
+	}
+	return nil
+}
+
+// partitions returns the partitions of a topic, fetching metadata if it is not cached.
+// The caller must hold t.mu.
+func (t *kafkaTarget) partitions(ctx context.Context, topic string) ([]kafkaPartition, error) {
+	if partitions, ok := t.topics[topic]; ok {
+		return partitions, nil
+	}
+
+	var errs []error
+	for _, address := range t.bootstrap {
+		conn, err := t.conn(ctx, address)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		brokers, partitions, err := conn.metadata(ctx, topic)
+		if err != nil {
+			var kafkaErr kafkaError
+			if !errors.As(err, &kafkaErr) {
+				t.closeConn(address)
+			}
+			errs = append(errs, fmt.Errorf("kafka: metadata of %s from %s: %w", topic, address, err))
+			continue
+		}
+		for id, brokerAddress := range brokers {
+			t.brokers[id] = brokerAddress
+		}
+		t.topics[topic] = partitions
+		return partitions, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// conn returns an authenticated connection to a broker. The caller must hold t.mu.
+func (t *kafkaTarget) conn(ctx context.Context, address string) (*kafkaConn, error) {
+	if conn, ok := t.conns[address]; ok {
+		return conn, nil
+	}
+
+	dialer := &net.Dialer{Timeout: kafkaDialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: failed to connect to %s: %w", address, err)
+	}
+	if t.tlsConfig != nil {
+		tlsConfig := t.tlsConfig
+		if tlsConfig.ServerName == "" && !tlsConfig.InsecureSkipVerify {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		tlsConn := tls.Client(netConn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("kafka: tls handshake with %s failed: %w", address, err)
+		}
+		netConn = tlsConn
+	}
+
+	conn := &kafkaConn{conn: netConn}
+	if t.sasl.Mechanism != "" {
+		if err := conn.authenticatePlain(ctx, t.sasl.Username, t.sasl.Password); err != nil {
+			conn.conn.Close()
+			return nil, fmt.Errorf("kafka: sasl authentication with %s failed: %w", address, err)
+		}
+	}
+	t.conns[address] = conn
+	return conn, nil
+}
+
+// closeConn closes and forgets the connection to a broker. The caller must hold t.mu.
+func (t *kafkaTarget) closeConn(address string) {
+	if conn, ok := t.conns[address]; ok {
+		conn.conn.Close()
+		delete(t.conns, address)
+	}
+}
+
+// Close closes all broker connections.
+func (t *kafkaTarget) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for address := range t.conns {
+		t.closeConn(address)
+	}
+	return nil
+}
+
+// kafkaConn is a connection to a Kafka broker.
+type kafkaConn struct {
+	conn          net.Conn
+	correlationID int32
+}
+
+// roundTrip sends a request and returns the response body.
+func (c *kafkaConn) roundTrip(ctx context.Context, apiKey, apiVersion int16, body []byte) (*kafkaDecoder, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(sendTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+
+	c.correlationID++
+	var req kafkaEncoder
+	req.int32(0) // size, set below
+	req.int16(apiKey)
+	req.int16(apiVersion)
+	req.int32(c.correlationID)
+	req.string(kafkaClientID)
+	req.buf = append(req.buf, body...)
+	binary.BigEndian.PutUint32(req.buf, uint32(len(req.buf)-4))
+	if _, err := c.conn.Write(req.buf); err != nil {
+		return nil, err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.conn, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return nil, err
+	}
+
+	d := &kafkaDecoder{buf: resp}
+	if id := d.int32(); id != c.correlationID {
+		return nil, fmt.Errorf("unexpected correlation id %d, want %d", id, c.correlationID)
+	}
+	return d, d.err
+}
+
+// authenticatePlain performs a SASL/PLAIN handshake.
+func (c *kafkaConn) authenticatePlain(ctx context.Context, username, password string) error {
+	var req kafkaEncoder
+	req.string("PLAIN")
+	d, err := c.roundTrip(ctx, kafkaAPISaslHandshake, kafkaSaslHandshakeVersion, req.buf)
+	if err != nil {
+		return err
+	}
+	if code := d.int16(); code != 0 {
+		return fmt.Errorf("PLAIN mechanism rejected: %w", kafkaError(code))
+	}
+
+	req = kafkaEncoder{}
+	req.bytes([]byte("\x00" + username + "\x00" + password))
+	d, err = c.roundTrip(ctx, kafkaAPISaslAuthenticate, kafkaSaslAuthenticateVersion, req.buf)
+	if err != nil {
+		return err
+	}
+	if code := d.int16(); code != 0 {
+		if message := d.nullableString(); message != "" {
+			return fmt.Errorf("%s: %w", message, kafkaError(code))
+		}
+		return kafkaError(code)
+	}
+	return d.err
+}
+
+// metadata returns the broker addresses and the partitions of a topic.
+func (c *kafkaConn) metadata(ctx context.Context, topic string) (map[int32]string, []kafkaPartition, error) {
+	var req kafkaEncoder
+	req.int32(1)
+	req.string(topic)
+	req.bool(true) // allow_auto_topic_creation
+	d, err := c.roundTrip(ctx, kafkaAPIMetadata, kafkaMetadataVersion, req.buf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d.int32() // throttle_time_ms
+	brokers := make(map[int32]string)
+	for n := d.arrayLen(); n > 0; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.nullableString() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.nullableString() // cluster_id
+	d.int32()          // controller_id
+
+	var partitions []kafkaPartition
+	var topicErr error
+	for n := d.arrayLen(); n > 0; n-- {
+		code := d.int16()
+		name := d.string()
+		d.bool() // is_internal
+		for m := d.arrayLen(); m > 0; m-- {
+			d.int16() // partition error_code
+			partition := kafkaPartition{ID: d.int32(), Leader: d.int32()}
+			d.int32Array() // replica_nodes
+			d.int32Array() // isr_nodes
+			if name == topic {
+				partitions = append(partitions, partition)
+			}
+		}
+		if name == topic && code != 0 {
+			topicErr = kafkaError(code)
+		}
+	}
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	if topicErr != nil {
+		return nil, nil, topicErr
+	}
+	if len(partitions) == 0 {
+		return nil, nil, fmt.Errorf("topic %s has no partitions", topic)
+	}
+	return brokers, partitions, nil
+}
+
+// produce writes a record batch to a partition and waits for all in-sync replicas.
+func (c *kafkaConn) produce(ctx context.Context, topic string, partition int32, batch []byte) error {
+	var req kafkaEncoder
+	req.nullableString(nil) // transactional_id
+	req.int16(-1)           // acks from all in-sync replicas
+	req.int32(int32(sendTimeout / time.Millisecond))
+	req.int32(1)
+	req.string(topic)
+	req.int32(1)
+	req.int32(partition)
+	req.bytes(batch)
+	d, err := c.roundTrip(ctx, kafkaAPIProduce, kafkaProduceVersion, req.buf)
+	if err != nil {
+		return err
+	}
+
+	for n := d.arrayLen(); n > 0; n-- {
+		d.string() // name
+		for m := d.arrayLen(); m > 0; m-- {
+			d.int32() // index
+			if code := d.int16(); code != 0 {
+				return kafkaError(code)
+			}
+			d.int64() // base_offset
+			d.int64() // log_append_time_ms
+		}
+	}
+	return d.err
+}
+
+// encodeRecordBatch encodes a record batch (magic 2) with a single record.
+func encodeRecordBatch(key, value []byte, timestamp time.Time) []byte {
+	var record kafkaEncoder
+	record.int8(0)   // attributes
+	record.varint(0) // timestamp delta
+	record.varint(0) // offset delta
+	record.varint(int64(len(key)))
+	record.buf = append(record.buf, key...)
+	record.varint(int64(len(value)))
+	record.buf = append(record.buf, value...)
+	record.varint(0) // headers
+
+	ms := timestamp.UnixMilli()
+	var batch kafkaEncoder
+	batch.int64(0)  // base offset
+	batch.int32(0)  // batch length, set below
+	batch.int32(-1) // partition leader epoch
+	batch.int8(2)   // magic
+	batch.int32(0)  // crc, set below
+	crcStart := len(batch.buf)
+	batch.int16(0)  // attributes
+	batch.int32(0)  // last offset delta
+	batch.int64(ms) // first timestamp
+	batch.int64(ms) // max timestamp
+	batch.int64(-1) // producer id
+	batch.int16(-1) // producer epoch
+	batch.int32(-1) // base sequence
+	batch.int32(1)  // records
+	batch.varint(int64(len(record.buf)))
+	batch.buf = append(batch.buf, record.buf...)
+
+	binary.BigEndian.PutUint32(batch.buf[8:], uint32(len(batch.buf)-12))
+	binary.BigEndian.PutUint32(batch.buf[crcStart-4:], crc32.Checksum(batch.buf[crcStart:], crc32c))
+	return batch.buf
+}
+
+// kafkaEncoder appends values in the Kafka wire format.
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int8(v int8)    { e.buf = append(e.buf, byte(v)) }
+func (e *kafkaEncoder) int16(v int16)  { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32)  { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64)  { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+func (e *kafkaEncoder) varint(v int64) { e.buf = binary.AppendVarint(e.buf, v) }
+
+func (e *kafkaEncoder) bool(v bool) {
+	if v {
+		e.int8(1)
+	} else {
+		e.int8(0)
+	}
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *kafkaEncoder) nullableString(s *string) {
+	if s == nil {
+		e.int16(-1)
+		return
+	}
+	e.string(*s)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// kafkaDecoder reads values in the Kafka wire format. After the first error,
+// all reads return zero values and err is set.
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = errors.New("kafka: truncated response")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) bool() bool {
+	return d.int8() != 0
+}
+
+func (d *kafkaDecoder) string() string {
+	return string(d.next(int(d.int16())))
+}
+
+func (d *kafkaDecoder) nullableString() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// arrayLen reads an array length. Null arrays have no elements.
+func (d *kafkaDecoder) arrayLen() int {
+	n := d.int32()
+	if n < 0 {
+		return 0
+	}
+	if int(n) > len(d.buf) && d.err == nil {
+		d.err = errors.New("kafka: truncated response")
+	}
+	if d.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (d *kafkaDecoder) int32Array() {
+	for n := d.arrayLen(); n > 0; n-- {
+		d.int32()
+	}
+}
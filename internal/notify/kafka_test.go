@@ -0,0 +1,226 @@
+package notify
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+)
+
+// kafkaRecord is a record produced to fakeKafkaBroker.
+type kafkaRecord struct {
+	topic     string
+	partition int32
+	key       string
+	value     string
+}
+
+// fakeKafkaBroker is a single broker cluster that serves metadata, SASL/PLAIN
+// and produce requests and records what it receives.
+type fakeKafkaBroker struct {
+	t        *testing.T
+	address  string
+	records  chan kafkaRecord
+	saslAuth chan string
+}
+
+func newFakeKafkaBroker(t *testing.T) *fakeKafkaBroker {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	b := &fakeKafkaBroker{
+		t:        t,
+		address:  ln.Addr().String(),
+		records:  make(chan kafkaRecord, 10),
+		saslAuth: make(chan string, 1),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeKafkaBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+
+		d := &kafkaDecoder{buf: req}
+		apiKey := d.int16()
+		d.int16() // version
+		correlationID := d.int32()
+		d.nullableString() // client id
+
+		var resp kafkaEncoder
+		resp.int32(0)
+		resp.int32(correlationID)
+		switch apiKey {
+		case kafkaAPISaslHandshake:
+			resp.int16(0)
+			resp.int32(1)
+			resp.string("PLAIN")
+		case kafkaAPISaslAuthenticate:
+			b.saslAuth <- string(d.next(int(d.int32())))
+			resp.int16(0)
+			resp.int16(-1)
+			resp.int32(0)
+		case kafkaAPIMetadata:
+			d.int32()
+			topic := d.string()
+			host, port, _ := net.SplitHostPort(b.address)
+			portNum, _ := strconv.Atoi(port)
+			resp.int32(0) // throttle
+			resp.int32(1) // brokers
+			resp.int32(7)
+			resp.string(host)
+			resp.int32(int32(portNum))
+			resp.int16(-1)
+			resp.int16(-1) // cluster id
+			resp.int32(7)  // controller
+			resp.int32(1)  // topics
+			resp.int16(0)
+			resp.string(topic)
+			resp.bool(false)
+			resp.int32(2) // partitions
+			for id := int32(0); id < 2; id++ {
+				resp.int16(0)
+				resp.int32(id)
+				resp.int32(7)
+				resp.int32(1)
+				resp.int32(7)
+				resp.int32(1)
+				resp.int32(7)
+			}
+		case kafkaAPIProduce:
+			d.nullableString() // transactional id
+			if acks := d.int16(); acks != -1 {
+				b.t.Errorf("acks = %d, want -1", acks)
+			}
+			d.int32() // timeout
+			d.int32() // topics
+			topic := d.string()
+			d.int32() // partitions
+			partition := d.int32()
+			batch := d.next(int(d.int32()))
+			record := b.decodeBatch(batch)
+			record.topic = topic
+			record.partition = partition
+			b.records <- record
+
+			resp.int32(1)
+			resp.string(topic)
+			resp.int32(1)
+			resp.int32(partition)
+			resp.int16(0)
+			resp.int64(0)
+			resp.int64(-1)
+			resp.int32(0) // throttle
+		default:
+			b.t.Errorf("unexpected api key %d", apiKey)
+			return
+		}
+		binary.BigEndian.PutUint32(resp.buf, uint32(len(resp.buf)-4))
+		if _, err := conn.Write(resp.buf); err != nil {
+			return
+		}
+	}
+}
+
+// decodeBatch verifies a record batch and returns its only record.
+func (b *fakeKafkaBroker) decodeBatch(batch []byte) kafkaRecord {
+	d := &kafkaDecoder{buf: batch}
+	d.int64() // base offset
+	if length := d.int32(); int(length) != len(batch)-12 {
+		b.t.Errorf("batch length = %d, want %d", length, len(batch)-12)
+	}
+	d.int32() // leader epoch
+	if magic := d.int8(); magic != 2 {
+		b.t.Errorf("magic = %d, want 2", magic)
+	}
+	crc := uint32(d.int32())
+	if want := crc32.Checksum(d.buf, crc32c); crc != want {
+		b.t.Errorf("crc = %x, want %x", crc, want)
+	}
+	d.next(2 + 4 + 8 + 8 + 8 + 2 + 4)
+	if n := d.int32(); n != 1 {
+		b.t.Errorf("records = %d, want 1", n)
+	}
+
+	varint := func() int64 {
+		v, n := binary.Varint(d.buf)
+		d.buf = d.buf[n:]
+		return v
+	}
+	varint() // record length
+	d.int8()
+	varint()
+	varint()
+	key := string(d.next(int(varint())))
+	value := string(d.next(int(varint())))
+	if d.err != nil {
+		b.t.Errorf("decode batch: %v", d.err)
+	}
+	return kafkaRecord{key: key, value: value}
+}
+
+func TestKafkaTarget(t *testing.T) {
+	broker := newFakeKafkaBroker(t)
+	target, err := newKafkaTarget(config.KafkaConfig{
+		Brokers: []string{broker.address},
+		Topic:   "jog-{{.Bucket}}",
+		SASL:    config.SASLConfig{Mechanism: "PLAIN", Username: "user", Password: "pass"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var partitions []int32
+	for i := 0; i < 2; i++ {
+		event := events.Event{Type: events.ObjectCreatedPut, Time: time.Now(), Bucket: "photos", Key: "cat.jpg"}
+		if err := target.Send(ctx, event, []byte(`{"n":`+strconv.Itoa(i)+`}`)); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		record := <-broker.records
+		if record.topic != "jog-photos" || record.key != "photos/cat.jpg" || record.value != `{"n":`+strconv.Itoa(i)+`}` {
+			t.Errorf("record = %+v", record)
+		}
+		partitions = append(partitions, record.partition)
+	}
+	if partitions[0] != partitions[1] {
+		t.Errorf("events of one object went to partitions %v", partitions)
+	}
+
+	// One connection serves both bootstrap and leader requests, so SASL ran once
+	if auth := <-broker.saslAuth; auth != "\x00user\x00pass" {
+		t.Errorf("sasl auth = %q", auth)
+	}
+}
@@ -0,0 +1,345 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+)
+
+const (
+	// defaultNATSSubject is the subject of events without a configured subject template.
+	defaultNATSSubject = "jog.events"
+	// natsDialTimeout bounds connecting to the NATS server.
+	natsDialTimeout = 5 * time.Second
+)
+
+// natsTarget publishes events to a NATS subject. With JetStream, each publish
+// waits for the stream acknowledgement, so events are only reported as
+// delivered once they are persisted.
+//
+// It speaks the NATS client protocol directly and keeps a single connection,
+// which is re-established on the next send after an error.
+type natsTarget struct {
+	address   string
+	tlsConfig *tls.Config
+	subject   *template.Template
+	jetStream bool
+	username  string
+	password  string
+	token     string
+
+	mu    sync.Mutex
+	conn  net.Conn
+	r     *bufio.Reader
+	inbox string
+}
+
+// natsInfo is the INFO message a NATS server sends after accepting a connection.
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+// natsConnect is the CONNECT message of the NATS client protocol.
+type natsConnect struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	Protocol  int    `json:"protocol"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+// natsPubAck is the JetStream acknowledgement of a published message.
+type natsPubAck struct {
+	Stream string `json:"stream"`
+	Seq    uint64 `json:"seq"`
+	Error  *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+func newNATSTarget(cfg config.NATSConfig, tlsConfig *tls.Config) (*natsTarget, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("nats url is required")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nats url: %w", err)
+	}
+	switch u.Scheme {
+	case "nats":
+	case "tls":
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported nats url scheme: %q", u.Scheme)
+	}
+
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if tlsConfig != nil && tlsConfig.ServerName == "" && !tlsConfig.InsecureSkipVerify {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	subject, err := parseNameTemplate("subject", cfg.Subject, defaultNATSSubject)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &natsTarget{
+		address:   address,
+		tlsConfig: tlsConfig,
+		subject:   subject,
+		jetStream: cfg.JetStream,
+		username:  cfg.Username,
+		password:  cfg.Password,
+		token:     cfg.Token,
+	}
+	if u.User != nil {
+		t.username = u.User.Username()
+		t.password, _ = u.User.Password()
+	}
+	return t, nil
+}
+
+// Send publishes an event and waits until the server has processed it.
+func (t *natsTarget) Send(ctx context.Context, event events.Event, payload []byte) error {
+	subject, err := executeNameTemplate(t.subject, event)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == nil {
+		if err := t.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if err := t.publish(ctx, subject, payload); err != nil {
+		t.closeConn()
+		return err
+	}
+	return nil
+}
+
+// connect opens a connection and completes the handshake. The caller must hold t.mu.
+func (t *natsTarget) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: natsDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	t.conn = conn
+	t.r = bufio.NewReader(conn)
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := t.handshake(ctx); err != nil {
+		t.closeConn()
+		return err
+	}
+	return nil
+}
+
+// handshake reads the server INFO, upgrades to TLS and authenticates.
+func (t *natsTarget) handshake(ctx context.Context) error {
+	line, err := t.readLine()
+	if err != nil {
+		return fmt.Errorf("failed to read nats info: %w", err)
+	}
+	infoJSON, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		return fmt.Errorf("unexpected nats greeting: %q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return fmt.Errorf("invalid nats info: %w", err)
+	}
+
+	if info.TLSRequired && t.tlsConfig == nil {
+		return errors.New("nats server requires tls")
+	}
+	if t.tlsConfig != nil {
+		tlsConn := tls.Client(t.conn, t.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("nats tls handshake failed: %w", err)
+		}
+		t.conn = tlsConn
+		t.r = bufio.NewReader(tlsConn)
+	}
+
+	connect, err := json.Marshal(natsConnect{
+		Name:      "jog",
+		Lang:      "go",
+		Version:   "1.0.0",
+		Protocol:  1,
+		User:      t.username,
+		Pass:      t.password,
+		AuthToken: t.token,
+	})
+	if err != nil {
+		return err
+	}
+	msg := "CONNECT " + string(connect) + "\r\n"
+	if t.jetStream {
+		t.inbox = "_INBOX." + randomToken()
+		msg += "SUB " + t.inbox + " 1\r\n"
+	}
+	if _, err := t.conn.Write([]byte(msg + "PING\r\n")); err != nil {
+		return fmt.Errorf("failed to send nats connect: %w", err)
+	}
+	_, err = t.waitFor(func(line string) bool { return line == "PONG" })
+	return err
+}
+
+// publish sends a message. Without JetStream, a PING round trip confirms the
+// server accepted it; with JetStream, the stream acknowledgement does.
+func (t *natsTarget) publish(ctx context.Context, subject string, payload []byte) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(sendTimeout)
+	}
+	t.conn.SetDeadline(deadline)
+
+	var header string
+	if t.jetStream {
+		header = fmt.Sprintf("PUB %s %s %d\r\n", subject, t.inbox, len(payload))
+	} else {
+		header = fmt.Sprintf("PUB %s %d\r\n", subject, len(payload))
+	}
+	msg := make([]byte, 0, len(header)+len(payload)+8)
+	msg = append(msg, header...)
+	msg = append(msg, payload...)
+	msg = append(msg, "\r\n"...)
+	if !t.jetStream {
+		msg = append(msg, "PING\r\n"...)
+	}
+	if _, err := t.conn.Write(msg); err != nil {
+		return fmt.Errorf("failed to publish to nats: %w", err)
+	}
+
+	if !t.jetStream {
+		_, err := t.waitFor(func(line string) bool { return line == "PONG" })
+		return err
+	}
+
+	ackData, err := t.waitFor(func(line string) bool {
+		return strings.HasPrefix(line, "MSG "+t.inbox+" ")
+	})
+	if err != nil {
+		return err
+	}
+	var ack natsPubAck
+	if err := json.Unmarshal(ackData, &ack); err != nil {
+		return fmt.Errorf("invalid jetstream ack: %w", err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("jetstream rejected event: %s (%d)", ack.Error.Description, ack.Error.Code)
+	}
+	return nil
+}
+
+// waitFor reads server messages until done reports the expected one. It answers
+// server PINGs and returns the payload of the expected message if it is a MSG.
+func (t *natsTarget) waitFor(done func(line string) bool) ([]byte, error) {
+	for {
+		line, err := t.readLine()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from nats: %w", err)
+		}
+
+		switch {
+		case line == "PING":
+			if _, err := t.conn.Write([]byte("PONG\r\n")); err != nil {
+				return nil, err
+			}
+			continue
+		case strings.HasPrefix(line, "-ERR"):
+			return nil, fmt.Errorf("nats error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+
+		var payload []byte
+		if strings.HasPrefix(line, "MSG ") {
+			if payload, err = t.readPayload(line); err != nil {
+				return nil, err
+			}
+		}
+		if done(line) {
+			return payload, nil
+		}
+	}
+}
+
+// readPayload reads the payload of a MSG whose header line is given.
+func (t *natsTarget) readPayload(line string) ([]byte, error) {
+	fields := strings.Fields(line)
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid nats message header: %q", line)
+	}
+	payload := make([]byte, size+2)
+	if _, err := io.ReadFull(t.r, payload); err != nil {
+		return nil, fmt.Errorf("failed to read nats message: %w", err)
+	}
+	return payload[:size], nil
+}
+
+// readLine reads a protocol line without the trailing CRLF.
+func (t *natsTarget) readLine() (string, error) {
+	line, err := t.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// closeConn closes the connection. The caller must hold t.mu.
+func (t *natsTarget) closeConn() {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+		t.r = nil
+	}
+}
+
+// Close closes the connection to the server.
+func (t *natsTarget) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeConn()
+	return nil
+}
+
+// randomToken returns a random hex string for inbox subjects.
+func randomToken() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+)
+
+// natsMessage is a message received by fakeNATSServer.
+type natsMessage struct {
+	subject string
+	payload string
+}
+
+// fakeNATSServer accepts one client and records its publishes. With jetStream,
+// publishes with a reply subject are acknowledged like a stream would.
+func fakeNATSServer(t *testing.T, jetStream bool) (string, <-chan natsMessage, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan natsMessage, 10)
+	connects := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		fmt.Fprint(conn, "INFO {\"server_id\":\"fake\",\"tls_required\":false}\r\n")
+		r := bufio.NewReader(conn)
+		seq := 0
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "CONNECT":
+				connects <- strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+			case "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case "PUB":
+				size, _ := strconv.Atoi(fields[len(fields)-1])
+				payload := make([]byte, size+2)
+				if _, err := io.ReadFull(r, payload); err != nil {
+					return
+				}
+				messages <- natsMessage{subject: fields[1], payload: string(payload[:size])}
+				if jetStream && len(fields) == 4 {
+					seq++
+					ack := fmt.Sprintf(`{"stream":"JOG","seq":%d}`, seq)
+					fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", fields[2], len(ack), ack)
+				}
+			}
+		}
+	}()
+	return "nats://" + ln.Addr().String(), messages, connects
+}
+
+func TestNATSTarget(t *testing.T) {
+	for _, jetStream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("jetstream=%v", jetStream), func(t *testing.T) {
+			url, messages, connects := fakeNATSServer(t, jetStream)
+			target, err := newNATSTarget(config.NATSConfig{
+				URL:       url,
+				Subject:   "jog.{{.Bucket}}",
+				JetStream: jetStream,
+				Token:     "secret",
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer target.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for _, key := range []string{"a", "b"} {
+				event := events.Event{Type: events.ObjectCreatedPut, Bucket: "photos", Key: key}
+				if err := target.Send(ctx, event, []byte(`{"key":"`+key+`"}`)); err != nil {
+					t.Fatalf("Send: %v", err)
+				}
+			}
+
+			if connect := <-connects; !strings.Contains(connect, `"auth_token":"secret"`) {
+				t.Errorf("CONNECT = %s, want auth token", connect)
+			}
+			for _, key := range []string{"a", "b"} {
+				msg := <-messages
+				if msg.subject != "jog.photos" || msg.payload != `{"key":"`+key+`"}` {
+					t.Errorf("message = %+v", msg)
+				}
+			}
+		})
+	}
+}
+
+func TestNATSTargetUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	target, err := newNATSTarget(config.NATSConfig{URL: "nats://" + address}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := target.Send(ctx, events.Event{Bucket: "b", Key: "k"}, []byte("{}")); err == nil {
+		t.Error("Send to a closed port succeeded")
+	}
+}
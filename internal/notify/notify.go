@@ -0,0 +1,237 @@
+// Package notify publishes object events to external messaging systems.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sendAttempts is how often delivery of an event to a target is attempted.
+	sendAttempts = 3
+	// retryDelay is the delay before the first retry; it doubles with each attempt.
+	retryDelay = 500 * time.Millisecond
+	// sendTimeout bounds a single delivery attempt.
+	sendTimeout = 10 * time.Second
+)
+
+var (
+	notificationsSent = metrics.NewCounter("jog_notifications_sent_total",
+		"Object events delivered to notification targets.")
+	notificationsFailed = metrics.NewCounter("jog_notifications_failed_total",
+		"Object events that could not be delivered to a notification target.")
+)
+
+// Target delivers events to an external system.
+type Target interface {
+	// Send delivers one event. It is not called concurrently.
+	Send(ctx context.Context, event events.Event, payload []byte) error
+	// Close releases the connections of the target.
+	Close() error
+}
+
+// NewTarget creates the target described by cfg.
+func NewTarget(cfg config.NotificationTargetConfig) (Target, error) {
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case "nats":
+		return newNATSTarget(cfg.NATS, tlsConfig)
+	case "kafka":
+		return newKafkaTarget(cfg.Kafka, tlsConfig)
+	case "sqs":
+		return newSQSTarget(cfg.SQS, tlsConfig)
+	default:
+		return nil, fmt.Errorf("unknown notification target type: %q", cfg.Type)
+	}
+}
+
+// newTLSConfig builds a client TLS configuration, or returns nil if TLS is disabled.
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// parseNameTemplate parses a subject or topic template. An empty text uses the
+// fallback name.
+func parseNameTemplate(kind, text, fallback string) (*template.Template, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(kind).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", kind, err)
+	}
+	return tmpl, nil
+}
+
+// executeNameTemplate renders a subject or topic for an event.
+func executeNameTemplate(tmpl *template.Template, event events.Event) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", err
+	}
+	name := buf.String()
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return "", fmt.Errorf("invalid %s %q for event", tmpl.Name(), name)
+	}
+	return name, nil
+}
+
+// matchEventType reports whether an event type matches one of the patterns.
+// A pattern ending with "*" matches event types starting with the rest of it.
+func matchEventType(patterns []string, eventType events.Type) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(string(eventType), prefix) {
+				return true
+			}
+		} else if pattern == string(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notifier delivers events from a broker to the configured targets.
+// Each target has its own subscription, so a slow target does not delay the others.
+type Notifier struct {
+	subscriptions []*events.Subscription
+	targets       []Target
+	wg            sync.WaitGroup
+}
+
+// Start creates the configured targets and starts delivering events published to broker.
+func Start(broker *events.Broker, cfg config.NotificationsConfig) (*Notifier, error) {
+	n := &Notifier{}
+	for _, targetCfg := range cfg.Targets {
+		if targetCfg.Name == "" {
+			n.Close()
+			return nil, errors.New("notification target name is required")
+		}
+		target, err := NewTarget(targetCfg)
+		if err != nil {
+			n.Close()
+			return nil, fmt.Errorf("notification target %s: %w", targetCfg.Name, err)
+		}
+
+		sub := broker.Subscribe(events.Filter{
+			AllTenants: true,
+			Bucket:     targetCfg.Bucket,
+			Prefix:     targetCfg.Prefix,
+		})
+		n.targets = append(n.targets, target)
+		n.subscriptions = append(n.subscriptions, sub)
+
+		n.wg.Add(1)
+		go n.deliver(targetCfg.Name, targetCfg.Events, sub, target)
+	}
+	return n, nil
+}
+
+// deliver sends the events of a subscription to a target until the subscription is closed.
+func (n *Notifier) deliver(name string, eventTypes []string, sub *events.Subscription, target Target) {
+	defer n.wg.Done()
+
+	for event := range sub.C {
+		if !matchEventType(eventTypes, event.Type) {
+			continue
+		}
+		payload, err := marshalRecords(name, event)
+		if err != nil {
+			log.Error().Err(err).Str("target", name).Msg("Failed to encode event")
+			continue
+		}
+
+		if err := send(target, event, payload); err != nil {
+			notificationsFailed.Inc()
+			log.Error().Err(err).
+				Str("target", name).
+				Str("bucket", event.Bucket).
+				Str("key", event.Key).
+				Str("event", string(event.Type)).
+				Msg("Failed to deliver event")
+			continue
+		}
+		notificationsSent.Inc()
+	}
+}
+
+// send delivers an event, retrying with backoff.
+func send(target Target, event events.Event, payload []byte) error {
+	var err error
+	delay := retryDelay
+	for attempt := 1; attempt <= sendAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = target.Send(ctx, event, payload)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt < sendAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+// Close stops delivery, waits for events in flight and closes the targets.
+// Events still buffered for a target are delivered before it is closed.
+func (n *Notifier) Close() error {
+	for _, sub := range n.subscriptions {
+		sub.Close()
+	}
+	n.wg.Wait()
+
+	var errs []error
+	for _, target := range n.targets {
+		errs = append(errs, target.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+)
+
+func TestMatchEventType(t *testing.T) {
+	tests := []struct {
+		patterns  []string
+		eventType events.Type
+		want      bool
+	}{
+		{nil, events.ObjectRemovedDelete, true},
+		{[]string{"s3:ObjectCreated:*"}, events.ObjectCreatedPut, true},
+		{[]string{"s3:ObjectCreated:*"}, events.ObjectRemovedDelete, false},
+		{[]string{"s3:ObjectRemoved:Delete"}, events.ObjectRemovedDelete, true},
+		{[]string{"s3:ObjectRemoved:Delete"}, events.ObjectRemovedDeleteMarkerCreated, false},
+	}
+	for _, tt := range tests {
+		if got := matchEventType(tt.patterns, tt.eventType); got != tt.want {
+			t.Errorf("matchEventType(%v, %s) = %v, want %v", tt.patterns, tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalRecords(t *testing.T) {
+	payload, err := marshalRecords("uploads", events.Event{
+		Type:      events.ObjectCreatedPut,
+		Time:      time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Bucket:    "bucket",
+		Key:       "photos/my cat.jpg",
+		Size:      42,
+		ETag:      "abc",
+		Principal: "AKID",
+		SourceIP:  "192.0.2.1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg eventMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Records) != 1 {
+		t.Fatalf("got %d records, want 1", len(msg.Records))
+	}
+	r := msg.Records[0]
+	if r.EventName != "ObjectCreated:Put" || r.EventTime != "2025-01-02T03:04:05.000Z" {
+		t.Errorf("eventName = %q, eventTime = %q", r.EventName, r.EventTime)
+	}
+	if r.S3.ConfigurationID != "uploads" || r.S3.Bucket.ARN != "arn:aws:s3:::bucket" {
+		t.Errorf("s3 = %+v", r.S3)
+	}
+	if r.S3.Object.Key != "photos%2Fmy+cat.jpg" || r.S3.Object.Size != 42 {
+		t.Errorf("object = %+v", r.S3.Object)
+	}
+	if r.UserIdentity.PrincipalID != "AKID" || r.RequestParameters.SourceIPAddress != "192.0.2.1" {
+		t.Errorf("userIdentity = %+v, requestParameters = %+v", r.UserIdentity, r.RequestParameters)
+	}
+	if r.JOG != nil {
+		t.Errorf("jog = %+v, want nil for the default tenant", r.JOG)
+	}
+}
+
+func TestNameTemplate(t *testing.T) {
+	tmpl, err := parseNameTemplate("subject", "jog.{{.Bucket}}", defaultNATSSubject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := executeNameTemplate(tmpl, events.Event{Bucket: "photos"})
+	if err != nil || name != "jog.photos" {
+		t.Errorf("subject = %q, %v, want jog.photos", name, err)
+	}
+
+	if _, err := executeNameTemplate(tmpl, events.Event{Bucket: "my bucket"}); err == nil {
+		t.Error("subject with a space was accepted")
+	}
+	if _, err := parseNameTemplate("subject", "{{.Bucket", ""); err == nil {
+		t.Error("invalid template was accepted")
+	}
+}
+
+func TestNewTargetValidation(t *testing.T) {
+	tests := []config.NotificationTargetConfig{
+		{Type: "sns"},
+		{Type: "nats"},
+		{Type: "nats", NATS: config.NATSConfig{URL: "http://localhost"}},
+		{Type: "kafka"},
+		{Type: "kafka", Kafka: config.KafkaConfig{Brokers: []string{"localhost:9092"}, SASL: config.SASLConfig{Mechanism: "gssapi"}}},
+	}
+	for _, cfg := range tests {
+		if _, err := NewTarget(cfg); err == nil {
+			t.Errorf("NewTarget(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+// recordingTarget records the events sent to it.
+type recordingTarget struct {
+	mu     sync.Mutex
+	events []events.Event
+	closed bool
+}
+
+func (r *recordingTarget) Send(ctx context.Context, event events.Event, payload []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingTarget) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestNotifierFilters(t *testing.T) {
+	broker := events.NewBroker()
+	target := &recordingTarget{}
+	n := &Notifier{}
+	sub := broker.Subscribe(events.Filter{AllTenants: true, Bucket: "bucket"})
+	n.subscriptions = append(n.subscriptions, sub)
+	n.targets = append(n.targets, target)
+	n.wg.Add(1)
+	go n.deliver("test", []string{"s3:ObjectCreated:*"}, sub, target)
+
+	broker.Publish(events.Event{Type: events.ObjectCreatedPut, Tenant: "acme", Bucket: "bucket", Key: "a"})
+	broker.Publish(events.Event{Type: events.ObjectRemovedDelete, Bucket: "bucket", Key: "a"})
+	broker.Publish(events.Event{Type: events.ObjectCreatedPut, Bucket: "other", Key: "b"})
+
+	if err := n.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !target.closed {
+		t.Error("target was not closed")
+	}
+	if len(target.events) != 1 || target.events[0].Tenant != "acme" {
+		t.Errorf("delivered %+v, want the created event of tenant acme", target.events)
+	}
+}
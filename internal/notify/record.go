@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kumasuke/jog/internal/events"
+)
+
+// region is reported as the region of all events.
+const region = "us-east-1"
+
+// eventMessage is an event notification in the S3 event message format, so
+// consumers written for S3 notifications can read JOG events.
+type eventMessage struct {
+	Records []eventRecord `json:"Records"`
+}
+
+type eventRecord struct {
+	EventVersion      string                 `json:"eventVersion"`
+	EventSource       string                 `json:"eventSource"`
+	AWSRegion         string                 `json:"awsRegion"`
+	EventTime         string                 `json:"eventTime"`
+	EventName         string                 `json:"eventName"`
+	UserIdentity      eventUserIdentity      `json:"userIdentity"`
+	RequestParameters eventRequestParameters `json:"requestParameters"`
+	S3                eventS3                `json:"s3"`
+	JOG               *eventJOG              `json:"jog,omitempty"`
+}
+
+type eventUserIdentity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+type eventRequestParameters struct {
+	SourceIPAddress string `json:"sourceIPAddress"`
+}
+
+type eventS3 struct {
+	SchemaVersion   string      `json:"s3SchemaVersion"`
+	ConfigurationID string      `json:"configurationId"`
+	Bucket          eventBucket `json:"bucket"`
+	Object          eventObject `json:"object"`
+}
+
+type eventBucket struct {
+	Name string `json:"name"`
+	ARN  string `json:"arn"`
+}
+
+type eventObject struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// eventJOG holds JOG specific event fields.
+type eventJOG struct {
+	Tenant string `json:"tenant"`
+}
+
+// marshalRecords encodes an event as an S3 event message.
+// configurationID names the notification target.
+func marshalRecords(configurationID string, event events.Event) ([]byte, error) {
+	record := eventRecord{
+		EventVersion: "2.1",
+		EventSource:  "aws:s3",
+		AWSRegion:    region,
+		EventTime:    event.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		EventName:    strings.TrimPrefix(string(event.Type), "s3:"),
+		UserIdentity: eventUserIdentity{
+			PrincipalID: event.Principal,
+		},
+		RequestParameters: eventRequestParameters{
+			SourceIPAddress: event.SourceIP,
+		},
+		S3: eventS3{
+			SchemaVersion:   "1.0",
+			ConfigurationID: configurationID,
+			Bucket: eventBucket{
+				Name: event.Bucket,
+				ARN:  "arn:aws:s3:::" + event.Bucket,
+			},
+			Object: eventObject{
+				// S3 URL-encodes keys in event messages
+				Key:       url.QueryEscape(event.Key),
+				Size:      event.Size,
+				ETag:      event.ETag,
+				VersionID: event.VersionID,
+				Sequencer: strings.ToUpper(strconv.FormatInt(event.Time.UnixNano(), 16)),
+			},
+		},
+	}
+	if event.Tenant != "" {
+		record.JOG = &eventJOG{Tenant: event.Tenant}
+	}
+	return json.Marshal(eventMessage{Records: []eventRecord{record}})
+}
@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+)
+
+// sqsAPIVersion is the version of the SQS query API.
+const sqsAPIVersion = "2012-11-05"
+
+// sqsTarget sends events to an SQS queue with the query API, which AWS SQS,
+// ElasticMQ and LocalStack all support. Message bodies are S3 event messages,
+// exactly as S3 delivers them to SQS.
+type sqsTarget struct {
+	queueURL    string
+	region      string
+	fifo        bool
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+// sqsSendMessageResponse is the response of SendMessage.
+type sqsSendMessageResponse struct {
+	MD5OfMessageBody string `xml:"SendMessageResult>MD5OfMessageBody"`
+	MessageID        string `xml:"SendMessageResult>MessageId"`
+}
+
+// sqsErrorResponse is the error response of the SQS query API.
+type sqsErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+func newSQSTarget(cfg config.SQSConfig, tlsConfig *tls.Config) (*sqsTarget, error) {
+	if cfg.QueueURL == "" {
+		return nil, errors.New("sqs queue_url is required")
+	}
+	u, err := url.Parse(cfg.QueueURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid sqs queue_url: %q", cfg.QueueURL)
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = sqsRegion(u.Hostname())
+	}
+
+	var provider aws.CredentialsProvider
+	if cfg.AccessKey != "" {
+		provider = credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion(region))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load aws credentials: %w", err)
+		}
+		provider = awsCfg.Credentials
+	}
+
+	client := &http.Client{Timeout: sendTimeout}
+	if tlsConfig != nil {
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		}
+	}
+
+	return &sqsTarget{
+		queueURL:    cfg.QueueURL,
+		region:      region,
+		fifo:        strings.HasSuffix(u.Path, ".fifo"),
+		credentials: aws.NewCredentialsCache(provider),
+		signer:      v4.NewSigner(),
+		client:      client,
+	}, nil
+}
+
+// sqsRegion returns the region of an AWS SQS endpoint, or us-east-1 for other hosts.
+func sqsRegion(host string) string {
+	labels := strings.Split(host, ".")
+	if strings.HasSuffix(host, ".amazonaws.com") && len(labels) >= 4 {
+		switch {
+		case labels[0] == "sqs":
+			// sqs.{region}.amazonaws.com
+			return labels[1]
+		case labels[1] == "queue":
+			// {region}.queue.amazonaws.com
+			return labels[0]
+		}
+	}
+	return "us-east-1"
+}
+
+// Send sends an event as a queue message.
+func (t *sqsTarget) Send(ctx context.Context, event events.Event, payload []byte) error {
+	form := url.Values{
+		"Action":      {"SendMessage"},
+		"Version":     {sqsAPIVersion},
+		"MessageBody": {string(payload)},
+	}
+	if t.fifo {
+		// Events of a bucket keep their order; the body contains a unique sequencer
+		sum := sha256.Sum256(payload)
+		form.Set("MessageGroupId", event.Bucket)
+		form.Set("MessageDeduplicationId", hex.EncodeToString(sum[:]))
+	}
+	body := form.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.queueURL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	creds, err := t.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("sqs: failed to retrieve credentials: %w", err)
+	}
+	bodyHash := sha256.Sum256([]byte(body))
+	if err := t.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(bodyHash[:]), "sqs", t.region, time.Now()); err != nil {
+		return fmt.Errorf("sqs: failed to sign request: %w", err)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sqs: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("sqs: failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp sqsErrorResponse
+		if xml.Unmarshal(respBody, &errResp) == nil && errResp.Code != "" {
+			return fmt.Errorf("sqs: %s: %s", errResp.Code, errResp.Message)
+		}
+		return fmt.Errorf("sqs: unexpected status %s", resp.Status)
+	}
+
+	var result sqsSendMessageResponse
+	if err := xml.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("sqs: invalid SendMessage response: %w", err)
+	}
+	sum := md5.Sum(payload)
+	if result.MD5OfMessageBody != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("sqs: MD5 of message %s does not match the sent body", result.MessageID)
+	}
+	return nil
+}
+
+// Close releases idle connections.
+func (t *sqsTarget) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+)
+
+func TestSQSRegion(t *testing.T) {
+	tests := map[string]string{
+		"sqs.eu-west-1.amazonaws.com":        "eu-west-1",
+		"ap-northeast-1.queue.amazonaws.com": "ap-northeast-1",
+		"localhost":                          "us-east-1",
+	}
+	for host, want := range tests {
+		if got := sqsRegion(host); got != want {
+			t.Errorf("sqsRegion(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestSQSTarget(t *testing.T) {
+	var form map[string]string
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		form = map[string]string{}
+		for name := range r.PostForm {
+			form[name] = r.PostForm.Get(name)
+		}
+
+		if strings.Contains(form["MessageBody"], "denied") {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>Access to the resource is denied.</Message></Error></ErrorResponse>`)
+			return
+		}
+		sum := md5.Sum([]byte(form["MessageBody"]))
+		fmt.Fprintf(w, `<SendMessageResponse><SendMessageResult><MD5OfMessageBody>%s</MD5OfMessageBody><MessageId>1</MessageId></SendMessageResult></SendMessageResponse>`, hex.EncodeToString(sum[:]))
+	}))
+	defer srv.Close()
+
+	target, err := newSQSTarget(config.SQSConfig{
+		QueueURL:  srv.URL + "/000000000000/events.fifo",
+		Region:    "eu-west-1",
+		AccessKey: "AKID",
+		SecretKey: "secret",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	event := events.Event{Type: events.ObjectCreatedPut, Bucket: "photos", Key: "cat.jpg"}
+	if err := target.Send(ctx, event, []byte(`{"Records":[]}`)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if form["Action"] != "SendMessage" || form["MessageBody"] != `{"Records":[]}` {
+		t.Errorf("form = %v", form)
+	}
+	if form["MessageGroupId"] != "photos" || form["MessageDeduplicationId"] == "" {
+		t.Errorf("FIFO parameters missing: %v", form)
+	}
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(authorization, "/eu-west-1/sqs/aws4_request") {
+		t.Errorf("Authorization = %q", authorization)
+	}
+
+	err = target.Send(ctx, event, []byte(`{"denied":true}`))
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Send error = %v, want AccessDenied", err)
+	}
+}
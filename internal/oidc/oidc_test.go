@@ -0,0 +1,207 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kumasuke/jog/internal/config"
+)
+
+// testProvider is an OpenID Connect provider serving its discovery document
+// and signing keys.
+type testProvider struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvider{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": p.URL, "jwks_uri": p.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		enc := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": enc(rsaKey.N.Bytes()), "e": enc(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": enc(ecKey.X.FillBytes(make([]byte, 32))), "y": enc(ecKey.Y.FillBytes(make([]byte, 32)))},
+			{"kty": "oct", "kid": "hmac", "k": enc([]byte("secret"))},
+		}})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// sign returns a token with claims signed with alg by the key kid.
+func (p *testProvider) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, p.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (p *testProvider) claims(overrides map[string]any) map[string]any {
+	claims := map[string]any{
+		"iss": p.URL,
+		"sub": "alice",
+		"aud": "jog",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+		} else {
+			claims[k] = v
+		}
+	}
+	return claims
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	tp := newTestProvider(t)
+	p, err := New(config.OIDCConfig{Issuer: tp.URL, ClientID: "jog"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	claims, err := p.Verify(ctx, tp.sign(t, "RS256", "rsa", tp.claims(map[string]any{"policy": []string{"read", "write"}})))
+	if err != nil {
+		t.Fatalf("Verify RS256: %v", err)
+	}
+	if claims.Subject != "alice" || !reflect.DeepEqual(claims.Audience, []string{"jog"}) {
+		t.Errorf("claims = %+v", claims)
+	}
+	if got := claims.Strings("policy"); !reflect.DeepEqual(got, []string{"read", "write"}) {
+		t.Errorf("Strings(policy) = %v, want [read write]", got)
+	}
+	if _, err := p.Verify(ctx, tp.sign(t, "ES256", "ec", tp.claims(map[string]any{"aud": []string{"other", "jog"}}))); err != nil {
+		t.Errorf("Verify ES256: %v", err)
+	}
+
+	tampered := tp.sign(t, "RS256", "rsa", tp.claims(nil))
+	parts := strings.Split(tampered, ".")
+	payload, _ := json.Marshal(tp.claims(map[string]any{"sub": "root"}))
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+
+	unsigned := strings.Split(tp.sign(t, "RS256", "rsa", tp.claims(nil)), ".")
+	none, _ := json.Marshal(map[string]string{"alg": "none", "kid": "rsa"})
+	unsigned[0] = base64.RawURLEncoding.EncodeToString(none)
+	unsigned[2] = ""
+
+	tests := map[string]struct {
+		token string
+		want  error
+	}{
+		"wrong audience":   {tp.sign(t, "RS256", "rsa", tp.claims(map[string]any{"aud": "other"})), ErrInvalidToken},
+		"wrong issuer":     {tp.sign(t, "RS256", "rsa", tp.claims(map[string]any{"iss": "https://evil.example.com"})), ErrInvalidToken},
+		"no subject":       {tp.sign(t, "RS256", "rsa", tp.claims(map[string]any{"sub": nil})), ErrInvalidToken},
+		"no expiry":        {tp.sign(t, "RS256", "rsa", tp.claims(map[string]any{"exp": nil})), ErrInvalidToken},
+		"not valid yet":    {tp.sign(t, "RS256", "rsa", tp.claims(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})), ErrInvalidToken},
+		"expired":          {tp.sign(t, "RS256", "rsa", tp.claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})), ErrExpiredToken},
+		"tampered":         {strings.Join(parts, "."), ErrInvalidToken},
+		"unsigned":         {strings.Join(unsigned, "."), ErrInvalidToken},
+		"key mismatch":     {tp.sign(t, "RS256", "ec", tp.claims(nil)), ErrInvalidToken},
+		"symmetric key":    {tp.sign(t, "HS256", "hmac", tp.claims(nil)), ErrInvalidToken},
+		"unknown key":      {tp.sign(t, "RS256", "rotated", tp.claims(nil)), ErrInvalidToken},
+		"not a JWT":        {"opaque-access-token", ErrInvalidToken},
+		"malformed header": {"e30.e30.", ErrInvalidToken},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.Verify(ctx, tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyProviderUnavailable(t *testing.T) {
+	tp := newTestProvider(t)
+	token := tp.sign(t, "RS256", "rsa", tp.claims(nil))
+	p, err := New(config.OIDCConfig{Issuer: tp.URL, ClientID: "jog", JWKSURL: tp.URL + "/missing"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, err = p.Verify(context.Background(), token)
+	if err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify = %v, want an error fetching the keys", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if p, err := New(config.OIDCConfig{}); p != nil || err != nil {
+		t.Errorf("New without issuer = %v, %v, want nil, nil", p, err)
+	}
+	for _, cfg := range []config.OIDCConfig{
+		{Issuer: "accounts.example.com", ClientID: "jog"},
+		{Issuer: "https://accounts.example.com"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestClaimsStrings(t *testing.T) {
+	claims := &Claims{raw: map[string]any{
+		"csv":    "read, write,,admin",
+		"list":   []any{"read", 1.0, "", "write"},
+		"number": 1.0,
+	}}
+	tests := map[string][]string{
+		"csv":     {"read", "write", "admin"},
+		"list":    {"read", "write"},
+		"number":  nil,
+		"missing": nil,
+	}
+	for name, want := range tests {
+		if got := claims.Strings(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("Strings(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AdminAction is the action of requests to the JOG admin endpoints.
+const AdminAction = "jog:Admin"
+
+// bucketSubresourceActions maps bucket subresources to the actions of GET,
+// PUT and DELETE requests for them, in the order the router checks them. JOG
+// extensions get actions named after their operations, such as
+// s3:GetBucketTiering.
+var bucketSubresourceActions = []struct {
+	subresource      string
+	get, put, delete string
+}{
+	{"uploads", "s3:ListBucketMultipartUploads", "", ""},
+	{"location", "s3:GetBucketLocation", "", ""},
+	{"tagging", "s3:GetBucketTagging", "s3:PutBucketTagging", "s3:PutBucketTagging"},
+	{"cors", "s3:GetBucketCORS", "s3:PutBucketCORS", "s3:PutBucketCORS"},
+	{"versioning", "s3:GetBucketVersioning", "s3:PutBucketVersioning", ""},
+	{"versions", "s3:ListBucketVersions", "", ""},
+	{"acl", "s3:GetBucketAcl", "s3:PutBucketAcl", ""},
+	{"encryption", "s3:GetEncryptionConfiguration", "s3:PutEncryptionConfiguration", "s3:PutEncryptionConfiguration"},
+	{"lifecycle", "s3:GetLifecycleConfiguration", "s3:PutLifecycleConfiguration", "s3:PutLifecycleConfiguration"},
+	{"object-lock", "s3:GetBucketObjectLockConfiguration", "s3:PutBucketObjectLockConfiguration", ""},
+	{"policy", "s3:GetBucketPolicy", "s3:PutBucketPolicy", "s3:DeleteBucketPolicy"},
+	{"website", "s3:GetBucketWebsite", "s3:PutBucketWebsite", "s3:DeleteBucketWebsite"},
+	{"tiering", "s3:GetBucketTiering", "s3:PutBucketTiering", "s3:DeleteBucketTiering"},
+	{"compression", "s3:GetBucketCompression", "s3:PutBucketCompression", "s3:DeleteBucketCompression"},
+	{"trash", "s3:GetBucketTrash", "s3:PutBucketTrash", "s3:DeleteBucketTrash"},
+	{"version-retention", "s3:GetBucketVersionRetention", "s3:PutBucketVersionRetention", "s3:DeleteBucketVersionRetention"},
+	{"content-types", "s3:GetBucketContentTypes", "s3:PutBucketContentTypes", "s3:DeleteBucketContentTypes"},
+	{"default-tags", "s3:GetBucketDefaultTags", "s3:PutBucketDefaultTags", "s3:DeleteBucketDefaultTags"},
+	{"response-headers", "s3:GetBucketResponseHeaders", "s3:PutBucketResponseHeaders", "s3:DeleteBucketResponseHeaders"},
+	{"skip-unchanged", "s3:GetBucketSkipUnchanged", "s3:PutBucketSkipUnchanged", ""},
+	{"worm", "s3:GetBucketWorm", "s3:PutBucketWorm", ""},
+	{"session", "s3express:CreateSession", "", ""},
+	{"search", "s3:ListBucket", "", ""},
+}
+
+// objectSubresourceActions maps object subresources to the actions of GET,
+// PUT and DELETE requests for them, in the order the router checks them.
+var objectSubresourceActions = []struct {
+	subresource      string
+	get, put, delete string
+}{
+	{"uploadId", "s3:ListMultipartUploadParts", "", "s3:AbortMultipartUpload"},
+	{"attributes", "s3:GetObjectAttributes", "", ""},
+	{"tagging", "s3:GetObjectTagging", "s3:PutObjectTagging", "s3:DeleteObjectTagging"},
+	{"acl", "s3:GetObjectAcl", "s3:PutObjectAcl", ""},
+	{"retention", "s3:GetObjectRetention", "s3:PutObjectRetention", ""},
+	{"legal-hold", "s3:GetObjectLegalHold", "s3:PutObjectLegalHold", ""},
+}
+
+// Action returns the IAM action of a request for a bucket and key, following
+// the S3 action names: s3:ListAllMyBuckets without a bucket, s3:ListBucket for
+// listing objects, s3:GetObject for reading an object, and so on. It returns
+// "" for DeleteObjects, whose keys are authorized one by one as
+// s3:DeleteObject, and for requests that are not S3 operations, such as CORS
+// preflight requests.
+func Action(r *http.Request, bucket, key string) string {
+	query := r.URL.Query()
+	versioned := query.Get("versionId") != ""
+
+	if bucket == "" {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return "s3:ListAllMyBuckets"
+		}
+		return ""
+	}
+
+	if key == "" {
+		switch r.Method {
+		case http.MethodPost:
+			return ""
+		case http.MethodHead:
+			return "s3:ListBucket"
+		}
+		// Subresources without an action for the method are routed as plain
+		// bucket requests, so they are skipped the same way.
+		for _, a := range bucketSubresourceActions {
+			if action := methodAction(r.Method, a.get, a.put, a.delete); action != "" && query.Has(a.subresource) {
+				return action
+			}
+		}
+		return methodAction(r.Method, "s3:ListBucket", "s3:CreateBucket", "s3:DeleteBucket")
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		// CreateMultipartUpload and CompleteMultipartUpload
+		return "s3:PutObject"
+	case http.MethodPut:
+		if query.Has("partNumber") && query.Has("uploadId") {
+			return "s3:PutObject"
+		}
+	case http.MethodHead:
+		if query.Has("partNumber") && query.Has("uploadId") {
+			// HeadPartUpload reports the parts of an upload, not an object
+			return "s3:HeadPartUpload"
+		}
+	}
+	for _, a := range objectSubresourceActions {
+		if action := methodAction(r.Method, a.get, a.put, a.delete); action != "" && query.Has(a.subresource) {
+			if versioned && a.subresource != "uploadId" {
+				action = versionAction(action)
+			}
+			return action
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if versioned {
+			return "s3:GetObjectVersion"
+		}
+		return "s3:GetObject"
+	case http.MethodPut:
+		return "s3:PutObject"
+	case http.MethodDelete:
+		if versioned {
+			return "s3:DeleteObjectVersion"
+		}
+		return "s3:DeleteObject"
+	}
+	return ""
+}
+
+// methodAction returns the action of a GET, PUT or DELETE request.
+func methodAction(method, get, put, del string) string {
+	switch method {
+	case http.MethodGet:
+		return get
+	case http.MethodPut:
+		return put
+	case http.MethodDelete:
+		return del
+	}
+	return ""
+}
+
+// versionAction returns the action on a specific version of an object, such
+// as s3:GetObjectVersionTagging for s3:GetObjectTagging. As in S3, retention
+// and legal holds have no version actions.
+func versionAction(action string) string {
+	for _, verb := range []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject"} {
+		if rest, ok := strings.CutPrefix(action, verb); ok && rest != "Retention" && rest != "LegalHold" {
+			return verb + "Version" + rest
+		}
+	}
+	return action
+}
@@ -0,0 +1,251 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// maxParsedPolicies bounds the cache of parsed bucket policies. The cache is
+// cleared when it is full.
+const maxParsedPolicies = 1024
+
+// Request is an action a caller requests on a bucket or object.
+type Request struct {
+	// Principal is the access key of the caller.
+	Principal string
+	Action    string
+	// Bucket and Key are empty for actions on no bucket, such as
+	// s3:ListAllMyBuckets.
+	Bucket string
+	Key    string
+	// Conditions are the values of the condition keys of the request, by
+	// lower case key.
+	Conditions map[string][]string
+}
+
+// NewRequest returns the request for an action of the caller of an HTTP
+// request. Its condition keys are aws:SourceIp, aws:SecureTransport,
+// aws:username, aws:userid, aws:CurrentTime and aws:EpochTime, s3:prefix,
+// s3:delimiter, s3:max-keys and s3:VersionId from the query, and s3:<header>
+// for the x-amz-* headers.
+func NewRequest(r *http.Request, principal, action, bucket, key string) *Request {
+	now := time.Now().UTC()
+	conditions := map[string][]string{
+		"aws:securetransport": {strconv.FormatBool(r.TLS != nil)},
+		"aws:username":        {principal},
+		"aws:userid":          {principal},
+		"aws:currenttime":     {now.Format(time.RFC3339)},
+		"aws:epochtime":       {strconv.FormatInt(now.Unix(), 10)},
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		conditions["aws:sourceip"] = []string{host}
+	}
+	query := r.URL.Query()
+	for _, param := range []string{"prefix", "delimiter", "max-keys"} {
+		if query.Has(param) {
+			conditions["s3:"+param] = []string{query.Get(param)}
+		}
+	}
+	if versionID := query.Get("versionId"); versionID != "" {
+		conditions["s3:versionid"] = []string{versionID}
+	}
+	for name, values := range r.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			conditions["s3:"+name] = values
+		}
+	}
+	return &Request{Principal: principal, Action: action, Bucket: bucket, Key: key, Conditions: conditions}
+}
+
+// Resource returns the ARN of the bucket or object of the request, or "*"
+// for actions on no bucket.
+func (r *Request) Resource() string {
+	switch {
+	case r.Bucket == "":
+		return "*"
+	case r.Key == "":
+		return "arn:aws:s3:::" + r.Bucket
+	default:
+		return "arn:aws:s3:::" + r.Bucket + "/" + r.Key
+	}
+}
+
+// conditionValues returns the values of a condition key and whether the
+// request has the key.
+func (r *Request) conditionValues(key string) ([]string, bool) {
+	values, ok := r.Conditions[strings.ToLower(key)]
+	return values, ok
+}
+
+// Authorizer decides requests with the identity policies attached to access
+// keys and the policies of buckets, as IAM does within an account:
+//
+//   - A request not allowed by its session policy is denied.
+//   - A request explicitly denied by an identity or bucket policy is denied.
+//   - A request of an access key with identity policies must be allowed by one
+//     of them or by the bucket policy.
+//   - Access keys without identity policies have full access, as before
+//     identity policies existed, unless a bucket policy denies the request.
+//     jog:Admin is the exception: only the administrator access key has it
+//     without an identity policy allowing it.
+type Authorizer struct {
+	store storage.Storage
+
+	mu         sync.RWMutex
+	identities map[string][]*Document
+	admin      string
+
+	parsedMu sync.Mutex
+	// parsed caches parsed bucket policies by their text; nil documents are
+	// policies that fail to parse.
+	parsed map[string]*Document
+}
+
+// NewAuthorizer creates an authorizer reading bucket policies from a store.
+func NewAuthorizer(store storage.Storage) *Authorizer {
+	return &Authorizer{
+		store:      store,
+		identities: make(map[string][]*Document),
+		parsed:     make(map[string]*Document),
+	}
+}
+
+// Attach replaces the identity policies of an access key. Without documents,
+// the access key has full access again.
+func (a *Authorizer) Attach(accessKey string, docs ...*Document) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(docs) == 0 {
+		delete(a.identities, accessKey)
+		return
+	}
+	a.identities[accessKey] = docs
+}
+
+// SetAdmin sets the administrator access key, which is allowed jog:Admin
+// without identity policies.
+func (a *Authorizer) SetAdmin(accessKey string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.admin = accessKey
+}
+
+// Restricted reports whether an access key has identity policies.
+func (a *Authorizer) Restricted(accessKey string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.identities[accessKey]) > 0
+}
+
+// sessionPolicyKey is the context key of the session policy of a request.
+type sessionPolicyKey struct{}
+
+// WithSessionPolicy returns a copy of ctx for requests limited to what a
+// policy allows, such as requests of service accounts. Like a session policy
+// in IAM, it cannot grant more than the identity and bucket policies.
+func WithSessionPolicy(ctx context.Context, doc *Document) context.Context {
+	return context.WithValue(ctx, sessionPolicyKey{}, doc)
+}
+
+// HasSessionPolicy reports whether the requests of ctx are limited by a
+// session policy.
+func HasSessionPolicy(ctx context.Context) bool {
+	_, ok := ctx.Value(sessionPolicyKey{}).(*Document)
+	return ok
+}
+
+// Authorize reports whether a request is allowed. The context selects the
+// tenant of the bucket policy and carries the session policy, if any.
+func (a *Authorizer) Authorize(ctx context.Context, req *Request) (bool, error) {
+	if session, ok := ctx.Value(sessionPolicyKey{}).(*Document); ok && session.Evaluate(req, false) != Allow {
+		return false, nil
+	}
+
+	a.mu.RLock()
+	identities := a.identities[req.Principal]
+	admin := a.admin
+	a.mu.RUnlock()
+
+	decision := NotApplicable
+	for _, doc := range identities {
+		switch doc.Evaluate(req, false) {
+		case Deny:
+			return false, nil
+		case Allow:
+			decision = Allow
+		}
+	}
+
+	if req.Bucket != "" {
+		doc, err := a.bucketPolicy(ctx, req.Bucket)
+		if err != nil {
+			return false, err
+		}
+		if doc != nil {
+			switch doc.Evaluate(req, true) {
+			case Deny:
+				return false, nil
+			case Allow:
+				decision = Allow
+			}
+		}
+	}
+
+	if req.Action == AdminAction && len(identities) == 0 {
+		return req.Principal == admin, nil
+	}
+	return len(identities) == 0 || decision == Allow, nil
+}
+
+// bucketPolicy returns the parsed policy of a bucket, or nil if the bucket
+// or its policy does not exist. Policies stored before they were validated
+// that fail to parse are ignored.
+func (a *Authorizer) bucketPolicy(ctx context.Context, bucket string) (*Document, error) {
+	text, err := a.store.GetBucketPolicy(ctx, bucket)
+	if errors.Is(err, storage.ErrNoSuchBucketPolicy) || errors.Is(err, storage.ErrBucketNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.parsedMu.Lock()
+	defer a.parsedMu.Unlock()
+	if doc, ok := a.parsed[text]; ok {
+		return doc, nil
+	}
+	doc, err := Parse([]byte(text))
+	if err != nil {
+		log.Warn().Err(err).Str("bucket", bucket).Msg("Ignoring invalid bucket policy")
+		doc = nil
+	}
+	if len(a.parsed) >= maxParsedPolicies {
+		clear(a.parsed)
+	}
+	a.parsed[text] = doc
+	return doc, nil
+}
+
+// ScopeDocument returns a policy allowing actions on the objects of a bucket
+// under a key prefix. With a prefix, bucket actions such as s3:ListBucket are
+// only allowed for requests whose s3:prefix is under it.
+func ScopeDocument(bucket, prefix string, actions []string) *Document {
+	bucketARN := "arn:aws:s3:::" + bucket
+	objects := Statement{Effect: "Allow", Action: actions, Resource: stringList{bucketARN + "/" + prefix + "*"}}
+	buckets := Statement{Effect: "Allow", Action: actions, Resource: stringList{bucketARN}}
+	if prefix != "" {
+		buckets.Condition = map[string]map[string]stringList{
+			"StringLike": {"s3:prefix": {prefix + "*"}},
+		}
+	}
+	return &Document{Version: "2012-10-17", Statement: []Statement{objects, buckets}}
+}
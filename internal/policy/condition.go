@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// conditionOperator compares a value of a request with a value of a policy.
+type conditionOperator struct {
+	match func(actual, value string) bool
+	// negated operators match when no value of the request matches.
+	negated bool
+}
+
+// conditionOperators are the supported condition operators, without the
+// IfExists suffix, which any operator but Null accepts.
+var conditionOperators = map[string]conditionOperator{
+	"StringEquals":              {match: stringEquals},
+	"StringNotEquals":           {match: stringEquals, negated: true},
+	"StringEqualsIgnoreCase":    {match: strings.EqualFold},
+	"StringNotEqualsIgnoreCase": {match: strings.EqualFold, negated: true},
+	"StringLike":                {match: stringLike},
+	"StringNotLike":             {match: stringLike, negated: true},
+	"NumericEquals":             {match: numeric(func(a, b float64) bool { return a == b })},
+	"NumericNotEquals":          {match: numeric(func(a, b float64) bool { return a == b }), negated: true},
+	"NumericLessThan":           {match: numeric(func(a, b float64) bool { return a < b })},
+	"NumericLessThanEquals":     {match: numeric(func(a, b float64) bool { return a <= b })},
+	"NumericGreaterThan":        {match: numeric(func(a, b float64) bool { return a > b })},
+	"NumericGreaterThanEquals":  {match: numeric(func(a, b float64) bool { return a >= b })},
+	"DateEquals":                {match: date(func(a, b time.Time) bool { return a.Equal(b) })},
+	"DateNotEquals":             {match: date(func(a, b time.Time) bool { return a.Equal(b) }), negated: true},
+	"DateLessThan":              {match: date(func(a, b time.Time) bool { return a.Before(b) })},
+	"DateLessThanEquals":        {match: date(func(a, b time.Time) bool { return !a.After(b) })},
+	"DateGreaterThan":           {match: date(func(a, b time.Time) bool { return a.After(b) })},
+	"DateGreaterThanEquals":     {match: date(func(a, b time.Time) bool { return !a.Before(b) })},
+	"Bool":                      {match: strings.EqualFold},
+	"IpAddress":                 {match: ipAddress},
+	"NotIpAddress":              {match: ipAddress, negated: true},
+	"Null":                      {},
+}
+
+// parseOperator returns a condition operator and whether it has the IfExists
+// suffix.
+func parseOperator(name string) (op conditionOperator, ifExists bool, ok bool) {
+	base, ifExists := strings.CutSuffix(name, "IfExists")
+	op, ok = conditionOperators[base]
+	if !ok || (base == "Null" && ifExists) {
+		return conditionOperator{}, false, false
+	}
+	return op, ifExists, true
+}
+
+// conditionsMatch reports whether a request meets all conditions of a
+// statement. As in IAM, a condition on a key the request does not have only
+// matches with IfExists operators and negated operators.
+func conditionsMatch(conditions map[string]map[string]stringList, req *Request) bool {
+	for name, keys := range conditions {
+		op, ifExists, _ := parseOperator(name)
+		for key, values := range keys {
+			actual, present := req.conditionValues(key)
+			if op.match == nil {
+				// Null: "true" requires the key to be missing
+				missing := len(values) > 0 && strings.EqualFold(values[0], "true")
+				if missing == present {
+					return false
+				}
+				continue
+			}
+			if !present {
+				if ifExists || op.negated {
+					continue
+				}
+				return false
+			}
+			if matchesAny(op.match, actual, values) == op.negated {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesAny reports whether a value of the request matches a value of the
+// policy.
+func matchesAny(match func(actual, value string) bool, actual, values []string) bool {
+	for _, a := range actual {
+		for _, v := range values {
+			if match(a, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func stringEquals(actual, value string) bool {
+	return actual == value
+}
+
+func stringLike(actual, value string) bool {
+	return wildcardMatch(value, actual)
+}
+
+// numeric returns an operator comparing numbers. Values that are not numbers
+// never match.
+func numeric(compare func(a, b float64) bool) func(actual, value string) bool {
+	return func(actual, value string) bool {
+		a, err := strconv.ParseFloat(actual, 64)
+		if err != nil {
+			return false
+		}
+		b, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		return compare(a, b)
+	}
+}
+
+// date returns an operator comparing times, given in RFC 3339 format or as
+// seconds since the epoch. Values that are not times never match.
+func date(compare func(a, b time.Time) bool) func(actual, value string) bool {
+	return func(actual, value string) bool {
+		a, ok := parseTime(actual)
+		if !ok {
+			return false
+		}
+		b, ok := parseTime(value)
+		if !ok {
+			return false
+		}
+		return compare(a, b)
+	}
+}
+
+func parseTime(s string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true
+	}
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+	return time.Time{}, false
+}
+
+// ipAddress matches an address against an address or CIDR prefix.
+func ipAddress(actual, value string) bool {
+	addr, err := netip.ParseAddr(actual)
+	if err != nil {
+		return false
+	}
+	if prefix, err := netip.ParsePrefix(value); err == nil {
+		return prefix.Contains(addr.Unmap())
+	}
+	if other, err := netip.ParseAddr(value); err == nil {
+		return other.Unmap() == addr.Unmap()
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+// Package policy evaluates IAM-style policy documents: identity policies
+// attached to access keys and bucket policies.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Decision is the result of evaluating a policy for a request.
+type Decision int
+
+const (
+	// NotApplicable means no statement applies to the request.
+	NotApplicable Decision = iota
+	// Allow means a statement allows the request and none denies it.
+	Allow
+	// Deny means a statement explicitly denies the request.
+	Deny
+)
+
+// Document is a policy document.
+type Document struct {
+	Version   string      `json:"Version,omitempty"`
+	ID        string      `json:"Id,omitempty"`
+	Statement []Statement `json:"Statement"`
+}
+
+// Statement is a statement of a policy document. Principal is only used by
+// bucket policies; identity policies apply to the keys they are attached to.
+type Statement struct {
+	Sid          string     `json:"Sid,omitempty"`
+	Effect       string     `json:"Effect"`
+	Principal    *Principal `json:"Principal,omitempty"`
+	NotPrincipal *Principal `json:"NotPrincipal,omitempty"`
+	Action       stringList `json:"Action,omitempty"`
+	NotAction    stringList `json:"NotAction,omitempty"`
+	Resource     stringList `json:"Resource,omitempty"`
+	NotResource  stringList `json:"NotResource,omitempty"`
+	// Condition maps condition operators to condition keys and their values.
+	Condition map[string]map[string]stringList `json:"Condition,omitempty"`
+}
+
+// Principal is "*" or the AWS principals of a statement.
+type Principal struct {
+	AWS stringList
+}
+
+// UnmarshalJSON accepts "*" and {"AWS": ...}. Other principal types, such as
+// services, are accepted but never match access keys.
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if s != "*" {
+			return fmt.Errorf("invalid principal %q", s)
+		}
+		p.AWS = stringList{"*"}
+		return nil
+	}
+	var m map[string]stringList
+	if err := json.Unmarshal(data, &m); err != nil {
+		return errors.New("principal must be \"*\" or an object")
+	}
+	p.AWS = m["AWS"]
+	return nil
+}
+
+// stringList is a policy element that is a string or an array of strings.
+type stringList []string
+
+// UnmarshalJSON accepts a string or an array of strings.
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = stringList{s}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return errors.New("expected a string or an array of strings")
+	}
+	*l = values
+	return nil
+}
+
+// Parse parses and validates a policy document. Unknown elements are
+// rejected, so that misspelled elements do not silently change what a policy
+// allows.
+func Parse(data []byte) (*Document, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var doc Document
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	if err := doc.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	return &doc, nil
+}
+
+// Merge returns a document with the statements of several documents, which
+// allows what any of them allows unless one of them denies it.
+func Merge(docs ...*Document) *Document {
+	merged := &Document{Version: "2012-10-17"}
+	for _, doc := range docs {
+		merged.Statement = append(merged.Statement, doc.Statement...)
+	}
+	return merged
+}
+
+// validate checks the statements of a document.
+func (d *Document) validate() error {
+	if len(d.Statement) == 0 {
+		return errors.New("no statements")
+	}
+	for i, s := range d.Statement {
+		if s.Effect != "Allow" && s.Effect != "Deny" {
+			return fmt.Errorf("statement %d: effect must be Allow or Deny, got %q", i, s.Effect)
+		}
+		if (len(s.Action) == 0) == (len(s.NotAction) == 0) {
+			return fmt.Errorf("statement %d: exactly one of Action and NotAction is required", i)
+		}
+		if len(s.Resource) > 0 && len(s.NotResource) > 0 {
+			return fmt.Errorf("statement %d: Resource and NotResource are exclusive", i)
+		}
+		if s.Principal != nil && s.NotPrincipal != nil {
+			return fmt.Errorf("statement %d: Principal and NotPrincipal are exclusive", i)
+		}
+		for operator, conditions := range s.Condition {
+			if _, _, ok := parseOperator(operator); !ok {
+				return fmt.Errorf("statement %d: unsupported condition operator %q", i, operator)
+			}
+			if len(conditions) == 0 {
+				return fmt.Errorf("statement %d: condition operator %s has no keys", i, operator)
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate evaluates the document for a request. With checkPrincipal, the
+// principals of statements must match the access key of the request, as in
+// bucket policies.
+func (d *Document) Evaluate(req *Request, checkPrincipal bool) Decision {
+	decision := NotApplicable
+	for i := range d.Statement {
+		s := &d.Statement[i]
+		if !s.applies(req, checkPrincipal) {
+			continue
+		}
+		if s.Effect == "Deny" {
+			return Deny
+		}
+		decision = Allow
+	}
+	return decision
+}
+
+// applies reports whether a statement applies to a request.
+func (s *Statement) applies(req *Request, checkPrincipal bool) bool {
+	if checkPrincipal {
+		switch {
+		case s.Principal != nil:
+			if !s.Principal.matches(req.Principal) {
+				return false
+			}
+		case s.NotPrincipal != nil:
+			if s.NotPrincipal.matches(req.Principal) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	if len(s.Action) > 0 && !matchAny(s.Action, req.Action, true) {
+		return false
+	}
+	if len(s.NotAction) > 0 && matchAny(s.NotAction, req.Action, true) {
+		return false
+	}
+	resource := req.Resource()
+	if len(s.Resource) > 0 && !matchAny(s.Resource, resource, false) {
+		return false
+	}
+	if len(s.NotResource) > 0 && matchAny(s.NotResource, resource, false) {
+		return false
+	}
+	return conditionsMatch(s.Condition, req)
+}
+
+// matches reports whether an access key is one of the principals: "*", the
+// access key itself, or a user ARN ending in "/<access key>".
+func (p *Principal) matches(accessKey string) bool {
+	for _, principal := range p.AWS {
+		if principal == "*" || principal == accessKey || strings.HasSuffix(principal, ":user/"+accessKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny reports whether a value matches one of the patterns, which may
+// contain the wildcards * and ?. Actions are matched case-insensitively.
+func matchAny(patterns []string, value string, ignoreCase bool) bool {
+	if ignoreCase {
+		value = strings.ToLower(value)
+	}
+	for _, pattern := range patterns {
+		if ignoreCase {
+			pattern = strings.ToLower(pattern)
+		}
+		if wildcardMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch matches a value against a pattern in which * matches any
+// sequence of characters and ? any single character.
+func wildcardMatch(pattern, value string) bool {
+	// Iterative matching with backtracking to the last *
+	p, v := 0, 0
+	star, match := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, match = p, v
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case star >= 0:
+			p = star + 1
+			match++
+			v = match
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
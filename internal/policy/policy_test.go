@@ -0,0 +1,246 @@
+package policy
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func mustParse(t *testing.T, document string) *Document {
+	t.Helper()
+	doc, err := Parse([]byte(document))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return doc
+}
+
+func TestParseRejectsInvalidDocuments(t *testing.T) {
+	for _, document := range []string{
+		`not json`,
+		`{"Version": "2012-10-17"}`,
+		`{"Statement": [{"Effect": "Maybe", "Action": "s3:*", "Resource": "*"}]}`,
+		`{"Statement": [{"Effect": "Allow", "Resource": "*"}]}`,
+		`{"Statement": [{"Effect": "Allow", "Action": "s3:*", "NotAction": "s3:GetObject"}]}`,
+		`{"Statement": [{"Effect": "Allow", "Actions": "s3:*"}]}`,
+		`{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Principal": "someone"}]}`,
+		`{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Condition": {"StringSortOf": {"s3:prefix": "a"}}}]}`,
+		`{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Condition": {"NullIfExists": {"s3:prefix": "true"}}}]}`,
+	} {
+		if _, err := Parse([]byte(document)); err == nil {
+			t.Errorf("Parse(%s) succeeded, want an error", document)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	doc := mustParse(t, `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Effect": "Allow", "Action": ["s3:GetObject", "s3:PutObject"], "Resource": "arn:aws:s3:::backups/db/*"},
+			{"Effect": "Allow", "Action": "s3:ListBucket", "Resource": "arn:aws:s3:::backups",
+				"Condition": {"StringLike": {"s3:prefix": "db/*"}}},
+			{"Effect": "Deny", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::backups/db/locked/*"}
+		]
+	}`)
+
+	tests := []struct {
+		action, bucket, key string
+		conditions          map[string][]string
+		want                Decision
+	}{
+		{"s3:GetObject", "backups", "db/dump.sql", nil, Allow},
+		{"S3:getobject", "backups", "db/dump.sql", nil, Allow},
+		{"s3:PutObject", "backups", "db/2024/dump.sql", nil, Allow},
+		{"s3:PutObject", "backups", "db/locked/dump.sql", nil, Deny},
+		{"s3:GetObject", "backups", "web/index.html", nil, NotApplicable},
+		{"s3:DeleteObject", "backups", "db/dump.sql", nil, NotApplicable},
+		{"s3:GetObject", "other", "db/dump.sql", nil, NotApplicable},
+		{"s3:ListBucket", "backups", "", map[string][]string{"s3:prefix": {"db/2024/"}}, Allow},
+		{"s3:ListBucket", "backups", "", map[string][]string{"s3:prefix": {"web/"}}, NotApplicable},
+		{"s3:ListBucket", "backups", "", nil, NotApplicable},
+	}
+	for _, tt := range tests {
+		req := &Request{Principal: "writer", Action: tt.action, Bucket: tt.bucket, Key: tt.key, Conditions: tt.conditions}
+		if got := doc.Evaluate(req, false); got != tt.want {
+			t.Errorf("Evaluate(%s %s) = %v, want %v", tt.action, req.Resource(), got, tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	reader := mustParse(t, `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`)
+	writer := mustParse(t, `{"Statement": [
+		{"Effect": "Allow", "Action": "s3:PutObject", "Resource": "*"},
+		{"Effect": "Deny", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::secrets/*"}
+	]}`)
+	merged := Merge(reader, writer)
+
+	tests := []struct {
+		action, bucket string
+		want           Decision
+	}{
+		{"s3:GetObject", "backups", Allow},
+		{"s3:PutObject", "backups", Allow},
+		{"s3:GetObject", "secrets", Deny},
+		{"s3:DeleteObject", "backups", NotApplicable},
+	}
+	for _, tt := range tests {
+		req := &Request{Principal: "user", Action: tt.action, Bucket: tt.bucket, Key: "key"}
+		if got := merged.Evaluate(req, false); got != tt.want {
+			t.Errorf("Evaluate(%s %s) = %v, want %v", tt.action, req.Resource(), got, tt.want)
+		}
+	}
+}
+
+func TestEvaluatePrincipals(t *testing.T) {
+	doc := mustParse(t, `{
+		"Statement": [
+			{"Effect": "Allow", "Principal": {"AWS": ["reader", "arn:aws:iam::123456789012:user/auditor"]},
+				"Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"},
+			{"Effect": "Deny", "NotPrincipal": {"AWS": "admin"}, "Action": "s3:DeleteObject", "Resource": "arn:aws:s3:::bucket/*"}
+		]
+	}`)
+
+	tests := []struct {
+		principal, action string
+		want              Decision
+	}{
+		{"reader", "s3:GetObject", Allow},
+		{"auditor", "s3:GetObject", Allow},
+		{"writer", "s3:GetObject", NotApplicable},
+		{"writer", "s3:DeleteObject", Deny},
+		{"admin", "s3:DeleteObject", NotApplicable},
+	}
+	for _, tt := range tests {
+		req := &Request{Principal: tt.principal, Action: tt.action, Bucket: "bucket", Key: "key"}
+		if got := doc.Evaluate(req, true); got != tt.want {
+			t.Errorf("Evaluate(%s, %s) = %v, want %v", tt.principal, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		condition  string
+		conditions map[string][]string
+		want       bool
+	}{
+		{`{"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}`, map[string][]string{"aws:sourceip": {"10.1.2.3"}}, true},
+		{`{"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}`, map[string][]string{"aws:sourceip": {"192.168.0.1"}}, false},
+		{`{"NotIpAddress": {"aws:SourceIp": ["10.0.0.0/8", "::1"]}}`, map[string][]string{"aws:sourceip": {"::1"}}, false},
+		{`{"Bool": {"aws:SecureTransport": "true"}}`, map[string][]string{"aws:securetransport": {"false"}}, false},
+		{`{"NumericLessThanEquals": {"s3:max-keys": "100"}}`, map[string][]string{"s3:max-keys": {"50"}}, true},
+		{`{"NumericLessThanEquals": {"s3:max-keys": "100"}}`, nil, false},
+		{`{"NumericLessThanEqualsIfExists": {"s3:max-keys": "100"}}`, nil, true},
+		{`{"StringNotEquals": {"s3:x-amz-acl": "public-read"}}`, nil, true},
+		{`{"DateLessThan": {"aws:CurrentTime": "2030-01-01T00:00:00Z"}}`, map[string][]string{"aws:currenttime": {"2029-12-31T23:59:59Z"}}, true},
+		{`{"DateGreaterThan": {"aws:EpochTime": "2030-01-01"}}`, map[string][]string{"aws:epochtime": {"1700000000"}}, false},
+		{`{"Null": {"s3:x-amz-server-side-encryption": "true"}}`, nil, true},
+		{`{"Null": {"s3:x-amz-server-side-encryption": "false"}}`, nil, false},
+		{`{"StringEqualsIgnoreCase": {"aws:username": "WRITER"}}`, map[string][]string{"aws:username": {"writer"}}, true},
+	}
+	for _, tt := range tests {
+		doc := mustParse(t, `{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*", "Condition": `+tt.condition+`}]}`)
+		req := &Request{Action: "s3:GetObject", Bucket: "bucket", Key: "key", Conditions: tt.conditions}
+		if got := doc.Evaluate(req, false) == Allow; got != tt.want {
+			t.Errorf("condition %s with %v = %v, want %v", tt.condition, tt.conditions, got, tt.want)
+		}
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket/a/b", true},
+		{"arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket", false},
+		{"arn:aws:s3:::bucket*", "arn:aws:s3:::bucket-2/key", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"*.log", "logs/app.log", true},
+		{"*.log", "logs/app.log.gz", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXcYYb", false},
+	}
+	for _, tt := range tests {
+		if got := wildcardMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("wildcardMatch(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAction(t *testing.T) {
+	tests := []struct {
+		method, target string
+		want           string
+	}{
+		{"GET", "/", "s3:ListAllMyBuckets"},
+		{"GET", "/bucket?list-type=2", "s3:ListBucket"},
+		{"HEAD", "/bucket", "s3:ListBucket"},
+		{"PUT", "/bucket", "s3:CreateBucket"},
+		{"DELETE", "/bucket", "s3:DeleteBucket"},
+		{"GET", "/bucket?uploads", "s3:ListBucketMultipartUploads"},
+		{"PUT", "/bucket?policy", "s3:PutBucketPolicy"},
+		{"DELETE", "/bucket?cors", "s3:PutBucketCORS"},
+		// Routed as PutBucketPolicy, as uploads has no PUT operation
+		{"PUT", "/bucket?uploads&policy", "s3:PutBucketPolicy"},
+		// Routed as DeleteBucket, as versioning has no DELETE operation
+		{"DELETE", "/bucket?versioning", "s3:DeleteBucket"},
+		{"GET", "/bucket?tiering", "s3:GetBucketTiering"},
+		{"GET", "/bucket?session", "s3express:CreateSession"},
+		{"POST", "/bucket?delete", ""},
+		{"GET", "/bucket/key", "s3:GetObject"},
+		{"HEAD", "/bucket/key?versionId=1", "s3:GetObjectVersion"},
+		{"PUT", "/bucket/key", "s3:PutObject"},
+		{"PUT", "/bucket/key?partNumber=1&uploadId=u", "s3:PutObject"},
+		{"POST", "/bucket/key?uploads", "s3:PutObject"},
+		{"GET", "/bucket/key?uploadId=u", "s3:ListMultipartUploadParts"},
+		{"HEAD", "/bucket/key?partNumber=1&uploadId=u", "s3:HeadPartUpload"},
+		{"HEAD", "/bucket/key?partNumber=1", "s3:GetObject"},
+		{"DELETE", "/bucket/key?uploadId=u", "s3:AbortMultipartUpload"},
+		{"DELETE", "/bucket/key", "s3:DeleteObject"},
+		{"DELETE", "/bucket/key?versionId=1", "s3:DeleteObjectVersion"},
+		{"GET", "/bucket/key?tagging&versionId=1", "s3:GetObjectVersionTagging"},
+		{"PUT", "/bucket/key?retention&versionId=1", "s3:PutObjectRetention"},
+		{"GET", "/bucket/key?attributes", "s3:GetObjectAttributes"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		bucket, key := "", ""
+		if tt.target != "/" {
+			bucket, key = "bucket", ""
+			if r.URL.Path != "/bucket" {
+				key = "key"
+			}
+		}
+		if got := Action(r, bucket, key); got != tt.want {
+			t.Errorf("Action(%s %s) = %q, want %q", tt.method, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/bucket?prefix=logs/&max-keys=10", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Amz-Server-Side-Encryption", "AES256")
+	req := NewRequest(r, "writer", "s3:ListBucket", "bucket", "")
+
+	for key, want := range map[string]string{
+		"aws:SourceIp":                    "192.0.2.1",
+		"aws:SecureTransport":             "false",
+		"aws:username":                    "writer",
+		"s3:prefix":                       "logs/",
+		"s3:max-keys":                     "10",
+		"s3:x-amz-server-side-encryption": "AES256",
+	} {
+		if values, ok := req.conditionValues(key); !ok || len(values) != 1 || values[0] != want {
+			t.Errorf("condition %s = %v, want %s", key, values, want)
+		}
+	}
+	if _, ok := req.conditionValues("s3:delimiter"); ok {
+		t.Error("condition s3:delimiter is set without a delimiter")
+	}
+}
@@ -0,0 +1,366 @@
+// Package replay proxies S3 requests to a real S3 endpoint while recording the
+// exchanges, and answers requests offline with the recorded responses, so the
+// test suites of applications with complex S3 call patterns can run
+// hermetically.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ModeRecord proxies requests to the upstream endpoint and records them.
+	ModeRecord = "record"
+	// ModeReplay answers requests with recorded responses.
+	ModeReplay = "replay"
+
+	// upstreamTimeout bounds a request to the upstream endpoint.
+	upstreamTimeout = 5 * time.Minute
+)
+
+var (
+	exchangesRecorded = metrics.NewCounter("jog_replay_recorded_total",
+		"Exchanges recorded from the upstream S3 endpoint.")
+	exchangesReplayed = metrics.NewCounter("jog_replay_replayed_total",
+		"Requests answered with recorded responses.")
+	exchangesUnmatched = metrics.NewCounter("jog_replay_unmatched_total",
+		"Requests without a recorded response.")
+)
+
+// skippedRequestHeaders are not forwarded upstream: the request is signed
+// again for the upstream endpoint, and the body is sent decoded.
+var skippedRequestHeaders = []string{
+	"Authorization", "Connection", "Content-Encoding", "Content-Length", "Expect",
+	"Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	"X-Amz-Content-Sha256", "X-Amz-Date", "X-Amz-Decoded-Content-Length", "X-Amz-Security-Token",
+}
+
+// skippedResponseHeaders are not recorded, as the server sets them for every
+// response.
+var skippedResponseHeaders = []string{
+	"Connection", "Date", "Keep-Alive", "Server", "Transfer-Encoding",
+	"X-Amz-Id-2", "X-Amz-Request-Id",
+}
+
+// Exchange is a recorded request and its response.
+type Exchange struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	// Query is the encoded query without presigning parameters.
+	Query  string      `json:"query,omitempty"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// key returns the key recorded exchanges are matched by.
+func (e *Exchange) key() string {
+	return e.Method + " " + e.Path + "?" + e.Query
+}
+
+// requestQuery returns the encoded query of a request without the parameters
+// of presigned URLs, which differ between runs.
+func requestQuery(r *http.Request) string {
+	query := r.URL.Query()
+	for name := range query {
+		if strings.HasPrefix(name, "X-Amz-") {
+			query.Del(name)
+		}
+	}
+	return query.Encode()
+}
+
+// Recorder proxies requests to the upstream endpoint and appends the
+// exchanges to the recording file.
+type Recorder struct {
+	endpoint *url.URL
+	region   string
+	creds    *aws.Credentials
+	signer   *v4.Signer
+	client   *http.Client
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRecorder creates a Recorder appending to cfg.File.
+func NewRecorder(cfg config.RecordReplayConfig) (*Recorder, error) {
+	if cfg.File == "" {
+		return nil, errors.New("record_replay.file is required")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid record_replay.endpoint: %q", endpoint)
+	}
+	file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %w", err)
+	}
+
+	r := &Recorder{
+		endpoint: u,
+		region:   cfg.Region,
+		signer: v4.NewSigner(func(o *v4.SignerOptions) {
+			// S3 signs paths escaped once
+			o.DisableURIPathEscaping = true
+		}),
+		client: &http.Client{
+			Timeout: upstreamTimeout,
+			// Redirects are recorded as they are
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		file: file,
+	}
+	if cfg.AccessKey != "" {
+		r.creds = &aws.Credentials{AccessKeyID: cfg.AccessKey, SecretAccessKey: cfg.SecretKey}
+	}
+	return r, nil
+}
+
+// ServeHTTP forwards a request upstream, writes the response and records the
+// exchange. Failing upstream requests are answered with 503 and not
+// recorded.
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	resp, err := r.forward(req)
+	if err != nil {
+		log.Error().Err(err).Str("path", req.URL.Path).Msg("Failed to proxy request to the recorded endpoint")
+		s3Err := *api.ErrServiceUnavailable
+		s3Err.Message = "The recorded S3 endpoint could not be reached."
+		api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Error().Err(err).Str("path", req.URL.Path).Msg("Failed to read response of the recorded endpoint")
+		api.WriteErrorWithResource(w, api.ErrInternalError, req.URL.Path)
+		return
+	}
+
+	exchange := &Exchange{
+		Time:   time.Now().UTC(),
+		Method: req.Method,
+		Path:   req.URL.EscapedPath(),
+		Query:  requestQuery(req),
+		Status: resp.StatusCode,
+		Header: resp.Header.Clone(),
+		Body:   body,
+	}
+	for _, name := range skippedResponseHeaders {
+		exchange.Header.Del(name)
+	}
+	if err := r.record(exchange); err != nil {
+		log.Error().Err(err).Msg("Failed to record exchange")
+	}
+	writeExchange(w, req, exchange)
+}
+
+// forward sends a request to the upstream endpoint, signed for it.
+func (r *Recorder) forward(req *http.Request) (*http.Response, error) {
+	var body io.Reader = req.Body
+	if api.IsAWSChunked(req.Header.Get("Content-Encoding"), req.Header.Get("X-Amz-Content-Sha256")) {
+		body = api.NewChunkedReader(req.Body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	target := *r.endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + req.URL.Path
+	target.RawPath = ""
+	if req.URL.RawPath != "" {
+		target.RawPath = strings.TrimSuffix(r.endpoint.EscapedPath(), "/") + req.URL.RawPath
+	}
+	target.RawQuery = requestQuery(req)
+
+	ctx := req.Context()
+	out, err := http.NewRequestWithContext(ctx, req.Method, target.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range req.Header {
+		if !isSkipped(skippedRequestHeaders, name) {
+			out.Header[name] = values
+		}
+	}
+	if encoding := strings.TrimPrefix(strings.TrimPrefix(req.Header.Get("Content-Encoding"), "aws-chunked"), ","); encoding != "" {
+		out.Header.Set("Content-Encoding", encoding)
+	}
+	out.ContentLength = int64(len(data))
+
+	if r.creds != nil {
+		sum := sha256.Sum256(data)
+		payloadHash := hex.EncodeToString(sum[:])
+		out.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		if err := r.signer.SignHTTP(ctx, *r.creds, out, payloadHash, "s3", r.region, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+	return r.client.Do(out)
+}
+
+// record appends an exchange to the recording file.
+func (r *Recorder) record(exchange *Exchange) error {
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	exchangesRecorded.Inc()
+	return nil
+}
+
+// Close closes the recording file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// Player answers requests with recorded responses. Requests are matched by
+// method, path and query; repeated requests get the responses recorded for
+// them in order, and the last one once they are used up.
+type Player struct {
+	mu        sync.Mutex
+	exchanges map[string][]*Exchange
+	next      map[string]int
+}
+
+// LoadPlayer reads the exchanges recorded in a file.
+func LoadPlayer(path string) (*Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %w", err)
+	}
+	defer f.Close()
+
+	p := &Player{
+		exchanges: make(map[string][]*Exchange),
+		next:      make(map[string]int),
+	}
+	scanner := bufio.NewScanner(f)
+	// Lines hold whole response bodies
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<30)
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("invalid recording at line %d: %w", n, err)
+		}
+		key := exchange.key()
+		p.exchanges[key] = append(p.exchanges[key], &exchange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording file: %w", err)
+	}
+	return p, nil
+}
+
+// Len returns the number of recorded exchanges.
+func (p *Player) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, exchanges := range p.exchanges {
+		n += len(exchanges)
+	}
+	return n
+}
+
+// Reset starts replaying the recorded responses from the beginning.
+func (p *Player) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clear(p.next)
+}
+
+// ServeHTTP answers a request with its next recorded response. Requests
+// without recorded responses fail with 400 InvalidRequest, naming the
+// request in the message.
+func (p *Player) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	key := (&Exchange{Method: req.Method, Path: req.URL.EscapedPath(), Query: requestQuery(req)}).key()
+
+	p.mu.Lock()
+	exchanges := p.exchanges[key]
+	var exchange *Exchange
+	if len(exchanges) > 0 {
+		i := min(p.next[key], len(exchanges)-1)
+		p.next[key] = i + 1
+		exchange = exchanges[i]
+	}
+	p.mu.Unlock()
+
+	if exchange == nil {
+		exchangesUnmatched.Inc()
+		log.Warn().Str("request", key).Msg("No recorded response matches the request")
+		s3Err := *api.ErrInvalidRequest
+		s3Err.Message = "No recorded response matches the request: " + key
+		api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+		return
+	}
+	// The request body is not used, but must be received for the response
+	_, _ = io.Copy(io.Discard, req.Body)
+	exchangesReplayed.Inc()
+	writeExchange(w, req, exchange)
+}
+
+// writeExchange writes the recorded response of an exchange.
+func writeExchange(w http.ResponseWriter, req *http.Request, exchange *Exchange) {
+	for name, values := range exchange.Header {
+		w.Header()[name] = values
+	}
+	if req.Method != http.MethodHead && w.Header().Get("Content-Length") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(exchange.Body)))
+	}
+	w.WriteHeader(exchange.Status)
+	if req.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(exchange.Body); err != nil && !errors.Is(err, context.Canceled) {
+		log.Debug().Err(err).Msg("Failed to write recorded response")
+	}
+}
+
+// isSkipped reports whether a canonical header name is in a list.
+func isSkipped(names []string, name string) bool {
+	for _, skipped := range names {
+		if strings.EqualFold(skipped, name) {
+			return true
+		}
+	}
+	return false
+}
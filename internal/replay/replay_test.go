@@ -0,0 +1,137 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kumasuke/jog/internal/config"
+)
+
+// fakeS3 is an upstream endpoint storing objects in memory, keyed by path.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(data)
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, data)
+	}
+}
+
+// serve sends a request to a handler and returns the response.
+func serve(h http.Handler, method, target, body string, header http.Header) *http.Response {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	upstream := httptest.NewServer(&fakeS3{objects: make(map[string]string)})
+	defer upstream.Close()
+	file := filepath.Join(t.TempDir(), "recording.jsonl")
+
+	recorder, err := NewRecorder(config.RecordReplayConfig{
+		File:      file,
+		Endpoint:  upstream.URL,
+		Region:    "us-east-1",
+		AccessKey: "AKID",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+
+	// Requests are recorded as the upstream endpoint answers them
+	if resp := serve(recorder, http.MethodGet, "/bucket/key.txt", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET before PUT: status %d", resp.StatusCode)
+	}
+	chunked := http.Header{
+		"Content-Encoding":             {"aws-chunked"},
+		"X-Amz-Content-Sha256":         {"STREAMING-AWS4-HMAC-SHA256-PAYLOAD"},
+		"X-Amz-Decoded-Content-Length": {"5"},
+		"Authorization":                {"AWS4-HMAC-SHA256 Credential=client/..."},
+	}
+	resp := serve(recorder, http.MethodPut, "/bucket/key.txt?x-id=PutObject", "5;chunk-signature=abc\r\nhello\r\n0;chunk-signature=def\r\n\r\n", chunked)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"etag"` {
+		t.Fatalf("PUT: status %d, ETag %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	resp = serve(recorder, http.MethodGet, "/bucket/key.txt?X-Amz-Signature=first", "", nil)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK || body != "hello" {
+		t.Fatalf("GET: status %d, body %q", resp.StatusCode, body)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	player, err := LoadPlayer(file)
+	if err != nil {
+		t.Fatalf("LoadPlayer: %v", err)
+	}
+	if player.Len() != 3 {
+		t.Errorf("Len = %d, want 3", player.Len())
+	}
+
+	// Repeated requests get the responses in the recorded order, then the
+	// last one; presigning parameters are ignored
+	for _, want := range []int{http.StatusNotFound, http.StatusOK, http.StatusOK} {
+		resp := serve(player, http.MethodGet, "/bucket/key.txt?X-Amz-Signature=second", "", nil)
+		if resp.StatusCode != want {
+			t.Errorf("replayed GET: status %d, want %d", resp.StatusCode, want)
+		}
+		if want == http.StatusOK {
+			if body := readBody(t, resp); body != "hello" || resp.Header.Get("Content-Type") != "text/plain" {
+				t.Errorf("replayed GET: body %q, content type %q", body, resp.Header.Get("Content-Type"))
+			}
+		}
+	}
+	if resp := serve(player, http.MethodPut, "/bucket/key.txt?x-id=PutObject", "other data", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("replayed PUT: status %d", resp.StatusCode)
+	}
+
+	resp = serve(player, http.MethodGet, "/bucket/other.txt", "", nil)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "GET /bucket/other.txt") {
+		t.Errorf("unmatched GET: status %d, body %q", resp.StatusCode, body)
+	}
+
+	player.Reset()
+	if resp := serve(player, http.MethodGet, "/bucket/key.txt", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after Reset: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/auth"
+	"github.com/kumasuke/jog/internal/policy"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultRotationGrace is how long the previous secret key of a rotated
+	// access key is accepted by default.
+	defaultRotationGrace = 24 * time.Hour
+	// maxRotationGrace is the longest the previous secret key of a rotated
+	// access key can be accepted.
+	maxRotationGrace = 30 * 24 * time.Hour
+	// maxRotateRequestSize is the largest JSON body of a rotation.
+	maxRotateRequestSize = 4 << 10
+	// minSecretKeyLength is the shortest secret key a rotation accepts.
+	minSecretKeyLength = 16
+)
+
+var errNoSuchAccessKey = api.NewError("NoSuchAccessKey", "The specified access key does not exist.")
+
+// accessKeyStore stores the secret keys of rotated access keys in the
+// metadata database of the default namespace.
+type accessKeyStore interface {
+	PutAccessKeySecrets(ctx context.Context, secrets *storage.AccessKeySecrets) error
+	ListAccessKeySecrets(ctx context.Context) ([]storage.AccessKeySecrets, error)
+}
+
+// rotateRequest is the JSON body of requests rotating an access key. Both
+// fields are optional.
+type rotateRequest struct {
+	// SecretKey is the new secret key; a random one is generated if unset.
+	SecretKey string `json:"secretKey"`
+	// PreviousExpiration is when the current secret key stops being
+	// accepted; 24 hours from now if unset, and at most 30 days from now.
+	PreviousExpiration *time.Time `json:"previousExpiration"`
+}
+
+// accessKeyResponse is the JSON body of an access key. The secret key is only
+// returned when the access key is rotated.
+type accessKeyResponse struct {
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey,omitempty"`
+	// PreviousExpiration is when the previous secret key stops being
+	// accepted, unset if it is not.
+	PreviousExpiration *time.Time `json:"previousExpiration,omitempty"`
+	// PreviousLastUsed is when the previous secret key last signed a
+	// request, unset if it did not since the rotation.
+	PreviousLastUsed *time.Time `json:"previousLastUsed,omitempty"`
+}
+
+func newAccessKeyResponse(secrets *storage.AccessKeySecrets, previousUsed time.Time, now time.Time) accessKeyResponse {
+	resp := accessKeyResponse{AccessKey: secrets.AccessKey}
+	if secrets.PreviousSecretKey != "" && now.Before(secrets.PreviousExpires) {
+		resp.PreviousExpiration = &secrets.PreviousExpires
+		if !previousUsed.IsZero() {
+			previousUsed = previousUsed.UTC()
+			resp.PreviousLastUsed = &previousUsed
+		}
+	}
+	return resp
+}
+
+// restoreSecrets applies the secret keys of access keys rotated before the
+// server started.
+func restoreSecrets(m *auth.Middleware, store accessKeyStore) {
+	list, err := store.ListAccessKeySecrets(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load rotated access keys")
+		return
+	}
+	for i := range list {
+		if !m.RestoreSecrets(&list[i]) {
+			log.Warn().Str("access_key", list[i].AccessKey).
+				Msg("Ignoring rotated secret keys of an access key removed or changed in the configuration")
+		}
+	}
+}
+
+// newSecretKey returns a random secret key.
+func newSecretKey() (string, error) {
+	b := make([]byte, 30)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// handleAdminAccessKey handles GET /_jog/admin/access-keys/{accessKey},
+// which reports whether the previous secret key of the access key is still
+// accepted and when it was last used, without revealing secret keys.
+func (r *Router) handleAdminAccessKey(w http.ResponseWriter, req *http.Request, accessKey string) {
+	m, ok := r.accessKeyManager(w, req, accessKey)
+	if !ok {
+		return
+	}
+	if req.Method != http.MethodGet {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+	secrets, previousUsed, _ := m.Secrets(accessKey)
+	writeAccessKeyJSON(w, http.StatusOK, newAccessKeyResponse(secrets, previousUsed, time.Now()))
+}
+
+// handleAdminAccessKeyRotate handles POST
+// /_jog/admin/access-keys/{accessKey}/rotate. The access key gets a new
+// secret key, only returned in the response, and its current secret key is
+// accepted as well until the previous expiration, so that clients can be
+// moved to the new one gradually.
+func (r *Router) handleAdminAccessKeyRotate(w http.ResponseWriter, req *http.Request, accessKey string) {
+	m, ok := r.accessKeyManager(w, req, accessKey)
+	if !ok {
+		return
+	}
+	if req.Method != http.MethodPost {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+	// Rotating writes to the metadata database like S3 writes
+	switch r.mode.Get() {
+	case ModeReadOnly:
+		api.WriteErrorWithResource(w, api.ErrReadOnlyMode, req.URL.Path)
+		return
+	case ModeMaintenance:
+		w.Header().Set("Retry-After", "60")
+		api.WriteErrorWithResource(w, api.ErrServiceUnavailable, req.URL.Path)
+		return
+	}
+
+	var body rotateRequest
+	var tooLarge *http.MaxBytesError
+	err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxRotateRequestSize)).Decode(&body)
+	if errors.As(err, &tooLarge) {
+		api.WriteError(w, api.ErrMaxMessageLengthExceeded)
+		return
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		api.WriteError(w, api.ErrInvalidRequest)
+		return
+	}
+	now := time.Now().UTC()
+	current, _, _ := m.Secrets(accessKey)
+	if current.PreviousSecretKey != "" && now.Before(current.PreviousExpires) {
+		// Rotating again would stop accepting a secret key clients may
+		// still use
+		s3Err := *api.ErrInvalidRequest
+		s3Err.Message = fmt.Sprintf("The previous secret key is accepted until %s; revoke it before rotating again.",
+			current.PreviousExpires.Format(time.RFC3339))
+		api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+		return
+	}
+	if err := body.validate(current.SecretKey, now); err != nil {
+		s3Err := *api.ErrInvalidArgument
+		s3Err.Message = err.Error()
+		api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+		return
+	}
+
+	secretKey := body.SecretKey
+	if secretKey == "" {
+		var err error
+		if secretKey, err = newSecretKey(); err != nil {
+			log.Error().Err(err).Msg("Failed to generate secret key")
+			api.WriteErrorWithResource(w, api.ErrInternalError, req.URL.Path)
+			return
+		}
+	}
+	secrets := &storage.AccessKeySecrets{
+		AccessKey:         accessKey,
+		SecretKey:         secretKey,
+		PreviousSecretKey: current.SecretKey,
+		PreviousExpires:   now.Add(defaultRotationGrace),
+	}
+	if body.PreviousExpiration != nil {
+		secrets.PreviousExpires = body.PreviousExpiration.UTC()
+	}
+	if !r.putSecrets(w, req, m, secrets) {
+		return
+	}
+	log.Info().Str("access_key", accessKey).Time("previous_expiration", secrets.PreviousExpires).Msg("Access key rotated")
+
+	resp := newAccessKeyResponse(secrets, time.Time{}, now)
+	resp.SecretKey = secretKey
+	writeAccessKeyJSON(w, http.StatusOK, resp)
+}
+
+// handleAdminAccessKeyPrevious handles DELETE
+// /_jog/admin/access-keys/{accessKey}/previous, which stops accepting the
+// previous secret key of the access key right away.
+func (r *Router) handleAdminAccessKeyPrevious(w http.ResponseWriter, req *http.Request, accessKey string) {
+	m, ok := r.accessKeyManager(w, req, accessKey)
+	if !ok {
+		return
+	}
+	if req.Method != http.MethodDelete {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	secrets, _, _ := m.Secrets(accessKey)
+	if secrets.PreviousSecretKey != "" {
+		secrets.PreviousSecretKey = ""
+		secrets.PreviousExpires = time.Time{}
+		if !r.putSecrets(w, req, m, secrets) {
+			return
+		}
+		log.Info().Str("access_key", accessKey).Msg("Previous secret key revoked")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validate checks the new secret key and previous expiration of a rotation.
+func (b *rotateRequest) validate(current string, now time.Time) error {
+	if b.SecretKey != "" && len(b.SecretKey) < minSecretKeyLength {
+		return fmt.Errorf("secret key must be at least %d characters", minSecretKeyLength)
+	}
+	if b.SecretKey != "" && b.SecretKey == current {
+		return fmt.Errorf("secret key must differ from the current secret key")
+	}
+	if b.PreviousExpiration != nil && !b.PreviousExpiration.After(now) {
+		return fmt.Errorf("previous expiration must be in the future")
+	}
+	if b.PreviousExpiration != nil && b.PreviousExpiration.After(now.Add(maxRotationGrace)) {
+		return fmt.Errorf("previous expiration must be at most %d days from now", maxRotationGrace/(24*time.Hour))
+	}
+	return nil
+}
+
+// putSecrets stores and applies the secret keys of an access key, writing an
+// error if they cannot be stored.
+func (r *Router) putSecrets(w http.ResponseWriter, req *http.Request, m *auth.Middleware, secrets *storage.AccessKeySecrets) bool {
+	if err := r.secrets.PutAccessKeySecrets(req.Context(), secrets); err != nil {
+		log.Error().Err(err).Str("access_key", secrets.AccessKey).Msg("Failed to store access key secrets")
+		api.WriteErrorWithResource(w, api.ErrInternalError, req.URL.Path)
+		return false
+	}
+	m.SetSecrets(secrets)
+	return true
+}
+
+// accessKeyManager returns the authentication middleware whose access key is
+// managed, writing an error if access keys cannot be rotated or the caller
+// may not manage the access key. Callers manage their own access key; the
+// access key of the default namespace manages all access keys.
+func (r *Router) accessKeyManager(w http.ResponseWriter, req *http.Request, accessKey string) (*auth.Middleware, bool) {
+	caller := storage.OwnerFromContext(req.Context())
+	m, ok := r.authMiddle.(*auth.Middleware)
+	if !ok || caller == "" || r.secrets == nil {
+		s3Err := *api.ErrNotImplemented
+		s3Err.Message = "Access key rotation requires authentication and a storage backend with a metadata database."
+		api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+		return nil, false
+	}
+	if policy.HasSessionPolicy(req.Context()) {
+		// Service accounts and temporary credentials act as the access key
+		// of their parent, whose secret key they must not replace
+		s3Err := *api.ErrAccessDenied
+		s3Err.Message = "Service accounts and temporary credentials cannot manage access keys."
+		api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+		return nil, false
+	}
+	if _, _, ok := m.Secrets(accessKey); !ok || (caller != accessKey && caller != m.RootAccessKey()) {
+		// Access keys of others are not revealed
+		api.WriteErrorWithResource(w, errNoSuchAccessKey, req.URL.Path)
+		return nil, false
+	}
+	return m, true
+}
+
+// writeAccessKeyJSON writes a JSON response of the admin access key
+// endpoints.
+func writeAccessKeyJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin access key response")
+	}
+}
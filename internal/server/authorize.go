@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/policy"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// AuthorizeMiddleware denies requests that the identity policies of their
+// access key or the policy of their bucket do not allow. Requests to the admin
+// endpoints are authorized as jog:Admin. Requests without an authenticated
+// access key, as with authentication disabled, are not authorized.
+func AuthorizeMiddleware(next http.Handler, authorizer *policy.Authorizer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := storage.OwnerFromContext(r.Context())
+		if principal == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var action, bucket, key string
+		if strings.HasPrefix(r.URL.Path, adminPathPrefix) {
+			action = policy.AdminAction
+		} else {
+			bucket, key, _ = strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			action = policy.Action(r, bucket, key)
+		}
+		if action == "" {
+			// DeleteObjects authorizes its keys one by one
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, err := authorizer.Authorize(r.Context(), policy.NewRequest(r, principal, action, bucket, key))
+		if err != nil {
+			log.Error().Err(err).Str("bucket", bucket).Str("action", action).Msg("Failed to authorize request")
+			api.WriteError(w, api.ErrInternalError)
+			return
+		}
+		if !allowed {
+			api.WriteErrorWithResource(w, api.ErrAccessDenied, r.URL.Path)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NamedPolicies returns the parsed policies of auth.policies by name. It
+// fails on invalid policies and policies without a unique name.
+func NamedPolicies(cfg config.AuthConfig) (map[string]*policy.Document, error) {
+	documents := make(map[string]*policy.Document, len(cfg.Policies))
+	for _, p := range cfg.Policies {
+		if p.Name == "" {
+			return nil, fmt.Errorf("policies must have a name")
+		}
+		if _, ok := documents[p.Name]; ok {
+			return nil, fmt.Errorf("duplicate policy %q", p.Name)
+		}
+		doc, err := policy.Parse([]byte(p.Document))
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", p.Name, err)
+		}
+		documents[p.Name] = doc
+	}
+	return documents, nil
+}
+
+// IdentityPolicies returns the parsed identity policies of the access keys of
+// users and tenants by access key. It fails on invalid policies, unknown
+// policy names and users without a unique access key.
+func IdentityPolicies(cfg config.AuthConfig) (map[string][]*policy.Document, error) {
+	documents, err := NamedPolicies(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	resolve := func(names []string) ([]*policy.Document, error) {
+		docs := make([]*policy.Document, 0, len(names))
+		for _, name := range names {
+			doc, ok := documents[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown policy %q", name)
+			}
+			docs = append(docs, doc)
+		}
+		return docs, nil
+	}
+
+	identities := make(map[string][]*policy.Document)
+	accessKeys := map[string]bool{cfg.AccessKey: true}
+	for _, tenant := range cfg.Tenants {
+		accessKeys[tenant.AccessKey] = true
+		docs, err := resolve(tenant.Policies)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		if len(docs) > 0 {
+			identities[tenant.AccessKey] = docs
+		}
+	}
+	for _, user := range cfg.Users {
+		if user.AccessKey == "" || accessKeys[user.AccessKey] {
+			return nil, fmt.Errorf("user access key must be set and unique")
+		}
+		accessKeys[user.AccessKey] = true
+		docs, err := resolve(user.Policies)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", user.AccessKey, err)
+		}
+		if len(docs) > 0 {
+			identities[user.AccessKey] = docs
+		}
+	}
+	return identities, nil
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"github.com/kumasuke/jog/internal/cluster"
+	"github.com/kumasuke/jog/internal/config"
+)
+
+// newClusterNode creates the local node of the cluster.
+func newClusterNode(cfg config.ClusterConfig) (*cluster.Node, error) {
+	id, err := nodeID(cfg.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.NewNode(cluster.Config{
+		ID:             id,
+		URL:            cfg.AdvertiseURL,
+		Seeds:          cfg.Seeds,
+		Secret:         cfg.Secret,
+		GossipInterval: cfg.GossipInterval,
+		FailureTimeout: cfg.FailureTimeout,
+		VirtualNodes:   cfg.VirtualNodes,
+	})
+}
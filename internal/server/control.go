@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/kumasuke/jog/internal/auth"
+	"github.com/kumasuke/jog/internal/control"
+	"github.com/kumasuke/jog/internal/events"
+	"github.com/kumasuke/jog/internal/policy"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// newControlServer creates the gRPC server of the control service. The
+// service is served with the certificate of the S3 API, and is not started
+// without one, as its calls manage the server.
+func newControlServer(s *Server, authMiddleware *auth.Middleware) (*grpc.Server, error) {
+	tls := s.config.Server.TLS
+	if tls.CertFile == "" || tls.KeyFile == "" {
+		return nil, fmt.Errorf("the gRPC control service (server.grpc_port) requires server.tls.cert_file and server.tls.key_file")
+	}
+	creds, err := credentials.NewServerTLSFromFile(tls.CertFile, tls.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the certificate of the control service: %w", err)
+	}
+	return control.NewServer(&controlBackend{server: s, auth: authMiddleware},
+		grpc.Creds(creds),
+		grpc.ConnectionTimeout(30*time.Second),
+		grpc.KeepaliveParams(keepalive.ServerParameters{MaxConnectionIdle: 120 * time.Second}),
+	), nil
+}
+
+// startControlServer listens on the control port and serves it in the background.
+func (s *Server) startControlServer() error {
+	addr := fmt.Sprintf("%s:%d", s.config.Server.Address, s.config.Server.GRPCPort)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for the control service: %w", err)
+	}
+	log.Info().Str("addr", addr).Msg("Starting gRPC control service")
+	go func() {
+		if err := s.controlServer.Serve(ln); err != nil {
+			log.Error().Err(err).Msg("Control service failed")
+		}
+	}()
+	return nil
+}
+
+// stopControlServer stops the control service, waiting for calls in progress
+// until ctx is done.
+func (s *Server) stopControlServer(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.controlServer.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.controlServer.Stop()
+	}
+}
+
+// controlBackend implements the calls of the control service on the server.
+type controlBackend struct {
+	server *Server
+	auth   *auth.Middleware
+}
+
+// Authenticate accepts the administrator access key and the access keys
+// whose identity policies allow jog:Admin, as for the admin endpoints.
+func (b *controlBackend) Authenticate(accessKey string, signedWith func(secretKey string) bool) (string, bool) {
+	tenant, ok := b.auth.Authenticate(accessKey, signedWith)
+	if !ok {
+		return "", false
+	}
+	req := &policy.Request{Principal: accessKey, Action: policy.AdminAction}
+	if allowed, err := b.server.router.handler.Authorizer().Authorize(context.Background(), req); err != nil || !allowed {
+		return "", false
+	}
+	return tenant, true
+}
+
+func (b *controlBackend) Mode() string {
+	return string(b.server.router.Mode().Get())
+}
+
+func (b *controlBackend) SetMode(name string) error {
+	mode, err := ParseMode(name)
+	if err != nil {
+		return fmt.Errorf("%w: %v", control.ErrInvalidArgument, err)
+	}
+	b.server.router.Mode().Set(mode)
+	log.Info().Str("mode", string(mode)).Msg("Server mode changed")
+	return nil
+}
+
+func (b *controlBackend) Users() []control.User {
+	cfg := b.server.config.Auth
+	users := []control.User{{AccessKey: cfg.AccessKey}}
+	for _, tenant := range cfg.Tenants {
+		users = append(users, control.User{AccessKey: tenant.AccessKey, Tenant: tenant.Name})
+	}
+	for _, user := range cfg.Users {
+		users = append(users, control.User{AccessKey: user.AccessKey})
+	}
+	return users
+}
+
+func (b *controlBackend) BucketStats(ctx context.Context, bucket string) (control.BucketStats, error) {
+	usage, err := b.server.storage.GetBucketUsage(ctx, bucket)
+	if err != nil {
+		return control.BucketStats{Bucket: bucket}, err
+	}
+	return control.BucketStats{
+		Bucket:           bucket,
+		Objects:          usage.Objects,
+		Bytes:            usage.Bytes,
+		MultipartUploads: usage.MultipartUploads,
+		VersionBytes:     usage.VersionBytes,
+		MultipartBytes:   usage.MultipartBytes,
+	}, nil
+}
+
+func (b *controlBackend) RunMaintenance(ctx context.Context, task string) (int, error) {
+	cfg := b.server.config.Storage
+	if b.server.standby() {
+		return 0, fmt.Errorf("%w: maintenance runs on the leader", control.ErrUnavailable)
+	}
+	switch task {
+	case control.TaskAbortStaleUploads:
+		// Without an age, every upload in progress would be aborted
+		if cfg.Multipart.AbortAfterDays <= 0 {
+			return 0, fmt.Errorf("%w: stale upload cleanup is disabled", control.ErrInvalidArgument)
+		}
+		return b.server.abortStaleUploads(ctx)
+	case control.TaskScrubObjects:
+		if cfg.Scrub.MaxObjects <= 0 {
+			return 0, fmt.Errorf("%w: integrity scrubbing is disabled", control.ErrInvalidArgument)
+		}
+		return b.server.scrubObjects(ctx)
+	default:
+		return 0, fmt.Errorf("%w: unknown maintenance task %q", control.ErrInvalidArgument, task)
+	}
+}
+
+func (b *controlBackend) Events() *events.Broker {
+	return b.server.router.handler.Events()
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/events"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// eventsKeepAlive is how often a comment is sent on idle event streams so
+// proxies and clients do not time out the connection.
+const eventsKeepAlive = 15 * time.Second
+
+// handleAdminEvents handles GET /_jog/admin/events. It streams object events
+// of the caller's tenant as Server-Sent Events, optionally filtered by the
+// bucket and prefix query parameters.
+func (r *Router) handleAdminEvents(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+	sub := r.handler.Events().Subscribe(events.Filter{
+		Tenant: storage.TenantFromContext(req.Context()),
+		Bucket: query.Get("bucket"),
+		Prefix: query.Get("prefix"),
+	})
+	defer sub.Close()
+
+	// The stream outlives the server write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Debug().Err(err).Msg("Failed to clear write deadline of event stream")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Error().Err(err).Msg("Event stream does not support flushing")
+		return
+	}
+
+	ticker := time.NewTicker(eventsKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to encode event")
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// faultErrors are the errors a fault rule can inject, by code.
+var faultErrors = map[string]*api.S3Error{
+	"InternalError":      api.ErrInternalError,
+	"ServiceUnavailable": api.ErrServiceUnavailable,
+	"SlowDown":           api.ErrSlowDown,
+}
+
+// FaultRule describes the faults injected into matching requests.
+type FaultRule struct {
+	// Operations are the S3 operations the rule applies to, such as
+	// "GetObject". Empty means all requests.
+	Operations []string `json:"operations,omitempty"`
+	// Buckets are the buckets the rule applies to. Empty means all buckets.
+	Buckets []string `json:"buckets,omitempty"`
+	// LatencyMs delays requests, plus a random delay of up to JitterMs.
+	LatencyMs int `json:"latencyMs,omitempty"`
+	JitterMs  int `json:"jitterMs,omitempty"`
+	// ErrorRate is the fraction of requests failed with ErrorCode, which
+	// defaults to InternalError.
+	ErrorRate float64 `json:"errorRate,omitempty"`
+	ErrorCode string  `json:"errorCode,omitempty"`
+	// BodyBytesPerSecond limits the rate response bodies are sent at.
+	BodyBytesPerSecond int `json:"bodyBytesPerSecond,omitempty"`
+}
+
+// validate checks the values of a rule.
+func (f FaultRule) validate() error {
+	if f.LatencyMs < 0 || f.JitterMs < 0 || f.BodyBytesPerSecond < 0 {
+		return errors.New("fault latencies and body rates must not be negative")
+	}
+	if !(f.ErrorRate >= 0 && f.ErrorRate <= 1) {
+		return errors.New("fault error rate must be between 0 and 1")
+	}
+	if _, ok := faultErrors[f.ErrorCode]; f.ErrorCode != "" && !ok {
+		return fmt.Errorf("unsupported fault error code: %s", f.ErrorCode)
+	}
+	return nil
+}
+
+// matches reports whether a rule applies to a request of an operation on a
+// bucket.
+func (f FaultRule) matches(operation, bucket string) bool {
+	if len(f.Operations) > 0 && !slices.Contains(f.Operations, operation) {
+		return false
+	}
+	return len(f.Buckets) == 0 || slices.Contains(f.Buckets, bucket)
+}
+
+// delay returns the latency injected into a request.
+func (f FaultRule) delay() time.Duration {
+	d := time.Duration(f.LatencyMs) * time.Millisecond
+	if f.JitterMs > 0 {
+		d += rand.N(time.Duration(f.JitterMs) * time.Millisecond)
+	}
+	return d
+}
+
+// faultRulesFromConfig converts the configured fault rules.
+func faultRulesFromConfig(cfg []config.FaultRuleConfig) ([]FaultRule, error) {
+	rules := make([]FaultRule, 0, len(cfg))
+	for i, c := range cfg {
+		rule := FaultRule{
+			Operations:         c.Operations,
+			Buckets:            c.Buckets,
+			LatencyMs:          int(c.Latency.Milliseconds()),
+			JitterMs:           int(c.Jitter.Milliseconds()),
+			ErrorRate:          c.ErrorRate,
+			ErrorCode:          c.ErrorCode,
+			BodyBytesPerSecond: c.BodyBytesPerSecond,
+		}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("fault rule %d: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Faults holds the fault injection rules. It is safe for concurrent use.
+type Faults struct {
+	mu      sync.RWMutex
+	enabled bool
+	rules   []FaultRule
+}
+
+// NewFaults creates a disabled Faults without rules.
+func NewFaults() *Faults {
+	return &Faults{}
+}
+
+// Set enables or disables fault injection and replaces the rules.
+func (f *Faults) Set(enabled bool, rules []FaultRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enabled = enabled
+	f.rules = rules
+}
+
+// Get returns whether fault injection is enabled and its rules.
+func (f *Faults) Get() (bool, []FaultRule) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.enabled, f.rules
+}
+
+// match returns the first rule that applies to a request, if fault injection
+// is enabled.
+func (f *Faults) match(operation, bucket string) (FaultRule, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.enabled {
+		return FaultRule{}, false
+	}
+	for _, rule := range f.rules {
+		if rule.matches(operation, bucket) {
+			return rule, true
+		}
+	}
+	return FaultRule{}, false
+}
+
+// faultQueryParameters are the query parameters of the operations s3Operation
+// names. Requests with other parameters address subresources.
+var faultQueryParameters = []string{
+	"continuation-token", "delete", "delimiter", "encoding-type", "fetch-owner",
+	"key-marker", "list-type", "marker", "max-keys", "max-parts", "max-uploads",
+	"part-number-marker", "partNumber", "prefix", "start-after", "upload-id-marker",
+	"uploadId", "uploads", "version-id-marker", "versionId", "versions", "x-id",
+}
+
+// s3Operation returns the name of the S3 operation of a request for the
+// bucket, object and multipart upload operations applications use to
+// transfer data, or "" for other requests, such as those of subresources.
+func s3Operation(r *http.Request) string {
+	query := r.URL.Query()
+	for name := range query {
+		// Added by presigning and to override response headers
+		if !slices.Contains(faultQueryParameters, name) && !strings.HasPrefix(name, "response-") && !strings.HasPrefix(name, "X-Amz-") {
+			return ""
+		}
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	copySource := r.Header.Get("x-amz-copy-source") != ""
+	switch {
+	case bucket == "":
+		if r.Method == http.MethodGet {
+			return "ListBuckets"
+		}
+	case key == "":
+		switch r.Method {
+		case http.MethodGet:
+			switch {
+			case query.Has("uploads"):
+				return "ListMultipartUploads"
+			case query.Has("versions"):
+				return "ListObjectVersions"
+			case query.Get("list-type") == "2":
+				return "ListObjectsV2"
+			default:
+				return "ListObjects"
+			}
+		case http.MethodHead:
+			return "HeadBucket"
+		case http.MethodPut:
+			return "CreateBucket"
+		case http.MethodDelete:
+			return "DeleteBucket"
+		case http.MethodPost:
+			if query.Has("delete") {
+				return "DeleteObjects"
+			}
+		}
+	default:
+		switch r.Method {
+		case http.MethodGet:
+			if query.Has("uploadId") {
+				return "ListParts"
+			}
+			return "GetObject"
+		case http.MethodHead:
+			return "HeadObject"
+		case http.MethodPut:
+			switch {
+			case query.Has("uploadId") && copySource:
+				return "UploadPartCopy"
+			case query.Has("uploadId"):
+				return "UploadPart"
+			case copySource:
+				return "CopyObject"
+			default:
+				return "PutObject"
+			}
+		case http.MethodPost:
+			if query.Has("uploads") {
+				return "CreateMultipartUpload"
+			}
+			if query.Has("uploadId") {
+				return "CompleteMultipartUpload"
+			}
+		case http.MethodDelete:
+			if query.Has("uploadId") {
+				return "AbortMultipartUpload"
+			}
+			return "DeleteObject"
+		}
+	}
+	return ""
+}
+
+// FaultMiddleware injects the faults of the first matching rule into S3
+// requests: it delays them, fails a fraction of them with an error, and sends
+// their response bodies slowly. Admin requests are not affected.
+func FaultMiddleware(next http.Handler, faults *Faults) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, adminPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		operation := s3Operation(r)
+		bucket, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		rule, ok := faults.match(operation, bucket)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if d := rule.delay(); d > 0 {
+			countFault(operation, "latency")
+			timer := time.NewTimer(d)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+		if rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate {
+			countFault(operation, "error")
+			s3Err, ok := faultErrors[rule.ErrorCode]
+			if !ok {
+				s3Err = api.ErrInternalError
+			}
+			if s3Err == api.ErrServiceUnavailable {
+				// The message of the maintenance mode does not apply
+				unavailable := *s3Err
+				unavailable.Message = "Please try again later."
+				s3Err = &unavailable
+			}
+			api.WriteErrorWithResource(w, s3Err, r.URL.Path)
+			return
+		}
+		if rule.BodyBytesPerSecond > 0 {
+			countFault(operation, "slow_body")
+			w = &slowBodyWriter{ResponseWriter: w, ctx: r.Context(), bytesPerSecond: rule.BodyBytesPerSecond}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// countFault counts an injected fault.
+func countFault(operation, fault string) {
+	if operation == "" {
+		operation = "other"
+	}
+	metrics.NewCounter("jog_injected_faults_total",
+		"Faults injected into S3 requests by fault injection rules.", "operation", operation, "fault", fault).Inc()
+}
+
+// slowBodyWriter sends a response body at a limited rate, flushing it in
+// chunks of a tenth of a second of data.
+type slowBodyWriter struct {
+	http.ResponseWriter
+	ctx            context.Context
+	bytesPerSecond int
+}
+
+func (w *slowBodyWriter) Write(b []byte) (int, error) {
+	chunk := max(w.bytesPerSecond/10, 1)
+	written := 0
+	for len(b) > 0 {
+		// Each chunk is sent after the time it takes at the rate
+		n := min(chunk, len(b))
+		timer := time.NewTimer(time.Duration(n) * time.Second / time.Duration(w.bytesPerSecond))
+		select {
+		case <-timer.C:
+		case <-w.ctx.Done():
+			timer.Stop()
+			return written, w.ctx.Err()
+		}
+
+		n, err := w.ResponseWriter.Write(b[:n])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+	}
+	return written, nil
+}
+
+// Unwrap returns the wrapped writer so http.ResponseController can flush it.
+func (w *slowBodyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// faultsResponse is the JSON body of the admin faults endpoint.
+type faultsResponse struct {
+	Enabled bool        `json:"enabled"`
+	Rules   []FaultRule `json:"rules"`
+}
+
+// handleAdminFaults handles GET, PUT and DELETE /_jog/admin/faults. PUT
+// replaces the fault injection rules and DELETE disables fault injection and
+// removes them. Rules are kept in memory, so rules set at runtime are lost on
+// restart. Only the credentials of the default namespace change them, as
+// they apply to all tenants.
+func (r *Router) handleAdminFaults(w http.ResponseWriter, req *http.Request) {
+	if (req.Method == http.MethodPut || req.Method == http.MethodDelete) && storage.TenantFromContext(req.Context()) != "" {
+		s3Err := *api.ErrAccessDenied
+		s3Err.Message = "Tenant credentials cannot change fault injection."
+		api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var body faultsResponse
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			api.WriteError(w, api.ErrInvalidRequest)
+			return
+		}
+		for _, rule := range body.Rules {
+			if err := rule.validate(); err != nil {
+				s3Err := *api.ErrInvalidArgument
+				s3Err.Message = err.Error()
+				api.WriteError(w, &s3Err)
+				return
+			}
+		}
+		r.faults.Set(body.Enabled, body.Rules)
+		log.Warn().Bool("enabled", body.Enabled).Int("rules", len(body.Rules)).Msg("Fault injection changed")
+	case http.MethodDelete:
+		r.faults.Set(false, nil)
+		log.Info().Msg("Fault injection disabled")
+	default:
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	enabled, rules := r.faults.Get()
+	if rules == nil {
+		rules = []FaultRule{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(faultsResponse{Enabled: enabled, Rules: rules}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin faults response")
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// leaderLease is the lease held by the leader of instances sharing a data volume.
+const leaderLease = "leader"
+
+var haLeader = metrics.NewGauge("jog_ha_leader",
+	"1 while the instance is the leader of its HA group, 0 on a standby.")
+
+// errStandby rejects writes on a standby, which clients retry on the leader.
+var errStandby = func() *api.S3Error {
+	err := *api.ErrServiceUnavailable
+	err.Message = "The server is a standby and does not accept writes."
+	return &err
+}()
+
+// leaseStore is implemented by the storage backends keeping their metadata in
+// SQLite.
+type leaseStore interface {
+	AcquireLease(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (int64, bool, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+	RemoveTempFiles() (int, error)
+}
+
+// election keeps the leader lease of an instance in the shared metadata
+// database. Only the leader accepts writes; a standby takes over once the
+// leader fails to renew the lease within its TTL.
+type election struct {
+	store    leaseStore
+	holder   string
+	ttl      time.Duration
+	interval time.Duration
+
+	started atomic.Bool
+	leader  atomic.Bool
+	term    atomic.Int64
+	// validUntil is when the lease expires unless renewed, in Unix nanoseconds.
+	validUntil atomic.Int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newElection creates the election of an instance sharing the storage of the
+// default namespace with other instances.
+func newElection(store storage.Storage, cfg config.HAConfig) (*election, error) {
+	holder, err := nodeID(cfg.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.LeaseTTL <= 0 || cfg.RenewInterval <= 0 || cfg.RenewInterval >= cfg.LeaseTTL {
+		return nil, fmt.Errorf("HA renew interval must be positive and shorter than the lease TTL")
+	}
+	if tenants, ok := store.(*storage.Tenants); ok {
+		store = tenants.Stores()[0]
+	}
+	leases, ok := store.(leaseStore)
+	if !ok {
+		return nil, fmt.Errorf("HA requires a storage backend with a metadata database")
+	}
+	return &election{
+		store:    leases,
+		holder:   holder,
+		ttl:      cfg.LeaseTTL,
+		interval: cfg.RenewInterval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// nodeID returns id, or the hostname if id is empty.
+func nodeID(id string) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine node ID: %w", err)
+	}
+	return hostname, nil
+}
+
+// Leader reports whether the instance holds the lease. It turns false when the
+// lease runs out even if renewing it hangs.
+func (e *election) Leader() bool {
+	return e.leader.Load() && time.Now().UnixNano() < e.validUntil.Load()
+}
+
+// Term returns the term of the latest lease held by the instance.
+func (e *election) Term() int64 {
+	return e.term.Load()
+}
+
+// start campaigns for the lease in the background.
+func (e *election) start() {
+	e.started.Store(true)
+	go e.run()
+}
+
+func (e *election) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	e.campaign()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.campaign()
+		}
+	}
+}
+
+// campaign acquires or renews the lease.
+func (e *election) campaign() {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), e.interval)
+	defer cancel()
+
+	term, ok, err := e.store.AcquireLease(ctx, leaderLease, e.holder, start, e.ttl)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to renew leader lease")
+	}
+	if !ok || err != nil {
+		if e.leader.Swap(false) {
+			log.Warn().Str("node", e.holder).Msg("Lost leadership, no longer accepting writes")
+		}
+		haLeader.Set(0)
+		return
+	}
+
+	e.validUntil.Store(start.Add(e.ttl).UnixNano())
+	if !e.leader.Load() {
+		// Writes of the previous leader that are still in progress fail
+		// instead of publishing objects after the new leader took over
+		removed, err := e.store.RemoveTempFiles()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to remove temporary files of the previous leader")
+		}
+		log.Info().Str("node", e.holder).Int64("term", term).Int("tempFiles", removed).Msg("Became leader")
+	}
+	e.term.Store(term)
+	e.leader.Store(true)
+	haLeader.Set(1)
+}
+
+// close stops campaigning and releases the lease, so a standby takes over
+// without waiting for it to expire.
+func (e *election) close() {
+	close(e.stop)
+	if e.started.Load() {
+		<-e.done
+	}
+	if !e.leader.Swap(false) {
+		return
+	}
+	haLeader.Set(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.store.ReleaseLease(ctx, leaderLease, e.holder); err != nil {
+		log.Error().Err(err).Msg("Failed to release leader lease")
+	}
+}
+
+// standbyFilter rejects requests that would write unless the instance is the
+// leader. The mode can still be changed on a standby.
+func (s *Server) standbyFilter(r *http.Request) error {
+	if isReadRequest(r) || r.URL.Path == adminPathPrefix+"mode" || s.election.Leader() {
+		return nil
+	}
+	return errStandby
+}
+
+// standby reports whether the instance is an HA standby.
+func (s *Server) standby() bool {
+	return s.election != nil && !s.election.Leader()
+}
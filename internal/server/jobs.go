@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/jobs"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	errNoSuchJob     = api.NewError("NoSuchJob", "The specified job does not exist.")
+	errJobNotRunning = api.NewError("InvalidJobState", "The specified job is not running.")
+)
+
+// jobListResponse is the JSON body of the admin job list endpoint.
+type jobListResponse struct {
+	Jobs []jobs.Job `json:"jobs"`
+}
+
+// handleAdminJobs handles GET and POST /_jog/admin/jobs.
+func (r *Router) handleAdminJobs(w http.ResponseWriter, req *http.Request) {
+	tenant := storage.TenantFromContext(req.Context())
+
+	switch req.Method {
+	case http.MethodGet:
+		list := r.jobs.List(tenant)
+		if list == nil {
+			list = []jobs.Job{}
+		}
+		writeJobJSON(w, http.StatusOK, jobListResponse{Jobs: list})
+	case http.MethodPost:
+		// Jobs modify objects, so they are subject to the server mode like S3 writes
+		switch r.mode.Get() {
+		case ModeReadOnly:
+			api.WriteErrorWithResource(w, api.ErrReadOnlyMode, req.URL.Path)
+			return
+		case ModeMaintenance:
+			w.Header().Set("Retry-After", "60")
+			api.WriteErrorWithResource(w, api.ErrServiceUnavailable, req.URL.Path)
+			return
+		}
+
+		var spec jobs.Spec
+		if err := json.NewDecoder(req.Body).Decode(&spec); err != nil {
+			api.WriteError(w, api.ErrInvalidRequest)
+			return
+		}
+		job, err := r.jobs.Submit(req.Context(), spec)
+		if err != nil {
+			writeJobError(w, req, err)
+			return
+		}
+		writeJobJSON(w, http.StatusCreated, job)
+	default:
+		api.WriteError(w, api.ErrMethodNotAllowed)
+	}
+}
+
+// handleAdminJob handles GET and DELETE /_jog/admin/jobs/{id}.
+func (r *Router) handleAdminJob(w http.ResponseWriter, req *http.Request, id string) {
+	tenant := storage.TenantFromContext(req.Context())
+
+	var (
+		job jobs.Job
+		err error
+	)
+	switch req.Method {
+	case http.MethodGet:
+		job, err = r.jobs.Get(tenant, id)
+	case http.MethodDelete:
+		job, err = r.jobs.Cancel(tenant, id)
+	default:
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeJobError(w, req, err)
+		return
+	}
+	writeJobJSON(w, http.StatusOK, job)
+}
+
+// writeJobError writes the S3 error response for a job manager error.
+func writeJobError(w http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		api.WriteErrorWithResource(w, errNoSuchJob, req.URL.Path)
+	case errors.Is(err, jobs.ErrJobNotRunning):
+		api.WriteErrorWithResource(w, errJobNotRunning, req.URL.Path)
+	case errors.Is(err, jobs.ErrInvalidSpec):
+		s3Err := *api.ErrInvalidArgument
+		s3Err.Message = strings.TrimPrefix(err.Error(), jobs.ErrInvalidSpec.Error()+": ")
+		api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+	default:
+		log.Error().Err(err).Msg("Batch job request failed")
+		api.WriteErrorWithResource(w, api.ErrInternalError, req.URL.Path)
+	}
+}
+
+// writeJobJSON writes a JSON response of the admin job endpoints.
+func writeJobJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin job response")
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// handleAdminMetrics handles GET /_jog/admin/metrics.
+func (r *Router) handleAdminMetrics(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	if err := metrics.WriteText(w); err != nil {
+		log.Error().Err(err).Msg("Failed to write metrics response")
+	}
+}
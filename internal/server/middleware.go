@@ -1,8 +1,10 @@
 package server
 
 import (
+	"errors"
 	"net/http"
 	"runtime/debug"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -10,6 +12,55 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Middleware wraps a handler with a cross-cutting concern such as logging or authentication.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with the given middleware. The first middleware is the outermost one,
+// so it sees the request first and the response last.
+func Chain(h http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// RequestFilter inspects requests before they are routed to the S3 handlers.
+type RequestFilter interface {
+	// FilterRequest returns a non-nil error to reject the request. An *api.S3Error
+	// is written as is; any other error is reported as AccessDenied with the
+	// error text as the message.
+	FilterRequest(r *http.Request) error
+}
+
+// RequestFilterFunc adapts a function to the RequestFilter interface.
+type RequestFilterFunc func(r *http.Request) error
+
+// FilterRequest calls f(r).
+func (f RequestFilterFunc) FilterRequest(r *http.Request) error {
+	return f(r)
+}
+
+// FilterMiddleware rejects requests for which the filter returns an error.
+func FilterMiddleware(filter RequestFilter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := filter.FilterRequest(r)
+			if err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var s3Err *api.S3Error
+			if !errors.As(err, &s3Err) {
+				denied := *api.ErrAccessDenied
+				denied.Message = err.Error()
+				s3Err = &denied
+			}
+			api.WriteErrorWithResource(w, s3Err, r.URL.Path)
+		})
+	}
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code.
 type responseWriter struct {
 	http.ResponseWriter
@@ -18,6 +69,11 @@ type responseWriter struct {
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
+	// Informational responses such as 100 Continue precede the final one
+	if code >= 100 && code < 200 {
+		rw.ResponseWriter.WriteHeader(code)
+		return
+	}
 	if rw.wroteHeader.Swap(true) {
 		return
 	}
@@ -25,6 +81,11 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap returns the wrapped writer so http.ResponseController can flush it.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (rw *responseWriter) Write(b []byte) (int, error) {
 	if rw.wroteHeader.CompareAndSwap(false, true) {
 		rw.status = http.StatusOK
@@ -33,6 +94,56 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
+// RequestIDMiddleware assigns each request an ID, returned in the
+// x-amz-request-id header of the response and in the RequestId element of
+// error responses.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(api.RequestIDHeader, api.NewRequestID())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ExpectContinueMiddleware sends 100 Continue to clients waiting for it
+// before they send the body of a request, e.g. curl and SDKs uploading large
+// objects with Expect: 100-continue. Without it, the interim response is only
+// sent once a handler starts reading the body, and clients that give up
+// waiting after a timeout stall every upload. The middleware runs after
+// authentication and the request filters, so requests rejected by them get
+// their error response instead, and the client does not send the body at
+// all. Go's HTTP server itself answers other expectations with 417
+// Expectation Failed.
+func ExpectContinueMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 0 && strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+			w.WriteHeader(http.StatusContinue)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HeaderLimitMiddleware rejects requests with more than limit header fields
+// with RequestHeaderSectionTooLarge. Repeated fields count once per value. A
+// zero limit disables the check.
+func HeaderLimitMiddleware(limit int) Middleware {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			count := 0
+			for _, values := range r.Header {
+				count += len(values)
+			}
+			if count > limit {
+				api.WriteErrorWithResource(w, api.ErrRequestHeaderSectionTooLarge, r.URL.Path)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // LoggingMiddleware logs HTTP requests.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -48,6 +159,7 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 			Int("status", rw.status).
 			Dur("duration", time.Since(start)).
 			Str("remote", r.RemoteAddr).
+			Str("requestId", w.Header().Get(api.RequestIDHeader)).
 			Msg("Request")
 	})
 }
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// Mode is the operating mode of the server.
+type Mode string
+
+const (
+	// ModeNormal serves all requests.
+	ModeNormal Mode = "normal"
+	// ModeReadOnly rejects mutating requests with 403 AccessDenied.
+	ModeReadOnly Mode = "read-only"
+	// ModeMaintenance rejects mutating requests with 503 ServiceUnavailable so clients retry later.
+	ModeMaintenance Mode = "maintenance"
+)
+
+// ParseMode parses a mode name. An empty name is the normal mode.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeNormal:
+		return ModeNormal, nil
+	case ModeReadOnly, ModeMaintenance:
+		return Mode(s), nil
+	default:
+		return "", fmt.Errorf("unknown server mode: %s", s)
+	}
+}
+
+// ModeSwitch holds the current server mode. It is safe for concurrent use.
+type ModeSwitch struct {
+	mode atomic.Value
+}
+
+// NewModeSwitch creates a ModeSwitch in the normal mode.
+func NewModeSwitch() *ModeSwitch {
+	m := &ModeSwitch{}
+	m.mode.Store(ModeNormal)
+	return m
+}
+
+// Get returns the current mode.
+func (m *ModeSwitch) Get() Mode {
+	return m.mode.Load().(Mode)
+}
+
+// Set changes the current mode.
+func (m *ModeSwitch) Set(mode Mode) {
+	m.mode.Store(mode)
+}
+
+// isReadRequest reports whether a request does not modify any state.
+func isReadRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// ModeMiddleware rejects mutating requests unless the server is in the normal mode.
+func ModeMiddleware(next http.Handler, mode *ModeSwitch) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isReadRequest(r) || strings.HasPrefix(r.URL.Path, adminPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch mode.Get() {
+		case ModeReadOnly:
+			api.WriteErrorWithResource(w, api.ErrReadOnlyMode, r.URL.Path)
+		case ModeMaintenance:
+			w.Header().Set("Retry-After", "60")
+			api.WriteErrorWithResource(w, api.ErrServiceUnavailable, r.URL.Path)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// modeResponse is the JSON body of the admin mode endpoint.
+type modeResponse struct {
+	Mode Mode `json:"mode"`
+}
+
+// handleAdminMode handles GET and PUT /_jog/admin/mode. Only the credentials
+// of the default namespace change the mode, as it applies to all tenants.
+func (r *Router) handleAdminMode(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if storage.TenantFromContext(req.Context()) != "" {
+			s3Err := *api.ErrAccessDenied
+			s3Err.Message = "Tenant credentials cannot change the server mode."
+			api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+			return
+		}
+		var body modeResponse
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			api.WriteError(w, api.ErrInvalidRequest)
+			return
+		}
+		mode, err := ParseMode(string(body.Mode))
+		if err != nil {
+			api.WriteError(w, api.ErrInvalidArgument)
+			return
+		}
+		r.mode.Set(mode)
+		log.Info().Str("mode", string(mode)).Msg("Server mode changed")
+	default:
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(modeResponse{Mode: r.mode.Get()}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin mode response")
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kumasuke/jog/internal/config"
+)
+
+// CheckProtocols validates the TLS and HTTP/2 settings of the S3 API server.
+func CheckProtocols(cfg config.ServerConfig) error {
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return fmt.Errorf("server.tls requires both cert_file and key_file")
+	}
+	if cfg.HTTP2.H2C && cfg.TLS.CertFile != "" {
+		return fmt.Errorf("server.http2.h2c cannot be combined with server.tls")
+	}
+	if cfg.HTTP2.MaxConcurrentStreams < 0 {
+		return fmt.Errorf("server.http2.max_concurrent_streams must not be negative")
+	}
+	return nil
+}
+
+// ConfigureProtocols sets the HTTP versions, HTTP/2 settings and keep-alives
+// of an S3 API server from settings accepted by CheckProtocols. HTTP/2 is
+// negotiated with TLS clients when enabled, and spoken without TLS (h2c) to
+// clients with prior knowledge when server.http2.h2c is set; HTTP/1.1 is
+// always served.
+func ConfigureProtocols(srv *http.Server, cfg config.ServerConfig) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(cfg.HTTP2.Enabled && cfg.TLS.CertFile != "")
+	protocols.SetUnencryptedHTTP2(cfg.HTTP2.H2C)
+	srv.Protocols = &protocols
+	srv.HTTP2 = &http.HTTP2Config{
+		MaxConcurrentStreams: cfg.HTTP2.MaxConcurrentStreams,
+		SendPingTimeout:      cfg.HTTP2.PingInterval,
+		PingTimeout:          cfg.HTTP2.PingTimeout,
+	}
+	srv.SetKeepAlivesEnabled(!cfg.DisableKeepAlives)
+}
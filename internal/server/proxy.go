@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies parses the addresses and CIDR prefixes of
+// server.trusted_proxies.
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	proxies := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// TrustedProxyMiddleware applies the X-Forwarded-* headers of requests sent
+// by trusted proxies, so that the rest of the server sees the request as the
+// client sent it to the proxy:
+//
+//   - RemoteAddr gets the client address from X-Forwarded-For, the last
+//     address not belonging to a trusted proxy, with the port of the proxy
+//     connection. Logs and event source IPs use it.
+//   - URL.Scheme is set from X-Forwarded-Proto, for absolute URLs in
+//     responses.
+//   - Host is set from X-Forwarded-Host, which signatures and presigned URLs
+//     are verified against when ingress controllers rewrite the Host header.
+//
+// The headers of other clients are ignored, since anyone can send them.
+func TrustedProxyMiddleware(proxies []netip.Prefix) Middleware {
+	return func(next http.Handler) http.Handler {
+		if len(proxies) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, port, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !isTrustedProxy(proxies, addr) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if client, ok := forwardedClient(proxies, r.Header.Values("X-Forwarded-For")); ok {
+				r.RemoteAddr = net.JoinHostPort(client.String(), port)
+			}
+			if proto := strings.ToLower(firstForwarded(r.Header.Get("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+				r.URL.Scheme = proto
+			}
+			if forwardedHost := firstForwarded(r.Header.Get("X-Forwarded-Host")); forwardedHost != "" {
+				r.Host = forwardedHost
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient returns the client address of an X-Forwarded-For chain:
+// proxies append the address they received a request from, so the client is
+// the last address that is not a trusted proxy. It reports false if the
+// chain is empty or malformed.
+func forwardedClient(proxies []netip.Prefix, values []string) (netip.Addr, bool) {
+	var chain []string
+	for _, value := range values {
+		chain = append(chain, strings.Split(value, ",")...)
+	}
+
+	var client netip.Addr
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(chain[i]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		client = addr.Unmap()
+		if !isTrustedProxy(proxies, client) {
+			break
+		}
+	}
+	return client, client.IsValid()
+}
+
+// firstForwarded returns the first element of a comma-separated forwarded
+// header, the value set by the proxy closest to the client.
+func firstForwarded(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.TrimSpace(first)
+}
+
+// isTrustedProxy reports whether addr belongs to a trusted proxy.
+func isTrustedProxy(proxies []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
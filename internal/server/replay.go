@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/replay"
+)
+
+// SetReplay sets the player whose recorded responses the replay admin
+// endpoint rewinds.
+func (r *Router) SetReplay(p *replay.Player) {
+	r.player = p
+}
+
+// handleAdminReplayReset handles POST /_jog/admin/replay/reset, which starts
+// replaying the recorded responses from the beginning, e.g. between tests.
+func (r *Router) handleAdminReplayReset(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+	if r.player == nil {
+		s3Err := *api.ErrInvalidRequest
+		s3Err.Message = "Replay mode is not enabled."
+		api.WriteError(w, &s3Err)
+		return
+	}
+	r.player.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// replayMiddleware answers S3 requests with the handler of the record/replay
+// mode, which proxies them to a real S3 endpoint or replays its recorded
+// responses, instead of routing them to the storage. Admin requests are
+// routed as usual.
+func replayMiddleware(handler http.Handler) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, adminPathPrefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/kms"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// RolePrimary serves reads and writes.
+	RolePrimary = "primary"
+	// RoleReplica serves reads from a copy of a primary's data directory and
+	// metadata database, kept up to date by an external sync tool.
+	RoleReplica = "replica"
+
+	// readyzPath is the path of the readiness endpoint.
+	readyzPath = "/readyz"
+)
+
+var replicationLag = metrics.NewGauge("jog_replication_lag_seconds",
+	"Age of the latest primary heartbeat in the metadata of a read replica.")
+
+// errReplicaReadOnly rejects writes on a read replica.
+var errReplicaReadOnly = func() *api.S3Error {
+	err := *api.ErrReadOnlyMode
+	err.Message = "The server is a read replica."
+	return &err
+}()
+
+// replicationHeartbeat is implemented by the storage backends keeping their
+// metadata in SQLite.
+type replicationHeartbeat interface {
+	WriteReplicationHeartbeat(ctx context.Context) error
+	ReplicationHeartbeat(ctx context.Context) (time.Time, error)
+}
+
+// parseRole parses a server role. An empty role is the primary role.
+func parseRole(role string) (string, error) {
+	switch role {
+	case "", RolePrimary:
+		return RolePrimary, nil
+	case RoleReplica:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown server role: %s", role)
+	}
+}
+
+// newReplicaStorage opens the synced copy of a primary's filesystem storage
+// read-only.
+func newReplicaStorage(cfg config.StorageConfig) (storage.Storage, error) {
+	if cfg.Backend != "" && cfg.Backend != "filesystem" {
+		return nil, fmt.Errorf("replicas require the filesystem storage backend")
+	}
+	etagMode, err := storage.ParseETagMode(cfg.EncryptedETags)
+	if err != nil {
+		return nil, err
+	}
+	pathEncoding, err := storage.ParsePathEncoding(cfg.PathEncoding)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := storage.ParseLayout(cfg.Layout)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := kms.New(cfg.KMS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize kms: %w", err)
+	}
+	store, err := storage.NewFileSystemFollower(cfg.DataDir, cfg.MetadataDB)
+	if err != nil {
+		return nil, err
+	}
+	store.SetEncryptedETagMode(etagMode)
+	store.SetPathEncoding(pathEncoding)
+	store.SetLayout(layout)
+	store.SetKMS(keys, cfg.KMS.DefaultKeyID)
+	return store, nil
+}
+
+// replicaFilter rejects requests that would write on a read replica. The
+// mode can still be changed, e.g. to maintenance.
+func replicaFilter(r *http.Request) error {
+	if isReadRequest(r) || r.URL.Path == adminPathPrefix+"mode" {
+		return nil
+	}
+	return errReplicaReadOnly
+}
+
+// heartbeatStore returns the storage of the default namespace, whose metadata
+// carries the replication heartbeats, or nil if it has no heartbeats.
+func (s *Server) heartbeatStore() replicationHeartbeat {
+	store := s.storage
+	if tenants, ok := store.(*storage.Tenants); ok {
+		store = tenants.Stores()[0]
+	}
+	hb, _ := store.(replicationHeartbeat)
+	return hb
+}
+
+// writeHeartbeat records a heartbeat in the metadata of a primary.
+func (s *Server) writeHeartbeat(ctx context.Context) (int, error) {
+	return 0, s.heartbeatStore().WriteReplicationHeartbeat(ctx)
+}
+
+// replicationLag returns how far the metadata of a replica is behind its
+// primary, as the age of the latest heartbeat.
+func (s *Server) replicationLag(ctx context.Context) (time.Duration, time.Time, error) {
+	hb := s.heartbeatStore()
+	if hb == nil {
+		return 0, time.Time{}, fmt.Errorf("storage backend has no replication heartbeats")
+	}
+	last, err := hb.ReplicationHeartbeat(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if last.IsZero() {
+		return 0, last, fmt.Errorf("no heartbeat of the primary found")
+	}
+	lag := max(time.Since(last), 0)
+	replicationLag.Set(int64(lag.Seconds()))
+	return lag, last, nil
+}
+
+// updateReplicationLag refreshes the replication lag metric of a replica.
+func (s *Server) updateReplicationLag(ctx context.Context) (int, error) {
+	_, _, err := s.replicationLag(ctx)
+	return 0, err
+}
+
+// readinessResponse is the JSON body of the readiness endpoint.
+type readinessResponse struct {
+	Status        string     `json:"status"`
+	Role          string     `json:"role"`
+	Term          int64      `json:"term,omitempty"`
+	LastHeartbeat *time.Time `json:"lastHeartbeat,omitempty"`
+	LagSeconds    *float64   `json:"lagSeconds,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// readiness reports whether the server should receive traffic. A replica is
+// not ready while its copy is more than the maximum lag behind the primary,
+// and an HA standby is not ready until it becomes the leader.
+func (s *Server) readiness(ctx context.Context) (readinessResponse, bool) {
+	resp := readinessResponse{Status: "ready", Role: s.role}
+	if s.election != nil {
+		// Load balancers send all traffic to the leader
+		resp.Role = "leader"
+		resp.Term = s.election.Term()
+		if !s.election.Leader() {
+			resp.Role = "standby"
+			resp.Status = "standby"
+			return resp, false
+		}
+		return resp, true
+	}
+	if s.role != RoleReplica {
+		return resp, true
+	}
+
+	lag, last, err := s.replicationLag(ctx)
+	if err != nil {
+		resp.Status = "unavailable"
+		resp.Error = err.Error()
+		return resp, false
+	}
+	seconds := lag.Seconds()
+	resp.LastHeartbeat = &last
+	resp.LagSeconds = &seconds
+	if maxLag := s.config.Replication.MaxLag; maxLag > 0 && lag > maxLag {
+		resp.Status = "lagging"
+		return resp, false
+	}
+	return resp, true
+}
+
+// readyzMiddleware serves unauthenticated GET /readyz requests for load
+// balancers. Signed requests still reach a bucket named "readyz".
+func (s *Server) readyzMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != readyzPath || r.URL.RawQuery != "" || !isReadRequest(r) || r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		resp, ready := s.readiness(r.Context())
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error().Err(err).Msg("Failed to encode readiness response")
+		}
+	})
+}
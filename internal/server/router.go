@@ -6,31 +6,169 @@ import (
 
 	"github.com/kumasuke/jog/internal/api"
 	"github.com/kumasuke/jog/internal/auth"
+	"github.com/kumasuke/jog/internal/jobs"
+	"github.com/kumasuke/jog/internal/replay"
+	"github.com/kumasuke/jog/internal/shadow"
+	"github.com/kumasuke/jog/internal/storage"
 )
 
+// adminPathPrefix is the path prefix of JOG admin endpoints.
+// Bucket names cannot start with an underscore, so it never collides with S3 requests.
+const adminPathPrefix = "/_jog/admin/"
+
+// unimplementedSubresources lists S3 subresources JOG does not implement.
+// Requests for them fail with NotImplemented instead of being served as plain
+// bucket or object requests.
+var unimplementedSubresources = []string{
+	"accelerate",
+	"analytics",
+	"intelligent-tiering",
+	"inventory",
+	"logging",
+	"metrics",
+	"notification",
+	"ownershipControls",
+	"policyStatus",
+	"publicAccessBlock",
+	"replication",
+	"requestPayment",
+	"restore",
+	"select",
+	"torrent",
+}
+
 // Router handles S3 API routing.
 type Router struct {
 	handler    *api.Handler
 	authMiddle auth.Authenticator
+	mode       *ModeSwitch
+	throttle   *Throttle
+	faults     *Faults
+	jobs       *jobs.Manager
+	shadow     *shadow.Mirror
+	player     *replay.Player
+	hooks      []Middleware
+	// accounts is nil when the storage backend cannot store service accounts.
+	accounts serviceAccountStore
+	// secrets is nil when the storage backend cannot store rotated access
+	// keys.
+	secrets accessKeyStore
+	// webIdentity is nil when STS requests are not served.
+	webIdentity *webIdentity
 }
 
 // NewRouter creates a new Router.
 func NewRouter(handler *api.Handler, authMiddle auth.Authenticator) *Router {
+	// Service accounts and rotated access keys are stored in the metadata
+	// database of the default namespace
+	store := handler.Storage()
+	if tenants, ok := store.(*storage.Tenants); ok {
+		store = tenants.Stores()[0]
+	}
+	accounts, _ := store.(serviceAccountStore)
+	secrets, _ := store.(accessKeyStore)
+
+	if m, ok := authMiddle.(*auth.Middleware); ok {
+		// Accept the credentials of directory bucket sessions
+		m.SetSessionStore(handler.Sessions())
+		if accounts != nil {
+			m.SetServiceAccounts(accounts)
+		}
+		if secrets != nil {
+			restoreSecrets(m, secrets)
+		}
+	}
 	return &Router{
 		handler:    handler,
 		authMiddle: authMiddle,
+		mode:       NewModeSwitch(),
+		throttle:   NewThrottle(),
+		faults:     NewFaults(),
+		jobs:       jobs.NewManager(handler.Storage()),
+		accounts:   accounts,
+		secrets:    secrets,
+	}
+}
+
+// Mode returns the switch controlling the server mode.
+func (r *Router) Mode() *ModeSwitch {
+	return r.mode
+}
+
+// Throttle returns the request limits of buckets.
+func (r *Router) Throttle() *Throttle {
+	return r.throttle
+}
+
+// Faults returns the fault injection rules.
+func (r *Router) Faults() *Faults {
+	return r.faults
+}
+
+// Jobs returns the manager running batch jobs.
+func (r *Router) Jobs() *jobs.Manager {
+	return r.jobs
+}
+
+// Use adds middleware that runs after the built-in middleware, just before the
+// request is routed. Hooks run in the order they were added. Use must not be
+// called while the router is serving requests.
+func (r *Router) Use(middleware ...Middleware) {
+	r.hooks = append(r.hooks, middleware...)
+}
+
+// AddFilter adds a hook that can reject requests before they are routed.
+func (r *Router) AddFilter(filter RequestFilter) {
+	r.Use(FilterMiddleware(filter))
+}
+
+// AddUploadValidator adds a hook that can reject PutObject uploads after
+// their data has been received and before it is stored. Like Use, it must not
+// be called while the router is serving requests.
+func (r *Router) AddUploadValidator(validator api.UploadValidator) {
+	r.handler.AddUploadValidator(validator)
+}
+
+// middleware returns the middleware chain, outermost first:
+//
+//  1. Request ID assigns the x-amz-request-id of the response.
+//  2. Recovery turns panics into 500 InternalError.
+//  3. Logging logs every request, including rejected ones.
+//  4. Authentication verifies the signature and attaches the tenant.
+//  5. Authorization evaluates identity and bucket policies.
+//  6. Mode rejects writes in the read-only and maintenance modes.
+//  7. Throttle rejects requests beyond the limits of their bucket.
+//  8. Faults injects latency and errors when fault injection is enabled.
+//  9. Hooks added with Use and AddFilter, in the order they were added.
+//  10. Expect continue answers Expect: 100-continue of accepted requests.
+//
+// CORS depends on the bucket configuration, so the S3 handlers evaluate it
+// after routing, as they do the policies of the keys of DeleteObjects and of
+// copy sources.
+func (r *Router) middleware() []Middleware {
+	chain := []Middleware{
+		RequestIDMiddleware,
+		RecoveryMiddleware,
+		LoggingMiddleware,
+		r.authMiddle.Wrap,
+		func(next http.Handler) http.Handler { return AuthorizeMiddleware(next, r.handler.Authorizer()) },
+		func(next http.Handler) http.Handler { return ModeMiddleware(next, r.mode) },
+		func(next http.Handler) http.Handler { return ThrottleMiddleware(next, r.throttle) },
+		func(next http.Handler) http.Handler { return FaultMiddleware(next, r.faults) },
 	}
+	chain = append(chain, r.hooks...)
+	return append(chain, ExpectContinueMiddleware)
 }
 
 // ServeHTTP handles HTTP requests.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	// Apply middleware
-	var handler http.Handler = r.routeRequest()
-	handler = r.authMiddle.Wrap(handler)
-	handler = LoggingMiddleware(handler)
-	handler = RecoveryMiddleware(handler)
-
-	handler.ServeHTTP(w, req)
+	if r.webIdentity != nil && isSTSRequest(req) {
+		// STS requests are authenticated by the ID token they carry, and
+		// neither read nor write buckets
+		Chain(http.HandlerFunc(r.handleSTS), RequestIDMiddleware, RecoveryMiddleware, LoggingMiddleware).ServeHTTP(w, req)
+		return
+	}
+	Chain(r.routeRequest(), r.middleware()...).ServeHTTP(w, req)
 }
 
 // routeRequest returns a handler that routes requests based on S3 API patterns.
@@ -39,6 +177,11 @@ func (r *Router) routeRequest() http.HandlerFunc {
 		path := req.URL.Path
 		query := req.URL.Query()
 
+		if strings.HasPrefix(path, adminPathPrefix) {
+			r.routeAdmin(w, req, strings.TrimPrefix(path, adminPathPrefix))
+			return
+		}
+
 		// Parse bucket and key from path
 		// S3 path-style: /{bucket} or /{bucket}/{key}
 		parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
@@ -56,6 +199,17 @@ func (r *Router) routeRequest() http.HandlerFunc {
 		req = api.WithBucket(req, bucket)
 		req = api.WithKey(req, key)
 
+		if bucket != "" {
+			for _, subresource := range unimplementedSubresources {
+				if query.Has(subresource) {
+					s3Err := *api.ErrNotImplemented
+					s3Err.Message = "The " + subresource + " subresource is not implemented."
+					api.WriteErrorWithResource(w, &s3Err, path)
+					return
+				}
+			}
+		}
+
 		switch req.Method {
 		case http.MethodGet:
 			if bucket == "" {
@@ -98,6 +252,39 @@ func (r *Router) routeRequest() http.HandlerFunc {
 				} else if query.Has("website") {
 					// GET /{bucket}?website - GetBucketWebsite
 					r.handler.GetBucketWebsite(w, req)
+				} else if query.Has("tiering") {
+					// GET /{bucket}?tiering - GetBucketTiering (JOG extension)
+					r.handler.GetBucketTiering(w, req)
+				} else if query.Has("compression") {
+					// GET /{bucket}?compression - GetBucketCompression (JOG extension)
+					r.handler.GetBucketCompression(w, req)
+				} else if query.Has("trash") {
+					// GET /{bucket}?trash - GetBucketTrash (JOG extension)
+					r.handler.GetBucketTrash(w, req)
+				} else if query.Has("version-retention") {
+					// GET /{bucket}?version-retention - GetBucketVersionRetention (JOG extension)
+					r.handler.GetBucketVersionRetention(w, req)
+				} else if query.Has("content-types") {
+					// GET /{bucket}?content-types - GetBucketContentTypes (JOG extension)
+					r.handler.GetBucketContentTypes(w, req)
+				} else if query.Has("default-tags") {
+					// GET /{bucket}?default-tags - GetBucketDefaultTags (JOG extension)
+					r.handler.GetBucketDefaultTags(w, req)
+				} else if query.Has("response-headers") {
+					// GET /{bucket}?response-headers - GetBucketResponseHeaders (JOG extension)
+					r.handler.GetBucketResponseHeaders(w, req)
+				} else if query.Has("skip-unchanged") {
+					// GET /{bucket}?skip-unchanged - GetBucketSkipUnchanged (JOG extension)
+					r.handler.GetBucketSkipUnchanged(w, req)
+				} else if query.Has("worm") {
+					// GET /{bucket}?worm - GetBucketWorm (JOG extension)
+					r.handler.GetBucketWorm(w, req)
+				} else if query.Has("session") {
+					// GET /{bucket}?session - CreateSession
+					r.handler.CreateSession(w, req)
+				} else if query.Has("search") {
+					// GET /{bucket}?search - SearchObjects (JOG extension)
+					r.handler.SearchObjects(w, req)
 				} else if query.Get("list-type") == "2" {
 					// GET /{bucket}?list-type=2 - ListObjectsV2
 					r.handler.ListObjectsV2(w, req)
@@ -157,6 +344,33 @@ func (r *Router) routeRequest() http.HandlerFunc {
 				} else if query.Has("website") {
 					// PUT /{bucket}?website - PutBucketWebsite
 					r.handler.PutBucketWebsite(w, req)
+				} else if query.Has("tiering") {
+					// PUT /{bucket}?tiering - PutBucketTiering (JOG extension)
+					r.handler.PutBucketTiering(w, req)
+				} else if query.Has("compression") {
+					// PUT /{bucket}?compression - PutBucketCompression (JOG extension)
+					r.handler.PutBucketCompression(w, req)
+				} else if query.Has("trash") {
+					// PUT /{bucket}?trash - PutBucketTrash (JOG extension)
+					r.handler.PutBucketTrash(w, req)
+				} else if query.Has("version-retention") {
+					// PUT /{bucket}?version-retention - PutBucketVersionRetention (JOG extension)
+					r.handler.PutBucketVersionRetention(w, req)
+				} else if query.Has("content-types") {
+					// PUT /{bucket}?content-types - PutBucketContentTypes (JOG extension)
+					r.handler.PutBucketContentTypes(w, req)
+				} else if query.Has("default-tags") {
+					// PUT /{bucket}?default-tags - PutBucketDefaultTags (JOG extension)
+					r.handler.PutBucketDefaultTags(w, req)
+				} else if query.Has("response-headers") {
+					// PUT /{bucket}?response-headers - PutBucketResponseHeaders (JOG extension)
+					r.handler.PutBucketResponseHeaders(w, req)
+				} else if query.Has("skip-unchanged") {
+					// PUT /{bucket}?skip-unchanged - PutBucketSkipUnchanged (JOG extension)
+					r.handler.PutBucketSkipUnchanged(w, req)
+				} else if query.Has("worm") {
+					// PUT /{bucket}?worm - PutBucketWorm (JOG extension)
+					r.handler.PutBucketWorm(w, req)
 				} else {
 					// PUT /{bucket} - CreateBucket
 					r.handler.CreateBucket(w, req)
@@ -183,6 +397,9 @@ func (r *Router) routeRequest() http.HandlerFunc {
 				} else if query.Has("legal-hold") {
 					// PUT /{bucket}/{key}?legal-hold - PutObjectLegalHold
 					r.handler.PutObjectLegalHold(w, req)
+				} else if query.Has("append") {
+					// PUT /{bucket}/{key}?append&position={position} - AppendObject (JOG extension)
+					r.handler.AppendObject(w, req)
 				} else if req.Header.Get("x-amz-copy-source") != "" {
 					// PUT /{bucket}/{key} with x-amz-copy-source - CopyObject
 					r.handler.CopyObject(w, req)
@@ -236,8 +453,29 @@ func (r *Router) routeRequest() http.HandlerFunc {
 				} else if query.Has("website") {
 					// DELETE /{bucket}?website - DeleteBucketWebsite
 					r.handler.DeleteBucketWebsite(w, req)
+				} else if query.Has("tiering") {
+					// DELETE /{bucket}?tiering - DeleteBucketTiering (JOG extension)
+					r.handler.DeleteBucketTiering(w, req)
+				} else if query.Has("compression") {
+					// DELETE /{bucket}?compression - DeleteBucketCompression (JOG extension)
+					r.handler.DeleteBucketCompression(w, req)
+				} else if query.Has("trash") {
+					// DELETE /{bucket}?trash - DeleteBucketTrash (JOG extension)
+					r.handler.DeleteBucketTrash(w, req)
+				} else if query.Has("version-retention") {
+					// DELETE /{bucket}?version-retention - DeleteBucketVersionRetention (JOG extension)
+					r.handler.DeleteBucketVersionRetention(w, req)
+				} else if query.Has("content-types") {
+					// DELETE /{bucket}?content-types - DeleteBucketContentTypes (JOG extension)
+					r.handler.DeleteBucketContentTypes(w, req)
+				} else if query.Has("default-tags") {
+					// DELETE /{bucket}?default-tags - DeleteBucketDefaultTags (JOG extension)
+					r.handler.DeleteBucketDefaultTags(w, req)
+				} else if query.Has("response-headers") {
+					// DELETE /{bucket}?response-headers - DeleteBucketResponseHeaders (JOG extension)
+					r.handler.DeleteBucketResponseHeaders(w, req)
 				} else {
-					// DELETE /{bucket} - DeleteBucket
+					// DELETE /{bucket} - DeleteBucket (?force=true empties it first)
 					r.handler.DeleteBucket(w, req)
 				}
 			} else if bucket != "" && key != "" {
@@ -260,8 +498,13 @@ func (r *Router) routeRequest() http.HandlerFunc {
 				// HEAD /{bucket} - HeadBucket
 				r.handler.HeadBucket(w, req)
 			} else if bucket != "" && key != "" {
-				// HEAD /{bucket}/{key} - HeadObject
-				r.handler.HeadObject(w, req)
+				if query.Has("partNumber") && query.Has("uploadId") {
+					// HEAD /{bucket}/{key}?partNumber={partNumber}&uploadId={uploadId} - HeadPartUpload (JOG extension)
+					r.handler.HeadPartUpload(w, req)
+				} else {
+					// HEAD /{bucket}/{key} - HeadObject
+					r.handler.HeadObject(w, req)
+				}
 			} else {
 				api.WriteError(w, api.ErrInvalidRequest)
 			}
@@ -279,3 +522,95 @@ func (r *Router) routeRequest() http.HandlerFunc {
 		}
 	}
 }
+
+// routeAdmin routes JOG admin requests.
+func (r *Router) routeAdmin(w http.ResponseWriter, req *http.Request, endpoint string) {
+	switch endpoint {
+	case "mode":
+		// GET/PUT /_jog/admin/mode - Get or change the server mode
+		r.handleAdminMode(w, req)
+	case "metrics":
+		// GET /_jog/admin/metrics - Server metrics in the Prometheus text format
+		r.handleAdminMetrics(w, req)
+	case "events":
+		// GET /_jog/admin/events - Stream object events as Server-Sent Events
+		r.handleAdminEvents(w, req)
+	case "jobs":
+		// GET /_jog/admin/jobs - List batch jobs
+		// POST /_jog/admin/jobs - Submit a batch job
+		r.handleAdminJobs(w, req)
+	case "shadow":
+		// GET /_jog/admin/shadow - Divergence report of the shadow secondary
+		r.handleAdminShadow(w, req)
+	case "replay/reset":
+		// POST /_jog/admin/replay/reset - Replay the recorded responses from the beginning
+		r.handleAdminReplayReset(w, req)
+	case "faults":
+		// GET/PUT/DELETE /_jog/admin/faults - Get or change the fault injection rules
+		r.handleAdminFaults(w, req)
+	case "service-accounts":
+		// GET /_jog/admin/service-accounts - List the service accounts of the caller
+		// POST /_jog/admin/service-accounts - Mint a service account
+		r.handleAdminServiceAccounts(w, req)
+	default:
+		if id, ok := strings.CutPrefix(endpoint, "jobs/"); ok && id != "" {
+			// GET /_jog/admin/jobs/{id} - Get the status of a batch job
+			// DELETE /_jog/admin/jobs/{id} - Cancel a batch job
+			r.handleAdminJob(w, req, id)
+			return
+		}
+		if accessKey, ok := strings.CutPrefix(endpoint, "service-accounts/"); ok && accessKey != "" && !strings.Contains(accessKey, "/") {
+			// GET /_jog/admin/service-accounts/{accessKey} - Get a service account
+			// DELETE /_jog/admin/service-accounts/{accessKey} - Delete a service account
+			r.handleAdminServiceAccount(w, req, accessKey)
+			return
+		}
+		if accessKey, ok := strings.CutPrefix(endpoint, "access-keys/"); ok {
+			if accessKey, ok := strings.CutSuffix(accessKey, "/rotate"); ok && accessKey != "" && !strings.Contains(accessKey, "/") {
+				// POST /_jog/admin/access-keys/{accessKey}/rotate - Rotate the secret key of an access key
+				r.handleAdminAccessKeyRotate(w, req, accessKey)
+				return
+			}
+			if accessKey, ok := strings.CutSuffix(accessKey, "/previous"); ok && accessKey != "" && !strings.Contains(accessKey, "/") {
+				// DELETE /_jog/admin/access-keys/{accessKey}/previous - Stop accepting the previous secret key
+				r.handleAdminAccessKeyPrevious(w, req, accessKey)
+				return
+			}
+			if accessKey != "" && !strings.Contains(accessKey, "/") {
+				// GET /_jog/admin/access-keys/{accessKey} - Rotation status of an access key
+				r.handleAdminAccessKey(w, req, accessKey)
+				return
+			}
+		}
+		if bucket, ok := strings.CutPrefix(endpoint, "buckets/"); ok {
+			if bucket, ok := strings.CutSuffix(bucket, "/stats"); ok && bucket != "" && !strings.Contains(bucket, "/") {
+				// GET /_jog/admin/buckets/{name}/stats - Usage statistics of a bucket
+				r.handleAdminBucketStats(w, req, bucket)
+				return
+			}
+			if bucket, ok := strings.CutSuffix(bucket, "/limits"); ok && bucket != "" && !strings.Contains(bucket, "/") {
+				// GET, PUT, DELETE /_jog/admin/buckets/{name}/limits - Request limits of a bucket
+				r.handleAdminBucketLimits(w, req, bucket)
+				return
+			}
+			if bucket, ok := strings.CutSuffix(bucket, "/usage-history"); ok && bucket != "" && !strings.Contains(bucket, "/") {
+				// GET /_jog/admin/buckets/{name}/usage-history - Daily usage records of a bucket
+				r.handleAdminBucketUsageHistory(w, req, bucket)
+				return
+			}
+			if bucket, ok := strings.CutSuffix(bucket, "/trash"); ok && bucket != "" && !strings.Contains(bucket, "/") {
+				// GET /_jog/admin/buckets/{name}/trash - List the trash of a bucket
+				r.handleAdminBucketTrash(w, req, bucket)
+				return
+			}
+			if rest, ok := strings.CutSuffix(bucket, "/restore"); ok {
+				if bucket, id, ok := strings.Cut(rest, "/trash/"); ok && bucket != "" && id != "" && !strings.Contains(bucket, "/") && !strings.Contains(id, "/") {
+					// POST /_jog/admin/buckets/{name}/trash/{id}/restore - Restore an object from the trash
+					r.handleAdminRestoreTrash(w, req, bucket, id)
+					return
+				}
+			}
+		}
+		api.WriteError(w, api.ErrInvalidRequest)
+	}
+}
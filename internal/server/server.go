@@ -3,61 +3,851 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"time"
 
 	"github.com/kumasuke/jog/internal/api"
 	"github.com/kumasuke/jog/internal/auth"
+	"github.com/kumasuke/jog/internal/cluster"
 	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+	"github.com/kumasuke/jog/internal/kms"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/kumasuke/jog/internal/notify"
+	"github.com/kumasuke/jog/internal/oidc"
+	"github.com/kumasuke/jog/internal/replay"
+	"github.com/kumasuke/jog/internal/shadow"
 	"github.com/kumasuke/jog/internal/storage"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
 )
 
+var (
+	uploadsAborted = metrics.NewCounter("jog_multipart_uploads_aborted_total",
+		"Stale multipart uploads aborted by the background cleanup.")
+	uploadBytesReclaimed = metrics.NewCounter("jog_multipart_reclaimed_bytes_total",
+		"Bytes of parts reclaimed by aborting stale multipart uploads.")
+	objectsScrubbed = metrics.NewCounter("jog_scrub_objects_verified_total",
+		"Objects re-read and verified by the integrity scrubber.")
+	bytesScrubbed = metrics.NewCounter("jog_scrub_bytes_read_total",
+		"Bytes of object data read by the integrity scrubber.")
+	objectsCorrupted = metrics.NewCounter("jog_scrub_corrupted_objects_total",
+		"Objects found by the integrity scrubber not to match their checksums.")
+)
+
+// validTenantName matches tenant names, which are used as directory names.
+var validTenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
 // Server represents the JOG HTTP server.
 type Server struct {
 	httpServer *http.Server
-	storage    storage.Storage
-	config     *config.Config
+	// controlServer serves the gRPC control service; nil if it is disabled.
+	controlServer *grpc.Server
+	// clusterNode is the local node in cluster mode; nil otherwise.
+	clusterNode *cluster.Node
+	router      *Router
+	notifier    *notify.Notifier
+	mirror      *shadow.Mirror
+	watchers    []*storage.DataDirWatcher
+	storage     storage.Storage
+	config      *config.Config
+	// recorder records the exchanges of the record mode; nil otherwise.
+	recorder *replay.Recorder
+	// election is the leader election in HA mode; nil otherwise.
+	election *election
+	// role is RolePrimary or RoleReplica.
+	role string
+	stop chan struct{}
 }
 
 // New creates a new Server instance.
 func New(cfg *config.Config) (*Server, error) {
+	mode, err := ParseMode(cfg.Server.Mode)
+	if err != nil {
+		return nil, err
+	}
+	role, err := parseRole(cfg.Server.Role)
+	if err != nil {
+		return nil, err
+	}
+	defaultLimits := bucketLimitsFromConfig(cfg.Server.BucketLimits.Default)
+	if err := defaultLimits.validate(); err != nil {
+		return nil, err
+	}
+	for bucket, limits := range cfg.Server.BucketLimits.Buckets {
+		if err := bucketLimitsFromConfig(limits).validate(); err != nil {
+			return nil, fmt.Errorf("bucket %s: %w", bucket, err)
+		}
+	}
+	faultRules, err := faultRulesFromConfig(cfg.Server.Faults.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server.faults: %w", err)
+	}
+	switch cfg.RecordReplay.Mode {
+	case "", replay.ModeRecord, replay.ModeReplay:
+	default:
+		return nil, fmt.Errorf("unknown record_replay.mode: %s", cfg.RecordReplay.Mode)
+	}
+	if cfg.RecordReplay.Mode != "" && cfg.Cluster.Enabled {
+		return nil, fmt.Errorf("record/replay mode cannot be combined with cluster mode")
+	}
+	if role == RoleReplica && cfg.Cluster.Enabled {
+		return nil, fmt.Errorf("replicas cannot join a cluster")
+	}
+	if cfg.HA.Enabled && (role == RoleReplica || cfg.Cluster.Enabled) {
+		return nil, fmt.Errorf("HA mode cannot be combined with replicas or cluster mode")
+	}
+	if cfg.Storage.Watch.Enabled && role == RoleReplica {
+		return nil, fmt.Errorf("replicas cannot watch their data directory")
+	}
+	if err := CheckProtocols(cfg.Server); err != nil {
+		return nil, err
+	}
+	trustedProxies, err := ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server.trusted_proxies: %w", err)
+	}
+	contentTypes, err := storage.NewContentTypeTable(cfg.Storage.ContentTypes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage.content_types: %w", err)
+	}
+	mfaDevices, err := newMFADevices(cfg.Auth.MFADevices)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth.mfa_devices: %w", err)
+	}
+	namedPolicies, err := NamedPolicies(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth.policies: %w", err)
+	}
+	identityPolicies, err := IdentityPolicies(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth.policies: %w", err)
+	}
+	webIdentityProvider, err := oidc.New(cfg.Auth.OIDC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth.oidc: %w", err)
+	}
+	var prePut api.UploadValidator
+	if cfg.Hooks.PrePut.URL != "" {
+		prePut, err = NewPrePutHook(cfg.Hooks.PrePut)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hooks.pre_put: %w", err)
+		}
+	}
+
+	var clusterNode *cluster.Node
+	if cfg.Cluster.Enabled {
+		clusterNode, err = newClusterNode(cfg.Cluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize cluster: %w", err)
+		}
+	}
+
 	// Initialize storage
-	store, err := storage.NewFileSystem(cfg.Storage.DataDir, cfg.Storage.MetadataDB)
+	var store storage.Storage
+	if role == RoleReplica {
+		store, err = newReplicaStorage(cfg.Storage)
+	} else {
+		store, err = NewStorage(cfg.Storage)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
+	if len(cfg.Auth.Tenants) > 0 {
+		store, err = newTenantStorage(store, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize tenants: %w", err)
+		}
+	}
+
+	var election *election
+	if cfg.HA.Enabled {
+		election, err = newElection(store, cfg.HA)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("failed to initialize HA: %w", err)
+		}
+	}
 
 	// Create API handler
 	apiHandler := api.NewHandler(store)
+	apiHandler.SetContentTypes(contentTypes)
+	apiHandler.SetMFADevices(mfaDevices)
+	apiHandler.SetRegion(cfg.Server.Region)
+	apiHandler.SetUploadSpoolDir(cfg.Hooks.PrePut.SpoolDir)
+	apiHandler.Authorizer().SetAdmin(cfg.Auth.AccessKey)
+	for accessKey, docs := range identityPolicies {
+		apiHandler.Authorizer().Attach(accessKey, docs...)
+	}
+	if prePut != nil {
+		apiHandler.AddUploadValidator(prePut)
+	}
+
+	// Deliver object events to the configured notification targets
+	notifier, err := notify.Start(apiHandler.Events(), cfg.Notifications)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to initialize notifications: %w", err)
+	}
+
+	// Mirror writes to the secondary being migrated from
+	var mirror *shadow.Mirror
+	if cfg.Shadow.Enabled {
+		mirror, err = shadow.Start(apiHandler.Events(), store, cfg.Shadow)
+		if err != nil {
+			notifier.Close()
+			store.Close()
+			return nil, fmt.Errorf("failed to initialize shadow mode: %w", err)
+		}
+	}
+
+	// Serve S3 requests from a real S3 endpoint or its recordings
+	var recorder *replay.Recorder
+	var player *replay.Player
+	switch cfg.RecordReplay.Mode {
+	case replay.ModeRecord:
+		recorder, err = replay.NewRecorder(cfg.RecordReplay)
+	case replay.ModeReplay:
+		if player, err = replay.LoadPlayer(cfg.RecordReplay.File); err == nil {
+			log.Info().Int("exchanges", player.Len()).Str("file", cfg.RecordReplay.File).Msg("Replaying recorded S3 responses")
+		}
+	}
+	if err != nil {
+		if mirror != nil {
+			mirror.Close()
+		}
+		notifier.Close()
+		store.Close()
+		return nil, fmt.Errorf("failed to initialize record/replay mode: %w", err)
+	}
 
 	// Create auth middleware
 	authMiddleware := auth.NewMiddleware(cfg.Auth.AccessKey, cfg.Auth.SecretKey)
+	authMiddleware.SetCredentialScope(cfg.Auth.Regions, cfg.Auth.Services)
+	authMiddleware.SetPresignCache(cfg.Auth.PresignCache.Size, cfg.Auth.PresignCache.TTL)
+	for _, tenant := range cfg.Auth.Tenants {
+		authMiddleware.AddTenantCredential(tenant.AccessKey, tenant.SecretKey, tenant.Name)
+	}
+	for _, user := range cfg.Auth.Users {
+		authMiddleware.AddTenantCredential(user.AccessKey, user.SecretKey, "")
+	}
 
 	// Create router
 	router := NewRouter(apiHandler, authMiddleware)
+	if role == RoleReplica {
+		router.AddFilter(RequestFilterFunc(replicaFilter))
+		if mode == ModeNormal {
+			mode = ModeReadOnly
+		}
+	}
+	router.Mode().Set(mode)
+	router.Throttle().SetDefault(defaultLimits)
+	for bucket, limits := range cfg.Server.BucketLimits.Buckets {
+		router.Throttle().Set(bucket, bucketLimitsFromConfig(limits))
+	}
+	router.Faults().Set(cfg.Server.Faults.Enabled, faultRules)
+	if cfg.Server.Faults.Enabled {
+		log.Warn().Int("rules", len(faultRules)).Msg("Fault injection is enabled")
+	}
+	if webIdentityProvider != nil {
+		router.SetWebIdentity(webIdentityProvider, cfg.Auth.OIDC, namedPolicies)
+		log.Info().Str("issuer", cfg.Auth.OIDC.Issuer).Msg("Exchanging ID tokens for temporary credentials")
+	}
+	if mirror != nil {
+		router.SetShadow(mirror)
+		if cfg.Shadow.CompareReads {
+			router.Use(shadowCompareMiddleware(mirror))
+		}
+	}
+
+	if recorder != nil {
+		router.Use(replayMiddleware(recorder))
+	}
+	if player != nil {
+		router.SetReplay(player)
+		router.Use(replayMiddleware(player))
+	}
+
+	// In cluster mode, requests are routed to the node owning the object
+	var handler http.Handler = router
+	if clusterNode != nil {
+		handler = cluster.NewHandler(clusterNode, router)
+	}
+
+	s := &Server{
+		clusterNode: clusterNode,
+		election:    election,
+		router:      router,
+		notifier:    notifier,
+		mirror:      mirror,
+		recorder:    recorder,
+		storage:     store,
+		config:      cfg,
+		role:        role,
+		stop:        make(chan struct{}),
+	}
+
+	if election != nil {
+		router.AddFilter(RequestFilterFunc(s.standbyFilter))
+	}
 
 	// Create HTTP server
-	httpServer := &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Address, cfg.Server.Port),
-		Handler:      router,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  120 * time.Second,
-	}
-
-	return &Server{
-		httpServer: httpServer,
-		storage:    store,
-		config:     cfg,
+	serverHandler := Chain(handler,
+		TrustedProxyMiddleware(trustedProxies),
+		HeaderLimitMiddleware(cfg.Server.MaxHeaderCount),
+		BodyTimeoutMiddleware(cfg.Server.BodyTimeout, cfg.Server.BodyIdleTimeout),
+		s.readyzMiddleware,
+	)
+	s.httpServer = &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", cfg.Server.Address, cfg.Server.Port),
+		Handler:           serverHandler,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+	ConfigureProtocols(s.httpServer, cfg.Server)
+	// End event streams, which would otherwise keep Shutdown waiting
+	s.httpServer.RegisterOnShutdown(apiHandler.Events().Close)
+
+	if cfg.Server.GRPCPort > 0 {
+		if s.controlServer, err = newControlServer(s, authMiddleware); err != nil {
+			return nil, err
+		}
+	}
+
+	s.startBackgroundJobs()
+
+	return s, nil
+}
+
+// newMFADevices creates the verifier of the configured MFA devices.
+func newMFADevices(devices []config.MFADeviceConfig) (*api.MFADevices, error) {
+	secrets := make(map[string]string, len(devices))
+	for _, device := range devices {
+		if _, ok := secrets[device.Serial]; ok {
+			return nil, fmt.Errorf("duplicate MFA device %q", device.Serial)
+		}
+		secrets[device.Serial] = device.Secret
+	}
+	return api.NewMFADevices(secrets)
+}
+
+// NewStorage creates the storage backend selected by the configuration.
+func NewStorage(cfg config.StorageConfig) (storage.Storage, error) {
+	etagMode, err := storage.ParseETagMode(cfg.EncryptedETags)
+	if err != nil {
+		return nil, err
+	}
+	pathEncoding, err := storage.ParsePathEncoding(cfg.PathEncoding)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := storage.ParseLayout(cfg.Layout)
+	if err != nil {
+		return nil, err
+	}
+	versionIDFormat, err := storage.ParseVersionIDFormat(cfg.VersionIDFormat)
+	if err != nil {
+		return nil, err
+	}
+	if pathEncoding == storage.PathEncodingPlain && (runtime.GOOS == "windows" || runtime.GOOS == "darwin") {
+		log.Warn().Msg("Keys that differ only by case or contain characters reserved by the filesystem may collide or fail; set storage.path_encoding to portable for new data directories")
+	}
+	compression, err := compressionPolicy(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := kms.New(cfg.KMS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize kms: %w", err)
+	}
+	if keys != nil && cfg.Backend != "" && cfg.Backend != "filesystem" {
+		return nil, fmt.Errorf("encryption at rest requires the filesystem storage backend")
+	}
+
+	store, err := newBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// All backends are built on the filesystem backend
+	if s, ok := store.(interface{ SetEncryptedETagMode(storage.ETagMode) }); ok {
+		s.SetEncryptedETagMode(etagMode)
+	}
+	if s, ok := store.(interface{ SetPathEncoding(storage.PathEncoding) }); ok {
+		s.SetPathEncoding(pathEncoding)
+	}
+	if s, ok := store.(interface{ SetLayout(storage.Layout) }); ok {
+		s.SetLayout(layout)
+	}
+	if s, ok := store.(interface{ SetVersionIDFormat(storage.VersionIDFormat) }); ok {
+		s.SetVersionIDFormat(versionIDFormat)
+	}
+	if s, ok := store.(interface {
+		SetSlowQueryLog(time.Duration, func(storage.SlowQuery))
+	}); ok {
+		s.SetSlowQueryLog(cfg.SlowQueryThreshold, logSlowQuery)
+	}
+	// Backends storing data elsewhere read local object files as is
+	if fs, ok := store.(*storage.FileSystem); ok {
+		fs.SetCompressionPolicy(compression)
+		fs.SetKMS(keys, cfg.KMS.DefaultKeyID)
+	}
+	return store, nil
+}
+
+// logSlowQuery logs a metadata statement slower than storage.slow_query_threshold.
+func logSlowQuery(q storage.SlowQuery) {
+	log.Warn().Str("statement", q.Statement).Dur("duration", q.Duration).Str("query", q.Query).Msg("Slow metadata query")
+}
+
+// compressionPolicy converts the compression settings of the filesystem backend.
+func compressionPolicy(cfg config.CompressionConfig) (storage.CompressionPolicy, error) {
+	algorithm, err := storage.ParseCompressionAlgorithm(cfg.Algorithm)
+	if err != nil {
+		return storage.CompressionPolicy{}, err
+	}
+	return storage.CompressionPolicy{
+		Algorithm:    algorithm,
+		ContentTypes: cfg.ContentTypes,
+		MinSize:      cfg.MinSize,
 	}, nil
 }
 
+// newBackend creates the storage backend named by cfg.Backend.
+func newBackend(cfg config.StorageConfig) (storage.Storage, error) {
+	switch cfg.Backend {
+	case "", "filesystem":
+		return storage.NewFileSystem(cfg.DataDir, cfg.MetadataDB)
+	case "tiered":
+		remote, err := newBlobStore(cfg.Tiered.Remote, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewTiered(cfg.DataDir, cfg.MetadataDB, remote, storage.TieringConfiguration{
+			SizeThreshold: cfg.Tiered.SizeThreshold,
+			ColdAfterDays: cfg.Tiered.ColdAfterDays,
+		})
+	case "erasure":
+		blobs, err := storage.NewErasureBlobStore(cfg.Erasure.Dirs, cfg.Erasure.DataShards, cfg.Erasure.ParityShards)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewPassthrough(cfg.DataDir, cfg.MetadataDB, blobs)
+	case "dedup":
+		mode, err := storage.ParseChunkingMode(cfg.Dedup.Chunking)
+		if err != nil {
+			return nil, err
+		}
+		dir := cfg.Dedup.Dir
+		if dir == "" {
+			dir = filepath.Join(cfg.DataDir, ".dedup")
+		}
+		blobs, err := storage.NewDedupBlobStore(dir, mode, cfg.Dedup.ChunkSize)
+		if err != nil {
+			return nil, err
+		}
+		store, err := storage.NewPassthrough(cfg.DataDir, cfg.MetadataDB, blobs)
+		if err != nil {
+			blobs.Close()
+			return nil, err
+		}
+		return store, nil
+	default:
+		blobs, err := newBlobStore(cfg.Backend, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewPassthrough(cfg.DataDir, cfg.MetadataDB, blobs)
+	}
+}
+
+// newTenantStorage wraps the default namespace with one storage per configured
+// tenant. Each tenant gets its own data directory and metadata database below
+// <data_dir>/.tenants/<name>, which cannot collide with bucket directories.
+func newTenantStorage(defaultStore storage.Storage, cfg *config.Config) (storage.Storage, error) {
+	if cfg.Storage.Backend != "" && cfg.Storage.Backend != "filesystem" {
+		defaultStore.Close()
+		return nil, fmt.Errorf("tenants require the filesystem storage backend")
+	}
+
+	tenants := make(map[string]storage.Storage)
+	closeAll := func() {
+		defaultStore.Close()
+		for _, store := range tenants {
+			store.Close()
+		}
+	}
+
+	keys, err := kms.New(cfg.Storage.KMS)
+	if err != nil {
+		closeAll()
+		return nil, fmt.Errorf("failed to initialize kms: %w", err)
+	}
+
+	accessKeys := map[string]bool{cfg.Auth.AccessKey: true}
+	for _, tenant := range cfg.Auth.Tenants {
+		if !validTenantName.MatchString(tenant.Name) {
+			closeAll()
+			return nil, fmt.Errorf("invalid tenant name %q", tenant.Name)
+		}
+		if tenant.AccessKey == "" || accessKeys[tenant.AccessKey] {
+			closeAll()
+			return nil, fmt.Errorf("tenant %s: access key must be set and unique", tenant.Name)
+		}
+		accessKeys[tenant.AccessKey] = true
+
+		// Several access keys may share a tenant
+		if _, ok := tenants[tenant.Name]; ok {
+			continue
+		}
+		dir := filepath.Join(cfg.Storage.DataDir, ".tenants", tenant.Name)
+		open := storage.NewFileSystem
+		if cfg.Server.Role == RoleReplica {
+			open = storage.NewFileSystemFollower
+		}
+		store, err := open(dir, filepath.Join(dir, "metadata.db"))
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		// The modes and policy were validated when the default store was created
+		etagMode, _ := storage.ParseETagMode(cfg.Storage.EncryptedETags)
+		store.SetEncryptedETagMode(etagMode)
+		pathEncoding, _ := storage.ParsePathEncoding(cfg.Storage.PathEncoding)
+		store.SetPathEncoding(pathEncoding)
+		layout, _ := storage.ParseLayout(cfg.Storage.Layout)
+		store.SetLayout(layout)
+		versionIDFormat, _ := storage.ParseVersionIDFormat(cfg.Storage.VersionIDFormat)
+		store.SetVersionIDFormat(versionIDFormat)
+		compression, _ := compressionPolicy(cfg.Storage.Compression)
+		store.SetCompressionPolicy(compression)
+		store.SetSlowQueryLog(cfg.Storage.SlowQueryThreshold, logSlowQuery)
+		store.SetKMS(keys, cfg.Storage.KMS.DefaultKeyID)
+		tenants[tenant.Name] = store
+	}
+
+	return storage.NewTenants(defaultStore, tenants), nil
+}
+
+// newBlobStore creates the remote blob store for the given backend name,
+// wrapped in a local object cache if enabled.
+func newBlobStore(backend string, cfg config.StorageConfig) (storage.BlobStore, error) {
+	var blobs storage.BlobStore
+	var err error
+
+	switch backend {
+	case "azure":
+		blobs, err = storage.NewAzureBlobStore(cfg.Azure.AccountName, cfg.Azure.AccountKey, cfg.Azure.Container, cfg.Azure.Endpoint)
+	case "gcs":
+		blobs, err = storage.NewGCSBlobStore(cfg.GCS.Bucket, cfg.GCS.CredentialsFile, cfg.GCS.Endpoint)
+	case "s3":
+		blobs, err = storage.NewS3BlobStore(cfg.S3.Endpoint, cfg.S3.Region, cfg.S3.Bucket, cfg.S3.AccessKey, cfg.S3.SecretKey)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Cache.Enabled {
+		dir := cfg.Cache.Dir
+		if dir == "" {
+			dir = filepath.Join(cfg.DataDir, ".cache")
+		}
+		blobs, err = storage.NewCachingBlobStore(blobs, dir, cfg.Cache.MaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create object cache: %w", err)
+		}
+	}
+
+	return blobs, nil
+}
+
+// startBackgroundJobs starts the periodic maintenance jobs of the storage backend.
+func (s *Server) startBackgroundJobs() {
+	cfg := s.config.Storage
+
+	// Replicas only read; the primary maintains the data
+	if s.role == RoleReplica {
+		if interval := s.config.Replication.HeartbeatInterval; interval > 0 {
+			go s.runPeriodically("replication-lag", interval, s.updateReplicationLag)
+		}
+		return
+	}
+	if interval := s.config.Replication.HeartbeatInterval; interval > 0 && s.heartbeatStore() != nil {
+		go s.runPeriodically("replication-heartbeat", interval, s.writeHeartbeat)
+	}
+
+	switch store := s.storage.(type) {
+	case *storage.Tiered:
+		if cfg.Tiered.MigrateInterval > 0 {
+			go s.runPeriodically("migrate-cold-objects", cfg.Tiered.MigrateInterval, store.MigrateColdObjects)
+		}
+	case *storage.Passthrough:
+		if erasure, ok := store.BlobStore().(*storage.ErasureBlobStore); ok && cfg.Erasure.RepairInterval > 0 {
+			go s.runPeriodically("repair-erasure-shards", cfg.Erasure.RepairInterval, erasure.Repair)
+		}
+	}
+
+	if cfg.Multipart.AbortAfterDays > 0 && cfg.Multipart.CleanupInterval > 0 {
+		go s.runPeriodically("abort-stale-uploads", cfg.Multipart.CleanupInterval, s.abortStaleUploads)
+	}
+	if cfg.Scrub.Interval > 0 && cfg.Scrub.MaxObjects > 0 {
+		go s.runPeriodically("scrub-objects", cfg.Scrub.Interval, s.scrubObjects)
+	}
+	if cfg.Trash.PurgeInterval > 0 {
+		go s.runPeriodically("purge-trash", cfg.Trash.PurgeInterval, s.purgeTrash)
+	}
+	if cfg.EmptyDirs.PruneInterval > 0 {
+		go s.runPeriodically("prune-empty-dirs", cfg.EmptyDirs.PruneInterval, s.pruneEmptyDirs)
+	}
+	if cfg.TempFiles.MaxAge > 0 {
+		// Temporary files of writes interrupted by a crash are reclaimed at
+		// startup, not only after the first interval
+		go s.runJob("remove-stale-temp-files", s.removeStaleTempFiles)
+		if cfg.TempFiles.ScanInterval > 0 {
+			go s.runPeriodically("remove-stale-temp-files", cfg.TempFiles.ScanInterval, s.removeStaleTempFiles)
+		}
+	}
+	if cfg.Versions.PruneInterval > 0 {
+		go s.runPeriodically("prune-noncurrent-versions", cfg.Versions.PruneInterval, s.pruneNoncurrentVersions)
+	}
+	if cfg.UsageHistory.Interval > 0 {
+		// Servers restarted more often than the interval still record every day
+		go s.runJob("record-usage-history", s.recordUsageHistory)
+		go s.runPeriodically("record-usage-history", cfg.UsageHistory.Interval, s.recordUsageHistory)
+	}
+}
+
+// abortStaleUploads aborts multipart uploads older than the configured age in
+// every tenant namespace.
+func (s *Server) abortStaleUploads(ctx context.Context) (int, error) {
+	stores := []storage.Storage{s.storage}
+	if tenants, ok := s.storage.(*storage.Tenants); ok {
+		stores = tenants.Stores()
+	}
+
+	olderThan := time.Duration(s.config.Storage.Multipart.AbortAfterDays) * 24 * time.Hour
+	aborted := 0
+	var errs []error
+	for _, store := range stores {
+		result, err := storage.AbortStaleUploads(ctx, store, olderThan)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		aborted += result.Aborted
+		uploadsAborted.Add(int64(result.Aborted))
+		uploadBytesReclaimed.Add(result.ReclaimedBytes)
+	}
+	return aborted, errors.Join(errs...)
+}
+
+// purgeTrash deletes the objects whose trash retention ended in every tenant
+// namespace.
+func (s *Server) purgeTrash(ctx context.Context) (int, error) {
+	stores := []storage.Storage{s.storage}
+	if tenants, ok := s.storage.(*storage.Tenants); ok {
+		stores = tenants.Stores()
+	}
+
+	now := time.Now()
+	purged := 0
+	var errs []error
+	for _, store := range stores {
+		n, err := store.PurgeTrash(ctx, now)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		purged += n
+	}
+	return purged, errors.Join(errs...)
+}
+
+// pruneEmptyDirs removes the empty directories of the buckets of every tenant
+// namespace.
+func (s *Server) pruneEmptyDirs(ctx context.Context) (int, error) {
+	stores := []storage.Storage{s.storage}
+	if tenants, ok := s.storage.(*storage.Tenants); ok {
+		stores = tenants.Stores()
+	}
+
+	removed := 0
+	var errs []error
+	for _, store := range stores {
+		n, err := store.PruneEmptyDirs(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		removed += n
+	}
+	return removed, errors.Join(errs...)
+}
+
+// pruneNoncurrentVersions deletes the noncurrent versions beyond the version
+// retention of their buckets in every tenant namespace.
+func (s *Server) pruneNoncurrentVersions(ctx context.Context) (int, error) {
+	stores := []storage.Storage{s.storage}
+	if tenants, ok := s.storage.(*storage.Tenants); ok {
+		stores = tenants.Stores()
+	}
+
+	pruned := 0
+	var errs []error
+	for _, store := range stores {
+		n, err := store.PruneNoncurrentVersions(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		pruned += n
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// removeStaleTempFiles removes the temporary files of interrupted writes in
+// every tenant namespace and logs the reclaimed space.
+func (s *Server) removeStaleTempFiles(ctx context.Context) (int, error) {
+	cfg := s.config.Storage.TempFiles
+	stores := []storage.Storage{s.storage}
+	if tenants, ok := s.storage.(*storage.Tenants); ok {
+		stores = tenants.Stores()
+	}
+
+	var total storage.TempFilesResult
+	var errs []error
+	for _, store := range stores {
+		result, err := store.RemoveStaleTempFiles(ctx, cfg.MaxAge, cfg.MaxFiles)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		total.Removed += result.Removed
+		total.ReclaimedBytes += result.ReclaimedBytes
+	}
+	if total.Removed > 0 {
+		log.Info().
+			Int("files", total.Removed).
+			Int64("bytes", total.ReclaimedBytes).
+			Msg("Removed stale temporary files")
+	}
+	return total.Removed, errors.Join(errs...)
+}
+
+// scrubObjects verifies the least recently verified objects of every tenant
+// namespace. Corrupted objects are logged and published as events.
+func (s *Server) scrubObjects(ctx context.Context) (int, error) {
+	cfg := s.config.Storage.Scrub
+
+	tenants := []string{""}
+	for _, tenant := range s.config.Auth.Tenants {
+		tenants = append(tenants, tenant.Name)
+	}
+
+	verified := 0
+	var errs []error
+	for _, tenant := range tenants {
+		tenantCtx := ctx
+		if tenant != "" {
+			tenantCtx = storage.WithTenant(ctx, tenant)
+		}
+		result, err := storage.ScrubObjects(tenantCtx, s.storage, storage.ScrubOptions{
+			MaxObjects:     cfg.MaxObjects,
+			ReverifyAfter:  cfg.ReverifyAfter,
+			BytesPerSecond: cfg.BytesPerSecond,
+			OnCorruption: func(v storage.ObjectVerification) {
+				log.Error().
+					Str("tenant", tenant).
+					Str("bucket", v.Bucket).
+					Str("key", v.Key).
+					Str("corruption", v.Corruption).
+					Msg("Corrupted object detected")
+				s.router.handler.Events().Publish(events.Event{
+					Type:   events.ObjectIntegrityCorrupted,
+					Time:   v.VerifiedAt,
+					Tenant: tenant,
+					Bucket: v.Bucket,
+					Key:    v.Key,
+					Detail: v.Corruption,
+				})
+			},
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+		verified += result.Verified
+		objectsScrubbed.Add(int64(result.Verified))
+		bytesScrubbed.Add(result.BytesRead)
+		objectsCorrupted.Add(int64(result.Corrupted))
+	}
+	return verified, errors.Join(errs...)
+}
+
+// runPeriodically runs a maintenance job every interval until the server shuts down.
+// The job returns the number of items it processed.
+func (s *Server) runPeriodically(name string, interval time.Duration, job func(ctx context.Context) (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.runJob(name, job)
+		}
+	}
+}
+
+// runJob runs a maintenance job once and logs its outcome.
+func (s *Server) runJob(name string, job func(ctx context.Context) (int, error)) {
+	// Only the leader maintains a shared data volume
+	if s.standby() {
+		return
+	}
+	n, err := job(context.Background())
+	if err != nil {
+		log.Error().Err(err).Str("job", name).Msg("Background job failed")
+	}
+	if n > 0 {
+		log.Info().Str("job", name).Int("count", n).Msg("Background job completed")
+	}
+}
+
 // Start starts the HTTP server.
 func (s *Server) Start() error {
-	log.Info().Str("addr", s.httpServer.Addr).Msg("Starting HTTP server")
-	err := s.httpServer.ListenAndServe()
+	if s.controlServer != nil {
+		if err := s.startControlServer(); err != nil {
+			return err
+		}
+	}
+
+	if s.clusterNode != nil {
+		log.Info().Str("node", s.clusterNode.ID()).Msg("Joining cluster")
+		s.clusterNode.Start()
+	}
+	if s.election != nil {
+		s.election.start()
+	}
+	if s.config.Storage.Watch.Enabled {
+		if err := s.startWatchers(); err != nil {
+			s.closeWatchers()
+			return err
+		}
+	}
+
+	var err error
+	if tls := s.config.Server.TLS; tls.CertFile != "" {
+		log.Info().Str("addr", s.httpServer.Addr).Msg("Starting HTTPS server")
+		err = s.httpServer.ListenAndServeTLS(tls.CertFile, tls.KeyFile)
+	} else {
+		log.Info().Str("addr", s.httpServer.Addr).Msg("Starting HTTP server")
+		err = s.httpServer.ListenAndServe()
+	}
 	if err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("server error: %w", err)
 	}
@@ -70,10 +860,44 @@ func (s *Server) Shutdown() error {
 	defer cancel()
 
 	log.Info().Msg("Shutting down server")
+	close(s.stop)
+	if s.clusterNode != nil {
+		s.clusterNode.Stop()
+	}
 
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		return fmt.Errorf("shutdown error: %w", err)
 	}
+	// Event streams of the control service ended with the event broker above
+	if s.controlServer != nil {
+		s.stopControlServer(ctx)
+	}
+
+	// Running batch jobs are cancelled; their reports are still written
+	s.router.Jobs().Close()
+	s.closeWatchers()
+
+	// Hand the lease over once no more writes are in progress
+	if s.election != nil {
+		s.election.close()
+	}
+
+	if err := s.notifier.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to close notification targets")
+	}
+
+	// Writes still queued are mirrored before the storage is closed
+	if s.mirror != nil {
+		if err := s.mirror.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close shadow report")
+		}
+	}
+
+	if s.recorder != nil {
+		if err := s.recorder.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close recording file")
+		}
+	}
 
 	if err := s.storage.Close(); err != nil {
 		return fmt.Errorf("storage close error: %w", err)
@@ -86,3 +910,8 @@ func (s *Server) Shutdown() error {
 func (s *Server) Storage() storage.Storage {
 	return s.storage
 }
+
+// Router returns the request router, e.g. to add hooks before Start.
+func (s *Server) Router() *Router {
+	return s.router
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/auth"
+	"github.com/kumasuke/jog/internal/policy"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// serviceAccountKeyPrefix starts the access keys of service accounts.
+const serviceAccountKeyPrefix = "JOGSA"
+
+var errNoSuchServiceAccount = api.NewError("NoSuchServiceAccount", "The specified service account does not exist.")
+
+// serviceAccountStore stores service accounts in the metadata database of the
+// default namespace.
+type serviceAccountStore interface {
+	PutServiceAccount(ctx context.Context, account *storage.ServiceAccount) error
+	GetServiceAccount(ctx context.Context, accessKey string) (*storage.ServiceAccount, error)
+	ListServiceAccounts(ctx context.Context, parent string) ([]storage.ServiceAccount, error)
+	DeleteServiceAccount(ctx context.Context, accessKey string) error
+}
+
+// serviceAccountRequest is the JSON body of requests minting service accounts.
+type serviceAccountRequest struct {
+	Bucket  string   `json:"bucket"`
+	Prefix  string   `json:"prefix"`
+	Actions []string `json:"actions"`
+	// Description notes what the service account is used for.
+	Description string `json:"description"`
+	// Expiration is when the service account expires; unset never.
+	Expiration *time.Time `json:"expiration"`
+}
+
+// serviceAccountResponse is the JSON body of a service account. The secret
+// key is only returned when the service account is minted.
+type serviceAccountResponse struct {
+	AccessKey   string     `json:"accessKey"`
+	SecretKey   string     `json:"secretKey,omitempty"`
+	Parent      string     `json:"parent"`
+	Bucket      string     `json:"bucket"`
+	Prefix      string     `json:"prefix"`
+	Actions     []string   `json:"actions"`
+	Description string     `json:"description,omitempty"`
+	Created     time.Time  `json:"created"`
+	Expiration  *time.Time `json:"expiration,omitempty"`
+}
+
+// serviceAccountListResponse is the JSON body of the service account list
+// endpoint.
+type serviceAccountListResponse struct {
+	ServiceAccounts []serviceAccountResponse `json:"serviceAccounts"`
+}
+
+func newServiceAccountResponse(account *storage.ServiceAccount) serviceAccountResponse {
+	resp := serviceAccountResponse{
+		AccessKey:   account.AccessKey,
+		Parent:      account.Parent,
+		Bucket:      account.Bucket,
+		Prefix:      account.Prefix,
+		Actions:     account.Actions,
+		Description: account.Description,
+		Created:     account.Created,
+	}
+	if !account.Expires.IsZero() {
+		resp.Expiration = &account.Expires
+	}
+	return resp
+}
+
+// validate checks the scope of a service account to mint.
+func (s *serviceAccountRequest) validate(now time.Time) error {
+	if !api.ValidateBucketName(s.Bucket) {
+		return fmt.Errorf("invalid bucket name %q", s.Bucket)
+	}
+	if strings.ContainsAny(s.Prefix, "*?") {
+		return fmt.Errorf("prefix must not contain wildcards")
+	}
+	if len(s.Actions) == 0 {
+		return fmt.Errorf("at least one action is required")
+	}
+	for _, action := range s.Actions {
+		// Other services, such as s3express:CreateSession, would let the
+		// account act as its parent beyond its scope
+		if name, ok := strings.CutPrefix(action, "s3:"); !ok || name == "" {
+			return fmt.Errorf("invalid action %q: service accounts only have s3: actions", action)
+		}
+	}
+	if s.Expiration != nil && !s.Expiration.After(now) {
+		return fmt.Errorf("expiration must be in the future")
+	}
+	return nil
+}
+
+// newServiceAccountKey returns a random access key for a service account.
+func newServiceAccountKey() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return serviceAccountKeyPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// handleAdminServiceAccounts handles GET and POST
+// /_jog/admin/service-accounts. POST mints a service account of the caller,
+// whose secret key is only returned in the response; GET lists the service
+// accounts of the caller.
+func (r *Router) handleAdminServiceAccounts(w http.ResponseWriter, req *http.Request) {
+	parent, ok := r.serviceAccountParent(w, req)
+	if !ok {
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		accounts, err := r.accounts.ListServiceAccounts(req.Context(), parent)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list service accounts")
+			api.WriteErrorWithResource(w, api.ErrInternalError, req.URL.Path)
+			return
+		}
+		list := make([]serviceAccountResponse, len(accounts))
+		for i := range accounts {
+			list[i] = newServiceAccountResponse(&accounts[i])
+		}
+		writeServiceAccountJSON(w, http.StatusOK, serviceAccountListResponse{ServiceAccounts: list})
+	case http.MethodPost:
+		// Minting writes to the metadata database like S3 writes
+		switch r.mode.Get() {
+		case ModeReadOnly:
+			api.WriteErrorWithResource(w, api.ErrReadOnlyMode, req.URL.Path)
+			return
+		case ModeMaintenance:
+			w.Header().Set("Retry-After", "60")
+			api.WriteErrorWithResource(w, api.ErrServiceUnavailable, req.URL.Path)
+			return
+		}
+
+		var body serviceAccountRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			api.WriteError(w, api.ErrInvalidRequest)
+			return
+		}
+		now := time.Now().UTC()
+		if err := body.validate(now); err != nil {
+			s3Err := *api.ErrInvalidArgument
+			s3Err.Message = err.Error()
+			api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+			return
+		}
+
+		accessKey, err := newServiceAccountKey()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to generate service account key")
+			api.WriteErrorWithResource(w, api.ErrInternalError, req.URL.Path)
+			return
+		}
+		secretKey, ok := r.authMiddle.(*auth.Middleware).ServiceAccountSecret(parent, accessKey)
+		if !ok {
+			s3Err := *api.ErrInvalidRequest
+			s3Err.Message = "Only configured access keys can mint service accounts."
+			api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+			return
+		}
+		account := &storage.ServiceAccount{
+			AccessKey:   accessKey,
+			SecretHash:  auth.HashSecret(secretKey),
+			Parent:      parent,
+			Bucket:      body.Bucket,
+			Prefix:      body.Prefix,
+			Actions:     body.Actions,
+			Description: body.Description,
+			Created:     now,
+		}
+		if body.Expiration != nil {
+			account.Expires = body.Expiration.UTC()
+		}
+		if err := r.accounts.PutServiceAccount(req.Context(), account); err != nil {
+			log.Error().Err(err).Msg("Failed to store service account")
+			api.WriteErrorWithResource(w, api.ErrInternalError, req.URL.Path)
+			return
+		}
+		log.Info().Str("access_key", accessKey).Str("parent", parent).Str("bucket", account.Bucket).
+			Str("prefix", account.Prefix).Msg("Service account minted")
+
+		resp := newServiceAccountResponse(account)
+		resp.SecretKey = secretKey
+		writeServiceAccountJSON(w, http.StatusCreated, resp)
+	default:
+		api.WriteError(w, api.ErrMethodNotAllowed)
+	}
+}
+
+// handleAdminServiceAccount handles GET and DELETE
+// /_jog/admin/service-accounts/{accessKey} for service accounts of the
+// caller. Deleted service accounts are rejected right away.
+func (r *Router) handleAdminServiceAccount(w http.ResponseWriter, req *http.Request, accessKey string) {
+	parent, ok := r.serviceAccountParent(w, req)
+	if !ok {
+		return
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodDelete {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	account, err := r.accounts.GetServiceAccount(req.Context(), accessKey)
+	if err == nil && account.Parent != parent {
+		// Service accounts of other access keys are not revealed
+		err = storage.ErrNoSuchServiceAccount
+	}
+	if err == nil && req.Method == http.MethodDelete {
+		err = r.accounts.DeleteServiceAccount(req.Context(), accessKey)
+	}
+	if errors.Is(err, storage.ErrNoSuchServiceAccount) {
+		api.WriteErrorWithResource(w, errNoSuchServiceAccount, req.URL.Path)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("access_key", accessKey).Msg("Service account request failed")
+		api.WriteErrorWithResource(w, api.ErrInternalError, req.URL.Path)
+		return
+	}
+
+	if req.Method == http.MethodDelete {
+		log.Info().Str("access_key", accessKey).Str("parent", parent).Msg("Service account deleted")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeServiceAccountJSON(w, http.StatusOK, newServiceAccountResponse(account))
+}
+
+// serviceAccountParent returns the access key of the caller, whose service
+// accounts are managed, writing an error if service accounts are unavailable
+// or the caller may not manage them.
+func (r *Router) serviceAccountParent(w http.ResponseWriter, req *http.Request) (string, bool) {
+	parent := storage.OwnerFromContext(req.Context())
+	if _, ok := r.authMiddle.(*auth.Middleware); !ok || parent == "" || r.accounts == nil {
+		s3Err := *api.ErrNotImplemented
+		s3Err.Message = "Service accounts require authentication and a storage backend with a metadata database."
+		api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+		return "", false
+	}
+	if policy.HasSessionPolicy(req.Context()) {
+		// Service accounts act as the access key of their caller, so callers
+		// limited by a session policy would mint keys beyond their limits
+		s3Err := *api.ErrAccessDenied
+		s3Err.Message = "Service accounts and temporary credentials cannot manage service accounts."
+		api.WriteErrorWithResource(w, &s3Err, req.URL.Path)
+		return "", false
+	}
+	return parent, true
+}
+
+// writeServiceAccountJSON writes a JSON response of the admin service account
+// endpoints.
+func writeServiceAccountJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin service account response")
+	}
+}
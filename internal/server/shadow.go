@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/shadow"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// SetShadow sets the mirror whose report is served by the shadow admin endpoint.
+func (r *Router) SetShadow(m *shadow.Mirror) {
+	r.shadow = m
+}
+
+// handleAdminShadow handles GET /_jog/admin/shadow.
+func (r *Router) handleAdminShadow(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+	if r.shadow == nil {
+		s3Err := *api.ErrInvalidRequest
+		s3Err.Message = "Shadow mode is not enabled."
+		api.WriteError(w, &s3Err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(r.shadow.Report()); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin shadow response")
+	}
+}
+
+// isPlainObjectRead reports whether the query of a GET or HEAD request reads
+// the current version of an object, rather than a subresource, a version or a
+// part of it.
+func isPlainObjectRead(query url.Values) bool {
+	for name := range query {
+		// Added by SDKs, overriding response headers and presigning
+		if name != "x-id" && !strings.HasPrefix(name, "response-") && !strings.HasPrefix(name, "X-Amz-") {
+			return false
+		}
+	}
+	return true
+}
+
+// shadowCompareMiddleware compares the objects returned by plain GET and HEAD
+// requests of the default namespace with the shadow secondary.
+func shadowCompareMiddleware(m *shadow.Mirror) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if (r.Method != http.MethodGet && r.Method != http.MethodHead) || !isPlainObjectRead(r.URL.Query()) ||
+				strings.HasPrefix(r.URL.Path, adminPathPrefix) || storage.TenantFromContext(r.Context()) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			bucket, key, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if rw.status != http.StatusOK || !ok || key == "" {
+				return
+			}
+			size, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64)
+			if err != nil {
+				size = -1
+			}
+			m.Compare(bucket, key, w.Header().Get("ETag"), size)
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/auth"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/oidc"
+	"github.com/kumasuke/jog/internal/policy"
+	"github.com/rs/zerolog/log"
+)
+
+// stsNamespace is the XML namespace of STS responses.
+const stsNamespace = "https://sts.amazonaws.com/doc/2011-06-15/"
+
+const (
+	// minSTSDuration and defaultSTSDuration are the shortest and default
+	// DurationSeconds of AssumeRoleWithWebIdentity, as in AWS STS.
+	minSTSDuration     = 15 * time.Minute
+	defaultSTSDuration = time.Hour
+)
+
+// roleSessionNameRegex matches valid RoleSessionName parameters.
+var roleSessionNameRegex = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+// webIdentity exchanges ID tokens of an OpenID Connect provider for
+// temporary credentials.
+type webIdentity struct {
+	provider *oidc.Provider
+	// policyClaim is the claim naming the policies of the credentials.
+	policyClaim string
+	maxDuration time.Duration
+	policies    map[string]*policy.Document
+}
+
+// SetWebIdentity serves AssumeRoleWithWebIdentity, exchanging ID tokens of a
+// provider for temporary credentials limited to the policies their policy
+// claim names. Without authentication, it does nothing.
+func (r *Router) SetWebIdentity(provider *oidc.Provider, cfg config.OIDCConfig, policies map[string]*policy.Document) {
+	m, ok := r.authMiddle.(*auth.Middleware)
+	if !ok || provider == nil {
+		return
+	}
+	m.SetFederation(policies)
+	r.webIdentity = &webIdentity{
+		provider:    provider,
+		policyClaim: cfg.PolicyClaim,
+		maxDuration: cfg.MaxDuration,
+		policies:    policies,
+	}
+}
+
+// isSTSRequest reports whether a request is an STS query API request, which
+// AWS SDKs send as form encoded POST requests to the endpoint root. S3 has
+// no POST / operation, so they never collide with S3 requests.
+func isSTSRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && req.URL.Path == "/" &&
+		strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+}
+
+// assumeRoleWithWebIdentityResponse is the response of
+// AssumeRoleWithWebIdentity.
+type assumeRoleWithWebIdentityResponse struct {
+	XMLName   xml.Name                        `xml:"AssumeRoleWithWebIdentityResponse"`
+	Xmlns     string                          `xml:"xmlns,attr"`
+	Result    assumeRoleWithWebIdentityResult `xml:"AssumeRoleWithWebIdentityResult"`
+	RequestID string                          `xml:"ResponseMetadata>RequestId"`
+}
+
+type assumeRoleWithWebIdentityResult struct {
+	Credentials                 stsCredentials `xml:"Credentials"`
+	SubjectFromWebIdentityToken string         `xml:"SubjectFromWebIdentityToken"`
+	Provider                    string         `xml:"Provider"`
+	Audience                    string         `xml:"Audience"`
+}
+
+type stsCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+	Expiration      string `xml:"Expiration"`
+}
+
+// stsErrorResponse is an error response of the STS query API.
+type stsErrorResponse struct {
+	XMLName   xml.Name `xml:"ErrorResponse"`
+	Xmlns     string   `xml:"xmlns,attr"`
+	Error     stsError `xml:"Error"`
+	RequestID string   `xml:"RequestId"`
+}
+
+type stsError struct {
+	Type    string `xml:"Type"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// handleSTS handles POST / with Action=AssumeRoleWithWebIdentity. The
+// request is not signed; the ID token it carries authenticates the caller.
+func (r *Router) handleSTS(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		writeSTSError(w, http.StatusBadRequest, "InvalidParameterValue", "The request body is not a valid form.")
+		return
+	}
+	if action := req.PostForm.Get("Action"); action != "AssumeRoleWithWebIdentity" {
+		writeSTSError(w, http.StatusBadRequest, "InvalidAction", fmt.Sprintf("The action %s is not supported.", action))
+		return
+	}
+
+	token := req.PostForm.Get("WebIdentityToken")
+	if token == "" {
+		writeSTSError(w, http.StatusBadRequest, "MissingParameter", "The request must contain the parameter WebIdentityToken.")
+		return
+	}
+	if name := req.PostForm.Get("RoleSessionName"); name != "" && !roleSessionNameRegex.MatchString(name) {
+		writeSTSError(w, http.StatusBadRequest, "ValidationError", "RoleSessionName must be 2 to 64 characters of [\\w+=,.@-].")
+		return
+	}
+	duration := min(defaultSTSDuration, r.webIdentity.maxDuration)
+	if s := req.PostForm.Get("DurationSeconds"); s != "" {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || time.Duration(seconds)*time.Second < minSTSDuration || time.Duration(seconds)*time.Second > r.webIdentity.maxDuration {
+			writeSTSError(w, http.StatusBadRequest, "ValidationError", fmt.Sprintf(
+				"DurationSeconds must be between %d and %d.", int(minSTSDuration.Seconds()), int(r.webIdentity.maxDuration.Seconds())))
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	claims, err := r.webIdentity.provider.Verify(req.Context(), token)
+	switch {
+	case errors.Is(err, oidc.ErrExpiredToken):
+		writeSTSError(w, http.StatusBadRequest, "ExpiredTokenException", "Token expired.")
+		return
+	case errors.Is(err, oidc.ErrInvalidToken):
+		writeSTSError(w, http.StatusBadRequest, "InvalidIdentityToken", err.Error())
+		return
+	case err != nil:
+		log.Error().Err(err).Str("issuer", r.webIdentity.provider.Issuer()).Msg("Failed to fetch the signing keys of the identity provider")
+		writeSTSError(w, http.StatusBadRequest, "IDPCommunicationError", "The identity provider could not be reached.")
+		return
+	}
+
+	// Names of unknown policies are ignored, as groups of the provider may
+	// be put in the same claim
+	policies := slices.DeleteFunc(claims.Strings(r.webIdentity.policyClaim), func(name string) bool {
+		return r.webIdentity.policies[name] == nil
+	})
+	if len(policies) == 0 {
+		writeSTSError(w, http.StatusForbidden, "AccessDenied", fmt.Sprintf(
+			"The web identity token names no policy of auth.policies in its %s claim.", r.webIdentity.policyClaim))
+		return
+	}
+
+	creds, err := r.authMiddle.(*auth.Middleware).IssueTemporaryCredentials(claims.Subject, policies, duration)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to issue temporary credentials")
+		writeSTSError(w, http.StatusInternalServerError, "InternalFailure", "The request processing has failed because of an unknown error.")
+		return
+	}
+	log.Info().Str("subject", claims.Subject).Str("access_key", creds.AccessKeyID).Strs("policies", policies).
+		Time("expiration", creds.Expiration).Msg("Temporary credentials issued")
+
+	resp := assumeRoleWithWebIdentityResponse{
+		Xmlns: stsNamespace,
+		Result: assumeRoleWithWebIdentityResult{
+			Credentials: stsCredentials{
+				AccessKeyID:     creds.AccessKeyID,
+				SecretAccessKey: creds.SecretAccessKey,
+				SessionToken:    creds.SessionToken,
+				Expiration:      creds.Expiration.Format(time.RFC3339),
+			},
+			SubjectFromWebIdentityToken: claims.Subject,
+			Provider:                    r.webIdentity.provider.Issuer(),
+			Audience:                    r.webIdentity.provider.ClientID(),
+		},
+		RequestID: w.Header().Get(api.RequestIDHeader),
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusOK)
+	if err := xml.NewEncoder(w).Encode(resp); err != nil {
+		log.Error().Err(err).Msg("Failed to encode AssumeRoleWithWebIdentity response")
+	}
+}
+
+// writeSTSError writes an error response of the STS query API.
+func writeSTSError(w http.ResponseWriter, status int, code, message string) {
+	errType := "Sender"
+	if status >= http.StatusInternalServerError {
+		errType = "Receiver"
+	}
+	resp := stsErrorResponse{
+		Xmlns:     stsNamespace,
+		Error:     stsError{Type: errType, Code: code, Message: message},
+		RequestID: w.Header().Get(api.RequestIDHeader),
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	if err := xml.NewEncoder(w).Encode(resp); err != nil {
+		log.Error().Err(err).Msg("Failed to encode STS error response")
+	}
+}
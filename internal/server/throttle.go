@@ -0,0 +1,246 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// maxThrottleStates bounds the buckets whose request counts a Throttle
+// tracks. Idle buckets are forgotten beyond it, so that requests to many
+// bucket names do not grow it without bound.
+const maxThrottleStates = 10000
+
+// BucketLimits are the request limits of a bucket. Zero means no limit.
+type BucketLimits struct {
+	// MaxConcurrentRequests limits the requests in progress.
+	MaxConcurrentRequests int `json:"maxConcurrentRequests"`
+	// RequestsPerSecond limits the rate of requests, with bursts of up to
+	// Burst requests. Burst defaults to the rate rounded up.
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+// validate checks that limits are not negative.
+func (l BucketLimits) validate() error {
+	if l.MaxConcurrentRequests < 0 || l.RequestsPerSecond < 0 || l.Burst < 0 ||
+		math.IsNaN(l.RequestsPerSecond) || math.IsInf(l.RequestsPerSecond, 0) {
+		return errors.New("bucket limits must not be negative")
+	}
+	return nil
+}
+
+// burst returns the number of tokens of the rate limit.
+func (l BucketLimits) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Ceil(l.RequestsPerSecond)
+}
+
+// bucketLimitsFromConfig converts the configured limits of a bucket.
+func bucketLimitsFromConfig(cfg config.BucketLimitConfig) BucketLimits {
+	return BucketLimits{
+		MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+		RequestsPerSecond:     cfg.RequestsPerSecond,
+		Burst:                 cfg.Burst,
+	}
+}
+
+// throttleState counts the requests of a bucket.
+type throttleState struct {
+	inFlight int
+	// tokens are the requests the rate limit accepts at last, refilled at
+	// the rate of the limit up to its burst.
+	tokens float64
+	last   time.Time
+}
+
+// Throttle enforces the request limits of buckets. It is safe for concurrent
+// use.
+type Throttle struct {
+	mu       sync.Mutex
+	defaults BucketLimits
+	limits   map[string]BucketLimits
+	states   map[string]*throttleState
+}
+
+// NewThrottle creates a Throttle without limits.
+func NewThrottle() *Throttle {
+	return &Throttle{
+		limits: make(map[string]BucketLimits),
+		states: make(map[string]*throttleState),
+	}
+}
+
+// SetDefault sets the limits of buckets without limits of their own.
+func (t *Throttle) SetDefault(limits BucketLimits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.defaults = limits
+}
+
+// Set sets the limits of a bucket.
+func (t *Throttle) Set(bucket string, limits BucketLimits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limits[bucket] = limits
+}
+
+// Delete removes the limits of a bucket, so that the default limits apply.
+func (t *Throttle) Delete(bucket string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.limits, bucket)
+}
+
+// Get returns the limits of a bucket and whether they are its own limits
+// rather than the default limits.
+func (t *Throttle) Get(bucket string) (BucketLimits, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if limits, ok := t.limits[bucket]; ok {
+		return limits, true
+	}
+	return t.defaults, false
+}
+
+// acquire admits a request to a bucket unless it exceeds the limits of the
+// bucket. The returned function must be called when an admitted request
+// ends.
+func (t *Throttle) acquire(bucket string) (release func(), ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	limits, own := t.limits[bucket]
+	if !own {
+		limits = t.defaults
+	}
+	if limits == (BucketLimits{}) {
+		return func() {}, true
+	}
+
+	now := time.Now()
+	state := t.states[bucket]
+	if state == nil {
+		if len(t.states) >= maxThrottleStates {
+			t.forgetIdle(now)
+		}
+		state = &throttleState{tokens: limits.burst(), last: now}
+		t.states[bucket] = state
+	}
+	if limits.RequestsPerSecond > 0 {
+		elapsed := now.Sub(state.last).Seconds()
+		state.tokens = math.Min(limits.burst(), state.tokens+elapsed*limits.RequestsPerSecond)
+		state.last = now
+		if state.tokens < 1 {
+			return nil, false
+		}
+	}
+	if limits.MaxConcurrentRequests > 0 && state.inFlight >= limits.MaxConcurrentRequests {
+		return nil, false
+	}
+
+	if limits.RequestsPerSecond > 0 {
+		state.tokens--
+	}
+	state.inFlight++
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		state.inFlight--
+	}, true
+}
+
+// forgetIdle removes the states of buckets without requests in progress
+// that the rate limit no longer remembers, as their tokens are refilled.
+func (t *Throttle) forgetIdle(now time.Time) {
+	for bucket, state := range t.states {
+		if state.inFlight > 0 {
+			continue
+		}
+		limits, own := t.limits[bucket]
+		if !own {
+			limits = t.defaults
+		}
+		if limits.RequestsPerSecond == 0 || state.tokens+now.Sub(state.last).Seconds()*limits.RequestsPerSecond >= limits.burst() {
+			delete(t.states, bucket)
+		}
+	}
+}
+
+// ThrottleMiddleware rejects requests beyond the limits of their bucket with
+// 503 SlowDown, which S3 clients retry with backoff. Admin requests and
+// requests without a bucket are not limited.
+func ThrottleMiddleware(next http.Handler, throttle *Throttle) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bucket, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if bucket == "" || strings.HasPrefix(r.URL.Path, adminPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		release, ok := throttle.acquire(bucket)
+		if !ok {
+			metrics.NewCounter("jog_throttled_requests_total",
+				"Requests rejected with SlowDown for exceeding the limits of their bucket.", "bucket", bucket).Inc()
+			api.WriteErrorWithResource(w, api.ErrSlowDown, r.URL.Path)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bucketLimitsResponse is the JSON body of the admin bucket limits endpoint.
+type bucketLimitsResponse struct {
+	Bucket string `json:"bucket"`
+	BucketLimits
+	// Default reports whether the default limits apply to the bucket.
+	Default bool `json:"default"`
+}
+
+// handleAdminBucketLimits handles GET, PUT and DELETE
+// /_jog/admin/buckets/{name}/limits. PUT sets the request limits of a bucket
+// and DELETE restores the default limits. Limits are kept in memory, so
+// limits set at runtime are lost on restart.
+func (r *Router) handleAdminBucketLimits(w http.ResponseWriter, req *http.Request, bucket string) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var limits BucketLimits
+		if err := json.NewDecoder(req.Body).Decode(&limits); err != nil {
+			api.WriteError(w, api.ErrInvalidRequest)
+			return
+		}
+		if err := limits.validate(); err != nil {
+			api.WriteError(w, api.ErrInvalidArgument)
+			return
+		}
+		r.throttle.Set(bucket, limits)
+		log.Info().Str("bucket", bucket).Str("limits", fmt.Sprintf("%+v", limits)).Msg("Bucket limits changed")
+	case http.MethodDelete:
+		r.throttle.Delete(bucket)
+		log.Info().Str("bucket", bucket).Msg("Bucket limits reset to the default")
+	default:
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	limits, own := r.throttle.Get(bucket)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(bucketLimitsResponse{Bucket: bucket, BucketLimits: limits, Default: !own}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin bucket limits response")
+	}
+}
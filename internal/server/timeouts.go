@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BodyTimeoutMiddleware protects the server from slow clients uploading
+// request bodies. The body of a request must be read within total, and the
+// client must send data at least every idle while it is read, otherwise body
+// reads fail with os.ErrDeadlineExceeded and the handlers answer
+// RequestTimeout. A zero duration disables the respective limit.
+func BodyTimeoutMiddleware(total, idle time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		if total <= 0 && idle <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body := &deadlineBody{
+				ReadCloser: r.Body,
+				rc:         http.NewResponseController(w),
+				idle:       idle,
+			}
+			if total > 0 {
+				body.deadline = time.Now().Add(total)
+			}
+			if !body.setDeadline() {
+				// The connection does not support read deadlines
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Body = body
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// deadlineBody extends the read deadline of the connection before every
+// read of a request body, so that only stalls, not slow uploads as a whole,
+// hit the idle timeout.
+type deadlineBody struct {
+	io.ReadCloser
+	rc       *http.ResponseController
+	idle     time.Duration
+	deadline time.Time
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	if b.idle > 0 {
+		b.setDeadline()
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// setDeadline sets the read deadline to the idle timeout from now, capped at
+// the deadline of the whole body. It reports false if the connection does not
+// support read deadlines.
+func (b *deadlineBody) setDeadline() bool {
+	deadline := b.deadline
+	if b.idle > 0 {
+		if idle := time.Now().Add(b.idle); deadline.IsZero() || idle.Before(deadline) {
+			deadline = idle
+		}
+	}
+	if err := b.rc.SetReadDeadline(deadline); err != nil {
+		if !errors.Is(err, http.ErrNotSupported) {
+			log.Debug().Err(err).Msg("Failed to set request body read deadline")
+		}
+		return false
+	}
+	return true
+}
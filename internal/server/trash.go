@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	errNoSuchTrashEntry = api.NewError("NoSuchTrashEntry", "The specified trash entry does not exist.")
+	errTrashKeyExists   = api.NewError("ObjectAlreadyExists", "An object was written to the key of the trash entry since it was deleted.")
+)
+
+// trashEntryResponse is an entry of the JSON body of the admin trash endpoint.
+type trashEntryResponse struct {
+	ID           string    `json:"id"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	ContentType  string    `json:"contentType,omitempty"`
+	LastModified time.Time `json:"lastModified"`
+	DeletedAt    time.Time `json:"deletedAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// trashListResponse is the JSON body of the admin trash endpoint.
+type trashListResponse struct {
+	Bucket  string               `json:"bucket"`
+	Entries []trashEntryResponse `json:"entries"`
+}
+
+// handleAdminBucketTrash handles GET /_jog/admin/buckets/{name}/trash, listing
+// the objects in the trash of a bucket. The prefix parameter restricts the
+// listing to keys starting with it.
+func (r *Router) handleAdminBucketTrash(w http.ResponseWriter, req *http.Request, bucket string) {
+	if req.Method != http.MethodGet {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	entries, err := r.handler.Storage().ListTrash(req.Context(), bucket, req.URL.Query().Get("prefix"))
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			api.WriteErrorWithResource(w, api.ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to list bucket trash")
+		api.WriteError(w, api.ErrInternalError)
+		return
+	}
+
+	resp := trashListResponse{Bucket: bucket, Entries: []trashEntryResponse{}}
+	for _, entry := range entries {
+		resp.Entries = append(resp.Entries, trashEntryResponse{
+			ID:           entry.ID,
+			Key:          entry.Key,
+			Size:         entry.Size,
+			ETag:         entry.ETag,
+			ContentType:  entry.ContentType,
+			LastModified: entry.LastModified,
+			DeletedAt:    entry.DeletedAt,
+			ExpiresAt:    entry.ExpiresAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin bucket trash response")
+	}
+}
+
+// handleAdminRestoreTrash handles POST
+// /_jog/admin/buckets/{name}/trash/{id}/restore, moving an object in the
+// trash back to its key.
+func (r *Router) handleAdminRestoreTrash(w http.ResponseWriter, req *http.Request, bucket, id string) {
+	if req.Method != http.MethodPost {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	// Restoring writes an object, so it is subject to the server mode like S3 writes
+	switch r.mode.Get() {
+	case ModeReadOnly:
+		api.WriteErrorWithResource(w, api.ErrReadOnlyMode, req.URL.Path)
+		return
+	case ModeMaintenance:
+		w.Header().Set("Retry-After", "60")
+		api.WriteErrorWithResource(w, api.ErrServiceUnavailable, req.URL.Path)
+		return
+	}
+
+	obj, err := r.handler.Storage().RestoreTrash(req.Context(), bucket, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrBucketNotFound):
+			api.WriteErrorWithResource(w, api.ErrNoSuchBucket, "/"+bucket)
+		case errors.Is(err, storage.ErrNoSuchTrashEntry):
+			api.WriteErrorWithResource(w, errNoSuchTrashEntry, req.URL.Path)
+		case errors.Is(err, storage.ErrObjectExists):
+			api.WriteErrorWithResource(w, errTrashKeyExists, req.URL.Path)
+		default:
+			log.Error().Err(err).Str("bucket", bucket).Str("id", id).Msg("Failed to restore object from trash")
+			api.WriteError(w, api.ErrInternalError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"bucket": bucket,
+		"key":    obj.Key,
+		"size":   obj.Size,
+		"etag":   obj.ETag,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin trash restore response")
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/rs/zerolog/log"
+)
+
+// maxHookResponseSize bounds the error body read from the pre-put hook.
+const maxHookResponseSize = 64 * 1024
+
+// errPrePutHookUnavailable rejects uploads while the pre-put hook fails.
+var errPrePutHookUnavailable = api.NewError("ServiceUnavailable", "The upload could not be validated. Please try again.")
+
+// prePutHook validates uploads with an external HTTP service, e.g. an
+// antivirus scanner. The service receives the data of each upload in a POST
+// request with the bucket and key in the query, and for appended data the
+// append position, and the content type and user metadata in X-Jog-* headers. A 2xx response accepts the upload; a 4xx
+// response rejects it with the status and the code and message of a JSON
+// body {"code": "...", "message": "..."}.
+type prePutHook struct {
+	url      *url.URL
+	client   *http.Client
+	failOpen bool
+}
+
+// NewPrePutHook creates the pre-put hook of the configuration.
+func NewPrePutHook(cfg config.PrePutHookConfig) (api.UploadValidator, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q", cfg.URL)
+	}
+	return &prePutHook{
+		url:      u,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		failOpen: cfg.FailOpen,
+	}, nil
+}
+
+// ValidateUpload sends an upload to the hook.
+func (h *prePutHook) ValidateUpload(ctx context.Context, upload *api.Upload) error {
+	u := *h.url
+	query := u.Query()
+	query.Set("bucket", upload.Bucket)
+	query.Set("key", upload.Key)
+	if upload.Append {
+		query.Set("append", strconv.FormatInt(upload.Position, 10))
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), upload.Open())
+	if err != nil {
+		return err
+	}
+	req.ContentLength = upload.Size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Jog-Content-Type", upload.ContentType)
+	for key, value := range upload.Metadata {
+		req.Header.Set("X-Jog-Meta-"+key, value)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return h.unavailable(upload, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		var body struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, maxHookResponseSize)).Decode(&body)
+		s3Err := &api.S3Error{
+			Code:       body.Code,
+			Message:    body.Message,
+			HTTPStatus: resp.StatusCode,
+		}
+		if s3Err.Code == "" {
+			s3Err.Code = "AccessDenied"
+		}
+		if s3Err.Message == "" {
+			s3Err.Message = "The upload was rejected."
+		}
+		return s3Err
+	default:
+		return h.unavailable(upload, fmt.Errorf("unexpected status %s", resp.Status))
+	}
+}
+
+// unavailable handles a failure of the hook itself, accepting the upload if
+// the hook fails open.
+func (h *prePutHook) unavailable(upload *api.Upload, err error) error {
+	log.Warn().Err(err).Str("bucket", upload.Bucket).Str("key", upload.Key).Bool("failOpen", h.failOpen).Msg("Pre-put hook failed")
+	if h.failOpen {
+		return nil
+	}
+	return errPrePutHookUnavailable
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// bucketStatsResponse is the JSON body of the admin bucket stats endpoint.
+type bucketStatsResponse struct {
+	Bucket           string `json:"bucket"`
+	Objects          int64  `json:"objects"`
+	Bytes            int64  `json:"bytes"`
+	VersionBytes     int64  `json:"versionBytes"`
+	MultipartUploads int64  `json:"multipartUploads"`
+	MultipartBytes   int64  `json:"multipartBytes"`
+}
+
+// handleAdminBucketStats handles GET /_jog/admin/buckets/{name}/stats. The
+// usage is read from counters maintained on every write, not by listing the
+// bucket.
+func (r *Router) handleAdminBucketStats(w http.ResponseWriter, req *http.Request, bucket string) {
+	if req.Method != http.MethodGet {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	usage, err := r.handler.Storage().GetBucketUsage(req.Context(), bucket)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			api.WriteErrorWithResource(w, api.ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket usage")
+		api.WriteError(w, api.ErrInternalError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(bucketStatsResponse{
+		Bucket:           bucket,
+		Objects:          usage.Objects,
+		Bytes:            usage.Bytes,
+		VersionBytes:     usage.VersionBytes,
+		MultipartUploads: usage.MultipartUploads,
+		MultipartBytes:   usage.MultipartBytes,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin bucket stats response")
+	}
+}
+
+// usageRecordResponse is an entry of the JSON body of the admin bucket usage
+// history endpoint.
+type usageRecordResponse struct {
+	Date           string    `json:"date"`
+	Objects        int64     `json:"objects"`
+	Bytes          int64     `json:"bytes"`
+	VersionBytes   int64     `json:"versionBytes"`
+	MultipartBytes int64     `json:"multipartBytes"`
+	PhysicalBytes  int64     `json:"physicalBytes"`
+	RecordedAt     time.Time `json:"recordedAt"`
+}
+
+// usageHistoryResponse is the JSON body of the admin bucket usage history
+// endpoint.
+type usageHistoryResponse struct {
+	Bucket  string                `json:"bucket"`
+	Records []usageRecordResponse `json:"records"`
+}
+
+// handleAdminBucketUsageHistory handles GET
+// /_jog/admin/buckets/{name}/usage-history, listing the daily usage records
+// of a bucket, oldest first. The days parameter restricts the listing to the
+// last days days.
+func (r *Router) handleAdminBucketUsageHistory(w http.ResponseWriter, req *http.Request, bucket string) {
+	if req.Method != http.MethodGet {
+		api.WriteError(w, api.ErrMethodNotAllowed)
+		return
+	}
+
+	days := 0
+	if value := req.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			api.WriteError(w, api.ErrInvalidArgument)
+			return
+		}
+		days = n
+	}
+
+	records, err := r.handler.Storage().GetBucketUsageHistory(req.Context(), bucket, days)
+	if err != nil {
+		if errors.Is(err, storage.ErrBucketNotFound) {
+			api.WriteErrorWithResource(w, api.ErrNoSuchBucket, "/"+bucket)
+			return
+		}
+		log.Error().Err(err).Str("bucket", bucket).Msg("Failed to get bucket usage history")
+		api.WriteError(w, api.ErrInternalError)
+		return
+	}
+
+	resp := usageHistoryResponse{Bucket: bucket, Records: []usageRecordResponse{}}
+	for _, record := range records {
+		resp.Records = append(resp.Records, usageRecordResponse{
+			Date:           record.Date,
+			Objects:        record.Objects,
+			Bytes:          record.Bytes,
+			VersionBytes:   record.VersionBytes,
+			MultipartBytes: record.MultipartBytes,
+			PhysicalBytes:  record.PhysicalBytes,
+			RecordedAt:     record.RecordedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error().Err(err).Msg("Failed to encode admin bucket usage history response")
+	}
+}
+
+// recordUsageHistory records the usage of the buckets of every tenant
+// namespace and exports it as gauges labeled with the tenant and bucket.
+// Gauges of deleted buckets keep their last value until a restart.
+func (s *Server) recordUsageHistory(ctx context.Context) (int, error) {
+	cfg := s.config.Storage.UsageHistory
+
+	tenants := []string{""}
+	for _, tenant := range s.config.Auth.Tenants {
+		tenants = append(tenants, tenant.Name)
+	}
+
+	now := time.Now()
+	recorded := 0
+	var errs []error
+	for _, tenant := range tenants {
+		tenantCtx := ctx
+		if tenant != "" {
+			tenantCtx = storage.WithTenant(ctx, tenant)
+		}
+		records, err := s.storage.RecordUsageHistory(tenantCtx, now, cfg.KeepDays)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, record := range records {
+			labels := []string{"tenant", tenant, "bucket", record.Bucket}
+			metrics.NewGauge("jog_bucket_objects", "Current objects of a bucket at its last usage record.", labels...).Set(record.Objects)
+			metrics.NewGauge("jog_bucket_bytes", "Logical size of the current objects of a bucket at its last usage record.", labels...).Set(record.Bytes)
+			metrics.NewGauge("jog_bucket_physical_bytes", "Size of the files of a bucket in the data directory at its last usage record.", labels...).Set(record.PhysicalBytes)
+		}
+		recorded += len(records)
+	}
+	return recorded, errors.Join(errs...)
+}
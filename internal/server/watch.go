@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/kumasuke/jog/internal/events"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+var objectsReconciled = metrics.NewCounter("jog_watch_reconciled_objects_total",
+	"Objects whose metadata was updated for files changed outside JOG.")
+
+// startWatchers watches the data directory of every namespace for files
+// changed outside JOG. Reconciled objects are published as events, so
+// notification targets see them like uploads and deletes.
+func (s *Server) startWatchers() error {
+	tenants := []string{""}
+	seen := map[string]bool{"": true}
+	for _, tenant := range s.config.Auth.Tenants {
+		if !seen[tenant.Name] {
+			seen[tenant.Name] = true
+			tenants = append(tenants, tenant.Name)
+		}
+	}
+
+	for _, tenant := range tenants {
+		store := s.storage
+		if t, ok := store.(*storage.Tenants); ok {
+			store = t.Store(tenant)
+		}
+		fsys, ok := store.(*storage.FileSystem)
+		if !ok {
+			return fmt.Errorf("watching the data directory requires the filesystem storage backend")
+		}
+
+		watcher, err := fsys.WatchDataDir(storage.WatchOptions{
+			Debounce: s.config.Storage.Watch.Debounce,
+			OnReconcile: func(obj storage.ObjectRef, removed bool) {
+				objectsReconciled.Inc()
+				log.Info().
+					Str("tenant", tenant).
+					Str("bucket", obj.Bucket).
+					Str("key", obj.Key).
+					Bool("removed", removed).
+					Msg("Reconciled object changed outside JOG")
+				eventType := events.ObjectCreatedPut
+				if removed {
+					eventType = events.ObjectRemovedDelete
+				}
+				s.router.handler.Events().Publish(events.Event{
+					Type:   eventType,
+					Tenant: tenant,
+					Bucket: obj.Bucket,
+					Key:    obj.Key,
+				})
+			},
+			OnError: func(err error) {
+				log.Error().Err(err).Str("tenant", tenant).Msg("Failed to reconcile data directory")
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to watch data directory: %w", err)
+		}
+		s.watchers = append(s.watchers, watcher)
+	}
+	return nil
+}
+
+// closeWatchers stops watching the data directories.
+func (s *Server) closeWatchers() {
+	for _, watcher := range s.watchers {
+		if err := watcher.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close data directory watcher")
+		}
+	}
+	s.watchers = nil
+}
@@ -0,0 +1,397 @@
+// Package shadow mirrors writes to a secondary S3 endpoint and compares reads
+// with it, so a migration to JOG can be verified while the system migrated
+// from is kept up to date.
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+	"github.com/kumasuke/jog/internal/metrics"
+	"github.com/kumasuke/jog/internal/storage"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// syncAttempts is how often mirroring an object is attempted.
+	syncAttempts = 3
+	// retryDelay is the delay before the first retry; it doubles with each attempt.
+	retryDelay = 500 * time.Millisecond
+	// syncTimeout bounds a single attempt to mirror an object.
+	syncTimeout = 10 * time.Minute
+	// compareTimeout bounds comparing an object with the secondary.
+	compareTimeout = 10 * time.Second
+	// recentDivergences is the number of divergences kept for the report.
+	recentDivergences = 100
+	// compareBuffer is the number of reads waiting to be dispatched for comparison.
+	compareBuffer = 256
+)
+
+var (
+	objectsMirrored = metrics.NewCounter("jog_shadow_mirrored_total",
+		"Writes mirrored to the shadow secondary.")
+	mirrorsFailed = metrics.NewCounter("jog_shadow_mirror_failed_total",
+		"Writes that could not be mirrored to the shadow secondary.")
+	readsCompared = metrics.NewCounter("jog_shadow_reads_compared_total",
+		"Reads compared with the shadow secondary.")
+	divergencesFound = metrics.NewCounter("jog_shadow_divergences_total",
+		"Divergences between JOG and the shadow secondary.")
+)
+
+// Kind is the kind of a divergence.
+type Kind string
+
+const (
+	// MirrorFailed means a write could not be mirrored to the secondary.
+	MirrorFailed Kind = "mirror-failed"
+	// MirrorDropped means a write was not mirrored because the queue was full.
+	MirrorDropped Kind = "mirror-dropped"
+	// Missing means an object read from JOG does not exist on the secondary.
+	Missing Kind = "missing"
+	// SizeMismatch means an object has different sizes on JOG and the secondary.
+	SizeMismatch Kind = "size-mismatch"
+	// ETagMismatch means an object has different ETags on JOG and the secondary.
+	ETagMismatch Kind = "etag-mismatch"
+)
+
+// Divergence is a difference between JOG and the secondary.
+type Divergence struct {
+	Time   time.Time `json:"time"`
+	Kind   Kind      `json:"kind"`
+	Bucket string    `json:"bucket"`
+	Key    string    `json:"key"`
+	// Local and Secondary are the values that differ.
+	Local     string `json:"local,omitempty"`
+	Secondary string `json:"secondary,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report summarizes the mirroring since the server started.
+type Report struct {
+	Mirrored     int64 `json:"mirrored"`
+	MirrorFailed int64 `json:"mirrorFailed"`
+	Compared     int64 `json:"compared"`
+	Divergences  int64 `json:"divergences"`
+	// Recent lists the latest divergences, oldest first.
+	Recent []Divergence `json:"recent"`
+}
+
+// job is an object to mirror or, for reads, to compare with the secondary.
+type job struct {
+	bucket string
+	key    string
+
+	compare bool
+	// etag and size were returned by the read; size is negative if unknown.
+	etag string
+	size int64
+}
+
+// Mirror applies the writes of the default namespace to a secondary S3
+// endpoint. Writes of a key are mirrored in order by the same worker.
+type Mirror struct {
+	store     storage.Storage
+	secondary *storage.S3BlobStore
+	sub       *events.Subscription
+	wg        sync.WaitGroup
+
+	// compares are dispatched after the events published before them
+	compares chan job
+
+	// queueMu guards closing the queues against comparisons of late reads
+	queueMu sync.RWMutex
+	queues  []chan job
+	closed  bool
+
+	mu         sync.Mutex
+	report     Report
+	reportFile *os.File
+}
+
+// Start mirrors the writes published to broker from store to the secondary
+// described by cfg.
+func Start(broker *events.Broker, store storage.Storage, cfg config.ShadowConfig) (*Mirror, error) {
+	if cfg.Workers <= 0 || cfg.QueueSize <= 0 {
+		return nil, errors.New("shadow workers and queue size must be positive")
+	}
+	// The bucket is replaced for every object
+	secondary, err := storage.NewS3BlobStore(cfg.Endpoint, cfg.Region, "-", cfg.AccessKey, cfg.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mirror{
+		store:     store,
+		secondary: secondary,
+		compares:  make(chan job, compareBuffer),
+		report:    Report{Recent: []Divergence{}},
+	}
+	if cfg.ReportFile != "" {
+		m.reportFile, err = os.OpenFile(cfg.ReportFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open shadow report file: %w", err)
+		}
+	}
+
+	queueSize := max(cfg.QueueSize/cfg.Workers, 1)
+	for range cfg.Workers {
+		queue := make(chan job, queueSize)
+		m.queues = append(m.queues, queue)
+		m.wg.Add(1)
+		go m.work(queue)
+	}
+
+	m.sub = broker.Subscribe(events.Filter{})
+	m.wg.Add(1)
+	go m.dispatch()
+	return m, nil
+}
+
+// dispatch queues the objects changed by events and the reads to compare
+// until the subscription is closed.
+func (m *Mirror) dispatch() {
+	defer m.wg.Done()
+	defer func() {
+		m.queueMu.Lock()
+		defer m.queueMu.Unlock()
+		m.closed = true
+		for _, queue := range m.queues {
+			close(queue)
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-m.sub.C:
+			if !ok {
+				// Reads compared before closing are still compared
+				for {
+					select {
+					case j := <-m.compares:
+						m.enqueue(j)
+					default:
+						return
+					}
+				}
+			}
+			m.dispatchEvent(event)
+		case j := <-m.compares:
+			// Writes completed before the read are mirrored before comparing
+		drain:
+			for {
+				select {
+				case event, ok := <-m.sub.C:
+					if !ok {
+						break drain
+					}
+					m.dispatchEvent(event)
+				default:
+					break drain
+				}
+			}
+			m.enqueue(j)
+		}
+	}
+}
+
+// dispatchEvent queues the object changed by an event.
+func (m *Mirror) dispatchEvent(event events.Event) {
+	if !strings.HasPrefix(string(event.Type), "s3:ObjectCreated:") && !strings.HasPrefix(string(event.Type), "s3:ObjectRemoved:") {
+		return
+	}
+	if !m.enqueue(job{bucket: event.Bucket, key: event.Key}) {
+		mirrorsFailed.Inc()
+		m.record(Divergence{Kind: MirrorDropped, Bucket: event.Bucket, Key: event.Key})
+	}
+}
+
+// enqueue queues a job on the worker of its key. It returns false if the
+// queue is full or closed.
+func (m *Mirror) enqueue(j job) bool {
+	m.queueMu.RLock()
+	defer m.queueMu.RUnlock()
+	if m.closed {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(j.bucket + "/" + j.key))
+	select {
+	case m.queues[h.Sum32()%uint32(len(m.queues))] <- j:
+		return true
+	default:
+		return false
+	}
+}
+
+// Compare queues a comparison of an object read from the default namespace
+// with the secondary. It runs after the writes of the key published before
+// it, so objects not mirrored yet are not reported. Comparisons are skipped
+// while the queue is full. A negative size is not compared.
+func (m *Mirror) Compare(bucket, key, etag string, size int64) {
+	m.queueMu.RLock()
+	defer m.queueMu.RUnlock()
+	if m.closed {
+		return
+	}
+	select {
+	case m.compares <- job{bucket: bucket, key: key, compare: true, etag: strings.Trim(etag, `"`), size: size}:
+	default:
+	}
+}
+
+// work runs the jobs of a queue until it is closed.
+func (m *Mirror) work(queue <-chan job) {
+	defer m.wg.Done()
+
+	for j := range queue {
+		if j.compare {
+			m.compare(j)
+			continue
+		}
+		if err := m.mirror(j.bucket, j.key); err != nil {
+			mirrorsFailed.Inc()
+			log.Error().Err(err).Str("bucket", j.bucket).Str("key", j.key).Msg("Failed to mirror object to shadow secondary")
+			m.record(Divergence{Kind: MirrorFailed, Bucket: j.bucket, Key: j.key, Error: err.Error()})
+			continue
+		}
+		objectsMirrored.Inc()
+		m.mu.Lock()
+		m.report.Mirrored++
+		m.mu.Unlock()
+	}
+}
+
+// mirror copies the current state of an object to the secondary, retrying with
+// backoff. Objects that no longer exist are deleted, so mirroring is
+// idempotent and a later write of the key supersedes an earlier one.
+func (m *Mirror) mirror(bucket, key string) error {
+	var err error
+	delay := retryDelay
+	for attempt := 1; attempt <= syncAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+		err = m.sync(ctx, bucket, key)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt < syncAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+// sync makes one attempt to copy an object to the secondary.
+func (m *Mirror) sync(ctx context.Context, bucket, key string) error {
+	secondary := m.secondary.WithBucket(bucket)
+	obj, err := m.store.GetObject(ctx, bucket, key)
+	if errors.Is(err, storage.ErrObjectNotFound) || errors.Is(err, storage.ErrBucketNotFound) {
+		return secondary.DeleteBlob(ctx, key)
+	}
+	if err != nil {
+		return err
+	}
+	defer obj.Body.Close()
+	return secondary.PutObject(ctx, key, obj.Body, obj.Size, obj.ContentType, obj.Metadata)
+}
+
+// compare compares an object read from JOG with the secondary.
+func (m *Mirror) compare(j job) {
+	ctx, cancel := context.WithTimeout(context.Background(), compareTimeout)
+	defer cancel()
+
+	info, err := m.secondary.WithBucket(j.bucket).StatBlob(ctx, j.key)
+	if err != nil && !errors.Is(err, storage.ErrBlobNotFound) {
+		log.Warn().Err(err).Str("bucket", j.bucket).Str("key", j.key).Msg("Failed to compare object with shadow secondary")
+		return
+	}
+	readsCompared.Inc()
+	m.mu.Lock()
+	m.report.Compared++
+	m.mu.Unlock()
+
+	divergence := Divergence{Bucket: j.bucket, Key: j.key}
+	switch {
+	case err != nil:
+		divergence.Kind = Missing
+	case j.size >= 0 && info.Size != j.size:
+		divergence.Kind = SizeMismatch
+		divergence.Local = strconv.FormatInt(j.size, 10)
+		divergence.Secondary = strconv.FormatInt(info.Size, 10)
+	case info.ETag != j.etag && !isMultipartETag(j.etag) && !isMultipartETag(info.ETag):
+		divergence.Kind = ETagMismatch
+		divergence.Local = j.etag
+		divergence.Secondary = info.ETag
+	default:
+		return
+	}
+
+	// An object written after the read is mirrored by a later job
+	if obj, err := m.store.HeadObject(ctx, j.bucket, j.key); err != nil || strings.Trim(obj.ETag, `"`) != j.etag {
+		return
+	}
+	m.record(divergence)
+}
+
+// isMultipartETag reports whether an ETag is the ETag of a multipart upload.
+// Objects uploaded in parts are mirrored in one piece, so their ETags differ.
+func isMultipartETag(etag string) bool {
+	return strings.Contains(etag, "-")
+}
+
+// record adds a divergence to the report.
+func (m *Mirror) record(d Divergence) {
+	if d.Time.IsZero() {
+		d.Time = time.Now().UTC()
+	}
+	divergencesFound.Inc()
+	log.Warn().Str("kind", string(d.Kind)).Str("bucket", d.Bucket).Str("key", d.Key).Msg("Shadow secondary diverged")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d.Kind == MirrorFailed || d.Kind == MirrorDropped {
+		m.report.MirrorFailed++
+	}
+	m.report.Divergences++
+	m.report.Recent = append(m.report.Recent, d)
+	if len(m.report.Recent) > recentDivergences {
+		m.report.Recent = m.report.Recent[1:]
+	}
+
+	if m.reportFile != nil {
+		line, _ := json.Marshal(d)
+		if _, err := m.reportFile.Write(append(line, '\n')); err != nil {
+			log.Error().Err(err).Msg("Failed to write shadow report")
+		}
+	}
+}
+
+// Report returns a summary of the mirroring since the server started.
+func (m *Mirror) Report() Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	report := m.report
+	report.Recent = append([]Divergence{}, m.report.Recent...)
+	return report
+}
+
+// Close stops mirroring new writes and waits for the queued ones.
+func (m *Mirror) Close() error {
+	m.sub.Close()
+	m.wg.Wait()
+	if m.reportFile != nil {
+		return m.reportFile.Close()
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package shadow
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/events"
+	"github.com/kumasuke/jog/internal/storage"
+)
+
+// fakeObject is an object stored by fakeS3.
+type fakeObject struct {
+	data        string
+	contentType string
+	meta        string
+}
+
+// fakeS3 is a secondary storing objects in memory, keyed by path.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = fakeObject{
+			data:        string(data),
+			contentType: r.Header.Get("Content-Type"),
+			meta:        r.Header.Get("X-Amz-Meta-Owner"),
+		}
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodHead:
+		obj, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sum := md5.Sum([]byte(obj.data))
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+	}
+}
+
+func TestMirror(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := storage.NewFileSystem(dir, filepath.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+	defer store.Close()
+	if err := store.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	secondary := &fakeS3{objects: map[string]fakeObject{}}
+	srv := httptest.NewServer(secondary)
+	defer srv.Close()
+
+	broker := events.NewBroker()
+	reportFile := filepath.Join(dir, "shadow.jsonl")
+	m, err := Start(broker, store, config.ShadowConfig{
+		Endpoint:   srv.URL,
+		AccessKey:  "AKID",
+		SecretKey:  "secret",
+		Workers:    2,
+		QueueSize:  10,
+		ReportFile: reportFile,
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	put := func(key, data string) storage.Object {
+		obj, err := store.PutObject(ctx, "bucket", key, strings.NewReader(data), int64(len(data)), "text/plain", map[string]string{"owner": "alice"})
+		if err != nil {
+			t.Fatalf("PutObject: %v", err)
+		}
+		broker.Publish(events.Event{Type: events.ObjectCreatedPut, Bucket: "bucket", Key: key})
+		return *obj
+	}
+
+	put("dir/kept.txt", "hello")
+	removed := put("removed", "bye")
+	if err := store.DeleteObject(ctx, "bucket", "removed"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	broker.Publish(events.Event{Type: events.ObjectRemovedDelete, Bucket: "bucket", Key: "removed"})
+	// Events of other tenants are not mirrored
+	broker.Publish(events.Event{Type: events.ObjectCreatedPut, Tenant: "acme", Bucket: "bucket", Key: "dir/kept.txt"})
+
+	// Compared after the writes queued before it
+	m.Compare("bucket", "dir/kept.txt", `"`+md5Hex("hello")+`"`, 5)
+	m.Compare("bucket", "removed", removed.ETag, removed.Size)
+
+	broker.Close()
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	obj, ok := secondary.objects["/bucket/dir/kept.txt"]
+	if !ok || obj.data != "hello" || obj.contentType != "text/plain" || obj.meta != "alice" {
+		t.Errorf("mirrored object = %+v, %v", obj, ok)
+	}
+	if _, ok := secondary.objects["/bucket/removed"]; ok {
+		t.Error("deleted object was not deleted on the secondary")
+	}
+
+	report := m.Report()
+	if report.Mirrored != 3 || report.MirrorFailed != 0 || report.Compared != 2 {
+		t.Errorf("report = %+v", report)
+	}
+	// The deleted object was read before the delete, so it is not reported
+	if report.Divergences != 0 {
+		t.Errorf("divergences = %+v", report.Recent)
+	}
+}
+
+func TestMirrorDivergences(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := storage.NewFileSystem(dir, filepath.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+	defer store.Close()
+	if err := store.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	for key, data := range map[string]string{"missing": "a", "changed": "new"} {
+		if _, err := store.PutObject(ctx, "bucket", key, strings.NewReader(data), int64(len(data)), "", nil); err != nil {
+			t.Fatalf("PutObject: %v", err)
+		}
+	}
+
+	secondary := &fakeS3{objects: map[string]fakeObject{"/bucket/changed": {data: "old"}}}
+	srv := httptest.NewServer(secondary)
+	defer srv.Close()
+
+	broker := events.NewBroker()
+	reportFile := filepath.Join(dir, "shadow.jsonl")
+	m, err := Start(broker, store, config.ShadowConfig{
+		Endpoint:   srv.URL,
+		AccessKey:  "AKID",
+		SecretKey:  "secret",
+		Workers:    1,
+		QueueSize:  10,
+		ReportFile: reportFile,
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	m.Compare("bucket", "missing", md5Hex("a"), 1)
+	m.Compare("bucket", "changed", md5Hex("new"), 3)
+	broker.Close()
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	report := m.Report()
+	if report.Divergences != 2 || len(report.Recent) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if d := report.Recent[0]; d.Kind != Missing || d.Key != "missing" {
+		t.Errorf("first divergence = %+v", d)
+	}
+	if d := report.Recent[1]; d.Kind != ETagMismatch || d.Local != md5Hex("new") || d.Secondary != md5Hex("old") {
+		t.Errorf("second divergence = %+v", d)
+	}
+
+	f, err := os.Open(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []Divergence
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var d Divergence
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			t.Fatalf("report line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, d)
+	}
+	if len(lines) != 2 || lines[0].Kind != Missing {
+		t.Errorf("report file = %+v", lines)
+	}
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// AccessKeySecrets are the secret keys of a rotated access key. They replace
+// the secret key of the access key in the configuration.
+type AccessKeySecrets struct {
+	AccessKey string
+	SecretKey string
+	// PreviousSecretKey is accepted as well until PreviousExpires, so that
+	// clients can move to the new secret key one by one. It is empty once
+	// revoked.
+	PreviousSecretKey string
+	PreviousExpires   time.Time
+}
+
+// PutAccessKeySecrets stores the secret keys of an access key, replacing
+// those stored before.
+func (m *Metadata) PutAccessKeySecrets(ctx context.Context, secrets *AccessKeySecrets) error {
+	var previousExpires int64
+	if !secrets.PreviousExpires.IsZero() {
+		previousExpires = secrets.PreviousExpires.UnixNano()
+	}
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO access_key_secrets (access_key, secret_key, previous_secret_key, previous_expires)
+		VALUES (?, ?, ?, ?)
+	`, secrets.AccessKey, secrets.SecretKey, secrets.PreviousSecretKey, previousExpires)
+	return err
+}
+
+// ListAccessKeySecrets returns the secret keys of all rotated access keys,
+// ordered by access key.
+func (m *Metadata) ListAccessKeySecrets(ctx context.Context) ([]AccessKeySecrets, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT access_key, secret_key, previous_secret_key, previous_expires
+		FROM access_key_secrets ORDER BY access_key
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []AccessKeySecrets
+	for rows.Next() {
+		var secrets AccessKeySecrets
+		var previousExpires int64
+		if err := rows.Scan(&secrets.AccessKey, &secrets.SecretKey, &secrets.PreviousSecretKey, &previousExpires); err != nil {
+			return nil, err
+		}
+		if previousExpires != 0 {
+			secrets.PreviousExpires = time.Unix(0, previousExpires).UTC()
+		}
+		list = append(list, secrets)
+	}
+	return list, rows.Err()
+}
+
+// PutAccessKeySecrets stores the secret keys of an access key in the
+// metadata database.
+func (fs *FileSystem) PutAccessKeySecrets(ctx context.Context, secrets *AccessKeySecrets) error {
+	return fs.metadata.PutAccessKeySecrets(ctx, secrets)
+}
+
+// ListAccessKeySecrets lists the secret keys of rotated access keys in the
+// metadata database.
+func (fs *FileSystem) ListAccessKeySecrets(ctx context.Context) ([]AccessKeySecrets, error) {
+	return fs.metadata.ListAccessKeySecrets(ctx)
+}
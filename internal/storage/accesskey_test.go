@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAccessKeySecrets(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if list, err := fs.ListAccessKeySecrets(ctx); err != nil || len(list) != 0 {
+		t.Fatalf("ListAccessKeySecrets = %v, %v, want none", list, err)
+	}
+
+	rotated := &AccessKeySecrets{
+		AccessKey:         "tenant",
+		SecretKey:         "new-secret",
+		PreviousSecretKey: "old-secret",
+		PreviousExpires:   time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC),
+	}
+	if err := fs.PutAccessKeySecrets(ctx, rotated); err != nil {
+		t.Fatalf("PutAccessKeySecrets: %v", err)
+	}
+	revoked := &AccessKeySecrets{AccessKey: "admin", SecretKey: "admin-secret"}
+	if err := fs.PutAccessKeySecrets(ctx, revoked); err != nil {
+		t.Fatalf("PutAccessKeySecrets: %v", err)
+	}
+
+	list, err := fs.ListAccessKeySecrets(ctx)
+	if err != nil {
+		t.Fatalf("ListAccessKeySecrets: %v", err)
+	}
+	if want := []AccessKeySecrets{*revoked, *rotated}; !reflect.DeepEqual(list, want) {
+		t.Errorf("ListAccessKeySecrets = %+v, want %+v", list, want)
+	}
+
+	// Revoking the previous secret key replaces the stored secret keys
+	rotated.PreviousSecretKey = ""
+	rotated.PreviousExpires = time.Time{}
+	if err := fs.PutAccessKeySecrets(ctx, rotated); err != nil {
+		t.Fatalf("PutAccessKeySecrets: %v", err)
+	}
+	list, err = fs.ListAccessKeySecrets(ctx)
+	if err != nil {
+		t.Fatalf("ListAccessKeySecrets: %v", err)
+	}
+	if len(list) != 2 || !reflect.DeepEqual(list[1], *rotated) {
+		t.Errorf("ListAccessKeySecrets after revoking = %+v, want %+v", list, *rotated)
+	}
+}
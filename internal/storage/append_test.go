@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppendObjectInPlace(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "logs"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	objectPath := filepath.Join(fs.dataDir, "logs", "app.log")
+
+	var content string
+	var info os.FileInfo
+	for i, line := range []string{"first\n", "second\n", "third\n"} {
+		obj, err := fs.AppendObject(ctx, "logs", "app.log", int64(len(content)), strings.NewReader(line), int64(len(line)), "text/plain", nil)
+		if err != nil {
+			t.Fatalf("AppendObject %d: %v", i, err)
+		}
+		content += line
+		sum := md5.Sum([]byte(content))
+		if want := hex.EncodeToString(sum[:]); obj.ETag != want || obj.Size != int64(len(content)) {
+			t.Errorf("append %d = ETag %s, size %d, want %s, %d", i, obj.ETag, obj.Size, want, len(content))
+		}
+
+		// Appends extend the file of the object instead of replacing it
+		next, err := os.Stat(objectPath)
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if info != nil && !os.SameFile(info, next) {
+			t.Errorf("append %d replaced the object file", i)
+		}
+		info = next
+	}
+
+	// Bodies shorter than their size are rejected and leave the object as is
+	_, err := fs.AppendObject(ctx, "logs", "app.log", int64(len(content)), strings.NewReader("trunc"), 10, "", nil)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("AppendObject with a truncated body = %v, want io.ErrUnexpectedEOF", err)
+	}
+	data, err := fs.GetObject(ctx, "logs", "app.log")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	defer data.Body.Close()
+	got, _ := io.ReadAll(data.Body)
+	if string(got) != content || data.Size != int64(len(content)) {
+		t.Errorf("GetObject after a truncated append = %q (size %d), want %q", got, data.Size, content)
+	}
+
+	// Without a recorded digest, the object is read once to hash it
+	if _, err := fs.metadata.db.Exec(`DELETE FROM object_append_digests`); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := fs.AppendObject(ctx, "logs", "app.log", int64(len(content)), strings.NewReader("fourth\n"), 7, "", nil)
+	if err != nil {
+		t.Fatalf("AppendObject: %v", err)
+	}
+	sum := md5.Sum([]byte(content + "fourth\n"))
+	if want := hex.EncodeToString(sum[:]); obj.ETag != want {
+		t.Errorf("ETag without a recorded digest = %s, want %s", obj.ETag, want)
+	}
+}
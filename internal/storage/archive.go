@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// archiveFormatVersion is the version of the bucket archive layout.
+	archiveFormatVersion = 1
+	// archiveHeaderFile is the first entry of an archive.
+	archiveHeaderFile = "jog-export.json"
+	// archiveObjectsDir holds one sidecar and one data entry per object.
+	archiveObjectsDir = "objects/"
+)
+
+// ErrInvalidArchive is returned when a bucket archive cannot be read.
+var ErrInvalidArchive = errors.New("invalid archive")
+
+// ExportOptions holds options for exporting a bucket.
+type ExportOptions struct {
+	// IncludeVersions exports all object versions and delete markers instead of
+	// only the current objects.
+	IncludeVersions bool
+}
+
+// ArchiveHeader is the first entry of a bucket archive.
+type ArchiveHeader struct {
+	Version    int       `json:"version"`
+	Bucket     string    `json:"bucket"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Versioned  bool      `json:"versioned"`
+	Tags       []Tag     `json:"tags,omitempty"`
+}
+
+// ArchiveObject is the metadata sidecar of an object in a bucket archive.
+// It is followed by the object data, except for delete markers.
+type ArchiveObject struct {
+	Key          string            `json:"key"`
+	VersionID    string            `json:"versionId,omitempty"`
+	DeleteMarker bool              `json:"deleteMarker,omitempty"`
+	LastModified time.Time         `json:"lastModified"`
+	Size         int64             `json:"size"`
+	ContentType  string            `json:"contentType,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Tags         []Tag             `json:"tags,omitempty"`
+}
+
+// ExportBucket writes the objects of a bucket to w as a tar stream and returns
+// the number of exported entries. Versions of a key are written oldest first,
+// so importing them in order recreates the version history.
+func ExportBucket(ctx context.Context, s Storage, bucket string, w io.Writer, opts ExportOptions) (int, error) {
+	if _, err := s.HeadBucket(ctx, bucket); err != nil {
+		return 0, err
+	}
+
+	header := ArchiveHeader{
+		Version:    archiveFormatVersion,
+		Bucket:     bucket,
+		ExportedAt: time.Now().UTC(),
+		Versioned:  opts.IncludeVersions,
+	}
+	tags, err := s.GetBucketTagging(ctx, bucket)
+	if err != nil && !errors.Is(err, ErrNoSuchTagSet) && !errors.Is(err, ErrNotImplemented) {
+		return 0, err
+	}
+	header.Tags = tags
+
+	tw := tar.NewWriter(w)
+	if err := writeTarJSON(tw, archiveHeaderFile, header); err != nil {
+		return 0, err
+	}
+
+	ex := &exporter{storage: s, bucket: bucket, tw: tw}
+	if opts.IncludeVersions {
+		err = ex.exportVersions(ctx)
+	} else {
+		err = ex.exportObjects(ctx)
+	}
+	if err != nil {
+		return ex.count, err
+	}
+
+	return ex.count, tw.Close()
+}
+
+// exporter writes archive entries for a single bucket.
+type exporter struct {
+	storage Storage
+	bucket  string
+	tw      *tar.Writer
+	count   int
+}
+
+// exportObjects writes the current objects of the bucket.
+func (e *exporter) exportObjects(ctx context.Context) error {
+	input := &ListObjectsInput{Bucket: e.bucket, MaxKeys: 1000}
+	for {
+		output, err := e.storage.ListObjectsV2(ctx, input)
+		if err != nil {
+			return err
+		}
+		for _, obj := range output.Objects {
+			data, err := e.storage.GetObject(ctx, e.bucket, obj.Key)
+			if errors.Is(err, ErrObjectNotFound) {
+				// Deleted while the export was running
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			entry := ArchiveObject{
+				Key:          obj.Key,
+				LastModified: data.LastModified,
+				Size:         data.Size,
+				ContentType:  data.ContentType,
+				Metadata:     data.Metadata,
+			}
+			err = e.writeObject(ctx, entry, data.Body, true)
+			data.Body.Close()
+			if err != nil {
+				return err
+			}
+		}
+		if !output.IsTruncated {
+			return nil
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+}
+
+// exportVersions writes all versions and delete markers of the bucket.
+func (e *exporter) exportVersions(ctx context.Context) error {
+	input := &ListObjectVersionsInput{Bucket: e.bucket, MaxKeys: 1000}
+	var pending []ObjectVersion
+	for {
+		output, err := e.storage.ListObjectVersions(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		versions := append(output.Versions, output.DeleteMarkers...)
+		sort.SliceStable(versions, func(i, j int) bool { return versions[i].Key < versions[j].Key })
+		for _, v := range versions {
+			// Versions of one key may span several pages; flush once the key changes
+			if len(pending) > 0 && pending[0].Key != v.Key {
+				if err := e.writeVersions(ctx, pending); err != nil {
+					return err
+				}
+				pending = nil
+			}
+			pending = append(pending, v)
+		}
+
+		if !output.IsTruncated {
+			break
+		}
+		input.KeyMarker = output.NextKeyMarker
+		input.VersionIdMarker = output.NextVersionIdMarker
+	}
+
+	if len(pending) > 0 {
+		return e.writeVersions(ctx, pending)
+	}
+	return nil
+}
+
+// writeVersions writes the versions of a single key, oldest first.
+func (e *exporter) writeVersions(ctx context.Context, versions []ObjectVersion) error {
+	// Versions are listed newest first; reverse them so ties keep that order
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.Before(versions[j].LastModified)
+	})
+
+	for i, v := range versions {
+		latest := i == len(versions)-1
+		entry := ArchiveObject{
+			Key:          v.Key,
+			VersionID:    v.VersionID,
+			DeleteMarker: v.IsDeleteMarker,
+			LastModified: v.LastModified,
+		}
+		if v.IsDeleteMarker {
+			if err := e.writeObject(ctx, entry, nil, false); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := e.storage.GetObjectVersioned(ctx, e.bucket, v.Key, v.VersionID)
+		if errors.Is(err, ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entry.Size = data.Size
+		entry.ContentType = data.ContentType
+		entry.Metadata = data.Metadata
+		err = e.writeObject(ctx, entry, data.Body, latest)
+		data.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeObject writes the sidecar and the data of an object. Tags are exported
+// for the current version only, as they are stored per key.
+func (e *exporter) writeObject(ctx context.Context, entry ArchiveObject, body io.Reader, withTags bool) error {
+	if withTags {
+		tags, err := e.storage.GetObjectTagging(ctx, e.bucket, entry.Key)
+		if err != nil && !errors.Is(err, ErrObjectNotFound) && !errors.Is(err, ErrNotImplemented) {
+			return err
+		}
+		entry.Tags = tags
+	}
+
+	name := archiveObjectsDir + strconv.Itoa(e.count)
+	if err := writeTarJSON(e.tw, name+".json", entry); err != nil {
+		return err
+	}
+	if body != nil {
+		if err := e.tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    entry.Size,
+			ModTime: entry.LastModified,
+		}); err != nil {
+			return err
+		}
+		if _, err := io.Copy(e.tw, body); err != nil {
+			return fmt.Errorf("failed to export %s: %w", entry.Key, err)
+		}
+	}
+	e.count++
+	return nil
+}
+
+// ImportBucket loads a bucket archive written by ExportBucket into bucket,
+// creating the bucket if needed, and returns the number of imported entries.
+// Archives with versions enable versioning on the target bucket.
+func ImportBucket(ctx context.Context, s Storage, bucket string, r io.Reader) (int, error) {
+	tr := tar.NewReader(r)
+
+	var header ArchiveHeader
+	if err := readTarJSON(tr, archiveHeaderFile, &header); err != nil {
+		return 0, err
+	}
+	if header.Version != archiveFormatVersion {
+		return 0, fmt.Errorf("%w: unsupported archive version %d", ErrInvalidArchive, header.Version)
+	}
+	if bucket == "" {
+		bucket = header.Bucket
+	}
+
+	if err := s.CreateBucket(ctx, bucket); err != nil && !errors.Is(err, ErrBucketAlreadyExists) {
+		return 0, err
+	}
+	if len(header.Tags) > 0 {
+		if err := s.PutBucketTagging(ctx, bucket, header.Tags); err != nil {
+			return 0, err
+		}
+	}
+	if header.Versioned {
+		if err := s.PutBucketVersioning(ctx, bucket, VersioningStatusEnabled); err != nil {
+			return 0, err
+		}
+	}
+
+	count := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		if !strings.HasPrefix(hdr.Name, archiveObjectsDir) || !strings.HasSuffix(hdr.Name, ".json") {
+			return count, fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, hdr.Name)
+		}
+
+		var entry ArchiveObject
+		if err := json.NewDecoder(tr).Decode(&entry); err != nil {
+			return count, fmt.Errorf("%w: failed to parse %s: %v", ErrInvalidArchive, hdr.Name, err)
+		}
+
+		if entry.DeleteMarker {
+			if _, _, err := s.DeleteObjectVersioned(ctx, bucket, entry.Key, ""); err != nil {
+				return count, err
+			}
+			count++
+			continue
+		}
+
+		dataName := strings.TrimSuffix(hdr.Name, ".json")
+		hdr, err = tr.Next()
+		if err != nil {
+			return count, fmt.Errorf("%w: missing data for %s: %v", ErrInvalidArchive, entry.Key, err)
+		}
+		if hdr.Name != dataName || hdr.Size != entry.Size {
+			return count, fmt.Errorf("%w: unexpected data entry %q for %s", ErrInvalidArchive, hdr.Name, entry.Key)
+		}
+
+		if header.Versioned {
+			_, _, err = s.PutObjectVersioned(ctx, bucket, entry.Key, tr, entry.Size, entry.ContentType, entry.Metadata)
+		} else {
+			_, err = s.PutObject(ctx, bucket, entry.Key, tr, entry.Size, entry.ContentType, entry.Metadata)
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to import %s: %w", entry.Key, err)
+		}
+		if len(entry.Tags) > 0 {
+			if err := s.PutObjectTagging(ctx, bucket, entry.Key, entry.Tags); err != nil {
+				return count, err
+			}
+		}
+		count++
+	}
+}
+
+// writeTarJSON writes v as a JSON file entry.
+func writeTarJSON(tw *tar.Writer, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// readTarJSON reads the next entry, which must be named name, as JSON into v.
+func readTarJSON(tr *tar.Reader, name string, v interface{}) error {
+	hdr, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if hdr.Name != name {
+		return fmt.Errorf("%w: expected %s, got %q", ErrInvalidArchive, name, hdr.Name)
+	}
+	if err := json.NewDecoder(tr).Decode(v); err != nil {
+		return fmt.Errorf("%w: failed to parse %s: %v", ErrInvalidArchive, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestFileSystem(t *testing.T) *FileSystem {
+	t.Helper()
+	dir := t.TempDir()
+	fs, err := NewFileSystem(dir, filepath.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("failed to create filesystem: %v", err)
+	}
+	t.Cleanup(func() { fs.Close() })
+	return fs
+}
+
+func TestExportImportBucket(t *testing.T) {
+	ctx := context.Background()
+	src := newTestFileSystem(t)
+
+	if err := src.CreateBucket(ctx, "source"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := src.PutObject(ctx, "source", "docs/a.txt", strings.NewReader("alpha"), 5, "text/plain", map[string]string{"owner": "alice"}); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, err := src.PutObject(ctx, "source", "b.bin", strings.NewReader("bravo!"), 6, "application/octet-stream", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if err := src.PutObjectTagging(ctx, "source", "docs/a.txt", []Tag{{Key: "env", Value: "prod"}}); err != nil {
+		t.Fatalf("PutObjectTagging: %v", err)
+	}
+
+	var archive bytes.Buffer
+	count, err := ExportBucket(ctx, src, "source", &archive, ExportOptions{})
+	if err != nil {
+		t.Fatalf("ExportBucket: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("exported %d objects, want 2", count)
+	}
+
+	dst := newTestFileSystem(t)
+	if _, err := ImportBucket(ctx, dst, "target", &archive); err != nil {
+		t.Fatalf("ImportBucket: %v", err)
+	}
+
+	if got := readObject(t, dst, "target", "docs/a.txt"); got != "alpha" {
+		t.Errorf("docs/a.txt = %q, want %q", got, "alpha")
+	}
+	if got := readObject(t, dst, "target", "b.bin"); got != "bravo!" {
+		t.Errorf("b.bin = %q, want %q", got, "bravo!")
+	}
+
+	obj, err := dst.HeadObject(ctx, "target", "docs/a.txt")
+	if err != nil {
+		t.Fatalf("HeadObject: %v", err)
+	}
+	if obj.ContentType != "text/plain" || obj.Metadata["owner"] != "alice" {
+		t.Errorf("unexpected metadata: content type %q, metadata %v", obj.ContentType, obj.Metadata)
+	}
+
+	tags, err := dst.GetObjectTagging(ctx, "target", "docs/a.txt")
+	if err != nil {
+		t.Fatalf("GetObjectTagging: %v", err)
+	}
+	if len(tags) != 1 || tags[0].Key != "env" || tags[0].Value != "prod" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+}
+
+func TestExportImportBucket_Versions(t *testing.T) {
+	ctx := context.Background()
+	src := newTestFileSystem(t)
+
+	if err := src.CreateBucket(ctx, "source"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := src.PutBucketVersioning(ctx, "source", VersioningStatusEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	for _, body := range []string{"v1", "v2"} {
+		if _, _, err := src.PutObjectVersioned(ctx, "source", "key", strings.NewReader(body), 2, "", nil); err != nil {
+			t.Fatalf("PutObjectVersioned: %v", err)
+		}
+	}
+
+	var archive bytes.Buffer
+	count, err := ExportBucket(ctx, src, "source", &archive, ExportOptions{IncludeVersions: true})
+	if err != nil {
+		t.Fatalf("ExportBucket: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("exported %d versions, want 2", count)
+	}
+
+	dst := newTestFileSystem(t)
+	if _, err := ImportBucket(ctx, dst, "", &archive); err != nil {
+		t.Fatalf("ImportBucket: %v", err)
+	}
+
+	if got := readObject(t, dst, "source", "key"); got != "v2" {
+		t.Errorf("current version = %q, want %q", got, "v2")
+	}
+	versions, err := dst.ListObjectVersions(ctx, &ListObjectVersionsInput{Bucket: "source", MaxKeys: 1000})
+	if err != nil {
+		t.Fatalf("ListObjectVersions: %v", err)
+	}
+	if len(versions.Versions) != 2 {
+		t.Errorf("imported %d versions, want 2", len(versions.Versions))
+	}
+}
+
+func TestImportBucket_InvalidArchive(t *testing.T) {
+	dst := newTestFileSystem(t)
+	_, err := ImportBucket(context.Background(), dst, "target", strings.NewReader("not a tar archive"))
+	if err == nil {
+		t.Fatal("expected error for invalid archive")
+	}
+}
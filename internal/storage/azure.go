@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// azureAPIVersion is the Azure Blob Storage REST API version used for requests.
+const azureAPIVersion = "2021-08-06"
+
+// AzureBlobStore implements BlobStore using Azure Blob Storage with Shared Key authentication.
+type AzureBlobStore struct {
+	accountName string
+	accountKey  []byte
+	container   string
+	endpoint    string
+	client      *http.Client
+}
+
+// NewAzureBlobStore creates a new Azure Blob Storage blob store.
+// If endpoint is empty, https://{accountName}.blob.core.windows.net is used.
+// For Azurite, use an endpoint such as http://127.0.0.1:10000/devstoreaccount1.
+func NewAzureBlobStore(accountName, accountKey, container, endpoint string) (*AzureBlobStore, error) {
+	if accountName == "" || accountKey == "" || container == "" {
+		return nil, fmt.Errorf("azure account name, account key and container are required")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid azure account key: %w", err)
+	}
+
+	if endpoint == "" {
+		endpoint = "https://" + accountName + ".blob.core.windows.net"
+	}
+
+	return &AzureBlobStore{
+		accountName: accountName,
+		accountKey:  key,
+		container:   container,
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		client:      newBlobHTTPClient(),
+	}, nil
+}
+
+// blobURL returns the URL of a blob in the container.
+func (a *AzureBlobStore) blobURL(name string) string {
+	return a.endpoint + "/" + a.container + "/" + escapeBlobName(name)
+}
+
+// PutBlob uploads a block blob.
+func (a *AzureBlobStore) PutBlob(ctx context.Context, name string, body io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, a.blobURL(name), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+
+	resp, err := a.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetBlob downloads a blob or a range of it.
+func (a *AzureBlobStore) GetBlob(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.blobURL(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	if rangeHeader := formatRange(offset, length); rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	resp, err := a.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// DeleteBlob deletes a blob.
+func (a *AzureBlobStore) DeleteBlob(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, a.blobURL(name), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.do(req)
+	if err != nil {
+		if err == ErrBlobNotFound {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do signs and sends a request, converting error responses into errors.
+func (a *AzureBlobStore) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	req.Header.Set("Authorization", "SharedKey "+a.accountName+":"+a.sign(req))
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("azure %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// sign computes the Shared Key signature for a request.
+func (a *AzureBlobStore) sign(req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	// Canonicalized headers: all x-ms-* headers, lowercased and sorted
+	var msHeaders []string
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-ms-") {
+			msHeaders = append(msHeaders, lower)
+		}
+	}
+	sort.Strings(msHeaders)
+	var canonicalHeaders strings.Builder
+	for _, name := range msHeaders {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	// Canonicalized resource: /{account}{path} followed by sorted query parameters
+	canonicalResource := "/" + a.accountName + req.URL.EscapedPath()
+	query := req.URL.Query()
+	var params []string
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		values := query[name]
+		sort.Strings(values)
+		canonicalResource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date (x-ms-date is used instead)
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}, "\n") + "\n" + canonicalHeaders.String() + canonicalResource
+
+	mac := hmac.New(sha256.New, a.accountKey)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// escapeBlobName escapes each path segment of a blob name, preserving slashes.
+func escapeBlobName(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// formatRange returns an HTTP Range header value, or "" for the whole blob.
+func formatRange(offset, length int64) string {
+	if offset == 0 && length < 0 {
+		return ""
+	}
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// backupFormatVersion is the version of the backup layout.
+	backupFormatVersion = 1
+	// backupManifestFile is written last, so its presence marks a complete backup.
+	backupManifestFile = "manifest.json"
+	// backupMetadataFile is the SQLite snapshot inside a backup.
+	backupMetadataFile = "metadata.db"
+	// backupDataDir holds the copied data files inside a backup.
+	backupDataDir = "data"
+)
+
+// ErrInvalidBackup is returned when a backup is incomplete or fails verification.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// BackupManifest describes the contents of a backup.
+type BackupManifest struct {
+	Version   int          `json:"version"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Files     []BackupFile `json:"files"`
+}
+
+// BackupFile is a data file in a backup. Path is relative to the data directory.
+type BackupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Backup creates a backup of the metadata database and the data directory in outDir.
+// It can run while the server is serving requests: the database is snapshotted
+// with VACUUM INTO, and data files are replaced atomically by the server, so each
+// copied file is complete. Objects written during the backup may be missing from it.
+func Backup(ctx context.Context, dataDir, metadataDB, outDir string) (*BackupManifest, error) {
+	if rel, err := filepath.Rel(absPath(dataDir), absPath(outDir)); err == nil && filepath.IsLocal(rel) {
+		return nil, fmt.Errorf("backup directory must not be inside the data directory")
+	}
+	if err := ensureEmptyDir(outDir); err != nil {
+		return nil, err
+	}
+
+	// Snapshot the metadata database
+	db, err := sql.Open("sqlite", metadataDB+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	snapshotPath := filepath.Join(outDir, backupMetadataFile)
+	if _, err := db.ExecContext(ctx, "VACUUM INTO '"+strings.ReplaceAll(snapshotPath, "'", "''")+"'"); err != nil {
+		return nil, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	manifest := &BackupManifest{
+		Version:   backupFormatVersion,
+		CreatedAt: time.Now().UTC(),
+		Files:     []BackupFile{},
+	}
+
+	skip := metadataFiles(metadataDB)
+	err = filepath.WalkDir(dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dataDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// The object cache of remote backends can be rebuilt
+			if rel == ".cache" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".tmp-") || skip[absPath(path)] {
+			return nil
+		}
+
+		file, err := copyFileWithHash(path, filepath.Join(outDir, backupDataDir, rel))
+		if err != nil {
+			// The object was deleted while the backup was running
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		file.Path = filepath.ToSlash(rel)
+		manifest.Files = append(manifest.Files, *file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy data files: %w", err)
+	}
+
+	if err := writeJSONFile(filepath.Join(outDir, backupManifestFile), manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Restore restores a backup created by Backup into dataDir and metadataDB.
+// dataDir must be empty or not exist, and metadataDB must not exist.
+// Every data file is verified against the manifest.
+func Restore(ctx context.Context, backupDir, dataDir, metadataDB string) (*BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(backupDir, backupManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read manifest: %v", ErrInvalidBackup, err)
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse manifest: %v", ErrInvalidBackup, err)
+	}
+	if manifest.Version != backupFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported backup version %d", ErrInvalidBackup, manifest.Version)
+	}
+
+	if _, err := os.Stat(metadataDB); err == nil {
+		return nil, fmt.Errorf("metadata database %s already exists", metadataDB)
+	}
+	if err := ensureEmptyDir(dataDir); err != nil {
+		return nil, err
+	}
+
+	for _, file := range manifest.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Reject paths escaping the data directory
+		rel := filepath.FromSlash(file.Path)
+		if !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("%w: invalid path %q", ErrInvalidBackup, file.Path)
+		}
+
+		copied, err := copyFileWithHash(filepath.Join(backupDir, backupDataDir, rel), filepath.Join(dataDir, rel))
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", file.Path, err)
+		}
+		if copied.Size != file.Size || copied.SHA256 != file.SHA256 {
+			return nil, fmt.Errorf("%w: checksum mismatch for %s", ErrInvalidBackup, file.Path)
+		}
+	}
+
+	if _, err := copyFileWithHash(filepath.Join(backupDir, backupMetadataFile), metadataDB); err != nil {
+		return nil, fmt.Errorf("failed to restore database: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// metadataFiles returns the absolute paths of the database and its journal files.
+func metadataFiles(metadataDB string) map[string]bool {
+	db := absPath(metadataDB)
+	return map[string]bool{
+		db:              true,
+		db + "-wal":     true,
+		db + "-shm":     true,
+		db + "-journal": true,
+	}
+}
+
+// absPath returns the absolute form of path, or path itself if that fails.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// ensureEmptyDir creates dir if needed and fails if it already has entries.
+func ensureEmptyDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("directory %s is not empty", dir)
+	}
+	return nil
+}
+
+// copyFileWithHash copies src to dst through a temp file and returns the size and SHA-256 of the data.
+func copyFileWithHash(src, dst string) (*BackupFile, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), in)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return nil, err
+	}
+
+	return &BackupFile{
+		Size:   written,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// writeJSONFile writes v as indented JSON to path.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "data")
+	fs, err := NewFileSystem(dataDir, filepath.Join(dataDir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("failed to create filesystem: %v", err)
+	}
+	defer fs.Close()
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := fs.PutObject(ctx, "bucket", "dir/key.txt", strings.NewReader("hello backup"), 12, "text/plain", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	backupDir := filepath.Join(dir, "backup")
+	manifest, err := Backup(ctx, dataDir, filepath.Join(dataDir, "metadata.db"), backupDir)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if len(manifest.Files) != 1 || manifest.Files[0].Path != "bucket/dir/key.txt" {
+		t.Fatalf("unexpected manifest files: %+v", manifest.Files)
+	}
+
+	restoreDir := filepath.Join(dir, "restored")
+	if _, err := Restore(ctx, backupDir, restoreDir, filepath.Join(restoreDir, "metadata.db")); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	restored, err := NewFileSystem(restoreDir, filepath.Join(restoreDir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("failed to open restored filesystem: %v", err)
+	}
+	defer restored.Close()
+
+	if got := readObject(t, restored, "bucket", "dir/key.txt"); got != "hello backup" {
+		t.Errorf("restored object = %q, want %q", got, "hello backup")
+	}
+
+	// Restoring over existing data is refused
+	if _, err := Restore(ctx, backupDir, restoreDir, filepath.Join(dir, "other.db")); err == nil {
+		t.Error("expected restore into non-empty directory to fail")
+	}
+}
+
+func TestRestore_ChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "data")
+	fs, err := NewFileSystem(dataDir, filepath.Join(dataDir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("failed to create filesystem: %v", err)
+	}
+	defer fs.Close()
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := fs.PutObject(ctx, "bucket", "key", strings.NewReader("original"), 8, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	backupDir := filepath.Join(dir, "backup")
+	if _, err := Backup(ctx, dataDir, filepath.Join(dataDir, "metadata.db"), backupDir); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDir, "data", "bucket", "key"), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	restoreDir := filepath.Join(dir, "restored")
+	_, err = Restore(ctx, backupDir, restoreDir, filepath.Join(restoreDir, "metadata.db"))
+	if !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Restore error = %v, want ErrInvalidBackup", err)
+	}
+}
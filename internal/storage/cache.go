@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachingBlobStore wraps a BlobStore and keeps a local copy of blobs on disk.
+// Uploads are written through to the upstream store and cached; full reads that
+// miss the cache are fetched from upstream and cached as they are streamed.
+// When maxBytes is positive, the least recently used blobs are evicted to keep
+// the cache below that size.
+type CachingBlobStore struct {
+	upstream BlobStore
+	dir      string
+	maxBytes int64
+
+	mu sync.Mutex
+	// generations count the writes and deletes of blobs, spread over
+	// stripes like key locks. A copy is only committed if no write or delete
+	// of its blob started since it was read, so that a read racing with a
+	// write never caches the data the write replaced. Guarded by mu.
+	generations [keyLockStripes]uint64
+}
+
+// NewCachingBlobStore creates a new caching blob store using dir for cached data.
+func NewCachingBlobStore(upstream BlobStore, dir string, maxBytes int64) (*CachingBlobStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &CachingBlobStore{
+		upstream: upstream,
+		dir:      dir,
+		maxBytes: maxBytes,
+	}, nil
+}
+
+// cachePath returns the path of the cached copy of a blob.
+func (c *CachingBlobStore) cachePath(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// generationStripe returns the index of the generation counter of a blob.
+func generationStripe(name string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return h.Sum32() % keyLockStripes
+}
+
+// generation returns the current generation of a blob.
+func (c *CachingBlobStore) generation(name string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[generationStripe(name)]
+}
+
+// PutBlob uploads a blob to the upstream store and caches it.
+func (c *CachingBlobStore) PutBlob(ctx context.Context, name string, body io.Reader, size int64) error {
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// Remove the stale copy first so a failed upload never leaves old data behind
+	gen := c.remove(name)
+
+	if err := c.upstream.PutBlob(ctx, name, io.TeeReader(body, tmp), size); err != nil {
+		tmp.Close()
+		return err
+	}
+	// The upload succeeded; caching is best effort
+	if err := tmp.Close(); err == nil {
+		c.commit(tmp.Name(), name, gen)
+	}
+	return nil
+}
+
+// GetBlob reads a blob from the cache, falling back to the upstream store.
+func (c *CachingBlobStore) GetBlob(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	path := c.cachePath(name)
+	if f, err := os.Open(path); err == nil {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if length < 0 {
+			return f, nil
+		}
+		return &limitedReadCloser{Reader: io.LimitReader(f, length), Closer: f}, nil
+	}
+
+	gen := c.generation(name)
+	rc, err := c.upstream.GetBlob(ctx, name, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	if offset != 0 || length >= 0 {
+		return rc, nil
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return rc, nil
+	}
+	return &cachingReader{cache: c, name: name, gen: gen, body: rc, tmp: tmp}, nil
+}
+
+// DeleteBlob deletes a blob from the upstream store and the cache.
+func (c *CachingBlobStore) DeleteBlob(ctx context.Context, name string) error {
+	c.remove(name)
+	return c.upstream.DeleteBlob(ctx, name)
+}
+
+// remove drops the cached copy of a blob before it is written or deleted,
+// and returns the generation of the write.
+func (c *CachingBlobStore) remove(name string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	os.Remove(c.cachePath(name))
+	stripe := generationStripe(name)
+	c.generations[stripe]++
+	return c.generations[stripe]
+}
+
+// commit moves a fully written temp file into the cache and evicts old
+// entries. The file is dropped if the blob was written or deleted since gen.
+func (c *CachingBlobStore) commit(tmpPath, name string, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generations[generationStripe(name)] != gen {
+		os.Remove(tmpPath)
+		return
+	}
+	if err := os.Rename(tmpPath, c.cachePath(name)); err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+	c.evict()
+}
+
+// evict removes the least recently used cache entries until the cache fits in maxBytes.
+// The caller must hold c.mu.
+func (c *CachingBlobStore) evict() {
+	if c.maxBytes <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	var files []os.FileInfo
+	var total int64
+	for _, entry := range entries {
+		// Skip in-progress temp files
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, info := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, info.Name())); err == nil {
+			total -= info.Size()
+		}
+	}
+}
+
+// limitedReadCloser combines a limited reader with the closer of the underlying file.
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// cachingReader streams an upstream blob while copying it into the cache.
+// The copy is only committed if the whole blob was read successfully.
+type cachingReader struct {
+	cache *CachingBlobStore
+	name  string
+	// gen is the generation of the blob when the read started.
+	gen    uint64
+	body   io.ReadCloser
+	tmp    *os.File
+	failed bool
+	done   bool
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if n > 0 && !r.failed {
+		if _, werr := r.tmp.Write(p[:n]); werr != nil {
+			r.failed = true
+		}
+	}
+	if err == io.EOF {
+		r.done = true
+	} else if err != nil {
+		r.failed = true
+	}
+	return n, err
+}
+
+func (r *cachingReader) Close() error {
+	err := r.body.Close()
+	closeErr := r.tmp.Close()
+	if r.done && !r.failed && closeErr == nil {
+		r.cache.commit(r.tmp.Name(), r.name, r.gen)
+	} else {
+		os.Remove(r.tmp.Name())
+	}
+	return err
+}
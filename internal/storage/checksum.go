@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"strings"
+)
+
+// Checksum is the checksum sent with a part upload. Value is the base64
+// encoded checksum of the part, or "" when only the algorithm was given and
+// the checksum is computed from the data.
+type Checksum struct {
+	Algorithm string
+	Value     string
+}
+
+// ValidChecksumAlgorithm reports whether algorithm is a supported checksum
+// algorithm: CRC32, CRC32C, SHA1 or SHA256.
+func ValidChecksumAlgorithm(algorithm string) bool {
+	return NewChecksumHash(algorithm) != nil
+}
+
+// NewChecksumHash returns a hash for a checksum algorithm, or nil if the
+// algorithm is unknown.
+func NewChecksumHash(algorithm string) hash.Hash {
+	switch strings.ToUpper(algorithm) {
+	case "CRC32":
+		return crc32.NewIEEE()
+	case "CRC32C":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "SHA1":
+		return sha1.New()
+	case "SHA256":
+		return sha256.New()
+	default:
+		return nil
+	}
+}
+
+// partChecksumHash returns the checksum algorithm of a part and a hash
+// computing it: the algorithm of its multipart upload, or else the algorithm
+// sent with the part. It returns a nil hash when neither asks for a checksum.
+func partChecksumHash(ctx context.Context, upload *MultipartUpload) (string, hash.Hash, error) {
+	algorithm := upload.ChecksumAlgorithm
+	if checksum := ChecksumFromContext(ctx); checksum != nil {
+		if algorithm != "" && !strings.EqualFold(algorithm, checksum.Algorithm) {
+			return "", nil, ErrChecksumAlgorithmMismatch
+		}
+		algorithm = checksum.Algorithm
+	}
+	if algorithm == "" {
+		return "", nil, nil
+	}
+	h := NewChecksumHash(algorithm)
+	if h == nil {
+		return "", nil, ErrChecksumAlgorithmMismatch
+	}
+	return strings.ToUpper(algorithm), h, nil
+}
+
+// partChecksum returns the base64 encoded sum of h, the checksum of a part,
+// and verifies it against the checksum sent with the part.
+func partChecksum(ctx context.Context, h hash.Hash) (string, error) {
+	if h == nil {
+		return "", nil
+	}
+	sum := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	if checksum := ChecksumFromContext(ctx); checksum != nil && checksum.Value != "" && checksum.Value != sum {
+		return "", ErrBadDigest
+	}
+	return sum, nil
+}
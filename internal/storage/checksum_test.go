@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+func crc32Checksum(data string) string {
+	sum := crc32.ChecksumIEEE([]byte(data))
+	return base64.StdEncoding.EncodeToString([]byte{byte(sum >> 24), byte(sum >> 16), byte(sum >> 8), byte(sum)})
+}
+
+func TestMultipartChecksums(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	upload, err := fs.CreateMultipartUpload(WithChecksum(ctx, &Checksum{Algorithm: "crc32"}), "bucket", "big.bin", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	if upload.ChecksumAlgorithm != "CRC32" {
+		t.Errorf("ChecksumAlgorithm = %q, want CRC32", upload.ChecksumAlgorithm)
+	}
+
+	// A part whose checksum does not match is rejected and not stored
+	badCtx := WithChecksum(ctx, &Checksum{Algorithm: "CRC32", Value: crc32Checksum("other")})
+	if _, err := fs.UploadPart(badCtx, "bucket", "big.bin", upload.UploadID, 1, strings.NewReader("aaaa"), 4); !errors.Is(err, ErrBadDigest) {
+		t.Fatalf("UploadPart with a bad checksum: err = %v, want ErrBadDigest", err)
+	}
+	if part, err := fs.metadata.GetPart(ctx, upload.UploadID, 1); err != nil || part != nil {
+		t.Fatalf("GetPart = %+v, %v, want no part", part, err)
+	}
+	shaCtx := WithChecksum(ctx, &Checksum{Algorithm: "SHA256"})
+	if _, err := fs.UploadPart(shaCtx, "bucket", "big.bin", upload.UploadID, 1, strings.NewReader("aaaa"), 4); !errors.Is(err, ErrChecksumAlgorithmMismatch) {
+		t.Fatalf("UploadPart with another algorithm: err = %v, want ErrChecksumAlgorithmMismatch", err)
+	}
+
+	// Parts get checksums whether or not one was sent
+	partCtx := WithChecksum(ctx, &Checksum{Algorithm: "CRC32", Value: crc32Checksum("aaaa")})
+	part1, err := fs.UploadPart(partCtx, "bucket", "big.bin", upload.UploadID, 1, strings.NewReader("aaaa"), 4)
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	part2, err := fs.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 2, strings.NewReader("bb"), 2)
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	progress, err := fs.UploadPartRange(ctx, "bucket", "big.bin", upload.UploadID, 3, 0, strings.NewReader("cc"), 2, 2)
+	if err != nil {
+		t.Fatalf("UploadPartRange: %v", err)
+	}
+	part3 := progress.Part
+	for i, tc := range []struct {
+		part *Part
+		data string
+	}{{part1, "aaaa"}, {part2, "bb"}, {part3, "cc"}} {
+		if tc.part.ChecksumAlgorithm != "CRC32" || tc.part.Checksum != crc32Checksum(tc.data) {
+			t.Errorf("part %d checksum = %s %q, want CRC32 %q", i+1, tc.part.ChecksumAlgorithm, tc.part.Checksum, crc32Checksum(tc.data))
+		}
+	}
+
+	listed, err := fs.ListParts(ctx, &ListPartsInput{Bucket: "bucket", Key: "big.bin", UploadID: upload.UploadID})
+	if err != nil {
+		t.Fatalf("ListParts: %v", err)
+	}
+	if listed.ChecksumAlgorithm != "CRC32" || len(listed.Parts) != 3 || listed.Parts[1].Checksum != part2.Checksum {
+		t.Errorf("ListParts = %+v", listed)
+	}
+
+	// Checksums listed on completion must match the parts
+	completed := []Part{
+		{PartNumber: 1, ETag: part1.ETag, ChecksumAlgorithm: "CRC32", Checksum: part1.Checksum},
+		{PartNumber: 2, ETag: part2.ETag, ChecksumAlgorithm: "CRC32", Checksum: part1.Checksum},
+		{PartNumber: 3, ETag: part3.ETag},
+	}
+	if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, completed); !errors.Is(err, ErrInvalidPart) {
+		t.Fatalf("CompleteMultipartUpload with a bad checksum: err = %v, want ErrInvalidPart", err)
+	}
+	completed[1].Checksum = part2.Checksum
+	if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, completed); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+
+	parts, err := fs.GetObjectParts(ctx, "bucket", "big.bin")
+	if err != nil {
+		t.Fatalf("GetObjectParts: %v", err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("got %d parts, want 3", len(parts))
+	}
+	for i, want := range []*Part{part1, part2, part3} {
+		if parts[i].ChecksumAlgorithm != "CRC32" || parts[i].Checksum != want.Checksum {
+			t.Errorf("object part %d checksum = %s %q, want CRC32 %q", i+1, parts[i].ChecksumAlgorithm, parts[i].Checksum, want.Checksum)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// gearTable holds the random values of the gear rolling hash used for
+// content-defined chunking. It is generated from a fixed seed because chunk
+// boundaries, and with them deduplication across writes, depend on it.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x6a6f672d64656475) // "jog-dedu"
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunker splits a stream into chunks.
+type chunker struct {
+	r    *bufio.Reader
+	mode ChunkingMode
+	// min, avg and max are the chunk size bounds; fixed chunks are avg bytes.
+	min, avg, max int
+	// mask selects the hash bits that must be zero at a boundary, so that a
+	// boundary follows on average avg bytes after the minimum size.
+	mask uint64
+	buf  []byte
+}
+
+func newChunker(r *bufio.Reader, mode ChunkingMode, size int) *chunker {
+	c := &chunker{r: r, mode: mode, avg: size}
+	if mode == ChunkingContentDefined {
+		c.min = size / 4
+		c.max = size * 4
+		// The top bits of the gear hash depend on the most bytes
+		maskBits := bits.Len(uint(size-c.min)) - 1
+		c.mask = ^uint64(0) << (64 - maskBits)
+	}
+	return c
+}
+
+// next returns the next chunk, or io.EOF at the end of the stream. The chunk is
+// only valid until the next call.
+func (c *chunker) next() ([]byte, error) {
+	if c.mode == ChunkingFixed {
+		return c.nextFixed()
+	}
+	return c.nextContentDefined()
+}
+
+func (c *chunker) nextFixed() ([]byte, error) {
+	if c.buf == nil {
+		c.buf = make([]byte, c.avg)
+	}
+	n, err := io.ReadFull(c.r, c.buf)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	if n == 0 && err == nil {
+		err = io.EOF
+	}
+	return c.buf[:n], err
+}
+
+func (c *chunker) nextContentDefined() ([]byte, error) {
+	c.buf = c.buf[:0]
+	var hash uint64
+	for len(c.buf) < c.max {
+		b, err := c.r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.buf = append(c.buf, b)
+		if len(c.buf) < c.min {
+			continue
+		}
+		hash = (hash << 1) + gearTable[b]
+		if hash&c.mask == 0 {
+			break
+		}
+	}
+	if len(c.buf) == 0 {
+		return nil, io.EOF
+	}
+	return c.buf, nil
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// errCloneUnsupported is returned when a copy cannot share the source data.
+var errCloneUnsupported = errors.New("clone unsupported")
+
+// cloneFile creates dst sharing the data of src: a copy-on-write clone where
+// the file system supports it, or else a hard link. Object files are replaced
+// by renames and never modified in place, so a hard-linked copy is not
+// affected by later writes to its source.
+func cloneFile(src, dst string) error {
+	if err := reflink(src, dst); err == nil {
+		return nil
+	}
+	return os.Link(src, dst)
+}
+
+// cloneObjectFile stores the destination of a copy by sharing the data of the
+// source file, which takes the same time regardless of the object size. It
+// returns the ETag and size of the copy, or errCloneUnsupported if the data
+// must be copied: when the copy changes the stored representation, when the
+// source is encrypted with a data key of its own, when the content MD5 for
+// the ETag is not known, or when the file system supports neither clones nor
+// hard links.
+func (fs *FileSystem) cloneObjectFile(ctx context.Context, srcBucket, srcKey, srcPath string, srcObj *Object, dstBucket, dstPath string, compression CompressionAlgorithm) (string, int64, error) {
+	// Renaming a link over the file it links to does nothing
+	if srcPath == dstPath {
+		return "", 0, errCloneUnsupported
+	}
+
+	srcCompression, err := fs.metadata.GetObjectCompression(ctx, srcBucket, srcKey)
+	if err != nil {
+		return "", 0, err
+	}
+	if srcCompression == "" {
+		srcCompression = string(CompressionNone)
+	}
+	if CompressionAlgorithm(srcCompression) != compression {
+		return "", 0, errCloneUnsupported
+	}
+	srcEncryption, err := fs.metadata.GetObjectEncryption(ctx, srcBucket, srcKey, "")
+	if err != nil {
+		return "", 0, err
+	}
+	if srcEncryption != nil {
+		return "", 0, errCloneUnsupported
+	}
+
+	strategy, err := fs.etagStrategy(ctx, dstBucket)
+	if err != nil {
+		return "", 0, err
+	}
+	var etag string
+	if _, random := strategy.(RandomETags); random {
+		etag = strategy.ObjectETag(nil)
+	} else {
+		// Single part ETags are the content MD5 unless random ETags are enabled,
+		// multipart ETags are not
+		if strings.Contains(srcObj.ETag, "-") || fs.encryptedETagMode == ETagModeRandom {
+			return "", 0, errCloneUnsupported
+		}
+		etag = srcObj.ETag
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(dstPath), ".tmp-"+uuid.New().String())
+	if err := cloneFile(srcPath, tmpPath); err != nil {
+		return "", 0, errCloneUnsupported
+	}
+
+	// The source may have been replaced since its metadata was read
+	current, err := fs.metadata.GetObject(ctx, srcBucket, srcKey)
+	if err != nil || current == nil || current.ETag != srcObj.ETag || current.Size != srcObj.Size {
+		os.Remove(tmpPath)
+		return "", 0, errCloneUnsupported
+	}
+
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		os.Remove(tmpPath)
+		return "", 0, fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return etag, srcObj.Size, nil
+}
@@ -0,0 +1,8 @@
+package storage
+
+import "golang.org/x/sys/unix"
+
+// reflink creates dst as a copy-on-write clone of src, supported by APFS.
+func reflink(src, dst string) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCopyObjectSharesData(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	src, err := fs.PutObject(ctx, "bucket", "src.bin", strings.NewReader("original"), 8, "application/octet-stream", nil)
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	dst, err := fs.CopyObject(ctx, "bucket", "src.bin", "bucket", "dir/dst.bin", nil)
+	if err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if dst.ETag != src.ETag || dst.Size != src.Size {
+		t.Errorf("copy has ETag %s and size %d, want %s and %d", dst.ETag, dst.Size, src.ETag, src.Size)
+	}
+
+	// Overwriting the source does not change the copy
+	if _, err := fs.PutObject(ctx, "bucket", "src.bin", strings.NewReader("replaced"), 8, "application/octet-stream", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	data, err := fs.GetObject(ctx, "bucket", "dir/dst.bin")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	body, err := io.ReadAll(data.Body)
+	data.Body.Close()
+	if err != nil || string(body) != "original" {
+		t.Errorf("copy has content %q, %v, want %q", body, err, "original")
+	}
+}
+
+func TestCopyObjectFallsBackToCopying(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	// Multipart ETags are not the content MD5 the copy needs
+	upload, err := fs.CreateMultipartUpload(ctx, "bucket", "multipart.bin", "application/octet-stream", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	part, err := fs.UploadPart(ctx, "bucket", "multipart.bin", upload.UploadID, 1, strings.NewReader("data"), 4)
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "multipart.bin", upload.UploadID, []Part{{PartNumber: 1, ETag: part.ETag}}); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	copied, err := fs.CopyObject(ctx, "bucket", "multipart.bin", "bucket", "copy.bin", nil)
+	if err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if copied.ETag != "8d777f385d3dfec8815d20f7496026dc" {
+		t.Errorf("copy of multipart object has ETag %s, want content MD5", copied.ETag)
+	}
+
+	// Objects stored compressed are copied to uncompressed destinations
+	fs.SetCompressionPolicy(CompressionPolicy{Algorithm: CompressionGzip, ContentTypes: []string{"text/*"}})
+	text := strings.Repeat("text ", 100)
+	if _, err := fs.PutObject(ctx, "bucket", "text.txt", strings.NewReader(text), int64(len(text)), "text/plain", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if err := fs.CreateBucket(ctx, "plain"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := fs.PutBucketCompression(ctx, "plain", &CompressionConfiguration{Algorithm: CompressionNone}); err != nil {
+		t.Fatalf("PutBucketCompression: %v", err)
+	}
+	if _, err := fs.CopyObject(ctx, "bucket", "text.txt", "plain", "text.txt", nil); err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(fs.dataDir, "plain", "text.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(text)) {
+		t.Errorf("copy stored with %d bytes, want %d uncompressed", info.Size(), len(text))
+	}
+}
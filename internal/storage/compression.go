@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CompressionAlgorithm selects how object data is compressed at rest (JOG extension).
+type CompressionAlgorithm string
+
+const (
+	// CompressionNone stores object data as is.
+	CompressionNone CompressionAlgorithm = "none"
+	// CompressionGzip stores object data gzip compressed.
+	CompressionGzip CompressionAlgorithm = "gzip"
+)
+
+// ParseCompressionAlgorithm parses a compression algorithm. An empty string
+// selects CompressionNone. zstd is not available in this build.
+func ParseCompressionAlgorithm(s string) (CompressionAlgorithm, error) {
+	switch CompressionAlgorithm(s) {
+	case "", CompressionNone:
+		return CompressionNone, nil
+	case CompressionGzip:
+		return CompressionGzip, nil
+	case "zstd":
+		return "", fmt.Errorf("compression algorithm %q is not supported by this build", s)
+	default:
+		return "", fmt.Errorf("invalid compression algorithm %q", s)
+	}
+}
+
+// CompressionPolicy selects the objects the filesystem backend compresses at rest.
+type CompressionPolicy struct {
+	// Algorithm compresses objects of the listed content types in buckets
+	// without a compression configuration. CompressionNone disables it.
+	Algorithm CompressionAlgorithm
+	// ContentTypes lists the compressible content types. An entry ending in
+	// "/*" matches all subtypes, e.g. "text/*".
+	ContentTypes []string
+	// MinSize is the size in bytes below which objects are stored uncompressed.
+	MinSize int64
+}
+
+// CompressionConfiguration is the compression setting of a bucket (JOG extension).
+// It overrides the content types of the server policy: objects in the bucket are
+// compressed with Algorithm regardless of their content type, and not at all if
+// Algorithm is CompressionNone.
+type CompressionConfiguration struct {
+	Algorithm CompressionAlgorithm
+}
+
+// SetCompressionPolicy sets the objects compressed by PutObject and CopyObject.
+// Objects written before keep their representation.
+func (fs *FileSystem) SetCompressionPolicy(policy CompressionPolicy) {
+	fs.compression = policy
+}
+
+// compressible reports whether the policy compresses the given content type.
+func (p CompressionPolicy) compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, pattern := range p.ContentTypes {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// objectCompression returns the algorithm to store a new object with. size is
+// the content length, or negative if unknown.
+func (fs *FileSystem) objectCompression(ctx context.Context, bucket, contentType string, size int64) (CompressionAlgorithm, error) {
+	if size >= 0 && size < fs.compression.MinSize {
+		return CompressionNone, nil
+	}
+
+	algorithm, err := fs.metadata.GetBucketCompression(ctx, bucket)
+	if err != nil {
+		return "", err
+	}
+	if algorithm != "" {
+		return CompressionAlgorithm(algorithm), nil
+	}
+
+	if fs.compression.Algorithm == "" || fs.compression.Algorithm == CompressionNone || !fs.compression.compressible(contentType) {
+		return CompressionNone, nil
+	}
+	return fs.compression.Algorithm, nil
+}
+
+// compressWriter returns a writer storing data to w with the given algorithm.
+// Close flushes the compressed stream but does not close w.
+func compressWriter(w io.Writer, algorithm CompressionAlgorithm) io.WriteCloser {
+	if algorithm == CompressionGzip {
+		return gzip.NewWriter(w)
+	}
+	return nopWriteCloser{w}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// openObjectFile opens the data file of an object at offset, decrypting and
+// decompressing it if the object is encrypted or compressed at rest. Errors
+// opening the file are returned unwrapped so that callers can check
+// os.IsNotExist.
+func (fs *FileSystem) openObjectFile(ctx context.Context, bucket, key, path string, offset int64) (io.ReadCloser, error) {
+	algorithm, err := fs.metadata.GetObjectCompression(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := fs.metadata.GetObjectEncryption(ctx, bucket, key, "")
+	if err != nil {
+		return nil, err
+	}
+	compressed := CompressionAlgorithm(algorithm) == CompressionGzip
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data io.ReadCloser = file
+	switch {
+	case enc != nil:
+		// Compressed data is encrypted as a whole and decrypted from the start
+		dataOffset := offset
+		if compressed {
+			dataOffset = 0
+		}
+		if data, err = fs.decryptObjectFile(ctx, file, enc, dataOffset); err != nil {
+			return nil, err
+		}
+	case !compressed && offset > 0:
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to seek: %w", err)
+		}
+	}
+	if !compressed {
+		return data, nil
+	}
+
+	zr, err := gzip.NewReader(data)
+	if err != nil {
+		data.Close()
+		return nil, fmt.Errorf("failed to read compressed object: %w", err)
+	}
+	body := &decompressReader{Reader: zr, file: data}
+	if offset > 0 {
+		if _, err := io.CopyN(io.Discard, zr, offset); err != nil {
+			body.Close()
+			return nil, fmt.Errorf("failed to seek: %w", err)
+		}
+	}
+	return body, nil
+}
+
+// decompressReader reads a compressed object file and closes it when done.
+type decompressReader struct {
+	*gzip.Reader
+	file io.Closer
+}
+
+func (r *decompressReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+// PutBucketCompression sets the compression configuration for a bucket.
+func (fs *FileSystem) PutBucketCompression(ctx context.Context, bucket string, config *CompressionConfiguration) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return fs.metadata.PutBucketCompression(ctx, bucket, string(config.Algorithm))
+}
+
+// GetBucketCompression returns the compression configuration for a bucket.
+func (fs *FileSystem) GetBucketCompression(ctx context.Context, bucket string) (*CompressionConfiguration, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	algorithm, err := fs.metadata.GetBucketCompression(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if algorithm == "" {
+		return nil, ErrNoSuchCompressionConfiguration
+	}
+	return &CompressionConfiguration{Algorithm: CompressionAlgorithm(algorithm)}, nil
+}
+
+// DeleteBucketCompression deletes the compression configuration for a bucket.
+func (fs *FileSystem) DeleteBucketCompression(ctx context.Context, bucket string) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return fs.metadata.DeleteBucketCompression(ctx, bucket)
+}
+
+// PutBucketCompression is not supported by passthrough backends, which store
+// object data in the blob store as is.
+func (p *Passthrough) PutBucketCompression(ctx context.Context, bucket string, config *CompressionConfiguration) error {
+	return ErrNotImplemented
+}
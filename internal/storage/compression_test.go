@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressionPolicyCompressible(t *testing.T) {
+	policy := CompressionPolicy{ContentTypes: []string{"text/*", "application/json"}}
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"text/plain", true},
+		{"text/html; charset=utf-8", true},
+		{"Application/JSON", true},
+		{"application/json-seq", false},
+		{"image/png", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := policy.compressible(tt.contentType); got != tt.want {
+			t.Errorf("compressible(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestParseCompressionAlgorithm(t *testing.T) {
+	for s, want := range map[string]CompressionAlgorithm{"": CompressionNone, "none": CompressionNone, "gzip": CompressionGzip} {
+		got, err := ParseCompressionAlgorithm(s)
+		if err != nil || got != want {
+			t.Errorf("ParseCompressionAlgorithm(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"zstd", "lz4"} {
+		if _, err := ParseCompressionAlgorithm(s); err == nil {
+			t.Errorf("ParseCompressionAlgorithm(%q) succeeded", s)
+		}
+	}
+}
+
+func TestFileSystemCompression(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	fs.SetCompressionPolicy(CompressionPolicy{
+		Algorithm:    CompressionGzip,
+		ContentTypes: []string{"text/*"},
+		MinSize:      16,
+	})
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	content := strings.Repeat("compressible text ", 1000)
+	put := func(key, contentType, data string) *Object {
+		t.Helper()
+		obj, err := fs.PutObject(ctx, "bucket", key, strings.NewReader(data), int64(len(data)), contentType, nil)
+		if err != nil {
+			t.Fatalf("PutObject(%s): %v", key, err)
+		}
+		return obj
+	}
+	storedSize := func(key string) int64 {
+		t.Helper()
+		info, err := os.Stat(filepath.Join(fs.dataDir, "bucket", key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+	get := func(key string) (*Object, string) {
+		t.Helper()
+		data, err := fs.GetObject(ctx, "bucket", key)
+		if err != nil {
+			t.Fatalf("GetObject(%s): %v", key, err)
+		}
+		defer data.Body.Close()
+		body, err := io.ReadAll(data.Body)
+		if err != nil {
+			t.Fatalf("read %s: %v", key, err)
+		}
+		return &data.Object, string(body)
+	}
+
+	plain := put("plain.bin", "application/octet-stream", content)
+	text := put("text.txt", "text/plain", content)
+	put("small.txt", "text/plain", "tiny")
+
+	if plain.ETag != text.ETag || text.Size != int64(len(content)) {
+		t.Errorf("compressed object has ETag %s and size %d, want %s and %d", text.ETag, text.Size, plain.ETag, len(content))
+	}
+	if got := storedSize("plain.bin"); got != int64(len(content)) {
+		t.Errorf("plain.bin stored with %d bytes, want %d", got, len(content))
+	}
+	if got := storedSize("text.txt"); got >= int64(len(content))/10 {
+		t.Errorf("text.txt stored with %d bytes, want compressed", got)
+	}
+	if got := storedSize("small.txt"); got != 4 {
+		t.Errorf("small.txt stored with %d bytes, want 4", got)
+	}
+
+	obj, body := get("text.txt")
+	if body != content || obj.Size != int64(len(content)) {
+		t.Errorf("GetObject returned %d bytes with size %d, want original content", len(body), obj.Size)
+	}
+
+	// Verification reads the decompressed content
+	verification, err := fs.VerifyObject(ctx, "bucket", "text.txt")
+	if err != nil {
+		t.Fatalf("VerifyObject: %v", err)
+	}
+	if verification.Corrupted() {
+		t.Errorf("compressed object reported corrupted: %s", verification.Corruption)
+	}
+
+	// Ranges are served from the decompressed content
+	data, err := fs.GetObjectRange(ctx, "bucket", "text.txt", 100, 199)
+	if err != nil {
+		t.Fatalf("GetObjectRange: %v", err)
+	}
+	ranged, err := io.ReadAll(data.Body)
+	data.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ranged) != content[100:200] || data.Size != 100 {
+		t.Errorf("GetObjectRange returned %q with size %d, want %q", ranged, data.Size, content[100:200])
+	}
+
+	// Copies decompress the source and compress the destination per its content type
+	if _, err := fs.CopyObject(ctx, "bucket", "text.txt", "bucket", "copy.txt", nil); err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if _, body := get("copy.txt"); body != content {
+		t.Errorf("copy has %d bytes, want original content", len(body))
+	}
+	if got := storedSize("copy.txt"); got >= int64(len(content))/10 {
+		t.Errorf("copy.txt stored with %d bytes, want compressed", got)
+	}
+
+	// Appending to a compressed object rewrites it uncompressed
+	if _, err := fs.AppendObject(ctx, "bucket", "text.txt", int64(len(content)), strings.NewReader("tail"), 4, "", nil); err != nil {
+		t.Fatalf("AppendObject: %v", err)
+	}
+	if _, body := get("text.txt"); body != content+"tail" {
+		t.Errorf("appended object has %d bytes, want %d", len(body), len(content)+4)
+	}
+
+	// Overwriting a compressed object with an uncompressed one clears the flag
+	put("copy.txt", "application/octet-stream", content)
+	if _, body := get("copy.txt"); body != content {
+		t.Errorf("overwritten object has %d bytes, want original content", len(body))
+	}
+}
+
+func TestFileSystemCompressionMetadataFailure(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	fs.SetCompressionPolicy(CompressionPolicy{Algorithm: CompressionGzip, ContentTypes: []string{"text/*"}})
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	content := strings.Repeat("compressible text ", 100)
+	for _, key := range []string{"text", "source"} {
+		if _, err := fs.PutObject(ctx, "bucket", key, strings.NewReader(content), int64(len(content)), "text/plain", nil); err != nil {
+			t.Fatalf("PutObject(%s): %v", key, err)
+		}
+	}
+
+	// A compressed object is never recorded without its algorithm
+	if _, err := fs.metadata.db.Exec(`
+		CREATE TRIGGER fail_compression BEFORE INSERT ON object_compression
+		BEGIN SELECT RAISE(ABORT, 'injected'); END
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.PutObject(ctx, "bucket", "text", strings.NewReader(content), int64(len(content)), "text/plain", nil); err == nil {
+		t.Fatal("PutObject succeeded without recording the compression")
+	}
+	if obj, err := fs.metadata.GetObject(ctx, "bucket", "text"); err != nil || obj != nil {
+		t.Errorf("object after a failed compression record = %+v, %v, want none", obj, err)
+	}
+	if _, err := fs.CopyObject(ctx, "bucket", "source", "bucket", "copy", nil); err == nil {
+		t.Fatal("CopyObject succeeded without recording the compression")
+	}
+	if obj, err := fs.metadata.GetObject(ctx, "bucket", "copy"); err != nil || obj != nil {
+		t.Errorf("copy after a failed compression record = %+v, %v, want none", obj, err)
+	}
+}
+
+func TestBucketCompression(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	if _, err := fs.GetBucketCompression(ctx, "bucket"); err != ErrNoSuchCompressionConfiguration {
+		t.Fatalf("GetBucketCompression = %v, want ErrNoSuchCompressionConfiguration", err)
+	}
+	if err := fs.PutBucketCompression(ctx, "missing", &CompressionConfiguration{Algorithm: CompressionGzip}); err != ErrBucketNotFound {
+		t.Fatalf("PutBucketCompression on missing bucket = %v, want ErrBucketNotFound", err)
+	}
+
+	// The bucket setting compresses all content types without a server policy
+	if err := fs.PutBucketCompression(ctx, "bucket", &CompressionConfiguration{Algorithm: CompressionGzip}); err != nil {
+		t.Fatalf("PutBucketCompression: %v", err)
+	}
+	config, err := fs.GetBucketCompression(ctx, "bucket")
+	if err != nil || config.Algorithm != CompressionGzip {
+		t.Fatalf("GetBucketCompression = %+v, %v", config, err)
+	}
+
+	content := bytes.Repeat([]byte{0}, 64*1024)
+	if _, err := fs.PutObject(ctx, "bucket", "zeros.bin", bytes.NewReader(content), int64(len(content)), "application/octet-stream", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(fs.dataDir, "bucket", "zeros.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() >= int64(len(content)) {
+		t.Errorf("zeros.bin stored with %d bytes, want compressed", info.Size())
+	}
+	data, err := fs.GetObject(ctx, "bucket", "zeros.bin")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	body, err := io.ReadAll(data.Body)
+	data.Body.Close()
+	if err != nil || !bytes.Equal(body, content) {
+		t.Errorf("GetObject returned %d bytes, %v, want original content", len(body), err)
+	}
+
+	// "none" disables compression regardless of the server policy
+	fs.SetCompressionPolicy(CompressionPolicy{Algorithm: CompressionGzip, ContentTypes: []string{"text/*"}})
+	if err := fs.PutBucketCompression(ctx, "bucket", &CompressionConfiguration{Algorithm: CompressionNone}); err != nil {
+		t.Fatalf("PutBucketCompression: %v", err)
+	}
+	text := strings.Repeat("a", 4096)
+	if _, err := fs.PutObject(ctx, "bucket", "text.txt", strings.NewReader(text), int64(len(text)), "text/plain", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	info, err = os.Stat(filepath.Join(fs.dataDir, "bucket", "text.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(text)) {
+		t.Errorf("text.txt stored with %d bytes, want uncompressed", info.Size())
+	}
+
+	if err := fs.DeleteBucketCompression(ctx, "bucket"); err != nil {
+		t.Fatalf("DeleteBucketCompression: %v", err)
+	}
+	if _, err := fs.GetBucketCompression(ctx, "bucket"); err != ErrNoSuchCompressionConfiguration {
+		t.Fatalf("GetBucketCompression after delete = %v, want ErrNoSuchCompressionConfiguration", err)
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+)
+
+// DefaultContentType is the content type of objects whose content type is
+// neither given nor inferred from their key.
+const DefaultContentType = "application/octet-stream"
+
+// ContentTypeRule maps a key extension to a content type (JOG extension).
+type ContentTypeRule struct {
+	// Extension is the lowercase extension including the leading dot, e.g. ".wasm".
+	Extension   string
+	ContentType string
+}
+
+// ContentTypeConfiguration holds the content type inference rules of a bucket
+// (JOG extension). Its rules take precedence over the server-wide table.
+type ContentTypeConfiguration struct {
+	Rules []ContentTypeRule
+}
+
+// ContentTypeTable maps lowercase key extensions, including the leading dot,
+// to content types.
+type ContentTypeTable map[string]string
+
+// NewContentTypeTable validates a mapping of extensions to content types and
+// normalizes the extensions. The leading dot is optional.
+func NewContentTypeTable(types map[string]string) (ContentTypeTable, error) {
+	table := make(ContentTypeTable, len(types))
+	for ext, contentType := range types {
+		rule, err := NewContentTypeRule(ext, contentType)
+		if err != nil {
+			return nil, err
+		}
+		table[rule.Extension] = rule.ContentType
+	}
+	return table, nil
+}
+
+// NewContentTypeRule validates a content type inference rule and normalizes
+// its extension. The leading dot is optional.
+func NewContentTypeRule(ext, contentType string) (ContentTypeRule, error) {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if len(ext) < 2 || strings.ContainsAny(ext[1:], "./") {
+		return ContentTypeRule{}, fmt.Errorf("invalid extension %q", ext)
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return ContentTypeRule{}, fmt.Errorf("invalid content type %q for extension %s", contentType, ext)
+	}
+	return ContentTypeRule{Extension: ext, ContentType: contentType}, nil
+}
+
+// Table returns the rules of the configuration as a table.
+func (c *ContentTypeConfiguration) Table() ContentTypeTable {
+	table := make(ContentTypeTable, len(c.Rules))
+	for _, rule := range c.Rules {
+		table[rule.Extension] = rule.ContentType
+	}
+	return table
+}
+
+// InferContentType returns the content type of an object from the extension of
+// its key. The tables are consulted in order, then the system MIME types. Keys
+// with unknown extensions get DefaultContentType.
+func InferContentType(key string, tables ...ContentTypeTable) string {
+	ext := strings.ToLower(path.Ext(key))
+	if ext == "" {
+		return DefaultContentType
+	}
+	for _, table := range tables {
+		if contentType, ok := table[ext]; ok {
+			return contentType
+		}
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return DefaultContentType
+}
+
+// PutBucketContentTypes sets the content type inference rules for a bucket.
+func (fs *FileSystem) PutBucketContentTypes(ctx context.Context, bucket string, config *ContentTypeConfiguration) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return fs.metadata.PutBucketContentTypes(ctx, bucket, config.Rules)
+}
+
+// GetBucketContentTypes returns the content type inference rules for a bucket.
+func (fs *FileSystem) GetBucketContentTypes(ctx context.Context, bucket string) (*ContentTypeConfiguration, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	rules, err := fs.metadata.GetBucketContentTypes(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, ErrNoSuchContentTypeConfiguration
+	}
+	return &ContentTypeConfiguration{Rules: rules}, nil
+}
+
+// DeleteBucketContentTypes deletes the content type inference rules for a bucket.
+func (fs *FileSystem) DeleteBucketContentTypes(ctx context.Context, bucket string) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return fs.metadata.DeleteBucketContentTypes(ctx, bucket)
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInferContentType(t *testing.T) {
+	server, err := NewContentTypeTable(map[string]string{
+		"md":   "text/markdown; charset=utf-8",
+		".CSS": "text/x-custom",
+	})
+	if err != nil {
+		t.Fatalf("NewContentTypeTable: %v", err)
+	}
+	bucket := ContentTypeTable{".md": "text/plain"}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"site/index.html", "text/html; charset=utf-8"},
+		{"app.WASM", "application/wasm"},
+		{"style.css", "text/x-custom"},
+		{"README.md", "text/plain"},
+		{"Makefile", DefaultContentType},
+		{"archive.unknown-ext", DefaultContentType},
+		{"dir.d/file", DefaultContentType},
+	}
+	for _, tt := range tests {
+		if got := InferContentType(tt.key, bucket, server); got != tt.want {
+			t.Errorf("InferContentType(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+
+	for ext, contentType := range map[string]string{"": "text/plain", "tar.gz": "application/gzip", "js": "not a type"} {
+		if _, err := NewContentTypeRule(ext, contentType); err == nil {
+			t.Errorf("NewContentTypeRule(%q, %q) succeeded, want error", ext, contentType)
+		}
+	}
+}
+
+func TestBucketContentTypes(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	if _, err := fs.GetBucketContentTypes(ctx, "bucket"); err != ErrNoSuchContentTypeConfiguration {
+		t.Fatalf("GetBucketContentTypes = %v, want ErrNoSuchContentTypeConfiguration", err)
+	}
+	config := &ContentTypeConfiguration{Rules: []ContentTypeRule{{Extension: ".md", ContentType: "text/markdown"}}}
+	if err := fs.PutBucketContentTypes(ctx, "missing", config); err != ErrBucketNotFound {
+		t.Fatalf("PutBucketContentTypes on missing bucket = %v, want ErrBucketNotFound", err)
+	}
+
+	if err := fs.PutBucketContentTypes(ctx, "bucket", config); err != nil {
+		t.Fatalf("PutBucketContentTypes: %v", err)
+	}
+	// Putting rules replaces the existing ones
+	config = &ContentTypeConfiguration{Rules: []ContentTypeRule{
+		{Extension: ".wasm", ContentType: "application/octet-stream"},
+		{Extension: ".glb", ContentType: "model/gltf-binary"},
+	}}
+	if err := fs.PutBucketContentTypes(ctx, "bucket", config); err != nil {
+		t.Fatalf("PutBucketContentTypes: %v", err)
+	}
+	got, err := fs.GetBucketContentTypes(ctx, "bucket")
+	if err != nil {
+		t.Fatalf("GetBucketContentTypes: %v", err)
+	}
+	table := got.Table()
+	if len(table) != 2 || table[".glb"] != "model/gltf-binary" || table[".wasm"] != "application/octet-stream" {
+		t.Errorf("GetBucketContentTypes = %+v", got.Rules)
+	}
+
+	if err := fs.DeleteBucketContentTypes(ctx, "bucket"); err != nil {
+		t.Fatalf("DeleteBucketContentTypes: %v", err)
+	}
+	if _, err := fs.GetBucketContentTypes(ctx, "bucket"); err != ErrNoSuchContentTypeConfiguration {
+		t.Fatalf("GetBucketContentTypes after delete = %v, want ErrNoSuchContentTypeConfiguration", err)
+	}
+}
@@ -0,0 +1,116 @@
+package storage
+
+import "context"
+
+type (
+	// tenantContextKey is the context key for the tenant of a request.
+	tenantContextKey struct{}
+	// ownerContextKey is the context key for the caller of a request.
+	ownerContextKey struct{}
+	// sseContextKey is the context key for the server-side encryption
+	// requested by a write.
+	sseContextKey struct{}
+	// checksumContextKey is the context key for the checksum sent with a
+	// part upload or requested for a multipart upload.
+	checksumContextKey struct{}
+	// objectLockContextKey is the context key for the object lock requested
+	// for a multipart upload.
+	objectLockContextKey struct{}
+	// objectTagsContextKey is the context key for the tags requested for a
+	// multipart upload.
+	objectTagsContextKey struct{}
+	// bucketRegionContextKey is the context key for the location constraint
+	// of a bucket being created.
+	bucketRegionContextKey struct{}
+)
+
+// WithTenant returns a copy of ctx for requests made by the given tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant of a request, or "" for the default namespace.
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// WithOwner returns a copy of ctx for requests made by the given owner ID.
+// Buckets created with this context are owned by it.
+func WithOwner(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, ownerContextKey{}, owner)
+}
+
+// OwnerFromContext returns the owner ID of the caller, or "" for
+// unauthenticated requests.
+func OwnerFromContext(ctx context.Context) string {
+	owner, _ := ctx.Value(ownerContextKey{}).(string)
+	return owner
+}
+
+// WithServerSideEncryption returns a copy of ctx for writes requesting the
+// given server-side encryption, overriding the default encryption of the
+// bucket.
+func WithServerSideEncryption(ctx context.Context, sse *ServerSideEncryption) context.Context {
+	return context.WithValue(ctx, sseContextKey{}, sse)
+}
+
+// ServerSideEncryptionFromContext returns the server-side encryption
+// requested by a write, or nil if none was requested.
+func ServerSideEncryptionFromContext(ctx context.Context) *ServerSideEncryption {
+	sse, _ := ctx.Value(sseContextKey{}).(*ServerSideEncryption)
+	return sse
+}
+
+// WithChecksum returns a copy of ctx for part uploads sent with the given
+// checksum, or for multipart uploads whose parts get checksums of its
+// algorithm.
+func WithChecksum(ctx context.Context, checksum *Checksum) context.Context {
+	return context.WithValue(ctx, checksumContextKey{}, checksum)
+}
+
+// ChecksumFromContext returns the checksum sent with a part upload or
+// requested for a multipart upload, or nil if none was.
+func ChecksumFromContext(ctx context.Context) *Checksum {
+	checksum, _ := ctx.Value(checksumContextKey{}).(*Checksum)
+	return checksum
+}
+
+// WithObjectLock returns a copy of ctx for multipart uploads whose object gets
+// the given retention and legal hold on completion.
+func WithObjectLock(ctx context.Context, lock *ObjectLock) context.Context {
+	return context.WithValue(ctx, objectLockContextKey{}, lock)
+}
+
+// ObjectLockFromContext returns the object lock requested for a multipart
+// upload, or nil if none was.
+func ObjectLockFromContext(ctx context.Context) *ObjectLock {
+	lock, _ := ctx.Value(objectLockContextKey{}).(*ObjectLock)
+	return lock
+}
+
+// WithObjectTags returns a copy of ctx for multipart uploads whose object gets
+// the given tags on completion.
+func WithObjectTags(ctx context.Context, tags []Tag) context.Context {
+	return context.WithValue(ctx, objectTagsContextKey{}, tags)
+}
+
+// ObjectTagsFromContext returns the tags requested for a multipart upload, or
+// nil if none were.
+func ObjectTagsFromContext(ctx context.Context) []Tag {
+	tags, _ := ctx.Value(objectTagsContextKey{}).([]Tag)
+	return tags
+}
+
+// WithBucketRegion returns a copy of ctx for creating a bucket with the given
+// location constraint.
+func WithBucketRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, bucketRegionContextKey{}, region)
+}
+
+// BucketRegionFromContext returns the location constraint of a bucket being
+// created, or "" for the region of the server.
+func BucketRegionFromContext(ctx context.Context) string {
+	region, _ := ctx.Value(bucketRegionContextKey{}).(string)
+	return region
+}
@@ -0,0 +1,423 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ChunkingMode selects how the dedup blob store splits blobs into chunks.
+type ChunkingMode string
+
+const (
+	// ChunkingFixed splits blobs into chunks of the same size. It is cheap but
+	// an insertion shifts all following chunk boundaries.
+	ChunkingFixed ChunkingMode = "fixed"
+	// ChunkingContentDefined places chunk boundaries where a rolling hash of
+	// the content matches, so boundaries survive insertions and deletions.
+	ChunkingContentDefined ChunkingMode = "cdc"
+)
+
+// DefaultChunkSize is the default (average) chunk size of the dedup blob store.
+const DefaultChunkSize = 1024 * 1024
+
+// ParseChunkingMode parses a chunking mode. An empty string selects ChunkingContentDefined.
+func ParseChunkingMode(s string) (ChunkingMode, error) {
+	switch ChunkingMode(s) {
+	case "", ChunkingContentDefined:
+		return ChunkingContentDefined, nil
+	case ChunkingFixed:
+		return ChunkingFixed, nil
+	default:
+		return "", fmt.Errorf("invalid chunking mode %q", s)
+	}
+}
+
+// DedupBlobStore implements BlobStore with a content-addressed chunk store.
+// Blobs are split into chunks that are stored once per distinct content under
+// their SHA-256 hash; a blob is a manifest listing its chunks. Chunks are
+// reference counted in a SQLite index and removed once no blob uses them.
+//
+// Chunk files are only deleted while no write is in progress, so a write never
+// refers to a chunk that was collected after it found the chunk on disk.
+type DedupBlobStore struct {
+	dir       string
+	db        *sql.DB
+	mode      ChunkingMode
+	chunkSize int
+
+	// gcMu is held shared by writes and exclusively by garbage collection.
+	gcMu sync.RWMutex
+	// indexMu serializes index updates, which read and adjust reference counts.
+	indexMu sync.Mutex
+}
+
+// dedupChunk is a chunk of a blob.
+type dedupChunk struct {
+	hash   string
+	offset int64
+	size   int64
+}
+
+// NewDedupBlobStore creates a dedup blob store in dir. chunkSize is the chunk
+// size for fixed chunking and the average chunk size for content-defined chunking.
+func NewDedupBlobStore(dir string, mode ChunkingMode, chunkSize int) (*DedupBlobStore, error) {
+	if chunkSize < 4096 {
+		return nil, fmt.Errorf("dedup chunk size must be at least 4096 bytes, got %d", chunkSize)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "chunks"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dedup directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "index.db")+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dedup index: %w", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS chunks (
+			hash TEXT PRIMARY KEY,
+			size INTEGER NOT NULL,
+			refs INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS blob_chunks (
+			name TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			hash TEXT NOT NULL,
+			chunk_offset INTEGER NOT NULL,
+			size INTEGER NOT NULL,
+			PRIMARY KEY (name, seq)
+		);
+		CREATE TABLE IF NOT EXISTS blobs (
+			name TEXT PRIMARY KEY,
+			size INTEGER NOT NULL
+		);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create dedup index: %w", err)
+	}
+
+	return &DedupBlobStore{
+		dir:       dir,
+		db:        db,
+		mode:      mode,
+		chunkSize: chunkSize,
+	}, nil
+}
+
+// chunkPath returns the path of a chunk file, fanned out by hash prefix.
+func (d *DedupBlobStore) chunkPath(hash string) string {
+	return filepath.Join(d.dir, "chunks", hash[:2], hash[2:4], hash)
+}
+
+// PutBlob splits a blob into chunks, stores the chunks not stored yet and
+// replaces the manifest of the blob.
+func (d *DedupBlobStore) PutBlob(ctx context.Context, name string, body io.Reader, size int64) error {
+	if err := d.putBlob(ctx, name, body, size); err != nil {
+		return err
+	}
+	// Chunks only used by an overwritten version are garbage now
+	d.collectGarbage(ctx)
+	return nil
+}
+
+func (d *DedupBlobStore) putBlob(ctx context.Context, name string, body io.Reader, size int64) (err error) {
+	d.gcMu.RLock()
+	defer d.gcMu.RUnlock()
+
+	var written []dedupChunk
+	defer func() {
+		if err != nil {
+			d.abandonChunks(written)
+		}
+	}()
+
+	chunker := newChunker(bufio.NewReaderSize(io.LimitReader(body, size), 64*1024), d.mode, d.chunkSize)
+	var chunks []dedupChunk
+	var offset int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := chunker.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read blob data: %w", err)
+		}
+
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		chunk := dedupChunk{hash: hash, offset: offset, size: int64(len(data))}
+		created, err := d.writeChunk(hash, data)
+		if err != nil {
+			return err
+		}
+		if created {
+			written = append(written, chunk)
+		}
+		chunks = append(chunks, chunk)
+		offset += int64(len(data))
+	}
+	if offset != size {
+		return fmt.Errorf("failed to read blob data: got %d bytes, expected %d", offset, size)
+	}
+
+	return d.replaceManifest(ctx, name, size, chunks)
+}
+
+// writeChunk stores a chunk unless a chunk with the same hash exists. It
+// reports whether the chunk was created.
+func (d *DedupBlobStore) writeChunk(hash string, data []byte) (bool, error) {
+	path := d.chunkPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return false, fmt.Errorf("failed to create chunk: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return false, fmt.Errorf("failed to write chunk: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return false, fmt.Errorf("failed to write chunk: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return false, fmt.Errorf("failed to store chunk: %w", err)
+	}
+	return true, nil
+}
+
+// abandonChunks registers chunks written by a failed write without references,
+// so garbage collection removes them unless another blob uses them by then.
+func (d *DedupBlobStore) abandonChunks(chunks []dedupChunk) {
+	for _, chunk := range chunks {
+		d.db.Exec(`INSERT OR IGNORE INTO chunks (hash, size, refs) VALUES (?, ?, 0)`, chunk.hash, chunk.size)
+	}
+}
+
+// replaceManifest stores the chunk list of a blob and moves the references
+// from the chunks of the previous version to the new chunks.
+func (d *DedupBlobStore) replaceManifest(ctx context.Context, name string, size int64, chunks []dedupChunk) error {
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := releaseChunks(ctx, tx, name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO blobs (name, size) VALUES (?, ?)`, name, size); err != nil {
+		return err
+	}
+	for i, chunk := range chunks {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO blob_chunks (name, seq, hash, chunk_offset, size) VALUES (?, ?, ?, ?, ?)
+		`, name, i, chunk.hash, chunk.offset, chunk.size); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO chunks (hash, size, refs) VALUES (?, ?, 1)
+			ON CONFLICT (hash) DO UPDATE SET refs = refs + 1
+		`, chunk.hash, chunk.size); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// releaseChunks removes the manifest of a blob and drops its chunk references.
+func releaseChunks(ctx context.Context, tx *sql.Tx, name string) error {
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE chunks SET refs = refs - (
+			SELECT COUNT(*) FROM blob_chunks b WHERE b.name = ? AND b.hash = chunks.hash
+		)
+		WHERE hash IN (SELECT hash FROM blob_chunks WHERE name = ?)
+	`, name, name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM blob_chunks WHERE name = ?`, name); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM blobs WHERE name = ?`, name)
+	return err
+}
+
+// GetBlob returns length bytes of a blob starting at offset.
+func (d *DedupBlobStore) GetBlob(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	var size int64
+	err := d.db.QueryRowContext(ctx, `SELECT size FROM blobs WHERE name = ?`, name).Scan(&size)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > size {
+		offset = size
+	}
+	end := size
+	if length >= 0 && offset+length < end {
+		end = offset + length
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT hash, chunk_offset, size FROM blob_chunks
+		WHERE name = ? AND chunk_offset < ? AND chunk_offset + size > ?
+		ORDER BY seq
+	`, name, end, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []dedupChunk
+	for rows.Next() {
+		var chunk dedupChunk
+		if err := rows.Scan(&chunk.hash, &chunk.offset, &chunk.size); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &dedupReader{store: d, chunks: chunks, pos: offset, end: end}, nil
+}
+
+// DeleteBlob deletes the manifest of a blob and collects chunks no longer used.
+func (d *DedupBlobStore) DeleteBlob(ctx context.Context, name string) error {
+	d.indexMu.Lock()
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		d.indexMu.Unlock()
+		return err
+	}
+	err = releaseChunks(ctx, tx, name)
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	d.indexMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	d.collectGarbage(ctx)
+	return nil
+}
+
+// collectGarbage deletes unreferenced chunks. It is skipped while writes are
+// in progress; the chunks are then collected by a later delete.
+func (d *DedupBlobStore) collectGarbage(ctx context.Context) {
+	if !d.gcMu.TryLock() {
+		return
+	}
+	defer d.gcMu.Unlock()
+
+	rows, err := d.db.QueryContext(ctx, `SELECT hash FROM chunks WHERE refs <= 0`)
+	if err != nil {
+		return
+	}
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if rows.Scan(&hash) == nil {
+			hashes = append(hashes, hash)
+		}
+	}
+	rows.Close()
+
+	for _, hash := range hashes {
+		if err := os.Remove(d.chunkPath(hash)); err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		d.db.ExecContext(ctx, `DELETE FROM chunks WHERE hash = ? AND refs <= 0`, hash)
+	}
+}
+
+// Close closes the chunk index.
+func (d *DedupBlobStore) Close() error {
+	return d.db.Close()
+}
+
+// dedupReader streams a range of a blob chunk by chunk.
+type dedupReader struct {
+	store  *DedupBlobStore
+	chunks []dedupChunk
+	pos    int64
+	end    int64
+	file   *os.File
+	remain int64
+}
+
+func (r *dedupReader) Read(p []byte) (int, error) {
+	for r.remain == 0 {
+		if r.file != nil {
+			r.file.Close()
+			r.file = nil
+		}
+		if r.pos >= r.end || len(r.chunks) == 0 {
+			return 0, io.EOF
+		}
+
+		chunk := r.chunks[0]
+		r.chunks = r.chunks[1:]
+		f, err := os.Open(r.store.chunkPath(chunk.hash))
+		if err != nil {
+			return 0, fmt.Errorf("failed to open chunk %s: %w", chunk.hash, err)
+		}
+		if _, err := f.Seek(r.pos-chunk.offset, io.SeekStart); err != nil {
+			f.Close()
+			return 0, err
+		}
+		r.file = f
+		r.remain = min(chunk.offset+chunk.size, r.end) - r.pos
+	}
+
+	if int64(len(p)) > r.remain {
+		p = p[:r.remain]
+	}
+	n, err := r.file.Read(p)
+	r.pos += int64(n)
+	r.remain -= int64(n)
+	if errors.Is(err, io.EOF) {
+		if r.remain > 0 {
+			return n, fmt.Errorf("chunk is truncated: %w", io.ErrUnexpectedEOF)
+		}
+		err = nil
+	}
+	return n, err
+}
+
+func (r *dedupReader) Close() error {
+	if r.file != nil {
+		return r.file.Close()
+	}
+	return nil
+}
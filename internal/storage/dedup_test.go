@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// countChunkFiles returns the number of chunk files of a dedup store.
+func countChunkFiles(t *testing.T, d *DedupBlobStore) int {
+	t.Helper()
+	n := 0
+	err := filepath.WalkDir(filepath.Join(d.dir, "chunks"), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && filepath.Base(path)[0] != '.' {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestChunkerContentDefined(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 256*1024)
+	rng.Read(data)
+
+	split := func(data []byte) map[string]bool {
+		c := newChunker(bufio.NewReader(bytes.NewReader(data)), ChunkingContentDefined, 8192)
+		chunks := map[string]bool{}
+		total := 0
+		for {
+			chunk, err := c.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunk) > 4*8192 {
+				t.Errorf("chunk of %d bytes exceeds the maximum", len(chunk))
+			}
+			total += len(chunk)
+			chunks[string(chunk)] = true
+		}
+		if total != len(data) {
+			t.Errorf("chunks hold %d bytes, want %d", total, len(data))
+		}
+		return chunks
+	}
+
+	original := split(data)
+	// Inserting bytes near the start only changes the chunks around the insertion
+	shifted := split(append(append(append([]byte(nil), data[:1000]...), "inserted"...), data[1000:]...))
+	shared := 0
+	for chunk := range shifted {
+		if original[chunk] {
+			shared++
+		}
+	}
+	if shared < len(original)-2 {
+		t.Errorf("%d of %d chunks shared after an insertion, want all but 2", shared, len(original))
+	}
+}
+
+func TestDedupBlobStore(t *testing.T) {
+	ctx := context.Background()
+	for _, mode := range []ChunkingMode{ChunkingFixed, ChunkingContentDefined} {
+		t.Run(string(mode), func(t *testing.T) {
+			d, err := NewDedupBlobStore(t.TempDir(), mode, 4096)
+			if err != nil {
+				t.Fatalf("NewDedupBlobStore: %v", err)
+			}
+			defer d.Close()
+
+			rng := rand.New(rand.NewSource(2))
+			data := make([]byte, 100*1024)
+			rng.Read(data)
+
+			if err := d.PutBlob(ctx, "a/one", bytes.NewReader(data), int64(len(data))); err != nil {
+				t.Fatalf("PutBlob: %v", err)
+			}
+			chunks := countChunkFiles(t, d)
+			if chunks < 2 {
+				t.Fatalf("blob stored in %d chunks, want several", chunks)
+			}
+
+			// An identical blob adds no chunks
+			if err := d.PutBlob(ctx, "b/two", bytes.NewReader(data), int64(len(data))); err != nil {
+				t.Fatalf("PutBlob: %v", err)
+			}
+			if n := countChunkFiles(t, d); n != chunks {
+				t.Errorf("identical blob added chunks: %d, want %d", n, chunks)
+			}
+
+			if got := readBlob(t, d, "b/two", 0, -1); !bytes.Equal(got, data) {
+				t.Error("blob content differs")
+			}
+			if got := readBlob(t, d, "a/one", 5000, 10000); !bytes.Equal(got, data[5000:15000]) {
+				t.Error("range content differs")
+			}
+			if got := readBlob(t, d, "a/one", int64(len(data))-10, -1); !bytes.Equal(got, data[len(data)-10:]) {
+				t.Error("tail content differs")
+			}
+
+			// Chunks stay while one blob references them
+			if err := d.DeleteBlob(ctx, "a/one"); err != nil {
+				t.Fatalf("DeleteBlob: %v", err)
+			}
+			if n := countChunkFiles(t, d); n != chunks {
+				t.Errorf("chunks after deleting one reference: %d, want %d", n, chunks)
+			}
+			if _, err := d.GetBlob(ctx, "a/one", 0, -1); err != ErrBlobNotFound {
+				t.Errorf("GetBlob of deleted blob: %v", err)
+			}
+
+			// Overwriting the last reference frees the old chunks
+			if err := d.PutBlob(ctx, "b/two", bytes.NewReader([]byte("small")), 5); err != nil {
+				t.Fatalf("PutBlob: %v", err)
+			}
+			if n := countChunkFiles(t, d); n != 1 {
+				t.Errorf("chunks after overwrite: %d, want 1", n)
+			}
+			if err := d.DeleteBlob(ctx, "b/two"); err != nil {
+				t.Fatalf("DeleteBlob: %v", err)
+			}
+			if n := countChunkFiles(t, d); n != 0 {
+				t.Errorf("chunks after deleting all blobs: %d, want 0", n)
+			}
+
+			// Empty blobs have no chunks
+			if err := d.PutBlob(ctx, "empty", bytes.NewReader(nil), 0); err != nil {
+				t.Fatalf("PutBlob: %v", err)
+			}
+			if got := readBlob(t, d, "empty", 0, -1); len(got) != 0 {
+				t.Errorf("empty blob has %d bytes", len(got))
+			}
+		})
+	}
+}
+
+func TestDedupBlobStoreShortBody(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDedupBlobStore(t.TempDir(), ChunkingFixed, 4096)
+	if err != nil {
+		t.Fatalf("NewDedupBlobStore: %v", err)
+	}
+	defer d.Close()
+
+	if err := d.PutBlob(ctx, "short", bytes.NewReader(make([]byte, 10000)), 20000); err == nil {
+		t.Fatal("PutBlob with a short body succeeded")
+	}
+	// Chunks of the failed write are collected by the next delete
+	if err := d.DeleteBlob(ctx, "unrelated"); err != nil {
+		t.Fatalf("DeleteBlob: %v", err)
+	}
+	if n := countChunkFiles(t, d); n != 0 {
+		t.Errorf("chunks left by failed write: %d, want 0", n)
+	}
+}
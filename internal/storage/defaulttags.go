@@ -0,0 +1,54 @@
+package storage
+
+import "context"
+
+// PutBucketDefaultTags sets the default tags of a bucket (JOG extension),
+// which are added to the tags of objects written to it.
+func (fs *FileSystem) PutBucketDefaultTags(ctx context.Context, bucket string, tags []Tag) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return fs.metadata.PutBucketDefaultTags(ctx, bucket, tags)
+}
+
+// GetBucketDefaultTags returns the default tags of a bucket.
+func (fs *FileSystem) GetBucketDefaultTags(ctx context.Context, bucket string) ([]Tag, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	tags, err := fs.metadata.GetBucketDefaultTags(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, ErrNoSuchDefaultTagsConfiguration
+	}
+	return tags, nil
+}
+
+// DeleteBucketDefaultTags deletes the default tags of a bucket. Objects keep
+// the tags they were written with.
+func (fs *FileSystem) DeleteBucketDefaultTags(ctx context.Context, bucket string) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return fs.metadata.DeleteBucketDefaultTags(ctx, bucket)
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeleteMarkerErrors(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := fs.PutBucketVersioning(ctx, "bucket", VersioningStatusEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+
+	// Keys that never existed are not found without a delete marker
+	var marker *DeleteMarkerError
+	if _, err := fs.HeadObject(ctx, "bucket", "key"); !errors.Is(err, ErrObjectNotFound) || errors.As(err, &marker) {
+		t.Fatalf("HeadObject of a missing key: err = %v, want ErrObjectNotFound", err)
+	}
+
+	if _, _, err := fs.PutObjectVersioned(ctx, "bucket", "key", strings.NewReader("data"), 4, "", nil); err != nil {
+		t.Fatalf("PutObjectVersioned: %v", err)
+	}
+	markerID, isDeleteMarker, err := fs.DeleteObjectVersioned(ctx, "bucket", "key", "")
+	if err != nil || !isDeleteMarker {
+		t.Fatalf("DeleteObjectVersioned = %v, %v, want a delete marker", isDeleteMarker, err)
+	}
+
+	for name, get := range map[string]func() error{
+		"GetObject": func() error {
+			_, err := fs.GetObject(ctx, "bucket", "key")
+			return err
+		},
+		"HeadObject": func() error {
+			_, err := fs.HeadObject(ctx, "bucket", "key")
+			return err
+		},
+		"GetObjectVersioned": func() error {
+			_, err := fs.GetObjectVersioned(ctx, "bucket", "key", markerID)
+			return err
+		},
+		"HeadObjectVersioned": func() error {
+			_, err := fs.HeadObjectVersioned(ctx, "bucket", "key", markerID)
+			return err
+		},
+	} {
+		err := get()
+		if !errors.As(err, &marker) || marker.VersionID != markerID {
+			t.Errorf("%s: err = %v, want DeleteMarkerError of %s", name, err, markerID)
+		}
+		if !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("%s: err = %v, want ErrObjectNotFound", name, err)
+		}
+	}
+}
+
+func TestDeleteLatestVersionRestoresPrevious(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := fs.PutBucketVersioning(ctx, "bucket", VersioningStatusEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+
+	for _, data := range []string{"first", "second"} {
+		if _, _, err := fs.PutObjectVersioned(ctx, "bucket", "dir/key", strings.NewReader(data), int64(len(data)), "", nil); err != nil {
+			t.Fatalf("PutObjectVersioned: %v", err)
+		}
+	}
+	markerID, _, err := fs.DeleteObjectVersioned(ctx, "bucket", "dir/key", "")
+	if err != nil {
+		t.Fatalf("DeleteObjectVersioned: %v", err)
+	}
+
+	current := func() string {
+		t.Helper()
+		obj, err := fs.GetObject(ctx, "bucket", "dir/key")
+		if errors.Is(err, ErrObjectNotFound) {
+			return ""
+		}
+		if err != nil {
+			t.Fatalf("GetObject: %v", err)
+		}
+		defer obj.Body.Close()
+		data, err := io.ReadAll(obj.Body)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		return string(data)
+	}
+
+	// Deleting the delete marker restores the version it hid
+	if _, isDeleteMarker, err := fs.DeleteObjectVersioned(ctx, "bucket", "dir/key", markerID); err != nil || !isDeleteMarker {
+		t.Fatalf("DeleteObjectVersioned of the marker = %v, %v", isDeleteMarker, err)
+	}
+	if got := current(); got != "second" {
+		t.Fatalf("current object after deleting the marker = %q, want %q", got, "second")
+	}
+
+	// Deleting the current version makes the one before it current
+	latest, err := fs.metadata.GetLatestObjectVersion(ctx, "bucket", "dir/key")
+	if err != nil || latest == nil {
+		t.Fatalf("GetLatestObjectVersion = %v, %v", latest, err)
+	}
+	if _, _, err := fs.DeleteObjectVersioned(ctx, "bucket", "dir/key", latest.VersionID); err != nil {
+		t.Fatalf("DeleteObjectVersioned of the current version: %v", err)
+	}
+	if got := current(); got != "first" {
+		t.Fatalf("current object after deleting the current version = %q, want %q", got, "first")
+	}
+
+	// Deleting the last version leaves no current object
+	latest, err = fs.metadata.GetLatestObjectVersion(ctx, "bucket", "dir/key")
+	if err != nil || latest == nil {
+		t.Fatalf("GetLatestObjectVersion = %v, %v", latest, err)
+	}
+	if _, _, err := fs.DeleteObjectVersioned(ctx, "bucket", "dir/key", latest.VersionID); err != nil {
+		t.Fatalf("DeleteObjectVersioned of the last version: %v", err)
+	}
+	if got := current(); got != "" {
+		t.Fatalf("current object after deleting all versions = %q, want none", got)
+	}
+}
+
+func TestVersionedCurrentFileIsReplaced(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := fs.PutBucketVersioning(ctx, "bucket", VersioningStatusEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	currentPath := filepath.Join(fs.dataDir, "bucket", fs.keyPath("dir/key"))
+	put := func(data string) string {
+		t.Helper()
+		_, versionID, err := fs.PutObjectVersioned(ctx, "bucket", "dir/key", strings.NewReader(data), int64(len(data)), "", nil)
+		if err != nil {
+			t.Fatalf("PutObjectVersioned: %v", err)
+		}
+		return versionID
+	}
+	// readOpen reads a file opened before the current file was replaced
+	readOpen := func(file *os.File, want string) {
+		t.Helper()
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil || string(data) != want {
+			t.Errorf("open current file = %q, %v, want %q", data, err, want)
+		}
+	}
+
+	// Readers of the current file keep reading the data they opened
+	put("first version")
+	file, err := os.Open(currentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := put("second")
+	readOpen(file, "first version")
+
+	file, err = os.Open(currentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := fs.DeleteObjectVersioned(ctx, "bucket", "dir/key", second); err != nil {
+		t.Fatalf("DeleteObjectVersioned: %v", err)
+	}
+	readOpen(file, "second")
+	if got := readObject(t, fs, "bucket", "dir/key"); got != "first version" {
+		t.Errorf("restored object = %q, want %q", got, "first version")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(currentPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".tmp-") {
+			t.Errorf("temp file %s left behind", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// emptyBucketBatchSize is the number of keys deleted per DeleteObjects call.
+const emptyBucketBatchSize = 1000
+
+// EmptyBucket removes all pending multipart uploads, object versions, delete
+// markers and objects of a bucket so it can be deleted. It stops at the first
+// error, leaving the remaining data in place.
+func EmptyBucket(ctx context.Context, s Storage, bucket string) error {
+	if _, err := s.HeadBucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	// Abort pending multipart uploads
+	uploadsInput := &ListMultipartUploadsInput{Bucket: bucket, MaxUploads: 1000}
+	for {
+		output, err := s.ListMultipartUploads(ctx, uploadsInput)
+		if err != nil {
+			return err
+		}
+		for _, upload := range output.Uploads {
+			if err := s.AbortMultipartUpload(ctx, bucket, upload.Key, upload.UploadID); err != nil {
+				return fmt.Errorf("failed to abort upload %s: %w", upload.UploadID, err)
+			}
+		}
+		if !output.IsTruncated {
+			break
+		}
+		uploadsInput.KeyMarker = output.NextKeyMarker
+		uploadsInput.UploadIdMarker = output.NextUploadIdMarker
+	}
+
+	// Delete versions and delete markers. Deleted entries disappear from the
+	// listing, so every page starts from the beginning.
+	for {
+		output, err := s.ListObjectVersions(ctx, &ListObjectVersionsInput{Bucket: bucket, MaxKeys: 1000})
+		if err != nil {
+			return err
+		}
+		versions := append(output.Versions, output.DeleteMarkers...)
+		if len(versions) == 0 {
+			break
+		}
+		for _, v := range versions {
+			if _, _, err := s.DeleteObjectVersioned(ctx, bucket, v.Key, v.VersionID); err != nil {
+				return fmt.Errorf("failed to delete version %s of %s: %w", v.VersionID, v.Key, err)
+			}
+		}
+	}
+
+	// Delete current objects
+	for {
+		output, err := s.ListObjectsV2(ctx, &ListObjectsInput{Bucket: bucket, MaxKeys: emptyBucketBatchSize})
+		if err != nil {
+			return err
+		}
+		if len(output.Objects) == 0 {
+			return nil
+		}
+
+		keys := make([]string, len(output.Objects))
+		for i, obj := range output.Objects {
+			keys[i] = obj.Key
+		}
+		_, errs, err := s.DeleteObjects(ctx, bucket, keys)
+		if err != nil {
+			return err
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("failed to delete %s: %s", errs[0].Key, errs[0].Message)
+		}
+	}
+}
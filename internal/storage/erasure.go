@@ -0,0 +1,637 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// erasureMagic identifies shard files.
+	erasureMagic = "JOGEC001"
+	// erasureHeaderSize is the size of the shard file header.
+	erasureHeaderSize = 36
+	// erasureShardBlockSize is the number of bytes each shard holds per stripe.
+	erasureShardBlockSize = 64 * 1024
+)
+
+// ErasureBlobStore implements BlobStore by striping blobs across several
+// directories (typically one per disk) with Reed-Solomon parity.
+//
+// Each blob is stored as one shard file per directory at the same relative path.
+// A shard file starts with a header followed by one block per stripe; each block
+// is erasureShardBlockSize bytes and a CRC-32 checksum, so corrupted blocks are
+// detected and treated like missing shards.
+type ErasureBlobStore struct {
+	dirs []string
+	rs   *reedSolomon
+}
+
+// shardHeader is the header of a shard file.
+type shardHeader struct {
+	// id identifies the write; shards from different writes are never mixed.
+	id           [8]byte
+	size         int64
+	dataShards   uint16
+	parityShards uint16
+	index        uint16
+	blockSize    uint32
+}
+
+// NewErasureBlobStore creates a new erasure-coded blob store.
+// dirs must contain exactly dataShards+parityShards directories.
+func NewErasureBlobStore(dirs []string, dataShards, parityShards int) (*ErasureBlobStore, error) {
+	if len(dirs) != dataShards+parityShards {
+		return nil, fmt.Errorf("erasure coding with %d data and %d parity shards requires %d directories, got %d",
+			dataShards, parityShards, dataShards+parityShards, len(dirs))
+	}
+
+	rs, err := newReedSolomon(dataShards, parityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create erasure directory: %w", err)
+		}
+	}
+
+	return &ErasureBlobStore{
+		dirs: dirs,
+		rs:   rs,
+	}, nil
+}
+
+// shardPath returns the path of a shard file.
+func (e *ErasureBlobStore) shardPath(index int, name string) string {
+	return filepath.Join(e.dirs[index], filepath.FromSlash(name))
+}
+
+// writeQuorum returns the number of shards that must be written for a put to succeed.
+func (e *ErasureBlobStore) writeQuorum() int {
+	if e.rs.dataShards == e.rs.parityShards {
+		return e.rs.dataShards + 1
+	}
+	return e.rs.dataShards
+}
+
+// stripeSize returns the number of blob bytes in one stripe.
+func (e *ErasureBlobStore) stripeSize() int64 {
+	return int64(e.rs.dataShards) * erasureShardBlockSize
+}
+
+// PutBlob encodes a blob and writes its shards to all directories.
+// Writing succeeds as long as the write quorum of shards could be stored.
+func (e *ErasureBlobStore) PutBlob(ctx context.Context, name string, body io.Reader, size int64) error {
+	header := shardHeader{
+		size:         size,
+		dataShards:   uint16(e.rs.dataShards),
+		parityShards: uint16(e.rs.parityShards),
+		blockSize:    erasureShardBlockSize,
+	}
+	if _, err := rand.Read(header.id[:]); err != nil {
+		return err
+	}
+
+	total := len(e.dirs)
+	files := make([]*os.File, total)
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+				os.Remove(f.Name())
+			}
+		}
+	}()
+
+	for i := range e.dirs {
+		path := e.shardPath(i, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			continue
+		}
+		f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+		if err != nil {
+			continue
+		}
+		header.index = uint16(i)
+		if _, err := f.Write(header.marshal()); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			continue
+		}
+		files[i] = f
+	}
+	if err := e.checkQuorum(files); err != nil {
+		return err
+	}
+
+	// Encode the blob stripe by stripe
+	stripe := make([]byte, e.stripeSize())
+	shards := make([][]byte, total)
+	for i := range shards {
+		shards[i] = make([]byte, erasureShardBlockSize)
+	}
+	var written int64
+	for written < size {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n := e.stripeSize()
+		if remaining := size - written; remaining < n {
+			n = remaining
+		}
+		if _, err := io.ReadFull(body, stripe[:n]); err != nil {
+			return fmt.Errorf("failed to read blob data: %w", err)
+		}
+		// Zero the padding of the last stripe
+		for i := n; i < int64(len(stripe)); i++ {
+			stripe[i] = 0
+		}
+		written += n
+
+		for d := 0; d < e.rs.dataShards; d++ {
+			copy(shards[d], stripe[d*erasureShardBlockSize:(d+1)*erasureShardBlockSize])
+		}
+		e.rs.encode(shards)
+
+		for i, f := range files {
+			if f == nil {
+				continue
+			}
+			if err := writeShardBlock(f, shards[i]); err != nil {
+				f.Close()
+				os.Remove(f.Name())
+				files[i] = nil
+			}
+		}
+		if err := e.checkQuorum(files); err != nil {
+			return err
+		}
+	}
+
+	// Commit the shards that were written successfully
+	committed := 0
+	for i, f := range files {
+		if f == nil {
+			// Never leave a shard of a previous write behind
+			os.Remove(e.shardPath(i, name))
+			continue
+		}
+		files[i] = nil
+		if err := f.Close(); err != nil {
+			os.Remove(f.Name())
+			os.Remove(e.shardPath(i, name))
+			continue
+		}
+		if err := os.Rename(f.Name(), e.shardPath(i, name)); err != nil {
+			os.Remove(f.Name())
+			os.Remove(e.shardPath(i, name))
+			continue
+		}
+		committed++
+	}
+	if committed < e.writeQuorum() {
+		return fmt.Errorf("erasure write quorum not met: %d of %d shards written", committed, e.writeQuorum())
+	}
+
+	return nil
+}
+
+// checkQuorum returns an error if fewer than the write quorum of shard files are still writable.
+func (e *ErasureBlobStore) checkQuorum(files []*os.File) error {
+	available := 0
+	for _, f := range files {
+		if f != nil {
+			available++
+		}
+	}
+	if available < e.writeQuorum() {
+		return fmt.Errorf("erasure write quorum not met: %d of %d shards writable", available, e.writeQuorum())
+	}
+	return nil
+}
+
+// GetBlob returns length bytes of a blob starting at offset, reconstructing
+// missing or corrupted shards on the fly.
+func (e *ErasureBlobStore) GetBlob(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	set, err := e.openShards(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > set.size {
+		offset = set.size
+	}
+	end := set.size
+	if length >= 0 && offset+length < end {
+		end = offset + length
+	}
+
+	return &erasureReader{store: e, set: set, pos: offset, end: end}, nil
+}
+
+// DeleteBlob deletes all shards of a blob.
+func (e *ErasureBlobStore) DeleteBlob(ctx context.Context, name string) error {
+	var errs []error
+	for i := range e.dirs {
+		path := e.shardPath(i, name)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		e.removeEmptyParents(i, filepath.Dir(path))
+	}
+	if len(errs) > e.rs.parityShards {
+		return fmt.Errorf("failed to delete shards: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// removeEmptyParents removes empty directories between dir and the shard root.
+func (e *ErasureBlobStore) removeEmptyParents(index int, dir string) {
+	root := filepath.Clean(e.dirs[index])
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// shardSet holds the open shard files of a blob that belong to the same write.
+// files[i] is nil for missing shards or shards of another write.
+type shardSet struct {
+	files []*os.File
+	size  int64
+}
+
+func (s *shardSet) close() {
+	for _, f := range s.files {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+// openShards opens the shard files of a blob, keeping only the shards of the
+// write that most shards agree on.
+func (e *ErasureBlobStore) openShards(name string) (*shardSet, error) {
+	total := len(e.dirs)
+	files := make([]*os.File, total)
+	headers := make([]*shardHeader, total)
+	votes := make(map[[8]byte]int)
+
+	for i := range e.dirs {
+		f, err := os.Open(e.shardPath(i, name))
+		if err != nil {
+			continue
+		}
+		buf := make([]byte, erasureHeaderSize)
+		if _, err := io.ReadFull(f, buf); err != nil {
+			f.Close()
+			continue
+		}
+		header, err := unmarshalShardHeader(buf)
+		if err != nil || int(header.index) != i ||
+			int(header.dataShards) != e.rs.dataShards || int(header.parityShards) != e.rs.parityShards ||
+			header.blockSize != erasureShardBlockSize {
+			f.Close()
+			continue
+		}
+		files[i] = f
+		headers[i] = header
+		votes[header.id]++
+	}
+
+	// Pick the write with the most shards
+	var best [8]byte
+	bestVotes := 0
+	for id, n := range votes {
+		if n > bestVotes {
+			best, bestVotes = id, n
+		}
+	}
+
+	set := &shardSet{files: files}
+	for i, header := range headers {
+		if header == nil {
+			continue
+		}
+		if header.id != best {
+			files[i].Close()
+			files[i] = nil
+			continue
+		}
+		set.size = header.size
+	}
+
+	if bestVotes == 0 {
+		return nil, ErrBlobNotFound
+	}
+	if bestVotes < e.rs.dataShards {
+		set.close()
+		return nil, fmt.Errorf("failed to read blob %s: %w", name, errTooFewShards)
+	}
+	return set, nil
+}
+
+// readStripe reads and decodes one stripe. bad[i] is set for shards whose block
+// was missing or failed the checksum.
+func (e *ErasureBlobStore) readStripe(set *shardSet, stripe int64) ([][]byte, []bool, error) {
+	total := len(e.dirs)
+	shards := make([][]byte, total)
+	bad := make([]bool, total)
+	offset := erasureHeaderSize + stripe*(erasureShardBlockSize+4)
+
+	valid := 0
+	for i, f := range set.files {
+		if f == nil {
+			bad[i] = true
+			continue
+		}
+		block := make([]byte, erasureShardBlockSize+4)
+		if _, err := f.ReadAt(block, offset); err != nil {
+			bad[i] = true
+			continue
+		}
+		data := block[:erasureShardBlockSize]
+		if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(block[erasureShardBlockSize:]) {
+			bad[i] = true
+			continue
+		}
+		shards[i] = data
+		valid++
+	}
+
+	if valid < total {
+		if err := e.rs.reconstruct(shards); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return shards, bad, nil
+}
+
+// erasureReader streams a range of a blob stripe by stripe.
+type erasureReader struct {
+	store *ErasureBlobStore
+	set   *shardSet
+	pos   int64
+	end   int64
+	buf   []byte
+}
+
+func (r *erasureReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.pos >= r.end {
+			return 0, io.EOF
+		}
+
+		stripeSize := r.store.stripeSize()
+		stripe := r.pos / stripeSize
+		shards, _, err := r.store.readStripe(r.set, stripe)
+		if err != nil {
+			return 0, err
+		}
+
+		data := make([]byte, 0, stripeSize)
+		for d := 0; d < r.store.rs.dataShards; d++ {
+			data = append(data, shards[d]...)
+		}
+
+		start := r.pos - stripe*stripeSize
+		stop := stripeSize
+		if stripeEnd := r.end - stripe*stripeSize; stripeEnd < stop {
+			stop = stripeEnd
+		}
+		r.buf = data[start:stop]
+		r.pos += stop - start
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *erasureReader) Close() error {
+	r.set.close()
+	return nil
+}
+
+// Repair scans all blobs and rebuilds missing or corrupted shards from the
+// remaining ones. It returns the number of shards rebuilt.
+func (e *ErasureBlobStore) Repair(ctx context.Context) (int, error) {
+	names, err := e.listBlobs()
+	if err != nil {
+		return 0, err
+	}
+
+	repaired := 0
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return repaired, err
+		}
+		n, err := e.repairBlob(name)
+		if err != nil {
+			return repaired, fmt.Errorf("failed to repair %s: %w", name, err)
+		}
+		repaired += n
+	}
+
+	return repaired, nil
+}
+
+// listBlobs returns the names of all blobs that have at least one shard.
+func (e *ErasureBlobStore) listBlobs() ([]string, error) {
+	seen := make(map[string]bool)
+	for _, dir := range e.dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Skip unreadable directories, e.g. a failed disk
+				return filepath.SkipDir
+			}
+			if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return nil
+			}
+			seen[filepath.ToSlash(rel)] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// repairBlob rebuilds the bad shards of a single blob.
+func (e *ErasureBlobStore) repairBlob(name string) (int, error) {
+	set, err := e.openShards(name)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer set.close()
+
+	// Find shards that are bad in any stripe
+	total := len(e.dirs)
+	stripes := (set.size + e.stripeSize() - 1) / e.stripeSize()
+	rebuild := make([]bool, total)
+	for i, f := range set.files {
+		rebuild[i] = f == nil
+	}
+	for s := int64(0); s < stripes; s++ {
+		_, bad, err := e.readStripe(set, s)
+		if err != nil {
+			return 0, err
+		}
+		for i := range bad {
+			rebuild[i] = rebuild[i] || bad[i]
+		}
+	}
+
+	var targets []int
+	for i, r := range rebuild {
+		if r {
+			targets = append(targets, i)
+		}
+	}
+	if len(targets) == 0 {
+		return 0, nil
+	}
+
+	// Take the id from a healthy shard header so rebuilt shards join the same write
+	var header *shardHeader
+	for _, f := range set.files {
+		if f == nil {
+			continue
+		}
+		buf := make([]byte, erasureHeaderSize)
+		if _, err := f.ReadAt(buf, 0); err == nil {
+			header, err = unmarshalShardHeader(buf)
+			if err == nil {
+				break
+			}
+		}
+	}
+	if header == nil {
+		return 0, errTooFewShards
+	}
+
+	tmps := make([]*os.File, len(targets))
+	defer func() {
+		for _, tmp := range tmps {
+			if tmp != nil {
+				tmp.Close()
+				os.Remove(tmp.Name())
+			}
+		}
+	}()
+	for t, target := range targets {
+		path := e.shardPath(target, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return 0, err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+		if err != nil {
+			return 0, err
+		}
+		tmps[t] = tmp
+		h := *header
+		h.index = uint16(target)
+		if _, err := tmp.Write(h.marshal()); err != nil {
+			return 0, err
+		}
+	}
+
+	for s := int64(0); s < stripes; s++ {
+		shards, _, err := e.readStripe(set, s)
+		if err != nil {
+			return 0, err
+		}
+		for t, target := range targets {
+			if err := writeShardBlock(tmps[t], shards[target]); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	repaired := 0
+	for t, target := range targets {
+		tmp := tmps[t]
+		tmps[t] = nil
+		if err := tmp.Close(); err != nil {
+			os.Remove(tmp.Name())
+			return repaired, err
+		}
+		if err := os.Rename(tmp.Name(), e.shardPath(target, name)); err != nil {
+			os.Remove(tmp.Name())
+			return repaired, err
+		}
+		repaired++
+	}
+
+	return repaired, nil
+}
+
+// writeShardBlock writes a shard block followed by its CRC-32 checksum.
+func writeShardBlock(w io.Writer, block []byte) error {
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(block))
+	if _, err := w.Write(block); err != nil {
+		return err
+	}
+	_, err := w.Write(sum[:])
+	return err
+}
+
+// marshal encodes the shard header.
+func (h *shardHeader) marshal() []byte {
+	buf := make([]byte, erasureHeaderSize)
+	copy(buf[0:8], erasureMagic)
+	copy(buf[8:16], h.id[:])
+	binary.BigEndian.PutUint64(buf[16:24], uint64(h.size))
+	binary.BigEndian.PutUint16(buf[24:26], h.dataShards)
+	binary.BigEndian.PutUint16(buf[26:28], h.parityShards)
+	binary.BigEndian.PutUint16(buf[28:30], h.index)
+	binary.BigEndian.PutUint32(buf[32:36], h.blockSize)
+	return buf
+}
+
+// unmarshalShardHeader decodes a shard header.
+func unmarshalShardHeader(buf []byte) (*shardHeader, error) {
+	if len(buf) < erasureHeaderSize || string(buf[0:8]) != erasureMagic {
+		return nil, errors.New("invalid shard header")
+	}
+	h := &shardHeader{
+		size:         int64(binary.BigEndian.Uint64(buf[16:24])),
+		dataShards:   binary.BigEndian.Uint16(buf[24:26]),
+		parityShards: binary.BigEndian.Uint16(buf[26:28]),
+		index:        binary.BigEndian.Uint16(buf[28:30]),
+		blockSize:    binary.BigEndian.Uint32(buf[32:36]),
+	}
+	copy(h.id[:], buf[8:16])
+	return h, nil
+}
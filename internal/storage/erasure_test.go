@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReedSolomon_Reconstruct(t *testing.T) {
+	rs, err := newReedSolomon(4, 2)
+	if err != nil {
+		t.Fatalf("newReedSolomon: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	shards := make([][]byte, 6)
+	for i := range shards {
+		shards[i] = make([]byte, 32)
+		if i < 4 {
+			rng.Read(shards[i])
+		}
+	}
+	rs.encode(shards)
+
+	original := make([][]byte, len(shards))
+	for i := range shards {
+		original[i] = append([]byte(nil), shards[i]...)
+	}
+
+	// Any two shards may be lost
+	for a := 0; a < 6; a++ {
+		for b := a + 1; b < 6; b++ {
+			damaged := make([][]byte, len(original))
+			copy(damaged, original)
+			damaged[a], damaged[b] = nil, nil
+			if err := rs.reconstruct(damaged); err != nil {
+				t.Fatalf("reconstruct without shards %d and %d: %v", a, b, err)
+			}
+			for i := range damaged {
+				if !bytes.Equal(damaged[i], original[i]) {
+					t.Fatalf("shard %d differs after losing shards %d and %d", i, a, b)
+				}
+			}
+		}
+	}
+
+	damaged := make([][]byte, len(original))
+	copy(damaged, original)
+	damaged[0], damaged[1], damaged[2] = nil, nil, nil
+	if err := rs.reconstruct(damaged); !errors.Is(err, errTooFewShards) {
+		t.Errorf("expected errTooFewShards, got %v", err)
+	}
+}
+
+func newTestErasureBlobStore(t *testing.T, dataShards, parityShards int) *ErasureBlobStore {
+	t.Helper()
+	root := t.TempDir()
+	var dirs []string
+	for i := 0; i < dataShards+parityShards; i++ {
+		dirs = append(dirs, filepath.Join(root, "disk"+string(rune('0'+i))))
+	}
+	store, err := NewErasureBlobStore(dirs, dataShards, parityShards)
+	if err != nil {
+		t.Fatalf("NewErasureBlobStore: %v", err)
+	}
+	return store
+}
+
+func readBlob(t *testing.T, store BlobStore, name string, offset, length int64) []byte {
+	t.Helper()
+	rc, err := store.GetBlob(context.Background(), name, offset, length)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("failed to read blob: %v", err)
+	}
+	return data
+}
+
+func TestErasureBlobStore_DiskFailure(t *testing.T) {
+	ctx := context.Background()
+	store := newTestErasureBlobStore(t, 3, 1)
+
+	// Spans several stripes with a partial last stripe
+	data := make([]byte, 3*erasureShardBlockSize*2+12345)
+	rand.New(rand.NewSource(2)).Read(data)
+	if err := store.PutBlob(ctx, "bucket/dir/key", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("PutBlob: %v", err)
+	}
+
+	if got := readBlob(t, store, "bucket/dir/key", 0, -1); !bytes.Equal(got, data) {
+		t.Fatalf("blob differs after round trip")
+	}
+
+	// Lose a whole disk
+	if err := os.RemoveAll(store.dirs[1]); err != nil {
+		t.Fatalf("failed to remove disk: %v", err)
+	}
+	if got := readBlob(t, store, "bucket/dir/key", 0, -1); !bytes.Equal(got, data) {
+		t.Fatalf("blob differs with a failed disk")
+	}
+	offset, length := int64(erasureShardBlockSize-10), int64(3*erasureShardBlockSize)
+	if got := readBlob(t, store, "bucket/dir/key", offset, length); !bytes.Equal(got, data[offset:offset+length]) {
+		t.Fatalf("range differs with a failed disk")
+	}
+
+	// Corrupt a block on another disk; with one disk already lost the stripe is unreadable
+	path := store.shardPath(2, "bucket/dir/key")
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("failed to open shard: %v", err)
+	}
+	f.WriteAt([]byte("garbage"), erasureHeaderSize+10)
+	f.Close()
+	rc, err := store.GetBlob(ctx, "bucket/dir/key", 0, -1)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	if _, err := io.ReadAll(rc); !errors.Is(err, errTooFewShards) {
+		t.Errorf("expected errTooFewShards, got %v", err)
+	}
+	rc.Close()
+}
+
+func TestErasureBlobStore_Repair(t *testing.T) {
+	ctx := context.Background()
+	store := newTestErasureBlobStore(t, 2, 2)
+
+	data := make([]byte, 2*erasureShardBlockSize+100)
+	rand.New(rand.NewSource(3)).Read(data)
+	if err := store.PutBlob(ctx, "bucket/key", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("PutBlob: %v", err)
+	}
+	if err := store.PutBlob(ctx, "bucket/empty", bytes.NewReader(nil), 0); err != nil {
+		t.Fatalf("PutBlob: %v", err)
+	}
+
+	// Lose one shard and corrupt another
+	os.Remove(store.shardPath(0, "bucket/key"))
+	f, err := os.OpenFile(store.shardPath(3, "bucket/key"), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("failed to open shard: %v", err)
+	}
+	f.WriteAt([]byte("garbage"), erasureHeaderSize+erasureShardBlockSize+4+1)
+	f.Close()
+
+	repaired, err := store.Repair(ctx)
+	if err != nil {
+		t.Fatalf("Repair: %v", err)
+	}
+	if repaired != 2 {
+		t.Errorf("expected 2 repaired shards, got %d", repaired)
+	}
+
+	// After repair the blob survives losing the two other shards
+	os.Remove(store.shardPath(1, "bucket/key"))
+	os.Remove(store.shardPath(2, "bucket/key"))
+	if got := readBlob(t, store, "bucket/key", 0, -1); !bytes.Equal(got, data) {
+		t.Fatalf("blob differs after repair")
+	}
+	if got := readBlob(t, store, "bucket/empty", 0, -1); len(got) != 0 {
+		t.Errorf("expected empty blob, got %d bytes", len(got))
+	}
+
+	if err := store.DeleteBlob(ctx, "bucket/key"); err != nil {
+		t.Fatalf("DeleteBlob: %v", err)
+	}
+	if _, err := store.GetBlob(ctx, "bucket/key", 0, -1); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+}
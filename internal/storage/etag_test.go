@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestMD5ETags(t *testing.T) {
+	sum := md5.Sum([]byte("hello"))
+	if got, want := (MD5ETags{}).ObjectETag(sum[:]), "5d41402abc4b2a76b9719d911017c592"; got != want {
+		t.Errorf("ObjectETag = %q, want %q", got, want)
+	}
+
+	partA := md5.Sum([]byte("a"))
+	partB := md5.Sum([]byte("b"))
+	composite := md5.Sum(append(partA[:], partB[:]...))
+	want := hex.EncodeToString(composite[:]) + "-2"
+	got := (MD5ETags{}).MultipartETag([]string{hex.EncodeToString(partA[:]), hex.EncodeToString(partB[:])})
+	if got != want {
+		t.Errorf("MultipartETag = %q, want %q", got, want)
+	}
+}
+
+func TestEncryptedBucketRandomETags(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	fs.SetEncryptedETagMode(ETagModeRandom)
+
+	for _, bucket := range []string{"plain", "encrypted"} {
+		if err := fs.CreateBucket(ctx, bucket); err != nil {
+			t.Fatalf("CreateBucket: %v", err)
+		}
+	}
+	err := fs.PutBucketEncryption(ctx, "encrypted", &ServerSideEncryptionConfiguration{
+		Rules: []ServerSideEncryptionRule{{
+			ApplyServerSideEncryptionByDefault: &ServerSideEncryptionByDefault{SSEAlgorithm: "AES256"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("PutBucketEncryption: %v", err)
+	}
+
+	sum := md5.Sum([]byte("content"))
+	plainMD5 := hex.EncodeToString(sum[:])
+
+	plain, err := fs.PutObject(ctx, "plain", "obj", strings.NewReader("content"), 7, "", nil)
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if plain.ETag != plainMD5 {
+		t.Errorf("unencrypted ETag = %q, want MD5 %q", plain.ETag, plainMD5)
+	}
+
+	encrypted, err := fs.PutObject(ctx, "encrypted", "obj", strings.NewReader("content"), 7, "", nil)
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if encrypted.ETag == plainMD5 || len(encrypted.ETag) != 32 {
+		t.Errorf("encrypted ETag = %q, want a random 32 digit hex value", encrypted.ETag)
+	}
+
+	copied, err := fs.CopyObject(ctx, "plain", "obj", "encrypted", "copy", nil)
+	if err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if copied.ETag == plainMD5 {
+		t.Error("copy into encrypted bucket reveals the content MD5")
+	}
+
+	upload, err := fs.CreateMultipartUpload(ctx, "encrypted", "multi", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	var parts []Part
+	for i, data := range []string{"part-1", "part-2"} {
+		part, err := fs.UploadPart(ctx, "encrypted", "multi", upload.UploadID, int32(i+1), strings.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+		partSum := md5.Sum([]byte(data))
+		if part.ETag == hex.EncodeToString(partSum[:]) {
+			t.Errorf("part %d ETag reveals the content MD5", i+1)
+		}
+		parts = append(parts, Part{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	multi, err := fs.CompleteMultipartUpload(ctx, "encrypted", "multi", upload.UploadID, parts)
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	if !strings.HasSuffix(multi.ETag, "-2") {
+		t.Errorf("multipart ETag = %q, want part count suffix -2", multi.ETag)
+	}
+}
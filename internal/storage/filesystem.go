@@ -4,23 +4,54 @@ import (
 	"context"
 	"crypto/md5"
 	"crypto/rand"
+	"encoding"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/kumasuke/jog/internal/kms"
 )
 
 // FileSystem implements Storage using local file system.
 type FileSystem struct {
 	dataDir  string
 	metadata *Metadata
+
+	// keyLocks serialize writes to the same object key, see lockKey.
+	keyLocks [keyLockStripes]sync.Mutex
+	// uploadLocks serialize the parts and state of an upload, see lockUpload.
+	uploadLocks [keyLockStripes]sync.Mutex
+
+	// encryptedETagMode selects the ETags of objects in encrypted buckets.
+	encryptedETagMode ETagMode
+
+	// pathEncoding and layout map object keys to file paths, see keyPath.
+	pathEncoding PathEncoding
+	layout       Layout
+
+	// versionIDFormat selects the format of new version IDs.
+	versionIDFormat VersionIDFormat
+
+	// compression selects the objects compressed at rest.
+	compression CompressionPolicy
+
+	// kms wraps the data keys of objects encrypted at rest; nil stores all
+	// objects in plaintext.
+	kms             kms.Provider
+	kmsDefaultKeyID string
+
+	// partLocks serializes writes to the in-progress data of resumable part uploads.
+	partLocksMu sync.Mutex
+	partLocks   map[string]*partLock
 }
 
 // NewFileSystem creates a new file system storage backend.
@@ -60,7 +91,7 @@ func (fs *FileSystem) CreateBucket(ctx context.Context, name string) error {
 	}
 
 	// Save bucket metadata
-	return fs.metadata.CreateBucket(ctx, name, time.Now())
+	return fs.metadata.CreateBucket(ctx, name, time.Now(), OwnerFromContext(ctx), BucketRegionFromContext(ctx))
 }
 
 // DeleteBucket deletes a bucket.
@@ -95,7 +126,7 @@ func (fs *FileSystem) DeleteBucket(ctx context.Context, name string) error {
 
 // HeadBucket returns bucket metadata if it exists.
 func (fs *FileSystem) HeadBucket(ctx context.Context, name string) (*Bucket, error) {
-	bucket, err := fs.metadata.GetBucket(ctx, name)
+	bucket, err := fs.metadata.GetBucket(ctx, name, OwnerFromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -105,9 +136,9 @@ func (fs *FileSystem) HeadBucket(ctx context.Context, name string) (*Bucket, err
 	return bucket, nil
 }
 
-// ListBuckets returns all buckets.
+// ListBuckets returns the buckets visible to the caller.
 func (fs *FileSystem) ListBuckets(ctx context.Context) ([]Bucket, error) {
-	return fs.metadata.ListBuckets(ctx)
+	return fs.metadata.ListBuckets(ctx, OwnerFromContext(ctx))
 }
 
 // PutObject stores an object.
@@ -126,15 +157,25 @@ func (fs *FileSystem) PutObject(ctx context.Context, bucket, key string, body io
 	if !exists {
 		return nil, ErrBucketNotFound
 	}
-	objectDir := filepath.Dir(objectPath)
-	if err := os.MkdirAll(objectDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create object directory: %w", err)
+
+	// Write-once buckets never overwrite objects (JOG extension)
+	if err := fs.checkWorm(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+
+	compression, err := fs.objectCompression(ctx, bucket, contentType, size)
+	if err != nil {
+		return nil, err
+	}
+	sse, err := fs.objectEncryption(ctx, bucket)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create temporary file
-	tmpFile, err := os.CreateTemp(objectDir, ".tmp-*")
+	tmpFile, err := createTempFile(filepath.Dir(objectPath))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+		return nil, err
 	}
 	tmpPath := tmpFile.Name()
 	defer func() {
@@ -142,49 +183,325 @@ func (fs *FileSystem) PutObject(ctx context.Context, bucket, key string, body io
 		os.Remove(tmpPath) // Clean up temp file if we don't rename it
 	}()
 
-	// Write data and calculate MD5
+	// Write data and calculate MD5 over the uncompressed content, which is
+	// compressed before it is encrypted
+	encryptor, enc, err := fs.prepareEncryption(ctx, bucket, key, sse, tmpFile)
+	if err != nil {
+		return nil, err
+	}
 	hash := md5.New()
-	writer := io.MultiWriter(tmpFile, hash)
+	compressor := compressWriter(encryptor, compression)
+	writer := io.MultiWriter(compressor, hash)
 
 	written, err := io.Copy(writer, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to write object: %w", err)
 	}
 
+	if err := compressor.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress object: %w", err)
+	}
+	if err := encryptor.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt object: %w", err)
+	}
 	if err := tmpFile.Close(); err != nil {
 		return nil, fmt.Errorf("failed to close temp file: %w", err)
 	}
 
+	// Set default content type
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	unlock := fs.lockKey(ctx, bucket, key)
+	defer unlock()
+
+	// Identical overwrites keep the current file (JOG extension), unless
+	// the new object is to be encrypted
+	if enc == nil {
+		current, err := fs.unchangedObject(ctx, bucket, key, hash.Sum(nil), written)
+		if err != nil {
+			return nil, err
+		}
+		if current != nil {
+			return fs.keepUnchangedObject(ctx, bucket, current, contentType, metadata)
+		}
+	}
+
 	// Calculate ETag
-	etag := hex.EncodeToString(hash.Sum(nil))
+	etag, err := fs.objectETag(ctx, bucket, hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
 
 	// Rename temp file to final path
 	if err := os.Rename(tmpPath, objectPath); err != nil {
 		return nil, fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
-	// Set default content type
+	// Save object metadata
+	obj := &Object{
+		Key:          key,
+		Size:         written,
+		LastModified: time.Now(),
+		ETag:         etag,
+		ContentType:  contentType,
+		Metadata:     metadata,
+	}
+
+	if err := fs.putObjectAtRest(ctx, bucket, obj, enc, compression); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// AppendObject appends data to an object at the given position.
+// The position must be 0 for a new object, or equal to the current size of
+// an existing object. The appended object is rewritten through a temp file so
+// that readers never observe a partially appended object.
+func (fs *FileSystem) AppendObject(ctx context.Context, bucket, key string, position int64, body io.Reader, size int64, contentType string, metadata map[string]string) (*Object, error) {
+	// Validate object key to prevent path traversal
+	objectPath, err := fs.validateObjectKey(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	// The position check and the size/ETag update happen atomically
+	unlock := fs.lockKey(ctx, bucket, key)
+	defer unlock()
+
+	// Objects of write-once buckets cannot be extended
+	if err := fs.checkWorm(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+
+	// Get current object metadata
+	current, err := fs.metadata.GetObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var currentSize int64
+	if current != nil {
+		// Multipart objects do not have a plain MD5 ETag and cannot be extended
+		if strings.Contains(current.ETag, "-") {
+			return nil, ErrObjectNotAppendable
+		}
+		currentSize = current.Size
+	}
+	if position != currentSize {
+		return nil, &PositionNotEqualToLengthError{Length: currentSize}
+	}
+	sse, err := fs.objectEncryption(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	// Plaintext objects are extended in place, so that an append only
+	// writes its own data. Encrypted and compressed objects are rewritten,
+	// as their final segment or stream has to be sealed again.
+	if current != nil && sse == nil {
+		plain, err := fs.isPlainObject(ctx, bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		if plain {
+			return fs.appendInPlace(ctx, bucket, key, objectPath, current, body, size)
+		}
+	}
+
+	// Create temporary file
+	tmpFile, err := createTempFile(filepath.Dir(objectPath))
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		tmpFile.Close()
+		os.Remove(tmpPath) // Clean up temp file if we don't rename it
+	}()
+
+	// Write existing data followed by appended data, calculating MD5 over both
+	encryptor, enc, err := fs.prepareEncryption(ctx, bucket, key, sse, tmpFile)
+	if err != nil {
+		return nil, err
+	}
+	hash := md5.New()
+	writer := io.MultiWriter(encryptor, hash)
+
+	if current != nil {
+		srcFile, err := fs.openObjectFile(ctx, bucket, key, objectPath, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open object file: %w", err)
+		}
+		_, err = io.Copy(writer, srcFile)
+		srcFile.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy object: %w", err)
+		}
+	}
+
+	written, err := io.Copy(writer, io.LimitReader(body, size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to append object: %w", err)
+	}
+	if written != size {
+		return nil, fmt.Errorf("failed to append object: %w", io.ErrUnexpectedEOF)
+	}
+
+	if err := encryptor.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt object: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	// Calculate ETag
+	etag, err := fs.objectETag(ctx, bucket, hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	// Rename temp file to final path
+	if err := os.Rename(tmpPath, objectPath); err != nil {
+		return nil, fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	// Content type and metadata are fixed by the first append
+	if current != nil {
+		contentType = current.ContentType
+		metadata = current.Metadata
+	}
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
-	// Save object metadata
 	obj := &Object{
 		Key:          key,
-		Size:         written,
+		Size:         currentSize + written,
 		LastModified: time.Now(),
 		ETag:         etag,
 		ContentType:  contentType,
 		Metadata:     metadata,
 	}
 
-	if err := fs.metadata.PutObject(ctx, bucket, obj); err != nil {
+	if err := fs.putObjectAtRest(ctx, bucket, obj, enc, CompressionNone); err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		fs.putAppendDigest(ctx, bucket, obj, hash)
+	}
+
+	return obj, nil
+}
+
+// isPlainObject reports whether an object is stored uncompressed and
+// unencrypted, so that its file holds exactly its data.
+func (fs *FileSystem) isPlainObject(ctx context.Context, bucket, key string) (bool, error) {
+	algorithm, err := fs.metadata.GetObjectCompression(ctx, bucket, key)
+	if err != nil || algorithm != "" {
+		return false, err
+	}
+	enc, err := fs.metadata.GetObjectEncryption(ctx, bucket, key, "")
+	return enc == nil, err
+}
+
+// appendInPlace appends size bytes of body to the file of a plaintext
+// object. It must be called with the key lock held. Readers are limited to
+// the recorded size of the object, so they do not see appended data before
+// the metadata is updated; data left beyond the size by a failed append is
+// cut off by the next one.
+func (fs *FileSystem) appendInPlace(ctx context.Context, bucket, key, objectPath string, current *Object, body io.Reader, size int64) (*Object, error) {
+	hash, err := fs.appendDigest(ctx, bucket, key, objectPath, current)
+	if err != nil {
 		return nil, err
 	}
 
+	file, err := os.OpenFile(objectPath, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object file: %w", err)
+	}
+	defer file.Close()
+	if err := file.Truncate(current.Size); err != nil {
+		return nil, fmt.Errorf("failed to truncate object file: %w", err)
+	}
+	if _, err := file.Seek(current.Size, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek: %w", err)
+	}
+
+	written, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(body, size))
+	if err == nil && written != size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		file.Truncate(current.Size)
+		return nil, fmt.Errorf("failed to append object: %w", err)
+	}
+
+	etag, err := fs.objectETag(ctx, bucket, hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	obj := &Object{
+		Key:          key,
+		Size:         current.Size + written,
+		LastModified: time.Now(),
+		ETag:         etag,
+		ContentType:  current.ContentType,
+		Metadata:     current.Metadata,
+	}
+	if err := fs.metadata.PutObject(ctx, bucket, obj); err != nil {
+		return nil, err
+	}
+	fs.putAppendDigest(ctx, bucket, obj, hash)
 	return obj, nil
 }
 
+// appendDigest returns the MD5 state of a plaintext object, from its
+// recorded state if it is current and otherwise by reading the object once.
+func (fs *FileSystem) appendDigest(ctx context.Context, bucket, key, objectPath string, current *Object) (hash.Hash, error) {
+	h := md5.New()
+	state, err := fs.metadata.GetAppendDigest(ctx, bucket, key, current.ETag, current.Size)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil && h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state) == nil {
+		return h, nil
+	}
+
+	h.Reset()
+	file, err := os.Open(objectPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object file: %w", err)
+	}
+	defer file.Close()
+	if n, err := io.Copy(h, io.LimitReader(file, current.Size)); err != nil || n != current.Size {
+		return nil, fmt.Errorf("failed to read object file: %w", errors.Join(err, io.ErrUnexpectedEOF))
+	}
+	return h, nil
+}
+
+// putAppendDigest records the MD5 state of an appended object. Failures are
+// ignored, as the next append reads the object instead.
+func (fs *FileSystem) putAppendDigest(ctx context.Context, bucket string, obj *Object, h hash.Hash) {
+	if state, err := h.(encoding.BinaryMarshaler).MarshalBinary(); err == nil {
+		_ = fs.metadata.PutAppendDigest(ctx, bucket, obj.Key, obj.ETag, obj.Size, state)
+	}
+}
+
 // GetObject retrieves an object.
 func (fs *FileSystem) GetObject(ctx context.Context, bucket, key string) (*ObjectData, error) {
 	// Validate object key to prevent path traversal
@@ -208,11 +525,11 @@ func (fs *FileSystem) GetObject(ctx context.Context, bucket, key string) (*Objec
 		return nil, err
 	}
 	if obj == nil {
-		return nil, ErrObjectNotFound
+		return nil, fs.objectNotFound(ctx, bucket, key)
 	}
 
 	// Open object file
-	file, err := os.Open(objectPath)
+	file, err := fs.openObjectFile(ctx, bucket, key, objectPath, 0)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, ErrObjectNotFound
@@ -220,9 +537,11 @@ func (fs *FileSystem) GetObject(ctx context.Context, bucket, key string) (*Objec
 		return nil, fmt.Errorf("failed to open object file: %w", err)
 	}
 
+	// Appends extend plaintext files before the metadata, so only the
+	// recorded size is read
 	return &ObjectData{
 		Object: *obj,
-		Body:   file,
+		Body:   &limitedReader{file, obj.Size},
 	}, nil
 }
 
@@ -249,11 +568,11 @@ func (fs *FileSystem) GetObjectRange(ctx context.Context, bucket, key string, st
 		return nil, err
 	}
 	if obj == nil {
-		return nil, ErrObjectNotFound
+		return nil, fs.objectNotFound(ctx, bucket, key)
 	}
 
-	// Open object file
-	file, err := os.Open(objectPath)
+	// Open object file at the start position
+	file, err := fs.openObjectFile(ctx, bucket, key, objectPath, start)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, ErrObjectNotFound
@@ -261,12 +580,6 @@ func (fs *FileSystem) GetObjectRange(ctx context.Context, bucket, key string, st
 		return nil, fmt.Errorf("failed to open object file: %w", err)
 	}
 
-	// Seek to start position
-	if _, err := file.Seek(start, io.SeekStart); err != nil {
-		file.Close()
-		return nil, fmt.Errorf("failed to seek: %w", err)
-	}
-
 	// Calculate size for range
 	rangeSize := end - start + 1
 
@@ -327,12 +640,21 @@ func (fs *FileSystem) HeadObject(ctx context.Context, bucket, key string) (*Obje
 		return nil, err
 	}
 	if obj == nil {
-		return nil, ErrObjectNotFound
+		return nil, fs.objectNotFound(ctx, bucket, key)
 	}
 
 	return obj, nil
 }
 
+// GetObjectParts returns the parts of an object created by multipart upload,
+// ordered by part number, or nil for objects uploaded in a single request.
+func (fs *FileSystem) GetObjectParts(ctx context.Context, bucket, key string) ([]ObjectPart, error) {
+	if _, err := fs.HeadObject(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+	return fs.metadata.GetObjectParts(ctx, bucket, key)
+}
+
 // DeleteObject deletes an object.
 func (fs *FileSystem) DeleteObject(ctx context.Context, bucket, key string) error {
 	// Validate object key to prevent path traversal
@@ -350,6 +672,20 @@ func (fs *FileSystem) DeleteObject(ctx context.Context, bucket, key string) erro
 		return ErrBucketNotFound
 	}
 
+	// Write-once buckets never delete objects (JOG extension)
+	if err := fs.checkWorm(ctx, bucket, key); err != nil {
+		return err
+	}
+
+	unlock := fs.lockKey(ctx, bucket, key)
+	defer unlock()
+	defer fs.pruneEmptyDirs(bucket, objectPath)
+
+	// Buckets in trash mode keep the object until its retention ends
+	if trashed, err := fs.trashObject(ctx, bucket, key, objectPath); err != nil || trashed {
+		return err
+	}
+
 	// Delete object file
 	if err := os.Remove(objectPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete object file: %w", err)
@@ -371,6 +707,16 @@ func (fs *FileSystem) CopyObject(ctx context.Context, srcBucket, srcKey, dstBuck
 		return nil, err
 	}
 
+	// Write-once buckets never overwrite objects (JOG extension)
+	if err := fs.checkWorm(ctx, dstBucket, dstKey); err != nil {
+		return nil, err
+	}
+
+	// Copying an object onto itself with new metadata keeps its data
+	if srcPath == dstPath && metadata != nil {
+		return fs.replaceObjectMetadata(ctx, dstBucket, dstKey, metadata)
+	}
+
 	// Check if source bucket exists
 	exists, err := fs.metadata.BucketExists(ctx, srcBucket)
 	if err != nil {
@@ -398,15 +744,14 @@ func (fs *FileSystem) CopyObject(ctx context.Context, srcBucket, srcKey, dstBuck
 		return nil, ErrObjectNotFound
 	}
 
-	// Open source file
-	srcFile, err := os.Open(srcPath)
+	compression, err := fs.objectCompression(ctx, dstBucket, srcObj.ContentType, srcObj.Size)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrObjectNotFound
-		}
-		return nil, fmt.Errorf("failed to open source object: %w", err)
+		return nil, err
+	}
+	sse, err := fs.objectEncryption(ctx, dstBucket)
+	if err != nil {
+		return nil, err
 	}
-	defer srcFile.Close()
 
 	// Create destination directory
 	dstDir := filepath.Dir(dstPath)
@@ -414,36 +759,23 @@ func (fs *FileSystem) CopyObject(ctx context.Context, srcBucket, srcKey, dstBuck
 		return nil, fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
-	// Create temporary file
-	tmpFile, err := os.CreateTemp(dstDir, ".tmp-*")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
-	}
-	tmpPath := tmpFile.Name()
-	defer func() {
-		tmpFile.Close()
-		os.Remove(tmpPath) // Clean up temp file if we don't rename it
-	}()
+	unlock := fs.lockKey(ctx, dstBucket, dstKey)
+	defer unlock()
 
-	// Copy file and calculate MD5
-	hash := md5.New()
-	writer := io.MultiWriter(tmpFile, hash)
-
-	written, err := io.Copy(writer, srcFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to copy object: %w", err)
+	// Share the source data instead of copying it where possible. Encrypted
+	// copies get a data key of their own, so their data is never shared.
+	var etag string
+	var written int64
+	var enc *ObjectEncryption
+	err = errCloneUnsupported
+	if sse == nil {
+		etag, written, err = fs.cloneObjectFile(ctx, srcBucket, srcKey, srcPath, srcObj, dstBucket, dstPath, compression)
 	}
-
-	if err := tmpFile.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close temp file: %w", err)
+	if errors.Is(err, errCloneUnsupported) {
+		etag, written, enc, err = fs.copyObjectFile(ctx, srcBucket, srcKey, srcPath, dstBucket, dstKey, dstPath, compression, sse)
 	}
-
-	// Calculate ETag
-	etag := hex.EncodeToString(hash.Sum(nil))
-
-	// Rename temp file to final path
-	if err := os.Rename(tmpPath, dstPath); err != nil {
-		return nil, fmt.Errorf("failed to rename temp file: %w", err)
+	if err != nil {
+		return nil, err
 	}
 
 	// Determine metadata to use
@@ -467,13 +799,107 @@ func (fs *FileSystem) CopyObject(ctx context.Context, srcBucket, srcKey, dstBuck
 	}
 
 	// Save object metadata
-	if err := fs.metadata.PutObject(ctx, dstBucket, obj); err != nil {
+	if err := fs.putObjectAtRest(ctx, dstBucket, obj, enc, compression); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// replaceObjectMetadata handles a copy of an object onto itself with new
+// metadata, which updates the metadata without rewriting the data.
+func (fs *FileSystem) replaceObjectMetadata(ctx context.Context, bucket, key string, metadata map[string]string) (*Object, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, &BucketNotFoundError{Bucket: bucket}
+	}
+
+	unlock := fs.lockKey(ctx, bucket, key)
+	defer unlock()
+
+	obj, err := fs.metadata.GetObject(ctx, bucket, key)
+	if err != nil {
 		return nil, err
 	}
+	if obj == nil {
+		return nil, ErrObjectNotFound
+	}
 
+	obj.Metadata = metadata
+	obj.LastModified = time.Now()
+	if err := fs.metadata.UpdateObjectMetadata(ctx, bucket, key, obj.Metadata, obj.LastModified); err != nil {
+		return nil, err
+	}
 	return obj, nil
 }
 
+// copyObjectFile stores the destination of a copy by copying the source data,
+// compressed with compression and encrypted with sse. It returns the ETag,
+// size and encryption of the copy.
+func (fs *FileSystem) copyObjectFile(ctx context.Context, srcBucket, srcKey, srcPath, dstBucket, dstKey, dstPath string, compression CompressionAlgorithm, sse *ServerSideEncryption) (string, int64, *ObjectEncryption, error) {
+	// Open source file
+	srcFile, err := fs.openObjectFile(ctx, srcBucket, srcKey, srcPath, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", 0, nil, ErrObjectNotFound
+		}
+		return "", 0, nil, fmt.Errorf("failed to open source object: %w", err)
+	}
+	defer srcFile.Close()
+
+	// Create temporary file
+	tmpFile, err := createTempFile(filepath.Dir(dstPath))
+	if err != nil {
+		return "", 0, nil, err
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		tmpFile.Close()
+		os.Remove(tmpPath) // Clean up temp file if we don't rename it
+	}()
+
+	// Copy file and calculate MD5 over the uncompressed content
+	encryptor, enc, err := fs.prepareEncryption(ctx, dstBucket, dstKey, sse, tmpFile)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	hash := md5.New()
+	compressor := compressWriter(encryptor, compression)
+	writer := io.MultiWriter(compressor, hash)
+
+	written, err := io.Copy(writer, srcFile)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	if err := compressor.Close(); err != nil {
+		return "", 0, nil, fmt.Errorf("failed to compress object: %w", err)
+	}
+	if err := encryptor.Close(); err != nil {
+		return "", 0, nil, fmt.Errorf("failed to encrypt object: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", 0, nil, fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	// Calculate ETag
+	etag, err := fs.objectETag(ctx, dstBucket, hash.Sum(nil))
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	// Rename temp file to final path
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		return "", 0, nil, fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	return etag, written, enc, nil
+}
+
 // ListObjectsV2 lists objects in a bucket.
 func (fs *FileSystem) ListObjectsV2(ctx context.Context, input *ListObjectsInput) (*ListObjectsOutput, error) {
 	// Check if bucket exists
@@ -497,61 +923,76 @@ func (fs *FileSystem) ListObjectsV2(ctx context.Context, input *ListObjectsInput
 		startKey = input.ContinuationToken
 	}
 
-	// Get objects with prefix, pagination handled by SQL
-	// Request more objects when delimiter is used (we may need to skip common prefixes)
-	fetchLimit := maxKeys
-	if input.Delimiter != "" {
-		// Fetch more to account for common prefixes that will be collapsed
-		fetchLimit = maxKeys * 10
-		if fetchLimit > 10000 {
-			fetchLimit = 10000
-		}
+	// A start key returned for a common prefix skips the whole prefix
+	var skipPrefix string
+	if input.Delimiter != "" && strings.HasPrefix(startKey, input.Prefix) && strings.HasSuffix(startKey, input.Delimiter) {
+		skipPrefix = startKey
 	}
 
-	objects, err := fs.metadata.ListObjects(ctx, input.Bucket, input.Prefix, startKey, fetchLimit)
-	if err != nil {
-		return nil, err
+	// Objects and common prefixes are merged in key order and count alike
+	// towards MaxKeys, as in S3. Keys rolled up into common prefixes are
+	// fetched in batches until the page is full.
+	fetchLimit := maxKeys
+	if input.Delimiter != "" {
+		fetchLimit = 1000
 	}
 
-	// Handle delimiter for common prefixes
-	var resultObjects []Object
-	var commonPrefixes []string
-	commonPrefixMap := make(map[string]bool)
+	output := &ListObjectsOutput{}
+	var lastPrefix string
+	count := int32(0)
+	for !output.IsTruncated {
+		var objects []Object
+		if input.TagKey != "" {
+			objects, err = fs.metadata.ListTaggedObjects(ctx, input.Bucket, input.Prefix, startKey, fetchLimit, input.TagKey, input.TagValue)
+		} else {
+			objects, err = fs.metadata.ListObjects(ctx, input.Bucket, input.Prefix, startKey, fetchLimit)
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	if input.Delimiter != "" {
 		for _, obj := range objects {
-			// Find delimiter after prefix
-			if len(obj.Key) > len(input.Prefix) {
-				suffix := obj.Key[len(input.Prefix):]
-				idx := strings.Index(suffix, input.Delimiter)
-				if idx >= 0 {
-					// This is a common prefix
-					prefixKey := obj.Key[:len(input.Prefix)+idx+len(input.Delimiter)]
-					if !commonPrefixMap[prefixKey] {
-						commonPrefixMap[prefixKey] = true
-						commonPrefixes = append(commonPrefixes, prefixKey)
+			if skipPrefix != "" && strings.HasPrefix(obj.Key, skipPrefix) {
+				continue
+			}
+			var commonPrefix string
+			if input.Delimiter != "" && len(obj.Key) > len(input.Prefix) {
+				if idx := strings.Index(obj.Key[len(input.Prefix):], input.Delimiter); idx >= 0 {
+					commonPrefix = obj.Key[:len(input.Prefix)+idx+len(input.Delimiter)]
+					// Keys are sorted, so keys of a common prefix are adjacent
+					if commonPrefix == lastPrefix {
+						continue
 					}
-					continue
 				}
 			}
-			resultObjects = append(resultObjects, obj)
-		}
-	} else {
-		resultObjects = objects
-	}
 
-	output := &ListObjectsOutput{}
+			if count == maxKeys {
+				output.IsTruncated = true
+				break
+			}
+			count++
+
+			if commonPrefix != "" {
+				lastPrefix = commonPrefix
+				output.CommonPrefixes = append(output.CommonPrefixes, commonPrefix)
+				output.NextContinuationToken = commonPrefix
+			} else {
+				output.Objects = append(output.Objects, obj)
+				output.NextContinuationToken = obj.Key
+			}
+		}
 
-	// Check if we need pagination
-	if int32(len(resultObjects)) > maxKeys {
-		output.IsTruncated = true
-		output.NextContinuationToken = resultObjects[maxKeys-1].Key
-		resultObjects = resultObjects[:maxKeys]
+		// ListObjects returns one object beyond the limit if there are more
+		if int32(len(objects)) <= fetchLimit {
+			break
+		}
+		startKey = objects[len(objects)-1].Key
 	}
 
-	output.Objects = resultObjects
-	output.CommonPrefixes = commonPrefixes
-	output.KeyCount = int32(len(resultObjects))
+	if !output.IsTruncated {
+		output.NextContinuationToken = ""
+	}
+	output.KeyCount = count
 
 	return output, nil
 }
@@ -572,6 +1013,14 @@ func (fs *FileSystem) CreateMultipartUpload(ctx context.Context, bucket, key, co
 		return nil, ErrBucketNotFound
 	}
 
+	// Reject encryption that cannot be applied on completion up front
+	sse := ServerSideEncryptionFromContext(ctx)
+	if sse != nil {
+		if sse, err = fs.objectEncryption(ctx, bucket); err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate upload ID
 	uploadID := generateUploadID()
 
@@ -589,6 +1038,14 @@ func (fs *FileSystem) CreateMultipartUpload(ctx context.Context, bucket, key, co
 		Initiated:   time.Now(),
 	}
 
+	// Parts get checksums of the algorithm requested for the upload
+	if checksum := ChecksumFromContext(ctx); checksum != nil {
+		if !ValidChecksumAlgorithm(checksum.Algorithm) {
+			return nil, ErrChecksumAlgorithmMismatch
+		}
+		upload.ChecksumAlgorithm = strings.ToUpper(checksum.Algorithm)
+	}
+
 	// Create directory for parts
 	partsDir := filepath.Join(fs.dataDir, ".uploads", uploadID)
 	if err := os.MkdirAll(partsDir, 0755); err != nil {
@@ -600,29 +1057,45 @@ func (fs *FileSystem) CreateMultipartUpload(ctx context.Context, bucket, key, co
 		os.RemoveAll(partsDir)
 		return nil, err
 	}
+	if sse != nil {
+		if err := fs.metadata.PutUploadEncryption(ctx, uploadID, sse); err != nil {
+			fs.metadata.DeleteMultipartUpload(ctx, uploadID)
+			os.RemoveAll(partsDir)
+			return nil, err
+		}
+	}
+	if lock := ObjectLockFromContext(ctx); lock != nil {
+		if err := fs.metadata.PutUploadObjectLock(ctx, uploadID, lock); err != nil {
+			fs.metadata.DeleteMultipartUpload(ctx, uploadID)
+			os.RemoveAll(partsDir)
+			return nil, err
+		}
+	}
+	if tags := ObjectTagsFromContext(ctx); len(tags) > 0 {
+		if err := fs.metadata.PutUploadTags(ctx, uploadID, tags); err != nil {
+			fs.metadata.DeleteMultipartUpload(ctx, uploadID)
+			os.RemoveAll(partsDir)
+			return nil, err
+		}
+	}
 
 	return upload, nil
 }
 
 // UploadPart uploads a part for a multipart upload.
 func (fs *FileSystem) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.Reader, size int64) (*Part, error) {
-	// Check if upload exists
-	upload, err := fs.metadata.GetMultipartUpload(ctx, uploadID)
+	upload, err := fs.checkUpload(ctx, bucket, key, uploadID)
 	if err != nil {
 		return nil, err
 	}
-	if upload == nil {
-		return nil, ErrUploadNotFound
-	}
 
-	// Verify bucket and key match
-	if upload.Bucket != bucket || upload.Key != key {
-		return nil, ErrUploadNotFound
+	checksumAlgorithm, checksumHash, err := partChecksumHash(ctx, upload)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create part file
 	partsDir := filepath.Join(fs.dataDir, ".uploads", uploadID)
-	partPath := filepath.Join(partsDir, fmt.Sprintf("%d", partNumber))
 
 	// Write to temp file first
 	tmpFile, err := os.CreateTemp(partsDir, ".tmp-*")
@@ -635,9 +1108,12 @@ func (fs *FileSystem) UploadPart(ctx context.Context, bucket, key, uploadID stri
 		os.Remove(tmpPath)
 	}()
 
-	// Write data and calculate MD5
+	// Write data and calculate MD5 and the checksum
 	hash := md5.New()
 	writer := io.MultiWriter(tmpFile, hash)
+	if checksumHash != nil {
+		writer = io.MultiWriter(tmpFile, hash, checksumHash)
+	}
 
 	written, err := io.Copy(writer, body)
 	if err != nil {
@@ -648,27 +1124,35 @@ func (fs *FileSystem) UploadPart(ctx context.Context, bucket, key, uploadID stri
 		return nil, fmt.Errorf("failed to close temp file: %w", err)
 	}
 
-	// Calculate ETag
-	etag := hex.EncodeToString(hash.Sum(nil))
+	// A part whose checksum does not match is not stored
+	checksum, err := partChecksum(ctx, checksumHash)
+	if err != nil {
+		return nil, err
+	}
 
-	// Rename temp file to part file
-	if err := os.Rename(tmpPath, partPath); err != nil {
-		return nil, fmt.Errorf("failed to rename temp file: %w", err)
+	// Calculate ETag
+	etag, err := fs.objectETag(ctx, bucket, hash.Sum(nil))
+	if err != nil {
+		return nil, err
 	}
 
 	part := &Part{
-		PartNumber:   partNumber,
-		Size:         written,
-		ETag:         etag,
-		LastModified: time.Now(),
+		PartNumber:        partNumber,
+		Size:              written,
+		ETag:              etag,
+		LastModified:      time.Now(),
+		ChecksumAlgorithm: checksumAlgorithm,
+		Checksum:          checksum,
 	}
 
-	// Save part metadata
-	if err := fs.metadata.PutPart(ctx, uploadID, part); err != nil {
-		os.Remove(partPath)
+	// Rename temp file to part file and save part metadata
+	if err := fs.storePart(ctx, uploadID, tmpPath, part); err != nil {
 		return nil, err
 	}
 
+	// The part was uploaded again in full, drop bytes of an interrupted resumable upload
+	os.Remove(fs.partialPartPath(uploadID, partNumber))
+
 	return part, nil
 }
 
@@ -680,19 +1164,10 @@ func (fs *FileSystem) UploadPartCopy(ctx context.Context, bucket, key, uploadID
 		return nil, err
 	}
 
-	// Check if upload exists
-	upload, err := fs.metadata.GetMultipartUpload(ctx, uploadID)
+	upload, err := fs.checkUpload(ctx, bucket, key, uploadID)
 	if err != nil {
 		return nil, err
 	}
-	if upload == nil {
-		return nil, ErrUploadNotFound
-	}
-
-	// Verify bucket and key match
-	if upload.Bucket != bucket || upload.Key != key {
-		return nil, ErrUploadNotFound
-	}
 
 	// Check if source bucket exists
 	exists, err := fs.metadata.BucketExists(ctx, srcBucket)
@@ -712,16 +1187,6 @@ func (fs *FileSystem) UploadPartCopy(ctx context.Context, bucket, key, uploadID
 		return nil, ErrObjectNotFound
 	}
 
-	// Open source object file
-	srcFile, err := os.Open(srcPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrObjectNotFound
-		}
-		return nil, fmt.Errorf("failed to open source object: %w", err)
-	}
-	defer srcFile.Close()
-
 	// Determine start and end positions
 	var start, end int64
 	if startByte != nil && endByte != nil {
@@ -736,17 +1201,21 @@ func (fs *FileSystem) UploadPartCopy(ctx context.Context, bucket, key, uploadID
 		end = srcObj.Size - 1
 	}
 
-	// Seek to start position
-	if _, err := srcFile.Seek(start, io.SeekStart); err != nil {
-		return nil, fmt.Errorf("failed to seek: %w", err)
+	// Open source object file at the start position
+	srcFile, err := fs.openObjectFile(ctx, srcBucket, srcKey, srcPath, start)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to open source object: %w", err)
 	}
+	defer srcFile.Close()
 
 	// Calculate copy size
 	copySize := end - start + 1
 
 	// Create part file
 	partsDir := filepath.Join(fs.dataDir, ".uploads", uploadID)
-	partPath := filepath.Join(partsDir, fmt.Sprintf("%d", partNumber))
 
 	// Write to temp file first
 	tmpFile, err := os.CreateTemp(partsDir, ".tmp-*")
@@ -759,9 +1228,13 @@ func (fs *FileSystem) UploadPartCopy(ctx context.Context, bucket, key, uploadID
 		os.Remove(tmpPath)
 	}()
 
-	// Copy data and calculate MD5
+	// Copy data and calculate MD5 and the checksum of the upload
 	hash := md5.New()
 	writer := io.MultiWriter(tmpFile, hash)
+	checksumHash := NewChecksumHash(upload.ChecksumAlgorithm)
+	if checksumHash != nil {
+		writer = io.MultiWriter(tmpFile, hash, checksumHash)
+	}
 
 	// Use LimitReader to copy only the specified range
 	limitedReader := io.LimitReader(srcFile, copySize)
@@ -775,11 +1248,9 @@ func (fs *FileSystem) UploadPartCopy(ctx context.Context, bucket, key, uploadID
 	}
 
 	// Calculate ETag
-	etag := hex.EncodeToString(hash.Sum(nil))
-
-	// Rename temp file to part file
-	if err := os.Rename(tmpPath, partPath); err != nil {
-		return nil, fmt.Errorf("failed to rename temp file: %w", err)
+	etag, err := fs.objectETag(ctx, bucket, hash.Sum(nil))
+	if err != nil {
+		return nil, err
 	}
 
 	part := &Part{
@@ -788,42 +1259,117 @@ func (fs *FileSystem) UploadPartCopy(ctx context.Context, bucket, key, uploadID
 		ETag:         etag,
 		LastModified: time.Now(),
 	}
+	if checksumHash != nil {
+		part.ChecksumAlgorithm = upload.ChecksumAlgorithm
+		part.Checksum = base64.StdEncoding.EncodeToString(checksumHash.Sum(nil))
+	}
 
-	// Save part metadata
-	if err := fs.metadata.PutPart(ctx, uploadID, part); err != nil {
-		os.Remove(partPath)
+	// Rename temp file to part file and save part metadata
+	if err := fs.storePart(ctx, uploadID, tmpPath, part); err != nil {
 		return nil, err
 	}
 
 	return part, nil
 }
 
+// uploadStateError returns the error of an operation that requires an
+// active upload, for an upload in another state.
+func uploadStateError(state UploadState) error {
+	if state == UploadStateCompleting {
+		return ErrUploadCompleting
+	}
+	return ErrUploadNotFound
+}
+
+// transitionUpload moves an upload from one state to another, failing if it
+// was moved out of state from concurrently.
+func (fs *FileSystem) transitionUpload(ctx context.Context, uploadID string, from, to UploadState) error {
+	defer fs.lockUpload(uploadID)()
+
+	ok, err := fs.metadata.TransitionUpload(ctx, uploadID, from, to)
+	if err != nil || ok {
+		return err
+	}
+	upload, err := fs.metadata.GetMultipartUpload(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	if upload == nil {
+		return ErrUploadNotFound
+	}
+	return uploadStateError(upload.State)
+}
+
+// storePart moves the file at path to the part file of a part and saves the
+// metadata of the part, if the upload is active.
+func (fs *FileSystem) storePart(ctx context.Context, uploadID, path string, part *Part) error {
+	defer fs.lockUpload(uploadID)()
+
+	upload, err := fs.metadata.GetMultipartUpload(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	if upload == nil {
+		return ErrUploadNotFound
+	}
+	if upload.State != UploadStateActive {
+		return uploadStateError(upload.State)
+	}
+
+	partPath := filepath.Join(fs.dataDir, ".uploads", uploadID, fmt.Sprintf("%d", part.PartNumber))
+	if err := os.Rename(path, partPath); err != nil {
+		return fmt.Errorf("failed to rename part file: %w", err)
+	}
+	if err := fs.metadata.PutPart(ctx, uploadID, part); err != nil {
+		// The file of an earlier upload of the part was replaced
+		os.Remove(partPath)
+		_ = fs.metadata.DeletePart(context.WithoutCancel(ctx), uploadID, part.PartNumber)
+		return err
+	}
+	return nil
+}
+
 // CompleteMultipartUpload completes a multipart upload.
-func (fs *FileSystem) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) (*Object, error) {
+func (fs *FileSystem) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) (obj *Object, err error) {
 	// Validate object key to prevent path traversal
 	objectPath, err := fs.validateObjectKey(bucket, key)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if upload exists
-	upload, err := fs.metadata.GetMultipartUpload(ctx, uploadID)
+	upload, err := fs.checkUpload(ctx, bucket, key, uploadID)
 	if err != nil {
 		return nil, err
 	}
-	if upload == nil {
-		return nil, ErrUploadNotFound
+
+	// Parts can no longer be uploaded or the upload aborted while it is
+	// assembled. A failed completion leaves the upload active for a retry.
+	if err := fs.transitionUpload(ctx, uploadID, UploadStateActive, UploadStateCompleting); err != nil {
+		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			fs.transitionUpload(context.WithoutCancel(ctx), uploadID, UploadStateCompleting, UploadStateActive)
+		}
+	}()
 
-	// Verify bucket and key match
-	if upload.Bucket != bucket || upload.Key != key {
-		return nil, ErrUploadNotFound
+	// Write-once buckets never overwrite objects (JOG extension)
+	if err := fs.checkWorm(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+
+	// Each part is listed once, in ascending order
+	for i := 1; i < len(parts); i++ {
+		if parts[i].PartNumber <= parts[i-1].PartNumber {
+			return nil, ErrInvalidPartOrder
+		}
 	}
 
 	// Verify all parts exist and ETags match
 	partsDir := filepath.Join(fs.dataDir, ".uploads", uploadID)
 	var totalSize int64
 	var partETags []string
+	layout := make([]ObjectPart, 0, len(parts))
 
 	for _, part := range parts {
 		storedPart, err := fs.metadata.GetPart(ctx, uploadID, part.PartNumber)
@@ -841,20 +1387,44 @@ func (fs *FileSystem) CompleteMultipartUpload(ctx context.Context, bucket, key,
 			return nil, ErrInvalidPart
 		}
 
+		// Checksums listed for the parts must match the uploaded ones
+		if part.Checksum != "" && (!strings.EqualFold(part.ChecksumAlgorithm, storedPart.ChecksumAlgorithm) || part.Checksum != storedPart.Checksum) {
+			return nil, ErrInvalidPart
+		}
+
+		layout = append(layout, ObjectPart{
+			PartNumber:        part.PartNumber,
+			Offset:            totalSize,
+			Size:              storedPart.Size,
+			ETag:              storedPart.ETag,
+			ChecksumAlgorithm: storedPart.ChecksumAlgorithm,
+			Checksum:          storedPart.Checksum,
+		})
 		totalSize += storedPart.Size
 		partETags = append(partETags, storedPart.ETag)
 	}
 
-	// Create final object directory
-	objectDir := filepath.Dir(objectPath)
-	if err := os.MkdirAll(objectDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create object directory: %w", err)
+	etags, err := fs.etagStrategy(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	// The encryption requested with CreateMultipartUpload, or else the
+	// default encryption of the bucket
+	sse, err := fs.metadata.GetUploadEncryption(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if sse == nil {
+		if sse, err = fs.objectEncryption(ctx, bucket); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create temp file for assembled object
-	tmpFile, err := os.CreateTemp(objectDir, ".tmp-*")
+	tmpFile, err := createTempFile(filepath.Dir(objectPath))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+		return nil, err
 	}
 	tmpPath := tmpFile.Name()
 	defer func() {
@@ -863,38 +1433,43 @@ func (fs *FileSystem) CompleteMultipartUpload(ctx context.Context, bucket, key,
 	}()
 
 	// Concatenate parts
+	encryptor, enc, err := fs.prepareEncryption(ctx, bucket, key, sse, tmpFile)
+	if err != nil {
+		return nil, err
+	}
 	for _, part := range parts {
 		partPath := filepath.Join(partsDir, fmt.Sprintf("%d", part.PartNumber))
 		partFile, err := os.Open(partPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open part file: %w", err)
 		}
-		_, err = io.Copy(tmpFile, partFile)
+		_, err = io.Copy(encryptor, partFile)
 		partFile.Close()
 		if err != nil {
 			return nil, fmt.Errorf("failed to copy part: %w", err)
 		}
 	}
 
+	if err := encryptor.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt object: %w", err)
+	}
 	if err := tmpFile.Close(); err != nil {
 		return nil, fmt.Errorf("failed to close temp file: %w", err)
 	}
 
+	unlock := fs.lockKey(ctx, bucket, key)
+	defer unlock()
+
 	// Rename temp file to final path
 	if err := os.Rename(tmpPath, objectPath); err != nil {
 		return nil, fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
 	// Calculate multipart ETag (MD5 of concatenated part MD5s + "-" + part count)
-	hash := md5.New()
-	for _, etag := range partETags {
-		data, _ := hex.DecodeString(etag)
-		hash.Write(data)
-	}
-	etag := fmt.Sprintf("%s-%d", hex.EncodeToString(hash.Sum(nil)), len(parts))
+	etag := etags.MultipartETag(partETags)
 
 	// Create object metadata
-	obj := &Object{
+	obj = &Object{
 		Key:          key,
 		Size:         totalSize,
 		LastModified: time.Now(),
@@ -903,12 +1478,21 @@ func (fs *FileSystem) CompleteMultipartUpload(ctx context.Context, bucket, key,
 		Metadata:     upload.Metadata,
 	}
 
-	if err := fs.metadata.PutObject(ctx, bucket, obj); err != nil {
+	if err := fs.putObjectAtRest(ctx, bucket, obj, enc, CompressionNone); err != nil {
 		os.Remove(objectPath)
 		return nil, err
 	}
+	if err := fs.metadata.PutObjectParts(ctx, bucket, key, layout); err != nil {
+		return nil, err
+	}
+	if err := fs.putUploadObjectLock(ctx, bucket, key, uploadID); err != nil {
+		return nil, err
+	}
+	if err := fs.putUploadTags(ctx, bucket, key, uploadID); err != nil {
+		return nil, err
+	}
 
-	// Clean up upload
+	// Clean up upload, including the parts that were uploaded but not listed
 	fs.metadata.DeleteMultipartUpload(ctx, uploadID)
 	os.RemoveAll(partsDir)
 
@@ -931,6 +1515,15 @@ func (fs *FileSystem) AbortMultipartUpload(ctx context.Context, bucket, key, upl
 		return ErrUploadNotFound
 	}
 
+	// Parts uploaded from now on are not stored, so none can be left behind
+	// once the parts directory is removed. An abort that failed half-way can
+	// be retried.
+	if upload.State != UploadStateAborted {
+		if err := fs.transitionUpload(ctx, uploadID, UploadStateActive, UploadStateAborted); err != nil {
+			return err
+		}
+	}
+
 	// Delete parts directory
 	partsDir := filepath.Join(fs.dataDir, ".uploads", uploadID)
 	os.RemoveAll(partsDir)
@@ -964,6 +1557,7 @@ func (fs *FileSystem) ListParts(ctx context.Context, input *ListPartsInput) (*Li
 		Parts:                parts,
 		IsTruncated:          isTruncated,
 		NextPartNumberMarker: nextMarker,
+		ChecksumAlgorithm:    upload.ChecksumAlgorithm,
 	}, nil
 }
 
@@ -978,6 +1572,10 @@ func (fs *FileSystem) ListMultipartUploads(ctx context.Context, input *ListMulti
 		return nil, ErrBucketNotFound
 	}
 
+	if input.Delimiter != "" {
+		return fs.listMultipartUploadsDelimited(ctx, input)
+	}
+
 	uploads, isTruncated, nextKeyMarker, nextUploadIDMarker, err := fs.metadata.ListMultipartUploadsByBucket(
 		ctx,
 		input.Bucket,
@@ -998,6 +1596,74 @@ func (fs *FileSystem) ListMultipartUploads(ctx context.Context, input *ListMulti
 	}, nil
 }
 
+// listMultipartUploadsDelimited lists multipart uploads, rolling up keys that
+// contain the delimiter after the prefix into common prefixes. Each common
+// prefix counts as one entry towards MaxUploads.
+func (fs *FileSystem) listMultipartUploadsDelimited(ctx context.Context, input *ListMultipartUploadsInput) (*ListMultipartUploadsOutput, error) {
+	maxUploads := input.MaxUploads
+	if maxUploads <= 0 {
+		maxUploads = 1000
+	}
+
+	// A key marker returned for a common prefix skips the whole prefix
+	var skipPrefix string
+	if strings.HasPrefix(input.KeyMarker, input.Prefix) && strings.HasSuffix(input.KeyMarker, input.Delimiter) {
+		skipPrefix = input.KeyMarker
+	}
+
+	output := &ListMultipartUploadsOutput{}
+	var lastPrefix string
+	count := int32(0)
+	keyMarker, uploadIDMarker := input.KeyMarker, input.UploadIdMarker
+	for {
+		uploads, isTruncated, nextKeyMarker, nextUploadIDMarker, err := fs.metadata.ListMultipartUploadsByBucket(
+			ctx, input.Bucket, input.Prefix, 1000, keyMarker, uploadIDMarker)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, upload := range uploads {
+			if skipPrefix != "" && strings.HasPrefix(upload.Key, skipPrefix) {
+				continue
+			}
+			var commonPrefix string
+			if idx := strings.Index(upload.Key[len(input.Prefix):], input.Delimiter); idx >= 0 {
+				commonPrefix = upload.Key[:len(input.Prefix)+idx+len(input.Delimiter)]
+				// Keys are sorted, so uploads of a common prefix are adjacent
+				if commonPrefix == lastPrefix {
+					continue
+				}
+			}
+
+			if count == maxUploads {
+				output.IsTruncated = true
+				return output, nil
+			}
+			count++
+
+			if commonPrefix != "" {
+				lastPrefix = commonPrefix
+				output.CommonPrefixes = append(output.CommonPrefixes, commonPrefix)
+				output.NextKeyMarker = commonPrefix
+				output.NextUploadIdMarker = ""
+			} else {
+				output.Uploads = append(output.Uploads, upload)
+				output.NextKeyMarker = upload.Key
+				output.NextUploadIdMarker = upload.UploadID
+			}
+		}
+
+		if !isTruncated {
+			break
+		}
+		keyMarker, uploadIDMarker = nextKeyMarker, nextUploadIDMarker
+	}
+
+	output.NextKeyMarker = ""
+	output.NextUploadIdMarker = ""
+	return output, nil
+}
+
 // DeleteObjects deletes multiple objects.
 func (fs *FileSystem) DeleteObjects(ctx context.Context, bucket string, keys []string) ([]DeletedObject, []DeleteError, error) {
 	// Check if bucket exists
@@ -1025,9 +1691,14 @@ func (fs *FileSystem) DeleteObjects(ctx context.Context, bucket string, keys []s
 			continue
 		}
 
-		// Delete object file
-		if err := os.Remove(objectPath); err != nil && !os.IsNotExist(err) {
-			// If there's an error other than "not exists", add to error list
+		if err := fs.checkWorm(ctx, bucket, key); errors.Is(err, ErrObjectImmutable) {
+			errs = append(errs, DeleteError{
+				Key:     key,
+				Code:    "AccessDenied",
+				Message: "The object is in a write-once bucket and cannot be deleted",
+			})
+			continue
+		} else if err != nil {
 			errs = append(errs, DeleteError{
 				Key:     key,
 				Code:    "InternalError",
@@ -1036,10 +1707,33 @@ func (fs *FileSystem) DeleteObjects(ctx context.Context, bucket string, keys []s
 			continue
 		}
 
-		// Delete object metadata
-		if err := fs.metadata.DeleteObject(ctx, bucket, key); err != nil {
+		// Writes of the key wait until it is deleted
+		err = func() error {
+			unlock := fs.lockKey(ctx, bucket, key)
+			defer unlock()
+
+			if trashed, err := fs.trashObject(ctx, bucket, key, objectPath); err != nil || trashed {
+				return err
+			}
+
+			// Delete object file
+			if err := os.Remove(objectPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+
 			// Even if metadata deletion fails, we still report success
 			// This matches S3 behavior for DeleteObjects
+			_ = fs.metadata.DeleteObject(ctx, bucket, key)
+			return nil
+		}()
+		fs.pruneEmptyDirs(bucket, objectPath)
+		if err != nil {
+			errs = append(errs, DeleteError{
+				Key:     key,
+				Code:    "InternalError",
+				Message: fmt.Sprintf("Failed to delete object: %v", err),
+			})
+			continue
 		}
 
 		// Report as deleted (even if it didn't exist, matching S3 behavior)
@@ -1248,8 +1942,13 @@ func (fs *FileSystem) DeleteBucketCors(ctx context.Context, bucket string) error
 	return fs.metadata.DeleteBucketCors(ctx, bucket)
 }
 
-// PutBucketVersioning sets the versioning status for a bucket.
+// PutBucketVersioning sets the versioning status for a bucket. The status
+// must be Enabled or Suspended, as buckets cannot become unversioned again.
 func (fs *FileSystem) PutBucketVersioning(ctx context.Context, bucket string, status VersioningStatus) error {
+	if status != VersioningStatusEnabled && status != VersioningStatusSuspended {
+		return ErrInvalidVersioningStatus
+	}
+
 	// Check if bucket exists
 	exists, err := fs.metadata.BucketExists(ctx, bucket)
 	if err != nil {
@@ -1281,6 +1980,59 @@ func (fs *FileSystem) GetBucketVersioning(ctx context.Context, bucket string) (V
 	return VersioningStatus(status), nil
 }
 
+// PutBucketMfaDelete sets the MFA delete status of a bucket.
+func (fs *FileSystem) PutBucketMfaDelete(ctx context.Context, bucket string, status MfaDeleteStatus) error {
+	if status != MfaDeleteStatusEnabled && status != MfaDeleteStatusDisabled {
+		return ErrInvalidVersioningStatus
+	}
+
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return fs.metadata.PutBucketMfaDelete(ctx, bucket, string(status))
+}
+
+// GetBucketMfaDelete returns the MFA delete status of a bucket, which is
+// empty if it was never set.
+func (fs *FileSystem) GetBucketMfaDelete(ctx context.Context, bucket string) (MfaDeleteStatus, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", ErrBucketNotFound
+	}
+
+	status, err := fs.metadata.GetBucketMfaDelete(ctx, bucket)
+	if err != nil {
+		return "", err
+	}
+
+	return MfaDeleteStatus(status), nil
+}
+
+// GetBucketVersioningConfiguration returns the versioning configuration of a
+// bucket.
+func (fs *FileSystem) GetBucketVersioningConfiguration(ctx context.Context, bucket string) (*VersioningConfiguration, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	return fs.metadata.GetBucketVersioningConfiguration(ctx, bucket)
+}
+
 // PutObjectVersioned stores a versioned object.
 func (fs *FileSystem) PutObjectVersioned(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, userMetadata map[string]string) (*Object, string, error) {
 	// Validate object key to prevent path traversal
@@ -1297,20 +2049,25 @@ func (fs *FileSystem) PutObjectVersioned(ctx context.Context, bucket, key string
 		return nil, "", ErrBucketNotFound
 	}
 
-	// Generate version ID
-	versionID := generateVersionID()
+	// Write-once buckets never replace the current version (JOG extension)
+	if err := fs.checkWorm(ctx, bucket, key); err != nil {
+		return nil, "", err
+	}
 
-	// Create object path with version
-	objectPath := filepath.Join(fs.dataDir, bucket, ".versions", key, versionID)
-	objectDir := filepath.Dir(objectPath)
-	if err := os.MkdirAll(objectDir, 0755); err != nil {
-		return nil, "", fmt.Errorf("failed to create object directory: %w", err)
+	sse, err := fs.objectEncryption(ctx, bucket)
+	if err != nil {
+		return nil, "", err
 	}
 
+	// Generate version ID
+	versionID := fs.newVersionID()
+
+	// Create object path with version
+	objectPath := fs.versionPath(bucket, key, versionID)
 	// Create temporary file
-	tmpFile, err := os.CreateTemp(objectDir, ".tmp-*")
+	tmpFile, err := createTempFile(filepath.Dir(objectPath))
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create temp file: %w", err)
+		return nil, "", err
 	}
 	tmpPath := tmpFile.Name()
 	defer func() {
@@ -1319,20 +2076,30 @@ func (fs *FileSystem) PutObjectVersioned(ctx context.Context, bucket, key string
 	}()
 
 	// Write data and calculate MD5
+	encryptor, enc, err := fs.prepareEncryption(ctx, bucket, key, sse, tmpFile)
+	if err != nil {
+		return nil, "", err
+	}
 	hash := md5.New()
-	writer := io.MultiWriter(tmpFile, hash)
+	writer := io.MultiWriter(encryptor, hash)
 
 	written, err := io.Copy(writer, body)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to write object: %w", err)
 	}
 
+	if err := encryptor.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt object: %w", err)
+	}
 	if err := tmpFile.Close(); err != nil {
 		return nil, "", fmt.Errorf("failed to close temp file: %w", err)
 	}
 
 	// Calculate ETag
-	etag := hex.EncodeToString(hash.Sum(nil))
+	etag, err := fs.objectETag(ctx, bucket, hash.Sum(nil))
+	if err != nil {
+		return nil, "", err
+	}
 
 	// Rename temp file to final path
 	if err := os.Rename(tmpPath, objectPath); err != nil {
@@ -1344,6 +2111,9 @@ func (fs *FileSystem) PutObjectVersioned(ctx context.Context, bucket, key string
 		contentType = "application/octet-stream"
 	}
 
+	// The version becomes the latest one and the current object at once
+	unlock := fs.lockKey(ctx, bucket, key)
+	defer unlock()
 	now := time.Now()
 
 	// Save version metadata
@@ -1361,6 +2131,13 @@ func (fs *FileSystem) PutObjectVersioned(ctx context.Context, bucket, key string
 		os.Remove(objectPath)
 		return nil, "", err
 	}
+	if enc != nil {
+		if err := fs.metadata.PutObjectEncryption(ctx, bucket, key, versionID, enc); err != nil {
+			fs.metadata.DeleteObjectVersion(ctx, bucket, key, versionID)
+			os.Remove(objectPath)
+			return nil, "", err
+		}
+	}
 
 	// Also update the regular objects table for compatibility
 	obj := &Object{
@@ -1372,27 +2149,29 @@ func (fs *FileSystem) PutObjectVersioned(ctx context.Context, bucket, key string
 		Metadata:     userMetadata,
 	}
 
-	if err := fs.metadata.PutObject(ctx, bucket, obj); err != nil {
-		return nil, "", err
-	}
-
-	// Copy to current object path
-	currentPath := filepath.Join(fs.dataDir, bucket, key)
+	// Replace the current object file with a copy of the version file, like
+	// restoreLatestVersion, before its metadata describes the new version
+	currentPath := filepath.Join(fs.dataDir, bucket, fs.keyPath(key))
 	currentDir := filepath.Dir(currentPath)
 	if err := os.MkdirAll(currentDir, 0755); err != nil {
 		return nil, "", fmt.Errorf("failed to create current object directory: %w", err)
 	}
-
-	// Copy version file to current
 	if err := copyFile(objectPath, currentPath); err != nil {
 		return nil, "", fmt.Errorf("failed to copy version to current: %w", err)
 	}
 
+	// The current object file is a copy of the version file, encrypted with
+	// the same data key
+	if err := fs.putObjectAtRest(ctx, bucket, obj, enc, CompressionNone); err != nil {
+		return nil, "", err
+	}
+
 	return obj, versionID, nil
 }
 
-// GetObjectVersioned retrieves a specific version of an object.
-func (fs *FileSystem) GetObjectVersioned(ctx context.Context, bucket, key, versionID string) (*ObjectData, error) {
+// HeadObjectVersioned retrieves the metadata of a specific version of an
+// object. A version that is a delete marker fails with a DeleteMarkerError.
+func (fs *FileSystem) HeadObjectVersioned(ctx context.Context, bucket, key, versionID string) (*Object, error) {
 	// Validate object key to prevent path traversal
 	if _, err := fs.validateObjectKey(bucket, key); err != nil {
 		return nil, err
@@ -1417,11 +2196,34 @@ func (fs *FileSystem) GetObjectVersioned(ctx context.Context, bucket, key, versi
 	}
 
 	if version.IsDeleteMarker {
-		return nil, ErrObjectNotFound
+		return nil, &DeleteMarkerError{VersionID: version.VersionID, LastModified: version.LastModified}
+	}
+
+	return &Object{
+		Key:          version.Key,
+		Size:         version.Size,
+		LastModified: version.LastModified,
+		ETag:         version.ETag,
+		ContentType:  version.ContentType,
+		Metadata:     version.Metadata,
+	}, nil
+}
+
+// GetObjectVersioned retrieves a specific version of an object. A version
+// that is a delete marker fails with a DeleteMarkerError.
+func (fs *FileSystem) GetObjectVersioned(ctx context.Context, bucket, key, versionID string) (*ObjectData, error) {
+	obj, err := fs.HeadObjectVersioned(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := fs.metadata.GetObjectEncryption(ctx, bucket, key, versionID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Open version file
-	objectPath := filepath.Join(fs.dataDir, bucket, ".versions", key, versionID)
+	objectPath := fs.versionPath(bucket, key, versionID)
 	file, err := os.Open(objectPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -1429,20 +2231,33 @@ func (fs *FileSystem) GetObjectVersioned(ctx context.Context, bucket, key, versi
 		}
 		return nil, fmt.Errorf("failed to open version file: %w", err)
 	}
+	var body io.ReadCloser = file
+	if enc != nil {
+		if body, err = fs.decryptObjectFile(ctx, file, enc, 0); err != nil {
+			return nil, err
+		}
+	}
 
 	return &ObjectData{
-		Object: Object{
-			Key:          version.Key,
-			Size:         version.Size,
-			LastModified: version.LastModified,
-			ETag:         version.ETag,
-			ContentType:  version.ContentType,
-			Metadata:     version.Metadata,
-		},
-		Body: file,
+		Object: *obj,
+		Body:   body,
 	}, nil
 }
 
+// objectNotFound returns the error for a key without a current object: a
+// DeleteMarkerError if the latest version of the key is a delete marker,
+// otherwise ErrObjectNotFound.
+func (fs *FileSystem) objectNotFound(ctx context.Context, bucket, key string) error {
+	version, err := fs.metadata.GetLatestObjectVersion(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	if version != nil && version.IsDeleteMarker {
+		return &DeleteMarkerError{VersionID: version.VersionID, LastModified: version.LastModified}
+	}
+	return ErrObjectNotFound
+}
+
 // DeleteObjectVersioned deletes an object, creating a delete marker if versioning is enabled.
 func (fs *FileSystem) DeleteObjectVersioned(ctx context.Context, bucket, key, versionID string) (string, bool, error) {
 	// Validate object key to prevent path traversal
@@ -1459,6 +2274,10 @@ func (fs *FileSystem) DeleteObjectVersioned(ctx context.Context, bucket, key, ve
 		return "", false, ErrBucketNotFound
 	}
 
+	// Deletes replace the current object like writes do
+	unlock := fs.lockKey(ctx, bucket, key)
+	defer unlock()
+
 	// If versionID is specified, delete that specific version
 	if versionID != "" {
 		// Get version to check if it's a delete marker
@@ -1469,23 +2288,43 @@ func (fs *FileSystem) DeleteObjectVersioned(ctx context.Context, bucket, key, ve
 		if version == nil {
 			return "", false, ErrObjectNotFound
 		}
+		if err := fs.checkWormVersion(ctx, bucket, version); err != nil {
+			return "", false, err
+		}
+		latest, err := fs.metadata.GetLatestObjectVersion(ctx, bucket, key)
+		if err != nil {
+			return "", false, err
+		}
 
 		// Delete version file
-		objectPath := filepath.Join(fs.dataDir, bucket, ".versions", key, versionID)
+		objectPath := fs.versionPath(bucket, key, versionID)
 		if err := os.Remove(objectPath); err != nil && !os.IsNotExist(err) {
 			return "", false, fmt.Errorf("failed to delete version file: %w", err)
 		}
+		fs.pruneEmptyDirs(bucket, objectPath)
 
 		// Delete version metadata
 		if err := fs.metadata.DeleteObjectVersion(ctx, bucket, key, versionID); err != nil {
 			return "", false, err
 		}
 
+		// Deleting the latest version makes the version before it current,
+		// which restores the object hidden by a delete marker
+		if latest != nil && latest.VersionID == versionID {
+			if err := fs.restoreLatestVersion(ctx, bucket, key, version); err != nil {
+				return "", false, err
+			}
+		}
+
 		return versionID, version.IsDeleteMarker, nil
 	}
 
-	// No versionID - create a delete marker
-	deleteMarkerID := generateVersionID()
+	// No versionID - create a delete marker, which would hide the current
+	// version of a write-once bucket
+	if err := fs.checkWorm(ctx, bucket, key); err != nil {
+		return "", false, err
+	}
+	deleteMarkerID := fs.newVersionID()
 	now := time.Now()
 
 	deleteMarker := &ObjectVersion{
@@ -1508,12 +2347,69 @@ func (fs *FileSystem) DeleteObjectVersioned(ctx context.Context, bucket, key, ve
 	}
 
 	// Remove current file
-	currentPath := filepath.Join(fs.dataDir, bucket, key)
+	currentPath := filepath.Join(fs.dataDir, bucket, fs.keyPath(key))
 	os.Remove(currentPath)
+	fs.pruneEmptyDirs(bucket, currentPath)
 
 	return deleteMarkerID, true, nil
 }
 
+// restoreLatestVersion makes the latest remaining version of key its current
+// object after the latest version, deleted, was removed: the current object
+// becomes a copy of that version, or is removed if the latest version is a
+// delete marker or no version is left. A current object written after the
+// deleted version, e.g. while versioning was suspended, is kept. The caller
+// holds the lock of the key.
+func (fs *FileSystem) restoreLatestVersion(ctx context.Context, bucket, key string, deleted *ObjectVersion) error {
+	current, err := fs.metadata.GetObject(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	if current != nil && current.LastModified.After(deleted.LastModified) {
+		return nil
+	}
+
+	currentPath := filepath.Join(fs.dataDir, bucket, fs.keyPath(key))
+	version, err := fs.metadata.GetLatestObjectVersion(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	if version == nil || version.IsDeleteMarker {
+		if current == nil {
+			return nil
+		}
+		if err := fs.metadata.DeleteObject(ctx, bucket, key); err != nil {
+			return err
+		}
+		os.Remove(currentPath)
+		fs.pruneEmptyDirs(bucket, currentPath)
+		return nil
+	}
+
+	enc, err := fs.metadata.GetObjectEncryption(ctx, bucket, key, version.VersionID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(currentPath), 0755); err != nil {
+		return fmt.Errorf("failed to create current object directory: %w", err)
+	}
+	if err := copyFile(fs.versionPath(bucket, key, version.VersionID), currentPath); err != nil {
+		return fmt.Errorf("failed to copy version to current: %w", err)
+	}
+
+	obj := &Object{
+		Key:          key,
+		Size:         version.Size,
+		LastModified: version.LastModified,
+		ETag:         version.ETag,
+		ContentType:  version.ContentType,
+		Metadata:     version.Metadata,
+	}
+	// The current object file is a copy of the version file, encrypted with
+	// the same data key
+	return fs.putObjectAtRest(ctx, bucket, obj, enc, CompressionNone)
+}
+
 // ListObjectVersions lists all versions of objects in a bucket.
 func (fs *FileSystem) ListObjectVersions(ctx context.Context, input *ListObjectVersionsInput) (*ListObjectVersionsOutput, error) {
 	// Check if bucket exists
@@ -1538,9 +2434,14 @@ func (fs *FileSystem) ListObjectVersions(ctx context.Context, input *ListObjectV
 		NextVersionIdMarker: nextVersionIDMarker,
 	}
 
-	// Find latest version for each key
+	// Find latest version for each key. Versions are listed newest first, so
+	// it is the first version of a key unless the page continues the
+	// versions of the key of the version marker.
 	latestVersions := make(map[string]string)
 	for _, v := range versions {
+		if v.Key == input.KeyMarker && input.VersionIdMarker != "" {
+			continue
+		}
 		if _, exists := latestVersions[v.Key]; !exists {
 			latestVersions[v.Key] = v.VersionID
 		}
@@ -1566,12 +2467,10 @@ func (fs *FileSystem) ListObjectVersions(ctx context.Context, input *ListObjectV
 	return output, nil
 }
 
-// generateVersionID generates a unique version ID.
-func generateVersionID() string {
-	return uuid.New().String()
-}
-
-// copyFile copies a file from src to dst.
+// copyFile copies a file from src to dst. dst is replaced by renaming a temp
+// file in its directory over it rather than rewritten, as it may share its
+// data with copies of the object and may be the live file of an object:
+// readers see the old or the new file in full, never a partial copy.
 func copyFile(src, dst string) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
@@ -1579,16 +2478,26 @@ func copyFile(src, dst string) error {
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(dst)
+	tmpFile, err := createTempFile(filepath.Dir(dst))
 	if err != nil {
 		return err
 	}
-	defer dstFile.Close()
+	tmpPath := tmpFile.Name()
+	defer func() {
+		tmpFile.Close()
+		os.Remove(tmpPath) // Clean up temp file if we don't rename it
+	}()
 
-	if _, err = io.Copy(dstFile, srcFile); err != nil {
+	if _, err = io.Copy(tmpFile, srcFile); err != nil {
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
 		return err
 	}
-	return dstFile.Sync()
+	return os.Rename(tmpPath, dst)
 }
 
 // DefaultOwnerID is the default owner ID for ACLs.
@@ -2146,6 +3055,36 @@ func (fs *FileSystem) GetObjectLegalHold(ctx context.Context, bucket, key string
 	}, nil
 }
 
+// putUploadObjectLock applies the retention and legal hold requested for a
+// multipart upload to the object it completed.
+func (fs *FileSystem) putUploadObjectLock(ctx context.Context, bucket, key, uploadID string) error {
+	lock, err := fs.metadata.GetUploadObjectLock(ctx, uploadID)
+	if err != nil || lock == nil {
+		return err
+	}
+	if lock.Retention != nil {
+		if err := fs.metadata.PutObjectRetention(ctx, bucket, key, string(lock.Retention.Mode), *lock.Retention.RetainUntilDate); err != nil {
+			return err
+		}
+	}
+	if lock.LegalHold != nil {
+		if err := fs.metadata.PutObjectLegalHold(ctx, bucket, key, string(lock.LegalHold.Status)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putUploadTags applies the tags requested for a multipart upload to the
+// object it completed.
+func (fs *FileSystem) putUploadTags(ctx context.Context, bucket, key, uploadID string) error {
+	tags, err := fs.metadata.GetUploadTags(ctx, uploadID)
+	if err != nil || len(tags) == 0 {
+		return err
+	}
+	return fs.metadata.PutObjectTags(ctx, bucket, key, tags)
+}
+
 // PutBucketPolicy stores the policy for a bucket.
 func (fs *FileSystem) PutBucketPolicy(ctx context.Context, bucket string, policy string) error {
 	// Check if bucket exists
@@ -2256,27 +3195,67 @@ func (fs *FileSystem) DeleteBucketWebsite(ctx context.Context, bucket string) er
 	return fs.metadata.DeleteBucketWebsite(ctx, bucket)
 }
 
+// PutBucketTiering is only supported by the tiered backend.
+func (fs *FileSystem) PutBucketTiering(ctx context.Context, bucket string, config *TieringConfiguration) error {
+	return ErrNotImplemented
+}
+
+// GetBucketTiering is only supported by the tiered backend.
+func (fs *FileSystem) GetBucketTiering(ctx context.Context, bucket string) (*TieringConfiguration, error) {
+	return nil, ErrNotImplemented
+}
+
+// DeleteBucketTiering is only supported by the tiered backend.
+func (fs *FileSystem) DeleteBucketTiering(ctx context.Context, bucket string) error {
+	return ErrNotImplemented
+}
+
 // Errors
 var (
-	ErrBucketNotFound                   = errors.New("bucket not found")
-	ErrBucketAlreadyExists              = errors.New("bucket already exists")
-	ErrBucketNotEmpty                   = errors.New("bucket not empty")
-	ErrObjectNotFound                   = errors.New("object not found")
-	ErrInvalidBucketName                = errors.New("invalid bucket name")
-	ErrInvalidKey                       = errors.New("invalid object key")
-	ErrUploadNotFound                   = errors.New("upload not found")
-	ErrInvalidPart                      = errors.New("invalid part")
-	ErrInvalidRange                     = errors.New("invalid range")
-	ErrNoSuchTagSet                     = errors.New("no such tag set")
-	ErrNoSuchCORSConfiguration          = errors.New("no such CORS configuration")
-	ErrNoSuchEncryptionConfiguration    = errors.New("no such encryption configuration")
-	ErrNoSuchLifecycleConfiguration     = errors.New("no such lifecycle configuration")
-	ErrObjectLockConfigurationNotFound  = errors.New("object lock configuration not found")
-	ErrNoSuchObjectLockConfiguration    = errors.New("no such object lock configuration")
-	ErrInvalidRequestObjectLock         = errors.New("bucket is not object lock enabled")
-	ErrMalformedXML                     = errors.New("malformed XML")
-	ErrNoSuchBucketPolicy               = errors.New("no such bucket policy")
-	ErrNoSuchWebsiteConfiguration       = errors.New("no such website configuration")
+	ErrBucketNotFound                      = errors.New("bucket not found")
+	ErrBucketAlreadyExists                 = errors.New("bucket already exists")
+	ErrBucketNotEmpty                      = errors.New("bucket not empty")
+	ErrObjectNotFound                      = errors.New("object not found")
+	ErrInvalidBucketName                   = errors.New("invalid bucket name")
+	ErrInvalidKey                          = errors.New("invalid object key")
+	ErrKeyTooLong                          = errors.New("object key too long")
+	ErrUploadNotFound                      = errors.New("upload not found")
+	ErrInvalidPart                         = errors.New("invalid part")
+	ErrInvalidPartOrder                    = errors.New("parts not in ascending order")
+	ErrInvalidVersioningStatus             = errors.New("invalid versioning status")
+	ErrUploadCompleting                    = errors.New("upload is being completed")
+	ErrInvalidRange                        = errors.New("invalid range")
+	ErrNoSuchTagSet                        = errors.New("no such tag set")
+	ErrNoSuchCORSConfiguration             = errors.New("no such CORS configuration")
+	ErrNoSuchEncryptionConfiguration       = errors.New("no such encryption configuration")
+	ErrNoSuchLifecycleConfiguration        = errors.New("no such lifecycle configuration")
+	ErrObjectLockConfigurationNotFound     = errors.New("object lock configuration not found")
+	ErrNoSuchObjectLockConfiguration       = errors.New("no such object lock configuration")
+	ErrInvalidRequestObjectLock            = errors.New("bucket is not object lock enabled")
+	ErrMalformedXML                        = errors.New("malformed XML")
+	ErrNoSuchBucketPolicy                  = errors.New("no such bucket policy")
+	ErrNoSuchWebsiteConfiguration          = errors.New("no such website configuration")
+	ErrNoSuchTieringConfiguration          = errors.New("no such tiering configuration")
+	ErrNoSuchCompressionConfiguration      = errors.New("no such compression configuration")
+	ErrNoSuchContentTypeConfiguration      = errors.New("no such content type configuration")
+	ErrNoSuchResponseHeaderConfiguration   = errors.New("no such response header configuration")
+	ErrObjectNotAppendable                 = errors.New("object not appendable")
+	ErrPositionNotEqualToLength            = errors.New("position not equal to length")
+	ErrPartOffsetMismatch                  = errors.New("part offset mismatch")
+	ErrKMSNotConfigured                    = errors.New("KMS not configured")
+	ErrBadDigest                           = errors.New("checksum does not match the data")
+	ErrChecksumAlgorithmMismatch           = errors.New("checksum algorithm does not match the upload")
+	ErrNoSuchTrashConfiguration            = errors.New("no such trash configuration")
+	ErrNoSuchTrashEntry                    = errors.New("no such trash entry")
+	ErrTrashVersionedBucket                = errors.New("trash mode is not available for buckets with versioning enabled")
+	ErrObjectExists                        = errors.New("object exists")
+	ErrNoSuchDefaultTagsConfiguration      = errors.New("no such default tags configuration")
+	ErrObjectImmutable                     = errors.New("object is immutable in write-once bucket")
+	ErrWormPermanent                       = errors.New("write-once mode cannot be disabled")
+	ErrNoSuchVersionRetentionConfiguration = errors.New("no such version retention configuration")
+	ErrInvalidVersionRetention             = errors.New("invalid number of noncurrent versions")
+	ErrNoSuchServiceAccount                = errors.New("no such service account")
+	ErrLinkEncrypted                       = errors.New("hard-linked objects cannot be encrypted")
 )
 
 // validateObjectKey validates the object key to prevent path traversal attacks.
@@ -2286,6 +3265,9 @@ func (fs *FileSystem) validateObjectKey(bucket, key string) (string, error) {
 	if key == "" {
 		return "", ErrInvalidKey
 	}
+	if len(key) > maxKeyLength {
+		return "", ErrKeyTooLong
+	}
 
 	// Reject keys containing path traversal sequences
 	// Check for ".." as a path component
@@ -2294,7 +3276,7 @@ func (fs *FileSystem) validateObjectKey(bucket, key string) (string, error) {
 	}
 
 	// Build the full path
-	objectPath := filepath.Join(fs.dataDir, bucket, key)
+	objectPath := filepath.Join(fs.dataDir, bucket, fs.keyPath(key))
 
 	// Clean the path to resolve any remaining traversal
 	cleanPath := filepath.Clean(objectPath)
@@ -2323,3 +3305,48 @@ func (e *BucketNotFoundError) Error() string {
 func (e *BucketNotFoundError) Is(target error) bool {
 	return target == ErrBucketNotFound
 }
+
+// DeleteMarkerError is an error for a request of a key whose latest version
+// is a delete marker, or of a version that is a delete marker. It includes
+// the version of the delete marker.
+type DeleteMarkerError struct {
+	VersionID    string
+	LastModified time.Time
+}
+
+func (e *DeleteMarkerError) Error() string {
+	return fmt.Sprintf("delete marker: %s", e.VersionID)
+}
+
+// Is implements errors.Is for DeleteMarkerError.
+func (e *DeleteMarkerError) Is(target error) bool {
+	return target == ErrObjectNotFound
+}
+
+// PositionNotEqualToLengthError is an error that includes the current object length.
+type PositionNotEqualToLengthError struct {
+	Length int64
+}
+
+func (e *PositionNotEqualToLengthError) Error() string {
+	return fmt.Sprintf("position not equal to length: %d", e.Length)
+}
+
+// Is implements errors.Is for PositionNotEqualToLengthError.
+func (e *PositionNotEqualToLengthError) Is(target error) bool {
+	return target == ErrPositionNotEqualToLength
+}
+
+// PartOffsetMismatchError is an error that includes the persisted offset of a part upload.
+type PartOffsetMismatchError struct {
+	Offset int64
+}
+
+func (e *PartOffsetMismatchError) Error() string {
+	return fmt.Sprintf("part offset mismatch: %d bytes persisted", e.Offset)
+}
+
+// Is implements errors.Is for PartOffsetMismatchError.
+func (e *PartOffsetMismatchError) Is(target error) bool {
+	return target == ErrPartOffsetMismatch
+}
@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gcsScope is the OAuth2 scope required for reading and writing objects.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// GCSBlobStore implements BlobStore using the Google Cloud Storage JSON API.
+type GCSBlobStore struct {
+	bucket   string
+	endpoint string
+	client   *http.Client
+	creds    *gcsServiceAccount
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// gcsServiceAccount holds the fields of a service account key file used for authentication.
+type gcsServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// NewGCSBlobStore creates a new Google Cloud Storage blob store.
+// credentialsFile is the path to a service account key file. If it is empty,
+// requests are sent without authentication (e.g. for a local GCS emulator).
+// If endpoint is empty, https://storage.googleapis.com is used.
+func NewGCSBlobStore(bucket, credentialsFile, endpoint string) (*GCSBlobStore, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("gcs bucket is required")
+	}
+
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+
+	store := &GCSBlobStore{
+		bucket:   bucket,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   newBlobHTTPClient(),
+	}
+
+	if credentialsFile != "" {
+		creds, err := loadGCSServiceAccount(credentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		store.creds = creds
+	}
+
+	return store, nil
+}
+
+// loadGCSServiceAccount reads and parses a service account key file.
+func loadGCSServiceAccount(path string) (*gcsServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gcs credentials file: %w", err)
+	}
+
+	var creds gcsServiceAccount
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse gcs credentials file: %w", err)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key in gcs credentials file")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key in gcs credentials file: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("gcs credentials private key is not an RSA key")
+	}
+	creds.key = rsaKey
+
+	return &creds, nil
+}
+
+// objectURL returns the JSON API URL of an object.
+func (g *GCSBlobStore) objectURL(name string) string {
+	return g.endpoint + "/storage/v1/b/" + url.PathEscape(g.bucket) + "/o/" + url.PathEscape(name)
+}
+
+// PutBlob uploads an object using a simple media upload.
+func (g *GCSBlobStore) PutBlob(ctx context.Context, name string, body io.Reader, size int64) error {
+	uploadURL := g.endpoint + "/upload/storage/v1/b/" + url.PathEscape(g.bucket) + "/o?uploadType=media&name=" + url.QueryEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := g.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetBlob downloads an object or a range of it.
+func (g *GCSBlobStore) GetBlob(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.objectURL(name)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	if rangeHeader := formatRange(offset, length); rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	resp, err := g.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// DeleteBlob deletes an object.
+func (g *GCSBlobStore) DeleteBlob(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, g.objectURL(name), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := g.do(req)
+	if err != nil {
+		if err == ErrBlobNotFound {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do authorizes and sends a request, converting error responses into errors.
+func (g *GCSBlobStore) do(req *http.Request) (*http.Response, error) {
+	if g.creds != nil {
+		token, err := g.accessToken(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("gcs %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// accessToken returns a cached OAuth2 access token, fetching a new one when it is about to expire.
+func (g *GCSBlobStore) accessToken(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.token != "" && time.Now().Add(time.Minute).Before(g.tokenExpiry) {
+		return g.token, nil
+	}
+
+	assertion, err := g.signJWT(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.creds.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch gcs access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("failed to fetch gcs access token: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("failed to decode gcs access token: %w", err)
+	}
+
+	g.token = tokenResp.AccessToken
+	g.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	return g.token, nil
+}
+
+// signJWT creates a signed JWT assertion for the OAuth2 JWT bearer grant.
+func (g *GCSBlobStore) signJWT(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   g.creds.ClientEmail,
+		"scope": gcsScope,
+		"aud":   g.creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, g.creds.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign gcs jwt: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
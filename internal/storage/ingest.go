@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// IngestOptions holds options for ingesting a directory tree as a bucket.
+type IngestOptions struct {
+	// Parallel is the number of files ingested concurrently.
+	Parallel int
+	// Link hard-links the files into the data directory instead of copying
+	// them. It requires the filesystem backend with the data directory on the
+	// same filesystem as the files.
+	Link bool
+}
+
+// IngestDirectory registers the files below localDir as the objects of
+// bucket, keyed by their slash-separated relative paths. The bucket is created
+// if it does not exist. Objects whose size and content MD5 already match the
+// file are skipped, so an interrupted ingest can be run again. Content types
+// are guessed from the file extension or, failing that, the file contents.
+// Files of buckets with default encryption are copied even with Link, as
+// hard links would store them in plaintext.
+func IngestDirectory(ctx context.Context, s Storage, localDir, bucket string, opts IngestOptions) (*SyncResult, error) {
+	var linker *FileSystem
+	if opts.Link {
+		// Backends embedding a FileSystem keep object data elsewhere
+		fsys, ok := s.(*FileSystem)
+		if !ok {
+			return nil, fmt.Errorf("hard links require the filesystem storage backend")
+		}
+		linker = fsys
+	}
+
+	if err := s.CreateBucket(ctx, bucket); err != nil && !errors.Is(err, ErrBucketAlreadyExists) {
+		return nil, err
+	}
+
+	var items []syncItem
+	err := filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		items = append(items, syncItem{Path: filepath.ToSlash(rel), Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return runSync(ctx, items, SyncOptions{Parallel: opts.Parallel}, func(ctx context.Context, item syncItem) (bool, error) {
+		localPath := filepath.Join(localDir, filepath.FromSlash(item.Path))
+
+		obj, err := s.HeadObject(ctx, bucket, item.Path)
+		if err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return false, err
+		}
+		if obj != nil && obj.Size == item.Size {
+			unchanged, err := ingestedUnchanged(ctx, s, bucket, obj, localPath)
+			if err != nil || unchanged {
+				return false, err
+			}
+		}
+
+		contentType, err := guessContentType(localPath)
+		if err != nil {
+			return false, err
+		}
+		if linker != nil {
+			_, err := linker.LinkObject(ctx, bucket, item.Path, localPath, contentType)
+			if err == nil {
+				return true, nil
+			}
+			if !errors.Is(err, ErrLinkEncrypted) {
+				return false, fmt.Errorf("failed to link %s: %w", item.Path, err)
+			}
+		}
+
+		f, err := os.Open(localPath)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		hash := md5.New()
+		obj, err = s.PutObject(ctx, bucket, item.Path, io.TeeReader(f, hash), item.Size, contentType, nil)
+		if err != nil {
+			return false, fmt.Errorf("failed to ingest %s: %w", item.Path, err)
+		}
+		if store, ok := s.(contentMD5Store); ok {
+			store.putContentMD5(ctx, bucket, item.Path, obj.ETag, hash.Sum(nil))
+		}
+		return true, nil
+	})
+}
+
+// contentMD5Store records the content MD5 of objects whose ETag is not their
+// MD5, such as objects with random ETags, so that ingesting again recognizes
+// unchanged files.
+type contentMD5Store interface {
+	contentMD5(ctx context.Context, bucket, key, etag string) (string, error)
+	putContentMD5(ctx context.Context, bucket, key, etag string, sum []byte)
+}
+
+// ingestedUnchanged reports whether an object has the content of the file at
+// localPath, by its ETag or else its recorded content MD5.
+func ingestedUnchanged(ctx context.Context, s Storage, bucket string, obj *Object, localPath string) (bool, error) {
+	sum, err := fileMD5(localPath)
+	if err != nil || sum == obj.ETag {
+		return err == nil, err
+	}
+	store, ok := s.(contentMD5Store)
+	if !ok {
+		return false, nil
+	}
+	recorded, err := store.contentMD5(ctx, bucket, obj.Key, obj.ETag)
+	return recorded == sum, err
+}
+
+// contentMD5 returns the hex content MD5 recorded for an object with the
+// given ETag, or "".
+func (fs *FileSystem) contentMD5(ctx context.Context, bucket, key, etag string) (string, error) {
+	return fs.metadata.GetContentMD5(ctx, bucket, key, etag)
+}
+
+// putContentMD5 records the content MD5 of an object if its ETag is not the
+// MD5. Failures are ignored, as the MD5 only saves copying unchanged files.
+func (fs *FileSystem) putContentMD5(ctx context.Context, bucket, key, etag string, sum []byte) {
+	if hexSum := hex.EncodeToString(sum); hexSum != etag {
+		_ = fs.metadata.PutContentMD5(ctx, bucket, key, etag, hexSum)
+	}
+}
+
+// guessContentType returns the content type of a file from its extension or,
+// for unknown extensions, its first 512 bytes.
+func guessContentType(localPath string) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(filepath.ToSlash(localPath))); contentType != "" {
+		return contentType, nil
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// LinkObject registers the file at localPath as an object by hard-linking it
+// into the data directory, so no data is copied. The object is stored
+// uncompressed and shares its contents with the file: overwriting or deleting
+// the object leaves the file alone, but the file must not be modified in
+// place afterwards. It returns ErrLinkEncrypted for objects that would be
+// encrypted.
+func (fs *FileSystem) LinkObject(ctx context.Context, bucket, key, localPath, contentType string) (*Object, error) {
+	objectPath, err := fs.validateObjectKey(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+	if err := fs.checkWorm(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+	// Linked files are stored as they are, in plaintext
+	sse, err := fs.objectEncryption(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if sse != nil {
+		return nil, ErrLinkEncrypted
+	}
+
+	objectDir := filepath.Dir(objectPath)
+	if err := os.MkdirAll(objectDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	// Link under a temporary name first, so the ETag is computed from the
+	// linked file and the object appears atomically
+	tmpPath := filepath.Join(objectDir, ".tmp-"+uuid.NewString())
+	if err := os.Link(localPath, tmpPath); err != nil {
+		return nil, fmt.Errorf("failed to link file: %w", err)
+	}
+	defer os.Remove(tmpPath) // Clean up the link if we don't rename it
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	hash := md5.New()
+	size, err := io.Copy(hash, f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	sum := hash.Sum(nil)
+	etag, err := fs.objectETag(ctx, bucket, sum)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := fs.lockKey(ctx, bucket, key)
+	defer unlock()
+	if err := os.Rename(tmpPath, objectPath); err != nil {
+		return nil, fmt.Errorf("failed to rename linked file: %w", err)
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	info, err := os.Stat(objectPath)
+	if err != nil {
+		return nil, err
+	}
+	obj := &Object{
+		Key:          key,
+		Size:         size,
+		LastModified: info.ModTime(),
+		ETag:         etag,
+		ContentType:  contentType,
+	}
+	if err := fs.metadata.PutObject(ctx, bucket, obj); err != nil {
+		return nil, err
+	}
+	fs.putContentMD5(ctx, bucket, key, etag, sum)
+	return obj, nil
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIngestDirectory(t *testing.T) {
+	ctx := context.Background()
+	local := t.TempDir()
+	files := map[string]string{
+		"index.html":      "<html><body>hi</body></html>",
+		"docs/readme":     "plain text",
+		"docs/data/x.bin": "\x00\x01\x02",
+	}
+	for name, content := range files {
+		p := filepath.Join(local, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, link := range []bool{false, true} {
+		fs := newTestFileSystem(t)
+		opts := IngestOptions{Parallel: 2, Link: link}
+		result, err := IngestDirectory(ctx, fs, local, "bucket", opts)
+		if err != nil {
+			t.Fatalf("IngestDirectory(link=%v): %v", link, err)
+		}
+		if result.Copied != 3 || result.Skipped != 0 {
+			t.Errorf("link=%v: copied %d, skipped %d; want 3, 0", link, result.Copied, result.Skipped)
+		}
+		for name, content := range files {
+			if got := readObject(t, fs, "bucket", name); got != content {
+				t.Errorf("link=%v: %s = %q, want %q", link, name, got, content)
+			}
+		}
+
+		obj, err := fs.HeadObject(ctx, "bucket", "docs/readme")
+		if err != nil {
+			t.Fatalf("HeadObject: %v", err)
+		}
+		if obj.ContentType != "text/plain; charset=utf-8" || obj.ETag != "31bc5c2b8fd4f20cd747347b7504a385" {
+			t.Errorf("link=%v: docs/readme = %q, %q", link, obj.ContentType, obj.ETag)
+		}
+		if obj, _ := fs.HeadObject(ctx, "bucket", "index.html"); obj == nil || obj.ContentType != "text/html; charset=utf-8" {
+			t.Errorf("link=%v: index.html = %+v", link, obj)
+		}
+
+		// Linked objects share the file
+		src, _ := os.Stat(filepath.Join(local, "index.html"))
+		dst, _ := os.Stat(filepath.Join(fs.dataDir, "bucket", "index.html"))
+		if os.SameFile(src, dst) != link {
+			t.Errorf("link=%v: object shares the file: %v", link, os.SameFile(src, dst))
+		}
+
+		// Ingesting again skips unchanged files
+		result, err = IngestDirectory(ctx, fs, local, "bucket", opts)
+		if err != nil {
+			t.Fatalf("IngestDirectory again: %v", err)
+		}
+		if result.Copied != 0 || result.Skipped != 3 {
+			t.Errorf("link=%v: second ingest copied %d, skipped %d; want 0, 3", link, result.Copied, result.Skipped)
+		}
+	}
+
+	// Overwriting a linked object leaves the file alone
+	fs := newTestFileSystem(t)
+	if _, err := IngestDirectory(ctx, fs, local, "bucket", IngestOptions{Link: true}); err != nil {
+		t.Fatalf("IngestDirectory: %v", err)
+	}
+	if _, err := fs.PutObject(ctx, "bucket", "docs/readme", strings.NewReader("changed"), 7, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(local, "docs", "readme")); string(data) != "plain text" {
+		t.Errorf("file changed to %q", data)
+	}
+}
+
+func TestIngestDirectoryEncryptedBucket(t *testing.T) {
+	ctx := context.Background()
+	local := t.TempDir()
+	if err := os.WriteFile(filepath.Join(local, "secret.txt"), []byte("secret data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := newTestFileSystem(t)
+	fs.SetKMS(newTestKMS(t), "key")
+	fs.SetEncryptedETagMode(ETagModeRandom)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := fs.PutBucketEncryption(ctx, "bucket", &ServerSideEncryptionConfiguration{Rules: []ServerSideEncryptionRule{{
+		ApplyServerSideEncryptionByDefault: &ServerSideEncryptionByDefault{SSEAlgorithm: SSEAlgorithmAES256},
+	}}}); err != nil {
+		t.Fatalf("PutBucketEncryption: %v", err)
+	}
+
+	// Files are copied and encrypted instead of linked in plaintext
+	opts := IngestOptions{Link: true}
+	result, err := IngestDirectory(ctx, fs, local, "bucket", opts)
+	if err != nil {
+		t.Fatalf("IngestDirectory: %v", err)
+	}
+	if result.Copied != 1 {
+		t.Errorf("copied %d, want 1", result.Copied)
+	}
+	src, _ := os.Stat(filepath.Join(local, "secret.txt"))
+	dst, _ := os.Stat(filepath.Join(fs.dataDir, "bucket", "secret.txt"))
+	if os.SameFile(src, dst) {
+		t.Error("object of an encrypted bucket is linked to the file")
+	}
+	if enc, err := fs.GetObjectEncryption(ctx, "bucket", "secret.txt", ""); err != nil || enc == nil {
+		t.Errorf("GetObjectEncryption = %+v, %v", enc, err)
+	}
+	if got := readObject(t, fs, "bucket", "secret.txt"); got != "secret data" {
+		t.Errorf("secret.txt = %q", got)
+	}
+
+	// Unchanged files are recognized by their recorded MD5, as their ETags
+	// are random
+	result, err = IngestDirectory(ctx, fs, local, "bucket", opts)
+	if err != nil {
+		t.Fatalf("IngestDirectory again: %v", err)
+	}
+	if result.Copied != 0 || result.Skipped != 1 {
+		t.Errorf("second ingest copied %d, skipped %d; want 0, 1", result.Copied, result.Skipped)
+	}
+}
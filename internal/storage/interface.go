@@ -11,6 +11,15 @@ import (
 type Bucket struct {
 	Name         string
 	CreationDate time.Time
+	// Owner is the ID of the caller that created the bucket, or "" if unowned.
+	Owner string
+	// Region is the location constraint the bucket was created with, or ""
+	// if it is in the region of the server.
+	Region string
+	// Accessible reports whether the caller that read the bucket may access
+	// it: the caller owns it, it is unowned or its ACL grants the caller
+	// access.
+	Accessible bool
 }
 
 // Object represents a stored object.
@@ -37,6 +46,10 @@ type ListObjectsInput struct {
 	MaxKeys           int32
 	ContinuationToken string
 	StartAfter        string
+	// TagKey, when set, lists only objects tagged with this key, and with
+	// the value TagValue unless it is nil (JOG extension).
+	TagKey   string
+	TagValue *string
 }
 
 // ListObjectsOutput holds the result of listing objects.
@@ -45,7 +58,62 @@ type ListObjectsOutput struct {
 	CommonPrefixes        []string
 	IsTruncated           bool
 	NextContinuationToken string
-	KeyCount              int32
+	// KeyCount is the number of objects and common prefixes in the page.
+	KeyCount int32
+}
+
+// SearchObjectsInput holds parameters for searching objects by metadata (JOG
+// extension).
+type SearchObjectsInput struct {
+	Bucket string
+	Prefix string
+	// Metadata holds user metadata, by lowercase name without the
+	// x-amz-meta- prefix, that objects must all have with the given values.
+	Metadata map[string]string
+	// ContentType, when set, matches objects of this content type, or of
+	// any of its subtypes when it ends with "/*", such as "image/*".
+	ContentType string
+	MaxKeys     int32
+	StartAfter  string
+}
+
+// SearchObjectsOutput holds the result of searching objects by metadata.
+type SearchObjectsOutput struct {
+	Objects     []Object
+	IsTruncated bool
+	// NextStartAfter is the key the next page starts after.
+	NextStartAfter string
+}
+
+// BucketUsage is the storage used by a bucket (JOG extension).
+type BucketUsage struct {
+	// Objects and Bytes count the current objects and their logical size.
+	Objects int64
+	Bytes   int64
+	// VersionBytes is the size of the versions kept by versioning,
+	// including current versions and excluding delete markers.
+	VersionBytes int64
+	// MultipartUploads and MultipartBytes count the multipart uploads in
+	// progress and the size of their uploaded parts.
+	MultipartUploads int64
+	MultipartBytes   int64
+}
+
+// UsageRecord is the usage of a bucket on a day, recorded by
+// RecordUsageHistory (JOG extension).
+type UsageRecord struct {
+	Bucket string
+	// Date is the UTC day of the record, formatted as YYYY-MM-DD.
+	Date           string
+	Objects        int64
+	Bytes          int64
+	VersionBytes   int64
+	MultipartBytes int64
+	// PhysicalBytes is the size of the files of the bucket in the data
+	// directory, after compression and including versions, as opposed to
+	// the logical size of its objects.
+	PhysicalBytes int64
+	RecordedAt    time.Time
 }
 
 // MultipartUpload represents a multipart upload in progress.
@@ -56,14 +124,47 @@ type MultipartUpload struct {
 	ContentType string
 	Metadata    map[string]string
 	Initiated   time.Time
+	// ChecksumAlgorithm is the checksum algorithm of the parts, if one was
+	// requested when the upload was created.
+	ChecksumAlgorithm string
+	// State is the state of the upload.
+	State UploadState
 }
 
+// UploadState is the state of a multipart upload. Parts are only stored for
+// active uploads. Completing or aborting an upload moves it out of the
+// active state first, so that its parts cannot change while they are
+// assembled or removed.
+type UploadState string
+
+const (
+	UploadStateActive     UploadState = "active"
+	UploadStateCompleting UploadState = "completing"
+	UploadStateAborted    UploadState = "aborted"
+)
+
 // Part represents an uploaded part.
 type Part struct {
 	PartNumber   int32
 	Size         int64
 	ETag         string
 	LastModified time.Time
+	// ChecksumAlgorithm and Checksum are set when the part was uploaded with
+	// a checksum, or to an upload created with a checksum algorithm.
+	ChecksumAlgorithm string
+	Checksum          string
+}
+
+// ObjectPart describes where a part of a completed multipart upload is stored
+// within the assembled object.
+type ObjectPart struct {
+	PartNumber int32
+	Offset     int64
+	Size       int64
+	ETag       string
+	// ChecksumAlgorithm and Checksum are set when the part was uploaded with a checksum.
+	ChecksumAlgorithm string
+	Checksum          string
 }
 
 // ListPartsInput holds parameters for listing parts.
@@ -80,12 +181,15 @@ type ListPartsOutput struct {
 	Parts                []Part
 	IsTruncated          bool
 	NextPartNumberMarker int32
+	// ChecksumAlgorithm is the checksum algorithm of the upload, if any.
+	ChecksumAlgorithm string
 }
 
 // ListMultipartUploadsInput holds parameters for listing multipart uploads.
 type ListMultipartUploadsInput struct {
 	Bucket         string
 	Prefix         string
+	Delimiter      string
 	MaxUploads     int32
 	KeyMarker      string
 	UploadIdMarker string
@@ -94,6 +198,7 @@ type ListMultipartUploadsInput struct {
 // ListMultipartUploadsOutput holds the result of listing multipart uploads.
 type ListMultipartUploadsOutput struct {
 	Uploads            []MultipartUpload
+	CommonPrefixes     []string
 	IsTruncated        bool
 	NextKeyMarker      string
 	NextUploadIdMarker string
@@ -135,11 +240,30 @@ type CORSConfiguration struct {
 type VersioningStatus string
 
 const (
-	VersioningStatusDisabled  VersioningStatus = ""
-	VersioningStatusEnabled   VersioningStatus = "Enabled"
-	VersioningStatusSuspended VersioningStatus = "Suspended"
+	// VersioningStatusUnversioned is the status of buckets whose versioning
+	// was never configured. Buckets cannot return to it once configured.
+	VersioningStatusUnversioned VersioningStatus = ""
+	VersioningStatusEnabled     VersioningStatus = "Enabled"
+	VersioningStatusSuspended   VersioningStatus = "Suspended"
+)
+
+// MfaDeleteStatus represents the MFA delete setting of a versioned bucket.
+// With MFA delete enabled, changing the versioning of the bucket and
+// permanently deleting versions require MFA authentication.
+type MfaDeleteStatus string
+
+const (
+	MfaDeleteStatusEnabled  MfaDeleteStatus = "Enabled"
+	MfaDeleteStatusDisabled MfaDeleteStatus = "Disabled"
 )
 
+// VersioningConfiguration is the versioning configuration of a bucket. The
+// statuses are empty if they were never configured.
+type VersioningConfiguration struct {
+	Status    VersioningStatus
+	MfaDelete MfaDeleteStatus
+}
+
 // ObjectVersion represents a version of an object.
 type ObjectVersion struct {
 	Key            string
@@ -258,6 +382,16 @@ type LifecycleRuleFilter struct {
 	Tag                   *Tag
 	ObjectSizeGreaterThan *int64
 	ObjectSizeLessThan    *int64
+	And                   *LifecycleRuleAndOperator
+}
+
+// LifecycleRuleAndOperator combines the conditions of a lifecycle rule
+// filter, which objects must all meet.
+type LifecycleRuleAndOperator struct {
+	Prefix                string
+	Tags                  []Tag
+	ObjectSizeGreaterThan *int64
+	ObjectSizeLessThan    *int64
 }
 
 // LifecycleExpiration represents expiration settings.
@@ -354,6 +488,13 @@ type ObjectLegalHold struct {
 	Status ObjectLegalHoldStatus
 }
 
+// ObjectLock holds the retention and legal hold requested with a write by the
+// x-amz-object-lock-* headers. Either may be nil.
+type ObjectLock struct {
+	Retention *ObjectRetention
+	LegalHold *ObjectLegalHold
+}
+
 // WebsiteConfiguration represents a bucket website configuration.
 type WebsiteConfiguration struct {
 	IndexDocument         *IndexDocument
@@ -399,6 +540,16 @@ type Redirect struct {
 	ReplaceKeyWith       string
 }
 
+// TieringConfiguration represents a bucket placement policy for tiered storage (JOG extension).
+type TieringConfiguration struct {
+	// SizeThreshold is the object size in bytes at or above which objects are stored remotely.
+	// Zero disables size-based placement.
+	SizeThreshold int64
+	// ColdAfterDays is the number of days after the last modification at which
+	// local objects are moved to remote storage. Zero disables age-based placement.
+	ColdAfterDays int32
+}
+
 // Storage defines the interface for storage backends.
 type Storage interface {
 	// Bucket operations
@@ -412,15 +563,22 @@ type Storage interface {
 	GetObject(ctx context.Context, bucket, key string) (*ObjectData, error)
 	GetObjectRange(ctx context.Context, bucket, key string, start, end int64) (*ObjectData, error)
 	HeadObject(ctx context.Context, bucket, key string) (*Object, error)
+	GetObjectParts(ctx context.Context, bucket, key string) ([]ObjectPart, error)
 	DeleteObject(ctx context.Context, bucket, key string) error
 	DeleteObjects(ctx context.Context, bucket string, keys []string) ([]DeletedObject, []DeleteError, error)
 	CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, metadata map[string]string) (*Object, error)
+	AppendObject(ctx context.Context, bucket, key string, position int64, body io.Reader, size int64, contentType string, metadata map[string]string) (*Object, error)
 	ListObjectsV2(ctx context.Context, input *ListObjectsInput) (*ListObjectsOutput, error)
+	// Metadata search (JOG extension)
+	SearchObjects(ctx context.Context, input *SearchObjectsInput) (*SearchObjectsOutput, error)
 
 	// Multipart upload operations
 	CreateMultipartUpload(ctx context.Context, bucket, key, contentType string, metadata map[string]string) (*MultipartUpload, error)
 	UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.Reader, size int64) (*Part, error)
 	UploadPartCopy(ctx context.Context, bucket, key, uploadID string, partNumber int32, srcBucket, srcKey string, startByte, endByte *int64) (*Part, error)
+	// Resumable part uploads (JOG extension)
+	UploadPartRange(ctx context.Context, bucket, key, uploadID string, partNumber int32, offset int64, body io.Reader, size, totalSize int64) (*PartUploadProgress, error)
+	GetPartUploadOffset(ctx context.Context, bucket, key, uploadID string, partNumber int32) (int64, error)
 	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) (*Object, error)
 	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
 	ListParts(ctx context.Context, input *ListPartsInput) (*ListPartsOutput, error)
@@ -442,8 +600,12 @@ type Storage interface {
 	// Versioning operations
 	PutBucketVersioning(ctx context.Context, bucket string, status VersioningStatus) error
 	GetBucketVersioning(ctx context.Context, bucket string) (VersioningStatus, error)
+	PutBucketMfaDelete(ctx context.Context, bucket string, status MfaDeleteStatus) error
+	GetBucketMfaDelete(ctx context.Context, bucket string) (MfaDeleteStatus, error)
+	GetBucketVersioningConfiguration(ctx context.Context, bucket string) (*VersioningConfiguration, error)
 	PutObjectVersioned(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, metadata map[string]string) (*Object, string, error)
 	GetObjectVersioned(ctx context.Context, bucket, key, versionID string) (*ObjectData, error)
+	HeadObjectVersioned(ctx context.Context, bucket, key, versionID string) (*Object, error)
 	DeleteObjectVersioned(ctx context.Context, bucket, key, versionID string) (string, bool, error)
 	ListObjectVersions(ctx context.Context, input *ListObjectVersionsInput) (*ListObjectVersionsOutput, error)
 
@@ -457,6 +619,7 @@ type Storage interface {
 	PutBucketEncryption(ctx context.Context, bucket string, config *ServerSideEncryptionConfiguration) error
 	GetBucketEncryption(ctx context.Context, bucket string) (*ServerSideEncryptionConfiguration, error)
 	DeleteBucketEncryption(ctx context.Context, bucket string) error
+	GetObjectEncryption(ctx context.Context, bucket, key, versionID string) (*ObjectEncryption, error)
 
 	// Lifecycle operations
 	PutBucketLifecycleConfiguration(ctx context.Context, bucket string, config *LifecycleConfiguration) error
@@ -483,6 +646,66 @@ type Storage interface {
 	GetBucketWebsite(ctx context.Context, bucket string) (*WebsiteConfiguration, error)
 	DeleteBucketWebsite(ctx context.Context, bucket string) error
 
+	// Tiering operations (JOG extension)
+	PutBucketTiering(ctx context.Context, bucket string, config *TieringConfiguration) error
+	GetBucketTiering(ctx context.Context, bucket string) (*TieringConfiguration, error)
+	DeleteBucketTiering(ctx context.Context, bucket string) error
+
+	// Compression operations (JOG extension)
+	PutBucketCompression(ctx context.Context, bucket string, config *CompressionConfiguration) error
+	GetBucketCompression(ctx context.Context, bucket string) (*CompressionConfiguration, error)
+	DeleteBucketCompression(ctx context.Context, bucket string) error
+
+	// Unchanged overwrite skipping operations (JOG extension)
+	PutBucketSkipUnchanged(ctx context.Context, bucket string, enabled bool) error
+	GetBucketSkipUnchanged(ctx context.Context, bucket string) (bool, error)
+
+	// Write-once operations (JOG extension)
+	PutBucketWorm(ctx context.Context, bucket string, enabled bool) error
+	GetBucketWorm(ctx context.Context, bucket string) (bool, error)
+
+	// Content type inference operations (JOG extension)
+	PutBucketContentTypes(ctx context.Context, bucket string, config *ContentTypeConfiguration) error
+	GetBucketContentTypes(ctx context.Context, bucket string) (*ContentTypeConfiguration, error)
+	DeleteBucketContentTypes(ctx context.Context, bucket string) error
+
+	// Default object tag operations (JOG extension)
+	PutBucketDefaultTags(ctx context.Context, bucket string, tags []Tag) error
+	GetBucketDefaultTags(ctx context.Context, bucket string) ([]Tag, error)
+	DeleteBucketDefaultTags(ctx context.Context, bucket string) error
+
+	// Response header policy operations (JOG extension)
+	PutBucketResponseHeaders(ctx context.Context, bucket string, config *ResponseHeaderConfiguration) error
+	GetBucketResponseHeaders(ctx context.Context, bucket string) (*ResponseHeaderConfiguration, error)
+	DeleteBucketResponseHeaders(ctx context.Context, bucket string) error
+
+	// Usage operations (JOG extension)
+	GetBucketUsage(ctx context.Context, bucket string) (*BucketUsage, error)
+	GetBucketUsageHistory(ctx context.Context, bucket string, days int) ([]UsageRecord, error)
+	RecordUsageHistory(ctx context.Context, now time.Time, keepDays int) ([]UsageRecord, error)
+
+	// Trash operations (JOG extension)
+	PutBucketTrash(ctx context.Context, bucket string, config *TrashConfiguration) error
+	GetBucketTrash(ctx context.Context, bucket string) (*TrashConfiguration, error)
+	DeleteBucketTrash(ctx context.Context, bucket string) error
+	ListTrash(ctx context.Context, bucket, prefix string) ([]TrashEntry, error)
+	RestoreTrash(ctx context.Context, bucket, id string) (*Object, error)
+	PurgeTrash(ctx context.Context, now time.Time) (int, error)
+
+	// Version retention operations (JOG extension)
+	PutBucketVersionRetention(ctx context.Context, bucket string, config *VersionRetentionConfiguration) error
+	GetBucketVersionRetention(ctx context.Context, bucket string) (*VersionRetentionConfiguration, error)
+	DeleteBucketVersionRetention(ctx context.Context, bucket string) error
+	PruneNoncurrentVersions(ctx context.Context) (int, error)
+
+	// Integrity operations (JOG extension)
+	VerifyObject(ctx context.Context, bucket, key string) (*ObjectVerification, error)
+	ListObjectsToVerify(ctx context.Context, verifiedBefore time.Time, limit int) ([]ObjectLocation, error)
+
+	// Housekeeping operations (JOG extension)
+	PruneEmptyDirs(ctx context.Context) (int, error)
+	RemoveStaleTempFiles(ctx context.Context, olderThan time.Duration, maxFiles int) (TempFilesResult, error)
+
 	// Close releases storage resources.
 	Close() error
 }
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+// keyLockStripes is the number of locks that the writes of object keys are
+// spread over, so that memory use does not grow with the number of keys.
+const keyLockStripes = 256
+
+// keyLockContextKey is the context key of the key lock held by a write.
+type keyLockContextKey struct{}
+
+// lockKey serializes writes to an object key and returns the unlock
+// function. Writes hold the lock while they replace the file and the
+// metadata of the object, so that concurrent writes of the same key cannot
+// interleave and leave the metadata of one write with the data of another:
+// the last write to commit wins. The lock is not taken again if ctx comes
+// from holdKey for the same lock.
+func (fs *FileSystem) lockKey(ctx context.Context, bucket, key string) func() {
+	mu := fs.keyLock(bucket, key)
+	if held, _ := ctx.Value(keyLockContextKey{}).(*sync.Mutex); held == mu {
+		return func() {}
+	}
+	mu.Lock()
+	return mu.Unlock
+}
+
+// holdKey takes the lock of an object key like lockKey and returns a context
+// for the writes made under it. Backends that wrap the writes of FileSystem
+// use it to keep writing, e.g. to a BlobStore, after FileSystem is done.
+func (fs *FileSystem) holdKey(ctx context.Context, bucket, key string) (context.Context, func()) {
+	unlock := fs.lockKey(ctx, bucket, key)
+	return context.WithValue(ctx, keyLockContextKey{}, fs.keyLock(bucket, key)), unlock
+}
+
+// keyLock returns the lock of the stripe of an object key.
+func (fs *FileSystem) keyLock(bucket, key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(bucket))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return &fs.keyLocks[h.Sum32()%keyLockStripes]
+}
+
+// lockUpload serializes the changes of the state of a multipart upload with
+// the storing of its parts and returns the unlock function. A part file is
+// only replaced while the upload is active, so that a part uploaded
+// concurrently with the completion or abort of the upload cannot change
+// or remove the parts that the completion reads.
+func (fs *FileSystem) lockUpload(uploadID string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(uploadID))
+	mu := &fs.uploadLocks[h.Sum32()%keyLockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
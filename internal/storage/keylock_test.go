@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentPutObject(t *testing.T) {
+	for name, newStorage := range map[string]func(t *testing.T) Storage{
+		"FileSystem":  func(t *testing.T) Storage { return newTestFileSystem(t) },
+		"Passthrough": func(t *testing.T) Storage { return newTestPassthrough(t, newMemoryBlobStore()) },
+		// Writes of the key alternate between the tiers
+		"Tiered": func(t *testing.T) Storage {
+			return newTestTiered(t, newMemoryBlobStore(), TieringConfiguration{SizeThreshold: 9500})
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newStorage(t)
+			if err := s.CreateBucket(ctx, "bucket"); err != nil {
+				t.Fatalf("CreateBucket: %v", err)
+			}
+
+			// Every write commits its data together with its metadata, so
+			// the object read afterwards is one of the writes as a whole
+			var wg sync.WaitGroup
+			for i := range 16 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					data := strings.Repeat(fmt.Sprintf("writer %d ", i), 1000+i)
+					if _, err := s.PutObject(ctx, "bucket", "key", strings.NewReader(data), int64(len(data)), "", nil); err != nil {
+						t.Errorf("PutObject: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+			assertObjectMatchesMetadata(t, s, "bucket", "key")
+		})
+	}
+}
+
+func TestConcurrentVersionedWrites(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	// Versions and delete markers become the latest version and the current
+	// object at once
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%4 == 3 {
+				if _, _, err := fs.DeleteObjectVersioned(ctx, "bucket", "key", ""); err != nil {
+					t.Errorf("DeleteObjectVersioned: %v", err)
+				}
+				return
+			}
+			data := strings.Repeat(fmt.Sprintf("writer %d ", i), 1000+i)
+			if _, _, err := fs.PutObjectVersioned(ctx, "bucket", "key", strings.NewReader(data), int64(len(data)), "", nil); err != nil {
+				t.Errorf("PutObjectVersioned: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	latest, err := fs.metadata.GetLatestObjectVersion(ctx, "bucket", "key")
+	if err != nil {
+		t.Fatalf("GetLatestObjectVersion: %v", err)
+	}
+	current, err := fs.metadata.GetObject(ctx, "bucket", "key")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	switch {
+	case latest.IsDeleteMarker && current != nil:
+		t.Errorf("latest version is a delete marker, current object has ETag %s", current.ETag)
+	case !latest.IsDeleteMarker && (current == nil || current.ETag != latest.ETag):
+		t.Errorf("latest version has ETag %s, current object is %+v", latest.ETag, current)
+	case current != nil:
+		assertObjectMatchesMetadata(t, fs, "bucket", "key")
+	}
+}
+
+func TestHoldKey(t *testing.T) {
+	fs := newTestFileSystem(t)
+
+	// Writes made under a held key lock do not wait for it, others do
+	ctx, unlock := fs.holdKey(context.Background(), "bucket", "key")
+	fs.lockKey(ctx, "bucket", "key")()
+
+	locked := make(chan struct{})
+	go func() {
+		fs.lockKey(context.Background(), "bucket", "key")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("lockKey did not wait for the held key lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
+
+// assertObjectMatchesMetadata checks that the data of an object has the size
+// and MD5 ETag its metadata records.
+func assertObjectMatchesMetadata(t *testing.T, s Storage, bucket, key string) {
+	t.Helper()
+	obj, err := s.GetObject(context.Background(), bucket, key)
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	defer obj.Body.Close()
+	data, err := io.ReadAll(obj.Body)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	sum := md5.Sum(data)
+	if etag := hex.EncodeToString(sum[:]); etag != obj.ETag || int64(len(data)) != obj.Size {
+		t.Errorf("data of %d bytes with ETag %s, metadata says %d bytes with ETag %s", len(data), etag, obj.Size, obj.ETag)
+	}
+}
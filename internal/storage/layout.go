@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// maxKeyLength is the S3 limit of the length of object keys in bytes.
+const maxKeyLength = 1024
+
+// maxNameLength is the limit of the length of file names in bytes on most
+// filesystems.
+const maxNameLength = 255
+
+// hashedNamePrefix starts the file names of key segments that are too long
+// for a file name. The leading dot hides them from the data directory
+// watcher, which cannot tell their keys.
+const hashedNamePrefix = ".long-"
+
+// Layout selects the version of the layout of the files of objects below the
+// bucket directories.
+type Layout string
+
+const (
+	// LayoutV1 stores every key segment in a file name of its own, so keys
+	// with segments longer than a file name cannot be stored.
+	LayoutV1 Layout = "v1"
+	// LayoutV2 stores key segments that are too long for a file name under a
+	// hashed name. Their keys are only kept in the metadata. A data
+	// directory of layout v1 can be used with layout v2.
+	LayoutV2 Layout = "v2"
+)
+
+// ParseLayout parses a storage layout. An empty string selects LayoutV1.
+func ParseLayout(s string) (Layout, error) {
+	switch Layout(s) {
+	case "", LayoutV1:
+		return LayoutV1, nil
+	case LayoutV2:
+		return LayoutV2, nil
+	default:
+		return "", fmt.Errorf("invalid storage layout %q", s)
+	}
+}
+
+// SetLayout sets the layout of the files of objects.
+func (fs *FileSystem) SetLayout(layout Layout) {
+	fs.layout = layout
+}
+
+// hashName returns the file name of a key segment whose encoded name is
+// longer than maxNameLength in layout v2, or the encoded name itself.
+func (fs *FileSystem) hashName(segment, name string) string {
+	if fs.layout != LayoutV2 || len(name) <= maxNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(segment))
+	return hashedNamePrefix + hex.EncodeToString(sum[:])
+}
+
+// isHashedName reports whether a file name was returned for a long key
+// segment by hashName.
+func (fs *FileSystem) isHashedName(name string) bool {
+	return fs.layout == LayoutV2 && strings.HasPrefix(name, hashedNamePrefix)
+}
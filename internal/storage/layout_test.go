@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLayoutV2LongKeys(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	long := strings.Repeat("x", 300)
+	key := "dir/" + long + "/object"
+
+	// Layout v1 cannot store segments longer than a file name
+	if _, err := fs.PutObject(ctx, "bucket", key, strings.NewReader("data"), 4, "", nil); err == nil {
+		t.Fatalf("PutObject of a long segment with layout v1 succeeded")
+	}
+
+	fs.SetLayout(LayoutV2)
+	if _, err := fs.PutObject(ctx, "bucket", key, strings.NewReader("data"), 4, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	obj, err := fs.GetObject(ctx, "bucket", key)
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	data, err := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if err != nil || string(data) != "data" {
+		t.Errorf("GetObject = %q, %v, want data", data, err)
+	}
+
+	path := fs.keyPath(key)
+	segments := strings.Split(path, "/")
+	if segments[0] != "dir" || !strings.HasPrefix(segments[1], hashedNamePrefix) || segments[2] != "object" {
+		t.Errorf("keyPath(%q) = %q, want the long segment hashed", key, path)
+	}
+	if _, err := os.Stat(filepath.Join(fs.dataDir, "bucket", path)); err != nil {
+		t.Errorf("object file: %v", err)
+	}
+	if _, ok := fs.pathKey(path); ok {
+		t.Errorf("pathKey(%q) succeeded, want false", path)
+	}
+	if fs.keyPath("dir/short") != "dir/short" {
+		t.Errorf("keyPath changed a short key")
+	}
+
+	// Keys longer than S3 allows are rejected
+	if _, err := fs.PutObject(ctx, "bucket", strings.Repeat("k", maxKeyLength+1), strings.NewReader("data"), 4, "", nil); !errors.Is(err, ErrKeyTooLong) {
+		t.Errorf("PutObject of a too long key: got %v, want ErrKeyTooLong", err)
+	}
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AcquireLease acquires or renews the named lease for holder until now+ttl.
+// It fails if another holder has a lease that has not expired. The term grows
+// every time the lease changes hands, so it identifies a holder's tenure.
+func (m *Metadata) AcquireLease(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (int64, bool, error) {
+	var term int64
+	err := m.db.QueryRowContext(ctx, `
+		INSERT INTO leases (name, holder, term, expires) VALUES (?, ?, 1, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			term = CASE WHEN leases.holder = excluded.holder THEN leases.term ELSE leases.term + 1 END,
+			holder = excluded.holder,
+			expires = excluded.expires
+		WHERE leases.holder = excluded.holder OR leases.expires < ?
+		RETURNING term
+	`, name, holder, now.Add(ttl).UnixNano(), now.UnixNano()).Scan(&term)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return term, true, nil
+}
+
+// ReleaseLease expires the named lease if holder holds it, so another holder
+// can acquire it right away.
+func (m *Metadata) ReleaseLease(ctx context.Context, name, holder string) error {
+	_, err := m.db.ExecContext(ctx, `
+		UPDATE leases SET expires = 0 WHERE name = ? AND holder = ?
+	`, name, holder)
+	return err
+}
+
+// AcquireLease acquires or renews a lease stored in the metadata database.
+// See Metadata.AcquireLease.
+func (fs *FileSystem) AcquireLease(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (int64, bool, error) {
+	return fs.metadata.AcquireLease(ctx, name, holder, now, ttl)
+}
+
+// ReleaseLease releases a lease stored in the metadata database.
+func (fs *FileSystem) ReleaseLease(ctx context.Context, name, holder string) error {
+	return fs.metadata.ReleaseLease(ctx, name, holder)
+}
+
+// RemoveTempFiles removes the temporary files of writes in progress below the
+// data directory. A writer whose temporary file is removed fails instead of
+// publishing its object, so this fences out a previous writer of a shared
+// data directory.
+func (fs *FileSystem) RemoveTempFiles() (int, error) {
+	removed := 0
+	err := filepath.WalkDir(fs.dataDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Directories removed concurrently are skipped
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireLease(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	now := time.Now()
+	ttl := 10 * time.Second
+
+	term, ok, err := fs.AcquireLease(ctx, "leader", "a", now, ttl)
+	if err != nil || !ok || term != 1 {
+		t.Fatalf("AcquireLease(a) = %d, %v, %v", term, ok, err)
+	}
+	// Another holder waits for the lease to expire
+	if _, ok, err := fs.AcquireLease(ctx, "leader", "b", now.Add(5*time.Second), ttl); err != nil || ok {
+		t.Fatalf("AcquireLease(b) while held = %v, %v", ok, err)
+	}
+	// Renewing keeps the term
+	if term, ok, err := fs.AcquireLease(ctx, "leader", "a", now.Add(5*time.Second), ttl); err != nil || !ok || term != 1 {
+		t.Fatalf("renewing = %d, %v, %v", term, ok, err)
+	}
+	if _, ok, _ := fs.AcquireLease(ctx, "leader", "b", now.Add(14*time.Second), ttl); ok {
+		t.Fatal("AcquireLease(b) succeeded before the renewed lease expired")
+	}
+
+	term, ok, err = fs.AcquireLease(ctx, "leader", "b", now.Add(16*time.Second), ttl)
+	if err != nil || !ok || term != 2 {
+		t.Fatalf("AcquireLease(b) after expiry = %d, %v, %v", term, ok, err)
+	}
+	if _, ok, _ := fs.AcquireLease(ctx, "leader", "a", now.Add(17*time.Second), ttl); ok {
+		t.Fatal("previous holder reacquired a held lease")
+	}
+
+	// Released leases can be taken over right away
+	if err := fs.ReleaseLease(ctx, "leader", "b"); err != nil {
+		t.Fatalf("ReleaseLease: %v", err)
+	}
+	if term, ok, err := fs.AcquireLease(ctx, "leader", "a", now.Add(18*time.Second), ttl); err != nil || !ok || term != 3 {
+		t.Fatalf("AcquireLease(a) after release = %d, %v, %v", term, ok, err)
+	}
+}
+
+func TestRemoveTempFiles(t *testing.T) {
+	fs := newTestFileSystem(t)
+	dir := filepath.Join(fs.dataDir, "bucket", "dir")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{".tmp-1", "dir/.tmp-2", "object", "dir/object"} {
+		if err := os.WriteFile(filepath.Join(fs.dataDir, "bucket", name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := fs.RemoveTempFiles()
+	if err != nil || removed != 2 {
+		t.Fatalf("RemoveTempFiles = %d, %v", removed, err)
+	}
+	for _, name := range []string{"object", "dir/object"} {
+		if _, err := os.Stat(filepath.Join(fs.dataDir, "bucket", name)); err != nil {
+			t.Errorf("%s was removed: %v", name, err)
+		}
+	}
+}
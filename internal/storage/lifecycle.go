@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// LifecycleCandidatesInput holds parameters for selecting the current objects
+// of a bucket that a lifecycle rule applies to.
+type LifecycleCandidatesInput struct {
+	Bucket string
+	// Filter is the filter of the rule; nil selects all objects.
+	Filter *LifecycleRuleFilter
+	// ModifiedBefore, unless zero, selects only objects last modified before
+	// it, such as the expiration date of the rule.
+	ModifiedBefore time.Time
+	StartAfter     string
+	MaxKeys        int32
+}
+
+// LifecycleCandidatesOutput holds a page of objects a lifecycle rule applies
+// to.
+type LifecycleCandidatesOutput struct {
+	Objects     []Object
+	IsTruncated bool
+	// NextStartAfter is the key the next page starts after.
+	NextStartAfter string
+}
+
+// lifecycleConditions are the conditions of a lifecycle rule filter, whether
+// given on their own or combined with And.
+type lifecycleConditions struct {
+	prefix          string
+	tags            []Tag
+	sizeGreaterThan *int64
+	sizeLessThan    *int64
+}
+
+// conditions returns the conditions of a filter.
+func (f *LifecycleRuleFilter) conditions() lifecycleConditions {
+	if f == nil {
+		return lifecycleConditions{}
+	}
+	if f.And != nil {
+		return lifecycleConditions{
+			prefix:          f.And.Prefix,
+			tags:            f.And.Tags,
+			sizeGreaterThan: f.And.ObjectSizeGreaterThan,
+			sizeLessThan:    f.And.ObjectSizeLessThan,
+		}
+	}
+	c := lifecycleConditions{
+		prefix:          f.Prefix,
+		sizeGreaterThan: f.ObjectSizeGreaterThan,
+		sizeLessThan:    f.ObjectSizeLessThan,
+	}
+	if f.Tag != nil {
+		c.tags = []Tag{*f.Tag}
+	}
+	return c
+}
+
+// Matches reports whether a filter applies to an object with a tag set. A nil
+// filter applies to all objects.
+func (f *LifecycleRuleFilter) Matches(obj Object, tags []Tag) bool {
+	c := f.conditions()
+	if !strings.HasPrefix(obj.Key, c.prefix) {
+		return false
+	}
+	if c.sizeGreaterThan != nil && obj.Size <= *c.sizeGreaterThan {
+		return false
+	}
+	if c.sizeLessThan != nil && obj.Size >= *c.sizeLessThan {
+		return false
+	}
+	for _, want := range c.tags {
+		if !slices.Contains(tags, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListLifecycleCandidates returns the current objects of a bucket that the
+// filter of a lifecycle rule applies to, ordered by key. Tag conditions are
+// evaluated in the metadata database, so rules scoped to a few tagged objects
+// do not need to read the tags of every object of a large bucket.
+func (fs *FileSystem) ListLifecycleCandidates(ctx context.Context, input *LifecycleCandidatesInput) (*LifecycleCandidatesOutput, error) {
+	exists, err := fs.metadata.BucketExists(ctx, input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	maxKeys := input.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	// Modification times are compared here, as the metadata stores them in
+	// a format SQLite cannot compare
+	output := &LifecycleCandidatesOutput{}
+	startAfter := input.StartAfter
+	conditions := input.Filter.conditions()
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		objects, err := fs.metadata.ListLifecycleCandidates(ctx, input.Bucket, conditions, startAfter, maxKeys)
+		if err != nil {
+			return nil, err
+		}
+		more := len(objects) > int(maxKeys)
+		if more {
+			objects = objects[:maxKeys]
+		}
+		for _, obj := range objects {
+			if !input.ModifiedBefore.IsZero() && !obj.LastModified.Before(input.ModifiedBefore) {
+				continue
+			}
+			if len(output.Objects) == int(maxKeys) {
+				output.IsTruncated = true
+				output.NextStartAfter = output.Objects[maxKeys-1].Key
+				return output, nil
+			}
+			output.Objects = append(output.Objects, obj)
+		}
+		if !more {
+			return output, nil
+		}
+		startAfter = objects[len(objects)-1].Key
+	}
+}
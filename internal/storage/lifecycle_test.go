@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListLifecycleCandidates(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	for _, obj := range []struct {
+		key  string
+		size int
+		tags []Tag
+	}{
+		{"logs/a.log", 10, []Tag{{Key: "team", Value: "web"}, {Key: "env", Value: "dev"}}},
+		{"logs/b.log", 100, []Tag{{Key: "team", Value: "web"}, {Key: "env", Value: "prod"}}},
+		{"logs/c.log", 1000, []Tag{{Key: "team", Value: "web"}, {Key: "env", Value: "dev"}}},
+		{"Logs/d.log", 10, []Tag{{Key: "team", Value: "web"}, {Key: "env", Value: "dev"}}},
+		{"logs_e.log", 10, []Tag{{Key: "team", Value: "web"}}},
+		{"tmp/f.log", 10, nil},
+	} {
+		data := strings.Repeat("x", obj.size)
+		if _, err := fs.PutObject(ctx, "bucket", obj.key, strings.NewReader(data), int64(obj.size), "text/plain", nil); err != nil {
+			t.Fatalf("PutObject %s: %v", obj.key, err)
+		}
+		if obj.tags != nil {
+			if err := fs.PutObjectTagging(ctx, "bucket", obj.key, obj.tags); err != nil {
+				t.Fatalf("PutObjectTagging %s: %v", obj.key, err)
+			}
+		}
+	}
+
+	size := func(n int64) *int64 { return &n }
+	tests := []struct {
+		name   string
+		filter *LifecycleRuleFilter
+		want   []string
+	}{
+		{"all", nil, []string{"Logs/d.log", "logs/a.log", "logs/b.log", "logs/c.log", "logs_e.log", "tmp/f.log"}},
+		{"prefix", &LifecycleRuleFilter{Prefix: "logs/"}, []string{"logs/a.log", "logs/b.log", "logs/c.log"}},
+		{"tag", &LifecycleRuleFilter{Tag: &Tag{Key: "env", Value: "prod"}}, []string{"logs/b.log"}},
+		{"size", &LifecycleRuleFilter{ObjectSizeGreaterThan: size(10)}, []string{"logs/b.log", "logs/c.log"}},
+		{"and", &LifecycleRuleFilter{And: &LifecycleRuleAndOperator{
+			Prefix:             "logs/",
+			Tags:               []Tag{{Key: "team", Value: "web"}, {Key: "env", Value: "dev"}},
+			ObjectSizeLessThan: size(1000),
+		}}, []string{"logs/a.log"}},
+		{"and without matches", &LifecycleRuleFilter{And: &LifecycleRuleAndOperator{
+			Tags: []Tag{{Key: "team", Value: "web"}, {Key: "env", Value: "qa"}},
+		}}, nil},
+	}
+	for _, tt := range tests {
+		output, err := fs.ListLifecycleCandidates(ctx, &LifecycleCandidatesInput{Bucket: "bucket", Filter: tt.filter})
+		if err != nil {
+			t.Fatalf("%s: ListLifecycleCandidates: %v", tt.name, err)
+		}
+		var keys []string
+		for _, obj := range output.Objects {
+			keys = append(keys, obj.Key)
+			tags, _ := fs.GetObjectTagging(ctx, "bucket", obj.Key)
+			if !tt.filter.Matches(obj, tags) {
+				t.Errorf("%s: filter does not match candidate %s", tt.name, obj.Key)
+			}
+		}
+		if !slices.Equal(keys, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, keys, tt.want)
+		}
+	}
+
+	// Pages continue after the last key
+	filter := &LifecycleRuleFilter{Tag: &Tag{Key: "team", Value: "web"}}
+	output, err := fs.ListLifecycleCandidates(ctx, &LifecycleCandidatesInput{Bucket: "bucket", Filter: filter, MaxKeys: 3})
+	if err != nil {
+		t.Fatalf("ListLifecycleCandidates: %v", err)
+	}
+	if len(output.Objects) != 3 || !output.IsTruncated || output.NextStartAfter != "logs/b.log" {
+		t.Fatalf("first page = %+v", output)
+	}
+	output, err = fs.ListLifecycleCandidates(ctx, &LifecycleCandidatesInput{Bucket: "bucket", Filter: filter, MaxKeys: 3, StartAfter: output.NextStartAfter})
+	if err != nil {
+		t.Fatalf("ListLifecycleCandidates: %v", err)
+	}
+	if len(output.Objects) != 2 || output.IsTruncated {
+		t.Errorf("second page = %+v", output)
+	}
+
+	// Objects modified after the cutoff are skipped
+	output, err = fs.ListLifecycleCandidates(ctx, &LifecycleCandidatesInput{Bucket: "bucket", ModifiedBefore: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("ListLifecycleCandidates: %v", err)
+	}
+	if len(output.Objects) != 0 || output.IsTruncated {
+		t.Errorf("objects modified before an hour ago = %+v", output)
+	}
+}
@@ -5,16 +5,20 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	_ "modernc.org/sqlite"
 )
 
 // Metadata manages object metadata using SQLite.
 type Metadata struct {
-	db *sql.DB
+	db *metadataDB
 }
 
 // NewMetadata creates a new metadata store.
@@ -24,12 +28,12 @@ func NewMetadata(dbPath string) (*Metadata, error) {
 		return nil, err
 	}
 
-	db, err := sql.Open("sqlite", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	m := &Metadata{db: db}
+	m := &Metadata{db: newMetadataDB(db)}
 	if err := m.initialize(); err != nil {
 		db.Close()
 		return nil, err
@@ -123,6 +127,55 @@ func (m *Metadata) initialize() error {
 		return fmt.Errorf("failed to create object_tags table: %w", err)
 	}
 
+	// Index object_tags for listing objects by tag
+	_, err = m.db.Exec(`CREATE INDEX IF NOT EXISTS idx_object_tags_tag ON object_tags(bucket, tag_key, tag_value)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
+	// Create object_metadata table, indexing user metadata for searches.
+	// Databases created before it existed get it filled from the metadata
+	// column of their objects.
+	var hasObjectMetadata bool
+	err = m.db.QueryRowContext(context.Background(), `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'object_metadata')`).Scan(&hasObjectMetadata)
+	if err != nil {
+		return fmt.Errorf("failed to check object_metadata table: %w", err)
+	}
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS object_metadata (
+			bucket TEXT NOT NULL,
+			key TEXT NOT NULL,
+			meta_key TEXT NOT NULL,
+			meta_value TEXT NOT NULL,
+			PRIMARY KEY (bucket, key, meta_key),
+			FOREIGN KEY (bucket, key) REFERENCES objects(bucket, key) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create object_metadata table: %w", err)
+	}
+	if !hasObjectMetadata {
+		_, err = m.db.Exec(`
+			INSERT OR IGNORE INTO object_metadata (bucket, key, meta_key, meta_value)
+			SELECT o.bucket, o.key, j.key, j.value
+			FROM objects o, json_each(o.metadata) j
+			WHERE json_valid(o.metadata) AND json_type(o.metadata) = 'object'
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to fill object_metadata table: %w", err)
+		}
+	}
+
+	// Index user metadata and content types for searching objects
+	_, err = m.db.Exec(`CREATE INDEX IF NOT EXISTS idx_object_metadata_meta ON object_metadata(bucket, meta_key, meta_value)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+	_, err = m.db.Exec(`CREATE INDEX IF NOT EXISTS idx_objects_content_type ON objects(bucket, content_type)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
 	// Create bucket_tags table
 	_, err = m.db.Exec(`
 		CREATE TABLE IF NOT EXISTS bucket_tags (
@@ -161,6 +214,18 @@ func (m *Metadata) initialize() error {
 		return fmt.Errorf("failed to create bucket_versioning table: %w", err)
 	}
 
+	// Create bucket_mfa_delete table
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_mfa_delete (
+			bucket TEXT PRIMARY KEY,
+			status TEXT NOT NULL,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_mfa_delete table: %w", err)
+	}
+
 	// Create object_versions table
 	_, err = m.db.Exec(`
 		CREATE TABLE IF NOT EXISTS object_versions (
@@ -305,167 +370,1883 @@ func (m *Metadata) initialize() error {
 		return fmt.Errorf("failed to create bucket_website table: %w", err)
 	}
 
-	return nil
-}
+	// Create bucket_tiering table (stores tiering config as JSON)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_tiering (
+			bucket TEXT PRIMARY KEY,
+			tiering_config TEXT NOT NULL,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_tiering table: %w", err)
+	}
 
-// CreateBucket creates a new bucket.
-func (m *Metadata) CreateBucket(ctx context.Context, name string, creationDate time.Time) error {
-	_, err := m.db.ExecContext(ctx, `
-		INSERT INTO buckets (name, creation_date) VALUES (?, ?)
-	`, name, creationDate)
-	return err
-}
+	// Create bucket_owners table (buckets created without authentication have no row)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_owners (
+			bucket TEXT PRIMARY KEY,
+			owner TEXT NOT NULL,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_owners table: %w", err)
+	}
 
-// DeleteBucket deletes a bucket.
-func (m *Metadata) DeleteBucket(ctx context.Context, name string) error {
-	_, err := m.db.ExecContext(ctx, `DELETE FROM buckets WHERE name = ?`, name)
-	return err
+	// Create bucket_regions table (buckets created without a location
+	// constraint have no row and are in the region of the server)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_regions (
+			bucket TEXT PRIMARY KEY,
+			region TEXT NOT NULL,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_regions table: %w", err)
+	}
+
+	// Create object_parts table (part layout of objects created by multipart upload)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS object_parts (
+			bucket TEXT NOT NULL,
+			key TEXT NOT NULL,
+			part_number INTEGER NOT NULL,
+			part_offset INTEGER NOT NULL,
+			size INTEGER NOT NULL,
+			etag TEXT NOT NULL,
+			checksum_algorithm TEXT NOT NULL DEFAULT '',
+			checksum TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (bucket, key, part_number),
+			FOREIGN KEY (bucket, key) REFERENCES objects(bucket, key) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create object_parts table: %w", err)
+	}
+
+	// Create object_verifications table (results of integrity scrubbing)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS object_verifications (
+			bucket TEXT NOT NULL,
+			key TEXT NOT NULL,
+			verified_at INTEGER NOT NULL,
+			corruption TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (bucket, key),
+			FOREIGN KEY (bucket, key) REFERENCES objects(bucket, key) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create object_verifications table: %w", err)
+	}
+
+	// Create bucket_compression table (compression setting of a bucket)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_compression (
+			bucket TEXT PRIMARY KEY,
+			algorithm TEXT NOT NULL,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_compression table: %w", err)
+	}
+
+	// Create bucket_content_types table (content type inference rules of a bucket)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_content_types (
+			bucket TEXT NOT NULL,
+			extension TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			PRIMARY KEY (bucket, extension),
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_content_types table: %w", err)
+	}
+
+	// Create bucket_default_tags table (tags added to objects written to a bucket)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_default_tags (
+			bucket TEXT NOT NULL,
+			tag_key TEXT NOT NULL,
+			tag_value TEXT NOT NULL,
+			PRIMARY KEY (bucket, tag_key),
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_default_tags table: %w", err)
+	}
+
+	// Create bucket_response_headers table (stores response header policy as JSON)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_response_headers (
+			bucket TEXT PRIMARY KEY,
+			headers_config TEXT NOT NULL,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_response_headers table: %w", err)
+	}
+
+	// Create bucket_skip_unchanged table (buckets skipping unchanged overwrites)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_skip_unchanged (
+			bucket TEXT PRIMARY KEY,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_skip_unchanged table: %w", err)
+	}
+
+	// Create bucket_worm table (buckets in write-once mode)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_worm (
+			bucket TEXT PRIMARY KEY,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_worm table: %w", err)
+	}
+
+	// Create bucket_version_retention table (number of noncurrent versions
+	// kept per key)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_version_retention (
+			bucket TEXT PRIMARY KEY,
+			max_noncurrent_versions INTEGER NOT NULL,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_version_retention table: %w", err)
+	}
+
+	// Create object_compression table (objects stored compressed, absent if not)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS object_compression (
+			bucket TEXT NOT NULL,
+			key TEXT NOT NULL,
+			algorithm TEXT NOT NULL,
+			PRIMARY KEY (bucket, key),
+			FOREIGN KEY (bucket, key) REFERENCES objects(bucket, key) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create object_compression table: %w", err)
+	}
+
+	// Create object_append_digests table (MD5 state of appendable objects,
+	// valid while the object has the recorded ETag and size)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS object_append_digests (
+			bucket TEXT NOT NULL,
+			key TEXT NOT NULL,
+			etag TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			state BLOB NOT NULL,
+			PRIMARY KEY (bucket, key)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create object_append_digests table: %w", err)
+	}
+
+	// Create object_content_md5 table (content MD5 of ingested objects whose
+	// ETag is not their MD5, valid while the object has the recorded ETag)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS object_content_md5 (
+			bucket TEXT NOT NULL,
+			key TEXT NOT NULL,
+			etag TEXT NOT NULL,
+			md5 TEXT NOT NULL,
+			PRIMARY KEY (bucket, key)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create object_content_md5 table: %w", err)
+	}
+
+	// Create object_encryption table (objects and versions encrypted at rest,
+	// absent if stored in plaintext). version_id is empty for current objects.
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS object_encryption (
+			bucket TEXT NOT NULL,
+			key TEXT NOT NULL,
+			version_id TEXT NOT NULL DEFAULT '',
+			algorithm TEXT NOT NULL,
+			key_id TEXT NOT NULL,
+			context TEXT,
+			wrapped_key BLOB NOT NULL,
+			PRIMARY KEY (bucket, key, version_id),
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create object_encryption table: %w", err)
+	}
+
+	// Create upload_encryption table (server-side encryption requested with
+	// CreateMultipartUpload, applied on completion)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS upload_encryption (
+			upload_id TEXT PRIMARY KEY,
+			algorithm TEXT NOT NULL,
+			key_id TEXT NOT NULL DEFAULT '',
+			context TEXT,
+			FOREIGN KEY (upload_id) REFERENCES multipart_uploads(upload_id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create upload_encryption table: %w", err)
+	}
+
+	// Create upload_checksums table (checksum algorithm requested with
+	// CreateMultipartUpload)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS upload_checksums (
+			upload_id TEXT PRIMARY KEY,
+			algorithm TEXT NOT NULL,
+			FOREIGN KEY (upload_id) REFERENCES multipart_uploads(upload_id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create upload_checksums table: %w", err)
+	}
+
+	// Create upload_object_lock table (retention and legal hold requested
+	// with CreateMultipartUpload, applied on completion)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS upload_object_lock (
+			upload_id TEXT PRIMARY KEY,
+			mode TEXT NOT NULL DEFAULT '',
+			retain_until_date DATETIME,
+			legal_hold TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (upload_id) REFERENCES multipart_uploads(upload_id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create upload_object_lock table: %w", err)
+	}
+
+	// Create upload_tags table (tags requested with CreateMultipartUpload,
+	// applied on completion)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS upload_tags (
+			upload_id TEXT NOT NULL,
+			tag_key TEXT NOT NULL,
+			tag_value TEXT NOT NULL,
+			PRIMARY KEY (upload_id, tag_key),
+			FOREIGN KEY (upload_id) REFERENCES multipart_uploads(upload_id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create upload_tags table: %w", err)
+	}
+
+	// Create upload_states table (uploads being completed or aborted).
+	// Uploads without a state are active.
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS upload_states (
+			upload_id TEXT PRIMARY KEY,
+			state TEXT NOT NULL,
+			FOREIGN KEY (upload_id) REFERENCES multipart_uploads(upload_id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create upload_states table: %w", err)
+	}
+
+	// Create part_checksums table (checksums of uploaded parts)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS part_checksums (
+			upload_id TEXT NOT NULL,
+			part_number INTEGER NOT NULL,
+			algorithm TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			PRIMARY KEY (upload_id, part_number),
+			FOREIGN KEY (upload_id) REFERENCES multipart_uploads(upload_id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create part_checksums table: %w", err)
+	}
+
+	// Create bucket_usage table, counters of the objects, versions and
+	// multipart uploads of buckets kept up to date by triggers. Databases
+	// created before it existed get the counters computed once.
+	var hasBucketUsage bool
+	err = m.db.QueryRowContext(context.Background(), `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'bucket_usage')`).Scan(&hasBucketUsage)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket_usage table: %w", err)
+	}
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_usage (
+			bucket TEXT PRIMARY KEY,
+			objects INTEGER NOT NULL DEFAULT 0,
+			bytes INTEGER NOT NULL DEFAULT 0,
+			version_bytes INTEGER NOT NULL DEFAULT 0,
+			multipart_uploads INTEGER NOT NULL DEFAULT 0,
+			multipart_bytes INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_usage table: %w", err)
+	}
+	for _, trigger := range bucketUsageTriggers {
+		if _, err := m.db.Exec(trigger); err != nil {
+			return fmt.Errorf("failed to create bucket_usage trigger: %w", err)
+		}
+	}
+	if !hasBucketUsage {
+		_, err = m.db.Exec(`
+			INSERT OR REPLACE INTO bucket_usage (bucket, objects, bytes, version_bytes, multipart_uploads, multipart_bytes)
+			SELECT b.name,
+				(SELECT COUNT(*) FROM objects o WHERE o.bucket = b.name),
+				(SELECT COALESCE(SUM(o.size), 0) FROM objects o WHERE o.bucket = b.name),
+				(SELECT COALESCE(SUM(v.size), 0) FROM object_versions v WHERE v.bucket = b.name AND v.is_delete_marker = 0),
+				(SELECT COUNT(*) FROM multipart_uploads u WHERE u.bucket = b.name),
+				(SELECT COALESCE(SUM(p.size), 0) FROM parts p JOIN multipart_uploads u ON u.upload_id = p.upload_id WHERE u.bucket = b.name)
+			FROM buckets b
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to fill bucket_usage table: %w", err)
+		}
+	}
+
+	// Create bucket_usage_history table (daily snapshots of the usage of
+	// buckets, recorded by RecordUsageHistory)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_usage_history (
+			bucket TEXT NOT NULL,
+			date TEXT NOT NULL,
+			objects INTEGER NOT NULL,
+			bytes INTEGER NOT NULL,
+			version_bytes INTEGER NOT NULL,
+			multipart_bytes INTEGER NOT NULL,
+			physical_bytes INTEGER NOT NULL,
+			recorded_at INTEGER NOT NULL,
+			PRIMARY KEY (bucket, date),
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_usage_history table: %w", err)
+	}
+	_, err = m.db.Exec(`CREATE INDEX IF NOT EXISTS idx_bucket_usage_history_date ON bucket_usage_history(date)`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_usage_history index: %w", err)
+	}
+
+	// Create bucket_trash table (retention of deleted objects in buckets in
+	// trash mode)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bucket_trash (
+			bucket TEXT PRIMARY KEY,
+			days INTEGER NOT NULL,
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket_trash table: %w", err)
+	}
+
+	// Create object_trash table (deleted objects kept in the trash of their
+	// bucket). details holds the metadata needed to restore an object.
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS object_trash (
+			bucket TEXT NOT NULL,
+			trash_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			etag TEXT NOT NULL,
+			content_type TEXT,
+			last_modified INTEGER NOT NULL,
+			deleted_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL,
+			details TEXT NOT NULL,
+			PRIMARY KEY (bucket, trash_id),
+			FOREIGN KEY (bucket) REFERENCES buckets(name) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create object_trash table: %w", err)
+	}
+	_, err = m.db.Exec(`CREATE INDEX IF NOT EXISTS idx_object_trash_expires ON object_trash(expires_at)`)
+	if err != nil {
+		return fmt.Errorf("failed to create object_trash index: %w", err)
+	}
+
+	// Create replication_heartbeat table (single row read by replicas)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS replication_heartbeat (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			time DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create replication_heartbeat table: %w", err)
+	}
+
+	// Create leases table (leader election of instances sharing the database)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS leases (
+			name TEXT PRIMARY KEY,
+			holder TEXT NOT NULL,
+			term INTEGER NOT NULL,
+			expires INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create leases table: %w", err)
+	}
+
+	// Create service accounts table (keys minted for applications; secrets
+	// are only stored hashed)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS service_accounts (
+			access_key TEXT PRIMARY KEY,
+			secret_hash TEXT NOT NULL,
+			parent TEXT NOT NULL,
+			bucket TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			actions TEXT NOT NULL,
+			description TEXT NOT NULL,
+			created INTEGER NOT NULL,
+			expires INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create service_accounts table: %w", err)
+	}
+
+	// Create access key secrets table (secret keys of rotated access keys,
+	// which replace the configured ones)
+	_, err = m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS access_key_secrets (
+			access_key TEXT PRIMARY KEY,
+			secret_key TEXT NOT NULL,
+			previous_secret_key TEXT NOT NULL,
+			previous_expires INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create access_key_secrets table: %w", err)
+	}
+
+	return nil
+}
+
+// bucketUsageTriggers maintain the bucket_usage counters on every write, so
+// that usage is read without scanning a bucket. Objects and parts are
+// replaced with INSERT OR REPLACE, which does not fire delete triggers, so the
+// replaced row is subtracted before the insert.
+var bucketUsageTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_objects_replace BEFORE INSERT ON objects BEGIN
+		UPDATE bucket_usage SET objects = objects - 1,
+			bytes = bytes - (SELECT size FROM objects WHERE bucket = NEW.bucket AND key = NEW.key)
+		WHERE bucket = NEW.bucket AND EXISTS (SELECT 1 FROM objects WHERE bucket = NEW.bucket AND key = NEW.key);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_objects_insert AFTER INSERT ON objects BEGIN
+		INSERT INTO bucket_usage (bucket, objects, bytes) VALUES (NEW.bucket, 1, NEW.size)
+		ON CONFLICT (bucket) DO UPDATE SET objects = objects + 1, bytes = bytes + excluded.bytes;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_objects_update AFTER UPDATE OF size ON objects BEGIN
+		UPDATE bucket_usage SET bytes = bytes - OLD.size + NEW.size WHERE bucket = NEW.bucket;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_objects_delete AFTER DELETE ON objects BEGIN
+		UPDATE bucket_usage SET objects = objects - 1, bytes = bytes - OLD.size WHERE bucket = OLD.bucket;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_versions_insert AFTER INSERT ON object_versions WHEN NOT NEW.is_delete_marker BEGIN
+		INSERT INTO bucket_usage (bucket, version_bytes) VALUES (NEW.bucket, NEW.size)
+		ON CONFLICT (bucket) DO UPDATE SET version_bytes = version_bytes + excluded.version_bytes;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_versions_delete AFTER DELETE ON object_versions WHEN NOT OLD.is_delete_marker BEGIN
+		UPDATE bucket_usage SET version_bytes = version_bytes - OLD.size WHERE bucket = OLD.bucket;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_uploads_insert AFTER INSERT ON multipart_uploads BEGIN
+		INSERT INTO bucket_usage (bucket, multipart_uploads) VALUES (NEW.bucket, 1)
+		ON CONFLICT (bucket) DO UPDATE SET multipart_uploads = multipart_uploads + 1;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_uploads_delete AFTER DELETE ON multipart_uploads BEGIN
+		UPDATE bucket_usage SET multipart_uploads = multipart_uploads - 1 WHERE bucket = OLD.bucket;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_parts_replace BEFORE INSERT ON parts BEGIN
+		UPDATE bucket_usage
+		SET multipart_bytes = multipart_bytes - (SELECT size FROM parts WHERE upload_id = NEW.upload_id AND part_number = NEW.part_number)
+		WHERE bucket = (SELECT bucket FROM multipart_uploads WHERE upload_id = NEW.upload_id)
+			AND EXISTS (SELECT 1 FROM parts WHERE upload_id = NEW.upload_id AND part_number = NEW.part_number);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_parts_insert AFTER INSERT ON parts BEGIN
+		UPDATE bucket_usage SET multipart_bytes = multipart_bytes + NEW.size
+		WHERE bucket = (SELECT bucket FROM multipart_uploads WHERE upload_id = NEW.upload_id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_parts_delete AFTER DELETE ON parts BEGIN
+		UPDATE bucket_usage SET multipart_bytes = multipart_bytes - OLD.size
+		WHERE bucket = (SELECT bucket FROM multipart_uploads WHERE upload_id = OLD.upload_id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS bucket_usage_buckets_delete AFTER DELETE ON buckets BEGIN
+		DELETE FROM bucket_usage WHERE bucket = OLD.name;
+	END`,
+}
+
+// CreateBucket creates a new bucket. An empty owner creates an unowned bucket.
+func (m *Metadata) CreateBucket(ctx context.Context, name string, creationDate time.Time, owner, region string) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT INTO buckets (name, creation_date) VALUES (?, ?)
+	`, name, creationDate)
+	if err != nil {
+		return err
+	}
+
+	// Replace any region left behind by a deleted bucket of the same name
+	if region == "" {
+		_, err = m.db.ExecContext(ctx, `DELETE FROM bucket_regions WHERE bucket = ?`, name)
+	} else {
+		_, err = m.db.ExecContext(ctx, `
+			INSERT OR REPLACE INTO bucket_regions (bucket, region) VALUES (?, ?)
+		`, name, region)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Replace any owner left behind by a deleted bucket of the same name
+	if owner == "" {
+		_, err = m.db.ExecContext(ctx, `DELETE FROM bucket_owners WHERE bucket = ?`, name)
+		return err
+	}
+	_, err = m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO bucket_owners (bucket, owner) VALUES (?, ?)
+	`, name, owner)
+	return err
+}
+
+// DeleteBucket deletes a bucket.
+func (m *Metadata) DeleteBucket(ctx context.Context, name string) error {
+	_, _ = m.db.ExecContext(ctx, `DELETE FROM object_trash WHERE bucket = ?`, name)
+	_, _ = m.db.ExecContext(ctx, `DELETE FROM bucket_trash WHERE bucket = ?`, name)
+	_, _ = m.db.ExecContext(ctx, `DELETE FROM bucket_mfa_delete WHERE bucket = ?`, name)
+	_, _ = m.db.ExecContext(ctx, `DELETE FROM bucket_default_tags WHERE bucket = ?`, name)
+	_, _ = m.db.ExecContext(ctx, `DELETE FROM bucket_worm WHERE bucket = ?`, name)
+	_, _ = m.db.ExecContext(ctx, `DELETE FROM bucket_version_retention WHERE bucket = ?`, name)
+	_, _ = m.db.ExecContext(ctx, `DELETE FROM bucket_regions WHERE bucket = ?`, name)
+	_, _ = m.db.ExecContext(ctx, `DELETE FROM bucket_usage_history WHERE bucket = ?`, name)
+	_, err := m.db.ExecContext(ctx, `DELETE FROM buckets WHERE name = ?`, name)
+	return err
+}
+
+// BucketExists checks if a bucket exists.
+func (m *Metadata) BucketExists(ctx context.Context, name string) (bool, error) {
+	var count int
+	err := m.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM buckets WHERE name = ?`, name).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetBucket returns bucket metadata. The bucket is accessible to owner under
+// the rules of ListBuckets.
+func (m *Metadata) GetBucket(ctx context.Context, name, owner string) (*Bucket, error) {
+	var bucket Bucket
+	err := m.db.QueryRowContext(ctx, `
+		SELECT b.name, b.creation_date, COALESCE(o.owner, ''), COALESCE(r.region, ''),
+			?2 = ''
+			OR COALESCE(o.owner, '') IN ('', ?2)
+			OR EXISTS (
+				SELECT 1 FROM bucket_acls a, json_each(a.acl_config, '$.Grants') g
+				WHERE a.bucket = b.name AND json_extract(g.value, '$.GranteeID') = ?2
+			)
+		FROM buckets b
+		LEFT JOIN bucket_owners o ON o.bucket = b.name
+		LEFT JOIN bucket_regions r ON r.bucket = b.name
+		WHERE b.name = ?1
+	`, name, owner).Scan(&bucket.Name, &bucket.CreationDate, &bucket.Owner, &bucket.Region, &bucket.Accessible)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+// ListBuckets returns the buckets visible to owner: buckets it owns, unowned
+// buckets and buckets whose ACL grants it access. An empty owner returns all buckets.
+func (m *Metadata) ListBuckets(ctx context.Context, owner string) ([]Bucket, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT b.name, b.creation_date, COALESCE(o.owner, ''), COALESCE(r.region, '')
+		FROM buckets b
+		LEFT JOIN bucket_owners o ON o.bucket = b.name
+		LEFT JOIN bucket_regions r ON r.bucket = b.name
+		WHERE ?1 = ''
+			OR COALESCE(o.owner, '') IN ('', ?1)
+			OR EXISTS (
+				SELECT 1 FROM bucket_acls a, json_each(a.acl_config, '$.Grants') g
+				WHERE a.bucket = b.name AND json_extract(g.value, '$.GranteeID') = ?1
+			)
+		ORDER BY b.name
+	`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []Bucket
+	for rows.Next() {
+		bucket := Bucket{Accessible: true}
+		if err := rows.Scan(&bucket.Name, &bucket.CreationDate, &bucket.Owner, &bucket.Region); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+// PutObject stores object metadata.
+func (m *Metadata) PutObject(ctx context.Context, bucket string, obj *Object) error {
+	return m.PutObjectAtRest(ctx, bucket, obj, nil, "")
+}
+
+// PutObjectAtRest stores object metadata together with how the object is
+// stored at rest: encrypted as enc describes, or in plaintext if enc is nil,
+// and compressed with algorithm, or uncompressed if it is empty. All are
+// written in one transaction, so that an object is never recorded without its
+// data key or compression, which its file cannot be read back without.
+func (m *Metadata) PutObjectAtRest(ctx context.Context, bucket string, obj *Object, enc *ObjectEncryption, algorithm string) error {
+	metadata, err := json.Marshal(obj.Metadata)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Clean up old retention/legal-hold settings when overwriting object
+	if _, err := tx.ExecContext(ctx, `DELETE FROM object_retention WHERE bucket = ? AND key = ?`, bucket, obj.Key); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM object_legal_hold WHERE bucket = ? AND key = ?`, bucket, obj.Key); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM object_parts WHERE bucket = ? AND key = ?`, bucket, obj.Key); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM object_verifications WHERE bucket = ? AND key = ?`, bucket, obj.Key); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM object_compression WHERE bucket = ? AND key = ?`, bucket, obj.Key); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM object_encryption WHERE bucket = ? AND key = ? AND version_id = ''`, bucket, obj.Key); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT OR REPLACE INTO objects (bucket, key, size, last_modified, etag, content_type, metadata)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, bucket, obj.Key, obj.Size, obj.LastModified, obj.ETag, obj.ContentType, string(metadata)); err != nil {
+		return err
+	}
+	if enc != nil {
+		if err := insertObjectEncryption(ctx, tx, bucket, obj.Key, "", enc); err != nil {
+			return err
+		}
+	}
+	if algorithm != "" {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO object_compression (bucket, key, algorithm)
+			VALUES (?, ?, ?)
+		`, bucket, obj.Key, algorithm); err != nil {
+			return err
+		}
+	}
+	if err := replaceObjectMetadataIndex(ctx, tx, bucket, obj.Key, obj.Metadata); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetObject returns object metadata.
+func (m *Metadata) GetObject(ctx context.Context, bucket, key string) (*Object, error) {
+	var obj Object
+	var metadataStr string
+	err := m.db.QueryRowContext(ctx, `
+		SELECT key, size, last_modified, etag, content_type, metadata
+		FROM objects WHERE bucket = ? AND key = ?
+	`, bucket, key).Scan(&obj.Key, &obj.Size, &obj.LastModified, &obj.ETag, &obj.ContentType, &metadataStr)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if metadataStr != "" {
+		if err := json.Unmarshal([]byte(metadataStr), &obj.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return &obj, nil
+}
+
+// UpdateObjectMetadata replaces the user metadata and modification time of an
+// object, keeping its data related records such as the part layout.
+func (m *Metadata) UpdateObjectMetadata(ctx context.Context, bucket, key string, metadata map[string]string, lastModified time.Time) error {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.ExecContext(ctx, `
+		UPDATE objects SET metadata = ?, last_modified = ? WHERE bucket = ? AND key = ?
+	`, string(metadataJSON), lastModified, bucket, key)
+	if err != nil {
+		return err
+	}
+	return m.putObjectMetadataIndex(ctx, bucket, key, metadata)
+}
+
+// UpdateObjectHeaders replaces the content type, user metadata and
+// modification time of an object, keeping its data related records.
+func (m *Metadata) UpdateObjectHeaders(ctx context.Context, bucket, key, contentType string, metadata map[string]string, lastModified time.Time) error {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.ExecContext(ctx, `
+		UPDATE objects SET content_type = ?, metadata = ?, last_modified = ? WHERE bucket = ? AND key = ?
+	`, contentType, string(metadataJSON), lastModified, bucket, key)
+	if err != nil {
+		return err
+	}
+	return m.putObjectMetadataIndex(ctx, bucket, key, metadata)
+}
+
+// putObjectMetadataIndex replaces the indexed user metadata of an object.
+func (m *Metadata) putObjectMetadataIndex(ctx context.Context, bucket, key string, metadata map[string]string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceObjectMetadataIndex(ctx, tx, bucket, key, metadata); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// replaceObjectMetadataIndex replaces the indexed user metadata of an object
+// within tx.
+func replaceObjectMetadataIndex(ctx context.Context, tx *metadataTx, bucket, key string, metadata map[string]string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM object_metadata WHERE bucket = ? AND key = ?`, bucket, key); err != nil {
+		return err
+	}
+	for name, value := range metadata {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO object_metadata (bucket, key, meta_key, meta_value)
+			VALUES (?, ?, ?, ?)
+		`, bucket, key, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteObject deletes object metadata.
+func (m *Metadata) DeleteObject(ctx context.Context, bucket, key string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM object_parts WHERE bucket = ? AND key = ?`,
+		`DELETE FROM object_verifications WHERE bucket = ? AND key = ?`,
+		`DELETE FROM object_compression WHERE bucket = ? AND key = ?`,
+		`DELETE FROM object_encryption WHERE bucket = ? AND key = ? AND version_id = ''`,
+		`DELETE FROM object_metadata WHERE bucket = ? AND key = ?`,
+		`DELETE FROM object_append_digests WHERE bucket = ? AND key = ?`,
+		`DELETE FROM object_content_md5 WHERE bucket = ? AND key = ?`,
+		`DELETE FROM objects WHERE bucket = ? AND key = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, bucket, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PutObjectParts stores the part layout of an object created by multipart upload.
+func (m *Metadata) PutObjectParts(ctx context.Context, bucket, key string, parts []ObjectPart) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM object_parts WHERE bucket = ? AND key = ?`, bucket, key); err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO object_parts (bucket, key, part_number, part_offset, size, etag, checksum_algorithm, checksum)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, bucket, key, part.PartNumber, part.Offset, part.Size, part.ETag, part.ChecksumAlgorithm, part.Checksum); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetObjectParts returns the part layout of an object ordered by part number,
+// or nil if the object was not created by multipart upload.
+func (m *Metadata) GetObjectParts(ctx context.Context, bucket, key string) ([]ObjectPart, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT part_number, part_offset, size, etag, checksum_algorithm, checksum FROM object_parts
+		WHERE bucket = ? AND key = ?
+		ORDER BY part_number
+	`, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []ObjectPart
+	for rows.Next() {
+		var part ObjectPart
+		if err := rows.Scan(&part.PartNumber, &part.Offset, &part.Size, &part.ETag, &part.ChecksumAlgorithm, &part.Checksum); err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, rows.Err()
+}
+
+// PutObjectVerification records when an object was last verified. corruption
+// describes the mismatch found, or is empty if the object was intact.
+func (m *Metadata) PutObjectVerification(ctx context.Context, bucket, key string, verifiedAt time.Time, corruption string) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO object_verifications (bucket, key, verified_at, corruption)
+		SELECT bucket, key, ?, ? FROM objects WHERE bucket = ? AND key = ?
+	`, verifiedAt.UnixNano(), corruption, bucket, key)
+	return err
+}
+
+// GetObjectVerification returns when an object was last verified and the
+// corruption found then. The time is zero if the object was never verified.
+func (m *Metadata) GetObjectVerification(ctx context.Context, bucket, key string) (time.Time, string, error) {
+	var verifiedAt int64
+	var corruption string
+	err := m.db.QueryRowContext(ctx, `
+		SELECT verified_at, corruption FROM object_verifications WHERE bucket = ? AND key = ?
+	`, bucket, key).Scan(&verifiedAt, &corruption)
+	if err == sql.ErrNoRows {
+		return time.Time{}, "", nil
+	}
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return time.Unix(0, verifiedAt).UTC(), corruption, nil
+}
+
+// GetObjectCompression returns the algorithm an object is compressed with at
+// rest, or "" if it is stored uncompressed.
+func (m *Metadata) GetObjectCompression(ctx context.Context, bucket, key string) (string, error) {
+	var algorithm string
+	err := m.db.QueryRowContext(ctx, `
+		SELECT algorithm FROM object_compression WHERE bucket = ? AND key = ?
+	`, bucket, key).Scan(&algorithm)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return algorithm, err
+}
+
+// PutAppendDigest records the MD5 state of an appendable object with the
+// ETag and size it was computed for, so that appends hash only their data.
+func (m *Metadata) PutAppendDigest(ctx context.Context, bucket, key, etag string, size int64, state []byte) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO object_append_digests (bucket, key, etag, size, state)
+		VALUES (?, ?, ?, ?, ?)
+	`, bucket, key, etag, size, state)
+	return err
+}
+
+// GetAppendDigest returns the MD5 state of an appendable object if it was
+// recorded for the given ETag and size, or nil.
+func (m *Metadata) GetAppendDigest(ctx context.Context, bucket, key, etag string, size int64) ([]byte, error) {
+	var state []byte
+	err := m.db.QueryRowContext(ctx, `
+		SELECT state FROM object_append_digests WHERE bucket = ? AND key = ? AND etag = ? AND size = ?
+	`, bucket, key, etag, size).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return state, err
+}
+
+// PutContentMD5 records the hex content MD5 of an object with the given ETag.
+func (m *Metadata) PutContentMD5(ctx context.Context, bucket, key, etag, sum string) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO object_content_md5 (bucket, key, etag, md5)
+		VALUES (?, ?, ?, ?)
+	`, bucket, key, etag, sum)
+	return err
+}
+
+// GetContentMD5 returns the hex content MD5 of an object if it was recorded
+// for the given ETag, or "".
+func (m *Metadata) GetContentMD5(ctx context.Context, bucket, key, etag string) (string, error) {
+	var sum string
+	err := m.db.QueryRowContext(ctx, `
+		SELECT md5 FROM object_content_md5 WHERE bucket = ? AND key = ? AND etag = ?
+	`, bucket, key, etag).Scan(&sum)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return sum, err
+}
+
+// PutObjectEncryption records how an object, or a version of it, is
+// encrypted at rest.
+func (m *Metadata) PutObjectEncryption(ctx context.Context, bucket, key, versionID string, enc *ObjectEncryption) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertObjectEncryption(ctx, tx, bucket, key, versionID, enc); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertObjectEncryption records how an object, or a version of it, is
+// encrypted at rest within tx.
+func insertObjectEncryption(ctx context.Context, tx *metadataTx, bucket, key, versionID string, enc *ObjectEncryption) error {
+	encCtx, err := json.Marshal(enc.Context)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT OR REPLACE INTO object_encryption (bucket, key, version_id, algorithm, key_id, context, wrapped_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, bucket, key, versionID, string(enc.Algorithm), enc.KMSKeyID, string(encCtx), enc.WrappedKey)
+	return err
+}
+
+// GetObjectEncryption returns how an object, or a version of it, is
+// encrypted at rest, or nil if it is stored in plaintext.
+func (m *Metadata) GetObjectEncryption(ctx context.Context, bucket, key, versionID string) (*ObjectEncryption, error) {
+	var enc ObjectEncryption
+	var algorithm string
+	var encCtx sql.NullString
+	err := m.db.QueryRowContext(ctx, `
+		SELECT algorithm, key_id, context, wrapped_key FROM object_encryption
+		WHERE bucket = ? AND key = ? AND version_id = ?
+	`, bucket, key, versionID).Scan(&algorithm, &enc.KMSKeyID, &encCtx, &enc.WrappedKey)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	enc.Algorithm = SSEAlgorithm(algorithm)
+	if encCtx.Valid && encCtx.String != "" {
+		if err := json.Unmarshal([]byte(encCtx.String), &enc.Context); err != nil {
+			return nil, err
+		}
+	}
+	return &enc, nil
+}
+
+// objectEncryptionRef is an encrypted object or version listed by
+// ListObjectEncryption.
+type objectEncryptionRef struct {
+	Bucket     string
+	Key        string
+	VersionID  string
+	Encryption *ObjectEncryption
+}
+
+// ListObjectEncryption returns the encrypted objects and versions of bucket
+// whose data keys are wrapped with keyID. Empty arguments match all.
+func (m *Metadata) ListObjectEncryption(ctx context.Context, bucket, keyID string) ([]objectEncryptionRef, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT bucket, key, version_id, algorithm, key_id, context, wrapped_key FROM object_encryption
+		WHERE (? = '' OR bucket = ?) AND (? = '' OR key_id = ?)
+		ORDER BY bucket, key, version_id
+	`, bucket, bucket, keyID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []objectEncryptionRef
+	for rows.Next() {
+		var ref objectEncryptionRef
+		var enc ObjectEncryption
+		var algorithm string
+		var encCtx sql.NullString
+		if err := rows.Scan(&ref.Bucket, &ref.Key, &ref.VersionID, &algorithm, &enc.KMSKeyID, &encCtx, &enc.WrappedKey); err != nil {
+			return nil, err
+		}
+		enc.Algorithm = SSEAlgorithm(algorithm)
+		if encCtx.Valid && encCtx.String != "" {
+			if err := json.Unmarshal([]byte(encCtx.String), &enc.Context); err != nil {
+				return nil, err
+			}
+		}
+		ref.Encryption = &enc
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// UpdateObjectEncryptionKey replaces the wrapped data key of an object or
+// version after it was rewrapped with keyID.
+func (m *Metadata) UpdateObjectEncryptionKey(ctx context.Context, bucket, key, versionID, keyID string, wrappedKey []byte) error {
+	_, err := m.db.ExecContext(ctx, `
+		UPDATE object_encryption SET key_id = ?, wrapped_key = ?
+		WHERE bucket = ? AND key = ? AND version_id = ?
+	`, keyID, wrappedKey, bucket, key, versionID)
+	return err
+}
+
+// PutUploadEncryption records the server-side encryption requested for a
+// multipart upload.
+func (m *Metadata) PutUploadEncryption(ctx context.Context, uploadID string, sse *ServerSideEncryption) error {
+	encCtx, err := json.Marshal(sse.Context)
+	if err != nil {
+		return err
+	}
+	_, err = m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO upload_encryption (upload_id, algorithm, key_id, context)
+		VALUES (?, ?, ?, ?)
+	`, uploadID, string(sse.Algorithm), sse.KMSKeyID, string(encCtx))
+	return err
+}
+
+// GetUploadEncryption returns the server-side encryption requested for a
+// multipart upload, or nil if none was requested.
+func (m *Metadata) GetUploadEncryption(ctx context.Context, uploadID string) (*ServerSideEncryption, error) {
+	var sse ServerSideEncryption
+	var algorithm string
+	var encCtx sql.NullString
+	err := m.db.QueryRowContext(ctx, `
+		SELECT algorithm, key_id, context FROM upload_encryption WHERE upload_id = ?
+	`, uploadID).Scan(&algorithm, &sse.KMSKeyID, &encCtx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sse.Algorithm = SSEAlgorithm(algorithm)
+	if encCtx.Valid && encCtx.String != "" {
+		if err := json.Unmarshal([]byte(encCtx.String), &sse.Context); err != nil {
+			return nil, err
+		}
+	}
+	return &sse, nil
+}
+
+// PutUploadObjectLock records the retention and legal hold requested for a
+// multipart upload.
+func (m *Metadata) PutUploadObjectLock(ctx context.Context, uploadID string, lock *ObjectLock) error {
+	var mode, legalHold string
+	var retainUntilDate *time.Time
+	if lock.Retention != nil {
+		mode = string(lock.Retention.Mode)
+		retainUntilDate = lock.Retention.RetainUntilDate
+	}
+	if lock.LegalHold != nil {
+		legalHold = string(lock.LegalHold.Status)
+	}
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO upload_object_lock (upload_id, mode, retain_until_date, legal_hold)
+		VALUES (?, ?, ?, ?)
+	`, uploadID, mode, retainUntilDate, legalHold)
+	return err
+}
+
+// GetUploadObjectLock returns the retention and legal hold requested for a
+// multipart upload, or nil if none were requested.
+func (m *Metadata) GetUploadObjectLock(ctx context.Context, uploadID string) (*ObjectLock, error) {
+	var mode, legalHold string
+	var retainUntilDate sql.NullTime
+	err := m.db.QueryRowContext(ctx, `
+		SELECT mode, retain_until_date, legal_hold FROM upload_object_lock WHERE upload_id = ?
+	`, uploadID).Scan(&mode, &retainUntilDate, &legalHold)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lock := &ObjectLock{}
+	if mode != "" && retainUntilDate.Valid {
+		lock.Retention = &ObjectRetention{Mode: ObjectLockRetentionMode(mode), RetainUntilDate: &retainUntilDate.Time}
+	}
+	if legalHold != "" {
+		lock.LegalHold = &ObjectLegalHold{Status: ObjectLegalHoldStatus(legalHold)}
+	}
+	return lock, nil
+}
+
+// PutUploadTags records the tags requested for a multipart upload.
+func (m *Metadata) PutUploadTags(ctx context.Context, uploadID string, tags []Tag) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, tag := range tags {
+		_, err = tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO upload_tags (upload_id, tag_key, tag_value)
+			VALUES (?, ?, ?)
+		`, uploadID, tag.Key, tag.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetUploadTags returns the tags requested for a multipart upload.
+func (m *Metadata) GetUploadTags(ctx context.Context, uploadID string) ([]Tag, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT tag_key, tag_value FROM upload_tags WHERE upload_id = ? ORDER BY tag_key
+	`, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.Key, &tag.Value); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// PutBucketCompression stores the compression algorithm of a bucket.
+func (m *Metadata) PutBucketCompression(ctx context.Context, bucket, algorithm string) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO bucket_compression (bucket, algorithm)
+		VALUES (?, ?)
+	`, bucket, algorithm)
+	return err
+}
+
+// GetBucketCompression returns the compression algorithm of a bucket, or ""
+// if the bucket has no compression configuration.
+func (m *Metadata) GetBucketCompression(ctx context.Context, bucket string) (string, error) {
+	var algorithm string
+	err := m.db.QueryRowContext(ctx, `
+		SELECT algorithm FROM bucket_compression WHERE bucket = ?
+	`, bucket).Scan(&algorithm)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return algorithm, err
+}
+
+// DeleteBucketCompression deletes the compression algorithm of a bucket.
+func (m *Metadata) DeleteBucketCompression(ctx context.Context, bucket string) error {
+	_, err := m.db.ExecContext(ctx, `DELETE FROM bucket_compression WHERE bucket = ?`, bucket)
+	return err
+}
+
+// PutBucketSkipUnchanged records whether a bucket skips unchanged overwrites.
+func (m *Metadata) PutBucketSkipUnchanged(ctx context.Context, bucket string, enabled bool) error {
+	if !enabled {
+		_, err := m.db.ExecContext(ctx, `DELETE FROM bucket_skip_unchanged WHERE bucket = ?`, bucket)
+		return err
+	}
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO bucket_skip_unchanged (bucket) VALUES (?)
+	`, bucket)
+	return err
+}
+
+// GetBucketSkipUnchanged reports whether a bucket skips unchanged overwrites.
+func (m *Metadata) GetBucketSkipUnchanged(ctx context.Context, bucket string) (bool, error) {
+	var count int
+	err := m.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM bucket_skip_unchanged WHERE bucket = ?
+	`, bucket).Scan(&count)
+	return count > 0, err
+}
+
+// PutBucketWorm records whether a bucket is in write-once mode.
+func (m *Metadata) PutBucketWorm(ctx context.Context, bucket string, enabled bool) error {
+	if !enabled {
+		_, err := m.db.ExecContext(ctx, `DELETE FROM bucket_worm WHERE bucket = ?`, bucket)
+		return err
+	}
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO bucket_worm (bucket) VALUES (?)
+	`, bucket)
+	return err
+}
+
+// GetBucketWorm reports whether a bucket is in write-once mode.
+func (m *Metadata) GetBucketWorm(ctx context.Context, bucket string) (bool, error) {
+	var count int
+	err := m.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM bucket_worm WHERE bucket = ?
+	`, bucket).Scan(&count)
+	return count > 0, err
+}
+
+// PutBucketVersionRetention stores the number of noncurrent versions per key
+// kept in a bucket.
+func (m *Metadata) PutBucketVersionRetention(ctx context.Context, bucket string, maxNoncurrentVersions int) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO bucket_version_retention (bucket, max_noncurrent_versions)
+		VALUES (?, ?)
+	`, bucket, maxNoncurrentVersions)
+	return err
+}
+
+// GetBucketVersionRetention returns the number of noncurrent versions per key
+// kept in a bucket, or -1 if the bucket keeps all versions.
+func (m *Metadata) GetBucketVersionRetention(ctx context.Context, bucket string) (int, error) {
+	var maxNoncurrentVersions int
+	err := m.db.QueryRowContext(ctx, `
+		SELECT max_noncurrent_versions FROM bucket_version_retention WHERE bucket = ?
+	`, bucket).Scan(&maxNoncurrentVersions)
+	if err == sql.ErrNoRows {
+		return -1, nil
+	}
+	return maxNoncurrentVersions, err
+}
+
+// DeleteBucketVersionRetention makes a bucket keep all versions.
+func (m *Metadata) DeleteBucketVersionRetention(ctx context.Context, bucket string) error {
+	_, err := m.db.ExecContext(ctx, `DELETE FROM bucket_version_retention WHERE bucket = ?`, bucket)
+	return err
+}
+
+// ListExcessVersions returns up to limit versions, oldest first, beyond the
+// noncurrent versions kept per key by the version retention of their
+// buckets. The latest version of a key is its current version. Write-once
+// buckets are skipped, as their versions cannot be deleted.
+func (m *Metadata) ListExcessVersions(ctx context.Context, limit int) ([]versionLocation, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT v.bucket, v.key, v.version_id
+		FROM (
+			SELECT bucket, key, version_id, last_modified,
+				ROW_NUMBER() OVER (PARTITION BY bucket, key ORDER BY last_modified DESC, version_id DESC) AS position
+			FROM object_versions
+			WHERE bucket IN (SELECT bucket FROM bucket_version_retention)
+				AND bucket NOT IN (SELECT bucket FROM bucket_worm)
+		) v
+		JOIN bucket_version_retention r ON r.bucket = v.bucket
+		WHERE v.position > r.max_noncurrent_versions + 1
+		ORDER BY v.last_modified, v.version_id
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var excess []versionLocation
+	for rows.Next() {
+		var loc versionLocation
+		if err := rows.Scan(&loc.Bucket, &loc.Key, &loc.VersionID); err != nil {
+			return nil, err
+		}
+		excess = append(excess, loc)
+	}
+	return excess, rows.Err()
+}
+
+// PutBucketContentTypes stores the content type inference rules of a bucket,
+// replacing the existing ones.
+func (m *Metadata) PutBucketContentTypes(ctx context.Context, bucket string, rules []ContentTypeRule) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM bucket_content_types WHERE bucket = ?`, bucket)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		_, err = tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO bucket_content_types (bucket, extension, content_type)
+			VALUES (?, ?, ?)
+		`, bucket, rule.Extension, rule.ContentType)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBucketContentTypes returns the content type inference rules of a bucket.
+func (m *Metadata) GetBucketContentTypes(ctx context.Context, bucket string) ([]ContentTypeRule, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT extension, content_type FROM bucket_content_types
+		WHERE bucket = ?
+		ORDER BY extension
+	`, bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []ContentTypeRule
+	for rows.Next() {
+		var rule ContentTypeRule
+		if err := rows.Scan(&rule.Extension, &rule.ContentType); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteBucketContentTypes deletes the content type inference rules of a bucket.
+func (m *Metadata) DeleteBucketContentTypes(ctx context.Context, bucket string) error {
+	_, err := m.db.ExecContext(ctx, `DELETE FROM bucket_content_types WHERE bucket = ?`, bucket)
+	return err
+}
+
+// PutBucketDefaultTags replaces the default tags of a bucket.
+func (m *Metadata) PutBucketDefaultTags(ctx context.Context, bucket string, tags []Tag) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM bucket_default_tags WHERE bucket = ?`, bucket)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		_, err = tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO bucket_default_tags (bucket, tag_key, tag_value)
+			VALUES (?, ?, ?)
+		`, bucket, tag.Key, tag.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBucketDefaultTags returns the default tags of a bucket.
+func (m *Metadata) GetBucketDefaultTags(ctx context.Context, bucket string) ([]Tag, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT tag_key, tag_value FROM bucket_default_tags
+		WHERE bucket = ?
+		ORDER BY tag_key
+	`, bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.Key, &tag.Value); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// DeleteBucketDefaultTags deletes the default tags of a bucket.
+func (m *Metadata) DeleteBucketDefaultTags(ctx context.Context, bucket string) error {
+	_, err := m.db.ExecContext(ctx, `DELETE FROM bucket_default_tags WHERE bucket = ?`, bucket)
+	return err
+}
+
+// PutBucketResponseHeaders stores the response header policy for a bucket.
+func (m *Metadata) PutBucketResponseHeaders(ctx context.Context, bucket string, headersConfig string) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO bucket_response_headers (bucket, headers_config)
+		VALUES (?, ?)
+	`, bucket, headersConfig)
+	return err
+}
+
+// GetBucketResponseHeaders returns the response header policy for a bucket.
+func (m *Metadata) GetBucketResponseHeaders(ctx context.Context, bucket string) (string, error) {
+	var headersConfig string
+	err := m.db.QueryRowContext(ctx, `
+		SELECT headers_config FROM bucket_response_headers WHERE bucket = ?
+	`, bucket).Scan(&headersConfig)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return headersConfig, nil
+}
+
+// DeleteBucketResponseHeaders deletes the response header policy for a bucket.
+func (m *Metadata) DeleteBucketResponseHeaders(ctx context.Context, bucket string) error {
+	_, err := m.db.ExecContext(ctx, `DELETE FROM bucket_response_headers WHERE bucket = ?`, bucket)
+	return err
+}
+
+// ListObjectsToVerify returns up to limit objects of all buckets that were not
+// verified since verifiedBefore, never verified objects first and then the
+// least recently verified ones.
+func (m *Metadata) ListObjectsToVerify(ctx context.Context, verifiedBefore time.Time, limit int) ([]ObjectLocation, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT o.bucket, o.key FROM objects o
+		LEFT JOIN object_verifications v ON v.bucket = o.bucket AND v.key = o.key
+		WHERE v.verified_at IS NULL OR v.verified_at < ?
+		ORDER BY COALESCE(v.verified_at, 0), o.bucket, o.key
+		LIMIT ?
+	`, verifiedBefore.UnixNano(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []ObjectLocation
+	for rows.Next() {
+		var obj ObjectLocation
+		if err := rows.Scan(&obj.Bucket, &obj.Key); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
+}
+
+// CountObjects returns the number of objects in a bucket.
+func (m *Metadata) CountObjects(ctx context.Context, bucket string) (int, error) {
+	var count int
+	err := m.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM objects WHERE bucket = ?`, bucket).Scan(&count)
+	return count, err
+}
+
+// ListObjects returns objects matching a prefix with pagination support.
+// startAfter specifies the key to start after (exclusive).
+// maxKeys limits the number of results (0 means default 1000).
+func (m *Metadata) ListObjects(ctx context.Context, bucket, prefix, startAfter string, maxKeys int32) ([]Object, error) {
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	var rows *sql.Rows
+	var err error
+
+	if startAfter != "" {
+		rows, err = m.db.QueryContext(ctx, `
+			SELECT key, size, last_modified, etag, content_type
+			FROM objects
+			WHERE bucket = ? AND key LIKE ? AND key > ?
+			ORDER BY key
+			LIMIT ?
+		`, bucket, prefix+"%", startAfter, maxKeys+1)
+	} else {
+		rows, err = m.db.QueryContext(ctx, `
+			SELECT key, size, last_modified, etag, content_type
+			FROM objects
+			WHERE bucket = ? AND key LIKE ?
+			ORDER BY key
+			LIMIT ?
+		`, bucket, prefix+"%", maxKeys+1)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []Object
+	for rows.Next() {
+		var obj Object
+		if err := rows.Scan(&obj.Key, &obj.Size, &obj.LastModified, &obj.ETag, &obj.ContentType); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
+}
+
+// ListTaggedObjects returns objects matching a prefix that are tagged with
+// tagKey, and with the value tagValue unless it is nil, with the pagination of
+// ListObjects.
+func (m *Metadata) ListTaggedObjects(ctx context.Context, bucket, prefix, startAfter string, maxKeys int32, tagKey string, tagValue *string) ([]Object, error) {
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	query := `
+		SELECT o.key, o.size, o.last_modified, o.etag, o.content_type
+		FROM objects o
+		JOIN object_tags t ON t.bucket = o.bucket AND t.key = o.key
+		WHERE o.bucket = ? AND o.key LIKE ? AND o.key > ? AND t.tag_key = ?`
+	args := []any{bucket, prefix + "%", startAfter, tagKey}
+	if tagValue != nil {
+		query += ` AND t.tag_value = ?`
+		args = append(args, *tagValue)
+	}
+	query += `
+		ORDER BY o.key
+		LIMIT ?`
+	args = append(args, maxKeys+1)
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []Object
+	for rows.Next() {
+		var obj Object
+		if err := rows.Scan(&obj.Key, &obj.Size, &obj.LastModified, &obj.ETag, &obj.ContentType); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
+}
+
+// ListLifecycleCandidates returns objects meeting the conditions of a
+// lifecycle rule filter, with the pagination of ListObjects. Each tag is matched with a join on
+// object_tags. Unlike listings, the prefix is matched literally and case
+// sensitively, as it decides which objects expire.
+func (m *Metadata) ListLifecycleCandidates(ctx context.Context, bucket string, c lifecycleConditions, startAfter string, maxKeys int32) ([]Object, error) {
+	query := `
+		SELECT o.key, o.size, o.last_modified, o.etag, o.content_type
+		FROM objects o`
+	var args []any
+	for i, tag := range c.tags {
+		alias := fmt.Sprintf("t%d", i)
+		query += fmt.Sprintf(`
+		JOIN object_tags %[1]s ON %[1]s.bucket = o.bucket AND %[1]s.key = o.key AND %[1]s.tag_key = ? AND %[1]s.tag_value = ?`, alias)
+		args = append(args, tag.Key, tag.Value)
+	}
+	query += `
+		WHERE o.bucket = ? AND o.key > ?`
+	args = append(args, bucket, startAfter)
+	if c.prefix != "" {
+		query += ` AND o.key >= ? AND substr(o.key, 1, ?) = ?`
+		args = append(args, c.prefix, utf8.RuneCountInString(c.prefix), c.prefix)
+	}
+	if c.sizeGreaterThan != nil {
+		query += ` AND o.size > ?`
+		args = append(args, *c.sizeGreaterThan)
+	}
+	if c.sizeLessThan != nil {
+		query += ` AND o.size < ?`
+		args = append(args, *c.sizeLessThan)
+	}
+	query += `
+		ORDER BY o.key
+		LIMIT ?`
+	args = append(args, maxKeys+1)
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []Object
+	for rows.Next() {
+		var obj Object
+		if err := rows.Scan(&obj.Key, &obj.Size, &obj.LastModified, &obj.ETag, &obj.ContentType); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
 }
 
-// BucketExists checks if a bucket exists.
-func (m *Metadata) BucketExists(ctx context.Context, name string) (bool, error) {
-	var count int
-	err := m.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM buckets WHERE name = ?`, name).Scan(&count)
+// SearchObjects returns objects matching a prefix that have all the user
+// metadata and the content type of input, with the pagination of
+// ListObjects. Returned objects include their user metadata.
+func (m *Metadata) SearchObjects(ctx context.Context, input *SearchObjectsInput) ([]Object, error) {
+	maxKeys := input.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	query := `
+		SELECT o.key, o.size, o.last_modified, o.etag, o.content_type, o.metadata
+		FROM objects o`
+	var args []any
+	for i, name := range slices.Sorted(maps.Keys(input.Metadata)) {
+		alias := fmt.Sprintf("m%d", i)
+		query += fmt.Sprintf(`
+		JOIN object_metadata %[1]s ON %[1]s.bucket = o.bucket AND %[1]s.key = o.key AND %[1]s.meta_key = ? AND %[1]s.meta_value = ?`, alias)
+		args = append(args, name, input.Metadata[name])
+	}
+	query += `
+		WHERE o.bucket = ? AND o.key LIKE ? AND o.key > ?`
+	args = append(args, input.Bucket, input.Prefix+"%", input.StartAfter)
+	if typePrefix, ok := strings.CutSuffix(input.ContentType, "/*"); ok {
+		// Match any subtype, "image/" <= content_type < "image0"
+		query += ` AND o.content_type >= ? AND o.content_type < ?`
+		args = append(args, typePrefix+"/", typePrefix+"0")
+	} else if input.ContentType != "" {
+		query += ` AND o.content_type = ?`
+		args = append(args, input.ContentType)
+	}
+	query += `
+		ORDER BY o.key
+		LIMIT ?`
+	args = append(args, maxKeys+1)
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return count > 0, nil
+	defer rows.Close()
+
+	var objects []Object
+	for rows.Next() {
+		var obj Object
+		var metadataStr sql.NullString
+		if err := rows.Scan(&obj.Key, &obj.Size, &obj.LastModified, &obj.ETag, &obj.ContentType, &metadataStr); err != nil {
+			return nil, err
+		}
+		if metadataStr.String != "" {
+			if err := json.Unmarshal([]byte(metadataStr.String), &obj.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
 }
 
-// GetBucket returns bucket metadata.
-func (m *Metadata) GetBucket(ctx context.Context, name string) (*Bucket, error) {
-	var bucket Bucket
+// GetBucketUsage returns the usage counters of a bucket.
+func (m *Metadata) GetBucketUsage(ctx context.Context, bucket string) (*BucketUsage, error) {
+	var usage BucketUsage
 	err := m.db.QueryRowContext(ctx, `
-		SELECT name, creation_date FROM buckets WHERE name = ?
-	`, name).Scan(&bucket.Name, &bucket.CreationDate)
+		SELECT objects, bytes, version_bytes, multipart_uploads, multipart_bytes
+		FROM bucket_usage WHERE bucket = ?
+	`, bucket).Scan(&usage.Objects, &usage.Bytes, &usage.VersionBytes, &usage.MultipartUploads, &usage.MultipartBytes)
 	if err == sql.ErrNoRows {
-		return nil, nil
+		// Buckets get counters on their first write
+		return &usage, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &bucket, nil
+	return &usage, nil
+}
+
+// PutUsageRecord stores the usage of a bucket on a day, replacing a record of
+// the same day.
+func (m *Metadata) PutUsageRecord(ctx context.Context, record *UsageRecord) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO bucket_usage_history (bucket, date, objects, bytes, version_bytes, multipart_bytes, physical_bytes, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, record.Bucket, record.Date, record.Objects, record.Bytes, record.VersionBytes, record.MultipartBytes,
+		record.PhysicalBytes, record.RecordedAt.UnixNano())
+	return err
 }
 
-// ListBuckets returns all buckets.
-func (m *Metadata) ListBuckets(ctx context.Context) ([]Bucket, error) {
+// ListUsageRecords returns the usage records of a bucket from the day since
+// on, oldest first.
+func (m *Metadata) ListUsageRecords(ctx context.Context, bucket, since string) ([]UsageRecord, error) {
 	rows, err := m.db.QueryContext(ctx, `
-		SELECT name, creation_date FROM buckets ORDER BY name
-	`)
+		SELECT date, objects, bytes, version_bytes, multipart_bytes, physical_bytes, recorded_at
+		FROM bucket_usage_history
+		WHERE bucket = ? AND date >= ?
+		ORDER BY date
+	`, bucket, since)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var buckets []Bucket
+	var records []UsageRecord
 	for rows.Next() {
-		var bucket Bucket
-		if err := rows.Scan(&bucket.Name, &bucket.CreationDate); err != nil {
+		record := UsageRecord{Bucket: bucket}
+		var recordedAt int64
+		if err := rows.Scan(&record.Date, &record.Objects, &record.Bytes, &record.VersionBytes, &record.MultipartBytes,
+			&record.PhysicalBytes, &recordedAt); err != nil {
 			return nil, err
 		}
-		buckets = append(buckets, bucket)
+		record.RecordedAt = time.Unix(0, recordedAt).UTC()
+		records = append(records, record)
 	}
-	return buckets, rows.Err()
+	return records, rows.Err()
 }
 
-// PutObject stores object metadata.
-func (m *Metadata) PutObject(ctx context.Context, bucket string, obj *Object) error {
-	metadata, err := json.Marshal(obj.Metadata)
-	if err != nil {
-		return err
+// DeleteUsageRecordsBefore deletes the usage records of all buckets of days
+// before the day before.
+func (m *Metadata) DeleteUsageRecordsBefore(ctx context.Context, before string) error {
+	_, err := m.db.ExecContext(ctx, `DELETE FROM bucket_usage_history WHERE date < ?`, before)
+	return err
+}
+
+// PutBucketTrash stores the number of days deleted objects of a bucket are
+// kept in its trash.
+func (m *Metadata) PutBucketTrash(ctx context.Context, bucket string, days int) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO bucket_trash (bucket, days)
+		VALUES (?, ?)
+	`, bucket, days)
+	return err
+}
+
+// GetBucketTrash returns the number of days deleted objects of a bucket are
+// kept in its trash, or 0 if the bucket is not in trash mode.
+func (m *Metadata) GetBucketTrash(ctx context.Context, bucket string) (int, error) {
+	var days int
+	err := m.db.QueryRowContext(ctx, `
+		SELECT days FROM bucket_trash WHERE bucket = ?
+	`, bucket).Scan(&days)
+	if err == sql.ErrNoRows {
+		return 0, nil
 	}
+	return days, err
+}
 
-	// Clean up old retention/legal-hold settings when overwriting object
-	_, _ = m.db.ExecContext(ctx, `DELETE FROM object_retention WHERE bucket = ? AND key = ?`, bucket, obj.Key)
-	_, _ = m.db.ExecContext(ctx, `DELETE FROM object_legal_hold WHERE bucket = ? AND key = ?`, bucket, obj.Key)
+// DeleteBucketTrash takes a bucket out of trash mode. Objects already in its
+// trash are kept until they expire.
+func (m *Metadata) DeleteBucketTrash(ctx context.Context, bucket string) error {
+	_, err := m.db.ExecContext(ctx, `DELETE FROM bucket_trash WHERE bucket = ?`, bucket)
+	return err
+}
 
+// PutTrashedObject records an object moved to the trash of a bucket.
+func (m *Metadata) PutTrashedObject(ctx context.Context, bucket string, obj *trashedObject) error {
+	details, err := json.Marshal(obj.trashDetails)
+	if err != nil {
+		return err
+	}
 	_, err = m.db.ExecContext(ctx, `
-		INSERT OR REPLACE INTO objects (bucket, key, size, last_modified, etag, content_type, metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, bucket, obj.Key, obj.Size, obj.LastModified, obj.ETag, obj.ContentType, string(metadata))
+		INSERT INTO object_trash (bucket, trash_id, key, size, etag, content_type, last_modified, deleted_at, expires_at, details)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, bucket, obj.ID, obj.Key, obj.Size, obj.ETag, obj.ContentType,
+		obj.LastModified.UnixNano(), obj.DeletedAt.UnixNano(), obj.ExpiresAt.UnixNano(), string(details))
 	return err
 }
 
-// GetObject returns object metadata.
-func (m *Metadata) GetObject(ctx context.Context, bucket, key string) (*Object, error) {
-	var obj Object
-	var metadataStr string
+// GetTrashedObject returns an object in the trash of a bucket, or nil if
+// there is no such entry.
+func (m *Metadata) GetTrashedObject(ctx context.Context, bucket, id string) (*trashedObject, error) {
+	var obj trashedObject
+	var lastModified, deletedAt, expiresAt int64
+	var details string
 	err := m.db.QueryRowContext(ctx, `
-		SELECT key, size, last_modified, etag, content_type, metadata
-		FROM objects WHERE bucket = ? AND key = ?
-	`, bucket, key).Scan(&obj.Key, &obj.Size, &obj.LastModified, &obj.ETag, &obj.ContentType, &metadataStr)
+		SELECT trash_id, key, size, etag, COALESCE(content_type, ''), last_modified, deleted_at, expires_at, details
+		FROM object_trash WHERE bucket = ? AND trash_id = ?
+	`, bucket, id).Scan(&obj.ID, &obj.Key, &obj.Size, &obj.ETag, &obj.ContentType, &lastModified, &deletedAt, &expiresAt, &details)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-
-	if metadataStr != "" {
-		if err := json.Unmarshal([]byte(metadataStr), &obj.Metadata); err != nil {
-			return nil, err
-		}
+	obj.LastModified = time.Unix(0, lastModified).UTC()
+	obj.DeletedAt = time.Unix(0, deletedAt).UTC()
+	obj.ExpiresAt = time.Unix(0, expiresAt).UTC()
+	if err := json.Unmarshal([]byte(details), &obj.trashDetails); err != nil {
+		return nil, err
 	}
-
 	return &obj, nil
 }
 
-// DeleteObject deletes object metadata.
-func (m *Metadata) DeleteObject(ctx context.Context, bucket, key string) error {
-	_, err := m.db.ExecContext(ctx, `DELETE FROM objects WHERE bucket = ? AND key = ?`, bucket, key)
-	return err
-}
-
-// CountObjects returns the number of objects in a bucket.
-func (m *Metadata) CountObjects(ctx context.Context, bucket string) (int, error) {
-	var count int
-	err := m.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM objects WHERE bucket = ?`, bucket).Scan(&count)
-	return count, err
-}
-
-// ListObjects returns objects matching a prefix with pagination support.
-// startAfter specifies the key to start after (exclusive).
-// maxKeys limits the number of results (0 means default 1000).
-func (m *Metadata) ListObjects(ctx context.Context, bucket, prefix, startAfter string, maxKeys int32) ([]Object, error) {
-	if maxKeys <= 0 {
-		maxKeys = 1000
+// ListTrash returns up to limit objects in the trash of a bucket whose keys
+// start with prefix, ordered by key and most recently deleted first.
+func (m *Metadata) ListTrash(ctx context.Context, bucket, prefix string, limit int) ([]TrashEntry, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT trash_id, key, size, etag, COALESCE(content_type, ''), last_modified, deleted_at, expires_at
+		FROM object_trash
+		WHERE bucket = ? AND key LIKE ?
+		ORDER BY key, deleted_at DESC
+		LIMIT ?
+	`, bucket, prefix+"%", limit)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	var rows *sql.Rows
-	var err error
-
-	if startAfter != "" {
-		rows, err = m.db.QueryContext(ctx, `
-			SELECT key, size, last_modified, etag, content_type
-			FROM objects
-			WHERE bucket = ? AND key LIKE ? AND key > ?
-			ORDER BY key
-			LIMIT ?
-		`, bucket, prefix+"%", startAfter, maxKeys+1)
-	} else {
-		rows, err = m.db.QueryContext(ctx, `
-			SELECT key, size, last_modified, etag, content_type
-			FROM objects
-			WHERE bucket = ? AND key LIKE ?
-			ORDER BY key
-			LIMIT ?
-		`, bucket, prefix+"%", maxKeys+1)
+	var entries []TrashEntry
+	for rows.Next() {
+		var entry TrashEntry
+		var lastModified, deletedAt, expiresAt int64
+		if err := rows.Scan(&entry.ID, &entry.Key, &entry.Size, &entry.ETag, &entry.ContentType, &lastModified, &deletedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		entry.LastModified = time.Unix(0, lastModified).UTC()
+		entry.DeletedAt = time.Unix(0, deletedAt).UTC()
+		entry.ExpiresAt = time.Unix(0, expiresAt).UTC()
+		entries = append(entries, entry)
 	}
+	return entries, rows.Err()
+}
 
+// ListExpiredTrash returns up to limit objects, in the trash of any bucket,
+// whose retention ended before now.
+func (m *Metadata) ListExpiredTrash(ctx context.Context, now time.Time, limit int) ([]trashLocation, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT bucket, trash_id FROM object_trash
+		WHERE expires_at <= ?
+		ORDER BY expires_at
+		LIMIT ?
+	`, now.UnixNano(), limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var objects []Object
+	var expired []trashLocation
 	for rows.Next() {
-		var obj Object
-		if err := rows.Scan(&obj.Key, &obj.Size, &obj.LastModified, &obj.ETag, &obj.ContentType); err != nil {
+		var loc trashLocation
+		if err := rows.Scan(&loc.Bucket, &loc.ID); err != nil {
 			return nil, err
 		}
-		objects = append(objects, obj)
+		expired = append(expired, loc)
 	}
-	return objects, rows.Err()
+	return expired, rows.Err()
+}
+
+// DeleteTrashedObject deletes an entry of the trash of a bucket.
+func (m *Metadata) DeleteTrashedObject(ctx context.Context, bucket, id string) error {
+	_, err := m.db.ExecContext(ctx, `DELETE FROM object_trash WHERE bucket = ? AND trash_id = ?`, bucket, id)
+	return err
 }
 
 // CreateMultipartUpload creates a new multipart upload record.
@@ -475,21 +2256,40 @@ func (m *Metadata) CreateMultipartUpload(ctx context.Context, upload *MultipartU
 		return err
 	}
 
-	_, err = m.db.ExecContext(ctx, `
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
 		INSERT INTO multipart_uploads (upload_id, bucket, key, content_type, metadata, initiated)
 		VALUES (?, ?, ?, ?, ?, ?)
-	`, upload.UploadID, upload.Bucket, upload.Key, upload.ContentType, string(metadata), upload.Initiated)
-	return err
+	`, upload.UploadID, upload.Bucket, upload.Key, upload.ContentType, string(metadata), upload.Initiated); err != nil {
+		return err
+	}
+	if upload.ChecksumAlgorithm != "" {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO upload_checksums (upload_id, algorithm) VALUES (?, ?)
+		`, upload.UploadID, upload.ChecksumAlgorithm); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // GetMultipartUpload returns a multipart upload by ID.
 func (m *Metadata) GetMultipartUpload(ctx context.Context, uploadID string) (*MultipartUpload, error) {
 	var upload MultipartUpload
 	var metadataStr string
+	var checksumAlgorithm sql.NullString
 	err := m.db.QueryRowContext(ctx, `
-		SELECT upload_id, bucket, key, content_type, metadata, initiated
-		FROM multipart_uploads WHERE upload_id = ?
-	`, uploadID).Scan(&upload.UploadID, &upload.Bucket, &upload.Key, &upload.ContentType, &metadataStr, &upload.Initiated)
+		SELECT u.upload_id, u.bucket, u.key, u.content_type, u.metadata, u.initiated, c.algorithm, COALESCE(s.state, 'active')
+		FROM multipart_uploads u
+		LEFT JOIN upload_checksums c ON c.upload_id = u.upload_id
+		LEFT JOIN upload_states s ON s.upload_id = u.upload_id
+		WHERE u.upload_id = ?
+	`, uploadID).Scan(&upload.UploadID, &upload.Bucket, &upload.Key, &upload.ContentType, &metadataStr, &upload.Initiated, &checksumAlgorithm, &upload.State)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -502,39 +2302,118 @@ func (m *Metadata) GetMultipartUpload(ctx context.Context, uploadID string) (*Mu
 			return nil, err
 		}
 	}
+	upload.ChecksumAlgorithm = checksumAlgorithm.String
 
 	return &upload, nil
 }
 
 // DeleteMultipartUpload deletes a multipart upload and its parts.
 func (m *Metadata) DeleteMultipartUpload(ctx context.Context, uploadID string) error {
-	// Parts will be deleted by cascade
-	_, err := m.db.ExecContext(ctx, `DELETE FROM multipart_uploads WHERE upload_id = ?`, uploadID)
-	return err
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Parts go first, while their bucket_usage trigger can find the upload
+	if _, err := tx.ExecContext(ctx, `DELETE FROM part_checksums WHERE upload_id = ?`, uploadID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM parts WHERE upload_id = ?`, uploadID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM upload_object_lock WHERE upload_id = ?`, uploadID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM upload_tags WHERE upload_id = ?`, uploadID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM upload_states WHERE upload_id = ?`, uploadID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM multipart_uploads WHERE upload_id = ?`, uploadID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// TransitionUpload moves an upload from one state to another. It reports
+// whether the upload existed and was in state from.
+func (m *Metadata) TransitionUpload(ctx context.Context, uploadID string, from, to UploadState) (bool, error) {
+	result, err := m.db.ExecContext(ctx, `
+		INSERT INTO upload_states (upload_id, state)
+		SELECT u.upload_id, ?
+		FROM multipart_uploads u LEFT JOIN upload_states s ON s.upload_id = u.upload_id
+		WHERE u.upload_id = ? AND COALESCE(s.state, 'active') = ?
+		ON CONFLICT (upload_id) DO UPDATE SET state = excluded.state
+	`, to, uploadID, from)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
 }
 
-// PutPart stores or updates a part.
+// PutPart stores or updates a part and its checksum. It returns
+// ErrUploadNotFound unless the upload is active.
 func (m *Metadata) PutPart(ctx context.Context, uploadID string, part *Part) error {
-	_, err := m.db.ExecContext(ctx, `
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
 		INSERT OR REPLACE INTO parts (upload_id, part_number, size, etag, last_modified)
-		VALUES (?, ?, ?, ?, ?)
-	`, uploadID, part.PartNumber, part.Size, part.ETag, part.LastModified)
-	return err
+		SELECT u.upload_id, ?, ?, ?, ?
+		FROM multipart_uploads u LEFT JOIN upload_states s ON s.upload_id = u.upload_id
+		WHERE u.upload_id = ? AND COALESCE(s.state, 'active') = 'active'
+	`, part.PartNumber, part.Size, part.ETag, part.LastModified, uploadID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUploadNotFound
+	}
+	if part.Checksum != "" {
+		_, err = tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO part_checksums (upload_id, part_number, algorithm, checksum)
+			VALUES (?, ?, ?, ?)
+		`, uploadID, part.PartNumber, part.ChecksumAlgorithm, part.Checksum)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM part_checksums WHERE upload_id = ? AND part_number = ?
+		`, uploadID, part.PartNumber)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // GetPart returns a specific part.
 func (m *Metadata) GetPart(ctx context.Context, uploadID string, partNumber int32) (*Part, error) {
 	var part Part
+	var checksumAlgorithm, checksum sql.NullString
 	err := m.db.QueryRowContext(ctx, `
-		SELECT part_number, size, etag, last_modified
-		FROM parts WHERE upload_id = ? AND part_number = ?
-	`, uploadID, partNumber).Scan(&part.PartNumber, &part.Size, &part.ETag, &part.LastModified)
+		SELECT p.part_number, p.size, p.etag, p.last_modified, c.algorithm, c.checksum
+		FROM parts p LEFT JOIN part_checksums c ON c.upload_id = p.upload_id AND c.part_number = p.part_number
+		WHERE p.upload_id = ? AND p.part_number = ?
+	`, uploadID, partNumber).Scan(&part.PartNumber, &part.Size, &part.ETag, &part.LastModified, &checksumAlgorithm, &checksum)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
+	part.ChecksumAlgorithm, part.Checksum = checksumAlgorithm.String, checksum.String
 	return &part, nil
 }
 
@@ -545,10 +2424,10 @@ func (m *Metadata) ListParts(ctx context.Context, uploadID string, maxParts int3
 	}
 
 	rows, err := m.db.QueryContext(ctx, `
-		SELECT part_number, size, etag, last_modified
-		FROM parts
-		WHERE upload_id = ? AND part_number > ?
-		ORDER BY part_number
+		SELECT p.part_number, p.size, p.etag, p.last_modified, c.algorithm, c.checksum
+		FROM parts p LEFT JOIN part_checksums c ON c.upload_id = p.upload_id AND c.part_number = p.part_number
+		WHERE p.upload_id = ? AND p.part_number > ?
+		ORDER BY p.part_number
 		LIMIT ?
 	`, uploadID, partNumberMarker, maxParts+1)
 	if err != nil {
@@ -559,9 +2438,11 @@ func (m *Metadata) ListParts(ctx context.Context, uploadID string, maxParts int3
 	var parts []Part
 	for rows.Next() {
 		var part Part
-		if err := rows.Scan(&part.PartNumber, &part.Size, &part.ETag, &part.LastModified); err != nil {
+		var checksumAlgorithm, checksum sql.NullString
+		if err := rows.Scan(&part.PartNumber, &part.Size, &part.ETag, &part.LastModified, &checksumAlgorithm, &checksum); err != nil {
 			return nil, false, 0, err
 		}
+		part.ChecksumAlgorithm, part.Checksum = checksumAlgorithm.String, checksum.String
 		parts = append(parts, part)
 	}
 
@@ -579,6 +2460,23 @@ func (m *Metadata) ListParts(ctx context.Context, uploadID string, maxParts int3
 	return parts, isTruncated, nextMarker, nil
 }
 
+// DeletePart deletes a part of a multipart upload and its checksum.
+func (m *Metadata) DeletePart(ctx context.Context, uploadID string, partNumber int32) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM part_checksums WHERE upload_id = ? AND part_number = ?`, uploadID, partNumber); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM parts WHERE upload_id = ? AND part_number = ?`, uploadID, partNumber); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // DeleteParts deletes all parts for a multipart upload.
 func (m *Metadata) DeleteParts(ctx context.Context, uploadID string) error {
 	_, err := m.db.ExecContext(ctx, `DELETE FROM parts WHERE upload_id = ?`, uploadID)
@@ -821,6 +2719,48 @@ func (m *Metadata) GetBucketVersioning(ctx context.Context, bucket string) (stri
 	return status, nil
 }
 
+// PutBucketMfaDelete sets the MFA delete status for a bucket.
+func (m *Metadata) PutBucketMfaDelete(ctx context.Context, bucket, status string) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO bucket_mfa_delete (bucket, status)
+		VALUES (?, ?)
+	`, bucket, status)
+	return err
+}
+
+// GetBucketMfaDelete returns the MFA delete status for a bucket.
+func (m *Metadata) GetBucketMfaDelete(ctx context.Context, bucket string) (string, error) {
+	var status string
+	err := m.db.QueryRowContext(ctx, `
+		SELECT status FROM bucket_mfa_delete WHERE bucket = ?
+	`, bucket).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// GetBucketVersioningConfiguration returns the versioning and MFA delete
+// statuses of a bucket, read together so that they are consistent.
+func (m *Metadata) GetBucketVersioningConfiguration(ctx context.Context, bucket string) (*VersioningConfiguration, error) {
+	var status, mfaDelete string
+	err := m.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE((SELECT status FROM bucket_versioning WHERE bucket = ?), ''),
+			COALESCE((SELECT status FROM bucket_mfa_delete WHERE bucket = ?), '')
+	`, bucket, bucket).Scan(&status, &mfaDelete)
+	if err != nil {
+		return nil, err
+	}
+	return &VersioningConfiguration{
+		Status:    VersioningStatus(status),
+		MfaDelete: MfaDeleteStatus(mfaDelete),
+	}, nil
+}
+
 // PutObjectVersion stores a new version of an object.
 func (m *Metadata) PutObjectVersion(ctx context.Context, bucket string, version *ObjectVersion) error {
 	metadata, err := json.Marshal(version.Metadata)
@@ -867,7 +2807,7 @@ func (m *Metadata) GetLatestObjectVersion(ctx context.Context, bucket, key strin
 	err := m.db.QueryRowContext(ctx, `
 		SELECT key, version_id, size, last_modified, etag, content_type, metadata, is_delete_marker
 		FROM object_versions WHERE bucket = ? AND key = ?
-		ORDER BY last_modified DESC LIMIT 1
+		ORDER BY last_modified DESC, version_id DESC LIMIT 1
 	`, bucket, key).Scan(&version.Key, &version.VersionID, &version.Size, &version.LastModified, &version.ETag, &version.ContentType, &metadataStr, &version.IsDeleteMarker)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -887,6 +2827,7 @@ func (m *Metadata) GetLatestObjectVersion(ctx context.Context, bucket, key strin
 
 // DeleteObjectVersion deletes a specific version of an object.
 func (m *Metadata) DeleteObjectVersion(ctx context.Context, bucket, key, versionID string) error {
+	_, _ = m.db.ExecContext(ctx, `DELETE FROM object_encryption WHERE bucket = ? AND key = ? AND version_id = ?`, bucket, key, versionID)
 	_, err := m.db.ExecContext(ctx, `DELETE FROM object_versions WHERE bucket = ? AND key = ? AND version_id = ?`, bucket, key, versionID)
 	return err
 }
@@ -905,18 +2846,23 @@ func (m *Metadata) ListObjectVersions(ctx context.Context, bucket, prefix string
 			SELECT key, version_id, size, last_modified, etag, content_type, metadata, is_delete_marker
 			FROM object_versions
 			WHERE bucket = ? AND key LIKE ?
-			ORDER BY key, last_modified DESC
+			ORDER BY key, last_modified DESC, version_id DESC
 			LIMIT ?
 		`, bucket, prefix+"%", maxKeys+1)
 	} else {
+		// Listing continues after the marker version in the order of the
+		// versions of its key, or after the key without a version marker
 		rows, err = m.db.QueryContext(ctx, `
-			SELECT key, version_id, size, last_modified, etag, content_type, metadata, is_delete_marker
-			FROM object_versions
-			WHERE bucket = ? AND key LIKE ?
-			  AND (key > ? OR (key = ? AND version_id > ?))
-			ORDER BY key, last_modified DESC
-			LIMIT ?
-		`, bucket, prefix+"%", keyMarker, keyMarker, versionIDMarker, maxKeys+1)
+			SELECT v.key, v.version_id, v.size, v.last_modified, v.etag, v.content_type, v.metadata, v.is_delete_marker
+			FROM object_versions v
+			LEFT JOIN object_versions m ON m.bucket = v.bucket AND m.key = ?3 AND m.version_id = ?4
+			WHERE v.bucket = ?1 AND v.key LIKE ?2
+			  AND (v.key > ?3 OR (v.key = ?3 AND (
+				v.last_modified < m.last_modified
+				OR (v.last_modified = m.last_modified AND v.version_id < m.version_id))))
+			ORDER BY v.key, v.last_modified DESC, v.version_id DESC
+			LIMIT ?5
+		`, bucket, prefix+"%", keyMarker, versionIDMarker, maxKeys+1)
 	}
 
 	if err != nil {
@@ -1248,6 +3194,36 @@ func (m *Metadata) DeleteBucketWebsite(ctx context.Context, bucket string) error
 	return err
 }
 
+// PutBucketTiering stores the tiering configuration for a bucket.
+func (m *Metadata) PutBucketTiering(ctx context.Context, bucket string, tieringConfig string) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO bucket_tiering (bucket, tiering_config)
+		VALUES (?, ?)
+	`, bucket, tieringConfig)
+	return err
+}
+
+// GetBucketTiering returns the tiering configuration for a bucket.
+func (m *Metadata) GetBucketTiering(ctx context.Context, bucket string) (string, error) {
+	var tieringConfig string
+	err := m.db.QueryRowContext(ctx, `
+		SELECT tiering_config FROM bucket_tiering WHERE bucket = ?
+	`, bucket).Scan(&tieringConfig)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return tieringConfig, nil
+}
+
+// DeleteBucketTiering deletes the tiering configuration for a bucket.
+func (m *Metadata) DeleteBucketTiering(ctx context.Context, bucket string) error {
+	_, err := m.db.ExecContext(ctx, `DELETE FROM bucket_tiering WHERE bucket = ?`, bucket)
+	return err
+}
+
 // Close closes the database connection.
 func (m *Metadata) Close() error {
 	return m.db.Close()
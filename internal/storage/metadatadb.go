@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kumasuke/jog/internal/metrics"
+)
+
+// SQLite result codes of lock contention (the primary code is the low byte
+// of extended codes such as SQLITE_BUSY_SNAPSHOT).
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// maxBusyRetries is the number of times a statement outside a transaction is
+// retried after SQLITE_BUSY, once the busy timeout of the connection expired.
+const maxBusyRetries = 3
+
+// busyRetryDelay is the delay before the first retry; it doubles on every retry.
+const busyRetryDelay = 10 * time.Millisecond
+
+var (
+	metadataBusyRetries = metrics.NewCounter("jog_metadata_busy_retries_total",
+		"Metadata statements retried after SQLITE_BUSY.")
+	metadataBusyErrors = metrics.NewCounter("jog_metadata_busy_errors_total",
+		"Metadata statements that failed with SQLITE_BUSY after all retries.")
+	metadataSlowQueries = metrics.NewCounter("jog_metadata_slow_queries_total",
+		"Metadata statements slower than storage.slow_query_threshold.")
+)
+
+// SlowQuery is a metadata statement that took longer than the slow query
+// threshold.
+type SlowQuery struct {
+	// Statement names the statement by its verb and table, e.g. "select objects".
+	Statement string
+	Query     string
+	Duration  time.Duration
+}
+
+// metadataDB wraps the metadata database to record the latency of every
+// statement, per statement name, and to retry statements failing with
+// SQLITE_BUSY. The latency of a query covers its first row.
+type metadataDB struct {
+	*sql.DB
+
+	slowThreshold time.Duration
+	slowLog       func(SlowQuery)
+}
+
+// metadataTx is a transaction of a metadataDB. Its statements are not
+// retried, since a busy transaction must be restarted as a whole.
+type metadataTx struct {
+	*sql.Tx
+	db *metadataDB
+}
+
+func newMetadataDB(db *sql.DB) *metadataDB {
+	return &metadataDB{DB: db}
+}
+
+// SetSlowQueryLog makes statements taking threshold or longer be passed to
+// logf. A zero threshold disables the slow query log.
+func (fs *FileSystem) SetSlowQueryLog(threshold time.Duration, logf func(SlowQuery)) {
+	fs.metadata.db.slowThreshold = threshold
+	fs.metadata.db.slowLog = logf
+}
+
+func (db *metadataDB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+func (db *metadataDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := db.retry(ctx, query, func() error {
+		var err error
+		result, err = db.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+func (db *metadataDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := db.retry(ctx, query, func() error {
+		var err error
+		rows, err = db.DB.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (db *metadataDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	var row *sql.Row
+	db.retry(ctx, query, func() error {
+		row = db.DB.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+	return row
+}
+
+func (db *metadataDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*metadataTx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &metadataTx{Tx: tx, db: db}, nil
+}
+
+func (tx *metadataTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := tx.Tx.ExecContext(ctx, query, args...)
+	tx.db.observe(query, time.Since(start), err)
+	return result, err
+}
+
+func (tx *metadataTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	tx.db.observe(query, time.Since(start), err)
+	return rows, err
+}
+
+func (tx *metadataTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	tx.db.observe(query, time.Since(start), row.Err())
+	return row
+}
+
+// retry runs a statement, retrying it with exponential backoff while it
+// fails with SQLITE_BUSY, and records its latency.
+func (db *metadataDB) retry(ctx context.Context, query string, run func() error) error {
+	delay := busyRetryDelay
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err := run()
+		db.observe(query, time.Since(start), err)
+		if !isBusy(err) || attempt == maxBusyRetries {
+			return err
+		}
+
+		metadataBusyRetries.Inc()
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// observe records the latency of a statement and counts busy errors and
+// slow statements.
+func (db *metadataDB) observe(query string, d time.Duration, err error) {
+	stmt := statementMetrics(query)
+	stmt.duration.Observe(d)
+	if isBusy(err) {
+		metadataBusyErrors.Inc()
+	}
+	if db.slowThreshold > 0 && d >= db.slowThreshold {
+		metadataSlowQueries.Inc()
+		if db.slowLog != nil {
+			db.slowLog(SlowQuery{Statement: stmt.name, Query: strings.Join(strings.Fields(query), " "), Duration: d})
+		}
+	}
+}
+
+// isBusy reports whether err is SQLITE_BUSY or SQLITE_LOCKED.
+func isBusy(err error) bool {
+	var sqliteErr interface{ Code() int }
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff
+	return code == sqliteBusy || code == sqliteLocked
+}
+
+// statement holds the name and latency metric of a statement.
+type statement struct {
+	name     string
+	duration *metrics.Summary
+}
+
+// statements caches the statement of each query text.
+var statements sync.Map
+
+// statementMetrics returns the statement of a query, registering its latency
+// metric on first use.
+func statementMetrics(query string) *statement {
+	if stmt, ok := statements.Load(query); ok {
+		return stmt.(*statement)
+	}
+	name := statementName(query)
+	stmt := &statement{
+		name: name,
+		duration: metrics.NewSummary("jog_metadata_query_duration_seconds",
+			"Latency of metadata statements by statement.", "statement", name),
+	}
+	actual, _ := statements.LoadOrStore(query, stmt)
+	return actual.(*statement)
+}
+
+// statementName names a statement by its verb and the table it works on,
+// e.g. "select objects" or "insert bucket_tags", so that statements on the
+// same table share a metric.
+func statementName(query string) string {
+	fields := strings.Fields(strings.ToLower(query))
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	verb := fields[0]
+
+	var rest []string
+	switch verb {
+	case "select", "delete", "with":
+		rest = fieldsAfter(fields, "from")
+	case "insert", "replace":
+		rest = fieldsAfter(fields, "into")
+	case "update", "create":
+		rest = fields[1:]
+	}
+	for _, field := range rest {
+		if statementKeywords[field] {
+			continue
+		}
+		// Strip the column list or punctuation following the table name
+		field = strings.TrimLeft(field, "(")
+		if i := strings.IndexAny(field, "(),;"); i >= 0 {
+			field = field[:i]
+		}
+		if field != "" {
+			return verb + " " + field
+		}
+	}
+	return verb
+}
+
+// statementKeywords are the keywords that may precede the table name of an
+// UPDATE or CREATE statement.
+var statementKeywords = map[string]bool{
+	"or": true, "abort": true, "fail": true, "ignore": true, "replace": true, "rollback": true,
+	"table": true, "index": true, "unique": true, "temp": true, "temporary": true,
+	"if": true, "not": true, "exists": true,
+}
+
+// fieldsAfter returns the fields following the first occurrence of keyword.
+func fieldsAfter(fields []string, keyword string) []string {
+	for i, field := range fields {
+		if field == keyword {
+			return fields[i+1:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStatementName(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT key, size FROM objects WHERE bucket = ?", "select objects"},
+		{"\n\t\tSELECT COUNT(*) FROM bucket_skip_unchanged WHERE bucket = ?", "select bucket_skip_unchanged"},
+		{"INSERT OR REPLACE INTO bucket_tags (bucket, tag_key, tag_value) VALUES (?, ?, ?)", "insert bucket_tags"},
+		{"INSERT INTO parts(upload_id) VALUES (?)", "insert parts"},
+		{"UPDATE OR IGNORE objects SET etag = ?", "update objects"},
+		{"DELETE FROM object_tags WHERE bucket = ?", "delete object_tags"},
+		{"CREATE TABLE IF NOT EXISTS buckets (name TEXT PRIMARY KEY)", "create buckets"},
+		{"CREATE INDEX IF NOT EXISTS idx_objects_bucket ON objects(bucket)", "create idx_objects_bucket"},
+		{"PRAGMA wal_checkpoint(TRUNCATE)", "pragma"},
+		{"", "unknown"},
+	}
+	for _, tt := range tests {
+		if got := statementName(tt.query); got != tt.want {
+			t.Errorf("statementName(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	fs := newTestFileSystem(t)
+	ctx := context.Background()
+
+	var slow []SlowQuery
+	fs.SetSlowQueryLog(time.Nanosecond, func(q SlowQuery) {
+		slow = append(slow, q)
+	})
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatal(err)
+	}
+	fs.SetSlowQueryLog(0, nil)
+
+	if len(slow) == 0 {
+		t.Fatal("no slow queries logged with a threshold of 1ns")
+	}
+	if slow[0].Statement == "" || slow[0].Query == "" || slow[0].Duration <= 0 {
+		t.Errorf("slow query = %+v", slow[0])
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StaleUploadsResult summarizes an AbortStaleUploads run.
+type StaleUploadsResult struct {
+	// Aborted is the number of aborted uploads.
+	Aborted int
+	// ReclaimedBytes is the total size of the parts of the aborted uploads.
+	ReclaimedBytes int64
+}
+
+// AbortStaleUploads aborts the multipart uploads of all buckets that were
+// initiated before now minus olderThan, regardless of bucket lifecycle rules.
+// Errors for single uploads are collected and the remaining uploads are still
+// processed.
+func AbortStaleUploads(ctx context.Context, s Storage, olderThan time.Duration) (StaleUploadsResult, error) {
+	var result StaleUploadsResult
+	cutoff := time.Now().Add(-olderThan)
+
+	// Without an owner in the context every bucket is listed
+	buckets, err := s.ListBuckets(WithOwner(ctx, ""))
+	if err != nil {
+		return result, err
+	}
+
+	var errs []error
+	for _, bucket := range buckets {
+		input := &ListMultipartUploadsInput{Bucket: bucket.Name, MaxUploads: 1000}
+		for {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			output, err := s.ListMultipartUploads(ctx, input)
+			if errors.Is(err, ErrBucketNotFound) {
+				// Deleted while the cleanup was running
+				break
+			}
+			if err != nil {
+				return result, err
+			}
+
+			for _, upload := range output.Uploads {
+				if !upload.Initiated.Before(cutoff) {
+					continue
+				}
+				size, err := uploadSize(ctx, s, bucket.Name, upload)
+				if err == nil {
+					err = s.AbortMultipartUpload(ctx, bucket.Name, upload.Key, upload.UploadID)
+				}
+				if errors.Is(err, ErrUploadNotFound) || errors.Is(err, ErrUploadCompleting) {
+					// Completed or aborted concurrently
+					continue
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to abort upload %s of %s/%s: %w", upload.UploadID, bucket.Name, upload.Key, err))
+					continue
+				}
+				result.Aborted++
+				result.ReclaimedBytes += size
+			}
+
+			if !output.IsTruncated {
+				break
+			}
+			input.KeyMarker = output.NextKeyMarker
+			input.UploadIdMarker = output.NextUploadIdMarker
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// uploadSize returns the total size of the parts of an upload.
+func uploadSize(ctx context.Context, s Storage, bucket string, upload MultipartUpload) (int64, error) {
+	var size int64
+	input := &ListPartsInput{Bucket: bucket, Key: upload.Key, UploadID: upload.UploadID, MaxParts: 1000}
+	for {
+		output, err := s.ListParts(ctx, input)
+		if err != nil {
+			return 0, err
+		}
+		for _, part := range output.Parts {
+			size += part.Size
+		}
+		if !output.IsTruncated {
+			return size, nil
+		}
+		input.PartNumberMarker = output.NextPartNumberMarker
+	}
+}
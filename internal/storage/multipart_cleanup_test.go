@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAbortStaleUploads(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	var uploads []*MultipartUpload
+	for _, bucket := range []string{"first", "second"} {
+		if err := fs.CreateBucket(ctx, bucket); err != nil {
+			t.Fatalf("CreateBucket: %v", err)
+		}
+		upload, err := fs.CreateMultipartUpload(ctx, bucket, "big.bin", "application/octet-stream", nil)
+		if err != nil {
+			t.Fatalf("CreateMultipartUpload: %v", err)
+		}
+		if _, err := fs.UploadPart(ctx, bucket, "big.bin", upload.UploadID, 1, strings.NewReader("part-data"), 9); err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+		uploads = append(uploads, upload)
+	}
+
+	// Fresh uploads are kept
+	result, err := AbortStaleUploads(ctx, fs, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("AbortStaleUploads: %v", err)
+	}
+	if result.Aborted != 0 {
+		t.Errorf("aborted %d fresh uploads, want 0", result.Aborted)
+	}
+
+	// A negative age moves the cutoff into the future, so every upload is stale
+	result, err = AbortStaleUploads(ctx, fs, -time.Minute)
+	if err != nil {
+		t.Fatalf("AbortStaleUploads: %v", err)
+	}
+	if result.Aborted != 2 || result.ReclaimedBytes != 18 {
+		t.Errorf("result = %+v, want 2 uploads and 18 bytes", result)
+	}
+
+	for _, upload := range uploads {
+		_, err := fs.ListParts(ctx, &ListPartsInput{Bucket: upload.Bucket, Key: upload.Key, UploadID: upload.UploadID})
+		if !errors.Is(err, ErrUploadNotFound) {
+			t.Errorf("ListParts after cleanup: got %v, want ErrUploadNotFound", err)
+		}
+	}
+}
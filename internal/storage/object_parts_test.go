@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestObjectPartLayout(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	upload, err := fs.CreateMultipartUpload(ctx, "bucket", "big.bin", "application/octet-stream", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	var completed []Part
+	for i, data := range []string{"aaaa", "bb", "cccccc"} {
+		part, err := fs.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, int32(i+1), strings.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+		completed = append(completed, Part{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, completed); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+
+	parts, err := fs.GetObjectParts(ctx, "bucket", "big.bin")
+	if err != nil {
+		t.Fatalf("GetObjectParts: %v", err)
+	}
+	want := []struct {
+		offset, size int64
+	}{{0, 4}, {4, 2}, {6, 6}}
+	if len(parts) != len(want) {
+		t.Fatalf("got %d parts, want %d", len(parts), len(want))
+	}
+	for i, part := range parts {
+		if part.PartNumber != int32(i+1) || part.Offset != want[i].offset || part.Size != want[i].size {
+			t.Errorf("part %d = %+v, want offset %d size %d", i+1, part, want[i].offset, want[i].size)
+		}
+		if part.ETag != completed[i].ETag {
+			t.Errorf("part %d ETag = %q, want %q", i+1, part.ETag, completed[i].ETag)
+		}
+	}
+
+	// Overwriting with a single PUT drops the layout
+	if _, err := fs.PutObject(ctx, "bucket", "big.bin", strings.NewReader("small"), 5, "text/plain", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	parts, err = fs.GetObjectParts(ctx, "bucket", "big.bin")
+	if err != nil {
+		t.Fatalf("GetObjectParts: %v", err)
+	}
+	if len(parts) != 0 {
+		t.Errorf("got %d parts after overwrite, want none", len(parts))
+	}
+}
+
+func TestCompleteMultipartUploadUnlistedParts(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	upload, err := fs.CreateMultipartUpload(ctx, "bucket", "big.bin", "application/octet-stream", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	var uploaded []Part
+	for i, data := range []string{"aaaa", "bb", "cccccc"} {
+		part, err := fs.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, int32(i+1), strings.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+		uploaded = append(uploaded, Part{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+
+	// Parts listed twice or out of order are rejected and the upload is kept
+	for _, parts := range [][]Part{
+		{uploaded[0], uploaded[0]},
+		{uploaded[2], uploaded[0]},
+	} {
+		if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, parts); !errors.Is(err, ErrInvalidPartOrder) {
+			t.Errorf("CompleteMultipartUpload(%v): got %v, want ErrInvalidPartOrder", parts, err)
+		}
+	}
+
+	// Part 2 is not listed, so it is dropped along with its file
+	obj, err := fs.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, []Part{uploaded[0], uploaded[2]})
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	if obj.Size != 10 {
+		t.Errorf("size = %d, want 10", obj.Size)
+	}
+	if _, err := os.Stat(filepath.Join(fs.dataDir, ".uploads", upload.UploadID)); !os.IsNotExist(err) {
+		t.Errorf("parts directory after completion: got %v, want it removed", err)
+	}
+	usage, err := fs.GetBucketUsage(ctx, "bucket")
+	if err != nil {
+		t.Fatalf("GetBucketUsage: %v", err)
+	}
+	if usage.MultipartUploads != 0 || usage.MultipartBytes != 0 {
+		t.Errorf("usage after completion = %+v, want no uploads", usage)
+	}
+}
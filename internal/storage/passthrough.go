@@ -0,0 +1,413 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BlobStore stores raw object data in a remote backend.
+// Blob names are "{bucket}/{key}" so a single remote container can hold all buckets.
+type BlobStore interface {
+	// PutBlob stores size bytes read from body under name.
+	PutBlob(ctx context.Context, name string, body io.Reader, size int64) error
+	// GetBlob returns length bytes of the blob starting at offset.
+	// A negative length reads to the end of the blob.
+	GetBlob(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error)
+	// DeleteBlob deletes the blob. Deleting a missing blob is not an error.
+	DeleteBlob(ctx context.Context, name string) error
+}
+
+// ErrBlobNotFound is returned by a BlobStore when the blob does not exist.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrNotImplemented is returned for operations the storage backend does not support.
+var ErrNotImplemented = errors.New("not implemented by storage backend")
+
+// Passthrough implements Storage by keeping metadata and bucket configuration
+// in the local SQLite database while storing object data in a BlobStore.
+// Multipart parts are staged on local disk until the upload is completed.
+type Passthrough struct {
+	*FileSystem
+	blobs BlobStore
+}
+
+// NewPassthrough creates a new passthrough storage backend.
+func NewPassthrough(dataDir string, metadataDB string, blobs BlobStore) (*Passthrough, error) {
+	fs, err := NewFileSystem(dataDir, metadataDB)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Passthrough{
+		FileSystem: fs,
+		blobs:      blobs,
+	}, nil
+}
+
+// BlobStore returns the blob store holding the object data.
+func (p *Passthrough) BlobStore() BlobStore {
+	return p.blobs
+}
+
+// Close closes the metadata database and the blob store if it holds resources.
+func (p *Passthrough) Close() error {
+	err := p.FileSystem.Close()
+	if closer, ok := p.blobs.(io.Closer); ok {
+		err = errors.Join(err, closer.Close())
+	}
+	return err
+}
+
+// blobName returns the remote blob name for an object.
+func blobName(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+// PutObject stores an object in the blob store.
+func (p *Passthrough) PutObject(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, metadata map[string]string) (*Object, error) {
+	// Validate object key to prevent path traversal
+	if _, err := p.validateObjectKey(bucket, key); err != nil {
+		return nil, err
+	}
+
+	// Check if bucket exists
+	exists, err := p.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	etags, err := p.etagStrategy(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	// The blob and the metadata of the object are replaced together
+	unlock := p.lockKey(ctx, bucket, key)
+	defer unlock()
+
+	// Upload data and calculate MD5
+	hash := md5.New()
+	counter := &countingReader{r: io.TeeReader(body, hash)}
+	if err := p.blobs.PutBlob(ctx, blobName(bucket, key), counter, size); err != nil {
+		return nil, fmt.Errorf("failed to upload object: %w", err)
+	}
+
+	// Set default content type
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	obj := &Object{
+		Key:          key,
+		Size:         counter.n,
+		LastModified: time.Now(),
+		ETag:         etags.ObjectETag(hash.Sum(nil)),
+		ContentType:  contentType,
+		Metadata:     metadata,
+	}
+
+	if err := p.metadata.PutObject(ctx, bucket, obj); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// GetObject retrieves an object from the blob store.
+func (p *Passthrough) GetObject(ctx context.Context, bucket, key string) (*ObjectData, error) {
+	obj, err := p.HeadObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := p.blobs.GetBlob(ctx, blobName(bucket, key), 0, -1)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to download object: %w", err)
+	}
+
+	return &ObjectData{
+		Object: *obj,
+		Body:   body,
+	}, nil
+}
+
+// GetObjectRange retrieves a range of an object from the blob store.
+func (p *Passthrough) GetObjectRange(ctx context.Context, bucket, key string, start, end int64) (*ObjectData, error) {
+	obj, err := p.HeadObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	rangeSize := end - start + 1
+	body, err := p.blobs.GetBlob(ctx, blobName(bucket, key), start, rangeSize)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to download object range: %w", err)
+	}
+
+	rangeObj := *obj
+	rangeObj.Size = rangeSize
+
+	return &ObjectData{
+		Object: rangeObj,
+		Body:   body,
+	}, nil
+}
+
+// DeleteObject deletes an object from the blob store.
+func (p *Passthrough) DeleteObject(ctx context.Context, bucket, key string) error {
+	// Validate object key to prevent path traversal
+	if _, err := p.validateObjectKey(bucket, key); err != nil {
+		return err
+	}
+
+	// Check if bucket exists
+	exists, err := p.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	unlock := p.lockKey(ctx, bucket, key)
+	defer unlock()
+
+	if err := p.blobs.DeleteBlob(ctx, blobName(bucket, key)); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	return p.metadata.DeleteObject(ctx, bucket, key)
+}
+
+// DeleteObjects deletes multiple objects from the blob store.
+func (p *Passthrough) DeleteObjects(ctx context.Context, bucket string, keys []string) ([]DeletedObject, []DeleteError, error) {
+	// Check if bucket exists
+	exists, err := p.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, ErrBucketNotFound
+	}
+
+	deleted := make([]DeletedObject, 0, len(keys))
+	errs := make([]DeleteError, 0)
+
+	for _, key := range keys {
+		if _, err := p.validateObjectKey(bucket, key); err != nil {
+			errs = append(errs, DeleteError{
+				Key:     key,
+				Code:    "InvalidArgument",
+				Message: "Invalid object key",
+			})
+			continue
+		}
+
+		err := func() error {
+			unlock := p.lockKey(ctx, bucket, key)
+			defer unlock()
+
+			if err := p.blobs.DeleteBlob(ctx, blobName(bucket, key)); err != nil {
+				return err
+			}
+
+			// Metadata deletion failures are not reported, matching FileSystem.DeleteObjects
+			_ = p.metadata.DeleteObject(ctx, bucket, key)
+			return nil
+		}()
+		if err != nil {
+			errs = append(errs, DeleteError{
+				Key:     key,
+				Code:    "InternalError",
+				Message: fmt.Sprintf("Failed to delete object: %v", err),
+			})
+			continue
+		}
+
+		deleted = append(deleted, DeletedObject{
+			Key: key,
+		})
+	}
+
+	return deleted, errs, nil
+}
+
+// CopyObject copies an object within the blob store.
+func (p *Passthrough) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, metadata map[string]string) (*Object, error) {
+	// Validate source and destination keys to prevent path traversal
+	if _, err := p.validateObjectKey(srcBucket, srcKey); err != nil {
+		return nil, err
+	}
+	if _, err := p.validateObjectKey(dstBucket, dstKey); err != nil {
+		return nil, err
+	}
+
+	// Copying an object onto itself with new metadata keeps its data
+	if srcBucket == dstBucket && srcKey == dstKey && metadata != nil {
+		return p.replaceObjectMetadata(ctx, dstBucket, dstKey, metadata)
+	}
+
+	// Check if source and destination buckets exist
+	for _, bucket := range []string{srcBucket, dstBucket} {
+		exists, err := p.metadata.BucketExists(ctx, bucket)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, &BucketNotFoundError{Bucket: bucket}
+		}
+	}
+
+	srcObj, err := p.metadata.GetObject(ctx, srcBucket, srcKey)
+	if err != nil {
+		return nil, err
+	}
+	if srcObj == nil {
+		return nil, ErrObjectNotFound
+	}
+
+	body, err := p.blobs.GetBlob(ctx, blobName(srcBucket, srcKey), 0, -1)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to download source object: %w", err)
+	}
+	defer body.Close()
+
+	// Use COPY directive semantics when no metadata is given
+	if metadata == nil {
+		metadata = srcObj.Metadata
+	}
+
+	obj, err := p.PutObject(ctx, dstBucket, dstKey, body, srcObj.Size, srcObj.ContentType, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// AppendObject is not supported by passthrough backends.
+func (p *Passthrough) AppendObject(ctx context.Context, bucket, key string, position int64, body io.Reader, size int64, contentType string, metadata map[string]string) (*Object, error) {
+	return nil, ErrNotImplemented
+}
+
+// UploadPartCopy copies data from an object in the blob store to a locally staged part.
+func (p *Passthrough) UploadPartCopy(ctx context.Context, bucket, key, uploadID string, partNumber int32, srcBucket, srcKey string, startByte, endByte *int64) (*Part, error) {
+	// Validate source key to prevent path traversal
+	if _, err := p.validateObjectKey(srcBucket, srcKey); err != nil {
+		return nil, err
+	}
+
+	// Check if source bucket exists
+	exists, err := p.metadata.BucketExists(ctx, srcBucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	srcObj, err := p.metadata.GetObject(ctx, srcBucket, srcKey)
+	if err != nil {
+		return nil, err
+	}
+	if srcObj == nil {
+		return nil, ErrObjectNotFound
+	}
+
+	// Determine start and end positions
+	start, end := int64(0), srcObj.Size-1
+	if startByte != nil && endByte != nil {
+		start, end = *startByte, *endByte
+		if start < 0 || end >= srcObj.Size || start > end {
+			return nil, ErrInvalidRange
+		}
+	}
+
+	body, err := p.blobs.GetBlob(ctx, blobName(srcBucket, srcKey), start, end-start+1)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to download source object: %w", err)
+	}
+	defer body.Close()
+
+	return p.FileSystem.UploadPart(ctx, bucket, key, uploadID, partNumber, body, end-start+1)
+}
+
+// CompleteMultipartUpload assembles the staged parts and uploads the result to the blob store.
+// The key stays locked until the upload is done, so that the metadata of the
+// object cannot be replaced before its data is.
+func (p *Passthrough) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) (*Object, error) {
+	ctx, unlock := p.holdKey(ctx, bucket, key)
+	defer unlock()
+
+	// Assemble parts on local disk, reusing the filesystem validation and ETag logic
+	obj, err := p.FileSystem.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts)
+	if err != nil {
+		return nil, err
+	}
+
+	localPath := filepath.Join(p.dataDir, bucket, p.keyPath(key))
+	defer os.Remove(localPath)
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open assembled object: %w", err)
+	}
+	defer file.Close()
+
+	if err := p.blobs.PutBlob(ctx, blobName(bucket, key), file, obj.Size); err != nil {
+		p.metadata.DeleteObject(ctx, bucket, key)
+		return nil, fmt.Errorf("failed to upload object: %w", err)
+	}
+
+	return obj, nil
+}
+
+// PutBucketVersioning is not supported by passthrough backends.
+func (p *Passthrough) PutBucketVersioning(ctx context.Context, bucket string, status VersioningStatus) error {
+	return ErrNotImplemented
+}
+
+// PutBucketMfaDelete is not supported by passthrough backends, which do not
+// support versioning.
+func (p *Passthrough) PutBucketMfaDelete(ctx context.Context, bucket string, status MfaDeleteStatus) error {
+	return ErrNotImplemented
+}
+
+// PutBucketWorm is not supported by passthrough backends, which write objects
+// without the write-once checks.
+func (p *Passthrough) PutBucketWorm(ctx context.Context, bucket string, enabled bool) error {
+	return ErrNotImplemented
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryBlobStore is an in-memory BlobStore for tests.
+type memoryBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func newMemoryBlobStore() *memoryBlobStore {
+	return &memoryBlobStore{blobs: make(map[string][]byte)}
+}
+
+func (m *memoryBlobStore) PutBlob(ctx context.Context, name string, body io.Reader, size int64) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[name] = data
+	return nil
+}
+
+func (m *memoryBlobStore) GetBlob(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[name]
+	if !ok {
+		return nil, ErrBlobNotFound
+	}
+	data = data[offset:]
+	if length >= 0 {
+		data = data[:length]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryBlobStore) DeleteBlob(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, name)
+	return nil
+}
+
+func newTestPassthrough(t *testing.T, blobs BlobStore) *Passthrough {
+	t.Helper()
+	dir := t.TempDir()
+	p, err := NewPassthrough(dir, filepath.Join(dir, "metadata.db"), blobs)
+	if err != nil {
+		t.Fatalf("failed to create passthrough storage: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestPassthrough_ObjectLifecycle(t *testing.T) {
+	ctx := context.Background()
+	blobs := newMemoryBlobStore()
+	p := newTestPassthrough(t, blobs)
+
+	if err := p.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	obj, err := p.PutObject(ctx, "bucket", "dir/key.txt", strings.NewReader("hello world"), 11, "text/plain", nil)
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if obj.ETag != "5eb63bbbe01eeed093cb22bb8f5acdc3" {
+		t.Errorf("unexpected ETag %q", obj.ETag)
+	}
+	if _, ok := blobs.blobs["bucket/dir/key.txt"]; !ok {
+		t.Fatalf("object was not stored in blob store")
+	}
+
+	data, err := p.GetObjectRange(ctx, "bucket", "dir/key.txt", 6, 10)
+	if err != nil {
+		t.Fatalf("GetObjectRange: %v", err)
+	}
+	body, _ := io.ReadAll(data.Body)
+	data.Body.Close()
+	if string(body) != "world" || data.Size != 5 {
+		t.Errorf("unexpected range %q (size %d)", body, data.Size)
+	}
+
+	if _, err := p.CopyObject(ctx, "bucket", "dir/key.txt", "bucket", "copy.txt", nil); err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	data, err = p.GetObject(ctx, "bucket", "copy.txt")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	body, _ = io.ReadAll(data.Body)
+	data.Body.Close()
+	if string(body) != "hello world" || data.ContentType != "text/plain" {
+		t.Errorf("unexpected copy %q (%s)", body, data.ContentType)
+	}
+
+	if err := p.DeleteObject(ctx, "bucket", "dir/key.txt"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, ok := blobs.blobs["bucket/dir/key.txt"]; ok {
+		t.Errorf("object was not deleted from blob store")
+	}
+	if _, err := p.GetObject(ctx, "bucket", "dir/key.txt"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected ErrObjectNotFound, got %v", err)
+	}
+}
+
+func TestPassthrough_MultipartUpload(t *testing.T) {
+	ctx := context.Background()
+	blobs := newMemoryBlobStore()
+	p := newTestPassthrough(t, blobs)
+
+	if err := p.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	upload, err := p.CreateMultipartUpload(ctx, "bucket", "big", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	part1, err := p.UploadPart(ctx, "bucket", "big", upload.UploadID, 1, strings.NewReader("abc"), 3)
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	part2, err := p.UploadPart(ctx, "bucket", "big", upload.UploadID, 2, strings.NewReader("def"), 3)
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+
+	if _, err := p.CompleteMultipartUpload(ctx, "bucket", "big", upload.UploadID, []Part{*part1, *part2}); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	if got := string(blobs.blobs["bucket/big"]); got != "abcdef" {
+		t.Errorf("unexpected assembled object %q", got)
+	}
+}
+
+func TestPassthrough_VersioningNotImplemented(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPassthrough(t, newMemoryBlobStore())
+
+	if err := p.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := p.PutBucketVersioning(ctx, "bucket", VersioningStatusEnabled); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("expected ErrNotImplemented, got %v", err)
+	}
+}
+
+func TestAzureBlobStore_RoundTrip(t *testing.T) {
+	var mu sync.Mutex
+	blobs := make(map[string][]byte)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey devstoreaccount1:") || r.Header.Get("x-ms-date") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			blobs[r.URL.Path], _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			data, ok := blobs[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Header.Get("Range") == "bytes=1-2" {
+				data = data[1:3]
+			}
+			w.Write(data)
+		case http.MethodDelete:
+			delete(blobs, r.URL.Path)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer srv.Close()
+
+	key := base64.StdEncoding.EncodeToString([]byte("secret"))
+	store, err := NewAzureBlobStore("devstoreaccount1", key, "jog", srv.URL)
+	if err != nil {
+		t.Fatalf("NewAzureBlobStore: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := store.PutBlob(ctx, "bucket/a b.txt", strings.NewReader("xyz"), 3); err != nil {
+		t.Fatalf("PutBlob: %v", err)
+	}
+
+	rc, err := store.GetBlob(ctx, "bucket/a b.txt", 1, 2)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "yz" {
+		t.Errorf("unexpected range %q", data)
+	}
+
+	if err := store.DeleteBlob(ctx, "bucket/a b.txt"); err != nil {
+		t.Fatalf("DeleteBlob: %v", err)
+	}
+	if _, err := store.GetBlob(ctx, "bucket/a b.txt", 0, -1); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+}
+
+func TestS3BlobStore_RoundTrip(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=upstream/") || r.Header.Get("X-Amz-Date") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.EscapedPath() != "/upstream/bucket/a%20b.txt" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Header.Get("Range") == "bytes=1-2" {
+				data = data[1:3]
+			}
+			w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	store, err := NewS3BlobStore(srv.URL, "us-east-1", "upstream", "upstream", "secret")
+	if err != nil {
+		t.Fatalf("NewS3BlobStore: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := store.PutBlob(ctx, "bucket/a b.txt", strings.NewReader("xyz"), 3); err != nil {
+		t.Fatalf("PutBlob: %v", err)
+	}
+
+	rc, err := store.GetBlob(ctx, "bucket/a b.txt", 1, 2)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "yz" {
+		t.Errorf("unexpected range %q", data)
+	}
+
+	if err := store.DeleteBlob(ctx, "bucket/a b.txt"); err != nil {
+		t.Fatalf("DeleteBlob: %v", err)
+	}
+	if _, err := store.GetBlob(ctx, "bucket/a b.txt", 0, -1); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+}
+
+func TestCachingBlobStore(t *testing.T) {
+	ctx := context.Background()
+	upstream := newMemoryBlobStore()
+	cache, err := NewCachingBlobStore(upstream, t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewCachingBlobStore: %v", err)
+	}
+
+	upstream.blobs["remote"] = []byte("from upstream")
+
+	// A full read populates the cache
+	rc, err := cache.GetBlob(ctx, "remote", 0, -1)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	io.ReadAll(rc)
+	rc.Close()
+
+	// Later reads are served locally even if upstream changes
+	upstream.blobs["remote"] = []byte("changed")
+	rc, err = cache.GetBlob(ctx, "remote", 5, 8)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "upstream" {
+		t.Errorf("expected cached range, got %q", data)
+	}
+
+	// Writes go through to upstream and replace the cached copy
+	if err := cache.PutBlob(ctx, "remote", strings.NewReader("new data"), 8); err != nil {
+		t.Fatalf("PutBlob: %v", err)
+	}
+	if got := string(upstream.blobs["remote"]); got != "new data" {
+		t.Errorf("upload was not written through, got %q", got)
+	}
+	delete(upstream.blobs, "remote")
+	rc, err = cache.GetBlob(ctx, "remote", 0, -1)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	data, _ = io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "new data" {
+		t.Errorf("expected cached upload, got %q", data)
+	}
+
+	// Deletes drop the cached copy
+	if err := cache.DeleteBlob(ctx, "remote"); err != nil {
+		t.Fatalf("DeleteBlob: %v", err)
+	}
+	if _, err := cache.GetBlob(ctx, "remote", 0, -1); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+
+	// A read that started before a write does not cache the data it replaced
+	upstream.blobs["raced"] = []byte("old data")
+	stale, err := cache.GetBlob(ctx, "raced", 0, -1)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	if err := cache.PutBlob(ctx, "raced", strings.NewReader("new data"), 8); err != nil {
+		t.Fatalf("PutBlob: %v", err)
+	}
+	io.ReadAll(stale)
+	stale.Close()
+	rc, err = cache.GetBlob(ctx, "raced", 0, -1)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	data, _ = io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "new data" {
+		t.Errorf("read racing a write cached %q, want %q", data, "new data")
+	}
+}
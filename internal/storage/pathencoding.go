@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// PathEncoding selects how the filesystem backend maps object keys to file
+// paths below the bucket directories.
+type PathEncoding string
+
+const (
+	// PathEncodingPlain stores objects at their keys as is, which works on
+	// case-sensitive filesystems that allow all characters but "/" in names.
+	PathEncodingPlain PathEncoding = "plain"
+	// PathEncodingPortable escapes the characters and names that Windows
+	// rejects and makes the names of keys that differ only by case distinct,
+	// for hosts with case-insensitive filesystems such as Windows and macOS.
+	PathEncodingPortable PathEncoding = "portable"
+)
+
+// ParsePathEncoding parses a path encoding. An empty string selects
+// PathEncodingPlain.
+func ParsePathEncoding(s string) (PathEncoding, error) {
+	switch PathEncoding(s) {
+	case "", PathEncodingPlain:
+		return PathEncodingPlain, nil
+	case PathEncodingPortable:
+		return PathEncodingPortable, nil
+	default:
+		return "", fmt.Errorf("invalid path encoding %q", s)
+	}
+}
+
+// SetPathEncoding sets how object keys are mapped to file paths. The encoding
+// of a data directory must not change once it holds objects, as objects
+// stored with another encoding are not found.
+func (fs *FileSystem) SetPathEncoding(encoding PathEncoding) {
+	fs.pathEncoding = encoding
+}
+
+// keyPath returns the path of the file of key relative to its bucket
+// directory.
+func (fs *FileSystem) keyPath(key string) string {
+	if fs.pathEncoding != PathEncodingPortable && fs.layout != LayoutV2 {
+		return key
+	}
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		name := segment
+		if fs.pathEncoding == PathEncodingPortable {
+			name = encodePathSegment(segment)
+		}
+		segments[i] = fs.hashName(segment, name)
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathKey returns the key of the object stored at path, relative to its
+// bucket directory and separated by slashes. It returns false for paths that
+// keyPath does not return and for paths with hashed names, whose keys are
+// only known to the metadata.
+func (fs *FileSystem) pathKey(path string) (string, bool) {
+	segments := strings.Split(path, "/")
+	for i, name := range segments {
+		if fs.isHashedName(name) {
+			return "", false
+		}
+		if fs.pathEncoding != PathEncodingPortable {
+			continue
+		}
+		segment, ok := decodePathSegment(name)
+		if !ok {
+			return "", false
+		}
+		segments[i] = segment
+	}
+	return strings.Join(segments, "/"), true
+}
+
+// versionPath returns the path of the file of an object version.
+func (fs *FileSystem) versionPath(bucket, key, versionID string) string {
+	return filepath.Join(fs.dataDir, bucket, ".versions", fs.keyPath(key), versionID)
+}
+
+// windowsReservedNames are the device names that Windows reserves regardless
+// of case and extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// encodePathSegment returns the file name of a key segment in the portable
+// encoding. Control characters, the characters Windows rejects, a trailing
+// dot or space and "%" and "~", which the encoding uses itself, are escaped as
+// %XX, as is the first character of reserved device names. Names of segments
+// with upper case letters get a "~" and a hash of the segment appended, so
+// that segments that differ only by case do not collide.
+func encodePathSegment(segment string) string {
+	var b strings.Builder
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		last := i == len(segment)-1
+		if c < 0x20 || c == 0x7f || strings.IndexByte(`<>:"\|?*%~`, c) >= 0 || last && (c == '.' || c == ' ') {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	name := b.String()
+
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReservedNames[strings.ToUpper(strings.TrimRight(base, " "))] {
+		name = fmt.Sprintf("%%%02X", name[0]) + name[1:]
+	}
+	if strings.ToLower(segment) != segment {
+		name += "~" + caseHash(segment)
+	}
+	return name
+}
+
+// decodePathSegment returns the key segment of a file name returned by
+// encodePathSegment.
+func decodePathSegment(name string) (string, bool) {
+	escaped, _, _ := strings.Cut(name, "~")
+	segment, err := url.PathUnescape(escaped)
+	if err != nil || encodePathSegment(segment) != name {
+		return "", false
+	}
+	return segment, true
+}
+
+// caseHash returns a short hash of a key segment that tells apart segments
+// that differ only by case.
+func caseHash(segment string) string {
+	sum := sha256.Sum256([]byte(segment))
+	return hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncodePathSegment(t *testing.T) {
+	tests := []struct {
+		segment string
+		want    string
+	}{
+		{"photo.jpg", "photo.jpg"},
+		{"a:b", "a%3Ab"},
+		{"what?*", "what%3F%2A"},
+		{"100%~", "100%25%7E"},
+		{"name.", "name%2E"},
+		{"name ", "name%20"},
+		{".", "%2E"},
+		{"con", "%63on"},
+		{"nul.txt", "%6Eul.txt"},
+		{"console", "console"},
+		{"Foo", "Foo~" + caseHash("Foo")},
+		{"CON", "%43ON~" + caseHash("CON")},
+	}
+	for _, tt := range tests {
+		name := encodePathSegment(tt.segment)
+		if name != tt.want {
+			t.Errorf("encodePathSegment(%q) = %q, want %q", tt.segment, name, tt.want)
+		}
+		if segment, ok := decodePathSegment(name); !ok || segment != tt.segment {
+			t.Errorf("decodePathSegment(%q) = %q, %v, want %q", name, segment, ok, tt.segment)
+		}
+	}
+
+	// Names that the encoding does not produce are not decoded
+	for _, name := range []string{"a%3ab", "Foo", "foo~" + caseHash("foo"), "Foo~00000000", "100%"} {
+		if segment, ok := decodePathSegment(name); ok {
+			t.Errorf("decodePathSegment(%q) = %q, want false", name, segment)
+		}
+	}
+}
+
+func TestPortablePathEncoding(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	fs.SetPathEncoding(PathEncodingPortable)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	// Keys that differ only by case are stored in files whose names differ
+	// on case-insensitive filesystems too
+	keys := []string{"docs/readme", "docs/README", "Docs/Readme", "c:/aux/trailing./x?"}
+	paths := make(map[string]string)
+	for _, key := range keys {
+		if _, err := fs.PutObject(ctx, "bucket", key, strings.NewReader(key), int64(len(key)), "", nil); err != nil {
+			t.Fatalf("PutObject(%q): %v", key, err)
+		}
+		path := fs.keyPath(key)
+		if other, ok := paths[strings.ToLower(path)]; ok {
+			t.Errorf("keys %q and %q map to paths that differ only by case", other, key)
+		}
+		paths[strings.ToLower(path)] = key
+		if got, ok := fs.pathKey(path); !ok || got != key {
+			t.Errorf("pathKey(%q) = %q, %v, want %q", path, got, ok, key)
+		}
+	}
+	for _, key := range keys {
+		obj, err := fs.GetObject(ctx, "bucket", key)
+		if err != nil {
+			t.Fatalf("GetObject(%q): %v", key, err)
+		}
+		data, err := io.ReadAll(obj.Body)
+		obj.Body.Close()
+		if err != nil || string(data) != key {
+			t.Errorf("GetObject(%q) = %q, %v", key, data, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pruneGracePeriod is how long PruneEmptyDirs leaves new directories alone,
+// so that it does not remove a directory that a write just created for its
+// object.
+const pruneGracePeriod = time.Minute
+
+// pruneEmptyDirs removes the empty directories between the file at path and
+// the directory of its bucket, which deleting nested keys leaves behind. It
+// stops at the first directory that is not empty. Pruning is best effort: a
+// write that creates its file in a directory removed concurrently creates the
+// directory again, see createTempFile.
+func (fs *FileSystem) pruneEmptyDirs(bucket, path string) {
+	bucketDir := filepath.Join(fs.dataDir, bucket) + string(filepath.Separator)
+	for dir := filepath.Dir(path); strings.HasPrefix(dir, bucketDir); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
+
+// PruneEmptyDirs removes the empty directories of all buckets that are older
+// than a grace period, such as directories left behind by deletes that could
+// not prune them. It returns the number of directories removed.
+func (fs *FileSystem) PruneEmptyDirs(ctx context.Context) (int, error) {
+	buckets, err := fs.metadata.ListBuckets(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-pruneGracePeriod)
+	removed := 0
+	for _, bucket := range buckets {
+		bucketDir := filepath.Join(fs.dataDir, bucket.Name)
+		var dirs []string
+		err := filepath.WalkDir(bucketDir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				// Removed concurrently, e.g. by a delete
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !d.IsDir() || path == bucketDir {
+				return nil
+			}
+			// Removing a child changes the modification time of its parent,
+			// so the age is checked before anything is removed
+			if info, err := d.Info(); err == nil && info.ModTime().Before(cutoff) {
+				dirs = append(dirs, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, fmt.Errorf("failed to walk bucket %s: %w", bucket.Name, err)
+		}
+
+		// Children come after their parents in walk order, so going backwards
+		// empties directories before their parents are tried
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if err := ctx.Err(); err != nil {
+				return removed, err
+			}
+			if os.Remove(dirs[i]) == nil {
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+// createTempFile creates a temp file in dir for the data of an object,
+// creating dir first. dir is created again if it was pruned in between.
+func createTempFile(dir string) (*os.File, error) {
+	for attempt := 0; ; attempt++ {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create object directory: %w", err)
+		}
+		file, err := os.CreateTemp(dir, ".tmp-*")
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) || attempt == 2 {
+			return nil, fmt.Errorf("failed to create temp file: %w", err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPruneEmptyDirs(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	for _, key := range []string{"a/b/c/one.txt", "a/two.txt"} {
+		if _, err := fs.PutObject(ctx, "bucket", key, strings.NewReader("data"), 4, "", nil); err != nil {
+			t.Fatalf("PutObject: %v", err)
+		}
+	}
+
+	// Deleting stops at the first directory that is not empty
+	if err := fs.DeleteObject(ctx, "bucket", "a/b/c/one.txt"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	bucketDir := filepath.Join(fs.dataDir, "bucket")
+	if _, err := os.Stat(filepath.Join(bucketDir, "a", "b")); !os.IsNotExist(err) {
+		t.Errorf("a/b after delete: got %v, want it removed", err)
+	}
+	if _, err := os.Stat(filepath.Join(bucketDir, "a", "two.txt")); err != nil {
+		t.Errorf("a/two.txt after delete: %v", err)
+	}
+	if err := fs.DeleteObject(ctx, "bucket", "a/two.txt"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(bucketDir, "a")); !os.IsNotExist(err) {
+		t.Errorf("a after delete: got %v, want it removed", err)
+	}
+	if _, err := os.Stat(bucketDir); err != nil {
+		t.Errorf("bucket directory after delete: %v", err)
+	}
+
+	// The sweep removes old empty directories and leaves new ones alone
+	oldDir := filepath.Join(bucketDir, "old", "empty")
+	newDir := filepath.Join(bucketDir, "new")
+	for _, dir := range []string{oldDir, newDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+	}
+	past := time.Now().Add(-time.Hour)
+	for _, dir := range []string{oldDir, filepath.Dir(oldDir)} {
+		if err := os.Chtimes(dir, past, past); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+	removed, err := fs.PruneEmptyDirs(ctx)
+	if err != nil {
+		t.Fatalf("PruneEmptyDirs: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("PruneEmptyDirs removed %d directories, want 2", removed)
+	}
+	if _, err := os.Stat(filepath.Dir(oldDir)); !os.IsNotExist(err) {
+		t.Errorf("old empty directories after sweep: got %v, want them removed", err)
+	}
+	if _, err := os.Stat(newDir); err != nil {
+		t.Errorf("new directory after sweep: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errTooFewShards is returned when not enough shards are available to reconstruct data.
+var errTooFewShards = errors.New("too few shards to reconstruct data")
+
+// gfExp and gfLog are exponent and logarithm tables for GF(2^8) with the
+// primitive polynomial x^8 + x^4 + x^3 + x^2 + 1 (0x11d).
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+// gfMul multiplies two elements of GF(2^8).
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfInv returns the multiplicative inverse of a non-zero element of GF(2^8).
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// reedSolomon is a systematic Reed-Solomon erasure code. The first dataShards
+// shards hold the data as is; the parity shards are computed with a Cauchy
+// matrix, so any dataShards of the total shards are enough to recover the data.
+type reedSolomon struct {
+	dataShards   int
+	parityShards int
+	// matrix has one row per shard; the top rows form the identity matrix.
+	matrix [][]byte
+}
+
+// newReedSolomon creates a Reed-Solomon code with the given shard counts.
+func newReedSolomon(dataShards, parityShards int) (*reedSolomon, error) {
+	if dataShards <= 0 || parityShards < 0 {
+		return nil, fmt.Errorf("invalid shard counts: %d data, %d parity", dataShards, parityShards)
+	}
+	if dataShards+parityShards > 256 {
+		return nil, fmt.Errorf("too many shards: %d", dataShards+parityShards)
+	}
+
+	total := dataShards + parityShards
+	matrix := make([][]byte, total)
+	for r := 0; r < total; r++ {
+		matrix[r] = make([]byte, dataShards)
+		if r < dataShards {
+			matrix[r][r] = 1
+			continue
+		}
+		// Cauchy matrix: 1 / (x_r + y_c) with disjoint x = {dataShards...} and y = {0...}
+		for c := 0; c < dataShards; c++ {
+			matrix[r][c] = gfInv(byte(r) ^ byte(c))
+		}
+	}
+
+	return &reedSolomon{
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		matrix:       matrix,
+	}, nil
+}
+
+// encode computes the parity shards from the data shards.
+// All shards must have the same length; parity shards are overwritten.
+func (rs *reedSolomon) encode(shards [][]byte) {
+	for p := rs.dataShards; p < len(shards); p++ {
+		rs.computeShard(shards[p], rs.matrix[p], shards[:rs.dataShards])
+	}
+}
+
+// computeShard sets out to the linear combination of inputs given by coefficients.
+func (rs *reedSolomon) computeShard(out []byte, coefficients []byte, inputs [][]byte) {
+	for i := range out {
+		out[i] = 0
+	}
+	for c, input := range inputs {
+		coefficient := coefficients[c]
+		if coefficient == 0 {
+			continue
+		}
+		for i, b := range input {
+			out[i] ^= gfMul(coefficient, b)
+		}
+	}
+}
+
+// reconstruct rebuilds missing shards in place. A shard is missing when it is nil.
+// Present shards must all have the same length.
+func (rs *reedSolomon) reconstruct(shards [][]byte) error {
+	var present []int
+	size := 0
+	for i, shard := range shards {
+		if shard != nil {
+			present = append(present, i)
+			size = len(shard)
+		}
+	}
+	if len(present) < rs.dataShards {
+		return errTooFewShards
+	}
+	if len(present) == len(shards) {
+		return nil
+	}
+
+	// Recover the data shards from the first dataShards present shards
+	rows := present[:rs.dataShards]
+	sub := make([][]byte, rs.dataShards)
+	inputs := make([][]byte, rs.dataShards)
+	for i, row := range rows {
+		sub[i] = rs.matrix[row]
+		inputs[i] = shards[row]
+	}
+	inverse, err := invertMatrix(sub)
+	if err != nil {
+		return err
+	}
+	for d := 0; d < rs.dataShards; d++ {
+		if shards[d] == nil {
+			shards[d] = make([]byte, size)
+			rs.computeShard(shards[d], inverse[d], inputs)
+		}
+	}
+
+	// Recompute missing parity shards from the complete data shards
+	for p := rs.dataShards; p < len(shards); p++ {
+		if shards[p] == nil {
+			shards[p] = make([]byte, size)
+			rs.computeShard(shards[p], rs.matrix[p], shards[:rs.dataShards])
+		}
+	}
+
+	return nil
+}
+
+// invertMatrix inverts a square matrix over GF(2^8) using Gauss-Jordan elimination.
+func invertMatrix(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	work := make([][]byte, n)
+	for i := range m {
+		work[i] = make([]byte, 2*n)
+		copy(work[i], m[i])
+		work[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		// Find a pivot row
+		pivot := -1
+		for row := col; row < n; row++ {
+			if work[row][col] != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, errors.New("matrix is singular")
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+
+		// Scale the pivot row to 1
+		scale := gfInv(work[col][col])
+		for i := range work[col] {
+			work[col][i] = gfMul(work[col][i], scale)
+		}
+
+		// Eliminate the column from all other rows
+		for row := 0; row < n; row++ {
+			if row == col || work[row][col] == 0 {
+				continue
+			}
+			factor := work[row][col]
+			for i := range work[row] {
+				work[row][i] ^= gfMul(factor, work[col][i])
+			}
+		}
+	}
+
+	inverse := make([][]byte, n)
+	for i := range work {
+		inverse[i] = work[i][n:]
+	}
+	return inverse, nil
+}
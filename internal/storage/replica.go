@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// followerConnLifetime is how long a follower keeps a metadata connection.
+// Connections are reopened regularly, so a metadata database that the sync
+// tool replaced with a new file is picked up.
+const followerConnLifetime = 10 * time.Second
+
+// NewMetadataFollower opens the metadata database of a primary read-only, e.g.
+// a copy synced from the primary. The database must exist; its schema is
+// managed by the primary.
+func NewMetadataFollower(dbPath string) (*Metadata, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+dbPath+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetConnMaxLifetime(followerConnLifetime)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return &Metadata{db: newMetadataDB(db)}, nil
+}
+
+// NewFileSystemFollower creates a read-only file system storage backend serving
+// a copy of a primary's data directory and metadata database. Writes fail.
+func NewFileSystemFollower(dataDir string, metadataDB string) (*FileSystem, error) {
+	if info, err := os.Stat(dataDir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("data directory %s does not exist", dataDir)
+	}
+
+	metadata, err := NewMetadataFollower(metadataDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize metadata: %w", err)
+	}
+
+	return &FileSystem{
+		dataDir:  dataDir,
+		metadata: metadata,
+	}, nil
+}
+
+// SetReplicationHeartbeat records the time of the primary's latest heartbeat.
+func (m *Metadata) SetReplicationHeartbeat(ctx context.Context, t time.Time) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO replication_heartbeat (id, time) VALUES (1, ?)
+	`, t.UTC())
+	return err
+}
+
+// ReplicationHeartbeat returns the time of the primary's latest heartbeat, or
+// the zero time if the primary never wrote one.
+func (m *Metadata) ReplicationHeartbeat(ctx context.Context) (time.Time, error) {
+	var t time.Time
+	err := m.db.QueryRowContext(ctx, `SELECT time FROM replication_heartbeat WHERE id = 1`).Scan(&t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return t, err
+}
+
+// WriteReplicationHeartbeat records the current time in the metadata database,
+// so replicas serving a copy of it can tell how far behind they are.
+func (fs *FileSystem) WriteReplicationHeartbeat(ctx context.Context) error {
+	return fs.metadata.SetReplicationHeartbeat(ctx, time.Now())
+}
+
+// ReplicationHeartbeat returns the time of the latest heartbeat written by
+// WriteReplicationHeartbeat, or the zero time if there is none.
+func (fs *FileSystem) ReplicationHeartbeat(ctx context.Context) (time.Time, error) {
+	return fs.metadata.ReplicationHeartbeat(ctx)
+}
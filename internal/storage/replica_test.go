@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSystemFollower(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	primary, err := NewFileSystem(dir, filepath.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+	defer primary.Close()
+	if err := primary.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	// The follower shares the files here; replicas serve a synced copy
+	follower, err := NewFileSystemFollower(dir, filepath.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("NewFileSystemFollower: %v", err)
+	}
+	defer follower.Close()
+
+	if last, err := follower.ReplicationHeartbeat(ctx); err != nil || !last.IsZero() {
+		t.Errorf("ReplicationHeartbeat before any heartbeat = %v, %v", last, err)
+	}
+
+	// Changes of the primary become visible
+	if _, err := primary.PutObject(ctx, "bucket", "key", strings.NewReader("hello"), 5, "text/plain", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	before := time.Now()
+	if err := primary.WriteReplicationHeartbeat(ctx); err != nil {
+		t.Fatalf("WriteReplicationHeartbeat: %v", err)
+	}
+	data, err := follower.GetObject(ctx, "bucket", "key")
+	if err != nil {
+		t.Fatalf("GetObject on follower: %v", err)
+	}
+	body, _ := io.ReadAll(data.Body)
+	data.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("follower read %q", body)
+	}
+	if last, err := follower.ReplicationHeartbeat(ctx); err != nil || last.Before(before.Add(-time.Second)) {
+		t.Errorf("ReplicationHeartbeat = %v, %v", last, err)
+	}
+
+	// Writes fail
+	if err := follower.CreateBucket(ctx, "other"); err == nil {
+		t.Error("CreateBucket on follower succeeded")
+	}
+
+	if _, err := NewFileSystemFollower(dir, filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("NewFileSystemFollower with missing database succeeded")
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ResponseHeader is a header added to object responses (JOG extension).
+type ResponseHeader struct {
+	Name  string
+	Value string
+}
+
+// ResponseHeaderRule adds headers to the responses of objects whose keys
+// start with Prefix. An empty prefix matches all objects.
+type ResponseHeaderRule struct {
+	Prefix  string
+	Headers []ResponseHeader
+}
+
+// ResponseHeaderConfiguration holds the response header policy of a bucket
+// (JOG extension), for buckets serving browsers without a fronting CDN.
+type ResponseHeaderConfiguration struct {
+	Rules []ResponseHeaderRule
+}
+
+// protectedResponseHeaders are the headers a response header policy cannot
+// set, because the object responses or the HTTP protocol own them.
+var protectedResponseHeaders = map[string]bool{
+	"Accept-Ranges":     true,
+	"Connection":        true,
+	"Content-Encoding":  true,
+	"Content-Length":    true,
+	"Content-Range":     true,
+	"Content-Type":      true,
+	"Date":              true,
+	"Etag":              true,
+	"Last-Modified":     true,
+	"Server":            true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// NewResponseHeader validates a policy header and canonicalizes its name.
+// x-amz-* headers and the headers of protectedResponseHeaders are rejected.
+func NewResponseHeader(name, value string) (ResponseHeader, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.IndexFunc(name, func(r rune) bool { return !isHeaderTokenRune(r) }) >= 0 {
+		return ResponseHeader{}, fmt.Errorf("invalid header name %q", name)
+	}
+	name = http.CanonicalHeaderKey(name)
+	if protectedResponseHeaders[name] || strings.HasPrefix(name, "X-Amz-") {
+		return ResponseHeader{}, fmt.Errorf("header %s cannot be set by a response header policy", name)
+	}
+	value = strings.TrimSpace(value)
+	if strings.ContainsFunc(value, func(r rune) bool { return (r < ' ' && r != '\t') || r == 0x7f }) {
+		return ResponseHeader{}, fmt.Errorf("invalid value for header %s", name)
+	}
+	return ResponseHeader{Name: name, Value: value}, nil
+}
+
+// isHeaderTokenRune reports whether r may appear in a header name (RFC 7230
+// section 3.2.6).
+func isHeaderTokenRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	default:
+		return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+	}
+}
+
+// Headers returns the headers of the rules matching key, in rule order. A
+// header set by several matching rules takes the value of the last one.
+func (c *ResponseHeaderConfiguration) Headers(key string) []ResponseHeader {
+	var headers []ResponseHeader
+	for _, rule := range c.Rules {
+		if strings.HasPrefix(key, rule.Prefix) {
+			headers = append(headers, rule.Headers...)
+		}
+	}
+	return headers
+}
+
+// PutBucketResponseHeaders sets the response header policy for a bucket.
+func (fs *FileSystem) PutBucketResponseHeaders(ctx context.Context, bucket string, config *ResponseHeaderConfiguration) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return fs.metadata.PutBucketResponseHeaders(ctx, bucket, string(configJSON))
+}
+
+// GetBucketResponseHeaders returns the response header policy for a bucket.
+func (fs *FileSystem) GetBucketResponseHeaders(ctx context.Context, bucket string) (*ResponseHeaderConfiguration, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	configJSON, err := fs.metadata.GetBucketResponseHeaders(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if configJSON == "" {
+		return nil, ErrNoSuchResponseHeaderConfiguration
+	}
+
+	var config ResponseHeaderConfiguration
+	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// DeleteBucketResponseHeaders deletes the response header policy for a bucket.
+func (fs *FileSystem) DeleteBucketResponseHeaders(ctx context.Context, bucket string) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return fs.metadata.DeleteBucketResponseHeaders(ctx, bucket)
+}
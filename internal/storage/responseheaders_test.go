@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNewResponseHeader(t *testing.T) {
+	header, err := NewResponseHeader(" x-frame-options ", "DENY")
+	if err != nil {
+		t.Fatalf("NewResponseHeader: %v", err)
+	}
+	if header != (ResponseHeader{Name: "X-Frame-Options", Value: "DENY"}) {
+		t.Errorf("NewResponseHeader = %+v", header)
+	}
+
+	for _, tt := range []struct{ name, value string }{
+		{"", "x"},
+		{"Bad Header", "x"},
+		{"Content-Type", "text/plain"},
+		{"etag", "x"},
+		{"X-Amz-Meta-Owner", "x"},
+		{"Cache-Control", "max-age=0\r\nSet-Cookie: a=b"},
+	} {
+		if _, err := NewResponseHeader(tt.name, tt.value); err == nil {
+			t.Errorf("NewResponseHeader(%q, %q) succeeded, want error", tt.name, tt.value)
+		}
+	}
+}
+
+func TestResponseHeaderConfigurationHeaders(t *testing.T) {
+	config := ResponseHeaderConfiguration{Rules: []ResponseHeaderRule{
+		{Headers: []ResponseHeader{{Name: "Cache-Control", Value: "no-cache"}}},
+		{Prefix: "assets/", Headers: []ResponseHeader{{Name: "Cache-Control", Value: "max-age=600"}}},
+	}}
+
+	if got := config.Headers("index.html"); len(got) != 1 || got[0].Value != "no-cache" {
+		t.Errorf("Headers(index.html) = %+v", got)
+	}
+	if got := config.Headers("assets/app.js"); len(got) != 2 || got[1].Value != "max-age=600" {
+		t.Errorf("Headers(assets/app.js) = %+v", got)
+	}
+}
+
+func TestBucketResponseHeaders(t *testing.T) {
+	fs := newTestFileSystem(t)
+	ctx := context.Background()
+	if err := fs.CreateBucket(ctx, "site"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.GetBucketResponseHeaders(ctx, "site"); !errors.Is(err, ErrNoSuchResponseHeaderConfiguration) {
+		t.Fatalf("GetBucketResponseHeaders = %v, want ErrNoSuchResponseHeaderConfiguration", err)
+	}
+
+	config := &ResponseHeaderConfiguration{Rules: []ResponseHeaderRule{
+		{Prefix: "assets/", Headers: []ResponseHeader{{Name: "Cache-Control", Value: "max-age=600"}}},
+	}}
+	if err := fs.PutBucketResponseHeaders(ctx, "site", config); err != nil {
+		t.Fatalf("PutBucketResponseHeaders: %v", err)
+	}
+	got, err := fs.GetBucketResponseHeaders(ctx, "site")
+	if err != nil {
+		t.Fatalf("GetBucketResponseHeaders: %v", err)
+	}
+	if len(got.Rules) != 1 || got.Rules[0].Prefix != "assets/" || got.Rules[0].Headers[0] != config.Rules[0].Headers[0] {
+		t.Errorf("GetBucketResponseHeaders = %+v", got)
+	}
+
+	if err := fs.DeleteBucketResponseHeaders(ctx, "site"); err != nil {
+		t.Fatalf("DeleteBucketResponseHeaders: %v", err)
+	}
+	if _, err := fs.GetBucketResponseHeaders(ctx, "site"); !errors.Is(err, ErrNoSuchResponseHeaderConfiguration) {
+		t.Errorf("GetBucketResponseHeaders after delete = %v", err)
+	}
+	if err := fs.PutBucketResponseHeaders(ctx, "missing", config); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("PutBucketResponseHeaders(missing) = %v, want ErrBucketNotFound", err)
+	}
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// PartUploadProgress is the state of a resumable part upload (JOG extension).
+type PartUploadProgress struct {
+	// Offset is the number of bytes of the part persisted so far.
+	Offset int64
+	// Part is the uploaded part once all of its bytes were received.
+	Part *Part
+}
+
+// partLock serializes writes to the in-progress data of one part.
+type partLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lockPart locks the in-progress data of a part and returns the unlock function.
+func (fs *FileSystem) lockPart(name string) func() {
+	fs.partLocksMu.Lock()
+	if fs.partLocks == nil {
+		fs.partLocks = make(map[string]*partLock)
+	}
+	lock := fs.partLocks[name]
+	if lock == nil {
+		lock = &partLock{}
+		fs.partLocks[name] = lock
+	}
+	lock.refs++
+	fs.partLocksMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		fs.partLocksMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(fs.partLocks, name)
+		}
+		fs.partLocksMu.Unlock()
+	}
+}
+
+// partialPartPath returns the file holding the bytes of a part received so far.
+// It lives in the parts directory of the upload, so aborting, completing and
+// cleaning up the upload remove it.
+func (fs *FileSystem) partialPartPath(uploadID string, partNumber int32) string {
+	return filepath.Join(fs.dataDir, ".uploads", uploadID, fmt.Sprintf("%d.partial", partNumber))
+}
+
+// checkUpload verifies that an active upload exists for the bucket and key
+// and returns it.
+func (fs *FileSystem) checkUpload(ctx context.Context, bucket, key, uploadID string) (*MultipartUpload, error) {
+	upload, err := fs.metadata.GetMultipartUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload == nil || upload.Bucket != bucket || upload.Key != key {
+		return nil, ErrUploadNotFound
+	}
+	if upload.State != UploadStateActive {
+		return nil, uploadStateError(upload.State)
+	}
+	return upload, nil
+}
+
+// UploadPartRange writes the bytes of a part starting at offset, size bytes of
+// a part of totalSize bytes. offset must equal the number of bytes persisted by
+// earlier calls. Bytes received before the body fails stay persisted, so that
+// an interrupted upload can be resumed from the offset reported by
+// GetPartUploadOffset. The part is stored once all totalSize bytes are received.
+func (fs *FileSystem) UploadPartRange(ctx context.Context, bucket, key, uploadID string, partNumber int32, offset int64, body io.Reader, size, totalSize int64) (*PartUploadProgress, error) {
+	upload, err := fs.checkUpload(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || size < 0 || offset+size > totalSize {
+		return nil, ErrInvalidRange
+	}
+
+	partialPath := fs.partialPartPath(uploadID, partNumber)
+	unlock := fs.lockPart(partialPath)
+	defer unlock()
+
+	file, err := os.OpenFile(partialPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// The parts directory was removed by an abort or completion
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("failed to open part file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat part file: %w", err)
+	}
+	if offset != info.Size() {
+		file.Close()
+		return nil, &PartOffsetMismatchError{Offset: info.Size()}
+	}
+
+	written, copyErr := io.Copy(file, io.LimitReader(body, size))
+	// Persist what was received even if the body failed
+	syncErr := file.Sync()
+	closeErr := file.Close()
+	if copyErr != nil {
+		return nil, fmt.Errorf("failed to write part: %w", copyErr)
+	}
+	if syncErr != nil {
+		return nil, fmt.Errorf("failed to sync part file: %w", syncErr)
+	}
+	if closeErr != nil {
+		return nil, fmt.Errorf("failed to close part file: %w", closeErr)
+	}
+	if written != size {
+		return nil, fmt.Errorf("failed to write part: %w", io.ErrUnexpectedEOF)
+	}
+
+	offset += written
+	if offset < totalSize {
+		return &PartUploadProgress{Offset: offset}, nil
+	}
+
+	part, err := fs.completePartialPart(ctx, bucket, upload, partNumber, partialPath)
+	if err != nil {
+		return nil, err
+	}
+	return &PartUploadProgress{Offset: offset, Part: part}, nil
+}
+
+// completePartialPart stores the fully received data of a part as the part.
+// Parts of uploads created with a checksum algorithm get their checksum.
+func (fs *FileSystem) completePartialPart(ctx context.Context, bucket string, upload *MultipartUpload, partNumber int32, partialPath string) (*Part, error) {
+	uploadID := upload.UploadID
+	file, err := os.Open(partialPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open part file: %w", err)
+	}
+	hash := md5.New()
+	var writer io.Writer = hash
+	checksumHash := NewChecksumHash(upload.ChecksumAlgorithm)
+	if checksumHash != nil {
+		writer = io.MultiWriter(hash, checksumHash)
+	}
+	written, err := io.Copy(writer, file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read part file: %w", err)
+	}
+
+	etag, err := fs.objectETag(ctx, bucket, hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	part := &Part{
+		PartNumber:   partNumber,
+		Size:         written,
+		ETag:         etag,
+		LastModified: time.Now(),
+	}
+	if checksumHash != nil {
+		part.ChecksumAlgorithm = upload.ChecksumAlgorithm
+		part.Checksum = base64.StdEncoding.EncodeToString(checksumHash.Sum(nil))
+	}
+
+	// Move the data to the part file and save part metadata
+	if err := fs.storePart(ctx, uploadID, partialPath, part); err != nil {
+		return nil, err
+	}
+
+	return part, nil
+}
+
+// GetPartUploadOffset returns the number of bytes of a resumable part upload
+// persisted so far, or 0 if none were received since the part was last stored.
+func (fs *FileSystem) GetPartUploadOffset(ctx context.Context, bucket, key, uploadID string, partNumber int32) (int64, error) {
+	if _, err := fs.checkUpload(ctx, bucket, key, uploadID); err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(fs.partialPartPath(uploadID, partNumber))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to stat part file: %w", err)
+	}
+	return info.Size(), nil
+}
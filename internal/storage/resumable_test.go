@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// failingReader returns data and then fails, like a dropped connection.
+type failingReader struct {
+	data string
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestUploadPartRangeResume(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	upload, err := fs.CreateMultipartUpload(ctx, "bucket", "big.bin", "application/octet-stream", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+
+	content := strings.Repeat("0123456789", 100)
+	total := int64(len(content))
+
+	// The first request drops after 300 of 600 bytes
+	_, err = fs.UploadPartRange(ctx, "bucket", "big.bin", upload.UploadID, 1, 0, &failingReader{data: content[:300]}, 600, total)
+	if err == nil {
+		t.Fatal("UploadPartRange with failing body succeeded")
+	}
+	offset, err := fs.GetPartUploadOffset(ctx, "bucket", "big.bin", upload.UploadID, 1)
+	if err != nil || offset != 300 {
+		t.Fatalf("GetPartUploadOffset = %d, %v, want 300", offset, err)
+	}
+
+	// Resuming at another offset is rejected with the persisted one
+	_, err = fs.UploadPartRange(ctx, "bucket", "big.bin", upload.UploadID, 1, 600, strings.NewReader(content[600:]), 400, total)
+	var offsetErr *PartOffsetMismatchError
+	if !errors.As(err, &offsetErr) || offsetErr.Offset != 300 {
+		t.Fatalf("UploadPartRange at wrong offset = %v, want PartOffsetMismatchError at 300", err)
+	}
+
+	progress, err := fs.UploadPartRange(ctx, "bucket", "big.bin", upload.UploadID, 1, 300, strings.NewReader(content[300:700]), 400, total)
+	if err != nil {
+		t.Fatalf("UploadPartRange: %v", err)
+	}
+	if progress.Offset != 700 || progress.Part != nil {
+		t.Fatalf("progress = %+v, want offset 700 without part", progress)
+	}
+
+	progress, err = fs.UploadPartRange(ctx, "bucket", "big.bin", upload.UploadID, 1, 700, strings.NewReader(content[700:]), 300, total)
+	if err != nil {
+		t.Fatalf("UploadPartRange: %v", err)
+	}
+	if progress.Part == nil || progress.Part.Size != total {
+		t.Fatalf("progress = %+v, want completed part of %d bytes", progress, total)
+	}
+	if offset, _ := fs.GetPartUploadOffset(ctx, "bucket", "big.bin", upload.UploadID, 1); offset != 0 {
+		t.Errorf("GetPartUploadOffset after completion = %d, want 0", offset)
+	}
+
+	// The resumed part has the ETag of a part uploaded in one request
+	plain, err := fs.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 2, strings.NewReader(content), total)
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	if progress.Part.ETag != plain.ETag {
+		t.Errorf("resumed part ETag = %s, want %s", progress.Part.ETag, plain.ETag)
+	}
+
+	if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, []Part{
+		{PartNumber: 1, ETag: progress.Part.ETag},
+		{PartNumber: 2, ETag: plain.ETag},
+	}); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	data, err := fs.GetObject(ctx, "bucket", "big.bin")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	body, err := io.ReadAll(data.Body)
+	data.Body.Close()
+	if err != nil || string(body) != content+content {
+		t.Errorf("object has %d bytes, %v, want both parts", len(body), err)
+	}
+}
+
+func TestUploadPartRangeUnknownUpload(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	if _, err := fs.UploadPartRange(ctx, "bucket", "key", "missing", 1, 0, strings.NewReader("x"), 1, 1); err != ErrUploadNotFound {
+		t.Errorf("UploadPartRange = %v, want ErrUploadNotFound", err)
+	}
+	if _, err := fs.GetPartUploadOffset(ctx, "bucket", "key", "missing", 1); err != ErrUploadNotFound {
+		t.Errorf("GetPartUploadOffset = %v, want ErrUploadNotFound", err)
+	}
+}
@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unsignedPayload is the payload hash used when the request body is not signed.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3BlobStore implements BlobStore using an upstream S3-compatible bucket.
+// Requests are signed with AWS Signature V4 using path-style URLs.
+type S3BlobStore struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// NewS3BlobStore creates a new blob store backed by an upstream S3 bucket.
+// If endpoint is empty, https://s3.{region}.amazonaws.com is used.
+func NewS3BlobStore(endpoint, region, bucket, accessKey, secretKey string) (*S3BlobStore, error) {
+	if bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("s3 bucket, access key and secret key are required")
+	}
+
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+
+	return &S3BlobStore{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    newBlobHTTPClient(),
+	}, nil
+}
+
+const (
+	// blobDialTimeout bounds connecting to an upstream blob store, including
+	// the TLS handshake.
+	blobDialTimeout = 10 * time.Second
+	// blobResponseHeaderTimeout bounds waiting for the response headers of
+	// an upstream request once it was sent.
+	blobResponseHeaderTimeout = time.Minute
+)
+
+// newBlobHTTPClient returns the HTTP client of upstream blob stores. Large
+// blobs stream for as long as they take, so instead of a timeout of the
+// whole request, connecting and waiting for the response are bounded; the
+// request context cancels the transfer of the body.
+func newBlobHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: blobDialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = blobDialTimeout
+	transport.ResponseHeaderTimeout = blobResponseHeaderTimeout
+	return &http.Client{Transport: transport}
+}
+
+// objectURL returns the path-style URL of an object in the upstream bucket.
+func (s *S3BlobStore) objectURL(name string) string {
+	return s.endpoint + "/" + awsURIEscape(s.bucket, false) + "/" + awsURIEscape(name, true)
+}
+
+// WithBucket returns a blob store for another bucket of the same endpoint,
+// sharing the credentials and HTTP client.
+func (s *S3BlobStore) WithBucket(bucket string) *S3BlobStore {
+	store := *s
+	store.bucket = bucket
+	return &store
+}
+
+// PutBlob uploads an object to the upstream bucket.
+func (s *S3BlobStore) PutBlob(ctx context.Context, name string, body io.Reader, size int64) error {
+	return s.PutObject(ctx, name, body, size, "application/octet-stream", nil)
+}
+
+// PutObject uploads an object with its content type and user metadata, so it
+// can be read by S3 clients of the upstream bucket as is.
+func (s *S3BlobStore) PutObject(ctx context.Context, name string, body io.Reader, size int64, contentType string, metadata map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(name), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range metadata {
+		req.Header.Set("X-Amz-Meta-"+k, v)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetBlob downloads an object or a range of it from the upstream bucket.
+func (s *S3BlobStore) GetBlob(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	if rangeHeader := formatRange(offset, length); rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// BlobInfo describes an object in the upstream bucket.
+type BlobInfo struct {
+	Size int64
+	// ETag is the entity tag without quotes.
+	ETag string
+}
+
+// StatBlob returns the size and ETag of an object in the upstream bucket, or
+// ErrBlobNotFound if it does not exist.
+func (s *S3BlobStore) StatBlob(ctx context.Context, name string) (BlobInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectURL(name), nil)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	resp.Body.Close()
+	return BlobInfo{
+		Size: resp.ContentLength,
+		ETag: strings.Trim(resp.Header.Get("ETag"), `"`),
+	}, nil
+}
+
+// DeleteBlob deletes an object from the upstream bucket.
+func (s *S3BlobStore) DeleteBlob(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(name), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		if err == ErrBlobNotFound {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do signs and sends a request, converting error responses into errors.
+func (s *S3BlobStore) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature V4 headers to a request.
+func (s *S3BlobStore) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	// Sign host and all x-amz-* headers
+	signedHeaders := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			signedHeaders = append(signedHeaders, lower)
+		}
+	}
+	sort.Strings(signedHeaders)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	// Canonical query string: sorted, URI-encoded parameters
+	query := req.URL.Query()
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, awsURIEscape(name, false)+"="+awsURIEscape(value, false))
+		}
+	}
+	sort.Strings(params)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// hmacSHA256 computes HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEscape encodes a string as required by AWS Signature V4.
+// Only unreserved characters are left as is; slashes are kept when keepSlash is true.
+func awsURIEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && keepSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// errObjectChanged is returned by VerifyObject when the object was overwritten
+// while it was read, so a mismatch says nothing about its integrity.
+var errObjectChanged = errors.New("object changed during verification")
+
+// ObjectLocation identifies an object.
+type ObjectLocation struct {
+	Bucket string
+	Key    string
+}
+
+// ObjectVerification is the result of re-reading an object and checking its
+// content against the size and ETags recorded when it was written.
+type ObjectVerification struct {
+	Bucket     string
+	Key        string
+	VerifiedAt time.Time
+	// BytesRead is the amount of object data read for the check.
+	BytesRead int64
+	// Corruption describes the mismatch found, or is empty if the object is intact.
+	Corruption string
+}
+
+// Corrupted reports whether the object did not match its metadata.
+func (v *ObjectVerification) Corrupted() bool {
+	return v.Corruption != ""
+}
+
+// VerifyObject re-reads an object, checks it against its metadata and records
+// the time of the check.
+func (fs *FileSystem) VerifyObject(ctx context.Context, bucket, key string) (*ObjectVerification, error) {
+	return fs.verifyObject(ctx, bucket, key, fs.GetObject)
+}
+
+// ListObjectsToVerify returns up to limit objects not verified since
+// verifiedBefore, least recently verified first.
+func (fs *FileSystem) ListObjectsToVerify(ctx context.Context, verifiedBefore time.Time, limit int) ([]ObjectLocation, error) {
+	return fs.metadata.ListObjectsToVerify(ctx, verifiedBefore, limit)
+}
+
+// VerifyObject re-reads an object from the blob store and checks it against its metadata.
+func (p *Passthrough) VerifyObject(ctx context.Context, bucket, key string) (*ObjectVerification, error) {
+	return p.verifyObject(ctx, bucket, key, p.GetObject)
+}
+
+// VerifyObject re-reads an object from the tier it is stored in and checks it
+// against its metadata.
+func (t *Tiered) VerifyObject(ctx context.Context, bucket, key string) (*ObjectVerification, error) {
+	return t.verifyObject(ctx, bucket, key, t.GetObject)
+}
+
+// verifyObject verifies an object read with get, which differs between backends.
+func (fs *FileSystem) verifyObject(ctx context.Context, bucket, key string, get func(ctx context.Context, bucket, key string) (*ObjectData, error)) (*ObjectVerification, error) {
+	obj, err := fs.metadata.GetObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, ErrObjectNotFound
+	}
+
+	result := &ObjectVerification{Bucket: bucket, Key: key}
+	data, err := get(ctx, bucket, key)
+	switch {
+	case errors.Is(err, ErrObjectNotFound):
+		result.Corruption = "object data is missing"
+	case err != nil:
+		return nil, err
+	default:
+		result.BytesRead, result.Corruption, err = fs.checkObjectData(ctx, bucket, data)
+		data.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if result.Corrupted() {
+		// Rule out a concurrent overwrite or delete before reporting corruption
+		current, err := fs.metadata.GetObject(ctx, bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil {
+			return nil, ErrObjectNotFound
+		}
+		if current.ETag != obj.ETag || !current.LastModified.Equal(obj.LastModified) {
+			return nil, errObjectChanged
+		}
+	}
+
+	result.VerifiedAt = time.Now().UTC()
+	if err := fs.metadata.PutObjectVerification(ctx, bucket, key, result.VerifiedAt, result.Corruption); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkObjectData reads object data and returns the number of bytes read and
+// the mismatch with the metadata, if any. ETags are only checked in buckets
+// with MD5 ETags; random ETags carry no information about the content.
+func (fs *FileSystem) checkObjectData(ctx context.Context, bucket string, data *ObjectData) (int64, string, error) {
+	parts, err := fs.metadata.GetObjectParts(ctx, bucket, data.Key)
+	if err != nil {
+		return 0, "", err
+	}
+	strategy, err := fs.etagStrategy(ctx, bucket)
+	if err != nil {
+		return 0, "", err
+	}
+	_, checkETags := strategy.(MD5ETags)
+
+	var read int64
+	var corruption string
+	partETags := make([]string, 0, len(parts))
+	for _, part := range parts {
+		hash := md5.New()
+		n, err := io.CopyN(hash, data.Body, part.Size)
+		read += n
+		if errors.Is(err, io.EOF) {
+			return read, fmt.Sprintf("size is %d bytes, expected %d", read, data.Size), nil
+		}
+		if err != nil {
+			return read, "", err
+		}
+		etag := hex.EncodeToString(hash.Sum(nil))
+		if checkETags && corruption == "" && etag != part.ETag {
+			corruption = fmt.Sprintf("part %d has ETag %s, expected %s", part.PartNumber, etag, part.ETag)
+		}
+		partETags = append(partETags, part.ETag)
+	}
+
+	hash := md5.New()
+	n, err := io.Copy(hash, data.Body)
+	read += n
+	if err != nil {
+		return read, "", err
+	}
+	if read != data.Size {
+		return read, fmt.Sprintf("size is %d bytes, expected %d", read, data.Size), nil
+	}
+	if corruption != "" || !checkETags {
+		return read, corruption, nil
+	}
+
+	switch {
+	case len(parts) > 0:
+		if etag := strategy.MultipartETag(partETags); etag != data.ETag {
+			corruption = fmt.Sprintf("part ETags combine to %s, expected %s", etag, data.ETag)
+		}
+	case !strings.Contains(data.ETag, "-"):
+		// Multipart objects written before part layouts were recorded cannot be checked
+		if etag := hex.EncodeToString(hash.Sum(nil)); etag != data.ETag {
+			corruption = fmt.Sprintf("content has ETag %s, expected %s", etag, data.ETag)
+		}
+	}
+	return read, corruption, nil
+}
+
+// ScrubOptions controls a ScrubObjects run.
+type ScrubOptions struct {
+	// MaxObjects bounds the number of objects verified per run.
+	MaxObjects int
+	// ReverifyAfter is how long a verification stays valid. Objects verified
+	// more recently are skipped.
+	ReverifyAfter time.Duration
+	// BytesPerSecond bounds the read rate of the run. Zero is unlimited.
+	BytesPerSecond int64
+	// OnCorruption is called for every corrupted object.
+	OnCorruption func(ObjectVerification)
+}
+
+// ScrubResult summarizes a ScrubObjects run.
+type ScrubResult struct {
+	// Verified is the number of verified objects, including corrupted ones.
+	Verified int
+	// Corrupted is the number of objects that did not match their metadata.
+	Corrupted int
+	// BytesRead is the amount of object data read.
+	BytesRead int64
+}
+
+// ScrubObjects verifies the objects that were never verified or not within
+// ReverifyAfter, least recently verified first, so that repeated runs cycle
+// through all objects. Reads are paced to stay within BytesPerSecond.
+// Errors for single objects are collected and the remaining objects are still
+// processed.
+func ScrubObjects(ctx context.Context, s Storage, opts ScrubOptions) (ScrubResult, error) {
+	var result ScrubResult
+
+	candidates, err := s.ListObjectsToVerify(ctx, time.Now().Add(-opts.ReverifyAfter), opts.MaxObjects)
+	if err != nil {
+		return result, err
+	}
+
+	start := time.Now()
+	var errs []error
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		verification, err := s.VerifyObject(ctx, candidate.Bucket, candidate.Key)
+		if errors.Is(err, ErrObjectNotFound) || errors.Is(err, errObjectChanged) {
+			// Deleted or overwritten while the scrub was running
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to verify %s/%s: %w", candidate.Bucket, candidate.Key, err))
+			continue
+		}
+
+		result.Verified++
+		result.BytesRead += verification.BytesRead
+		if verification.Corrupted() {
+			result.Corrupted++
+			if opts.OnCorruption != nil {
+				opts.OnCorruption(*verification)
+			}
+		}
+
+		if opts.BytesPerSecond > 0 {
+			budget := time.Duration(float64(result.BytesRead) / float64(opts.BytesPerSecond) * float64(time.Second))
+			if wait := budget - time.Since(start); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return result, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
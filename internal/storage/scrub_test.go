@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrubObjects(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	for key, content := range map[string]string{"intact.txt": "hello", "flipped.txt": "hello", "missing.txt": "hello"} {
+		if _, err := fs.PutObject(ctx, "bucket", key, strings.NewReader(content), int64(len(content)), "text/plain", nil); err != nil {
+			t.Fatalf("PutObject: %v", err)
+		}
+	}
+	upload, err := fs.CreateMultipartUpload(ctx, "bucket", "multipart.bin", "application/octet-stream", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	var completed []Part
+	for i, data := range []string{"aaaa", "bb"} {
+		part, err := fs.UploadPart(ctx, "bucket", "multipart.bin", upload.UploadID, int32(i+1), strings.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+		completed = append(completed, Part{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "multipart.bin", upload.UploadID, completed); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+
+	// Corrupt the data behind the metadata's back
+	if err := os.WriteFile(filepath.Join(fs.dataDir, "bucket", "flipped.txt"), []byte("jello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fs.dataDir, "bucket", "multipart.bin"), []byte("aaaabc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(fs.dataDir, "bucket", "missing.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupted := map[string]string{}
+	opts := ScrubOptions{
+		MaxObjects:    100,
+		ReverifyAfter: time.Hour,
+		OnCorruption: func(v ObjectVerification) {
+			corrupted[v.Key] = v.Corruption
+		},
+	}
+	result, err := ScrubObjects(ctx, fs, opts)
+	if err != nil {
+		t.Fatalf("ScrubObjects: %v", err)
+	}
+	if result.Verified != 4 || result.Corrupted != 3 {
+		t.Errorf("result = %+v, want 4 verified and 3 corrupted", result)
+	}
+	for _, key := range []string{"flipped.txt", "missing.txt", "multipart.bin"} {
+		if corrupted[key] == "" {
+			t.Errorf("%s was not reported as corrupted", key)
+		}
+	}
+	if !strings.Contains(corrupted["multipart.bin"], "part 2") {
+		t.Errorf("multipart corruption = %q, want part 2 reported", corrupted["multipart.bin"])
+	}
+
+	verifiedAt, corruption, err := fs.metadata.GetObjectVerification(ctx, "bucket", "intact.txt")
+	if err != nil || verifiedAt.IsZero() || corruption != "" {
+		t.Errorf("GetObjectVerification(intact.txt) = %v, %q, %v", verifiedAt, corruption, err)
+	}
+
+	// Recently verified objects are skipped until they are due again
+	result, err = ScrubObjects(ctx, fs, opts)
+	if err != nil {
+		t.Fatalf("ScrubObjects: %v", err)
+	}
+	if result.Verified != 0 {
+		t.Errorf("second run verified %d objects, want 0", result.Verified)
+	}
+
+	// Overwriting an object resets its verification
+	if _, err := fs.PutObject(ctx, "bucket", "flipped.txt", strings.NewReader("fixed"), 5, "text/plain", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	clear(corrupted)
+	result, err = ScrubObjects(ctx, fs, opts)
+	if err != nil {
+		t.Fatalf("ScrubObjects: %v", err)
+	}
+	if result.Verified != 1 || result.Corrupted != 0 {
+		t.Errorf("after overwrite: result = %+v, want 1 intact object verified", result)
+	}
+}
+
+func TestScrubObjectsBudget(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	content := strings.Repeat("x", 1000)
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := fs.PutObject(ctx, "bucket", key, strings.NewReader(content), int64(len(content)), "text/plain", nil); err != nil {
+			t.Fatalf("PutObject: %v", err)
+		}
+	}
+
+	// MaxObjects limits the run to 2000 bytes, which take 200ms at 10000 bytes per second
+	start := time.Now()
+	result, err := ScrubObjects(ctx, fs, ScrubOptions{MaxObjects: 2, BytesPerSecond: 10000})
+	if err != nil {
+		t.Fatalf("ScrubObjects: %v", err)
+	}
+	if result.Verified != 2 || result.BytesRead != 2000 {
+		t.Errorf("result = %+v, want 2 objects and 2000 bytes", result)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("run took %v, want at least 200ms", elapsed)
+	}
+}
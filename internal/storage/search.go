@@ -0,0 +1,36 @@
+package storage
+
+import "context"
+
+// SearchObjects returns the objects of a bucket having all the given user
+// metadata values and content type (JOG extension), for finding artifacts
+// such as every object with x-amz-meta-build-id 1234. Searches are answered
+// from indexes of the metadata database, without reading object files.
+func (fs *FileSystem) SearchObjects(ctx context.Context, input *SearchObjectsInput) (*SearchObjectsOutput, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, input.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	maxKeys := input.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	objects, err := fs.metadata.SearchObjects(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &SearchObjectsOutput{Objects: objects}
+	if len(objects) > int(maxKeys) {
+		output.Objects = objects[:maxKeys]
+		output.IsTruncated = true
+		output.NextStartAfter = output.Objects[maxKeys-1].Key
+	}
+	return output, nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func searchKeys(t *testing.T, fs *FileSystem, input *SearchObjectsInput) []string {
+	t.Helper()
+	input.Bucket = "bucket"
+	output, err := fs.SearchObjects(context.Background(), input)
+	if err != nil {
+		t.Fatalf("SearchObjects: %v", err)
+	}
+	var keys []string
+	for _, obj := range output.Objects {
+		keys = append(keys, obj.Key)
+	}
+	return keys
+}
+
+func TestSearchObjects(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	for _, obj := range []struct {
+		key, contentType string
+		metadata         map[string]string
+	}{
+		{"a.zip", "application/zip", map[string]string{"build-id": "1234", "branch": "main"}},
+		{"b.zip", "application/zip", map[string]string{"build-id": "1234", "branch": "dev"}},
+		{"c.png", "image/png", map[string]string{"build-id": "999"}},
+		{"d.txt", "text/plain", nil},
+	} {
+		if _, err := fs.PutObject(ctx, "bucket", obj.key, strings.NewReader("data"), 4, obj.contentType, obj.metadata); err != nil {
+			t.Fatalf("PutObject %s: %v", obj.key, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		input SearchObjectsInput
+		want  []string
+	}{
+		{"metadata", SearchObjectsInput{Metadata: map[string]string{"build-id": "1234"}}, []string{"a.zip", "b.zip"}},
+		{"all metadata", SearchObjectsInput{Metadata: map[string]string{"build-id": "1234", "branch": "main"}}, []string{"a.zip"}},
+		{"content type", SearchObjectsInput{ContentType: "text/plain"}, []string{"d.txt"}},
+		{"subtypes", SearchObjectsInput{ContentType: "image/*"}, []string{"c.png"}},
+		{"metadata and content type", SearchObjectsInput{Metadata: map[string]string{"build-id": "999"}, ContentType: "application/zip"}, nil},
+		{"prefix", SearchObjectsInput{Prefix: "b", Metadata: map[string]string{"build-id": "1234"}}, []string{"b.zip"}},
+	}
+	for _, tt := range tests {
+		if got := searchKeys(t, fs, &tt.input); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Pages continue after the last key
+	output, err := fs.SearchObjects(ctx, &SearchObjectsInput{Bucket: "bucket", Metadata: map[string]string{"build-id": "1234"}, MaxKeys: 1})
+	if err != nil {
+		t.Fatalf("SearchObjects: %v", err)
+	}
+	if len(output.Objects) != 1 || !output.IsTruncated || output.NextStartAfter != "a.zip" {
+		t.Fatalf("first page = %+v", output)
+	}
+	if output.Objects[0].Metadata["branch"] != "main" {
+		t.Errorf("metadata = %v, want branch main", output.Objects[0].Metadata)
+	}
+
+	// The index follows metadata changes and deletes
+	if err := fs.metadata.UpdateObjectMetadata(ctx, "bucket", "b.zip", map[string]string{"build-id": "1235"}, output.Objects[0].LastModified); err != nil {
+		t.Fatalf("UpdateObjectMetadata: %v", err)
+	}
+	if err := fs.DeleteObject(ctx, "bucket", "a.zip"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if got := searchKeys(t, fs, &SearchObjectsInput{Metadata: map[string]string{"build-id": "1234"}}); got != nil {
+		t.Errorf("after update and delete: got %v, want none", got)
+	}
+
+	// Databases without the index get it filled from the objects
+	if _, err := fs.metadata.db.Exec(`DROP TABLE object_metadata`); err != nil {
+		t.Fatalf("DROP TABLE: %v", err)
+	}
+	if err := fs.metadata.initialize(); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if got := searchKeys(t, fs, &SearchObjectsInput{Metadata: map[string]string{"build-id": "1235"}}); !slices.Equal(got, []string{"b.zip"}) {
+		t.Errorf("after backfill: got %v, want [b.zip]", got)
+	}
+
+	if _, err := fs.SearchObjects(ctx, &SearchObjectsInput{Bucket: "missing", ContentType: "text/plain"}); err != ErrBucketNotFound {
+		t.Errorf("SearchObjects on a missing bucket: err = %v, want ErrBucketNotFound", err)
+	}
+}
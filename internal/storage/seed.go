@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SeedManifest describes the buckets and objects a seed creates.
+type SeedManifest struct {
+	Buckets []SeedBucket `yaml:"buckets"`
+}
+
+// SeedBucket describes a bucket of a seed manifest.
+type SeedBucket struct {
+	Name       string            `yaml:"name"`
+	Versioning bool              `yaml:"versioning"`
+	Tags       map[string]string `yaml:"tags"`
+	Objects    []SeedObject      `yaml:"objects"`
+}
+
+// SeedObject describes an object of a seed manifest. Its data is Content, the
+// file at File or, with Size, generated bytes that depend only on the bucket
+// and key, so that every seed creates the same object. Without any of them
+// the object is empty.
+type SeedObject struct {
+	Key     string `yaml:"key"`
+	Content string `yaml:"content"`
+	// File is relative to the directory of the manifest.
+	File        string            `yaml:"file"`
+	Size        int64             `yaml:"size"`
+	ContentType string            `yaml:"content_type"`
+	Metadata    map[string]string `yaml:"metadata"`
+	Tags        map[string]string `yaml:"tags"`
+}
+
+// SeedResult reports what a seed created.
+type SeedResult struct {
+	Buckets int
+	Objects int
+	Bytes   int64
+}
+
+// LoadSeedManifest reads a YAML seed manifest. Unknown fields are rejected,
+// so that misspelled fields do not silently seed different data.
+func LoadSeedManifest(r io.Reader) (*SeedManifest, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var manifest SeedManifest
+	if err := dec.Decode(&manifest); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid seed manifest: %w", err)
+	}
+	if err := manifest.validate(); err != nil {
+		return nil, fmt.Errorf("invalid seed manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// validate checks that buckets and objects are named and that objects have
+// at most one source of data.
+func (m *SeedManifest) validate() error {
+	for i, bucket := range m.Buckets {
+		if bucket.Name == "" {
+			return fmt.Errorf("bucket %d has no name", i)
+		}
+		for j, obj := range bucket.Objects {
+			if obj.Key == "" {
+				return fmt.Errorf("object %d of bucket %s has no key", j, bucket.Name)
+			}
+			sources := 0
+			for _, set := range []bool{obj.Content != "", obj.File != "", obj.Size != 0} {
+				if set {
+					sources++
+				}
+			}
+			if sources > 1 {
+				return fmt.Errorf("object %s/%s must have only one of content, file and size", bucket.Name, obj.Key)
+			}
+			if obj.Size < 0 {
+				return fmt.Errorf("object %s/%s has a negative size", bucket.Name, obj.Key)
+			}
+		}
+	}
+	return nil
+}
+
+// Seed creates the buckets and objects of a manifest, resolving object files
+// relative to baseDir. Existing buckets are reused and existing objects are
+// overwritten, so a seed can be applied again.
+func Seed(ctx context.Context, s Storage, manifest *SeedManifest, baseDir string) (*SeedResult, error) {
+	result := &SeedResult{}
+	for _, bucket := range manifest.Buckets {
+		if err := s.CreateBucket(ctx, bucket.Name); err != nil && !errors.Is(err, ErrBucketAlreadyExists) {
+			return nil, fmt.Errorf("failed to create bucket %s: %w", bucket.Name, err)
+		}
+		if bucket.Versioning {
+			if err := s.PutBucketVersioning(ctx, bucket.Name, VersioningStatusEnabled); err != nil {
+				return nil, fmt.Errorf("failed to enable versioning of %s: %w", bucket.Name, err)
+			}
+		}
+		if len(bucket.Tags) > 0 {
+			if err := s.PutBucketTagging(ctx, bucket.Name, seedTags(bucket.Tags)); err != nil {
+				return nil, fmt.Errorf("failed to tag bucket %s: %w", bucket.Name, err)
+			}
+		}
+		result.Buckets++
+
+		for _, obj := range bucket.Objects {
+			size, err := seedObject(ctx, s, bucket.Name, obj, baseDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to seed %s/%s: %w", bucket.Name, obj.Key, err)
+			}
+			result.Objects++
+			result.Bytes += size
+		}
+	}
+	return result, nil
+}
+
+// seedObject creates an object of a manifest and returns its size.
+func seedObject(ctx context.Context, s Storage, bucket string, obj SeedObject, baseDir string) (int64, error) {
+	contentType := obj.ContentType
+	var body io.Reader
+	var size int64
+	switch {
+	case obj.File != "":
+		localPath := obj.File
+		if !filepath.IsAbs(localPath) {
+			localPath = filepath.Join(baseDir, localPath)
+		}
+		f, err := os.Open(localPath)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		if contentType == "" {
+			if contentType, err = guessContentType(localPath); err != nil {
+				return 0, err
+			}
+		}
+		body, size = f, info.Size()
+	case obj.Size > 0:
+		body, size = io.LimitReader(seedData(bucket, obj.Key), obj.Size), obj.Size
+	default:
+		body, size = strings.NewReader(obj.Content), int64(len(obj.Content))
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(obj.Key))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	if _, err := s.PutObject(ctx, bucket, obj.Key, body, size, contentType, obj.Metadata); err != nil {
+		return 0, err
+	}
+	if len(obj.Tags) > 0 {
+		if err := s.PutObjectTagging(ctx, bucket, obj.Key, seedTags(obj.Tags)); err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// seedData returns the endless pseudo-random data of a generated object,
+// seeded by its bucket and key.
+func seedData(bucket, key string) io.Reader {
+	seed := sha256.Sum256([]byte(bucket + "/" + key))
+	return rand.NewChaCha8(seed)
+}
+
+// seedTags converts a tag map to tags ordered by key.
+func seedTags(m map[string]string) []Tag {
+	tags := make([]Tag, 0, len(m))
+	for key, value := range m {
+		tags = append(tags, Tag{Key: key, Value: value})
+	}
+	slices.SortFunc(tags, func(a, b Tag) int { return strings.Compare(a.Key, b.Key) })
+	return tags
+}
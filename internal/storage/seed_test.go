@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testSeedManifest = `
+buckets:
+  - name: fixtures
+    versioning: true
+    tags:
+      env: test
+    objects:
+      - key: hello.txt
+        content: hello
+        metadata:
+          owner: alice
+        tags:
+          kind: greeting
+      - key: docs/page.html
+        file: page.html
+      - key: blobs/random.bin
+        size: 5000
+`
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), []byte("<html></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := LoadSeedManifest(strings.NewReader(testSeedManifest))
+	if err != nil {
+		t.Fatalf("LoadSeedManifest: %v", err)
+	}
+
+	var etags []string
+	for _, fs := range []*FileSystem{newTestFileSystem(t), newTestFileSystem(t)} {
+		result, err := Seed(ctx, fs, manifest, dir)
+		if err != nil {
+			t.Fatalf("Seed: %v", err)
+		}
+		if result.Buckets != 1 || result.Objects != 3 || result.Bytes != 5+13+5000 {
+			t.Errorf("result = %+v", result)
+		}
+
+		if got := readObject(t, fs, "fixtures", "hello.txt"); got != "hello" {
+			t.Errorf("hello.txt = %q", got)
+		}
+		obj, err := fs.HeadObject(ctx, "fixtures", "hello.txt")
+		if err != nil {
+			t.Fatalf("HeadObject: %v", err)
+		}
+		if obj.ContentType != "text/plain; charset=utf-8" || obj.Metadata["owner"] != "alice" {
+			t.Errorf("hello.txt content type %q, metadata %v", obj.ContentType, obj.Metadata)
+		}
+		tags, err := fs.GetObjectTagging(ctx, "fixtures", "hello.txt")
+		if err != nil || !slices.Equal(tags, []Tag{{Key: "kind", Value: "greeting"}}) {
+			t.Errorf("hello.txt tags = %v, %v", tags, err)
+		}
+		if got := readObject(t, fs, "fixtures", "docs/page.html"); got != "<html></html>" {
+			t.Errorf("docs/page.html = %q", got)
+		}
+		bucketTags, err := fs.GetBucketTagging(ctx, "fixtures")
+		if err != nil || !slices.Equal(bucketTags, []Tag{{Key: "env", Value: "test"}}) {
+			t.Errorf("bucket tags = %v, %v", bucketTags, err)
+		}
+		if status, _ := fs.GetBucketVersioning(ctx, "fixtures"); status != VersioningStatusEnabled {
+			t.Errorf("versioning = %q", status)
+		}
+
+		random, err := fs.HeadObject(ctx, "fixtures", "blobs/random.bin")
+		if err != nil {
+			t.Fatalf("HeadObject: %v", err)
+		}
+		if random.Size != 5000 {
+			t.Errorf("random.bin size = %d", random.Size)
+		}
+		etags = append(etags, random.ETag)
+	}
+
+	// Generated data is the same on every seed
+	if etags[0] != etags[1] {
+		t.Errorf("generated objects differ: %v", etags)
+	}
+}
+
+func TestLoadSeedManifestInvalid(t *testing.T) {
+	for _, manifest := range []string{
+		"buckets:\n  - objects: []\n",
+		"buckets:\n  - name: b\n    objects:\n      - content: x\n",
+		"buckets:\n  - name: b\n    objects:\n      - key: k\n        content: x\n        size: 10\n",
+		"buckets:\n  - name: b\n    objects:\n      - key: k\n        size: -1\n",
+		"buckets:\n  - name: b\n    object: []\n",
+	} {
+		if _, err := LoadSeedManifest(strings.NewReader(manifest)); err == nil {
+			t.Errorf("LoadSeedManifest(%q) succeeded", manifest)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ServiceAccount is an access key minted for an application. It acts as the
+// access key that minted it, restricted to a bucket, a key prefix and a set
+// of actions.
+type ServiceAccount struct {
+	AccessKey string
+	// SecretHash is the hex encoded SHA-256 hash of the secret key; the
+	// secret key itself is never stored.
+	SecretHash string
+	// Parent is the access key that minted the service account.
+	Parent  string
+	Bucket  string
+	Prefix  string
+	Actions []string
+	// Description is a note of what the service account is used for.
+	Description string
+	Created     time.Time
+	// Expires is zero for service accounts that do not expire.
+	Expires time.Time
+}
+
+// Expired reports whether the service account has expired at t.
+func (a *ServiceAccount) Expired(t time.Time) bool {
+	return !a.Expires.IsZero() && !t.Before(a.Expires)
+}
+
+// PutServiceAccount stores a service account, replacing one with the same
+// access key.
+func (m *Metadata) PutServiceAccount(ctx context.Context, account *ServiceAccount) error {
+	actions, err := json.Marshal(account.Actions)
+	if err != nil {
+		return err
+	}
+	var expires int64
+	if !account.Expires.IsZero() {
+		expires = account.Expires.UnixNano()
+	}
+	_, err = m.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO service_accounts
+			(access_key, secret_hash, parent, bucket, prefix, actions, description, created, expires)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, account.AccessKey, account.SecretHash, account.Parent, account.Bucket, account.Prefix,
+		string(actions), account.Description, account.Created.UnixNano(), expires)
+	return err
+}
+
+// GetServiceAccount returns the service account of an access key, or
+// ErrNoSuchServiceAccount.
+func (m *Metadata) GetServiceAccount(ctx context.Context, accessKey string) (*ServiceAccount, error) {
+	row := m.db.QueryRowContext(ctx, `
+		SELECT access_key, secret_hash, parent, bucket, prefix, actions, description, created, expires
+		FROM service_accounts WHERE access_key = ?
+	`, accessKey)
+	account, err := scanServiceAccount(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSuchServiceAccount
+	}
+	return account, err
+}
+
+// ListServiceAccounts returns the service accounts minted by an access key,
+// ordered by access key.
+func (m *Metadata) ListServiceAccounts(ctx context.Context, parent string) ([]ServiceAccount, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT access_key, secret_hash, parent, bucket, prefix, actions, description, created, expires
+		FROM service_accounts WHERE parent = ? ORDER BY access_key
+	`, parent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []ServiceAccount
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	return accounts, rows.Err()
+}
+
+// DeleteServiceAccount deletes a service account, or returns
+// ErrNoSuchServiceAccount.
+func (m *Metadata) DeleteServiceAccount(ctx context.Context, accessKey string) error {
+	result, err := m.db.ExecContext(ctx, `DELETE FROM service_accounts WHERE access_key = ?`, accessKey)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNoSuchServiceAccount
+	}
+	return err
+}
+
+// scanServiceAccount scans a row of the service_accounts table.
+func scanServiceAccount(row interface{ Scan(...any) error }) (*ServiceAccount, error) {
+	var account ServiceAccount
+	var actions string
+	var created, expires int64
+	if err := row.Scan(&account.AccessKey, &account.SecretHash, &account.Parent, &account.Bucket,
+		&account.Prefix, &actions, &account.Description, &created, &expires); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(actions), &account.Actions); err != nil {
+		return nil, err
+	}
+	account.Created = time.Unix(0, created).UTC()
+	if expires != 0 {
+		account.Expires = time.Unix(0, expires).UTC()
+	}
+	return &account, nil
+}
+
+// PutServiceAccount stores a service account in the metadata database.
+func (fs *FileSystem) PutServiceAccount(ctx context.Context, account *ServiceAccount) error {
+	return fs.metadata.PutServiceAccount(ctx, account)
+}
+
+// GetServiceAccount reads a service account from the metadata database.
+func (fs *FileSystem) GetServiceAccount(ctx context.Context, accessKey string) (*ServiceAccount, error) {
+	return fs.metadata.GetServiceAccount(ctx, accessKey)
+}
+
+// ListServiceAccounts lists service accounts in the metadata database.
+func (fs *FileSystem) ListServiceAccounts(ctx context.Context, parent string) ([]ServiceAccount, error) {
+	return fs.metadata.ListServiceAccounts(ctx, parent)
+}
+
+// DeleteServiceAccount deletes a service account from the metadata database.
+func (fs *FileSystem) DeleteServiceAccount(ctx context.Context, accessKey string) error {
+	return fs.metadata.DeleteServiceAccount(ctx, accessKey)
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServiceAccounts(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	account := &ServiceAccount{
+		AccessKey:   "JOGSA1",
+		SecretHash:  "hash",
+		Parent:      "admin",
+		Bucket:      "backups",
+		Prefix:      "db/",
+		Actions:     []string{"s3:PutObject", "s3:ListBucket"},
+		Description: "nightly dumps",
+		Created:     created,
+	}
+	if err := fs.PutServiceAccount(ctx, account); err != nil {
+		t.Fatalf("PutServiceAccount: %v", err)
+	}
+	expiring := &ServiceAccount{AccessKey: "JOGSA2", SecretHash: "hash", Parent: "admin", Bucket: "logs",
+		Actions: []string{"s3:GetObject"}, Created: created, Expires: created.Add(time.Hour)}
+	if err := fs.PutServiceAccount(ctx, expiring); err != nil {
+		t.Fatalf("PutServiceAccount: %v", err)
+	}
+
+	got, err := fs.GetServiceAccount(ctx, "JOGSA1")
+	if err != nil {
+		t.Fatalf("GetServiceAccount: %v", err)
+	}
+	if !reflect.DeepEqual(got, account) {
+		t.Errorf("GetServiceAccount = %+v, want %+v", got, account)
+	}
+	if got.Expired(created.Add(1000 * time.Hour)) {
+		t.Error("service account without expiration expired")
+	}
+	got, err = fs.GetServiceAccount(ctx, "JOGSA2")
+	if err != nil {
+		t.Fatalf("GetServiceAccount: %v", err)
+	}
+	if got.Expired(created) || !got.Expired(created.Add(time.Hour)) {
+		t.Errorf("service account expiring at %v has wrong expiration", got.Expires)
+	}
+
+	accounts, err := fs.ListServiceAccounts(ctx, "admin")
+	if err != nil {
+		t.Fatalf("ListServiceAccounts: %v", err)
+	}
+	if len(accounts) != 2 || accounts[0].AccessKey != "JOGSA1" || accounts[1].AccessKey != "JOGSA2" {
+		t.Errorf("ListServiceAccounts = %+v, want JOGSA1 and JOGSA2", accounts)
+	}
+	if accounts, _ := fs.ListServiceAccounts(ctx, "other"); len(accounts) != 0 {
+		t.Errorf("ListServiceAccounts of another parent = %+v, want none", accounts)
+	}
+
+	if err := fs.DeleteServiceAccount(ctx, "JOGSA1"); err != nil {
+		t.Fatalf("DeleteServiceAccount: %v", err)
+	}
+	if _, err := fs.GetServiceAccount(ctx, "JOGSA1"); err != ErrNoSuchServiceAccount {
+		t.Errorf("GetServiceAccount after delete = %v, want ErrNoSuchServiceAccount", err)
+	}
+	if err := fs.DeleteServiceAccount(ctx, "JOGSA1"); err != ErrNoSuchServiceAccount {
+		t.Errorf("DeleteServiceAccount twice = %v, want ErrNoSuchServiceAccount", err)
+	}
+}
@@ -0,0 +1,405 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+
+	"github.com/kumasuke/jog/internal/kms"
+)
+
+// sseSegmentSize is the plaintext size of the segments encrypted objects are
+// stored in. Each segment is sealed separately with AES-256-GCM, so that
+// range reads only decrypt the segments they cover.
+const sseSegmentSize = 64 * 1024
+
+// sseTagSize is the size of the authentication tag added to each segment.
+const sseTagSize = 16
+
+// errObjectAuthentication is returned for encrypted object data that was
+// modified or truncated.
+var errObjectAuthentication = errors.New("encrypted object data failed authentication")
+
+// ServerSideEncryption is the server-side encryption requested for an object
+// write with the x-amz-server-side-encryption headers.
+type ServerSideEncryption struct {
+	Algorithm SSEAlgorithm
+	// KMSKeyID is the master key of SSE-KMS requests. Empty selects the key
+	// of the bucket default encryption or the server default key.
+	KMSKeyID string
+	// Context is the encryption context of SSE-KMS requests.
+	Context map[string]string
+}
+
+// ObjectEncryption describes how an object is encrypted at rest.
+type ObjectEncryption struct {
+	Algorithm SSEAlgorithm
+	// KMSKeyID is the master key the data key is wrapped with.
+	KMSKeyID string
+	// Context is the encryption context the data key is wrapped with.
+	Context map[string]string
+	// WrappedKey is the data key of the object, wrapped with KMSKeyID.
+	WrappedKey []byte
+}
+
+// SetKMS enables encryption at rest. Objects written with server-side
+// encryption, or to buckets with default encryption, are encrypted with a
+// data key of their own, wrapped by provider. defaultKeyID wraps the data
+// keys of SSE-S3 objects and of SSE-KMS objects without key ID.
+func (fs *FileSystem) SetKMS(provider kms.Provider, defaultKeyID string) {
+	fs.kms = provider
+	fs.kmsDefaultKeyID = defaultKeyID
+}
+
+// GetObjectEncryption returns how an object, or a version of it, is
+// encrypted at rest, or nil if it is stored in plaintext.
+func (fs *FileSystem) GetObjectEncryption(ctx context.Context, bucket, key, versionID string) (*ObjectEncryption, error) {
+	return fs.metadata.GetObjectEncryption(ctx, bucket, key, versionID)
+}
+
+// objectEncryption returns the encryption of an object written to bucket:
+// the encryption requested with the write, or else the default encryption
+// of the bucket. It returns nil if the object is stored in plaintext, which
+// is the case for all objects without a KMS.
+func (fs *FileSystem) objectEncryption(ctx context.Context, bucket string) (*ServerSideEncryption, error) {
+	if sse := ServerSideEncryptionFromContext(ctx); sse != nil {
+		if fs.kms == nil {
+			// SSE-S3 requests were accepted before encryption at rest existed
+			if sse.Algorithm != SSEAlgorithmAES256 {
+				return nil, ErrKMSNotConfigured
+			}
+			return nil, nil
+		}
+		return sse, nil
+	}
+	if fs.kms == nil {
+		return nil, nil
+	}
+
+	configJSON, err := fs.metadata.GetBucketEncryption(ctx, bucket)
+	if err != nil || configJSON == "" {
+		return nil, err
+	}
+	var config ServerSideEncryptionConfiguration
+	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+		return nil, err
+	}
+	for _, rule := range config.Rules {
+		if d := rule.ApplyServerSideEncryptionByDefault; d != nil && d.SSEAlgorithm != "" {
+			return &ServerSideEncryption{Algorithm: d.SSEAlgorithm, KMSKeyID: d.KMSMasterKeyID}, nil
+		}
+	}
+	return nil, nil
+}
+
+// newObjectKey generates the data key of an object encrypted with sse and
+// returns it with the encryption to record for the object.
+func (fs *FileSystem) newObjectKey(ctx context.Context, bucket, key string, sse *ServerSideEncryption) (*ObjectEncryption, []byte, error) {
+	keyID := sse.KMSKeyID
+	if keyID == "" || sse.Algorithm == SSEAlgorithmAES256 {
+		keyID = fs.kmsDefaultKeyID
+	}
+	if keyID == "" {
+		return nil, nil, fmt.Errorf("%w: no key ID given and no default key configured", kms.ErrKeyNotFound)
+	}
+
+	// Like S3, bind the data key to the object ARN
+	encCtx := maps.Clone(sse.Context)
+	if encCtx == nil {
+		encCtx = make(map[string]string)
+	}
+	encCtx["aws:s3:arn"] = "arn:aws:s3:::" + bucket + "/" + key
+
+	dataKey, wrapped, err := kms.GenerateDataKey(ctx, fs.kms, keyID, encCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ObjectEncryption{
+		Algorithm:  sse.Algorithm,
+		KMSKeyID:   keyID,
+		Context:    encCtx,
+		WrappedKey: wrapped,
+	}, dataKey, nil
+}
+
+// prepareEncryption wraps w to encrypt an object with sse, as resolved by
+// objectEncryption. It returns w itself and a nil encryption for objects
+// stored in plaintext. Close finishes the encrypted stream but does not
+// close w.
+func (fs *FileSystem) prepareEncryption(ctx context.Context, bucket, key string, sse *ServerSideEncryption, w io.Writer) (io.WriteCloser, *ObjectEncryption, error) {
+	if sse == nil {
+		return nopWriteCloser{w}, nil, nil
+	}
+	enc, dataKey, err := fs.newObjectKey(ctx, bucket, key, sse)
+	if err != nil {
+		return nil, nil, err
+	}
+	encryptor, err := newEncryptWriter(w, dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encryptor, enc, nil
+}
+
+// putObjectAtRest stores the metadata of an object whose file was written
+// together with how it is encrypted and compressed. If this fails, the
+// metadata of an encrypted or compressed object is removed, as its file no
+// longer holds the data the previous metadata describes.
+func (fs *FileSystem) putObjectAtRest(ctx context.Context, bucket string, obj *Object, enc *ObjectEncryption, compression CompressionAlgorithm) error {
+	algorithm := string(compression)
+	if compression == CompressionNone {
+		algorithm = ""
+	}
+	if err := fs.metadata.PutObjectAtRest(ctx, bucket, obj, enc, algorithm); err != nil {
+		if enc != nil || algorithm != "" {
+			_ = fs.metadata.DeleteObject(ctx, bucket, obj.Key)
+		}
+		return err
+	}
+	return nil
+}
+
+// decryptObjectFile returns a reader of the plaintext of an encrypted object
+// file from offset on. It takes ownership of file.
+func (fs *FileSystem) decryptObjectFile(ctx context.Context, file *os.File, enc *ObjectEncryption, offset int64) (io.ReadCloser, error) {
+	if fs.kms == nil {
+		file.Close()
+		return nil, ErrKMSNotConfigured
+	}
+	dataKey, err := fs.kms.Decrypt(ctx, enc.KMSKeyID, enc.WrappedKey, enc.Context)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	body, err := newDecryptReader(file, dataKey, offset)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return body, nil
+}
+
+// RewrapFilter selects the objects RewrapObjectKeys rewraps. Empty fields
+// match all objects.
+type RewrapFilter struct {
+	Bucket   string
+	KMSKeyID string
+}
+
+// RewrapObjectKeys unwraps the data keys of the encrypted objects matching
+// filter and wraps them again with toKeyID, or with the current version of
+// their own master key if toKeyID is empty. Object data is not rewritten.
+// fn, if not nil, is called for every rewrapped object. It returns the
+// number of rewrapped objects.
+func (fs *FileSystem) RewrapObjectKeys(ctx context.Context, filter RewrapFilter, toKeyID string, fn func(bucket, key, versionID string)) (int, error) {
+	if fs.kms == nil {
+		return 0, ErrKMSNotConfigured
+	}
+	refs, err := fs.metadata.ListObjectEncryption(ctx, filter.Bucket, filter.KMSKeyID)
+	if err != nil {
+		return 0, err
+	}
+
+	rewrapped := 0
+	for _, ref := range refs {
+		enc := ref.Encryption
+		dataKey, err := fs.kms.Decrypt(ctx, enc.KMSKeyID, enc.WrappedKey, enc.Context)
+		if err != nil {
+			return rewrapped, fmt.Errorf("failed to unwrap key of %s/%s: %w", ref.Bucket, ref.Key, err)
+		}
+		keyID := enc.KMSKeyID
+		if toKeyID != "" {
+			keyID = toKeyID
+		}
+		wrapped, err := fs.kms.Encrypt(ctx, keyID, dataKey, enc.Context)
+		if err != nil {
+			return rewrapped, fmt.Errorf("failed to wrap key of %s/%s: %w", ref.Bucket, ref.Key, err)
+		}
+		if err := fs.metadata.UpdateObjectEncryptionKey(ctx, ref.Bucket, ref.Key, ref.VersionID, keyID, wrapped); err != nil {
+			return rewrapped, err
+		}
+		rewrapped++
+		if fn != nil {
+			fn(ref.Bucket, ref.Key, ref.VersionID)
+		}
+	}
+	return rewrapped, nil
+}
+
+// newSegmentCipher returns the AES-256-GCM cipher of a data key.
+func newSegmentCipher(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentNonce returns the nonce of a segment: its sequence number, with
+// the top bit set for the final segment so that truncation is detected.
+// Every object has a data key of its own, so nonces are never reused.
+func segmentNonce(nonce []byte, seq uint64, final bool) []byte {
+	clear(nonce)
+	if final {
+		nonce[0] = 0x80
+	}
+	binary.BigEndian.PutUint64(nonce[4:], seq)
+	return nonce
+}
+
+// encryptWriter encrypts a stream in sealed segments of sseSegmentSize
+// plaintext bytes.
+type encryptWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	nonce []byte
+	seq   uint64
+}
+
+func newEncryptWriter(w io.Writer, dataKey []byte) (*encryptWriter, error) {
+	aead, err := newSegmentCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:     w,
+		aead:  aead,
+		buf:   make([]byte, 0, sseSegmentSize+sseTagSize),
+		nonce: make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full segment is only sealed once more data follows, as the last
+		// segment is sealed as final by Close
+		if len(e.buf) == sseSegmentSize {
+			if err := e.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := min(len(p), sseSegmentSize-len(e.buf))
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the final segment.
+func (e *encryptWriter) Close() error {
+	return e.flush(true)
+}
+
+func (e *encryptWriter) flush(final bool) error {
+	sealed := e.aead.Seal(e.buf[:0], segmentNonce(e.nonce, e.seq, final), e.buf, nil)
+	e.seq++
+	_, err := e.w.Write(sealed)
+	e.buf = e.buf[:0]
+	return err
+}
+
+// decryptReader decrypts an object file written by encryptWriter.
+type decryptReader struct {
+	r       *bufio.Reader
+	file    io.Closer
+	aead    cipher.AEAD
+	nonce   []byte
+	seq     uint64
+	segment []byte
+	// plain holds the decrypted data of the current segment not yet read
+	plain []byte
+	// skip is the number of plaintext bytes to drop from the first segment
+	skip int
+	done bool
+}
+
+// newDecryptReader returns a reader of the plaintext of file from offset on.
+func newDecryptReader(file *os.File, dataKey []byte, offset int64) (*decryptReader, error) {
+	aead, err := newSegmentCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	seq, skip := offset/sseSegmentSize, offset%sseSegmentSize
+	// An offset at a segment boundary starts with the segment before it,
+	// which is the final segment if the offset is the object size
+	if seq > 0 && skip == 0 {
+		seq, skip = seq-1, sseSegmentSize
+	}
+	if seq > 0 {
+		if _, err := file.Seek(seq*(sseSegmentSize+sseTagSize), io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek: %w", err)
+		}
+	}
+	return &decryptReader{
+		r:       bufio.NewReader(file),
+		file:    file,
+		aead:    aead,
+		nonce:   make([]byte, aead.NonceSize()),
+		seq:     uint64(seq),
+		segment: make([]byte, sseSegmentSize+sseTagSize),
+		skip:    int(skip),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// next decrypts the next segment.
+func (d *decryptReader) next() error {
+	n, err := io.ReadFull(d.r, d.segment)
+	final := false
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		final = true
+	case err == io.EOF:
+		// The final segment is missing
+		return errObjectAuthentication
+	case err != nil:
+		return err
+	default:
+		_, err := d.r.Peek(1)
+		final = err == io.EOF
+	}
+	if n < sseTagSize {
+		return errObjectAuthentication
+	}
+
+	plain, err := d.aead.Open(d.segment[:0], segmentNonce(d.nonce, d.seq, final), d.segment[:n], nil)
+	if err != nil {
+		return errObjectAuthentication
+	}
+	d.seq++
+	d.done = final
+	if d.skip > 0 {
+		plain = plain[min(d.skip, len(plain)):]
+		d.skip = 0
+	}
+	d.plain = plain
+	return nil
+}
+
+func (d *decryptReader) Close() error {
+	return d.file.Close()
+}
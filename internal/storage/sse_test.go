@@ -0,0 +1,373 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/kms"
+)
+
+// newTestKMS returns a local KMS provider with master keys "key" and "other".
+func newTestKMS(t *testing.T) kms.Provider {
+	t.Helper()
+	key := func(b byte) string {
+		return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+	}
+	provider, err := kms.New(config.KMSConfig{
+		Provider: "local",
+		Local: config.LocalKMSConfig{Keys: []config.LocalKMSKey{
+			{ID: "key", Versions: []string{key(1)}},
+			{ID: "other", Versions: []string{key(2)}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("kms.New: %v", err)
+	}
+	return provider
+}
+
+func TestEncryptedSegments(t *testing.T) {
+	dataKey := make([]byte, kms.DataKeySize)
+	rand.Read(dataKey)
+
+	for _, size := range []int{0, 1, sseSegmentSize - 1, sseSegmentSize, sseSegmentSize + 1, 3*sseSegmentSize + 100} {
+		content := make([]byte, size)
+		rand.Read(content)
+
+		path := filepath.Join(t.TempDir(), "object")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := newEncryptWriter(f, dataKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Write in odd-sized chunks to cross segment boundaries
+		for data := content; len(data) > 0; {
+			n := min(len(data), 1000)
+			if _, err := w.Write(data[:n]); err != nil {
+				t.Fatal(err)
+			}
+			data = data[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		for _, offset := range []int{0, 1, sseSegmentSize - 1, sseSegmentSize, sseSegmentSize + 7, size} {
+			if offset > size {
+				continue
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := newDecryptReader(f, dataKey, int64(offset))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatalf("size %d offset %d: %v", size, offset, err)
+			}
+			if !bytes.Equal(got, content[offset:]) {
+				t.Errorf("size %d offset %d: read %d bytes, want %d", size, offset, len(got), size-offset)
+			}
+		}
+	}
+}
+
+func TestEncryptedSegmentsTampering(t *testing.T) {
+	dataKey := make([]byte, kms.DataKeySize)
+	rand.Read(dataKey)
+	content := bytes.Repeat([]byte("x"), 2*sseSegmentSize+10)
+
+	var buf bytes.Buffer
+	w, _ := newEncryptWriter(&buf, dataKey)
+	w.Write(content)
+	w.Close()
+	stored := buf.Bytes()
+
+	read := func(data []byte) error {
+		path := filepath.Join(t.TempDir(), "object")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := newDecryptReader(f, dataKey, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		_, err = io.ReadAll(r)
+		return err
+	}
+
+	if err := read(stored); err != nil {
+		t.Fatalf("unmodified data: %v", err)
+	}
+	modified := bytes.Clone(stored)
+	modified[sseSegmentSize+100] ^= 1
+	if err := read(modified); !errors.Is(err, errObjectAuthentication) {
+		t.Errorf("modified data: err = %v, want errObjectAuthentication", err)
+	}
+	// Dropping the final segment leaves a stream of complete segments
+	if err := read(stored[:2*(sseSegmentSize+sseTagSize)]); !errors.Is(err, errObjectAuthentication) {
+		t.Errorf("truncated data: err = %v, want errObjectAuthentication", err)
+	}
+}
+
+func TestFileSystemEncryption(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	fs.SetKMS(newTestKMS(t), "key")
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	content := strings.Repeat("secret data ", 20000)
+	put := func(ctx context.Context, key string) {
+		t.Helper()
+		if _, err := fs.PutObject(ctx, "bucket", key, strings.NewReader(content), int64(len(content)), "text/plain", nil); err != nil {
+			t.Fatalf("PutObject(%s): %v", key, err)
+		}
+	}
+	get := func(key string) string {
+		t.Helper()
+		data, err := fs.GetObject(ctx, "bucket", key)
+		if err != nil {
+			t.Fatalf("GetObject(%s): %v", key, err)
+		}
+		defer data.Body.Close()
+		body, err := io.ReadAll(data.Body)
+		if err != nil {
+			t.Fatalf("GetObject(%s): %v", key, err)
+		}
+		return string(body)
+	}
+	encryption := func(key string) *ObjectEncryption {
+		t.Helper()
+		enc, err := fs.GetObjectEncryption(ctx, "bucket", key, "")
+		if err != nil {
+			t.Fatalf("GetObjectEncryption(%s): %v", key, err)
+		}
+		return enc
+	}
+
+	put(ctx, "plain")
+	if enc := encryption("plain"); enc != nil {
+		t.Errorf("object without encryption is encrypted: %+v", enc)
+	}
+
+	kmsCtx := WithServerSideEncryption(ctx, &ServerSideEncryption{
+		Algorithm: SSEAlgorithmKMS,
+		KMSKeyID:  "other",
+		Context:   map[string]string{"team": "a"},
+	})
+	put(kmsCtx, "kms")
+	enc := encryption("kms")
+	if enc == nil || enc.Algorithm != SSEAlgorithmKMS || enc.KMSKeyID != "other" || enc.Context["team"] != "a" {
+		t.Fatalf("encryption = %+v", enc)
+	}
+	stored, err := os.ReadFile(filepath.Join(fs.dataDir, "bucket", "kms"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, []byte("secret data")) {
+		t.Error("object stored in plaintext")
+	}
+	if got := get("kms"); got != content {
+		t.Errorf("GetObject returned %d bytes, want %d", len(got), len(content))
+	}
+	data, err := fs.GetObjectRange(ctx, "bucket", "kms", 70000, 70011)
+	if err != nil {
+		t.Fatalf("GetObjectRange: %v", err)
+	}
+	body, _ := io.ReadAll(data.Body)
+	data.Body.Close()
+	if string(body) != content[70000:70012] {
+		t.Errorf("GetObjectRange = %q, want %q", body, content[70000:70012])
+	}
+
+	// Copies get a data key of their own
+	if _, err := fs.CopyObject(ctx, "bucket", "kms", "bucket", "copy", nil); err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if enc := encryption("copy"); enc != nil {
+		t.Errorf("copy without encryption is encrypted: %+v", enc)
+	}
+	if got := get("copy"); got != content {
+		t.Errorf("copy has %d bytes, want %d", len(got), len(content))
+	}
+
+	// Bucket default encryption applies to writes without encryption headers,
+	// together with compression
+	fs.SetCompressionPolicy(CompressionPolicy{Algorithm: CompressionGzip, ContentTypes: []string{"text/*"}})
+	if err := fs.PutBucketEncryption(ctx, "bucket", &ServerSideEncryptionConfiguration{Rules: []ServerSideEncryptionRule{{
+		ApplyServerSideEncryptionByDefault: &ServerSideEncryptionByDefault{SSEAlgorithm: SSEAlgorithmAES256},
+	}}}); err != nil {
+		t.Fatalf("PutBucketEncryption: %v", err)
+	}
+	put(ctx, "default")
+	if enc := encryption("default"); enc == nil || enc.Algorithm != SSEAlgorithmAES256 || enc.KMSKeyID != "key" {
+		t.Fatalf("default encryption = %+v", enc)
+	}
+	if got := get("default"); got != content {
+		t.Errorf("GetObject(default) returned %d bytes, want %d", len(got), len(content))
+	}
+
+	// Rewrapping changes the master key, not the data
+	count, err := fs.RewrapObjectKeys(ctx, RewrapFilter{KMSKeyID: "key"}, "other", nil)
+	if err != nil || count != 1 {
+		t.Fatalf("RewrapObjectKeys = %d, %v, want 1", count, err)
+	}
+	if enc := encryption("default"); enc.KMSKeyID != "other" {
+		t.Errorf("rewrapped key ID = %q, want other", enc.KMSKeyID)
+	}
+	if got := get("default"); got != content {
+		t.Errorf("GetObject after rewrap returned %d bytes, want %d", len(got), len(content))
+	}
+
+	// Overwrites and deletes drop the encryption of the old object
+	if err := fs.DeleteBucketEncryption(ctx, "bucket"); err != nil {
+		t.Fatalf("DeleteBucketEncryption: %v", err)
+	}
+	put(ctx, "kms")
+	if enc := encryption("kms"); enc != nil {
+		t.Errorf("overwritten object keeps encryption: %+v", enc)
+	}
+	if err := fs.DeleteObject(ctx, "bucket", "default"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if enc := encryption("default"); enc != nil {
+		t.Errorf("deleted object keeps encryption: %+v", enc)
+	}
+}
+
+func TestFileSystemEncryptionMetadataFailure(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	fs.SetKMS(newTestKMS(t), "key")
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	kmsCtx := WithServerSideEncryption(ctx, &ServerSideEncryption{Algorithm: SSEAlgorithmKMS})
+	if _, err := fs.PutObject(kmsCtx, "bucket", "obj", strings.NewReader("first"), 5, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	// An object is never recorded without the data key of its file
+	if _, err := fs.metadata.db.Exec(`
+		CREATE TRIGGER fail_encryption BEFORE INSERT ON object_encryption
+		BEGIN SELECT RAISE(ABORT, 'injected'); END
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.PutObject(kmsCtx, "bucket", "obj", strings.NewReader("second"), 6, "", nil); err == nil {
+		t.Fatal("PutObject succeeded without recording the encryption")
+	}
+	if obj, err := fs.metadata.GetObject(ctx, "bucket", "obj"); err != nil || obj != nil {
+		t.Errorf("object after a failed encryption record = %+v, %v, want none", obj, err)
+	}
+	if _, err := fs.metadata.db.Exec(`DROP TRIGGER fail_encryption`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records of the overwritten object that cannot be removed fail the write
+	if _, err := fs.PutObject(ctx, "bucket", "plain", strings.NewReader("first"), 5, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, err := fs.metadata.db.Exec(`
+		CREATE TRIGGER fail_parts BEFORE DELETE ON object_parts
+		BEGIN SELECT RAISE(ABORT, 'injected'); END
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.metadata.PutObjectParts(ctx, "bucket", "plain", []ObjectPart{{PartNumber: 1, Size: 5}}); err != nil {
+		t.Fatalf("PutObjectParts: %v", err)
+	}
+	if _, err := fs.PutObject(ctx, "bucket", "plain", strings.NewReader("second"), 6, "", nil); err == nil {
+		t.Error("PutObject succeeded without removing the part layout of the old object")
+	}
+	if err := fs.metadata.DeleteObject(ctx, "bucket", "plain"); err == nil {
+		t.Error("DeleteObject succeeded without removing the part layout")
+	}
+	if obj, err := fs.metadata.GetObject(ctx, "bucket", "plain"); err != nil || obj == nil || obj.Size != 5 {
+		t.Errorf("object after failed deletes = %+v, %v, want the first one", obj, err)
+	}
+}
+
+func TestFileSystemEncryptionWithoutKMS(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	// SSE-S3 requests are accepted and stored in plaintext
+	s3Ctx := WithServerSideEncryption(ctx, &ServerSideEncryption{Algorithm: SSEAlgorithmAES256})
+	if _, err := fs.PutObject(s3Ctx, "bucket", "s3", strings.NewReader("data"), 4, "", nil); err != nil {
+		t.Fatalf("PutObject with SSE-S3: %v", err)
+	}
+	kmsCtx := WithServerSideEncryption(ctx, &ServerSideEncryption{Algorithm: SSEAlgorithmKMS})
+	if _, err := fs.PutObject(kmsCtx, "bucket", "kms", strings.NewReader("data"), 4, "", nil); !errors.Is(err, ErrKMSNotConfigured) {
+		t.Errorf("PutObject with SSE-KMS: err = %v, want ErrKMSNotConfigured", err)
+	}
+}
+
+func TestFileSystemEncryptionMultipart(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	fs.SetKMS(newTestKMS(t), "key")
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	kmsCtx := WithServerSideEncryption(ctx, &ServerSideEncryption{Algorithm: SSEAlgorithmKMS})
+	upload, err := fs.CreateMultipartUpload(kmsCtx, "bucket", "object", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	var parts []Part
+	var content string
+	for i, data := range []string{strings.Repeat("a", 5<<20), "tail"} {
+		part, err := fs.UploadPart(ctx, "bucket", "object", upload.UploadID, int32(i+1), strings.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+		parts = append(parts, Part{PartNumber: int32(i + 1), ETag: part.ETag})
+		content += data
+	}
+	if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "object", upload.UploadID, parts); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+
+	enc, err := fs.GetObjectEncryption(ctx, "bucket", "object", "")
+	if err != nil || enc == nil || enc.Algorithm != SSEAlgorithmKMS || enc.KMSKeyID != "key" {
+		t.Fatalf("GetObjectEncryption = %+v, %v", enc, err)
+	}
+	data, err := fs.GetObject(ctx, "bucket", "object")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	defer data.Body.Close()
+	body, _ := io.ReadAll(data.Body)
+	if string(body) != content {
+		t.Errorf("GetObject returned %d bytes, want %d", len(body), len(content))
+	}
+}
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SyncOptions holds options for syncing between a local directory and a bucket.
+type SyncOptions struct {
+	// Parallel is the number of files transferred concurrently.
+	Parallel int
+	// Include limits the sync to paths matching any of these patterns.
+	Include []string
+	// Exclude skips paths matching any of these patterns.
+	Exclude []string
+	// DryRun reports what would be transferred without transferring anything.
+	DryRun bool
+}
+
+// SyncResult holds the result of a sync.
+type SyncResult struct {
+	Copied  int64
+	Skipped int64
+	Bytes   int64
+}
+
+// syncItem is a file to transfer. Path is relative and slash-separated.
+type syncItem struct {
+	Path string
+	Size int64
+	ETag string
+}
+
+// SyncToBucket copies the files below localDir to bucket under prefix.
+// Objects whose size and MD5 ETag already match the local file are skipped.
+func SyncToBucket(ctx context.Context, s Storage, localDir, bucket, prefix string, opts SyncOptions) (*SyncResult, error) {
+	if _, err := s.HeadBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+	prefix = normalizeSyncPrefix(prefix)
+
+	var items []syncItem
+	err := filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !opts.matches(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		items = append(items, syncItem{Path: rel, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return runSync(ctx, items, opts, func(ctx context.Context, item syncItem) (bool, error) {
+		localPath := filepath.Join(localDir, filepath.FromSlash(item.Path))
+		key := prefix + item.Path
+
+		obj, err := s.HeadObject(ctx, bucket, key)
+		if err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return false, err
+		}
+		if obj != nil && obj.Size == item.Size {
+			sum, err := fileMD5(localPath)
+			if err != nil {
+				return false, err
+			}
+			if sum == obj.ETag {
+				return false, nil
+			}
+		}
+		if opts.DryRun {
+			return true, nil
+		}
+
+		f, err := os.Open(localPath)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+
+		contentType := mime.TypeByExtension(path.Ext(item.Path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		if _, err := s.PutObject(ctx, bucket, key, f, item.Size, contentType, nil); err != nil {
+			return false, fmt.Errorf("failed to upload %s: %w", item.Path, err)
+		}
+		return true, nil
+	})
+}
+
+// SyncFromBucket copies the objects in bucket under prefix to localDir.
+// Local files whose size and MD5 already match the object ETag are skipped.
+func SyncFromBucket(ctx context.Context, s Storage, bucket, prefix, localDir string, opts SyncOptions) (*SyncResult, error) {
+	prefix = normalizeSyncPrefix(prefix)
+
+	var items []syncItem
+	input := &ListObjectsInput{Bucket: bucket, Prefix: prefix, MaxKeys: 1000}
+	for {
+		output, err := s.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range output.Objects {
+			rel := strings.TrimPrefix(obj.Key, prefix)
+			// Directory markers have no local counterpart
+			if rel == "" || strings.HasSuffix(rel, "/") {
+				continue
+			}
+			if !filepath.IsLocal(filepath.FromSlash(rel)) {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidKey, obj.Key)
+			}
+			if opts.matches(rel) {
+				items = append(items, syncItem{Path: rel, Size: obj.Size, ETag: obj.ETag})
+			}
+		}
+		if !output.IsTruncated {
+			break
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+
+	return runSync(ctx, items, opts, func(ctx context.Context, item syncItem) (bool, error) {
+		localPath := filepath.Join(localDir, filepath.FromSlash(item.Path))
+
+		if info, err := os.Stat(localPath); err == nil && info.Size() == item.Size {
+			sum, err := fileMD5(localPath)
+			if err != nil {
+				return false, err
+			}
+			if sum == item.ETag {
+				return false, nil
+			}
+		}
+		if opts.DryRun {
+			return true, nil
+		}
+
+		data, err := s.GetObject(ctx, bucket, prefix+item.Path)
+		if err != nil {
+			return false, fmt.Errorf("failed to download %s: %w", item.Path, err)
+		}
+		defer data.Body.Close()
+
+		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return false, err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(localPath), ".tmp-*")
+		if err != nil {
+			return false, err
+		}
+		defer os.Remove(tmp.Name())
+
+		if _, err := io.Copy(tmp, data.Body); err != nil {
+			tmp.Close()
+			return false, fmt.Errorf("failed to download %s: %w", item.Path, err)
+		}
+		if err := tmp.Close(); err != nil {
+			return false, err
+		}
+		return true, os.Rename(tmp.Name(), localPath)
+	})
+}
+
+// runSync transfers items with opts.Parallel workers. transfer reports whether
+// the item was copied or skipped. The first error stops the sync.
+func runSync(ctx context.Context, items []syncItem, opts SyncOptions, transfer func(context.Context, syncItem) (bool, error)) (*SyncResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+
+	var (
+		result   SyncResult
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	work := make(chan syncItem)
+
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range work {
+				copied, err := transfer(ctx, item)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				if copied {
+					atomic.AddInt64(&result.Copied, 1)
+					atomic.AddInt64(&result.Bytes, item.Size)
+				} else {
+					atomic.AddInt64(&result.Skipped, 1)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, item := range items {
+		select {
+		case work <- item:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if firstErr != nil {
+		return &result, firstErr
+	}
+	return &result, ctx.Err()
+}
+
+// matches reports whether a relative path passes the include and exclude filters.
+// Patterns use path.Match syntax and are matched against the whole path and the file name.
+func (o SyncOptions) matches(rel string) bool {
+	if len(o.Include) > 0 && !matchAny(o.Include, rel) {
+		return false
+	}
+	return !matchAny(o.Exclude, rel)
+}
+
+// matchAny reports whether rel or its base name matches any of the patterns.
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeSyncPrefix makes a non-empty prefix end with a slash.
+func normalizeSyncPrefix(prefix string) string {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// fileMD5 returns the hex MD5 of a file, as used for single-part ETags.
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
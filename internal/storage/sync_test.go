@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncToAndFromBucket(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	local := t.TempDir()
+	files := map[string]string{
+		"a.txt":          "alpha",
+		"nested/b.txt":   "bravo",
+		"nested/c.tmp":   "scratch",
+		"nested/d/e.txt": "echo",
+	}
+	for name, content := range files {
+		p := filepath.Join(local, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := SyncOptions{Parallel: 2, Exclude: []string{"*.tmp"}}
+	result, err := SyncToBucket(ctx, fs, local, "bucket", "seed", opts)
+	if err != nil {
+		t.Fatalf("SyncToBucket: %v", err)
+	}
+	if result.Copied != 3 || result.Skipped != 0 {
+		t.Errorf("first sync: copied %d, skipped %d; want 3, 0", result.Copied, result.Skipped)
+	}
+	if got := readObject(t, fs, "bucket", "seed/nested/d/e.txt"); got != "echo" {
+		t.Errorf("seed/nested/d/e.txt = %q, want %q", got, "echo")
+	}
+	if _, err := fs.HeadObject(ctx, "bucket", "seed/nested/c.tmp"); err == nil {
+		t.Error("excluded file was uploaded")
+	}
+
+	// Unchanged files are skipped
+	if err := os.WriteFile(filepath.Join(local, "a.txt"), []byte("ALPHA"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = SyncToBucket(ctx, fs, local, "bucket", "seed/", opts)
+	if err != nil {
+		t.Fatalf("SyncToBucket: %v", err)
+	}
+	if result.Copied != 1 || result.Skipped != 2 {
+		t.Errorf("second sync: copied %d, skipped %d; want 1, 2", result.Copied, result.Skipped)
+	}
+
+	restored := t.TempDir()
+	result, err = SyncFromBucket(ctx, fs, "bucket", "seed", restored, SyncOptions{Include: []string{"*.txt"}})
+	if err != nil {
+		t.Fatalf("SyncFromBucket: %v", err)
+	}
+	if result.Copied != 3 {
+		t.Errorf("download: copied %d, want 3", result.Copied)
+	}
+	data, err := os.ReadFile(filepath.Join(restored, "a.txt"))
+	if err != nil || string(data) != "ALPHA" {
+		t.Errorf("a.txt = %q, %v; want %q", data, err, "ALPHA")
+	}
+
+	result, err = SyncFromBucket(ctx, fs, "bucket", "seed", restored, SyncOptions{})
+	if err != nil {
+		t.Fatalf("SyncFromBucket: %v", err)
+	}
+	if result.Copied != 0 || result.Skipped != 3 {
+		t.Errorf("repeated download: copied %d, skipped %d; want 0, 3", result.Copied, result.Skipped)
+	}
+}
+
+func TestSyncOptions_Matches(t *testing.T) {
+	opts := SyncOptions{Include: []string{"logs/*", "*.txt"}, Exclude: []string{"secret.txt"}}
+	tests := map[string]bool{
+		"logs/app.log":    true,
+		"notes.txt":       true,
+		"deep/notes.txt":  true,
+		"deep/secret.txt": false,
+		"image.png":       false,
+		"logs/deep/x.log": false,
+	}
+	for rel, want := range tests {
+		if got := opts.matches(rel); got != want {
+			t.Errorf("matches(%q) = %v, want %v", rel, got, want)
+		}
+	}
+}
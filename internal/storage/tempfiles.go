@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TempFilesResult summarizes a RemoveStaleTempFiles run.
+type TempFilesResult struct {
+	// Removed is the number of removed temporary files.
+	Removed int
+	// ReclaimedBytes is the total size of the removed files.
+	ReclaimedBytes int64
+}
+
+// RemoveStaleTempFiles removes the temporary files below the data directory,
+// in object directories as well as in the directories of multipart uploads,
+// that were last written before now minus olderThan. These are left behind by
+// writes interrupted by a crash; a write in progress keeps its file fresh.
+// At most maxFiles files are removed if maxFiles is positive, so a scan of a
+// large data directory stays bounded and the next scan continues.
+func (fs *FileSystem) RemoveStaleTempFiles(ctx context.Context, olderThan time.Duration, maxFiles int) (TempFilesResult, error) {
+	var result TempFilesResult
+	cutoff := time.Now().Add(-olderThan)
+	err := filepath.WalkDir(fs.dataDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Directories removed concurrently are skipped
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		result.Removed++
+		result.ReclaimedBytes += info.Size()
+		if maxFiles > 0 && result.Removed >= maxFiles {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return result, err
+}
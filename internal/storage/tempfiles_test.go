@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoveStaleTempFiles(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	stale := []string{
+		filepath.Join(fs.dataDir, "bucket", "dir", ".tmp-crashed"),
+		filepath.Join(fs.dataDir, ".uploads", "upload-id", ".tmp-crashed"),
+	}
+	fresh := filepath.Join(fs.dataDir, "bucket", ".tmp-writing")
+	past := time.Now().Add(-2 * time.Hour)
+	for _, path := range append(stale, fresh) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("partial"), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if path != fresh {
+			if err := os.Chtimes(path, past, past); err != nil {
+				t.Fatalf("Chtimes: %v", err)
+			}
+		}
+	}
+
+	// A scan removes at most the given number of files
+	result, err := fs.RemoveStaleTempFiles(ctx, time.Hour, 1)
+	if err != nil {
+		t.Fatalf("RemoveStaleTempFiles: %v", err)
+	}
+	if result.Removed != 1 || result.ReclaimedBytes != 7 {
+		t.Errorf("RemoveStaleTempFiles = %+v, want 1 file of 7 bytes", result)
+	}
+	result, err = fs.RemoveStaleTempFiles(ctx, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("RemoveStaleTempFiles: %v", err)
+	}
+	if result.Removed != 1 || result.ReclaimedBytes != 7 {
+		t.Errorf("RemoveStaleTempFiles = %+v, want 1 file of 7 bytes", result)
+	}
+
+	for _, path := range stale {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s after scan: got %v, want it removed", path, err)
+		}
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("temporary file of a write in progress after scan: %v", err)
+	}
+}
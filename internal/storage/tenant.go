@@ -0,0 +1,535 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Tenants routes each call to the storage of the tenant in the request context.
+// Every tenant has its own metadata database and data directory, so bucket
+// names of different tenants cannot collide and are invisible to each other.
+// Requests without a tenant use the default namespace.
+type Tenants struct {
+	defaultStore Storage
+	tenants      map[string]Storage
+}
+
+// NewTenants creates a tenant router over the default namespace and the given
+// per-tenant storages.
+func NewTenants(defaultStore Storage, tenants map[string]Storage) *Tenants {
+	return &Tenants{
+		defaultStore: defaultStore,
+		tenants:      tenants,
+	}
+}
+
+// Stores returns the storages of the default namespace and all tenants.
+func (t *Tenants) Stores() []Storage {
+	stores := []Storage{t.defaultStore}
+	for _, store := range t.tenants {
+		stores = append(stores, store)
+	}
+	return stores
+}
+
+// Store returns the storage of a tenant, "" being the default namespace, or
+// nil for unknown tenants.
+func (t *Tenants) Store(tenant string) Storage {
+	if tenant == "" {
+		return t.defaultStore
+	}
+	return t.tenants[tenant]
+}
+
+// store returns the storage of the tenant in ctx. Tenants are set by the
+// authentication layer from the same configuration as the storages, so an
+// unknown tenant is a programming error.
+func (t *Tenants) store(ctx context.Context) Storage {
+	tenant := TenantFromContext(ctx)
+	if tenant == "" {
+		return t.defaultStore
+	}
+	store, ok := t.tenants[tenant]
+	if !ok {
+		panic(fmt.Sprintf("storage: unknown tenant %q", tenant))
+	}
+	return store
+}
+
+// Close closes the storages of all tenants.
+func (t *Tenants) Close() error {
+	var errs []error
+	for _, store := range t.Stores() {
+		if err := store.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Bucket operations
+
+func (t *Tenants) CreateBucket(ctx context.Context, name string) error {
+	return t.store(ctx).CreateBucket(ctx, name)
+}
+
+func (t *Tenants) DeleteBucket(ctx context.Context, name string) error {
+	return t.store(ctx).DeleteBucket(ctx, name)
+}
+
+func (t *Tenants) HeadBucket(ctx context.Context, name string) (*Bucket, error) {
+	return t.store(ctx).HeadBucket(ctx, name)
+}
+
+func (t *Tenants) ListBuckets(ctx context.Context) ([]Bucket, error) {
+	return t.store(ctx).ListBuckets(ctx)
+}
+
+// Object operations
+
+func (t *Tenants) PutObject(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, metadata map[string]string) (*Object, error) {
+	return t.store(ctx).PutObject(ctx, bucket, key, body, size, contentType, metadata)
+}
+
+func (t *Tenants) GetObject(ctx context.Context, bucket, key string) (*ObjectData, error) {
+	return t.store(ctx).GetObject(ctx, bucket, key)
+}
+
+func (t *Tenants) GetObjectRange(ctx context.Context, bucket, key string, start, end int64) (*ObjectData, error) {
+	return t.store(ctx).GetObjectRange(ctx, bucket, key, start, end)
+}
+
+func (t *Tenants) HeadObject(ctx context.Context, bucket, key string) (*Object, error) {
+	return t.store(ctx).HeadObject(ctx, bucket, key)
+}
+
+func (t *Tenants) GetObjectParts(ctx context.Context, bucket, key string) ([]ObjectPart, error) {
+	return t.store(ctx).GetObjectParts(ctx, bucket, key)
+}
+
+func (t *Tenants) DeleteObject(ctx context.Context, bucket, key string) error {
+	return t.store(ctx).DeleteObject(ctx, bucket, key)
+}
+
+func (t *Tenants) DeleteObjects(ctx context.Context, bucket string, keys []string) ([]DeletedObject, []DeleteError, error) {
+	return t.store(ctx).DeleteObjects(ctx, bucket, keys)
+}
+
+func (t *Tenants) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, metadata map[string]string) (*Object, error) {
+	return t.store(ctx).CopyObject(ctx, srcBucket, srcKey, dstBucket, dstKey, metadata)
+}
+
+func (t *Tenants) AppendObject(ctx context.Context, bucket, key string, position int64, body io.Reader, size int64, contentType string, metadata map[string]string) (*Object, error) {
+	return t.store(ctx).AppendObject(ctx, bucket, key, position, body, size, contentType, metadata)
+}
+
+func (t *Tenants) ListObjectsV2(ctx context.Context, input *ListObjectsInput) (*ListObjectsOutput, error) {
+	return t.store(ctx).ListObjectsV2(ctx, input)
+}
+
+func (t *Tenants) SearchObjects(ctx context.Context, input *SearchObjectsInput) (*SearchObjectsOutput, error) {
+	return t.store(ctx).SearchObjects(ctx, input)
+}
+
+// Multipart upload operations
+
+func (t *Tenants) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string, metadata map[string]string) (*MultipartUpload, error) {
+	return t.store(ctx).CreateMultipartUpload(ctx, bucket, key, contentType, metadata)
+}
+
+func (t *Tenants) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int32, body io.Reader, size int64) (*Part, error) {
+	return t.store(ctx).UploadPart(ctx, bucket, key, uploadID, partNumber, body, size)
+}
+
+func (t *Tenants) UploadPartCopy(ctx context.Context, bucket, key, uploadID string, partNumber int32, srcBucket, srcKey string, startByte, endByte *int64) (*Part, error) {
+	return t.store(ctx).UploadPartCopy(ctx, bucket, key, uploadID, partNumber, srcBucket, srcKey, startByte, endByte)
+}
+
+func (t *Tenants) UploadPartRange(ctx context.Context, bucket, key, uploadID string, partNumber int32, offset int64, body io.Reader, size, totalSize int64) (*PartUploadProgress, error) {
+	return t.store(ctx).UploadPartRange(ctx, bucket, key, uploadID, partNumber, offset, body, size, totalSize)
+}
+
+func (t *Tenants) GetPartUploadOffset(ctx context.Context, bucket, key, uploadID string, partNumber int32) (int64, error) {
+	return t.store(ctx).GetPartUploadOffset(ctx, bucket, key, uploadID, partNumber)
+}
+
+func (t *Tenants) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) (*Object, error) {
+	return t.store(ctx).CompleteMultipartUpload(ctx, bucket, key, uploadID, parts)
+}
+
+func (t *Tenants) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	return t.store(ctx).AbortMultipartUpload(ctx, bucket, key, uploadID)
+}
+
+func (t *Tenants) ListParts(ctx context.Context, input *ListPartsInput) (*ListPartsOutput, error) {
+	return t.store(ctx).ListParts(ctx, input)
+}
+
+func (t *Tenants) ListMultipartUploads(ctx context.Context, input *ListMultipartUploadsInput) (*ListMultipartUploadsOutput, error) {
+	return t.store(ctx).ListMultipartUploads(ctx, input)
+}
+
+// Tagging operations
+
+func (t *Tenants) PutObjectTagging(ctx context.Context, bucket, key string, tags []Tag) error {
+	return t.store(ctx).PutObjectTagging(ctx, bucket, key, tags)
+}
+
+func (t *Tenants) GetObjectTagging(ctx context.Context, bucket, key string) ([]Tag, error) {
+	return t.store(ctx).GetObjectTagging(ctx, bucket, key)
+}
+
+func (t *Tenants) DeleteObjectTagging(ctx context.Context, bucket, key string) error {
+	return t.store(ctx).DeleteObjectTagging(ctx, bucket, key)
+}
+
+func (t *Tenants) PutBucketTagging(ctx context.Context, bucket string, tags []Tag) error {
+	return t.store(ctx).PutBucketTagging(ctx, bucket, tags)
+}
+
+func (t *Tenants) GetBucketTagging(ctx context.Context, bucket string) ([]Tag, error) {
+	return t.store(ctx).GetBucketTagging(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketTagging(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketTagging(ctx, bucket)
+}
+
+// CORS operations
+
+func (t *Tenants) PutBucketCors(ctx context.Context, bucket string, cors *CORSConfiguration) error {
+	return t.store(ctx).PutBucketCors(ctx, bucket, cors)
+}
+
+func (t *Tenants) GetBucketCors(ctx context.Context, bucket string) (*CORSConfiguration, error) {
+	return t.store(ctx).GetBucketCors(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketCors(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketCors(ctx, bucket)
+}
+
+// Versioning operations
+
+func (t *Tenants) PutBucketVersioning(ctx context.Context, bucket string, status VersioningStatus) error {
+	return t.store(ctx).PutBucketVersioning(ctx, bucket, status)
+}
+
+func (t *Tenants) GetBucketVersioning(ctx context.Context, bucket string) (VersioningStatus, error) {
+	return t.store(ctx).GetBucketVersioning(ctx, bucket)
+}
+
+func (t *Tenants) PutBucketMfaDelete(ctx context.Context, bucket string, status MfaDeleteStatus) error {
+	return t.store(ctx).PutBucketMfaDelete(ctx, bucket, status)
+}
+
+func (t *Tenants) GetBucketMfaDelete(ctx context.Context, bucket string) (MfaDeleteStatus, error) {
+	return t.store(ctx).GetBucketMfaDelete(ctx, bucket)
+}
+
+func (t *Tenants) GetBucketVersioningConfiguration(ctx context.Context, bucket string) (*VersioningConfiguration, error) {
+	return t.store(ctx).GetBucketVersioningConfiguration(ctx, bucket)
+}
+
+func (t *Tenants) PutObjectVersioned(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, metadata map[string]string) (*Object, string, error) {
+	return t.store(ctx).PutObjectVersioned(ctx, bucket, key, body, size, contentType, metadata)
+}
+
+func (t *Tenants) GetObjectVersioned(ctx context.Context, bucket, key, versionID string) (*ObjectData, error) {
+	return t.store(ctx).GetObjectVersioned(ctx, bucket, key, versionID)
+}
+
+func (t *Tenants) HeadObjectVersioned(ctx context.Context, bucket, key, versionID string) (*Object, error) {
+	return t.store(ctx).HeadObjectVersioned(ctx, bucket, key, versionID)
+}
+
+func (t *Tenants) DeleteObjectVersioned(ctx context.Context, bucket, key, versionID string) (string, bool, error) {
+	return t.store(ctx).DeleteObjectVersioned(ctx, bucket, key, versionID)
+}
+
+func (t *Tenants) ListObjectVersions(ctx context.Context, input *ListObjectVersionsInput) (*ListObjectVersionsOutput, error) {
+	return t.store(ctx).ListObjectVersions(ctx, input)
+}
+
+// ACL operations
+
+func (t *Tenants) PutBucketACL(ctx context.Context, bucket string, acl *ACL) error {
+	return t.store(ctx).PutBucketACL(ctx, bucket, acl)
+}
+
+func (t *Tenants) GetBucketACL(ctx context.Context, bucket string) (*ACL, error) {
+	return t.store(ctx).GetBucketACL(ctx, bucket)
+}
+
+func (t *Tenants) PutObjectACL(ctx context.Context, bucket, key string, acl *ACL) error {
+	return t.store(ctx).PutObjectACL(ctx, bucket, key, acl)
+}
+
+func (t *Tenants) GetObjectACL(ctx context.Context, bucket, key string) (*ACL, error) {
+	return t.store(ctx).GetObjectACL(ctx, bucket, key)
+}
+
+// Encryption operations
+
+func (t *Tenants) PutBucketEncryption(ctx context.Context, bucket string, config *ServerSideEncryptionConfiguration) error {
+	return t.store(ctx).PutBucketEncryption(ctx, bucket, config)
+}
+
+func (t *Tenants) GetBucketEncryption(ctx context.Context, bucket string) (*ServerSideEncryptionConfiguration, error) {
+	return t.store(ctx).GetBucketEncryption(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketEncryption(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketEncryption(ctx, bucket)
+}
+
+func (t *Tenants) GetObjectEncryption(ctx context.Context, bucket, key, versionID string) (*ObjectEncryption, error) {
+	return t.store(ctx).GetObjectEncryption(ctx, bucket, key, versionID)
+}
+
+// Lifecycle operations
+
+func (t *Tenants) PutBucketLifecycleConfiguration(ctx context.Context, bucket string, config *LifecycleConfiguration) error {
+	return t.store(ctx).PutBucketLifecycleConfiguration(ctx, bucket, config)
+}
+
+func (t *Tenants) GetBucketLifecycleConfiguration(ctx context.Context, bucket string) (*LifecycleConfiguration, error) {
+	return t.store(ctx).GetBucketLifecycleConfiguration(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketLifecycle(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketLifecycle(ctx, bucket)
+}
+
+// Object Lock operations
+
+func (t *Tenants) SetBucketObjectLockEnabled(ctx context.Context, bucket string, enabled bool) error {
+	return t.store(ctx).SetBucketObjectLockEnabled(ctx, bucket, enabled)
+}
+
+func (t *Tenants) GetBucketObjectLockEnabled(ctx context.Context, bucket string) (bool, error) {
+	return t.store(ctx).GetBucketObjectLockEnabled(ctx, bucket)
+}
+
+func (t *Tenants) PutObjectLockConfiguration(ctx context.Context, bucket string, config *ObjectLockConfiguration) error {
+	return t.store(ctx).PutObjectLockConfiguration(ctx, bucket, config)
+}
+
+func (t *Tenants) GetObjectLockConfiguration(ctx context.Context, bucket string) (*ObjectLockConfiguration, error) {
+	return t.store(ctx).GetObjectLockConfiguration(ctx, bucket)
+}
+
+func (t *Tenants) PutObjectRetention(ctx context.Context, bucket, key string, retention *ObjectRetention) error {
+	return t.store(ctx).PutObjectRetention(ctx, bucket, key, retention)
+}
+
+func (t *Tenants) GetObjectRetention(ctx context.Context, bucket, key string) (*ObjectRetention, error) {
+	return t.store(ctx).GetObjectRetention(ctx, bucket, key)
+}
+
+func (t *Tenants) PutObjectLegalHold(ctx context.Context, bucket, key string, legalHold *ObjectLegalHold) error {
+	return t.store(ctx).PutObjectLegalHold(ctx, bucket, key, legalHold)
+}
+
+func (t *Tenants) GetObjectLegalHold(ctx context.Context, bucket, key string) (*ObjectLegalHold, error) {
+	return t.store(ctx).GetObjectLegalHold(ctx, bucket, key)
+}
+
+// Bucket Policy operations
+
+func (t *Tenants) PutBucketPolicy(ctx context.Context, bucket string, policy string) error {
+	return t.store(ctx).PutBucketPolicy(ctx, bucket, policy)
+}
+
+func (t *Tenants) GetBucketPolicy(ctx context.Context, bucket string) (string, error) {
+	return t.store(ctx).GetBucketPolicy(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketPolicy(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketPolicy(ctx, bucket)
+}
+
+// Website Hosting operations
+
+func (t *Tenants) PutBucketWebsite(ctx context.Context, bucket string, config *WebsiteConfiguration) error {
+	return t.store(ctx).PutBucketWebsite(ctx, bucket, config)
+}
+
+func (t *Tenants) GetBucketWebsite(ctx context.Context, bucket string) (*WebsiteConfiguration, error) {
+	return t.store(ctx).GetBucketWebsite(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketWebsite(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketWebsite(ctx, bucket)
+}
+
+// Tiering operations (JOG extension)
+
+func (t *Tenants) PutBucketTiering(ctx context.Context, bucket string, config *TieringConfiguration) error {
+	return t.store(ctx).PutBucketTiering(ctx, bucket, config)
+}
+
+func (t *Tenants) GetBucketTiering(ctx context.Context, bucket string) (*TieringConfiguration, error) {
+	return t.store(ctx).GetBucketTiering(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketTiering(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketTiering(ctx, bucket)
+}
+
+// Compression operations (JOG extension)
+
+func (t *Tenants) PutBucketCompression(ctx context.Context, bucket string, config *CompressionConfiguration) error {
+	return t.store(ctx).PutBucketCompression(ctx, bucket, config)
+}
+
+func (t *Tenants) GetBucketCompression(ctx context.Context, bucket string) (*CompressionConfiguration, error) {
+	return t.store(ctx).GetBucketCompression(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketCompression(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketCompression(ctx, bucket)
+}
+
+// Unchanged overwrite skipping operations (JOG extension)
+
+func (t *Tenants) PutBucketSkipUnchanged(ctx context.Context, bucket string, enabled bool) error {
+	return t.store(ctx).PutBucketSkipUnchanged(ctx, bucket, enabled)
+}
+
+func (t *Tenants) GetBucketSkipUnchanged(ctx context.Context, bucket string) (bool, error) {
+	return t.store(ctx).GetBucketSkipUnchanged(ctx, bucket)
+}
+
+// Write-once operations (JOG extension)
+
+func (t *Tenants) PutBucketWorm(ctx context.Context, bucket string, enabled bool) error {
+	return t.store(ctx).PutBucketWorm(ctx, bucket, enabled)
+}
+
+func (t *Tenants) GetBucketWorm(ctx context.Context, bucket string) (bool, error) {
+	return t.store(ctx).GetBucketWorm(ctx, bucket)
+}
+
+// Content type inference operations (JOG extension)
+
+func (t *Tenants) PutBucketContentTypes(ctx context.Context, bucket string, config *ContentTypeConfiguration) error {
+	return t.store(ctx).PutBucketContentTypes(ctx, bucket, config)
+}
+
+func (t *Tenants) GetBucketContentTypes(ctx context.Context, bucket string) (*ContentTypeConfiguration, error) {
+	return t.store(ctx).GetBucketContentTypes(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketContentTypes(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketContentTypes(ctx, bucket)
+}
+
+// Default object tag operations (JOG extension)
+
+func (t *Tenants) PutBucketDefaultTags(ctx context.Context, bucket string, tags []Tag) error {
+	return t.store(ctx).PutBucketDefaultTags(ctx, bucket, tags)
+}
+
+func (t *Tenants) GetBucketDefaultTags(ctx context.Context, bucket string) ([]Tag, error) {
+	return t.store(ctx).GetBucketDefaultTags(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketDefaultTags(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketDefaultTags(ctx, bucket)
+}
+
+// Response header policy operations (JOG extension)
+
+func (t *Tenants) PutBucketResponseHeaders(ctx context.Context, bucket string, config *ResponseHeaderConfiguration) error {
+	return t.store(ctx).PutBucketResponseHeaders(ctx, bucket, config)
+}
+
+func (t *Tenants) GetBucketResponseHeaders(ctx context.Context, bucket string) (*ResponseHeaderConfiguration, error) {
+	return t.store(ctx).GetBucketResponseHeaders(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketResponseHeaders(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketResponseHeaders(ctx, bucket)
+}
+
+// Usage operations (JOG extension)
+
+func (t *Tenants) GetBucketUsage(ctx context.Context, bucket string) (*BucketUsage, error) {
+	return t.store(ctx).GetBucketUsage(ctx, bucket)
+}
+
+func (t *Tenants) GetBucketUsageHistory(ctx context.Context, bucket string, days int) ([]UsageRecord, error) {
+	return t.store(ctx).GetBucketUsageHistory(ctx, bucket, days)
+}
+
+func (t *Tenants) RecordUsageHistory(ctx context.Context, now time.Time, keepDays int) ([]UsageRecord, error) {
+	return t.store(ctx).RecordUsageHistory(ctx, now, keepDays)
+}
+
+// Trash operations (JOG extension)
+
+func (t *Tenants) PutBucketTrash(ctx context.Context, bucket string, config *TrashConfiguration) error {
+	return t.store(ctx).PutBucketTrash(ctx, bucket, config)
+}
+
+func (t *Tenants) GetBucketTrash(ctx context.Context, bucket string) (*TrashConfiguration, error) {
+	return t.store(ctx).GetBucketTrash(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketTrash(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketTrash(ctx, bucket)
+}
+
+func (t *Tenants) ListTrash(ctx context.Context, bucket, prefix string) ([]TrashEntry, error) {
+	return t.store(ctx).ListTrash(ctx, bucket, prefix)
+}
+
+func (t *Tenants) RestoreTrash(ctx context.Context, bucket, id string) (*Object, error) {
+	return t.store(ctx).RestoreTrash(ctx, bucket, id)
+}
+
+func (t *Tenants) PurgeTrash(ctx context.Context, now time.Time) (int, error) {
+	return t.store(ctx).PurgeTrash(ctx, now)
+}
+
+// Version retention operations (JOG extension)
+
+func (t *Tenants) PutBucketVersionRetention(ctx context.Context, bucket string, config *VersionRetentionConfiguration) error {
+	return t.store(ctx).PutBucketVersionRetention(ctx, bucket, config)
+}
+
+func (t *Tenants) GetBucketVersionRetention(ctx context.Context, bucket string) (*VersionRetentionConfiguration, error) {
+	return t.store(ctx).GetBucketVersionRetention(ctx, bucket)
+}
+
+func (t *Tenants) DeleteBucketVersionRetention(ctx context.Context, bucket string) error {
+	return t.store(ctx).DeleteBucketVersionRetention(ctx, bucket)
+}
+
+func (t *Tenants) PruneNoncurrentVersions(ctx context.Context) (int, error) {
+	return t.store(ctx).PruneNoncurrentVersions(ctx)
+}
+
+// Integrity operations (JOG extension)
+
+func (t *Tenants) VerifyObject(ctx context.Context, bucket, key string) (*ObjectVerification, error) {
+	return t.store(ctx).VerifyObject(ctx, bucket, key)
+}
+
+func (t *Tenants) ListObjectsToVerify(ctx context.Context, verifiedBefore time.Time, limit int) ([]ObjectLocation, error) {
+	return t.store(ctx).ListObjectsToVerify(ctx, verifiedBefore, limit)
+}
+
+// Housekeeping operations (JOG extension)
+
+func (t *Tenants) PruneEmptyDirs(ctx context.Context) (int, error) {
+	return t.store(ctx).PruneEmptyDirs(ctx)
+}
+
+func (t *Tenants) RemoveStaleTempFiles(ctx context.Context, olderThan time.Duration, maxFiles int) (TempFilesResult, error) {
+	return t.store(ctx).RemoveStaleTempFiles(ctx, olderThan, maxFiles)
+}
@@ -0,0 +1,442 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Tiered implements Storage by keeping small and recently modified objects on
+// the local filesystem and spilling large or cold objects to a remote BlobStore.
+// Metadata always stays in the local SQLite database. An object is remote when
+// it has metadata but no local data file.
+type Tiered struct {
+	*Passthrough
+	defaultPolicy TieringConfiguration
+}
+
+// NewTiered creates a new tiered storage backend.
+// defaultPolicy applies to buckets without a tiering configuration.
+func NewTiered(dataDir string, metadataDB string, remote BlobStore, defaultPolicy TieringConfiguration) (*Tiered, error) {
+	p, err := NewPassthrough(dataDir, metadataDB, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tiered{
+		Passthrough:   p,
+		defaultPolicy: defaultPolicy,
+	}, nil
+}
+
+// policy returns the tiering policy for a bucket.
+func (t *Tiered) policy(ctx context.Context, bucket string) (TieringConfiguration, error) {
+	config, err := t.GetBucketTiering(ctx, bucket)
+	if err != nil {
+		if err == ErrNoSuchTieringConfiguration {
+			return t.defaultPolicy, nil
+		}
+		return TieringConfiguration{}, err
+	}
+	return *config, nil
+}
+
+// isLocal reports whether the object data is stored on the local filesystem.
+func (t *Tiered) isLocal(bucket, key string) bool {
+	objectPath, err := t.validateObjectKey(bucket, key)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(objectPath)
+	return err == nil && !info.IsDir()
+}
+
+// PutObject stores an object locally or remotely depending on the bucket policy.
+// The key stays locked until the copy in the other tier is removed.
+func (t *Tiered) PutObject(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string, metadata map[string]string) (*Object, error) {
+	ctx, unlock := t.holdKey(ctx, bucket, key)
+	defer unlock()
+
+	policy, err := t.policy(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	wasLocal := t.isLocal(bucket, key)
+
+	if policy.SizeThreshold > 0 && size >= policy.SizeThreshold {
+		obj, err := t.Passthrough.PutObject(ctx, bucket, key, body, size, contentType, metadata)
+		if err != nil {
+			return nil, err
+		}
+		if wasLocal {
+			t.removeLocal(bucket, key)
+		}
+		return obj, nil
+	}
+
+	existing, err := t.metadata.GetObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := t.FileSystem.PutObject(ctx, bucket, key, body, size, contentType, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the stale remote copy of an object that was previously spilled
+	if existing != nil && !wasLocal {
+		if err := t.blobs.DeleteBlob(ctx, blobName(bucket, key)); err != nil {
+			return nil, fmt.Errorf("failed to delete remote object: %w", err)
+		}
+	}
+
+	return obj, nil
+}
+
+// GetObject retrieves an object from the tier it is stored in.
+func (t *Tiered) GetObject(ctx context.Context, bucket, key string) (*ObjectData, error) {
+	if t.isLocal(bucket, key) {
+		return t.FileSystem.GetObject(ctx, bucket, key)
+	}
+	return t.Passthrough.GetObject(ctx, bucket, key)
+}
+
+// GetObjectRange retrieves a range of an object from the tier it is stored in.
+func (t *Tiered) GetObjectRange(ctx context.Context, bucket, key string, start, end int64) (*ObjectData, error) {
+	if t.isLocal(bucket, key) {
+		return t.FileSystem.GetObjectRange(ctx, bucket, key, start, end)
+	}
+	return t.Passthrough.GetObjectRange(ctx, bucket, key, start, end)
+}
+
+// DeleteObject deletes an object from the tier it is stored in.
+func (t *Tiered) DeleteObject(ctx context.Context, bucket, key string) error {
+	ctx, unlock := t.holdKey(ctx, bucket, key)
+	defer unlock()
+
+	if t.isLocal(bucket, key) {
+		return t.FileSystem.DeleteObject(ctx, bucket, key)
+	}
+	return t.Passthrough.DeleteObject(ctx, bucket, key)
+}
+
+// DeleteObjects deletes multiple objects from the tiers they are stored in.
+func (t *Tiered) DeleteObjects(ctx context.Context, bucket string, keys []string) ([]DeletedObject, []DeleteError, error) {
+	// Check if bucket exists
+	exists, err := t.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, ErrBucketNotFound
+	}
+
+	deleted := make([]DeletedObject, 0, len(keys))
+	errs := make([]DeleteError, 0)
+	for _, key := range keys {
+		keyDeleted, keyErrs, err := t.deleteObjectsKey(ctx, bucket, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		deleted = append(deleted, keyDeleted...)
+		errs = append(errs, keyErrs...)
+	}
+	return deleted, errs, nil
+}
+
+// deleteObjectsKey deletes a key of DeleteObjects from the tier it is stored
+// in, holding the key lock so that it cannot move to the other tier meanwhile.
+func (t *Tiered) deleteObjectsKey(ctx context.Context, bucket, key string) ([]DeletedObject, []DeleteError, error) {
+	ctx, unlock := t.holdKey(ctx, bucket, key)
+	defer unlock()
+
+	if t.isLocal(bucket, key) {
+		return t.FileSystem.DeleteObjects(ctx, bucket, []string{key})
+	}
+	return t.Passthrough.DeleteObjects(ctx, bucket, []string{key})
+}
+
+// CopyObject copies an object, placing the copy according to the destination bucket policy.
+func (t *Tiered) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string, metadata map[string]string) (*Object, error) {
+	// Copying an object onto itself with new metadata keeps its data in place
+	if srcBucket == dstBucket && srcKey == dstKey && metadata != nil {
+		if _, err := t.validateObjectKey(dstBucket, dstKey); err != nil {
+			return nil, err
+		}
+		return t.replaceObjectMetadata(ctx, dstBucket, dstKey, metadata)
+	}
+
+	ctx, unlock := t.holdKey(ctx, dstBucket, dstKey)
+	defer unlock()
+
+	if !t.isLocal(srcBucket, srcKey) {
+		// Stream remote sources through PutObject so the copy is placed by the destination policy
+		srcObj, err := t.Passthrough.GetObject(ctx, srcBucket, srcKey)
+		if err != nil {
+			return nil, err
+		}
+		defer srcObj.Body.Close()
+
+		// Use COPY directive semantics when no metadata is given
+		if metadata == nil {
+			metadata = srcObj.Metadata
+		}
+		return t.PutObject(ctx, dstBucket, dstKey, srcObj.Body, srcObj.Size, srcObj.ContentType, metadata)
+	}
+
+	wasLocal := t.isLocal(dstBucket, dstKey)
+	existing, err := t.metadata.GetObject(ctx, dstBucket, dstKey)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := t.FileSystem.CopyObject(ctx, srcBucket, srcKey, dstBucket, dstKey, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && !wasLocal {
+		if err := t.blobs.DeleteBlob(ctx, blobName(dstBucket, dstKey)); err != nil {
+			return nil, fmt.Errorf("failed to delete remote object: %w", err)
+		}
+	}
+
+	if err := t.applySizePolicy(ctx, dstBucket, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// AppendObject appends to a local object. Remote objects cannot be appended to.
+func (t *Tiered) AppendObject(ctx context.Context, bucket, key string, position int64, body io.Reader, size int64, contentType string, metadata map[string]string) (*Object, error) {
+	ctx, unlock := t.holdKey(ctx, bucket, key)
+	defer unlock()
+
+	existing, err := t.metadata.GetObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && !t.isLocal(bucket, key) {
+		return nil, ErrNotImplemented
+	}
+	return t.FileSystem.AppendObject(ctx, bucket, key, position, body, size, contentType, metadata)
+}
+
+// UploadPartCopy copies data from an object in either tier to a locally staged part.
+func (t *Tiered) UploadPartCopy(ctx context.Context, bucket, key, uploadID string, partNumber int32, srcBucket, srcKey string, startByte, endByte *int64) (*Part, error) {
+	if t.isLocal(srcBucket, srcKey) {
+		return t.FileSystem.UploadPartCopy(ctx, bucket, key, uploadID, partNumber, srcBucket, srcKey, startByte, endByte)
+	}
+	return t.Passthrough.UploadPartCopy(ctx, bucket, key, uploadID, partNumber, srcBucket, srcKey, startByte, endByte)
+}
+
+// CompleteMultipartUpload assembles the parts locally and spills the result if it is large enough.
+func (t *Tiered) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) (*Object, error) {
+	ctx, unlock := t.holdKey(ctx, bucket, key)
+	defer unlock()
+
+	wasLocal := t.isLocal(bucket, key)
+	existing, err := t.metadata.GetObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := t.FileSystem.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && !wasLocal {
+		if err := t.blobs.DeleteBlob(ctx, blobName(bucket, key)); err != nil {
+			return nil, fmt.Errorf("failed to delete remote object: %w", err)
+		}
+	}
+
+	if err := t.applySizePolicy(ctx, bucket, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// applySizePolicy moves a freshly written local object to remote storage if it
+// exceeds the bucket size threshold.
+func (t *Tiered) applySizePolicy(ctx context.Context, bucket string, obj *Object) error {
+	policy, err := t.policy(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if policy.SizeThreshold > 0 && obj.Size >= policy.SizeThreshold {
+		_, err := t.moveToRemote(ctx, bucket, obj)
+		return err
+	}
+	return nil
+}
+
+// moveToRemote uploads a local object to remote storage and removes the local
+// copy. It holds the key lock throughout, so that writes of the key cannot
+// replace the local copy while it is moved, and skips the object if it was
+// replaced since obj was read. It reports whether the object was moved.
+func (t *Tiered) moveToRemote(ctx context.Context, bucket string, obj *Object) (bool, error) {
+	key := obj.Key
+	objectPath, err := t.validateObjectKey(bucket, key)
+	if err != nil {
+		return false, err
+	}
+
+	unlock := t.lockKey(ctx, bucket, key)
+	defer unlock()
+
+	current, err := t.metadata.GetObject(ctx, bucket, key)
+	if err != nil {
+		return false, err
+	}
+	if current == nil || current.ETag != obj.ETag {
+		return false, nil
+	}
+
+	file, err := os.Open(objectPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open local object: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat local object: %w", err)
+	}
+
+	if err := t.blobs.PutBlob(ctx, blobName(bucket, key), file, info.Size()); err != nil {
+		return false, fmt.Errorf("failed to upload object: %w", err)
+	}
+
+	// Keep a local copy that was replaced during the upload, as the
+	// uploaded data is not the current object
+	if latest, err := os.Stat(objectPath); err != nil || !os.SameFile(info, latest) || !latest.ModTime().Equal(info.ModTime()) {
+		if err == nil {
+			_ = t.blobs.DeleteBlob(ctx, blobName(bucket, key))
+		}
+		return false, nil
+	}
+	t.removeLocal(bucket, key)
+	return true, nil
+}
+
+// removeLocal removes the local data file of an object.
+func (t *Tiered) removeLocal(bucket, key string) {
+	if objectPath, err := t.validateObjectKey(bucket, key); err == nil {
+		os.Remove(objectPath)
+	}
+}
+
+// MigrateColdObjects moves local objects that have not been modified for longer
+// than their bucket's ColdAfterDays to remote storage. It returns the number of
+// objects moved.
+func (t *Tiered) MigrateColdObjects(ctx context.Context) (int, error) {
+	buckets, err := t.metadata.ListBuckets(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, bucket := range buckets {
+		policy, err := t.policy(ctx, bucket.Name)
+		if err != nil {
+			return moved, err
+		}
+		if policy.ColdAfterDays <= 0 {
+			continue
+		}
+		cutoff := time.Now().AddDate(0, 0, -int(policy.ColdAfterDays))
+
+		startAfter := ""
+		for {
+			objects, err := t.metadata.ListObjects(ctx, bucket.Name, "", startAfter, 1000)
+			if err != nil {
+				return moved, err
+			}
+			for _, obj := range objects {
+				if obj.LastModified.Before(cutoff) && t.isLocal(bucket.Name, obj.Key) {
+					ok, err := t.moveToRemote(ctx, bucket.Name, &obj)
+					if err != nil {
+						return moved, err
+					}
+					if ok {
+						moved++
+					}
+				}
+			}
+			if len(objects) < 1000 {
+				break
+			}
+			startAfter = objects[len(objects)-1].Key
+		}
+	}
+
+	return moved, nil
+}
+
+// PutBucketTiering sets the tiering configuration for a bucket.
+func (t *Tiered) PutBucketTiering(ctx context.Context, bucket string, config *TieringConfiguration) error {
+	// Check if bucket exists
+	exists, err := t.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return t.metadata.PutBucketTiering(ctx, bucket, string(configJSON))
+}
+
+// GetBucketTiering returns the tiering configuration for a bucket.
+func (t *Tiered) GetBucketTiering(ctx context.Context, bucket string) (*TieringConfiguration, error) {
+	// Check if bucket exists
+	exists, err := t.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	configJSON, err := t.metadata.GetBucketTiering(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if configJSON == "" {
+		return nil, ErrNoSuchTieringConfiguration
+	}
+
+	var config TieringConfiguration
+	if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// DeleteBucketTiering deletes the tiering configuration for a bucket.
+func (t *Tiered) DeleteBucketTiering(ctx context.Context, bucket string) error {
+	// Check if bucket exists
+	exists, err := t.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return t.metadata.DeleteBucketTiering(ctx, bucket)
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestTiered(t *testing.T, remote BlobStore, policy TieringConfiguration) *Tiered {
+	t.Helper()
+	dir := t.TempDir()
+	tiered, err := NewTiered(dir, filepath.Join(dir, "metadata.db"), remote, policy)
+	if err != nil {
+		t.Fatalf("failed to create tiered storage: %v", err)
+	}
+	t.Cleanup(func() { tiered.Close() })
+	return tiered
+}
+
+func readObject(t *testing.T, s Storage, bucket, key string) string {
+	t.Helper()
+	data, err := s.GetObject(context.Background(), bucket, key)
+	if err != nil {
+		t.Fatalf("GetObject(%s): %v", key, err)
+	}
+	defer data.Body.Close()
+	body, _ := io.ReadAll(data.Body)
+	return string(body)
+}
+
+func TestTiered_SizePlacement(t *testing.T) {
+	ctx := context.Background()
+	remote := newMemoryBlobStore()
+	tiered := newTestTiered(t, remote, TieringConfiguration{SizeThreshold: 10})
+
+	if err := tiered.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	if _, err := tiered.PutObject(ctx, "bucket", "small", strings.NewReader("tiny"), 4, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, err := tiered.PutObject(ctx, "bucket", "large", strings.NewReader("large object"), 12, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	if !tiered.isLocal("bucket", "small") {
+		t.Errorf("small object should be stored locally")
+	}
+	if _, ok := remote.blobs["bucket/large"]; !ok || tiered.isLocal("bucket", "large") {
+		t.Errorf("large object should be stored remotely")
+	}
+	if got := readObject(t, tiered, "bucket", "large"); got != "large object" {
+		t.Errorf("unexpected remote object %q", got)
+	}
+
+	// Overwriting a remote object with a small one brings it back to local disk
+	if _, err := tiered.PutObject(ctx, "bucket", "large", strings.NewReader("small"), 5, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, ok := remote.blobs["bucket/large"]; ok {
+		t.Errorf("stale remote copy was not deleted")
+	}
+	if got := readObject(t, tiered, "bucket", "large"); got != "small" {
+		t.Errorf("unexpected local object %q", got)
+	}
+
+	if err := tiered.DeleteObject(ctx, "bucket", "small"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, err := tiered.GetObject(ctx, "bucket", "small"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected ErrObjectNotFound, got %v", err)
+	}
+}
+
+func TestTiered_BucketPolicy(t *testing.T) {
+	ctx := context.Background()
+	remote := newMemoryBlobStore()
+	tiered := newTestTiered(t, remote, TieringConfiguration{})
+
+	if err := tiered.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := tiered.GetBucketTiering(ctx, "bucket"); !errors.Is(err, ErrNoSuchTieringConfiguration) {
+		t.Errorf("expected ErrNoSuchTieringConfiguration, got %v", err)
+	}
+
+	if err := tiered.PutBucketTiering(ctx, "bucket", &TieringConfiguration{SizeThreshold: 1}); err != nil {
+		t.Fatalf("PutBucketTiering: %v", err)
+	}
+	config, err := tiered.GetBucketTiering(ctx, "bucket")
+	if err != nil {
+		t.Fatalf("GetBucketTiering: %v", err)
+	}
+	if config.SizeThreshold != 1 {
+		t.Errorf("unexpected size threshold %d", config.SizeThreshold)
+	}
+
+	if _, err := tiered.PutObject(ctx, "bucket", "key", strings.NewReader("data"), 4, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, ok := remote.blobs["bucket/key"]; !ok {
+		t.Errorf("object should follow the bucket policy and be stored remotely")
+	}
+}
+
+func TestTiered_MigrateColdObjects(t *testing.T) {
+	ctx := context.Background()
+	remote := newMemoryBlobStore()
+	tiered := newTestTiered(t, remote, TieringConfiguration{ColdAfterDays: 1})
+
+	if err := tiered.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	obj, err := tiered.PutObject(ctx, "bucket", "old", strings.NewReader("cold data"), 9, "", nil)
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, err := tiered.PutObject(ctx, "bucket", "new", strings.NewReader("hot data"), 8, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	// Backdate the first object past the cold threshold
+	obj.LastModified = time.Now().AddDate(0, 0, -2)
+	if err := tiered.metadata.PutObject(ctx, "bucket", obj); err != nil {
+		t.Fatalf("failed to backdate object: %v", err)
+	}
+
+	moved, err := tiered.MigrateColdObjects(ctx)
+	if err != nil {
+		t.Fatalf("MigrateColdObjects: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("expected 1 migrated object, got %d", moved)
+	}
+	if tiered.isLocal("bucket", "old") || !tiered.isLocal("bucket", "new") {
+		t.Errorf("unexpected placement after migration")
+	}
+	if got := readObject(t, tiered, "bucket", "old"); got != "cold data" {
+		t.Errorf("unexpected migrated object %q", got)
+	}
+}
+
+func TestTiered_MoveToRemoteReplacedObject(t *testing.T) {
+	ctx := context.Background()
+	remote := newMemoryBlobStore()
+	tiered := newTestTiered(t, remote, TieringConfiguration{ColdAfterDays: 1})
+
+	if err := tiered.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	stale, err := tiered.PutObject(ctx, "bucket", "key", strings.NewReader("cold data"), 9, "", nil)
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, err := tiered.PutObject(ctx, "bucket", "key", strings.NewReader("hot data"), 8, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	// An object replaced since it was found cold stays local
+	moved, err := tiered.moveToRemote(ctx, "bucket", stale)
+	if err != nil {
+		t.Fatalf("moveToRemote: %v", err)
+	}
+	if moved || !tiered.isLocal("bucket", "key") {
+		t.Errorf("replaced object was moved to remote storage")
+	}
+	if _, ok := remote.blobs["bucket/key"]; ok {
+		t.Errorf("replaced object was uploaded")
+	}
+	if got := readObject(t, tiered, "bucket", "key"); got != "hot data" {
+		t.Errorf("unexpected object %q", got)
+	}
+}
@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxTrashEntries bounds the entries returned by ListTrash.
+const maxTrashEntries = 1000
+
+// TrashConfiguration is the trash mode setting of a bucket (JOG extension).
+// In trash mode, deleting an object of a non-versioned bucket moves it to the
+// trash of the bucket, where it can be restored for Days days before it is
+// purged.
+type TrashConfiguration struct {
+	Days int
+}
+
+// TrashEntry is a deleted object kept in the trash of its bucket.
+type TrashEntry struct {
+	// ID identifies the entry; a key deleted several times has several entries.
+	ID           string
+	Key          string
+	Size         int64
+	ETag         string
+	ContentType  string
+	LastModified time.Time
+	DeletedAt    time.Time
+	// ExpiresAt is when the entry is purged.
+	ExpiresAt time.Time
+}
+
+// trashedObject is a trash entry with the metadata needed to restore it.
+type trashedObject struct {
+	TrashEntry
+	trashDetails
+}
+
+// trashDetails is the metadata of a trashed object besides its listing
+// attributes, stored as JSON.
+type trashDetails struct {
+	Metadata    map[string]string `json:",omitempty"`
+	Compression string            `json:",omitempty"`
+	Encryption  *ObjectEncryption `json:",omitempty"`
+	Parts       []ObjectPart      `json:",omitempty"`
+	Tags        []Tag             `json:",omitempty"`
+}
+
+// trashLocation identifies an entry of the trash of a bucket.
+type trashLocation struct {
+	Bucket string
+	ID     string
+}
+
+// PutBucketTrash puts a bucket in trash mode. Buckets with versioning
+// enabled keep deleted objects as versions and cannot use trash mode.
+func (fs *FileSystem) PutBucketTrash(ctx context.Context, bucket string, config *TrashConfiguration) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	status, err := fs.metadata.GetBucketVersioning(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if VersioningStatus(status) == VersioningStatusEnabled {
+		return ErrTrashVersionedBucket
+	}
+
+	return fs.metadata.PutBucketTrash(ctx, bucket, config.Days)
+}
+
+// GetBucketTrash returns the trash mode setting of a bucket.
+func (fs *FileSystem) GetBucketTrash(ctx context.Context, bucket string) (*TrashConfiguration, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	days, err := fs.metadata.GetBucketTrash(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if days == 0 {
+		return nil, ErrNoSuchTrashConfiguration
+	}
+	return &TrashConfiguration{Days: days}, nil
+}
+
+// DeleteBucketTrash takes a bucket out of trash mode. Objects already in the
+// trash are kept until they expire.
+func (fs *FileSystem) DeleteBucketTrash(ctx context.Context, bucket string) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return fs.metadata.DeleteBucketTrash(ctx, bucket)
+}
+
+// trashPath returns the path of the data of a trash entry. Entries are kept
+// in the hidden .trash directory of their bucket.
+func (fs *FileSystem) trashPath(bucket, id string) string {
+	return filepath.Join(fs.dataDir, bucket, ".trash", id)
+}
+
+// trashObject moves an object being deleted to the trash of its bucket if the
+// bucket is in trash mode. It reports whether the object was moved, in which
+// case its metadata is deleted; otherwise the caller deletes the object.
+func (fs *FileSystem) trashObject(ctx context.Context, bucket, key, objectPath string) (bool, error) {
+	days, err := fs.metadata.GetBucketTrash(ctx, bucket)
+	if err != nil || days == 0 {
+		return false, err
+	}
+	obj, err := fs.metadata.GetObject(ctx, bucket, key)
+	if err != nil || obj == nil {
+		return false, err
+	}
+
+	entry := &trashedObject{
+		TrashEntry: TrashEntry{
+			ID:           fs.newVersionID(),
+			Key:          key,
+			Size:         obj.Size,
+			ETag:         obj.ETag,
+			ContentType:  obj.ContentType,
+			LastModified: obj.LastModified,
+		},
+		trashDetails: trashDetails{Metadata: obj.Metadata},
+	}
+	if entry.Compression, err = fs.metadata.GetObjectCompression(ctx, bucket, key); err != nil {
+		return false, err
+	}
+	if entry.Encryption, err = fs.metadata.GetObjectEncryption(ctx, bucket, key, ""); err != nil {
+		return false, err
+	}
+	if entry.Parts, err = fs.metadata.GetObjectParts(ctx, bucket, key); err != nil {
+		return false, err
+	}
+	if entry.Tags, err = fs.metadata.GetObjectTags(ctx, bucket, key); err != nil {
+		return false, err
+	}
+
+	trashPath := fs.trashPath(bucket, entry.ID)
+	if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
+		return false, fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	if err := os.Rename(objectPath, trashPath); err != nil {
+		if os.IsNotExist(err) {
+			// Nothing to keep; the metadata is deleted as usual
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to move object to trash: %w", err)
+	}
+
+	entry.DeletedAt = time.Now().UTC()
+	entry.ExpiresAt = entry.DeletedAt.AddDate(0, 0, days)
+	if err := fs.metadata.PutTrashedObject(ctx, bucket, entry); err != nil {
+		os.Rename(trashPath, objectPath)
+		return false, err
+	}
+
+	fs.metadata.DeleteObjectTags(ctx, bucket, key)
+	return true, fs.metadata.DeleteObject(ctx, bucket, key)
+}
+
+// ListTrash returns up to 1000 objects in the trash of a bucket whose keys
+// start with prefix, ordered by key and most recently deleted first.
+func (fs *FileSystem) ListTrash(ctx context.Context, bucket, prefix string) ([]TrashEntry, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	return fs.metadata.ListTrash(ctx, bucket, prefix, maxTrashEntries)
+}
+
+// RestoreTrash moves an object in the trash of a bucket back to its key, with
+// the metadata, tags and encryption it was deleted with. It fails with
+// ErrObjectExists if an object was written to the key since.
+func (fs *FileSystem) RestoreTrash(ctx context.Context, bucket, id string) (*Object, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	entry, err := fs.metadata.GetTrashedObject(ctx, bucket, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrNoSuchTrashEntry
+	}
+	objectPath, err := fs.validateObjectKey(bucket, entry.Key)
+	if err != nil {
+		return nil, err
+	}
+	current, err := fs.metadata.GetObject(ctx, bucket, entry.Key)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		return nil, ErrObjectExists
+	}
+
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create object directory: %w", err)
+	}
+	if err := os.Rename(fs.trashPath(bucket, id), objectPath); err != nil {
+		return nil, fmt.Errorf("failed to restore object from trash: %w", err)
+	}
+
+	obj := &Object{
+		Key:          entry.Key,
+		Size:         entry.Size,
+		LastModified: entry.LastModified,
+		ETag:         entry.ETag,
+		ContentType:  entry.ContentType,
+		Metadata:     entry.Metadata,
+	}
+	if err := fs.putObjectAtRest(ctx, bucket, obj, entry.Encryption, CompressionAlgorithm(entry.Compression)); err != nil {
+		os.Rename(objectPath, fs.trashPath(bucket, id))
+		return nil, err
+	}
+	if len(entry.Parts) > 0 {
+		if err := fs.metadata.PutObjectParts(ctx, bucket, entry.Key, entry.Parts); err != nil {
+			return nil, err
+		}
+	}
+	if len(entry.Tags) > 0 {
+		if err := fs.metadata.PutObjectTags(ctx, bucket, entry.Key, entry.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fs.metadata.DeleteTrashedObject(ctx, bucket, id); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// PurgeTrash deletes the objects of all buckets whose trash retention ended
+// before now. It returns the number of objects purged.
+func (fs *FileSystem) PurgeTrash(ctx context.Context, now time.Time) (int, error) {
+	purged := 0
+	var errs []error
+	for {
+		expired, err := fs.metadata.ListExpiredTrash(ctx, now, maxTrashEntries)
+		if err != nil {
+			return purged, err
+		}
+		removed := 0
+		for _, loc := range expired {
+			if err := ctx.Err(); err != nil {
+				return purged, err
+			}
+			if err := os.Remove(fs.trashPath(loc.Bucket, loc.ID)); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("%s/%s: %w", loc.Bucket, loc.ID, err))
+				continue
+			}
+			if err := fs.metadata.DeleteTrashedObject(ctx, loc.Bucket, loc.ID); err != nil {
+				return purged, err
+			}
+			purged++
+			removed++
+		}
+		// Entries that could not be removed are retried on the next run
+		if len(expired) < maxTrashEntries || removed == 0 {
+			return purged, errors.Join(errs...)
+		}
+	}
+}
+
+// PutBucketTrash is not supported by passthrough backends, which delete
+// objects from the blob store directly.
+func (p *Passthrough) PutBucketTrash(ctx context.Context, bucket string, config *TrashConfiguration) error {
+	return ErrNotImplemented
+}
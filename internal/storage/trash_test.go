@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := fs.GetBucketTrash(ctx, "bucket"); !errors.Is(err, ErrNoSuchTrashConfiguration) {
+		t.Fatalf("GetBucketTrash: err = %v, want ErrNoSuchTrashConfiguration", err)
+	}
+	if err := fs.PutBucketTrash(ctx, "bucket", &TrashConfiguration{Days: 7}); err != nil {
+		t.Fatalf("PutBucketTrash: %v", err)
+	}
+	if config, err := fs.GetBucketTrash(ctx, "bucket"); err != nil || config.Days != 7 {
+		t.Fatalf("GetBucketTrash = %+v, %v, want 7 days", config, err)
+	}
+
+	meta := map[string]string{"owner": "alice"}
+	if _, err := fs.PutObject(ctx, "bucket", "docs/a.txt", strings.NewReader("hello"), 5, "text/plain", meta); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if err := fs.PutObjectTagging(ctx, "bucket", "docs/a.txt", []Tag{{Key: "team", Value: "storage"}}); err != nil {
+		t.Fatalf("PutObjectTagging: %v", err)
+	}
+	if _, err := fs.PutObject(ctx, "bucket", "b.txt", strings.NewReader("bye"), 3, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	// Deleted objects disappear from the bucket but are kept in the trash
+	if err := fs.DeleteObject(ctx, "bucket", "docs/a.txt"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, _, err := fs.DeleteObjects(ctx, "bucket", []string{"b.txt"}); err != nil {
+		t.Fatalf("DeleteObjects: %v", err)
+	}
+	if _, err := fs.HeadObject(ctx, "bucket", "docs/a.txt"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("HeadObject of a deleted object: err = %v, want ErrObjectNotFound", err)
+	}
+	entries, err := fs.ListTrash(ctx, "bucket", "")
+	if err != nil {
+		t.Fatalf("ListTrash: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Key != "b.txt" || entries[1].Key != "docs/a.txt" {
+		t.Fatalf("ListTrash = %+v", entries)
+	}
+	trashed, entry := entries[0], entries[1]
+	if entry.Size != 5 || entry.ContentType != "text/plain" || !entry.ExpiresAt.Equal(entry.DeletedAt.AddDate(0, 0, 7)) {
+		t.Errorf("trash entry = %+v", entry)
+	}
+	if entries, err := fs.ListTrash(ctx, "bucket", "docs/"); err != nil || len(entries) != 1 {
+		t.Errorf("ListTrash with prefix = %+v, %v", entries, err)
+	}
+
+	// Restoring fails while another object has the key
+	if _, err := fs.PutObject(ctx, "bucket", "docs/a.txt", strings.NewReader("new"), 3, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, err := fs.RestoreTrash(ctx, "bucket", entry.ID); !errors.Is(err, ErrObjectExists) {
+		t.Fatalf("RestoreTrash onto an object: err = %v, want ErrObjectExists", err)
+	}
+	if err := fs.DeleteBucketTrash(ctx, "bucket"); err != nil {
+		t.Fatalf("DeleteBucketTrash: %v", err)
+	}
+	if err := fs.DeleteObject(ctx, "bucket", "docs/a.txt"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+
+	obj, err := fs.RestoreTrash(ctx, "bucket", entry.ID)
+	if err != nil {
+		t.Fatalf("RestoreTrash: %v", err)
+	}
+	if obj.Key != "docs/a.txt" || obj.Size != 5 {
+		t.Errorf("restored object = %+v", obj)
+	}
+	data, err := fs.GetObject(ctx, "bucket", "docs/a.txt")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	body, _ := io.ReadAll(data.Body)
+	data.Body.Close()
+	if string(body) != "hello" || data.Metadata["owner"] != "alice" || data.ETag != entry.ETag {
+		t.Errorf("restored object = %q %+v", body, data.Object)
+	}
+	if tags, err := fs.GetObjectTagging(ctx, "bucket", "docs/a.txt"); err != nil || len(tags) != 1 || tags[0].Value != "storage" {
+		t.Errorf("restored tags = %+v, %v", tags, err)
+	}
+	if _, err := fs.RestoreTrash(ctx, "bucket", entry.ID); !errors.Is(err, ErrNoSuchTrashEntry) {
+		t.Errorf("RestoreTrash twice: err = %v, want ErrNoSuchTrashEntry", err)
+	}
+
+	// Expired entries are purged with their data
+	if n, err := fs.PurgeTrash(ctx, time.Now()); err != nil || n != 0 {
+		t.Fatalf("PurgeTrash before expiry = %d, %v", n, err)
+	}
+	if n, err := fs.PurgeTrash(ctx, time.Now().AddDate(0, 0, 8)); err != nil || n != 1 {
+		t.Fatalf("PurgeTrash = %d, %v, want 1", n, err)
+	}
+	if entries, err := fs.ListTrash(ctx, "bucket", ""); err != nil || len(entries) != 0 {
+		t.Errorf("ListTrash after purge = %+v, %v", entries, err)
+	}
+	if _, err := os.Stat(fs.trashPath("bucket", trashed.ID)); !os.IsNotExist(err) {
+		t.Errorf("purged trash file still exists: %v", err)
+	}
+}
+
+func TestTrashVersionedBucket(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := fs.PutBucketVersioning(ctx, "bucket", VersioningStatusEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	if err := fs.PutBucketTrash(ctx, "bucket", &TrashConfiguration{Days: 1}); !errors.Is(err, ErrTrashVersionedBucket) {
+		t.Errorf("PutBucketTrash: err = %v, want ErrTrashVersionedBucket", err)
+	}
+}
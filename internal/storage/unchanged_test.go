@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSkipUnchangedOverwrites(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	objectPath := filepath.Join(fs.dataDir, "bucket", "artifact.bin")
+
+	put := func(content, contentType string, metadata map[string]string) (*Object, os.FileInfo) {
+		t.Helper()
+		obj, err := fs.PutObject(ctx, "bucket", "artifact.bin", strings.NewReader(content), int64(len(content)), contentType, metadata)
+		if err != nil {
+			t.Fatalf("PutObject: %v", err)
+		}
+		info, err := os.Stat(objectPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj, info
+	}
+
+	// Disabled by default: identical overwrites replace the file
+	if enabled, err := fs.GetBucketSkipUnchanged(ctx, "bucket"); err != nil || enabled {
+		t.Fatalf("GetBucketSkipUnchanged = %v, %v, want false", enabled, err)
+	}
+	_, first := put("build-1", "application/octet-stream", nil)
+	_, second := put("build-1", "application/octet-stream", nil)
+	if os.SameFile(first, second) {
+		t.Error("identical overwrite kept the file with skipping disabled")
+	}
+
+	if err := fs.PutBucketSkipUnchanged(ctx, "missing", true); err != ErrBucketNotFound {
+		t.Fatalf("PutBucketSkipUnchanged on missing bucket = %v, want ErrBucketNotFound", err)
+	}
+	if err := fs.PutBucketSkipUnchanged(ctx, "bucket", true); err != nil {
+		t.Fatalf("PutBucketSkipUnchanged: %v", err)
+	}
+	if enabled, err := fs.GetBucketSkipUnchanged(ctx, "bucket"); err != nil || !enabled {
+		t.Fatalf("GetBucketSkipUnchanged = %v, %v, want true", enabled, err)
+	}
+
+	// Identical overwrites keep the file and the object
+	before, first := put("build-1", "application/octet-stream", nil)
+	after, second := put("build-1", "application/octet-stream", nil)
+	if !os.SameFile(first, second) {
+		t.Error("identical overwrite replaced the file")
+	}
+	if after.ETag != before.ETag || !after.LastModified.Equal(before.LastModified) {
+		t.Errorf("identical overwrite returned %+v, want %+v", after, before)
+	}
+
+	// Changed metadata is written without replacing the file
+	meta, third := put("build-1", "application/zip", map[string]string{"build": "1"})
+	if !os.SameFile(second, third) {
+		t.Error("metadata-only overwrite replaced the file")
+	}
+	head, err := fs.HeadObject(ctx, "bucket", "artifact.bin")
+	if err != nil {
+		t.Fatalf("HeadObject: %v", err)
+	}
+	if head.ContentType != "application/zip" || head.Metadata["build"] != "1" || head.ETag != meta.ETag {
+		t.Errorf("HeadObject after metadata-only overwrite = %+v", head)
+	}
+
+	// Changed data replaces the file
+	changed, fourth := put("build-2", "application/zip", map[string]string{"build": "1"})
+	if os.SameFile(third, fourth) || changed.ETag == meta.ETag {
+		t.Error("changed overwrite kept the file")
+	}
+
+	if err := fs.PutBucketSkipUnchanged(ctx, "bucket", false); err != nil {
+		t.Fatalf("PutBucketSkipUnchanged: %v", err)
+	}
+	if enabled, err := fs.GetBucketSkipUnchanged(ctx, "bucket"); err != nil || enabled {
+		t.Fatalf("GetBucketSkipUnchanged after disabling = %v, %v, want false", enabled, err)
+	}
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadStates(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	upload, err := fs.CreateMultipartUpload(ctx, "bucket", "big.bin", "application/octet-stream", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	if upload, err := fs.metadata.GetMultipartUpload(ctx, upload.UploadID); err != nil || upload.State != UploadStateActive {
+		t.Fatalf("GetMultipartUpload = %+v, %v, want an active upload", upload, err)
+	}
+	part, err := fs.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 1, strings.NewReader("part-data"), 9)
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+
+	// A completing upload cannot be changed or aborted
+	if ok, err := fs.metadata.TransitionUpload(ctx, upload.UploadID, UploadStateActive, UploadStateCompleting); err != nil || !ok {
+		t.Fatalf("TransitionUpload = %v, %v, want true", ok, err)
+	}
+	if ok, err := fs.metadata.TransitionUpload(ctx, upload.UploadID, UploadStateActive, UploadStateAborted); err != nil || ok {
+		t.Errorf("TransitionUpload from a stale state = %v, %v, want false", ok, err)
+	}
+	if _, err := fs.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 2, strings.NewReader("more"), 4); !errors.Is(err, ErrUploadCompleting) {
+		t.Errorf("UploadPart while completing: got %v, want ErrUploadCompleting", err)
+	}
+	if err := fs.AbortMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID); !errors.Is(err, ErrUploadCompleting) {
+		t.Errorf("AbortMultipartUpload while completing: got %v, want ErrUploadCompleting", err)
+	}
+	if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, []Part{*part}); !errors.Is(err, ErrUploadCompleting) {
+		t.Errorf("CompleteMultipartUpload while completing: got %v, want ErrUploadCompleting", err)
+	}
+	if ok, err := fs.metadata.TransitionUpload(ctx, upload.UploadID, UploadStateCompleting, UploadStateActive); err != nil || !ok {
+		t.Fatalf("TransitionUpload = %v, %v, want true", ok, err)
+	}
+
+	// A failed completion leaves the upload active
+	if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, []Part{{PartNumber: 1, ETag: "wrong"}}); !errors.Is(err, ErrInvalidPart) {
+		t.Fatalf("CompleteMultipartUpload with a wrong ETag: got %v, want ErrInvalidPart", err)
+	}
+	if _, err := fs.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 2, strings.NewReader("more"), 4); err != nil {
+		t.Errorf("UploadPart after a failed completion: %v", err)
+	}
+
+	// A part stored after the upload was aborted, as by an upload racing
+	// with the abort, is rejected, and the abort can be finished
+	if ok, err := fs.metadata.TransitionUpload(ctx, upload.UploadID, UploadStateActive, UploadStateAborted); err != nil || !ok {
+		t.Fatalf("TransitionUpload = %v, %v, want true", ok, err)
+	}
+	late := &Part{PartNumber: 3, Size: 4, ETag: "late", LastModified: time.Now()}
+	if err := fs.metadata.PutPart(ctx, upload.UploadID, late); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("PutPart after abort: got %v, want ErrUploadNotFound", err)
+	}
+	if err := fs.AbortMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID); err != nil {
+		t.Fatalf("AbortMultipartUpload: %v", err)
+	}
+	if err := fs.metadata.PutPart(ctx, upload.UploadID, late); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("PutPart of a deleted upload: got %v, want ErrUploadNotFound", err)
+	}
+	var parts int
+	if err := fs.metadata.db.QueryRow(`SELECT COUNT(*) FROM parts WHERE upload_id = ?`, upload.UploadID).Scan(&parts); err != nil {
+		t.Fatalf("count parts: %v", err)
+	}
+	if parts != 0 {
+		t.Errorf("%d parts left after abort, want none", parts)
+	}
+	if _, err := os.Stat(filepath.Join(fs.dataDir, ".uploads", upload.UploadID)); !os.IsNotExist(err) {
+		t.Errorf("parts directory after abort: got %v, want it removed", err)
+	}
+}
+
+func TestUploadPartDuringCompletion(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		upload, err := fs.CreateMultipartUpload(ctx, "bucket", "big.bin", "application/octet-stream", nil)
+		if err != nil {
+			t.Fatalf("CreateMultipartUpload: %v", err)
+		}
+		part, err := fs.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 1, strings.NewReader("original"), 8)
+		if err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+
+		// The part is uploaded again while the upload is completed
+		done := make(chan error, 1)
+		go func() {
+			_, err := fs.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 1, strings.NewReader("replaced"), 8)
+			done <- err
+		}()
+		_, completeErr := fs.CompleteMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID, []Part{*part})
+		uploadErr := <-done
+
+		if completeErr == nil {
+			// The completion assembled the part it listed
+			if got := readObject(t, fs, "bucket", "big.bin"); got != "original" {
+				t.Fatalf("object data = %q, want %q", got, "original")
+			}
+			if uploadErr == nil {
+				t.Fatalf("UploadPart succeeded after the upload was completed")
+			}
+			continue
+		}
+		// The part was replaced before the completion, which is rejected
+		if !errors.Is(completeErr, ErrInvalidPart) || uploadErr != nil {
+			t.Fatalf("CompleteMultipartUpload = %v, UploadPart = %v", completeErr, uploadErr)
+		}
+		data, err := os.ReadFile(filepath.Join(fs.dataDir, ".uploads", upload.UploadID, "1"))
+		if err != nil || string(data) != "replaced" {
+			t.Fatalf("part file = %q, %v, want %q", data, err, "replaced")
+		}
+		if err := fs.AbortMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID); err != nil {
+			t.Fatalf("AbortMultipartUpload: %v", err)
+		}
+	}
+}
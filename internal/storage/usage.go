@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// GetBucketUsage returns the number of objects, logical bytes, version bytes
+// and pending multipart bytes of a bucket (JOG extension). The counters are
+// maintained by the metadata database on every write and delete, so reading
+// them does not scan the bucket.
+func (fs *FileSystem) GetBucketUsage(ctx context.Context, bucket string) (*BucketUsage, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	return fs.metadata.GetBucketUsage(ctx, bucket)
+}
+
+// GetBucketUsageHistory returns the usage records of a bucket of the last
+// days days, including today, or all its records if days is not positive,
+// oldest first (JOG extension).
+func (fs *FileSystem) GetBucketUsageHistory(ctx context.Context, bucket string, days int) ([]UsageRecord, error) {
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	since := ""
+	if days > 0 {
+		since = time.Now().UTC().AddDate(0, 0, 1-days).Format(time.DateOnly)
+	}
+	return fs.metadata.ListUsageRecords(ctx, bucket, since)
+}
+
+// RecordUsageHistory records the usage of every bucket for the UTC day of
+// now, replacing the record of a previous run on the same day, and returns
+// the records (JOG extension). The physical size of a bucket is measured by
+// walking its directory. Records older than keepDays days are deleted if
+// keepDays is positive.
+func (fs *FileSystem) RecordUsageHistory(ctx context.Context, now time.Time, keepDays int) ([]UsageRecord, error) {
+	buckets, err := fs.metadata.ListBuckets(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	now = now.UTC()
+	var records []UsageRecord
+	for _, bucket := range buckets {
+		usage, err := fs.metadata.GetBucketUsage(ctx, bucket.Name)
+		if err != nil {
+			return records, err
+		}
+		physical, err := dirSize(ctx, filepath.Join(fs.dataDir, bucket.Name))
+		if err != nil {
+			return records, err
+		}
+		record := UsageRecord{
+			Bucket:         bucket.Name,
+			Date:           now.Format(time.DateOnly),
+			Objects:        usage.Objects,
+			Bytes:          usage.Bytes,
+			VersionBytes:   usage.VersionBytes,
+			MultipartBytes: usage.MultipartBytes,
+			PhysicalBytes:  physical,
+			RecordedAt:     now,
+		}
+		if err := fs.metadata.PutUsageRecord(ctx, &record); err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+
+	if keepDays > 0 {
+		if err := fs.metadata.DeleteUsageRecordsBefore(ctx, now.AddDate(0, 0, 1-keepDays).Format(time.DateOnly)); err != nil {
+			return records, err
+		}
+	}
+	return records, nil
+}
+
+// dirSize returns the total size of the files below dir.
+func dirSize(ctx context.Context, dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Files and directories removed concurrently are skipped
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bucketUsage(t *testing.T, fs *FileSystem) BucketUsage {
+	t.Helper()
+	usage, err := fs.GetBucketUsage(context.Background(), "bucket")
+	if err != nil {
+		t.Fatalf("GetBucketUsage: %v", err)
+	}
+	return *usage
+}
+
+func TestBucketUsage(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if got := bucketUsage(t, fs); got != (BucketUsage{}) {
+		t.Errorf("usage of an empty bucket = %+v", got)
+	}
+
+	// Overwrites replace the size of the object
+	for _, data := range []string{"hello", "hello world", "hi"} {
+		if _, err := fs.PutObject(ctx, "bucket", "a.txt", strings.NewReader(data), int64(len(data)), "", nil); err != nil {
+			t.Fatalf("PutObject: %v", err)
+		}
+	}
+	if _, err := fs.PutObject(ctx, "bucket", "b.txt", strings.NewReader("abcd"), 4, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if got, want := bucketUsage(t, fs), (BucketUsage{Objects: 2, Bytes: 6}); got != want {
+		t.Errorf("usage after puts = %+v, want %+v", got, want)
+	}
+	if err := fs.DeleteObject(ctx, "bucket", "a.txt"); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if got, want := bucketUsage(t, fs), (BucketUsage{Objects: 1, Bytes: 4}); got != want {
+		t.Errorf("usage after delete = %+v, want %+v", got, want)
+	}
+
+	// Versions count until they are deleted, delete markers do not
+	if err := fs.PutBucketVersioning(ctx, "bucket", VersioningStatusEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	_, v1, err := fs.PutObjectVersioned(ctx, "bucket", "v.txt", strings.NewReader("12345"), 5, "", nil)
+	if err != nil {
+		t.Fatalf("PutObjectVersioned: %v", err)
+	}
+	if _, _, err := fs.PutObjectVersioned(ctx, "bucket", "v.txt", strings.NewReader("123"), 3, "", nil); err != nil {
+		t.Fatalf("PutObjectVersioned: %v", err)
+	}
+	if got, want := bucketUsage(t, fs), (BucketUsage{Objects: 2, Bytes: 7, VersionBytes: 8}); got != want {
+		t.Errorf("usage after versioned puts = %+v, want %+v", got, want)
+	}
+	if _, _, err := fs.DeleteObjectVersioned(ctx, "bucket", "v.txt", v1); err != nil {
+		t.Fatalf("DeleteObjectVersioned: %v", err)
+	}
+	if got := bucketUsage(t, fs); got.VersionBytes != 3 {
+		t.Errorf("version bytes after deleting a version = %d, want 3", got.VersionBytes)
+	}
+
+	// Parts count while their upload is in progress
+	upload, err := fs.CreateMultipartUpload(ctx, "bucket", "big.bin", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	for _, data := range []string{"aaaaaaaa", "aa"} {
+		if _, err := fs.UploadPart(ctx, "bucket", "big.bin", upload.UploadID, 1, strings.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+	}
+	if got := bucketUsage(t, fs); got.MultipartUploads != 1 || got.MultipartBytes != 2 {
+		t.Errorf("usage of the upload = %+v, want 1 upload of 2 bytes", got)
+	}
+	if err := fs.AbortMultipartUpload(ctx, "bucket", "big.bin", upload.UploadID); err != nil {
+		t.Fatalf("AbortMultipartUpload: %v", err)
+	}
+	if got := bucketUsage(t, fs); got.MultipartUploads != 0 || got.MultipartBytes != 0 {
+		t.Errorf("usage after abort = %+v, want no uploads", got)
+	}
+
+	// Databases without counters get them computed from the tables
+	want := bucketUsage(t, fs)
+	if _, err := fs.metadata.db.Exec(`DROP TABLE bucket_usage`); err != nil {
+		t.Fatalf("DROP TABLE: %v", err)
+	}
+	if err := fs.metadata.initialize(); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if got := bucketUsage(t, fs); got != want {
+		t.Errorf("usage after recount = %+v, want %+v", got, want)
+	}
+
+	if _, err := fs.GetBucketUsage(ctx, "missing"); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("GetBucketUsage on a missing bucket: err = %v, want ErrBucketNotFound", err)
+	}
+}
+
+func TestRecordUsageHistory(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := fs.PutObject(ctx, "bucket", "a/b.txt", strings.NewReader("hello"), 5, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	now := time.Now().UTC()
+	for i, day := range []time.Time{now.AddDate(0, 0, -3), now.AddDate(0, 0, -1), now, now} {
+		records, err := fs.RecordUsageHistory(ctx, day, 3)
+		if err != nil {
+			t.Fatalf("RecordUsageHistory %d: %v", i, err)
+		}
+		if len(records) != 1 || records[0].Bytes != 5 || records[0].PhysicalBytes != 5 {
+			t.Fatalf("records %d = %+v", i, records)
+		}
+	}
+
+	// The record of three days ago is beyond the retention and today's
+	// second record replaced the first
+	history, err := fs.GetBucketUsageHistory(ctx, "bucket", 0)
+	if err != nil {
+		t.Fatalf("GetBucketUsageHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].Date != now.AddDate(0, 0, -1).Format(time.DateOnly) || history[1].Date != now.Format(time.DateOnly) {
+		t.Fatalf("history = %+v", history)
+	}
+	if history[1].Objects != 1 || history[1].Bucket != "bucket" {
+		t.Errorf("today's record = %+v", history[1])
+	}
+
+	if history, err := fs.GetBucketUsageHistory(ctx, "bucket", 1); err != nil || len(history) != 1 {
+		t.Errorf("history of the last day = %+v, %v", history, err)
+	}
+	if _, err := fs.GetBucketUsageHistory(ctx, "missing", 0); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("GetBucketUsageHistory of a missing bucket: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VersionIDFormat selects the format of new version IDs.
+type VersionIDFormat string
+
+const (
+	// VersionIDFormatULID generates ULIDs: a millisecond timestamp followed by
+	// random bits, in Crockford base32. IDs generated later sort after earlier
+	// ones, also within a millisecond, so they order versions with the same
+	// modification time.
+	VersionIDFormatULID VersionIDFormat = "ulid"
+	// VersionIDFormatUUID generates random UUIDs, as earlier releases did.
+	VersionIDFormatUUID VersionIDFormat = "uuid"
+)
+
+// ParseVersionIDFormat parses a version ID format. An empty string selects
+// VersionIDFormatULID.
+func ParseVersionIDFormat(s string) (VersionIDFormat, error) {
+	switch VersionIDFormat(s) {
+	case "", VersionIDFormatULID:
+		return VersionIDFormatULID, nil
+	case VersionIDFormatUUID:
+		return VersionIDFormatUUID, nil
+	default:
+		return "", fmt.Errorf("invalid version ID format %q", s)
+	}
+}
+
+// SetVersionIDFormat sets the format of new version IDs. Versions with IDs of
+// either format can be stored side by side.
+func (fs *FileSystem) SetVersionIDFormat(format VersionIDFormat) {
+	fs.versionIDFormat = format
+}
+
+// newVersionID returns a new version ID in the configured format.
+func (fs *FileSystem) newVersionID() string {
+	if fs.versionIDFormat == VersionIDFormatUUID {
+		return uuid.New().String()
+	}
+	return ulids.next(time.Now())
+}
+
+// crockford is the base32 alphabet of ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGenerator generates monotonic ULIDs: an ID generated in the same
+// millisecond as the previous one increments its random part instead of
+// drawing a new one.
+type ulidGenerator struct {
+	mu      sync.Mutex
+	lastMs  uint64
+	lastRnd [10]byte
+}
+
+// ulids generates the ULIDs of all stores of the process.
+var ulids ulidGenerator
+
+// next returns the ULID of now.
+func (g *ulidGenerator) next(now time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(now.UnixMilli())
+	if ms <= g.lastMs {
+		// Within the same millisecond, or after the clock went back
+		ms = g.lastMs
+		if !increment(g.lastRnd[:]) {
+			// The random part overflowed; borrow the next millisecond
+			ms++
+			rand.Read(g.lastRnd[:])
+		}
+	} else {
+		rand.Read(g.lastRnd[:])
+	}
+	g.lastMs = ms
+
+	var id [16]byte
+	for i := 5; i >= 0; i-- {
+		id[i] = byte(ms)
+		ms >>= 8
+	}
+	copy(id[6:], g.lastRnd[:])
+	return encodeULID(id)
+}
+
+// increment adds one to a big-endian number and reports false on overflow.
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID encodes the 128 bits of a ULID as 26 base32 characters, the
+// first of which holds the top 3 bits.
+func encodeULID(id [16]byte) string {
+	var out [26]byte
+	// Process the bits from the end, 5 at a time
+	var acc uint32
+	bits := 0
+	pos := len(out) - 1
+	for i := len(id) - 1; i >= 0; i-- {
+		acc |= uint32(id[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[pos] = crockford[acc&31]
+			pos--
+			acc >>= 5
+			bits -= 5
+		}
+	}
+	out[0] = crockford[acc&31]
+	return string(out[:])
+}
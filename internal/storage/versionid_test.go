@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestULIDsSortInGenerationOrder(t *testing.T) {
+	var g ulidGenerator
+	now := time.UnixMilli(1469918176385)
+
+	// IDs of the same millisecond, and of a clock going back, still increase
+	times := []time.Time{now, now, now, now.Add(-time.Second), now.Add(time.Millisecond)}
+	var prev string
+	for i, at := range times {
+		id := g.next(at)
+		if len(id) != 26 {
+			t.Fatalf("ULID %q has %d characters, want 26", id, len(id))
+		}
+		if i == 0 && !strings.HasPrefix(id, "01ARYZ6S41") {
+			t.Errorf("ULID %q does not start with the encoded time 01ARYZ6S41", id)
+		}
+		if id <= prev {
+			t.Errorf("ULID %d = %q, not after %q", i, id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestListObjectVersionsOrder(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	// Versions with the same modification time are ordered by their IDs
+	modified := time.Now().Truncate(time.Second)
+	var versionIDs []string
+	for range 3 {
+		version := &ObjectVersion{Key: "key", VersionID: fs.newVersionID(), LastModified: modified, ETag: "etag"}
+		if err := fs.metadata.PutObjectVersion(ctx, "bucket", version); err != nil {
+			t.Fatalf("PutObjectVersion: %v", err)
+		}
+		versionIDs = append(versionIDs, version.VersionID)
+	}
+
+	latest, err := fs.metadata.GetLatestObjectVersion(ctx, "bucket", "key")
+	if err != nil || latest == nil || latest.VersionID != versionIDs[2] {
+		t.Fatalf("GetLatestObjectVersion = %v, %v, want version %s", latest, err, versionIDs[2])
+	}
+
+	// Pages of one version each list the versions newest first, and only the
+	// first is the latest version
+	input := &ListObjectVersionsInput{Bucket: "bucket", MaxKeys: 1}
+	for i := 2; i >= 0; i-- {
+		output, err := fs.ListObjectVersions(ctx, input)
+		if err != nil {
+			t.Fatalf("ListObjectVersions: %v", err)
+		}
+		if len(output.Versions) != 1 || output.Versions[0].VersionID != versionIDs[i] {
+			t.Fatalf("page %d = %+v, want version %s", 2-i, output.Versions, versionIDs[i])
+		}
+		if got := output.Versions[0].IsLatest; got != (i == 2) {
+			t.Errorf("page %d IsLatest = %v", 2-i, got)
+		}
+		if output.IsTruncated != (i > 0) {
+			t.Fatalf("page %d IsTruncated = %v", 2-i, output.IsTruncated)
+		}
+		input.KeyMarker = output.NextKeyMarker
+		input.VersionIdMarker = output.NextVersionIdMarker
+	}
+}
+
+func TestParseVersionIDFormat(t *testing.T) {
+	for s, want := range map[string]VersionIDFormat{"": VersionIDFormatULID, "ulid": VersionIDFormatULID, "uuid": VersionIDFormatUUID} {
+		if got, err := ParseVersionIDFormat(s); err != nil || got != want {
+			t.Errorf("ParseVersionIDFormat(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseVersionIDFormat("ksuid"); err == nil {
+		t.Error("ParseVersionIDFormat(ksuid) succeeded")
+	}
+}
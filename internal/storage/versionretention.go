@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// maxPrunedVersions bounds the versions PruneNoncurrentVersions deletes per
+// batch.
+const maxPrunedVersions = 1000
+
+// VersionRetentionConfiguration is the version retention setting of a bucket
+// (JOG extension). Of the noncurrent versions of each key, only the
+// MaxNoncurrentVersions newest ones are kept; older versions, including
+// delete markers, are deleted by PruneNoncurrentVersions. This bounds the disk
+// space of versioned buckets whose objects are overwritten constantly, such
+// as CI artifacts.
+type VersionRetentionConfiguration struct {
+	MaxNoncurrentVersions int
+}
+
+// versionLocation identifies a version of an object.
+type versionLocation struct {
+	Bucket    string
+	Key       string
+	VersionID string
+}
+
+// PutBucketVersionRetention sets the number of noncurrent versions per key
+// kept in a bucket.
+func (fs *FileSystem) PutBucketVersionRetention(ctx context.Context, bucket string, config *VersionRetentionConfiguration) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	if config.MaxNoncurrentVersions < 0 {
+		return ErrInvalidVersionRetention
+	}
+	return fs.metadata.PutBucketVersionRetention(ctx, bucket, config.MaxNoncurrentVersions)
+}
+
+// GetBucketVersionRetention returns the version retention setting of a bucket.
+func (fs *FileSystem) GetBucketVersionRetention(ctx context.Context, bucket string) (*VersionRetentionConfiguration, error) {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBucketNotFound
+	}
+
+	maxNoncurrentVersions, err := fs.metadata.GetBucketVersionRetention(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if maxNoncurrentVersions < 0 {
+		return nil, ErrNoSuchVersionRetentionConfiguration
+	}
+	return &VersionRetentionConfiguration{MaxNoncurrentVersions: maxNoncurrentVersions}, nil
+}
+
+// DeleteBucketVersionRetention makes a bucket keep all versions again.
+func (fs *FileSystem) DeleteBucketVersionRetention(ctx context.Context, bucket string) error {
+	// Check if bucket exists
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBucketNotFound
+	}
+
+	return fs.metadata.DeleteBucketVersionRetention(ctx, bucket)
+}
+
+// PruneNoncurrentVersions deletes the noncurrent versions beyond the version
+// retention of their buckets, oldest first, and returns the number of
+// versions deleted. Current versions are never deleted.
+func (fs *FileSystem) PruneNoncurrentVersions(ctx context.Context) (int, error) {
+	pruned := 0
+	var errs []error
+	for {
+		excess, err := fs.metadata.ListExcessVersions(ctx, maxPrunedVersions)
+		if err != nil {
+			return pruned, err
+		}
+		removed := 0
+		for _, loc := range excess {
+			if err := ctx.Err(); err != nil {
+				return pruned, err
+			}
+			if _, _, err := fs.DeleteObjectVersioned(ctx, loc.Bucket, loc.Key, loc.VersionID); err != nil {
+				if errors.Is(err, ErrObjectNotFound) || errors.Is(err, ErrBucketNotFound) {
+					// Deleted concurrently
+					continue
+				}
+				errs = append(errs, fmt.Errorf("%s/%s@%s: %w", loc.Bucket, loc.Key, loc.VersionID, err))
+				continue
+			}
+			pruned++
+			removed++
+		}
+		// Versions that could not be deleted are retried on the next run
+		if len(excess) < maxPrunedVersions || removed == 0 {
+			return pruned, errors.Join(errs...)
+		}
+	}
+}
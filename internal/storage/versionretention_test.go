@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPruneNoncurrentVersions(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := fs.PutBucketVersioning(ctx, "bucket", VersioningStatusEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+
+	var versionIDs []string
+	for i := range 5 {
+		data := fmt.Sprintf("build %d", i)
+		_, versionID, err := fs.PutObjectVersioned(ctx, "bucket", "artifact", strings.NewReader(data), int64(len(data)), "", nil)
+		if err != nil {
+			t.Fatalf("PutObjectVersioned: %v", err)
+		}
+		versionIDs = append(versionIDs, versionID)
+	}
+
+	// Buckets without a retention keep all versions
+	if _, err := fs.GetBucketVersionRetention(ctx, "bucket"); !errors.Is(err, ErrNoSuchVersionRetentionConfiguration) {
+		t.Fatalf("GetBucketVersionRetention: err = %v, want ErrNoSuchVersionRetentionConfiguration", err)
+	}
+	if pruned, err := fs.PruneNoncurrentVersions(ctx); err != nil || pruned != 0 {
+		t.Fatalf("PruneNoncurrentVersions without retention = %d, %v, want 0", pruned, err)
+	}
+
+	if err := fs.PutBucketVersionRetention(ctx, "bucket", &VersionRetentionConfiguration{MaxNoncurrentVersions: -1}); !errors.Is(err, ErrInvalidVersionRetention) {
+		t.Fatalf("PutBucketVersionRetention(-1): err = %v, want ErrInvalidVersionRetention", err)
+	}
+	if err := fs.PutBucketVersionRetention(ctx, "bucket", &VersionRetentionConfiguration{MaxNoncurrentVersions: 2}); err != nil {
+		t.Fatalf("PutBucketVersionRetention: %v", err)
+	}
+
+	// The current version and the two newest noncurrent versions are kept
+	pruned, err := fs.PruneNoncurrentVersions(ctx)
+	if err != nil || pruned != 2 {
+		t.Fatalf("PruneNoncurrentVersions = %d, %v, want 2", pruned, err)
+	}
+	output, err := fs.ListObjectVersions(ctx, &ListObjectVersionsInput{Bucket: "bucket", MaxKeys: 1000})
+	if err != nil {
+		t.Fatalf("ListObjectVersions: %v", err)
+	}
+	kept := map[string]bool{}
+	for _, v := range output.Versions {
+		kept[v.VersionID] = true
+	}
+	for i, versionID := range versionIDs {
+		if want := i >= 2; kept[versionID] != want {
+			t.Errorf("version %d kept = %v, want %v", i, kept[versionID], want)
+		}
+	}
+	if obj, err := fs.HeadObject(ctx, "bucket", "artifact"); err != nil || obj.Size != int64(len("build 4")) {
+		t.Errorf("HeadObject after pruning = %v, %v, want the last build", obj, err)
+	}
+
+	// Pruning is idempotent
+	if pruned, err := fs.PruneNoncurrentVersions(ctx); err != nil || pruned != 0 {
+		t.Errorf("second PruneNoncurrentVersions = %d, %v, want 0", pruned, err)
+	}
+}
@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ReconcileObject brings the metadata of an object in line with its data file
+// after the file was added, changed or removed outside JOG. It reports whether
+// the metadata changed and whether the object was removed. Objects of
+// versioned buckets, compressed or encrypted objects and buckets unknown to
+// the metadata are left alone.
+func (fs *FileSystem) ReconcileObject(ctx context.Context, bucket, key string) (changed, removed bool, err error) {
+	objectPath, err := fs.validateObjectKey(bucket, key)
+	if err != nil {
+		return false, false, err
+	}
+
+	exists, err := fs.metadata.BucketExists(ctx, bucket)
+	if err != nil || !exists {
+		return false, false, err
+	}
+	versioning, err := fs.metadata.GetBucketVersioning(ctx, bucket)
+	if err != nil || versioning != "" {
+		return false, false, err
+	}
+	compression, err := fs.metadata.GetObjectCompression(ctx, bucket, key)
+	if err != nil || compression != "" {
+		return false, false, err
+	}
+	encryption, err := fs.metadata.GetObjectEncryption(ctx, bucket, key, "")
+	if err != nil || encryption != nil {
+		return false, false, err
+	}
+
+	obj, err := fs.metadata.GetObject(ctx, bucket, key)
+	if err != nil {
+		return false, false, err
+	}
+
+	info, err := os.Stat(objectPath)
+	if errors.Is(err, os.ErrNotExist) {
+		if obj == nil {
+			return false, false, nil
+		}
+		return true, true, fs.metadata.DeleteObject(ctx, bucket, key)
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if !info.Mode().IsRegular() {
+		return false, false, nil
+	}
+	// Files are written before their metadata, so files not modified since are unchanged
+	if obj != nil && obj.Size == info.Size() && !info.ModTime().After(obj.LastModified) {
+		return false, false, nil
+	}
+
+	f, err := os.Open(objectPath)
+	if err != nil {
+		return false, false, err
+	}
+	hash := md5.New()
+	size, err := io.Copy(hash, f)
+	f.Close()
+	if err != nil {
+		return false, false, fmt.Errorf("failed to read object file: %w", err)
+	}
+	sum := hash.Sum(nil)
+	if obj != nil && obj.Size == size && obj.ETag == hex.EncodeToString(sum) {
+		return false, false, nil
+	}
+
+	etag, err := fs.objectETag(ctx, bucket, sum)
+	if err != nil {
+		return false, false, err
+	}
+	updated := &Object{
+		Key:          key,
+		Size:         size,
+		LastModified: info.ModTime(),
+		ETag:         etag,
+	}
+	if obj != nil {
+		updated.ContentType = obj.ContentType
+		updated.Metadata = obj.Metadata
+	} else if updated.ContentType, err = guessContentType(objectPath); err != nil {
+		return false, false, err
+	}
+	return true, false, fs.metadata.PutObject(ctx, bucket, updated)
+}
+
+// WatchOptions holds options for watching the data directory.
+type WatchOptions struct {
+	// Debounce is how long a file must be left alone before it is reconciled,
+	// so files being copied are reconciled once they are complete.
+	Debounce time.Duration
+	// OnReconcile is called after the metadata of an object changed.
+	OnReconcile func(obj ObjectRef, removed bool)
+	// OnError is called when watching or reconciling fails.
+	OnError func(err error)
+}
+
+// ObjectRef identifies an object.
+type ObjectRef struct {
+	Bucket string
+	Key    string
+}
+
+// DataDirWatcher reconciles the metadata of objects whose files are changed
+// outside JOG, e.g. by rsync into the data directory.
+type DataDirWatcher struct {
+	fs      *FileSystem
+	opts    WatchOptions
+	watcher *fsnotify.Watcher
+
+	// dirs holds the watched directories
+	dirs map[string]bool
+
+	mu sync.Mutex
+	// pending maps objects to the time of their latest change
+	pending map[ObjectRef]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// WatchDataDir reconciles the files in the data directory that changed while
+// JOG was not running and starts watching it for further changes. Hidden
+// files and directories, such as temporary files and object versions, are
+// ignored. Files removed while JOG was not running are not detected.
+func (fs *FileSystem) WatchDataDir(opts WatchOptions) (*DataDirWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &DataDirWatcher{
+		fs:      fs,
+		opts:    opts,
+		watcher: watcher,
+		dirs:    make(map[string]bool),
+		pending: make(map[ObjectRef]time.Time),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := w.add(fs.dataDir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+// add watches a directory and its subdirectories, queueing the files found in
+// them for reconciliation.
+func (w *DataDirWatcher) add(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Directories removed concurrently are skipped
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if path != w.fs.dataDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if err := w.watcher.Add(path); err != nil {
+				return fmt.Errorf("failed to watch %s: %w", path, err)
+			}
+			w.dirs[path] = true
+			return nil
+		}
+		w.touch(path)
+		return nil
+	})
+}
+
+// object returns the object stored at path. It returns false for paths of
+// buckets and hidden files.
+func (w *DataDirWatcher) object(path string) (ObjectRef, bool) {
+	rel, err := filepath.Rel(w.fs.dataDir, path)
+	if err != nil {
+		return ObjectRef{}, false
+	}
+	bucket, keyPath, ok := strings.Cut(filepath.ToSlash(rel), "/")
+	if !ok || keyPath == "" || strings.HasPrefix(bucket, ".") {
+		return ObjectRef{}, false
+	}
+	for _, part := range strings.Split(keyPath, "/") {
+		if strings.HasPrefix(part, ".") {
+			return ObjectRef{}, false
+		}
+	}
+	key, ok := w.fs.pathKey(keyPath)
+	if !ok {
+		return ObjectRef{}, false
+	}
+	return ObjectRef{Bucket: bucket, Key: key}, true
+}
+
+// touch queues the object stored at path for reconciliation.
+func (w *DataDirWatcher) touch(path string) {
+	if obj, ok := w.object(path); ok {
+		w.queue(obj)
+	}
+}
+
+func (w *DataDirWatcher) queue(obj ObjectRef) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending[obj] = time.Now()
+}
+
+// removeDir queues the objects below a directory that was removed or moved
+// away, which is reported as a single event.
+func (w *DataDirWatcher) removeDir(path string) error {
+	for dir := range w.dirs {
+		if dir == path || strings.HasPrefix(dir, path+string(filepath.Separator)) {
+			delete(w.dirs, dir)
+		}
+	}
+	dir, ok := w.object(path)
+	if !ok {
+		return nil
+	}
+
+	input := &ListObjectsInput{Bucket: dir.Bucket, Prefix: dir.Key + "/", MaxKeys: 1000}
+	for {
+		output, err := w.fs.ListObjectsV2(context.Background(), input)
+		if err != nil {
+			return err
+		}
+		for _, obj := range output.Objects {
+			w.queue(ObjectRef{Bucket: dir.Bucket, Key: obj.Key})
+		}
+		if !output.IsTruncated {
+			return nil
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+}
+
+func (w *DataDirWatcher) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(max(w.opts.Debounce/2, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.error(err)
+		case <-ticker.C:
+			w.reconcile()
+		}
+	}
+}
+
+// handle queues the objects affected by a filesystem event.
+func (w *DataDirWatcher) handle(event fsnotify.Event) {
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+			// Files may have been created before the directory was watched
+			if err := w.add(event.Name); err != nil {
+				w.error(err)
+			}
+			return
+		}
+	}
+	if (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) && w.dirs[event.Name] {
+		if err := w.removeDir(event.Name); err != nil {
+			w.error(err)
+		}
+		return
+	}
+	w.touch(event.Name)
+}
+
+// reconcile reconciles the objects left alone for the debounce interval.
+func (w *DataDirWatcher) reconcile() {
+	w.mu.Lock()
+	var ready []ObjectRef
+	for obj, changed := range w.pending {
+		if time.Since(changed) >= w.opts.Debounce {
+			ready = append(ready, obj)
+			delete(w.pending, obj)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, obj := range ready {
+		changed, removed, err := w.fs.ReconcileObject(context.Background(), obj.Bucket, obj.Key)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidKey) {
+				w.error(fmt.Errorf("failed to reconcile %s/%s: %w", obj.Bucket, obj.Key, err))
+			}
+			continue
+		}
+		if changed && w.opts.OnReconcile != nil {
+			w.opts.OnReconcile(obj, removed)
+		}
+	}
+}
+
+func (w *DataDirWatcher) error(err error) {
+	if w.opts.OnError != nil {
+		w.opts.OnError(err)
+	}
+}
+
+// Close stops watching the data directory.
+func (w *DataDirWatcher) Close() error {
+	close(w.stop)
+	<-w.done
+	return w.watcher.Close()
+}
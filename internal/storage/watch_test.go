@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReconcileObject(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := fs.PutObject(ctx, "bucket", "kept", strings.NewReader("data"), 4, "text/plain", map[string]string{"a": "b"}); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	// Objects written through JOG are unchanged
+	if changed, _, err := fs.ReconcileObject(ctx, "bucket", "kept"); err != nil || changed {
+		t.Errorf("ReconcileObject(kept) = %v, %v", changed, err)
+	}
+
+	// Added files become objects
+	added := filepath.Join(fs.dataDir, "bucket", "dir", "added.json")
+	if err := os.MkdirAll(filepath.Dir(added), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(added, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, removed, err := fs.ReconcileObject(ctx, "bucket", "dir/added.json"); err != nil || !changed || removed {
+		t.Fatalf("ReconcileObject(added) = %v, %v, %v", changed, removed, err)
+	}
+	obj, err := fs.HeadObject(ctx, "bucket", "dir/added.json")
+	if err != nil || obj.Size != 2 || obj.ContentType != "application/json" || obj.ETag != "99914b932bd37a50b983c5e7c90ae93b" {
+		t.Errorf("added object = %+v, %v", obj, err)
+	}
+
+	// Changed files keep their content type and metadata
+	later := time.Now().Add(time.Minute)
+	kept := filepath.Join(fs.dataDir, "bucket", "kept")
+	if err := os.WriteFile(kept, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(kept, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _, err := fs.ReconcileObject(ctx, "bucket", "kept"); err != nil || !changed {
+		t.Errorf("ReconcileObject(changed) = %v, %v", changed, err)
+	}
+	if got := readObject(t, fs, "bucket", "kept"); got != "changed" {
+		t.Errorf("kept = %q", got)
+	}
+	obj, err = fs.HeadObject(ctx, "bucket", "kept")
+	if err != nil || obj.Size != 7 || obj.ContentType != "text/plain" || obj.Metadata["a"] != "b" {
+		t.Errorf("changed object = %+v, %v", obj, err)
+	}
+
+	// Removed files are removed from the metadata
+	if err := os.Remove(kept); err != nil {
+		t.Fatal(err)
+	}
+	if changed, removed, err := fs.ReconcileObject(ctx, "bucket", "kept"); err != nil || !changed || !removed {
+		t.Errorf("ReconcileObject(removed) = %v, %v, %v", changed, removed, err)
+	}
+	if _, err := fs.HeadObject(ctx, "bucket", "kept"); err == nil {
+		t.Error("removed object still exists")
+	}
+
+	// Files of unknown buckets are ignored
+	if changed, _, err := fs.ReconcileObject(ctx, "other", "key"); err != nil || changed {
+		t.Errorf("ReconcileObject(other) = %v, %v", changed, err)
+	}
+}
+
+func TestWatchDataDir(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	// Files added while not watching are picked up on start
+	if err := os.WriteFile(filepath.Join(fs.dataDir, "bucket", "before"), []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reconciled := make(chan ObjectRef, 10)
+	w, err := fs.WatchDataDir(WatchOptions{
+		Debounce:    20 * time.Millisecond,
+		OnReconcile: func(obj ObjectRef, removed bool) { reconciled <- obj },
+		OnError:     func(err error) { t.Errorf("watch error: %v", err) },
+	})
+	if err != nil {
+		t.Fatalf("WatchDataDir: %v", err)
+	}
+	defer w.Close()
+
+	wait := func(want string) {
+		t.Helper()
+		select {
+		case obj := <-reconciled:
+			if obj.Key != want {
+				t.Errorf("reconciled %s, want %s", obj.Key, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s was not reconciled", want)
+		}
+	}
+	wait("before")
+
+	// Files in new directories and hidden files
+	dir := filepath.Join(fs.dataDir, "bucket", "a", "b")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".partial"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, ".partial"), filepath.Join(dir, "file")); err != nil {
+		t.Fatal(err)
+	}
+	wait("a/b/file")
+	if got := readObject(t, fs, "bucket", "a/b/file"); got != "x" {
+		t.Errorf("a/b/file = %q", got)
+	}
+
+	// Directories moved away remove their objects
+	if err := os.Rename(filepath.Join(fs.dataDir, "bucket", "a"), filepath.Join(t.TempDir(), "a")); err != nil {
+		t.Fatal(err)
+	}
+	wait("a/b/file")
+	if _, err := fs.HeadObject(ctx, "bucket", "a/b/file"); err == nil {
+		t.Error("moved object still exists")
+	}
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWormBucket(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	if enabled, err := fs.GetBucketWorm(ctx, "bucket"); err != nil || enabled {
+		t.Fatalf("GetBucketWorm = %v, %v, want false", enabled, err)
+	}
+	if err := fs.PutBucketWorm(ctx, "bucket", true); err != nil {
+		t.Fatalf("PutBucketWorm: %v", err)
+	}
+	if enabled, err := fs.GetBucketWorm(ctx, "bucket"); err != nil || !enabled {
+		t.Fatalf("GetBucketWorm = %v, %v, want true", enabled, err)
+	}
+	if err := fs.PutBucketWorm(ctx, "bucket", false); !errors.Is(err, ErrWormPermanent) {
+		t.Fatalf("PutBucketWorm disabling: err = %v, want ErrWormPermanent", err)
+	}
+
+	// New keys can be written once
+	if _, err := fs.PutObject(ctx, "bucket", "log/1", strings.NewReader("first"), 5, "", nil); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if _, err := fs.CopyObject(ctx, "bucket", "log/1", "bucket", "log/2", nil); err != nil {
+		t.Fatalf("CopyObject to a new key: %v", err)
+	}
+
+	// Existing keys can be neither overwritten nor deleted
+	if _, err := fs.PutObject(ctx, "bucket", "log/1", strings.NewReader("other"), 5, "", nil); !errors.Is(err, ErrObjectImmutable) {
+		t.Errorf("PutObject overwrite: err = %v, want ErrObjectImmutable", err)
+	}
+	if _, err := fs.AppendObject(ctx, "bucket", "log/1", 5, strings.NewReader("more"), 4, "", nil); !errors.Is(err, ErrObjectImmutable) {
+		t.Errorf("AppendObject: err = %v, want ErrObjectImmutable", err)
+	}
+	if _, err := fs.CopyObject(ctx, "bucket", "log/2", "bucket", "log/1", nil); !errors.Is(err, ErrObjectImmutable) {
+		t.Errorf("CopyObject overwrite: err = %v, want ErrObjectImmutable", err)
+	}
+	if _, err := fs.CopyObject(ctx, "bucket", "log/1", "bucket", "log/1", map[string]string{"a": "b"}); !errors.Is(err, ErrObjectImmutable) {
+		t.Errorf("CopyObject onto itself: err = %v, want ErrObjectImmutable", err)
+	}
+	if err := fs.DeleteObject(ctx, "bucket", "log/1"); !errors.Is(err, ErrObjectImmutable) {
+		t.Errorf("DeleteObject: err = %v, want ErrObjectImmutable", err)
+	}
+	deleted, errs, err := fs.DeleteObjects(ctx, "bucket", []string{"log/2", "missing"})
+	if err != nil {
+		t.Fatalf("DeleteObjects: %v", err)
+	}
+	if len(errs) != 1 || errs[0].Key != "log/2" || errs[0].Code != "AccessDenied" || len(deleted) != 1 {
+		t.Errorf("DeleteObjects = %+v, %+v", deleted, errs)
+	}
+
+	upload, err := fs.CreateMultipartUpload(ctx, "bucket", "log/1", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	part, err := fs.UploadPart(ctx, "bucket", "log/1", upload.UploadID, 1, strings.NewReader("part"), 4)
+	if err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	if _, err := fs.CompleteMultipartUpload(ctx, "bucket", "log/1", upload.UploadID, []Part{*part}); !errors.Is(err, ErrObjectImmutable) {
+		t.Errorf("CompleteMultipartUpload: err = %v, want ErrObjectImmutable", err)
+	}
+
+	data, err := fs.GetObject(ctx, "bucket", "log/1")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	data.Body.Close()
+	if data.Size != 5 || len(data.Metadata) != 0 {
+		t.Errorf("object changed: %+v", data.Object)
+	}
+}
+
+func TestWormVersionedBucket(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestFileSystem(t)
+	if err := fs.CreateBucket(ctx, "bucket"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if err := fs.PutBucketVersioning(ctx, "bucket", VersioningStatusEnabled); err != nil {
+		t.Fatalf("PutBucketVersioning: %v", err)
+	}
+	if err := fs.PutBucketWorm(ctx, "bucket", true); err != nil {
+		t.Fatalf("PutBucketWorm: %v", err)
+	}
+
+	_, versionID, err := fs.PutObjectVersioned(ctx, "bucket", "log", strings.NewReader("first"), 5, "", nil)
+	if err != nil {
+		t.Fatalf("PutObjectVersioned: %v", err)
+	}
+	if _, _, err := fs.PutObjectVersioned(ctx, "bucket", "log", strings.NewReader("other"), 5, "", nil); !errors.Is(err, ErrObjectImmutable) {
+		t.Errorf("PutObjectVersioned overwrite: err = %v, want ErrObjectImmutable", err)
+	}
+	if _, _, err := fs.DeleteObjectVersioned(ctx, "bucket", "log", ""); !errors.Is(err, ErrObjectImmutable) {
+		t.Errorf("DeleteObjectVersioned: err = %v, want ErrObjectImmutable", err)
+	}
+	if _, _, err := fs.DeleteObjectVersioned(ctx, "bucket", "log", versionID); !errors.Is(err, ErrObjectImmutable) {
+		t.Errorf("DeleteObjectVersioned of a version: err = %v, want ErrObjectImmutable", err)
+	}
+}
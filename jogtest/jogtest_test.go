@@ -0,0 +1,139 @@
+package jogtest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/jogtest"
+)
+
+func newClient(srv *jogtest.Server) *s3.Client {
+	return s3.New(s3.Options{
+		BaseEndpoint: aws.String(srv.URL),
+		Region:       srv.Region,
+		Credentials:  credentials.NewStaticCredentialsProvider(srv.AccessKey, srv.SecretKey, ""),
+		UsePathStyle: true,
+	})
+}
+
+func TestNew(t *testing.T) {
+	srv := jogtest.New(t)
+	client := newClient(srv)
+	ctx := context.Background()
+
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("bucket")}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("key"),
+		Body:   strings.NewReader("hello"),
+	}); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	defer out.Body.Close()
+	body, _ := io.ReadAll(out.Body)
+	if string(body) != "hello" {
+		t.Errorf("body = %q, want %q", body, "hello")
+	}
+
+	// Unsigned requests are rejected
+	resp, err := http.Get(srv.URL + "/bucket/key")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unsigned request status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestStart_Close(t *testing.T) {
+	srv, err := jogtest.Start(jogtest.Options{DisableAuth: true})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	srv.Close()
+	srv.Close()
+	if _, err := os.Stat(srv.DataDir); !os.IsNotExist(err) {
+		t.Errorf("data directory still exists after Close: %v", err)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var order []string
+	srv := jogtest.NewWithOptions(t, jogtest.Options{
+		DisableAuth: true,
+		Middleware: []func(http.Handler) http.Handler{
+			func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, "middleware")
+					w.Header().Set("X-Hook", "1")
+					next.ServeHTTP(w, r)
+				})
+			},
+		},
+		RequestFilters: []func(r *http.Request) error{
+			func(r *http.Request) error {
+				order = append(order, "filter")
+				if r.Header.Get("X-Blocked") != "" {
+					return errors.New("blocked by filter")
+				}
+				return nil
+			},
+		},
+	})
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp.Header.Get("X-Hook") != "1" {
+		t.Errorf("X-Hook header missing")
+	}
+	if strings.Join(order, ",") != "middleware,filter" {
+		t.Errorf("hook order = %v, want [middleware filter]", order)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/", nil)
+	req.Header.Set("X-Blocked", "1")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("filtered status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+	if !strings.Contains(string(body), "blocked by filter") {
+		t.Errorf("body = %s, want filter message", body)
+	}
+}
@@ -0,0 +1,115 @@
+package s3compat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendObject sends an append request using raw HTTP since the AWS SDK has no append operation.
+func appendObject(t *testing.T, ts *testutil.TestServer, bucket, key, position, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPut, ts.Endpoint+"/"+bucket+"/"+key+"?append&position="+position, strings.NewReader(body))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestAppendObject(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := "logs/app.log"
+
+	// First append creates the object
+	resp := appendObject(t, ts, bucketName, key, "0", "line 1\n")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "7", resp.Header.Get("x-jog-next-append-position"))
+
+	// Second append extends it
+	resp = appendObject(t, ts, bucketName, key, "7", "line 2\n")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "14", resp.Header.Get("x-jog-next-append-position"))
+	appendETag := resp.Header.Get("ETag")
+
+	// Object content and ETag reflect both appends
+	getResp, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	defer getResp.Body.Close()
+
+	body, err := io.ReadAll(getResp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\n", string(body))
+	assert.Equal(t, int64(14), aws.ToInt64(getResp.ContentLength))
+	assert.Equal(t, appendETag, aws.ToString(getResp.ETag))
+	assert.Equal(t, "\"c7253b64411b3aa485924efce6494bb5\"", aws.ToString(getResp.ETag))
+}
+
+func TestAppendObjectPositionMismatch(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := testutil.RandomObjectKey()
+
+	// Appending to a missing object must start at position 0
+	resp := appendObject(t, ts, bucketName, key, "5", "data")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get("x-jog-next-append-position"))
+
+	resp = appendObject(t, ts, bucketName, key, "0", "data")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Stale position is rejected and reports the current length
+	resp = appendObject(t, ts, bucketName, key, "0", "more")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "4", resp.Header.Get("x-jog-next-append-position"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "PositionNotEqualToLength")
+}
+
+func TestAppendObjectInvalidPosition(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	for _, position := range []string{"", "-1", "abc"} {
+		resp := appendObject(t, ts, bucketName, testutil.RandomObjectKey(), position, "data")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "position %q", position)
+	}
+}
@@ -4,13 +4,19 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	jogconfig "github.com/kumasuke/jog/internal/config"
 	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -312,3 +318,123 @@ func TestAuthWithSpecialCharactersInKey(t *testing.T) {
 		})
 	}
 }
+
+func TestCredentialScope(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		EnableAuth: true,
+		Regions:    []string{"eu-west-1"},
+		Services:   []string{"s3"},
+	})
+	defer ts.Cleanup()
+
+	ctx := context.Background()
+	creds := credentials.NewStaticCredentialsProvider(ts.AccessKey, ts.SecretKey, "")
+	clientFor := func(region string) *s3.Client {
+		return s3.New(s3.Options{
+			Region:       region,
+			BaseEndpoint: aws.String(ts.Endpoint),
+			UsePathStyle: true,
+			Credentials:  creds,
+		})
+	}
+
+	t.Run("AllowedRegion", func(t *testing.T) {
+		_, err := clientFor("eu-west-1").ListBuckets(ctx, &s3.ListBucketsInput{})
+		require.NoError(t, err)
+	})
+
+	t.Run("WrongRegion", func(t *testing.T) {
+		_, err := clientFor("us-east-1").ListBuckets(ctx, &s3.ListBucketsInput{})
+		var apiErr smithy.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "AuthorizationHeaderMalformed", apiErr.ErrorCode())
+		assert.Contains(t, apiErr.ErrorMessage(), "the region 'us-east-1' is wrong; expecting 'eu-west-1'")
+	})
+
+	t.Run("WrongRegionPresigned", func(t *testing.T) {
+		req, err := s3.NewPresignClient(clientFor("us-east-1")).PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String("scope-bucket"),
+			Key:    aws.String("key"),
+		})
+		require.NoError(t, err)
+
+		resp, err := http.Get(req.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Contains(t, string(body), "<Code>AuthorizationQueryParametersError</Code>")
+		assert.Contains(t, string(body), "<Region>eu-west-1</Region>")
+	})
+
+	t.Run("WrongService", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, ts.Endpoint+"/", nil)
+		require.NoError(t, err)
+		cred, err := creds.Retrieve(ctx)
+		require.NoError(t, err)
+		url, _, err := v4.NewSigner().PresignHTTP(ctx, cred, req, "UNSIGNED-PAYLOAD", "sts", "eu-west-1", time.Now())
+		require.NoError(t, err)
+
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Contains(t, string(body), "incorrect service &#39;sts&#39;")
+	})
+}
+
+func TestPresignedURLCache(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		EnableAuth:   true,
+		PresignCache: jogconfig.PresignCacheConfig{Size: 10, TTL: time.Minute},
+	})
+	defer ts.Cleanup()
+
+	ctx := context.Background()
+	client := ts.S3Client(t)
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("media.txt"),
+		Body:   bytes.NewReader([]byte("cached")),
+	})
+	require.NoError(t, err)
+
+	req, err := s3.NewPresignClient(client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("media.txt"),
+	}, s3.WithPresignExpires(2*time.Second))
+	require.NoError(t, err)
+
+	get := func(url string) (int, string) {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// Repeated requests are served, the second from the cache
+	for range 2 {
+		status, body := get(req.URL)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "cached", body)
+	}
+
+	// Another signature for the cached URL is verified
+	tampered := req.URL[:len(req.URL)-1] + "0"
+	if tampered == req.URL {
+		tampered = req.URL[:len(req.URL)-1] + "1"
+	}
+	status, _ := get(tampered)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// Expired URLs are rejected although they were cached
+	time.Sleep(2100 * time.Millisecond)
+	status, _ = get(req.URL)
+	assert.Equal(t, http.StatusForbidden, status)
+}
@@ -0,0 +1,69 @@
+package s3compat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadBucketRegionAndAccess(t *testing.T) {
+	ts := testutil.NewTestServerWithTenants(t,
+		config.TenantConfig{Name: "team", AccessKey: "alice", SecretKey: "alice-secret"},
+		config.TenantConfig{Name: "team", AccessKey: "bob", SecretKey: "bob-secret"},
+	)
+	defer ts.Cleanup()
+
+	ctx := context.Background()
+	alice := ts.S3ClientWithCredentials(t, "alice", "alice-secret")
+	bob := ts.S3ClientWithCredentials(t, "bob", "bob-secret")
+
+	bucketName := testutil.RandomBucketName()
+	_, err := alice.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucketName)})
+	require.NoError(t, err)
+
+	statusCode := func(err error) int {
+		var respErr interface{ HTTPStatusCode() int }
+		require.True(t, errors.As(err, &respErr), "unexpected error: %v", err)
+		return respErr.HTTPStatusCode()
+	}
+
+	t.Run("Region", func(t *testing.T) {
+		output, err := alice.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucketName)})
+		require.NoError(t, err)
+		assert.Equal(t, "us-east-1", aws.ToString(output.BucketRegion))
+	})
+
+	t.Run("ForbiddenForOtherOwner", func(t *testing.T) {
+		_, err := bob.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucketName)})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, statusCode(err))
+	})
+
+	t.Run("MovedForOtherRegion", func(t *testing.T) {
+		euBucket := testutil.RandomBucketName()
+		_, err := alice.CreateBucket(ctx, &s3.CreateBucketInput{
+			Bucket: aws.String(euBucket),
+			CreateBucketConfiguration: &types.CreateBucketConfiguration{
+				LocationConstraint: types.BucketLocationConstraintEuWest1,
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = alice.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(euBucket)})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusMovedPermanently, statusCode(err))
+
+		location, err := alice.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: aws.String(euBucket)})
+		require.NoError(t, err)
+		assert.Equal(t, types.BucketLocationConstraintEuWest1, location.LocationConstraint)
+	})
+}
@@ -0,0 +1,32 @@
+package s3compat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/internal/conformance"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	ts := testutil.NewTestServerWithAuth(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	report := conformance.Run(context.Background(), client)
+	require.NotEmpty(t, report.Results)
+	for _, result := range report.Results {
+		assert.Equal(t, conformance.StatusPass, result.Status, "%s: %s", result.Operation, result.Error)
+	}
+	assert.Contains(t, report.Unchecked, "PutObjectRetention")
+
+	// The check bucket is deleted
+	out, err := client.ListBuckets(context.Background(), &s3.ListBucketsInput{})
+	require.NoError(t, err)
+	for _, b := range out.Buckets {
+		assert.NotEqual(t, report.Bucket, *b.Name)
+	}
+}
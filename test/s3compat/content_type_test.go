@@ -0,0 +1,107 @@
+package s3compat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// putRaw sends a request using raw HTTP, so no Content-Type header is added.
+func putRaw(t *testing.T, method, url, contentType, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestContentTypeInference(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	contentType := func(key string) string {
+		t.Helper()
+		out, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		require.NoError(t, err)
+		return aws.ToString(out.ContentType)
+	}
+	put := func(key, contentType string) {
+		t.Helper()
+		resp := putRaw(t, http.MethodPut, ts.Endpoint+"/"+bucketName+"/"+key, contentType, "data")
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	put("site/index.html", "")
+	put("site/app.js", "")
+	put("blob", "")
+	put("explicit.html", "text/plain")
+	assert.Equal(t, "text/html; charset=utf-8", contentType("site/index.html"))
+	assert.Equal(t, "text/javascript; charset=utf-8", contentType("site/app.js"))
+	assert.Equal(t, "application/octet-stream", contentType("blob"))
+	assert.Equal(t, "text/plain", contentType("explicit.html"))
+
+	// No bucket rules yet
+	resp := putRaw(t, http.MethodGet, ts.Endpoint+"/"+bucketName+"?content-types", "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	rules := `<ContentTypeConfiguration>
+		<Rule><Extension>md</Extension><ContentType>text/x-markdown</ContentType></Rule>
+		<Rule><Extension>.JS</Extension><ContentType>application/javascript</ContentType></Rule>
+	</ContentTypeConfiguration>`
+	resp = putRaw(t, http.MethodPut, ts.Endpoint+"/"+bucketName+"?content-types", "application/xml", rules)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodGet, ts.Endpoint+"/"+bucketName+"?content-types", "", "")
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "<Extension>.js</Extension>")
+	assert.Contains(t, string(body), "<Extension>.md</Extension>")
+
+	// Bucket rules apply to new uploads only
+	put("notes2.md", "")
+	put("site/app2.js", "")
+	assert.Equal(t, "text/x-markdown", contentType("notes2.md"))
+	assert.Equal(t, "application/javascript", contentType("site/app2.js"))
+	assert.Equal(t, "text/javascript; charset=utf-8", contentType("site/app.js"))
+
+	// Invalid rules are rejected
+	invalid := `<ContentTypeConfiguration><Rule><Extension>tar.gz</Extension><ContentType>application/gzip</ContentType></Rule></ContentTypeConfiguration>`
+	resp = putRaw(t, http.MethodPut, ts.Endpoint+"/"+bucketName+"?content-types", "application/xml", invalid)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodDelete, ts.Endpoint+"/"+bucketName+"?content-types", "", "")
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	put("site/app3.js", "")
+	assert.Equal(t, "text/javascript; charset=utf-8", contentType("site/app3.js"))
+}
@@ -0,0 +1,112 @@
+package s3compat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketDefaultTags(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	objectTags := func(key string) map[string]string {
+		out, err := client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		require.NoError(t, err)
+		tags := make(map[string]string)
+		for _, tag := range out.TagSet {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+		return tags
+	}
+
+	bucketURL := ts.Endpoint + "/" + bucketName
+	resp := putRaw(t, http.MethodGet, bucketURL+"?default-tags", "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodPut, bucketURL+"?default-tags", "application/xml",
+		`<Tagging><TagSet><Tag><Key>team</Key><Value>storage</Value></Tag><Tag><Key>retention</Key><Value>short</Value></Tag></TagSet></Tagging>`)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodGet, bucketURL+"?default-tags", "", "")
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "<Key>retention</Key><Value>short</Value>")
+
+	// Requested tags win over default tags with the same key
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:  aws.String(bucketName),
+		Key:     aws.String("report.csv"),
+		Body:    strings.NewReader("a,b,c"),
+		Tagging: aws.String("retention=long&owner=alice"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "storage", "retention": "long", "owner": "alice"}, objectTags("report.csv"))
+
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("archive.bin"),
+	})
+	require.NoError(t, err)
+	part, err := client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String("archive.bin"),
+		UploadId:   upload.UploadId,
+		PartNumber: aws.Int32(1),
+		Body:       strings.NewReader("data"),
+	})
+	require.NoError(t, err)
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String("archive.bin"),
+		UploadId: upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: []types.CompletedPart{{PartNumber: aws.Int32(1), ETag: part.ETag}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "storage", "retention": "short"}, objectTags("archive.bin"))
+
+	// Duplicate keys are rejected
+	resp = putRaw(t, http.MethodPut, bucketURL+"?default-tags", "application/xml",
+		`<Tagging><TagSet><Tag><Key>a</Key><Value>1</Value></Tag><Tag><Key>a</Key><Value>2</Value></Tag></TagSet></Tagging>`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodDelete, bucketURL+"?default-tags", "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("plain.txt"),
+		Body:   strings.NewReader("plain"),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, objectTags("plain.txt"))
+	// Objects keep the default tags they were written with
+	assert.Equal(t, "storage", objectTags("archive.bin")["team"])
+}
@@ -0,0 +1,147 @@
+package s3compat
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectoryBucket(t *testing.T) {
+	ts := testutil.NewTestServerWithAuth(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName() + "--use1-az4--x-s3"
+
+	_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{
+		Bucket: aws.String(bucketName),
+		CreateBucketConfiguration: &types.CreateBucketConfiguration{
+			Location: &types.LocationInfo{
+				Type: types.LocationTypeAvailabilityZone,
+				Name: aws.String("use1-az4"),
+			},
+			Bucket: &types.BucketInfo{
+				Type:           types.BucketTypeDirectory,
+				DataRedundancy: types.DataRedundancySingleAvailabilityZone,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("CreateSession", func(t *testing.T) {
+		result, err := client.CreateSession(ctx, &s3.CreateSessionInput{
+			Bucket:      aws.String(bucketName),
+			SessionMode: types.SessionModeReadOnly,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, result.Credentials)
+		assert.NotEmpty(t, aws.ToString(result.Credentials.AccessKeyId))
+		assert.NotEmpty(t, aws.ToString(result.Credentials.SecretAccessKey))
+		assert.NotEmpty(t, aws.ToString(result.Credentials.SessionToken))
+		assert.True(t, aws.ToTime(result.Credentials.Expiration).After(time.Now()))
+	})
+
+	t.Run("ObjectsWithSessionAuth", func(t *testing.T) {
+		// The SDK creates a session and signs object requests with its credentials
+		for _, key := range []string{"logs/2024/a.txt", "logs/2024/b.txt", "logs/2025/c.txt", "readme.txt"} {
+			_, err := client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket: aws.String(bucketName),
+				Key:    aws.String(key),
+				Body:   strings.NewReader("content of " + key),
+			})
+			require.NoError(t, err)
+		}
+
+		result, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("readme.txt"),
+		})
+		require.NoError(t, err)
+		defer result.Body.Close()
+		body, _ := io.ReadAll(result.Body)
+		assert.Equal(t, "content of readme.txt", string(body))
+	})
+
+	t.Run("ListObjectsV2Directories", func(t *testing.T) {
+		result, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:    aws.String(bucketName),
+			Prefix:    aws.String("logs/"),
+			Delimiter: aws.String("/"),
+		})
+		require.NoError(t, err)
+		var prefixes []string
+		for _, prefix := range result.CommonPrefixes {
+			prefixes = append(prefixes, aws.ToString(prefix.Prefix))
+		}
+		assert.Equal(t, []string{"logs/2024/", "logs/2025/"}, prefixes)
+		assert.Empty(t, result.Contents)
+
+		// Only prefixes ending in the delimiter are supported
+		_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:    aws.String(bucketName),
+			Prefix:    aws.String("logs/20"),
+			Delimiter: aws.String("/"),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "InvalidArgument")
+
+		// "/" is the only supported delimiter
+		_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:    aws.String(bucketName),
+			Delimiter: aws.String("-"),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "InvalidArgument")
+	})
+
+	t.Run("SessionCredentialsAreScopedToBucket", func(t *testing.T) {
+		session, err := client.CreateSession(ctx, &s3.CreateSessionInput{Bucket: aws.String(bucketName)})
+		require.NoError(t, err)
+
+		otherBucket := testutil.RandomBucketName()
+		cleanup := ts.CreateTestBucket(t, otherBucket)
+		defer cleanup()
+
+		sessionClient := ts.S3ClientWithCredentials(t,
+			aws.ToString(session.Credentials.AccessKeyId),
+			aws.ToString(session.Credentials.SecretAccessKey),
+		)
+		_, err = sessionClient.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String(otherBucket),
+		}, func(o *s3.Options) {
+			o.APIOptions = append(o.APIOptions, smithyhttp.AddHeaderValue("x-amz-s3session-token", aws.ToString(session.Credentials.SessionToken)))
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "403")
+	})
+
+	t.Run("InvalidDirectoryBucketName", func(t *testing.T) {
+		_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{
+			Bucket: aws.String("bad.name--use1-az4--x-s3"),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "InvalidBucketName")
+	})
+
+	t.Run("CreateSessionGeneralPurposeBucket", func(t *testing.T) {
+		otherBucket := testutil.RandomBucketName()
+		cleanup := ts.CreateTestBucket(t, otherBucket)
+		defer cleanup()
+
+		_, err := client.CreateSession(ctx, &s3.CreateSessionInput{Bucket: aws.String(otherBucket)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "InvalidRequest")
+	})
+}
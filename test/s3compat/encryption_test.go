@@ -2,12 +2,18 @@ package s3compat
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
+	"github.com/kumasuke/jog/internal/storage"
 	"github.com/kumasuke/jog/test/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -253,3 +259,84 @@ func TestPutBucketEncryptionOverwrite(t *testing.T) {
 	assert.Equal(t, "test-key-id", *rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID)
 	assert.True(t, *rule.BucketKeyEnabled)
 }
+
+func TestRandomETagsForEncryptedBucket(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{EncryptedETags: storage.ETagModeRandom})
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: aws.String(bucketName),
+		ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+			Rules: []types.ServerSideEncryptionRule{{
+				ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{
+					SSEAlgorithm: types.ServerSideEncryptionAes256,
+				},
+			}},
+		},
+	})
+	require.NoError(t, err)
+
+	content := "secret content"
+	sum := md5.Sum([]byte(content))
+	contentMD5 := hex.EncodeToString(sum[:])
+
+	// The SDK validates the upload with Content-MD5 and checksums, not the ETag
+	put, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String("object"),
+		Body:       strings.NewReader(content),
+		ContentMD5: aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, "\""+contentMD5+"\"", aws.ToString(put.ETag))
+
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("object"),
+	})
+	require.NoError(t, err)
+	body, err := io.ReadAll(get.Body)
+	get.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, content, string(body))
+	assert.Equal(t, aws.ToString(put.ETag), aws.ToString(get.ETag))
+
+	copied, err := client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String("copy"),
+		CopySource: aws.String(bucketName + "/object"),
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, "\""+contentMD5+"\"", aws.ToString(copied.CopyObjectResult.ETag))
+
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("multipart"),
+	})
+	require.NoError(t, err)
+	part, err := client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String("multipart"),
+		UploadId:   upload.UploadId,
+		PartNumber: aws.Int32(1),
+		Body:       strings.NewReader(content),
+	})
+	require.NoError(t, err)
+	complete, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String("multipart"),
+		UploadId: upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: []types.CompletedPart{{ETag: part.ETag, PartNumber: aws.Int32(1)}},
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(aws.ToString(complete.ETag), "-1\""))
+}
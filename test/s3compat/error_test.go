@@ -5,6 +5,7 @@ import (
 	"encoding/xml"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -17,11 +18,15 @@ import (
 
 // S3ErrorResponse represents the XML error response from S3.
 type S3ErrorResponse struct {
-	XMLName   xml.Name `xml:"Error"`
-	Code      string   `xml:"Code"`
-	Message   string   `xml:"Message"`
-	Resource  string   `xml:"Resource"`
-	RequestID string   `xml:"RequestId"`
+	XMLName    xml.Name `xml:"Error"`
+	Code       string   `xml:"Code"`
+	Message    string   `xml:"Message"`
+	BucketName string   `xml:"BucketName"`
+	Key        string   `xml:"Key"`
+	VersionID  string   `xml:"VersionId"`
+	UploadID   string   `xml:"UploadId"`
+	Resource   string   `xml:"Resource"`
+	RequestID  string   `xml:"RequestId"`
 }
 
 func TestErrorResponseFormat(t *testing.T) {
@@ -273,3 +278,126 @@ func TestGetObjectReturns200(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
+
+func TestErrorResponseElements(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	require.NoError(t, err)
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("dir/object"),
+		Body:   strings.NewReader("data"),
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		want   S3ErrorResponse
+	}{
+		{
+			name:   "NoSuchBucket",
+			method: http.MethodGet,
+			path:   "/non-existent-bucket/key",
+			status: http.StatusNotFound,
+			want:   S3ErrorResponse{Code: "NoSuchBucket", BucketName: "non-existent-bucket"},
+		},
+		{
+			name:   "NoSuchKey",
+			method: http.MethodGet,
+			path:   "/" + bucketName + "/dir/missing",
+			status: http.StatusNotFound,
+			want:   S3ErrorResponse{Code: "NoSuchKey", Key: "dir/missing"},
+		},
+		{
+			name:   "NoSuchVersion",
+			method: http.MethodGet,
+			path:   "/" + bucketName + "/dir/object?versionId=00000000-0000-0000-0000-000000000000",
+			status: http.StatusNotFound,
+			want:   S3ErrorResponse{Code: "NoSuchVersion", Key: "dir/object", VersionID: "00000000-0000-0000-0000-000000000000"},
+		},
+		{
+			name:   "NoSuchUpload",
+			method: http.MethodDelete,
+			path:   "/" + bucketName + "/dir/object?uploadId=missing-upload",
+			status: http.StatusNotFound,
+			want:   S3ErrorResponse{Code: "NoSuchUpload", UploadID: "missing-upload"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, ts.Endpoint+tc.path, nil)
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.status, resp.StatusCode)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			var got S3ErrorResponse
+			require.NoError(t, xml.Unmarshal(body, &got))
+
+			assert.Equal(t, tc.want.Code, got.Code)
+			assert.Equal(t, tc.want.BucketName, got.BucketName)
+			assert.Equal(t, tc.want.Key, got.Key)
+			assert.Equal(t, tc.want.VersionID, got.VersionID)
+			assert.Equal(t, tc.want.UploadID, got.UploadID)
+			assert.NotEmpty(t, got.RequestID)
+			assert.Equal(t, resp.Header.Get("x-amz-request-id"), got.RequestID)
+		})
+	}
+}
+
+func TestRequestIDHeader(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	// Successful responses carry a request ID too
+	resp, err := http.Get(ts.Endpoint + "/" + bucketName)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	first := resp.Header.Get("x-amz-request-id")
+	assert.NotEmpty(t, first)
+
+	resp, err = http.Get(ts.Endpoint + "/" + bucketName)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.NotEqual(t, first, resp.Header.Get("x-amz-request-id"))
+
+	// The SDK reads the request ID of errors
+	_, err = client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("missing"),
+	})
+	require.Error(t, err)
+	var respErr interface{ ServiceRequestID() string }
+	if assert.ErrorAs(t, err, &respErr) {
+		assert.NotEmpty(t, respErr.ServiceRequestID())
+	}
+}
@@ -0,0 +1,74 @@
+package s3compat
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStream(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.Endpoint+"/_jog/admin/events?bucket="+bucketName+"&prefix=watched/", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	for _, key := range []string{"ignored.txt", "watched/a.txt"} {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+			Body:   strings.NewReader("hello"),
+		})
+		require.NoError(t, err)
+	}
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("watched/a.txt"),
+	})
+	require.NoError(t, err)
+
+	type event struct {
+		Type string `json:"type"`
+		Key  string `json:"key"`
+		Size int64  `json:"size"`
+	}
+	var received []event
+	scanner := bufio.NewScanner(resp.Body)
+	for len(received) < 2 && scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e event
+		require.NoError(t, json.Unmarshal([]byte(data), &e))
+		received = append(received, e)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, received, 2)
+	assert.Equal(t, event{Type: "s3:ObjectCreated:Put", Key: "watched/a.txt", Size: 5}, received[0])
+	assert.Equal(t, event{Type: "s3:ObjectRemoved:Delete", Key: "watched/a.txt"}, received[1])
+}
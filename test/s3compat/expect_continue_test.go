@@ -0,0 +1,97 @@
+package s3compat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sendExpectHeaders opens a connection and sends the headers of a PUT request
+// with the given Expect header, but not its body.
+func sendExpectHeaders(t *testing.T, endpoint, target, expect string, contentLength int) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	u, err := url.Parse(endpoint)
+	require.NoError(t, err)
+	conn, err := net.Dial("tcp", u.Host)
+	require.NoError(t, err)
+	fmt.Fprintf(conn, "PUT %s HTTP/1.1\r\nHost: %s\r\nContent-Length: %d\r\nExpect: %s\r\n\r\n", target, u.Host, contentLength, expect)
+	return conn, bufio.NewReader(conn)
+}
+
+func TestExpectContinue(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	conn, reader := sendExpectHeaders(t, ts.Endpoint, "/"+bucketName+"/object", "100-continue", 5)
+	defer conn.Close()
+
+	// 100 Continue arrives before the body is sent
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusContinue, resp.StatusCode)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = io.WriteString(conn, "hello")
+	require.NoError(t, err)
+	resp, err = http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	getResult, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("object"),
+	})
+	require.NoError(t, err)
+	defer getResult.Body.Close()
+	body, err := io.ReadAll(getResult.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestExpectContinueRejected(t *testing.T) {
+	ts := testutil.NewTestServerWithAuth(t)
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	// Unauthenticated requests get their error instead of 100 Continue
+	conn, reader := sendExpectHeaders(t, ts.Endpoint, "/"+bucketName+"/object", "100-continue", 5)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Unknown expectations fail
+	conn2, reader2 := sendExpectHeaders(t, ts.Endpoint, "/"+bucketName+"/object", "something-else", 5)
+	defer conn2.Close()
+	require.NoError(t, conn2.SetReadDeadline(time.Now().Add(5*time.Second)))
+	resp, err = http.ReadResponse(reader2, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusExpectationFailed, resp.StatusCode)
+}
@@ -0,0 +1,129 @@
+package s3compat
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// faultsRequest sends a request to the admin faults endpoint and returns the
+// response body.
+func faultsRequest(t *testing.T, ts *testutil.TestServer, method, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.Endpoint+"/_jog/admin/faults", strings.NewReader(body))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+// getRawObject downloads an object with raw HTTP so SDK retries do not hide
+// the response status.
+func getRawObject(t *testing.T, ts *testutil.TestServer, bucket, key string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get(ts.Endpoint + "/" + bucket + "/" + key)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestFaultInjection(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer func() {
+		faultsRequest(t, ts, http.MethodDelete, "")
+		cleanup()
+	}()
+
+	content := strings.Repeat("x", 300)
+	resp := putRawObject(t, ts, bucketName, "object.txt", content)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		status, body := faultsRequest(t, ts, http.MethodGet, "")
+		require.Equal(t, http.StatusOK, status, body)
+		assert.JSONEq(t, `{"enabled":false,"rules":[]}`, body)
+	})
+
+	t.Run("ErrorsOnChosenOperations", func(t *testing.T) {
+		status, body := faultsRequest(t, ts, http.MethodPut,
+			`{"enabled":true,"rules":[{"operations":["GetObject"],"errorRate":1,"errorCode":"SlowDown"}]}`)
+		require.Equal(t, http.StatusOK, status, body)
+
+		status, body = getRawObject(t, ts, bucketName, "object.txt")
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Contains(t, body, "<Code>SlowDown</Code>")
+
+		// Other operations are served
+		resp := putRawObject(t, ts, bucketName, "other.txt", "data")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		status, body := faultsRequest(t, ts, http.MethodPut,
+			`{"enabled":true,"rules":[{"buckets":["`+bucketName+`"],"latencyMs":200}]}`)
+		require.Equal(t, http.StatusOK, status, body)
+
+		start := time.Now()
+		status, body = getRawObject(t, ts, bucketName, "object.txt")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, content, body)
+		assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	})
+
+	t.Run("SlowBody", func(t *testing.T) {
+		status, body := faultsRequest(t, ts, http.MethodPut,
+			`{"enabled":true,"rules":[{"operations":["GetObject"],"bodyBytesPerSecond":1000}]}`)
+		require.Equal(t, http.StatusOK, status, body)
+
+		start := time.Now()
+		status, body = getRawObject(t, ts, bucketName, "object.txt")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, content, body)
+		assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+	})
+
+	t.Run("DisabledRulesAreKept", func(t *testing.T) {
+		status, body := faultsRequest(t, ts, http.MethodPut,
+			`{"enabled":false,"rules":[{"errorRate":1}]}`)
+		require.Equal(t, http.StatusOK, status, body)
+		assert.Contains(t, body, `"errorRate":1`)
+
+		status, _ = getRawObject(t, ts, bucketName, "object.txt")
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("DeleteDisables", func(t *testing.T) {
+		status, body := faultsRequest(t, ts, http.MethodDelete, "")
+		require.Equal(t, http.StatusOK, status, body)
+		assert.JSONEq(t, `{"enabled":false,"rules":[]}`, body)
+	})
+
+	t.Run("InvalidRules", func(t *testing.T) {
+		status, _ := faultsRequest(t, ts, http.MethodPut, `{"enabled":true,"rules":[{"errorRate":2}]}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = faultsRequest(t, ts, http.MethodPut, `{"enabled":true,"rules":[{"errorRate":1,"errorCode":"NoSuchKey"}]}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
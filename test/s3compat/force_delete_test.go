@@ -0,0 +1,122 @@
+package s3compat
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deleteBucketRaw sends a DeleteBucket request with raw HTTP and returns the status code and body.
+func deleteBucketRaw(t *testing.T, ts *testutil.TestServer, bucket, query string, header http.Header) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodDelete, ts.Endpoint+"/"+bucket+query, nil)
+	require.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestForceDeleteBucket(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	populate := func(t *testing.T, bucket string) {
+		_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+		require.NoError(t, err)
+		_, err = client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+			Bucket:                  aws.String(bucket),
+			VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+		})
+		require.NoError(t, err)
+
+		for _, key := range []string{"a.txt", "a.txt", "dir/b.txt"} {
+			_, err := client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(key),
+				Body:   bytes.NewReader([]byte("data")),
+			})
+			require.NoError(t, err)
+		}
+		_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String("dir/b.txt")})
+		require.NoError(t, err)
+
+		_, err = client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String("pending.bin"),
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("WithoutForce", func(t *testing.T) {
+		bucket := testutil.RandomBucketName()
+		populate(t, bucket)
+
+		status, body := deleteBucketRaw(t, ts, bucket, "", nil)
+		assert.Equal(t, http.StatusConflict, status, body)
+		assert.Contains(t, body, "BucketNotEmpty")
+	})
+
+	t.Run("ForceQuery", func(t *testing.T) {
+		bucket := testutil.RandomBucketName()
+		populate(t, bucket)
+
+		status, body := deleteBucketRaw(t, ts, bucket, "?force=true", nil)
+		assert.Equal(t, http.StatusNoContent, status, body)
+
+		_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+		assert.Error(t, err)
+
+		// The name can be reused with no leftover uploads
+		_, err = client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+		require.NoError(t, err)
+		uploads, err := client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: aws.String(bucket)})
+		require.NoError(t, err)
+		assert.Empty(t, uploads.Uploads)
+	})
+
+	t.Run("MinioHeader", func(t *testing.T) {
+		bucket := testutil.RandomBucketName()
+		populate(t, bucket)
+
+		status, body := deleteBucketRaw(t, ts, bucket, "", http.Header{"X-Minio-Force-Delete": {"true"}})
+		assert.Equal(t, http.StatusNoContent, status, body)
+	})
+
+	t.Run("ObjectLockBucket", func(t *testing.T) {
+		bucket := testutil.RandomBucketName()
+		_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{
+			Bucket:                     aws.String(bucket),
+			ObjectLockEnabledForBucket: aws.Bool(true),
+		})
+		require.NoError(t, err)
+
+		status, body := deleteBucketRaw(t, ts, bucket, "?force=true", nil)
+		assert.Equal(t, http.StatusBadRequest, status, body)
+		assert.Contains(t, body, "InvalidRequest")
+	})
+
+	t.Run("NoSuchBucket", func(t *testing.T) {
+		status, body := deleteBucketRaw(t, ts, testutil.RandomBucketName(), "?force=true", nil)
+		assert.Equal(t, http.StatusNotFound, status, body)
+	})
+}
@@ -0,0 +1,58 @@
+package s3compat
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestH2C(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{H2C: true})
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	// A client speaking HTTP/2 with prior knowledge, as proxies do
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+
+	// Multiplex concurrent requests over the connection
+	var wg sync.WaitGroup
+	for _, key := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodPut, ts.Endpoint+"/"+bucketName+"/"+key, strings.NewReader("data of "+key))
+			if !assert.NoError(t, err) {
+				return
+			}
+			resp, err := client.Do(req)
+			if !assert.NoError(t, err) {
+				return
+			}
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, 2, resp.ProtoMajor)
+		}()
+	}
+	wg.Wait()
+
+	resp, err := client.Get(ts.Endpoint + "/" + bucketName + "/c.txt")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Equal(t, "data of c.txt", string(body))
+}
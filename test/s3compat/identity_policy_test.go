@@ -0,0 +1,300 @@
+package s3compat
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertAccessDenied asserts that a request failed with AccessDenied.
+func assertAccessDenied(t *testing.T, err error) {
+	t.Helper()
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "AccessDenied", apiErr.ErrorCode())
+	}
+}
+
+func TestIdentityPolicies(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		EnableAuth: true,
+		Policies: []config.PolicyConfig{
+			{Name: "read-only", Document: `{
+				"Version": "2012-10-17",
+				"Statement": [{"Effect": "Allow", "Action": ["s3:GetObject", "s3:ListBucket", "s3:ListAllMyBuckets"], "Resource": "*"}]
+			}`},
+			{Name: "operator", Document: `{
+				"Version": "2012-10-17",
+				"Statement": [{"Effect": "Allow", "Action": "jog:Admin", "Resource": "*"}]
+			}`},
+			{Name: "bucket-remover", Document: `{
+				"Version": "2012-10-17",
+				"Statement": [{"Effect": "Allow", "Action": "s3:DeleteBucket", "Resource": "*"}]
+			}`},
+			{Name: "bucket-cleaner", Document: `{
+				"Version": "2012-10-17",
+				"Statement": [{"Effect": "Allow", "Action": ["s3:DeleteBucket", "s3:DeleteObject"], "Resource": "*"}]
+			}`},
+			{Name: "db-writer", Document: `{
+				"Version": "2012-10-17",
+				"Statement": [
+					{"Effect": "Allow", "Action": ["s3:PutObject", "s3:DeleteObject"], "Resource": "arn:aws:s3:::*/db/*"},
+					{"Effect": "Allow", "Action": "s3:ListBucket", "Resource": "*", "Condition": {"StringLike": {"s3:prefix": "db/*"}}}
+				]
+			}`},
+		},
+		Users: []config.UserConfig{
+			{AccessKey: "backup-reader", SecretKey: "backup-reader-secret", Policies: []string{"read-only"}},
+			{AccessKey: "db-writer", SecretKey: "db-writer-secret", Policies: []string{"db-writer"}},
+			{AccessKey: "unrestricted", SecretKey: "unrestricted-secret"},
+			{AccessKey: "operator", SecretKey: "operator-secret", Policies: []string{"operator"}},
+			{AccessKey: "bucket-remover", SecretKey: "bucket-remover-secret", Policies: []string{"bucket-remover"}},
+			{AccessKey: "bucket-cleaner", SecretKey: "bucket-cleaner-secret", Policies: []string{"bucket-cleaner"}},
+		},
+	})
+	defer ts.Cleanup()
+
+	ctx := context.Background()
+	admin := ts.S3Client(t)
+	reader := ts.S3ClientWithCredentials(t, "backup-reader", "backup-reader-secret")
+	writer := ts.S3ClientWithCredentials(t, "db-writer", "db-writer-secret")
+	unrestricted := ts.S3ClientWithCredentials(t, "unrestricted", "unrestricted-secret")
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := admin.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("web/index.html"),
+		Body:   bytes.NewReader([]byte("index")),
+	})
+	require.NoError(t, err)
+
+	t.Run("ReadOnlyKey", func(t *testing.T) {
+		_, err := reader.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("web/index.html"),
+		})
+		require.NoError(t, err)
+
+		_, err = reader.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucketName)})
+		require.NoError(t, err)
+
+		_, err = reader.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("web/index.html"),
+			Body:   bytes.NewReader([]byte("defaced")),
+		})
+		assertAccessDenied(t, err)
+
+		_, err = reader.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("web/index.html"),
+		})
+		assertAccessDenied(t, err)
+
+		_, err = reader.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(testutil.RandomBucketName())})
+		assertAccessDenied(t, err)
+	})
+
+	t.Run("PrefixScopedKey", func(t *testing.T) {
+		_, err := writer.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("db/dump.sql"),
+			Body:   bytes.NewReader([]byte("dump")),
+		})
+		require.NoError(t, err)
+
+		_, err = writer.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("web/index.html"),
+			Body:   bytes.NewReader([]byte("defaced")),
+		})
+		assertAccessDenied(t, err)
+
+		// Reading is not allowed, not even under the prefix
+		_, err = writer.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("db/dump.sql"),
+		})
+		assertAccessDenied(t, err)
+
+		// Listing is only allowed under the prefix
+		_, err = writer.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String(bucketName),
+			Prefix: aws.String("db/"),
+		})
+		require.NoError(t, err)
+		_, err = writer.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucketName)})
+		assertAccessDenied(t, err)
+
+		// Copies need read access to their source
+		_, err = writer.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(bucketName),
+			Key:        aws.String("db/index.html"),
+			CopySource: aws.String(bucketName + "/web/index.html"),
+		})
+		assertAccessDenied(t, err)
+	})
+
+	t.Run("DeleteObjectsReportsDeniedKeys", func(t *testing.T) {
+		output, err := writer.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &types.Delete{Objects: []types.ObjectIdentifier{
+				{Key: aws.String("db/dump.sql")},
+				{Key: aws.String("web/index.html")},
+			}},
+		})
+		require.NoError(t, err)
+		require.Len(t, output.Deleted, 1)
+		assert.Equal(t, "db/dump.sql", aws.ToString(output.Deleted[0].Key))
+		require.Len(t, output.Errors, 1)
+		assert.Equal(t, "web/index.html", aws.ToString(output.Errors[0].Key))
+		assert.Equal(t, "AccessDenied", aws.ToString(output.Errors[0].Code))
+	})
+
+	t.Run("BucketPolicyAllowsRestrictedKey", func(t *testing.T) {
+		_, err := admin.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
+			Bucket: aws.String(bucketName),
+			Policy: aws.String(`{
+				"Version": "2012-10-17",
+				"Statement": [{"Effect": "Allow", "Principal": {"AWS": "db-writer"}, "Action": "s3:GetObject",
+					"Resource": "arn:aws:s3:::` + bucketName + `/web/*"}]
+			}`),
+		})
+		require.NoError(t, err)
+		defer admin.DeleteBucketPolicy(ctx, &s3.DeleteBucketPolicyInput{Bucket: aws.String(bucketName)})
+
+		_, err = writer.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("web/index.html"),
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("BucketPolicyDenyAppliesToAllKeys", func(t *testing.T) {
+		_, err := admin.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
+			Bucket: aws.String(bucketName),
+			Policy: aws.String(`{
+				"Version": "2012-10-17",
+				"Statement": [{"Effect": "Deny", "Principal": "*", "Action": "s3:DeleteObject",
+					"Resource": "arn:aws:s3:::` + bucketName + `/web/*"}]
+			}`),
+		})
+		require.NoError(t, err)
+		defer admin.DeleteBucketPolicy(ctx, &s3.DeleteBucketPolicyInput{Bucket: aws.String(bucketName)})
+
+		for _, client := range []*s3.Client{admin, unrestricted} {
+			_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(bucketName),
+				Key:    aws.String("web/index.html"),
+			})
+			assertAccessDenied(t, err)
+		}
+
+		// Keys without identity policies still have full access otherwise
+		_, err = unrestricted.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("web/about.html"),
+			Body:   bytes.NewReader([]byte("about")),
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("AdminNeedsExplicitAllow", func(t *testing.T) {
+		for accessKey, status := range map[string]int{
+			ts.AccessKey:   http.StatusOK,
+			"operator":     http.StatusOK,
+			"unrestricted": http.StatusForbidden,
+			"db-writer":    http.StatusForbidden,
+		} {
+			creds := aws.Credentials{AccessKeyID: accessKey, SecretAccessKey: accessKey + "-secret"}
+			if accessKey == ts.AccessKey {
+				creds = rootCredentials(ts)
+			}
+			resp := signedAdminRequest(t, ts, http.MethodGet, "/_jog/admin/mode", "", creds)
+			resp.Body.Close()
+			assert.Equal(t, status, resp.StatusCode, accessKey)
+		}
+	})
+
+	t.Run("ForceDeleteNeedsDeleteObject", func(t *testing.T) {
+		bucket := testutil.RandomBucketName()
+		_, err := admin.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+		require.NoError(t, err)
+		_, err = admin.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String("data.bin"),
+			Body:   bytes.NewReader([]byte("data")),
+		})
+		require.NoError(t, err)
+
+		// Emptying the bucket deletes its objects
+		resp := signedAdminRequest(t, ts, http.MethodDelete, "/"+bucket+"?force=true", "",
+			aws.Credentials{AccessKeyID: "bucket-remover", SecretAccessKey: "bucket-remover-secret"})
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		_, err = admin.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String("data.bin")})
+		require.NoError(t, err)
+
+		resp = signedAdminRequest(t, ts, http.MethodDelete, "/"+bucket+"?force=true", "",
+			aws.Credentials{AccessKeyID: "bucket-cleaner", SecretAccessKey: "bucket-cleaner-secret"})
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("HeadPartUploadIsNotGetObject", func(t *testing.T) {
+		upload, err := admin.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("web/large.bin"),
+		})
+		require.NoError(t, err)
+		defer admin.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucketName),
+			Key:      aws.String("web/large.bin"),
+			UploadId: upload.UploadId,
+		})
+
+		// Reading objects does not allow reading the parts of uploads
+		path := "/" + bucketName + "/web/large.bin?partNumber=1&uploadId=" + aws.ToString(upload.UploadId)
+		resp := signedAdminRequest(t, ts, http.MethodHead, path, "",
+			aws.Credentials{AccessKeyID: "backup-reader", SecretAccessKey: "backup-reader-secret"})
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestPutBucketPolicyValidatesDocument(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
+		Bucket: aws.String(bucketName),
+		Policy: aws.String(`{
+			"Version": "2012-10-17",
+			"Statement": [{"Effect": "Allow", "Principal": "*", "Actions": "s3:GetObject", "Resource": "*"}]
+		}`),
+	})
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "MalformedPolicy", apiErr.ErrorCode())
+	}
+}
@@ -0,0 +1,115 @@
+package s3compat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchJob is the JSON status of a batch job.
+type batchJob struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	TotalTasks     int64  `json:"totalTasks"`
+	SucceededTasks int64  `json:"succeededTasks"`
+	FailedTasks    int64  `json:"failedTasks"`
+}
+
+// adminRequest sends a request to the admin API and returns the status and body.
+func adminRequest(t *testing.T, ts *testutil.TestServer, method, path, body string) (int, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.Endpoint+"/_jog/admin/"+path, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+func TestBatchJobs(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	for _, key := range []string{"a.txt", "b.txt"} {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+			Body:   strings.NewReader("hello"),
+		})
+		require.NoError(t, err)
+	}
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("manifest.csv"),
+		Body:   strings.NewReader(bucketName + ",a.txt\n" + bucketName + ",b.txt\n"),
+	})
+	require.NoError(t, err)
+
+	t.Run("SubmitAndTrack", func(t *testing.T) {
+		spec := `{"operation":"tag","manifest":{"bucket":"` + bucketName + `","key":"manifest.csv"},` +
+			`"tagging":{"tags":[{"key":"batch","value":"yes"}]}}`
+		status, body := adminRequest(t, ts, http.MethodPost, "jobs", spec)
+		require.Equal(t, http.StatusCreated, status, string(body))
+
+		var job batchJob
+		require.NoError(t, json.Unmarshal(body, &job))
+		assert.Equal(t, int64(2), job.TotalTasks)
+
+		require.Eventually(t, func() bool {
+			status, body := adminRequest(t, ts, http.MethodGet, "jobs/"+job.ID, "")
+			require.Equal(t, http.StatusOK, status, string(body))
+			require.NoError(t, json.Unmarshal(body, &job))
+			return job.Status != "Active"
+		}, 10*time.Second, 20*time.Millisecond)
+		assert.Equal(t, "Complete", job.Status)
+		assert.Equal(t, int64(2), job.SucceededTasks)
+
+		tagging, err := client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("b.txt"),
+		})
+		require.NoError(t, err)
+		require.Len(t, tagging.TagSet, 1)
+		assert.Equal(t, "batch", aws.ToString(tagging.TagSet[0].Key))
+
+		status, body = adminRequest(t, ts, http.MethodGet, "jobs", "")
+		require.Equal(t, http.StatusOK, status)
+		assert.Contains(t, string(body), job.ID)
+
+		status, _ = adminRequest(t, ts, http.MethodDelete, "jobs/"+job.ID, "")
+		assert.Equal(t, http.StatusConflict, status)
+	})
+
+	t.Run("InvalidSpec", func(t *testing.T) {
+		status, body := adminRequest(t, ts, http.MethodPost, "jobs",
+			`{"operation":"copy","manifest":{"bucket":"`+bucketName+`","key":"manifest.csv"}}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Contains(t, string(body), "copy requires a target bucket")
+	})
+
+	t.Run("UnknownJob", func(t *testing.T) {
+		status, body := adminRequest(t, ts, http.MethodGet, "jobs/does-not-exist", "")
+		assert.Equal(t, http.StatusNotFound, status)
+		assert.Contains(t, string(body), "NoSuchJob")
+	})
+}
@@ -0,0 +1,202 @@
+package s3compat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessKey is the JSON body of an access key of the admin API.
+type accessKey struct {
+	AccessKey          string     `json:"accessKey"`
+	SecretKey          string     `json:"secretKey"`
+	PreviousExpiration *time.Time `json:"previousExpiration"`
+	PreviousLastUsed   *time.Time `json:"previousLastUsed"`
+}
+
+// accessKeyRequest sends a signed request to an admin access key endpoint
+// and decodes the access key it returns.
+func accessKeyRequest(t *testing.T, ts *testutil.TestServer, method, path, body string, creds aws.Credentials, status int) accessKey {
+	t.Helper()
+	resp := signedAdminRequest(t, ts, method, path, body, creds)
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	require.Equal(t, status, resp.StatusCode, string(data))
+	var key accessKey
+	if status == http.StatusOK {
+		require.NoError(t, json.Unmarshal(data, &key))
+	}
+	return key
+}
+
+func TestAccessKeyRotation(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		EnableAuth:   true,
+		PresignCache: config.PresignCacheConfig{Size: 10, TTL: time.Minute},
+		Policies: []config.PolicyConfig{{Name: "all", Document: `{
+			"Statement": [{"Effect": "Allow", "Action": ["s3:*", "jog:Admin"], "Resource": "*"}]
+		}`}},
+		Users: []config.UserConfig{
+			{AccessKey: "app", SecretKey: "app-secret-original", Policies: []string{"all"}},
+			{AccessKey: "batch", SecretKey: "batch-secret-original", Policies: []string{"all"}},
+			{AccessKey: "ci", SecretKey: "ci-secret-original", Policies: []string{"all"}},
+		},
+	})
+	defer ts.Cleanup()
+
+	ctx := context.Background()
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+	_, err := ts.S3Client(t).PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("report.csv"),
+		Body:   bytes.NewReader([]byte("report")),
+	})
+	require.NoError(t, err)
+
+	list := func(client *s3.Client) error {
+		_, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucketName)})
+		return err
+	}
+
+	t.Run("PreviousSecretDuringGracePeriod", func(t *testing.T) {
+		oldCreds := aws.Credentials{AccessKeyID: "app", SecretAccessKey: "app-secret-original"}
+		oldClient := ts.S3ClientWithCredentials(t, "app", "app-secret-original")
+
+		// Service accounts derive their secret key from the parent
+		resp := signedAdminRequest(t, ts, http.MethodPost, "/_jog/admin/service-accounts",
+			`{"bucket": "`+bucketName+`", "actions": ["s3:ListBucket"]}`, oldCreds)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var account serviceAccount
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&account))
+		accountClient := ts.S3ClientWithCredentials(t, account.AccessKey, account.SecretKey)
+
+		presigned, err := s3.NewPresignClient(oldClient).PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("report.csv"),
+		})
+		require.NoError(t, err)
+
+		rotated := accessKeyRequest(t, ts, http.MethodPost, "/_jog/admin/access-keys/app/rotate",
+			`{"previousExpiration": "`+time.Now().Add(time.Hour).UTC().Format(time.RFC3339)+`"}`, oldCreds, http.StatusOK)
+		require.NotEmpty(t, rotated.SecretKey)
+		require.NotNil(t, rotated.PreviousExpiration)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *rotated.PreviousExpiration, time.Minute)
+		newCreds := aws.Credentials{AccessKeyID: "app", SecretAccessKey: rotated.SecretKey}
+
+		// Both secret keys are accepted until the previous one expires
+		require.NoError(t, list(ts.S3ClientWithCredentials(t, "app", rotated.SecretKey)))
+		require.NoError(t, list(oldClient))
+		require.NoError(t, list(accountClient))
+		for range 2 {
+			presignedResp, err := http.Get(presigned.URL)
+			require.NoError(t, err)
+			presignedResp.Body.Close()
+			assert.Equal(t, http.StatusOK, presignedResp.StatusCode)
+		}
+
+		status := accessKeyRequest(t, ts, http.MethodGet, "/_jog/admin/access-keys/app", "", newCreds, http.StatusOK)
+		assert.Empty(t, status.SecretKey)
+		require.NotNil(t, status.PreviousLastUsed)
+		assert.WithinDuration(t, time.Now(), *status.PreviousLastUsed, time.Minute)
+
+		// Rotating again would drop the previous secret key clients may use
+		accessKeyRequest(t, ts, http.MethodPost, "/_jog/admin/access-keys/app/rotate", "", newCreds, http.StatusBadRequest)
+
+		accessKeyRequest(t, ts, http.MethodDelete, "/_jog/admin/access-keys/app/previous", "", newCreds, http.StatusNoContent)
+		assertErrorCode(t, list(oldClient), "SignatureDoesNotMatch")
+		assertErrorCode(t, list(accountClient), "InvalidAccessKeyId")
+		require.NoError(t, list(ts.S3ClientWithCredentials(t, "app", rotated.SecretKey)))
+		// The cached presigned URL is checked against the current secret keys
+		presignedResp, err := http.Get(presigned.URL)
+		require.NoError(t, err)
+		presignedResp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, presignedResp.StatusCode)
+
+		status = accessKeyRequest(t, ts, http.MethodGet, "/_jog/admin/access-keys/app", "", newCreds, http.StatusOK)
+		assert.Nil(t, status.PreviousExpiration)
+		assert.Nil(t, status.PreviousLastUsed)
+
+		// Revoking is idempotent
+		accessKeyRequest(t, ts, http.MethodDelete, "/_jog/admin/access-keys/app/previous", "", newCreds, http.StatusNoContent)
+	})
+
+	t.Run("PreviousSecretExpires", func(t *testing.T) {
+		oldClient := ts.S3ClientWithCredentials(t, "batch", "batch-secret-original")
+		expiration := time.Now().Add(2 * time.Second).UTC()
+		rotated := accessKeyRequest(t, ts, http.MethodPost, "/_jog/admin/access-keys/batch/rotate",
+			`{"secretKey": "batch-secret-rotated", "previousExpiration": "`+expiration.Format(time.RFC3339Nano)+`"}`,
+			rootCredentials(ts), http.StatusOK)
+		assert.Equal(t, "batch-secret-rotated", rotated.SecretKey)
+		require.NoError(t, list(oldClient))
+
+		time.Sleep(time.Until(expiration))
+		assertErrorCode(t, list(oldClient), "SignatureDoesNotMatch")
+		require.NoError(t, list(ts.S3ClientWithCredentials(t, "batch", "batch-secret-rotated")))
+	})
+
+	t.Run("InvalidRotations", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"short secret key":   `{"secretKey": "short"}`,
+			"past expiration":    `{"previousExpiration": "2020-01-01T00:00:00Z"}`,
+			"same secret key":    `{"secretKey": "` + ts.SecretKey + `"}`,
+			"malformed document": `{"secretKey":`,
+			"distant expiration": `{"previousExpiration": "` + time.Now().AddDate(0, 2, 0).UTC().Format(time.RFC3339) + `"}`,
+		} {
+			t.Run(name, func(t *testing.T) {
+				accessKeyRequest(t, ts, http.MethodPost, "/_jog/admin/access-keys/"+ts.AccessKey+"/rotate", body,
+					rootCredentials(ts), http.StatusBadRequest)
+			})
+		}
+	})
+
+	t.Run("OversizedRotation", func(t *testing.T) {
+		accessKeyRequest(t, ts, http.MethodPost, "/_jog/admin/access-keys/"+ts.AccessKey+"/rotate",
+			`{"secretKey": "`+strings.Repeat("k", 8<<10)+`"}`, rootCredentials(ts), http.StatusBadRequest)
+	})
+
+	t.Run("ForgedRequestsDoNotUsePreviousSecret", func(t *testing.T) {
+		ciCreds := aws.Credentials{AccessKeyID: "ci", SecretAccessKey: "ci-secret-original"}
+		resp := signedAdminRequest(t, ts, http.MethodPost, "/_jog/admin/service-accounts",
+			`{"bucket": "`+bucketName+`", "actions": ["s3:ListBucket"]}`, ciCreds)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var account serviceAccount
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&account))
+
+		rotated := accessKeyRequest(t, ts, http.MethodPost, "/_jog/admin/access-keys/ci/rotate", "", ciCreds, http.StatusOK)
+		newCreds := aws.Credentials{AccessKeyID: "ci", SecretAccessKey: rotated.SecretKey}
+
+		// The service account derives its secret key from the previous secret
+		// key, but requests not signed with it do not count as its use
+		assertErrorCode(t, list(ts.S3ClientWithCredentials(t, account.AccessKey, "forged-secret")), "SignatureDoesNotMatch")
+		status := accessKeyRequest(t, ts, http.MethodGet, "/_jog/admin/access-keys/ci", "", newCreds, http.StatusOK)
+		assert.Nil(t, status.PreviousLastUsed)
+
+		require.NoError(t, list(ts.S3ClientWithCredentials(t, account.AccessKey, account.SecretKey)))
+		status = accessKeyRequest(t, ts, http.MethodGet, "/_jog/admin/access-keys/ci", "", newCreds, http.StatusOK)
+		assert.NotNil(t, status.PreviousLastUsed)
+	})
+
+	t.Run("OtherAccessKeys", func(t *testing.T) {
+		// Access keys other than the default namespace only manage their own
+		batchCreds := aws.Credentials{AccessKeyID: "batch", SecretAccessKey: "batch-secret-rotated"}
+		accessKeyRequest(t, ts, http.MethodPost, "/_jog/admin/access-keys/"+ts.AccessKey+"/rotate", "", batchCreds, http.StatusNotFound)
+		accessKeyRequest(t, ts, http.MethodGet, "/_jog/admin/access-keys/app", "", batchCreds, http.StatusNotFound)
+		accessKeyRequest(t, ts, http.MethodGet, "/_jog/admin/access-keys/unknown", "", rootCredentials(ts), http.StatusNotFound)
+	})
+}
@@ -352,3 +352,113 @@ func TestPutBucketLifecycleConfigurationWithAbortIncompleteMultipartUpload(t *te
 	assert.NotNil(t, rule.AbortIncompleteMultipartUpload)
 	assert.Equal(t, int32(7), *rule.AbortIncompleteMultipartUpload.DaysAfterInitiation)
 }
+
+func TestPutBucketLifecycleConfigurationValidation(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	expire := func(id string, days int32) types.LifecycleRule {
+		return types.LifecycleRule{
+			ID:         aws.String(id),
+			Status:     types.ExpirationStatusEnabled,
+			Filter:     &types.LifecycleRuleFilter{Prefix: aws.String("logs/")},
+			Expiration: &types.LifecycleExpiration{Days: aws.Int32(days)},
+		}
+	}
+	tests := []struct {
+		name  string
+		rules []types.LifecycleRule
+		code  string
+	}{
+		{"DuplicateID", []types.LifecycleRule{expire("rule", 30), expire("rule", 60)}, "InvalidArgument"},
+		{"NonPositiveDays", []types.LifecycleRule{expire("rule", 0)}, "InvalidArgument"},
+		{"NoFilter", []types.LifecycleRule{{
+			ID:         aws.String("rule"),
+			Status:     types.ExpirationStatusEnabled,
+			Expiration: &types.LifecycleExpiration{Days: aws.Int32(30)},
+		}}, "MalformedXML"},
+		{"NoAction", []types.LifecycleRule{{
+			ID:     aws.String("rule"),
+			Status: types.ExpirationStatusEnabled,
+			Filter: &types.LifecycleRuleFilter{Prefix: aws.String("")},
+		}}, "InvalidRequest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+				Bucket:                 aws.String(bucketName),
+				LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: tt.rules},
+			})
+			require.Error(t, err)
+			var apiErr smithy.APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, tt.code, apiErr.ErrorCode())
+		})
+	}
+
+	// Rejected configurations are not stored
+	_, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucketName),
+	})
+	require.Error(t, err)
+}
+
+func TestPutBucketLifecycleConfigurationWithAndFilter(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucketName),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{
+			Rules: []types.LifecycleRule{
+				{
+					ID:     aws.String("expire-tagged-logs"),
+					Status: types.ExpirationStatusEnabled,
+					Filter: &types.LifecycleRuleFilter{
+						And: &types.LifecycleRuleAndOperator{
+							Prefix: aws.String("logs/"),
+							Tags: []types.Tag{
+								{Key: aws.String("team"), Value: aws.String("web")},
+								{Key: aws.String("env"), Value: aws.String("dev")},
+							},
+							ObjectSizeGreaterThan: aws.Int64(1024),
+						},
+					},
+					Expiration: &types.LifecycleExpiration{
+						Days: aws.Int32(7),
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	result, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucketName),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, result.Rules, 1)
+	require.NotNil(t, result.Rules[0].Filter)
+	and := result.Rules[0].Filter.And
+	require.NotNil(t, and)
+	assert.Equal(t, "logs/", aws.ToString(and.Prefix))
+	require.Len(t, and.Tags, 2)
+	assert.Equal(t, "team", aws.ToString(and.Tags[0].Key))
+	assert.Equal(t, "dev", aws.ToString(and.Tags[1].Value))
+	assert.Equal(t, int64(1024), aws.ToInt64(and.ObjectSizeGreaterThan))
+}
@@ -0,0 +1,96 @@
+package s3compat
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListBucketsFilteredByOwner(t *testing.T) {
+	ts := testutil.NewTestServerWithTenants(t,
+		config.TenantConfig{Name: "team", AccessKey: "alice", SecretKey: "alice-secret"},
+		config.TenantConfig{Name: "team", AccessKey: "bob", SecretKey: "bob-secret"},
+	)
+	defer ts.Cleanup()
+
+	ctx := context.Background()
+	alice := ts.S3ClientWithCredentials(t, "alice", "alice-secret")
+	bob := ts.S3ClientWithCredentials(t, "bob", "bob-secret")
+
+	aliceBucket := testutil.RandomBucketName()
+	bobBucket := testutil.RandomBucketName()
+	_, err := alice.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(aliceBucket)})
+	require.NoError(t, err)
+	_, err = bob.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bobBucket)})
+	require.NoError(t, err)
+
+	bucketNames := func(client *s3.Client) []string {
+		output, err := client.ListBuckets(ctx, &s3.ListBucketsInput{})
+		require.NoError(t, err)
+		var names []string
+		for _, b := range output.Buckets {
+			names = append(names, aws.ToString(b.Name))
+		}
+		return names
+	}
+
+	t.Run("OnlyOwnBuckets", func(t *testing.T) {
+		assert.Equal(t, []string{aliceBucket}, bucketNames(alice))
+		assert.Equal(t, []string{bobBucket}, bucketNames(bob))
+	})
+
+	t.Run("OwnerInResponse", func(t *testing.T) {
+		output, err := alice.ListBuckets(ctx, &s3.ListBucketsInput{})
+		require.NoError(t, err)
+		require.NotNil(t, output.Owner)
+		assert.Equal(t, "alice", aws.ToString(output.Owner.ID))
+	})
+
+	t.Run("GrantedBuckets", func(t *testing.T) {
+		_, err := alice.PutBucketAcl(ctx, &s3.PutBucketAclInput{
+			Bucket: aws.String(aliceBucket),
+			AccessControlPolicy: &types.AccessControlPolicy{
+				Owner: &types.Owner{ID: aws.String("alice")},
+				Grants: []types.Grant{
+					{
+						Grantee:    &types.Grantee{Type: types.TypeCanonicalUser, ID: aws.String("alice")},
+						Permission: types.PermissionFullControl,
+					},
+					{
+						Grantee:    &types.Grantee{Type: types.TypeCanonicalUser, ID: aws.String("bob")},
+						Permission: types.PermissionRead,
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, []string{aliceBucket, bobBucket}, bucketNames(bob))
+		assert.Equal(t, []string{aliceBucket}, bucketNames(alice))
+	})
+
+	t.Run("RecreateBucket", func(t *testing.T) {
+		errorCode := func(err error) string {
+			var apiErr smithy.APIError
+			require.True(t, errors.As(err, &apiErr))
+			return apiErr.ErrorCode()
+		}
+
+		_, err := alice.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(aliceBucket)})
+		require.Error(t, err)
+		assert.Equal(t, "BucketAlreadyOwnedByYou", errorCode(err))
+
+		_, err = bob.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(aliceBucket)})
+		require.Error(t, err)
+		assert.Equal(t, "BucketAlreadyExists", errorCode(err))
+	})
+}
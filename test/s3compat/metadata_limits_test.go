@@ -0,0 +1,110 @@
+package s3compat
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataTooLarge(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	// 2KB of names and values are accepted
+	fits := map[string]string{"name": strings.Repeat("x", 2048-len("name"))}
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String("fits"),
+		Body:     strings.NewReader("data"),
+		Metadata: fits,
+	})
+	require.NoError(t, err)
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("fits"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, fits["name"], head.Metadata["name"])
+
+	tooLarge := map[string]string{"name": strings.Repeat("x", 2048)}
+	assertMetadataTooLarge := func(t *testing.T, err error) {
+		t.Helper()
+		require.Error(t, err)
+		var apiErr smithy.APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, "MetadataTooLarge", apiErr.ErrorCode())
+		}
+	}
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String("too-large"),
+		Body:     strings.NewReader("data"),
+		Metadata: tooLarge,
+	})
+	assertMetadataTooLarge(t, err)
+
+	_, err = client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String("too-large"),
+		Metadata: tooLarge,
+	})
+	assertMetadataTooLarge(t, err)
+
+	_, err = client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(bucketName),
+		Key:               aws.String("too-large"),
+		CopySource:        aws.String(bucketName + "/fits"),
+		MetadataDirective: "REPLACE",
+		Metadata:          tooLarge,
+	})
+	assertMetadataTooLarge(t, err)
+
+	// Nothing was stored
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("too-large"),
+	})
+	require.Error(t, err)
+}
+
+func TestRequestHeaderCountLimit(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{MaxHeaderCount: 50})
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	put := func(headers int) *http.Response {
+		req, err := http.NewRequest(http.MethodPut, ts.Endpoint+"/"+bucketName+"/object", strings.NewReader("data"))
+		require.NoError(t, err)
+		for i := range headers {
+			req.Header.Set("X-Amz-Meta-H"+strconv.Itoa(i), "v")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	assert.Equal(t, http.StatusOK, put(10).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, put(60).StatusCode)
+}
@@ -0,0 +1,130 @@
+package s3compat
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mfaSecret is the TOTP secret of the MFA device of the tests, base32 encoded.
+const mfaSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// mfaHeader returns an x-amz-mfa header value of the test device with the
+// token of the time step steps after the current one. Tokens are accepted
+// once, so each request uses another step.
+func mfaHeader(steps int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(time.Now().Unix()/30+steps))
+	mac := hmac.New(sha1.New, []byte("12345678901234567890"))
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("mfa-device %06d", code%1000000)
+}
+
+func TestMfaDelete(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		MFADevices: map[string]string{"mfa-device": mfaSecret},
+	})
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	// Enabling MFA delete needs MFA authentication itself
+	versioning := &types.VersioningConfiguration{
+		Status:    types.BucketVersioningStatusEnabled,
+		MFADelete: types.MFADeleteEnabled,
+	}
+	_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucketName),
+		VersioningConfiguration: versioning,
+	})
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "AccessDenied", apiErr.ErrorCode())
+	}
+	_, err = client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucketName),
+		VersioningConfiguration: versioning,
+		MFA:                     aws.String(mfaHeader(0)),
+	})
+	require.NoError(t, err)
+
+	get, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(bucketName)})
+	require.NoError(t, err)
+	assert.Equal(t, types.BucketVersioningStatusEnabled, get.Status)
+	assert.Equal(t, types.MFADeleteStatusEnabled, get.MFADelete)
+
+	put, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("ledger.csv"),
+		Body:   strings.NewReader("v1"),
+	})
+	require.NoError(t, err)
+
+	// Delete markers can be created without MFA
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("ledger.csv"),
+	})
+	require.NoError(t, err)
+
+	// Suspending versioning and deleting versions need MFA
+	_, err = client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusSuspended,
+		},
+	})
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "AccessDenied", apiErr.ErrorCode())
+	}
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String("ledger.csv"),
+		VersionId: put.VersionId,
+		MFA:       aws.String("mfa-device 000000"),
+	})
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "AccessDenied", apiErr.ErrorCode())
+	}
+	_, err = client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String("ledger.csv"),
+		VersionId: put.VersionId,
+	})
+	require.NoError(t, err)
+
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String("ledger.csv"),
+		VersionId: put.VersionId,
+		MFA:       aws.String(mfaHeader(1)),
+	})
+	require.NoError(t, err)
+	_, err = client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String("ledger.csv"),
+		VersionId: put.VersionId,
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package s3compat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setServerMode changes the server mode through the admin API.
+func setServerMode(t *testing.T, ts *testutil.TestServer, mode string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPut, ts.Endpoint+"/_jog/admin/mode", strings.NewReader(`{"mode":"`+mode+`"}`))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Contains(t, string(body), `"mode":"`+mode+`"`)
+}
+
+// putRawObject uploads an object with raw HTTP so SDK retries do not hide the response status.
+func putRawObject(t *testing.T, ts *testutil.TestServer, bucket, key, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPut, ts.Endpoint+"/"+bucket+"/"+key, strings.NewReader(body))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestServerModes(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer func() {
+		setServerMode(t, ts, "normal")
+		cleanup()
+	}()
+
+	resp := putRawObject(t, ts, bucketName, "existing.txt", "hello")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	t.Run("ReadOnlyRejectsWrites", func(t *testing.T) {
+		setServerMode(t, ts, "read-only")
+
+		resp := putRawObject(t, ts, bucketName, "new.txt", "data")
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.Contains(t, string(body), "<Code>AccessDenied</Code>")
+
+		// Reads are still served
+		getResp, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("existing.txt"),
+		})
+		require.NoError(t, err)
+		data, _ := io.ReadAll(getResp.Body)
+		getResp.Body.Close()
+		assert.Equal(t, "hello", string(data))
+
+		_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String(bucketName),
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("MaintenanceRejectsWritesWithRetry", func(t *testing.T) {
+		setServerMode(t, ts, "maintenance")
+
+		resp := putRawObject(t, ts, bucketName, "new.txt", "data")
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Contains(t, string(body), "<Code>ServiceUnavailable</Code>")
+		assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+		_, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("existing.txt"),
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("NormalAcceptsWrites", func(t *testing.T) {
+		setServerMode(t, ts, "normal")
+
+		resp := putRawObject(t, ts, bucketName, "new.txt", "data")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("InvalidMode", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, ts.Endpoint+"/_jog/admin/mode", strings.NewReader(`{"mode":"sleepy"}`))
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
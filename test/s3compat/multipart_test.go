@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"strings"
 	"testing"
@@ -991,6 +994,88 @@ func TestCompleteMultipartUploadPartOutOfOrder(t *testing.T) {
 	})
 }
 
+func TestCompleteMultipartUploadPartList(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := testutil.RandomObjectKey()
+
+	createResult, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+
+	var parts []types.CompletedPart
+	for i := int32(1); i <= 2; i++ {
+		partResult, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(bucketName),
+			Key:        aws.String(key),
+			UploadId:   createResult.UploadId,
+			PartNumber: aws.Int32(i),
+			Body:       bytes.NewReader([]byte(fmt.Sprintf("part %d", i))),
+		})
+		require.NoError(t, err)
+		parts = append(parts, types.CompletedPart{PartNumber: aws.Int32(i), ETag: partResult.ETag})
+	}
+
+	tooMany := make([]types.CompletedPart, 10001)
+	for i := range tooMany {
+		tooMany[i] = types.CompletedPart{PartNumber: aws.Int32(int32(i + 1)), ETag: parts[0].ETag}
+	}
+
+	tests := []struct {
+		name  string
+		parts []types.CompletedPart
+		code  string
+	}{
+		{"duplicate part", []types.CompletedPart{parts[0], parts[0]}, "InvalidPartOrder"},
+		{"part number out of range", []types.CompletedPart{{PartNumber: aws.Int32(10001), ETag: parts[0].ETag}}, "InvalidArgument"},
+		{"too many parts", tooMany, "InvalidArgument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+				Bucket:          aws.String(bucketName),
+				Key:             aws.String(key),
+				UploadId:        createResult.UploadId,
+				MultipartUpload: &types.CompletedMultipartUpload{Parts: tt.parts},
+			})
+			require.Error(t, err)
+			var apiErr smithy.APIError
+			if assert.ErrorAs(t, err, &apiErr) {
+				assert.Equal(t, tt.code, apiErr.ErrorCode())
+			}
+		})
+	}
+
+	// Parts that are not listed are dropped
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(key),
+		UploadId:        createResult.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts[1:]},
+	})
+	require.NoError(t, err)
+
+	getResult, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	defer getResult.Body.Close()
+	body, err := io.ReadAll(getResult.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "part 2", string(body))
+}
+
 func TestUploadPartCopy(t *testing.T) {
 	ts := testutil.NewTestServer(t)
 	defer ts.Cleanup()
@@ -1336,3 +1421,411 @@ func TestListMultipartUploadsPrefix(t *testing.T) {
 		UploadId: upload3.UploadId,
 	})
 }
+
+func TestListMultipartUploadsDelimiter(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	for _, key := range []string{"a/1", "a/2", "b/c/1", "top"} {
+		_, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		require.NoError(t, err)
+	}
+
+	result, err := client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{
+		Bucket:    aws.String(bucketName),
+		Delimiter: aws.String("/"),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, result.Uploads, 1)
+	upload := result.Uploads[0]
+	assert.Equal(t, "top", aws.ToString(upload.Key))
+	assert.Equal(t, types.StorageClassStandard, upload.StorageClass)
+	require.NotNil(t, upload.Owner)
+	assert.NotEmpty(t, aws.ToString(upload.Owner.ID))
+	require.NotNil(t, upload.Initiator)
+	assert.Equal(t, aws.ToString(upload.Owner.ID), aws.ToString(upload.Initiator.ID))
+
+	var prefixes []string
+	for _, cp := range result.CommonPrefixes {
+		prefixes = append(prefixes, aws.ToString(cp.Prefix))
+	}
+	assert.Equal(t, []string{"a/", "b/"}, prefixes)
+	assert.Equal(t, "/", aws.ToString(result.Delimiter))
+
+	// Common prefixes count towards max-uploads and are skipped when paging
+	var pages [][]string
+	input := &s3.ListMultipartUploadsInput{
+		Bucket:     aws.String(bucketName),
+		Delimiter:  aws.String("/"),
+		MaxUploads: aws.Int32(1),
+	}
+	for {
+		page, err := client.ListMultipartUploads(ctx, input)
+		require.NoError(t, err)
+
+		var entries []string
+		for _, cp := range page.CommonPrefixes {
+			entries = append(entries, aws.ToString(cp.Prefix))
+		}
+		for _, u := range page.Uploads {
+			entries = append(entries, aws.ToString(u.Key))
+		}
+		pages = append(pages, entries)
+
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		require.Less(t, len(pages), 5, "pagination does not terminate")
+		input.KeyMarker = page.NextKeyMarker
+		input.UploadIdMarker = page.NextUploadIdMarker
+	}
+	assert.Equal(t, [][]string{{"a/"}, {"b/"}, {"top"}}, pages)
+
+	// Nested prefixes
+	result, err = client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{
+		Bucket:    aws.String(bucketName),
+		Prefix:    aws.String("b/"),
+		Delimiter: aws.String("/"),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, result.Uploads)
+	require.Len(t, result.CommonPrefixes, 1)
+	assert.Equal(t, "b/c/", aws.ToString(result.CommonPrefixes[0].Prefix))
+}
+
+func TestListPartsOwner(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("owned"),
+	})
+	require.NoError(t, err)
+
+	result, err := client.ListParts(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String("owned"),
+		UploadId: upload.UploadId,
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, result.Owner)
+	require.NotNil(t, result.Initiator)
+	assert.NotEmpty(t, aws.ToString(result.Owner.ID))
+	assert.Equal(t, aws.ToString(result.Owner.ID), aws.ToString(result.Initiator.ID))
+	assert.Equal(t, types.StorageClassStandard, result.StorageClass)
+}
+
+func TestGetObjectPartNumber(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := "parts.bin"
+	partData := []string{"first-part-", "second", "third-part-data"}
+
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+
+	var completed []types.CompletedPart
+	for i, data := range partData {
+		part, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(bucketName),
+			Key:        aws.String(key),
+			UploadId:   upload.UploadId,
+			PartNumber: aws.Int32(int32(i + 1)),
+			Body:       strings.NewReader(data),
+		})
+		require.NoError(t, err)
+		completed = append(completed, types.CompletedPart{ETag: part.ETag, PartNumber: aws.Int32(int32(i + 1))})
+	}
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(key),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	require.NoError(t, err)
+
+	t.Run("GetEachPart", func(t *testing.T) {
+		offset := 0
+		for i, data := range partData {
+			result, err := client.GetObject(ctx, &s3.GetObjectInput{
+				Bucket:     aws.String(bucketName),
+				Key:        aws.String(key),
+				PartNumber: aws.Int32(int32(i + 1)),
+			})
+			require.NoError(t, err)
+			body, err := io.ReadAll(result.Body)
+			result.Body.Close()
+			require.NoError(t, err)
+
+			assert.Equal(t, data, string(body))
+			assert.Equal(t, int32(len(partData)), aws.ToInt32(result.PartsCount))
+			expectedRange := fmt.Sprintf("bytes %d-%d/%d", offset, offset+len(data)-1, len(strings.Join(partData, "")))
+			assert.Equal(t, expectedRange, aws.ToString(result.ContentRange))
+			offset += len(data)
+		}
+	})
+
+	t.Run("HeadPart", func(t *testing.T) {
+		result, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:     aws.String(bucketName),
+			Key:        aws.String(key),
+			PartNumber: aws.Int32(2),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(partData[1])), aws.ToInt64(result.ContentLength))
+		assert.Equal(t, int32(len(partData)), aws.ToInt32(result.PartsCount))
+	})
+
+	t.Run("PartNumberOutOfRange", func(t *testing.T) {
+		_, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket:     aws.String(bucketName),
+			Key:        aws.String(key),
+			PartNumber: aws.Int32(4),
+		})
+		require.Error(t, err)
+		var apiErr smithy.APIError
+		if assert.ErrorAs(t, err, &apiErr) {
+			assert.Equal(t, "InvalidPartNumber", apiErr.ErrorCode())
+		}
+	})
+
+	t.Run("SinglePartObject", func(t *testing.T) {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+			Body:   strings.NewReader("replaced"),
+		})
+		require.NoError(t, err)
+
+		result, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket:     aws.String(bucketName),
+			Key:        aws.String(key),
+			PartNumber: aws.Int32(1),
+		})
+		require.NoError(t, err)
+		body, err := io.ReadAll(result.Body)
+		result.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, "replaced", string(body))
+		assert.Nil(t, result.PartsCount)
+
+		_, err = client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket:     aws.String(bucketName),
+			Key:        aws.String(key),
+			PartNumber: aws.Int32(2),
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestGetObjectAttributesObjectParts(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := "attributes.bin"
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+
+	var completed []types.CompletedPart
+	for i, data := range []string{"one", "two-two", "three"} {
+		part, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(bucketName),
+			Key:        aws.String(key),
+			UploadId:   upload.UploadId,
+			PartNumber: aws.Int32(int32(i + 1)),
+			Body:       strings.NewReader(data),
+		})
+		require.NoError(t, err)
+		completed = append(completed, types.CompletedPart{ETag: part.ETag, PartNumber: aws.Int32(int32(i + 1))})
+	}
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(key),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	require.NoError(t, err)
+
+	result, err := client.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+		Bucket:           aws.String(bucketName),
+		Key:              aws.String(key),
+		ObjectAttributes: []types.ObjectAttributes{types.ObjectAttributesObjectParts},
+		MaxParts:         aws.Int32(2),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result.ObjectParts)
+	assert.Equal(t, int32(3), aws.ToInt32(result.ObjectParts.TotalPartsCount))
+	assert.True(t, aws.ToBool(result.ObjectParts.IsTruncated))
+	assert.Equal(t, "2", aws.ToString(result.ObjectParts.NextPartNumberMarker))
+	require.Len(t, result.ObjectParts.Parts, 2)
+	assert.Equal(t, int32(1), aws.ToInt32(result.ObjectParts.Parts[0].PartNumber))
+	assert.Equal(t, int64(3), aws.ToInt64(result.ObjectParts.Parts[0].Size))
+	assert.Equal(t, int64(7), aws.ToInt64(result.ObjectParts.Parts[1].Size))
+
+	result, err = client.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+		Bucket:           aws.String(bucketName),
+		Key:              aws.String(key),
+		ObjectAttributes: []types.ObjectAttributes{types.ObjectAttributesObjectParts},
+		PartNumberMarker: aws.String("2"),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result.ObjectParts)
+	assert.False(t, aws.ToBool(result.ObjectParts.IsTruncated))
+	require.Len(t, result.ObjectParts.Parts, 1)
+	assert.Equal(t, int32(3), aws.ToInt32(result.ObjectParts.Parts[0].PartNumber))
+
+	// Objects uploaded in a single request have no parts section
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("single"),
+		Body:   strings.NewReader("data"),
+	})
+	require.NoError(t, err)
+	result, err = client.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+		Bucket:           aws.String(bucketName),
+		Key:              aws.String("single"),
+		ObjectAttributes: []types.ObjectAttributes{types.ObjectAttributesObjectParts, types.ObjectAttributesChecksum},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result.ObjectParts)
+	assert.Nil(t, result.Checksum)
+}
+
+func TestMultipartUploadChecksums(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := testutil.RandomObjectKey()
+	create, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String(bucketName),
+		Key:               aws.String(key),
+		ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.ChecksumAlgorithmCrc32, create.ChecksumAlgorithm)
+
+	// A part whose checksum does not match is rejected
+	_, err = client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(key),
+		UploadId:      create.UploadId,
+		PartNumber:    aws.Int32(1),
+		Body:          strings.NewReader("part one"),
+		ChecksumCRC32: aws.String("AAAAAA=="),
+	})
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "BadDigest", apiErr.ErrorCode())
+	}
+
+	var completed []types.CompletedPart
+	var sums []byte
+	for i, data := range []string{"part one", "part two"} {
+		part, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:            aws.String(bucketName),
+			Key:               aws.String(key),
+			UploadId:          create.UploadId,
+			PartNumber:        aws.Int32(int32(i + 1)),
+			Body:              strings.NewReader(data),
+			ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+		})
+		require.NoError(t, err)
+
+		sum := crc32.ChecksumIEEE([]byte(data))
+		raw := []byte{byte(sum >> 24), byte(sum >> 16), byte(sum >> 8), byte(sum)}
+		assert.Equal(t, base64.StdEncoding.EncodeToString(raw), aws.ToString(part.ChecksumCRC32))
+		sums = append(sums, raw...)
+		completed = append(completed, types.CompletedPart{
+			PartNumber:    aws.Int32(int32(i + 1)),
+			ETag:          part.ETag,
+			ChecksumCRC32: part.ChecksumCRC32,
+		})
+	}
+
+	parts, err := client.ListParts(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(key),
+		UploadId: create.UploadId,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.ChecksumAlgorithmCrc32, parts.ChecksumAlgorithm)
+	require.Len(t, parts.Parts, 2)
+	assert.Equal(t, aws.ToString(completed[1].ChecksumCRC32), aws.ToString(parts.Parts[1].ChecksumCRC32))
+
+	// The object checksum is the checksum of the part checksums
+	sum := crc32.ChecksumIEEE(sums)
+	composite := base64.StdEncoding.EncodeToString([]byte{byte(sum >> 24), byte(sum >> 16), byte(sum >> 8), byte(sum)}) + "-2"
+	complete, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(key),
+		UploadId:        create.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, composite, aws.ToString(complete.ChecksumCRC32))
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucketName),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, composite, aws.ToString(head.ChecksumCRC32))
+
+	attrs, err := client.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+		Bucket:           aws.String(bucketName),
+		Key:              aws.String(key),
+		ObjectAttributes: []types.ObjectAttributes{types.ObjectAttributesChecksum},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, attrs.Checksum)
+	assert.Equal(t, composite, aws.ToString(attrs.Checksum.ChecksumCRC32))
+}
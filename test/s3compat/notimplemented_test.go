@@ -0,0 +1,47 @@
+package s3compat
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnimplementedSubresources(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/" + bucketName + "/object?torrent"},
+		{http.MethodGet, "/" + bucketName + "?requestPayment"},
+		{http.MethodPut, "/" + bucketName + "?requestPayment"},
+		{http.MethodGet, "/" + bucketName + "?logging"},
+		{http.MethodPost, "/" + bucketName + "/object?restore"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.Endpoint+tt.path, nil)
+			require.NoError(t, err)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+			assert.Contains(t, string(body), "<Code>NotImplemented</Code>")
+		})
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"net/http"
 	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/kumasuke/jog/test/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -213,6 +217,127 @@ func TestHeadObject(t *testing.T) {
 	assert.Equal(t, *putResult.ETag, *headResult.ETag)
 }
 
+func TestHeadObjectRange(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := testutil.RandomObjectKey()
+	content := "0123456789ABCDEF"
+
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(content),
+	})
+	require.NoError(t, err)
+
+	// Full HEAD advertises range support
+	headResult, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "bytes", aws.ToString(headResult.AcceptRanges))
+	assert.Empty(t, responseHeader(headResult.ResultMetadata, "Content-Range"))
+
+	// HEAD with Range returns the metadata of the partial response
+	headResult, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Range:  aws.String("bytes=4-9"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), aws.ToInt64(headResult.ContentLength))
+	assert.Equal(t, "bytes 4-9/16", responseHeader(headResult.ResultMetadata, "Content-Range"))
+	assert.Equal(t, "bytes", aws.ToString(headResult.AcceptRanges))
+
+	getResult, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Range:  aws.String("bytes=-4"),
+	})
+	require.NoError(t, err)
+	defer getResult.Body.Close()
+	assert.Equal(t, "bytes 12-15/16", aws.ToString(getResult.ContentRange))
+	assert.Equal(t, "bytes", aws.ToString(getResult.AcceptRanges))
+}
+
+// responseHeader returns a header of the HTTP response of an operation, for
+// headers the SDK does not expose in its output, such as Content-Range of
+// HeadObject.
+func responseHeader(metadata middleware.Metadata, name string) string {
+	resp, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response)
+	if !ok {
+		return ""
+	}
+	return resp.Header.Get(name)
+}
+
+func TestGetObjectRangeNotSatisfiable(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := testutil.RandomObjectKey()
+	content := "0123456789ABCDEF"
+
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(content),
+	})
+	require.NoError(t, err)
+
+	getRange := func(rangeHeader string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, ts.Endpoint+"/"+bucketName+"/"+key, nil)
+		require.NoError(t, err)
+		req.Header.Set("Range", rangeHeader)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	// Ranges starting beyond the end of the object are not satisfiable
+	resp, body := getRange("bytes=16-20")
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	assert.Equal(t, "bytes */16", resp.Header.Get("Content-Range"))
+	assert.Contains(t, body, "<Code>InvalidRange</Code>")
+	assert.Contains(t, body, "<ActualObjectSize>16</ActualObjectSize>")
+
+	// Suffixes and last positions beyond the end are clamped
+	resp, body = getRange("bytes=-100")
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 0-15/16", resp.Header.Get("Content-Range"))
+	assert.Equal(t, content, body)
+
+	resp, body = getRange("bytes=10-100")
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 10-15/16", resp.Header.Get("Content-Range"))
+	assert.Equal(t, content[10:], body)
+
+	// Malformed ranges are ignored
+	resp, body = getRange("bytes=5-2")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, content, body)
+}
+
 func TestHeadObjectNotFound(t *testing.T) {
 	ts := testutil.NewTestServer(t)
 	defer ts.Cleanup()
@@ -410,6 +535,86 @@ func TestListObjectsV2Delimiter(t *testing.T) {
 	assert.Len(t, result.CommonPrefixes, 2)
 }
 
+func TestListObjectsV2DelimiterPagination(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	keys := []string{
+		"a.txt",
+		"b/1.txt",
+		"b/2.txt",
+		"c/1.txt",
+		"d.txt",
+		"e/1.txt",
+		"e/2.txt",
+	}
+	for _, key := range keys {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+			Body:   strings.NewReader("content"),
+		})
+		require.NoError(t, err)
+	}
+
+	// Common prefixes count toward MaxKeys and KeyCount
+	var entries []string
+	var token *string
+	for page := 0; page < 10; page++ {
+		result, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(bucketName),
+			Delimiter:         aws.String("/"),
+			MaxKeys:           aws.Int32(2),
+			ContinuationToken: token,
+		})
+		require.NoError(t, err)
+
+		count := len(result.Contents) + len(result.CommonPrefixes)
+		assert.LessOrEqual(t, count, 2)
+		assert.Equal(t, int32(count), aws.ToInt32(result.KeyCount))
+		for _, obj := range result.Contents {
+			entries = append(entries, aws.ToString(obj.Key))
+		}
+		for _, prefix := range result.CommonPrefixes {
+			entries = append(entries, aws.ToString(prefix.Prefix))
+		}
+		if !aws.ToBool(result.IsTruncated) {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	assert.ElementsMatch(t, []string{"a.txt", "b/", "c/", "d.txt", "e/"}, entries)
+
+	// ListObjects v1 continues after a common prefix returned as NextMarker
+	v1, err := client.ListObjects(ctx, &s3.ListObjectsInput{
+		Bucket:    aws.String(bucketName),
+		Delimiter: aws.String("/"),
+		MaxKeys:   aws.Int32(2),
+	})
+	require.NoError(t, err)
+	require.True(t, aws.ToBool(v1.IsTruncated))
+	assert.Equal(t, "b/", aws.ToString(v1.NextMarker))
+
+	v1, err = client.ListObjects(ctx, &s3.ListObjectsInput{
+		Bucket:    aws.String(bucketName),
+		Delimiter: aws.String("/"),
+		MaxKeys:   aws.Int32(2),
+		Marker:    v1.NextMarker,
+	})
+	require.NoError(t, err)
+	require.Len(t, v1.CommonPrefixes, 1)
+	assert.Equal(t, "c/", aws.ToString(v1.CommonPrefixes[0].Prefix))
+	require.Len(t, v1.Contents, 1)
+	assert.Equal(t, "d.txt", aws.ToString(v1.Contents[0].Key))
+}
+
 func TestListObjectsV2Pagination(t *testing.T) {
 	ts := testutil.NewTestServer(t)
 	defer ts.Cleanup()
@@ -457,6 +662,63 @@ func TestListObjectsV2Pagination(t *testing.T) {
 	assert.Len(t, result2.Contents, 2)
 }
 
+func TestListObjectsV2ParameterValidation(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(key),
+		})
+		require.NoError(t, err)
+	}
+
+	// max-keys=0 returns an empty, non-truncated page
+	result, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucketName),
+		MaxKeys: aws.Int32(0),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, result.Contents)
+	assert.False(t, aws.ToBool(result.IsTruncated))
+	assert.Equal(t, int32(0), aws.ToInt32(result.KeyCount))
+
+	// max-keys=0 still requires the bucket to exist
+	_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(testutil.RandomBucketName()),
+		MaxKeys: aws.Int32(0),
+	})
+	var noSuchBucket *types.NoSuchBucket
+	assert.ErrorAs(t, err, &noSuchBucket)
+
+	// Invalid parameters are rejected instead of defaulted
+	for _, query := range []string{
+		"list-type=2&max-keys=-1",
+		"list-type=2&max-keys=abc",
+		"list-type=2&continuation-token=not-a-token!",
+		"max-keys=-5",
+		"versions&max-keys=xyz",
+		"uploads&max-uploads=-1",
+	} {
+		resp, err := http.Get(ts.Endpoint + "/" + bucketName + "?" + query)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		assert.Contains(t, string(body), "<Code>InvalidArgument</Code>", query)
+	}
+}
+
 func TestPutGetLargeObject(t *testing.T) {
 	ts := testutil.NewTestServer(t)
 	defer ts.Cleanup()
@@ -670,6 +932,66 @@ func TestCopyObjectSourceNotFound(t *testing.T) {
 	assert.Contains(t, err.Error(), "NoSuchKey")
 }
 
+func TestCopyObjectToItself(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := testutil.RandomObjectKey()
+	content := "Hello, Self-Copy!"
+
+	putResult, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(content),
+		Metadata: map[string]string{
+			"original": "metadata",
+		},
+	})
+	require.NoError(t, err)
+
+	// Copying onto itself without changing anything is illegal
+	_, err = client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String(key),
+		CopySource: aws.String(bucketName + "/" + key),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "InvalidRequest")
+
+	// REPLACE updates the metadata and keeps the data
+	_, err = client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(bucketName),
+		Key:               aws.String(key),
+		CopySource:        aws.String(bucketName + "/" + key),
+		MetadataDirective: types.MetadataDirectiveReplace,
+		Metadata: map[string]string{
+			"new": "metadata",
+		},
+	})
+	require.NoError(t, err)
+
+	getResult, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	defer getResult.Body.Close()
+
+	body, err := io.ReadAll(getResult.Body)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(body))
+	assert.Equal(t, aws.ToString(putResult.ETag), aws.ToString(getResult.ETag))
+	assert.Equal(t, "metadata", getResult.Metadata["new"])
+	assert.NotContains(t, getResult.Metadata, "original")
+}
+
 func TestDeleteObjects(t *testing.T) {
 	ts := testutil.NewTestServer(t)
 	defer ts.Cleanup()
@@ -631,3 +631,141 @@ func TestPutObjectRetentionOnBucketWithoutObjectLock(t *testing.T) {
 		assert.Equal(t, "InvalidRequest", apiErr.ErrorCode())
 	}
 }
+
+// TestObjectLockHeadersOnWrite tests retention and legal hold requested with
+// PutObject, CopyObject and CreateMultipartUpload.
+func TestObjectLockHeadersOnWrite(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{
+		Bucket:                     aws.String(bucketName),
+		ObjectLockEnabledForBucket: aws.Bool(true),
+	})
+	require.NoError(t, err)
+	defer func() {
+		for _, key := range []string{"put.txt", "copy.txt", "multipart.bin"} {
+			client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket:                    aws.String(bucketName),
+				Key:                       aws.String(key),
+				BypassGovernanceRetention: aws.Bool(true),
+			})
+		}
+		client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucketName)})
+	}()
+
+	retainUntil := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:                    aws.String(bucketName),
+		Key:                       aws.String("put.txt"),
+		Body:                      strings.NewReader("locked"),
+		ObjectLockMode:            types.ObjectLockModeGovernance,
+		ObjectLockRetainUntilDate: aws.Time(retainUntil),
+		ObjectLockLegalHoldStatus: types.ObjectLockLegalHoldStatusOn,
+	})
+	require.NoError(t, err)
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("put.txt"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.ObjectLockModeGovernance, head.ObjectLockMode)
+	require.NotNil(t, head.ObjectLockRetainUntilDate)
+	assert.True(t, retainUntil.Equal(*head.ObjectLockRetainUntilDate))
+	assert.Equal(t, types.ObjectLockLegalHoldStatusOn, head.ObjectLockLegalHoldStatus)
+
+	// Copies get the lock of their request, not the one of the source
+	_, err = client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:                    aws.String(bucketName),
+		Key:                       aws.String("copy.txt"),
+		CopySource:                aws.String(bucketName + "/put.txt"),
+		ObjectLockMode:            types.ObjectLockModeCompliance,
+		ObjectLockRetainUntilDate: aws.Time(retainUntil),
+	})
+	require.NoError(t, err)
+	head, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("copy.txt"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.ObjectLockModeCompliance, head.ObjectLockMode)
+	assert.Empty(t, head.ObjectLockLegalHoldStatus)
+
+	// Multipart uploads get the lock on completion
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:                    aws.String(bucketName),
+		Key:                       aws.String("multipart.bin"),
+		ObjectLockLegalHoldStatus: types.ObjectLockLegalHoldStatusOn,
+	})
+	require.NoError(t, err)
+	part, err := client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String("multipart.bin"),
+		UploadId:   upload.UploadId,
+		PartNumber: aws.Int32(1),
+		Body:       strings.NewReader("part"),
+	})
+	require.NoError(t, err)
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String("multipart.bin"),
+		UploadId: upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: []types.CompletedPart{{PartNumber: aws.Int32(1), ETag: part.ETag}},
+		},
+	})
+	require.NoError(t, err)
+	legalHold, err := client.GetObjectLegalHold(ctx, &s3.GetObjectLegalHoldInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("multipart.bin"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.ObjectLockLegalHoldStatusOn, legalHold.LegalHold.Status)
+
+	// A mode without a retain until date is rejected
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:         aws.String(bucketName),
+		Key:            aws.String("invalid.txt"),
+		Body:           strings.NewReader("data"),
+		ObjectLockMode: types.ObjectLockModeGovernance,
+	})
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "InvalidArgument", apiErr.ErrorCode())
+	}
+}
+
+// TestObjectLockHeadersOnBucketWithoutObjectLock tests that lock headers are
+// rejected for buckets without object lock.
+func TestObjectLockHeadersOnBucketWithoutObjectLock(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:                    aws.String(bucketName),
+		Key:                       aws.String("held.txt"),
+		Body:                      strings.NewReader("data"),
+		ObjectLockLegalHoldStatus: types.ObjectLockLegalHoldStatusOn,
+	})
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "InvalidRequest", apiErr.ErrorCode())
+	}
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("held.txt"),
+	})
+	assert.Error(t, err)
+}
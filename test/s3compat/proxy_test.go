@@ -0,0 +1,90 @@
+package s3compat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// completeUploadRaw completes a single-part multipart upload with the given
+// request headers and returns the response body.
+func completeUploadRaw(t *testing.T, ts *testutil.TestServer, bucket, key string, header http.Header) string {
+	t.Helper()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	part, err := client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		UploadId:   created.UploadId,
+		PartNumber: aws.Int32(1),
+		Body:       strings.NewReader("data"),
+	})
+	require.NoError(t, err)
+
+	body := `<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>` + *part.ETag + `</ETag></Part></CompleteMultipartUpload>`
+	req, err := http.NewRequest(http.MethodPost, ts.Endpoint+"/"+bucket+"/"+key+"?uploadId="+*created.UploadId, strings.NewReader(body))
+	require.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(data))
+	return string(data)
+}
+
+func TestTrustedProxyForwardedHeaders(t *testing.T) {
+	forwarded := http.Header{
+		"X-Forwarded-For":   {"203.0.113.7, 127.0.0.1"},
+		"X-Forwarded-Proto": {"https"},
+		"X-Forwarded-Host":  {"s3.example.com"},
+	}
+
+	t.Run("trusted proxy", func(t *testing.T) {
+		ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+			TrustedProxies: []string{"127.0.0.0/8"},
+		})
+		defer ts.Cleanup()
+
+		bucketName := testutil.RandomBucketName()
+		cleanup := ts.CreateTestBucket(t, bucketName)
+		defer cleanup()
+
+		body := completeUploadRaw(t, ts, bucketName, "dir/file.bin", forwarded)
+		assert.Contains(t, body, "<Location>https://s3.example.com/"+bucketName+"/dir/file.bin</Location>")
+	})
+
+	t.Run("untrusted client", func(t *testing.T) {
+		ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+			TrustedProxies: []string{"10.0.0.0/8"},
+		})
+		defer ts.Cleanup()
+
+		bucketName := testutil.RandomBucketName()
+		cleanup := ts.CreateTestBucket(t, bucketName)
+		defer cleanup()
+
+		body := completeUploadRaw(t, ts, bucketName, "file.bin", forwarded)
+		assert.Contains(t, body, "<Location>"+ts.Endpoint+"/"+bucketName+"/file.bin</Location>")
+	})
+}
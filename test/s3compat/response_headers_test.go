@@ -0,0 +1,95 @@
+package s3compat
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketResponseHeaders(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	bucketURL := ts.Endpoint + "/" + bucketName
+	for _, key := range []string{"index.html", "assets/app.js"} {
+		resp := putRaw(t, http.MethodPut, bucketURL+"/"+key, "", "data")
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// No policy yet
+	resp := putRaw(t, http.MethodGet, bucketURL+"?response-headers", "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	policy := `<ResponseHeaderConfiguration>
+		<Rule>
+			<Header><Name>strict-transport-security</Name><Value>max-age=63072000</Value></Header>
+			<Header><Name>X-Content-Type-Options</Name><Value>nosniff</Value></Header>
+			<Header><Name>Cache-Control</Name><Value>no-cache</Value></Header>
+		</Rule>
+		<Rule>
+			<Prefix>assets/</Prefix>
+			<Header><Name>Cache-Control</Name><Value>public, max-age=31536000, immutable</Value></Header>
+		</Rule>
+	</ResponseHeaderConfiguration>`
+	resp = putRaw(t, http.MethodPut, bucketURL+"?response-headers", "application/xml", policy)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodGet, bucketURL+"?response-headers", "", "")
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "<Name>Strict-Transport-Security</Name>")
+	assert.Contains(t, string(body), "<Prefix>assets/</Prefix>")
+
+	resp = putRaw(t, http.MethodGet, bucketURL+"/index.html", "", "")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "max-age=63072000", resp.Header.Get("Strict-Transport-Security"))
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	// Later matching rules override earlier ones
+	resp = putRaw(t, http.MethodHead, bucketURL+"/assets/app.js", "", "")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "public, max-age=31536000, immutable", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+
+	req, err := http.NewRequest(http.MethodGet, bucketURL+"/assets/app.js", nil)
+	require.NoError(t, err)
+	req.Header.Set("Range", "bytes=0-1")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "public, max-age=31536000, immutable", resp.Header.Get("Cache-Control"))
+
+	// Headers owned by object responses cannot be overridden
+	for _, name := range []string{"Content-Length", "ETag", "x-amz-version-id", "Bad Header"} {
+		invalid := `<ResponseHeaderConfiguration><Rule><Header><Name>` + name + `</Name><Value>x</Value></Header></Rule></ResponseHeaderConfiguration>`
+		resp = putRaw(t, http.MethodPut, bucketURL+"?response-headers", "application/xml", invalid)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, name)
+	}
+
+	resp = putRaw(t, http.MethodDelete, bucketURL+"?response-headers", "", "")
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodGet, bucketURL+"/index.html", "", "")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("X-Content-Type-Options"))
+}
@@ -0,0 +1,110 @@
+package s3compat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// partUploadURL returns the URL of a part upload for raw HTTP requests, since
+// the AWS SDK has no resumable part uploads.
+func partUploadURL(ts *testutil.TestServer, bucket, key, uploadID string) string {
+	return ts.Endpoint + "/" + bucket + "/" + key + "?partNumber=1&uploadId=" + url.QueryEscape(uploadID)
+}
+
+// uploadPartRange sends part bytes with a Content-Range header.
+func uploadPartRange(t *testing.T, partURL, contentRange, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPut, partURL, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Range", contentRange)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func TestResumablePartUpload(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := testutil.RandomObjectKey()
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	partURL := partUploadURL(ts, bucketName, key, aws.ToString(upload.UploadId))
+
+	content := "hello, resumable world"
+
+	// Nothing persisted yet
+	req, err := http.NewRequest(http.MethodHead, partURL, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get("x-jog-part-offset"))
+
+	// First chunk is accepted but does not complete the part
+	resp = uploadPartRange(t, partURL, "bytes 0-9/22", content[:10])
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "10", resp.Header.Get("x-jog-part-offset"))
+	assert.Empty(t, resp.Header.Get("ETag"))
+
+	// A chunk not continuing at the persisted offset reports it
+	resp = uploadPartRange(t, partURL, "bytes 15-21/22", content[15:])
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "10", resp.Header.Get("x-jog-part-offset"))
+
+	// A Content-Range not matching the body is rejected
+	resp = uploadPartRange(t, partURL, "bytes 10-20/22", content[10:])
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+
+	// The last chunk completes the part
+	resp = uploadPartRange(t, partURL, "bytes 10-21/22", content[10:])
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "22", resp.Header.Get("x-jog-part-offset"))
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(key),
+		UploadId: upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: []types.CompletedPart{{PartNumber: aws.Int32(1), ETag: aws.String(etag)}},
+		},
+	})
+	require.NoError(t, err)
+
+	getResp, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	defer getResp.Body.Close()
+	body, err := io.ReadAll(getResp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(body))
+}
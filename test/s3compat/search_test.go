@@ -0,0 +1,82 @@
+package s3compat
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchObjects(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	for key, buildID := range map[string]string{"app-1.zip": "1234", "app-2.zip": "1235", "docs.zip": "1234"} {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(bucketName),
+			Key:         aws.String(key),
+			Body:        strings.NewReader("artifact"),
+			ContentType: aws.String("application/zip"),
+			Metadata:    map[string]string{"build-id": buildID},
+		})
+		require.NoError(t, err)
+	}
+
+	search := func(query string) (int, []string) {
+		resp, err := http.Get(ts.Endpoint + "/" + bucketName + "?search&" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var result struct {
+			Contents []struct {
+				Key      string `xml:"Key"`
+				Metadata []struct {
+					Name  string `xml:"Name"`
+					Value string `xml:"Value"`
+				} `xml:"Metadata"`
+			} `xml:"Contents"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+		require.NoError(t, xml.NewDecoder(resp.Body).Decode(&result))
+		var keys []string
+		for _, obj := range result.Contents {
+			keys = append(keys, obj.Key)
+			if assert.Len(t, obj.Metadata, 1) {
+				assert.Equal(t, "build-id", obj.Metadata[0].Name)
+			}
+		}
+		return resp.StatusCode, keys
+	}
+
+	status, keys := search("x-amz-meta-build-id=1234")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"app-1.zip", "docs.zip"}, keys)
+
+	status, keys = search("x-amz-meta-build-id=1234&prefix=app-&content-type=application/*")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"app-1.zip"}, keys)
+
+	status, keys = search("content-type=text/plain")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, keys)
+
+	// A search needs criteria
+	status, _ = search("prefix=app-")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
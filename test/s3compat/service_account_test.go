@@ -0,0 +1,237 @@
+package s3compat
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serviceAccount is the JSON body of a service account of the admin API.
+type serviceAccount struct {
+	AccessKey  string     `json:"accessKey"`
+	SecretKey  string     `json:"secretKey"`
+	Parent     string     `json:"parent"`
+	Bucket     string     `json:"bucket"`
+	Prefix     string     `json:"prefix"`
+	Actions    []string   `json:"actions"`
+	Expiration *time.Time `json:"expiration"`
+}
+
+// signedAdminRequest sends a SigV4 signed request to an admin endpoint.
+func signedAdminRequest(t *testing.T, ts *testutil.TestServer, method, path, body string, creds aws.Credentials) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.Endpoint+path, strings.NewReader(body))
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte(body))
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	require.NoError(t, v4.NewSigner().SignHTTP(context.Background(), creds, req, payloadHash, "s3", "us-east-1", time.Now()))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// rootCredentials returns the credentials of the default namespace.
+func rootCredentials(ts *testutil.TestServer) aws.Credentials {
+	return aws.Credentials{AccessKeyID: ts.AccessKey, SecretAccessKey: ts.SecretKey}
+}
+
+// mintServiceAccount mints a service account with the admin API.
+func mintServiceAccount(t *testing.T, ts *testutil.TestServer, body string) serviceAccount {
+	t.Helper()
+	resp := signedAdminRequest(t, ts, http.MethodPost, "/_jog/admin/service-accounts", body, rootCredentials(ts))
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(data))
+	var account serviceAccount
+	require.NoError(t, json.Unmarshal(data, &account))
+	return account
+}
+
+func TestServiceAccounts(t *testing.T) {
+	ts := testutil.NewTestServerWithAuth(t)
+	defer ts.Cleanup()
+
+	ctx := context.Background()
+	admin := ts.S3Client(t)
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := admin.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("web/index.html"),
+		Body:   bytes.NewReader([]byte("index")),
+	})
+	require.NoError(t, err)
+
+	account := mintServiceAccount(t, ts, `{"bucket": "`+bucketName+`", "prefix": "db/",
+		"actions": ["s3:PutObject", "s3:GetObject", "s3:ListBucket"], "description": "backups"}`)
+	assert.True(t, strings.HasPrefix(account.AccessKey, "JOGSA"))
+	assert.NotEmpty(t, account.SecretKey)
+	assert.Equal(t, ts.AccessKey, account.Parent)
+	client := ts.S3ClientWithCredentials(t, account.AccessKey, account.SecretKey)
+
+	t.Run("ScopedToBucketPrefixAndActions", func(t *testing.T) {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("db/dump.sql"),
+			Body:   bytes.NewReader([]byte("dump")),
+		})
+		require.NoError(t, err)
+
+		// Objects are owned by the bucket of the parent, which can read them
+		_, err = admin.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("db/dump.sql"),
+		})
+		require.NoError(t, err)
+
+		_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String(bucketName),
+			Prefix: aws.String("db/"),
+		})
+		require.NoError(t, err)
+
+		_, err = client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("web/index.html"),
+		})
+		assertAccessDenied(t, err)
+
+		_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("db/dump.sql"),
+		})
+		assertAccessDenied(t, err)
+
+		_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucketName)})
+		assertAccessDenied(t, err)
+
+		_, err = client.ListBuckets(ctx, &s3.ListBucketsInput{})
+		assertAccessDenied(t, err)
+
+		// Service accounts cannot use the admin API
+		resp := signedAdminRequest(t, ts, http.MethodGet, "/_jog/admin/service-accounts", "", aws.Credentials{AccessKeyID: account.AccessKey, SecretAccessKey: account.SecretKey})
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("SecretKeyIsNotStored", func(t *testing.T) {
+		resp := signedAdminRequest(t, ts, http.MethodGet, "/_jog/admin/service-accounts/"+account.AccessKey, "", rootCredentials(ts))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var got serviceAccount
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+		assert.Equal(t, account.AccessKey, got.AccessKey)
+		assert.Empty(t, got.SecretKey)
+		assert.Equal(t, "db/", got.Prefix)
+
+		list := signedAdminRequest(t, ts, http.MethodGet, "/_jog/admin/service-accounts", "", rootCredentials(ts))
+		defer list.Body.Close()
+		var body struct {
+			ServiceAccounts []serviceAccount `json:"serviceAccounts"`
+		}
+		require.NoError(t, json.NewDecoder(list.Body).Decode(&body))
+		require.Len(t, body.ServiceAccounts, 1)
+		assert.Empty(t, body.ServiceAccounts[0].SecretKey)
+	})
+
+	t.Run("InvalidScopes", func(t *testing.T) {
+		for _, body := range []string{
+			`{"bucket": "` + bucketName + `", "actions": []}`,
+			`{"bucket": "` + bucketName + `", "actions": ["s3express:CreateSession"]}`,
+			`{"bucket": "` + bucketName + `", "prefix": "db/*", "actions": ["s3:GetObject"]}`,
+			`{"bucket": "Invalid_Bucket", "actions": ["s3:GetObject"]}`,
+			`{"bucket": "` + bucketName + `", "actions": ["s3:GetObject"], "expiration": "2000-01-01T00:00:00Z"}`,
+		} {
+			resp := signedAdminRequest(t, ts, http.MethodPost, "/_jog/admin/service-accounts", body, rootCredentials(ts))
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		}
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		expiring := mintServiceAccount(t, ts, `{"bucket": "`+bucketName+`", "actions": ["s3:GetObject"],
+			"expiration": "`+time.Now().Add(2*time.Second).UTC().Format(time.RFC3339Nano)+`"}`)
+		expiringClient := ts.S3ClientWithCredentials(t, expiring.AccessKey, expiring.SecretKey)
+		_, err := expiringClient.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("web/index.html"),
+		})
+		require.NoError(t, err)
+
+		time.Sleep(time.Until(*expiring.Expiration))
+		_, err = expiringClient.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("web/index.html"),
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		resp := signedAdminRequest(t, ts, http.MethodDelete, "/_jog/admin/service-accounts/"+account.AccessKey, "", rootCredentials(ts))
+		resp.Body.Close()
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("db/dump.sql"),
+			Body:   bytes.NewReader([]byte("dump")),
+		})
+		require.Error(t, err)
+
+		resp = signedAdminRequest(t, ts, http.MethodDelete, "/_jog/admin/service-accounts/"+account.AccessKey, "", rootCredentials(ts))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestServiceAccountsOfRestrictedParent(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		EnableAuth: true,
+		Policies: []config.PolicyConfig{{Name: "reader", Document: `{
+			"Statement": [
+				{"Effect": "Allow", "Action": ["s3:GetObject", "jog:Admin"], "Resource": "*"}
+			]
+		}`}},
+		Users: []config.UserConfig{{AccessKey: "reader", SecretKey: "reader-secret", Policies: []string{"reader"}}},
+	})
+	defer ts.Cleanup()
+
+	ctx := context.Background()
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	resp := signedAdminRequest(t, ts, http.MethodPost, "/_jog/admin/service-accounts",
+		`{"bucket": "`+bucketName+`", "actions": ["s3:*"]}`, aws.Credentials{AccessKeyID: "reader", SecretAccessKey: "reader-secret"})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var account serviceAccount
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&account))
+
+	// A service account never has more access than its parent
+	client := ts.S3ClientWithCredentials(t, account.AccessKey, account.SecretKey)
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("key"),
+		Body:   bytes.NewReader([]byte("data")),
+	})
+	assertAccessDenied(t, err)
+}
@@ -0,0 +1,143 @@
+package s3compat
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKMSConfig is a local KMS with master keys "default" and "app".
+func testKMSConfig() *config.KMSConfig {
+	key := func(b byte) string {
+		return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+	}
+	return &config.KMSConfig{
+		Provider:     "local",
+		DefaultKeyID: "default",
+		Local: config.LocalKMSConfig{Keys: []config.LocalKMSKey{
+			{ID: "default", Versions: []string{key(1)}},
+			{ID: "app", Versions: []string{key(2)}},
+		}},
+	}
+}
+
+func TestServerSideEncryptionKMS(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{KMS: testKMSConfig()})
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	content := strings.Repeat("encrypted at rest ", 10000)
+	put, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:               aws.String(bucketName),
+		Key:                  aws.String("kms.txt"),
+		Body:                 strings.NewReader(content),
+		ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+		SSEKMSKeyId:          aws.String("app"),
+		SSEKMSEncryptionContext: aws.String(base64.StdEncoding.EncodeToString(
+			[]byte(`{"team":"storage"}`))),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.ServerSideEncryptionAwsKms, put.ServerSideEncryption)
+	assert.Equal(t, "app", aws.ToString(put.SSEKMSKeyId))
+
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("kms.txt"),
+		Range:  aws.String("bytes=100000-100016"),
+	})
+	require.NoError(t, err)
+	body, err := io.ReadAll(get.Body)
+	get.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, content[100000:100017], string(body))
+	assert.Equal(t, types.ServerSideEncryptionAwsKms, get.ServerSideEncryption)
+
+	// Bucket default encryption applies to writes without headers
+	_, err = client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: aws.String(bucketName),
+		ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+			Rules: []types.ServerSideEncryptionRule{{
+				ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{
+					SSEAlgorithm: types.ServerSideEncryptionAes256,
+				},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("default.txt"),
+		Body:   strings.NewReader(content),
+	})
+	require.NoError(t, err)
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("default.txt"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.ServerSideEncryptionAes256, head.ServerSideEncryption)
+	assert.Nil(t, head.SSEKMSKeyId)
+
+	// Unknown keys are rejected
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:               aws.String(bucketName),
+		Key:                  aws.String("missing.txt"),
+		Body:                 strings.NewReader("data"),
+		ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+		SSEKMSKeyId:          aws.String("missing"),
+	})
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "KMS.NotFoundException", apiErr.ErrorCode())
+	}
+}
+
+func TestServerSideEncryptionKMSNotConfigured(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:               aws.String(bucketName),
+		Key:                  aws.String("kms.txt"),
+		Body:                 strings.NewReader("data"),
+		ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+	})
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "InvalidArgument", apiErr.ErrorCode())
+	}
+
+	// SSE-S3 requests are accepted, as before encryption at rest existed
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:               aws.String(bucketName),
+		Key:                  aws.String("s3.txt"),
+		Body:                 strings.NewReader("data"),
+		ServerSideEncryption: types.ServerSideEncryptionAes256,
+	})
+	require.NoError(t, err)
+}
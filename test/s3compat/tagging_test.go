@@ -2,6 +2,9 @@ package s3compat
 
 import (
 	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -208,6 +211,71 @@ func TestPutObjectWithTagging(t *testing.T) {
 	assert.Equal(t, "JOG", tags["Project"])
 }
 
+func TestCreateMultipartUploadWithTagging(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	key := testutil.RandomObjectKey()
+
+	// Tags of the x-amz-tagging header are applied on completion
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:  aws.String(bucketName),
+		Key:     aws.String(key),
+		Tagging: aws.String("Environment=Production&Project=JOG"),
+	})
+	require.NoError(t, err)
+
+	part, err := client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String(key),
+		UploadId:   upload.UploadId,
+		PartNumber: aws.Int32(1),
+		Body:       strings.NewReader("content"),
+	})
+	require.NoError(t, err)
+
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(key),
+		UploadId: upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: []types.CompletedPart{{PartNumber: aws.Int32(1), ETag: part.ETag}},
+		},
+	})
+	require.NoError(t, err)
+
+	result, err := client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+
+	tags := make(map[string]string)
+	for _, tag := range result.TagSet {
+		tags[*tag.Key] = *tag.Value
+	}
+	assert.Equal(t, map[string]string{"Environment": "Production", "Project": "JOG"}, tags)
+
+	// Invalid tags are rejected up front
+	_, err = client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:  aws.String(bucketName),
+		Key:     aws.String(key),
+		Tagging: aws.String(strings.Repeat("k", 129) + "=value"),
+	})
+	require.Error(t, err)
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "InvalidRequest", apiErr.ErrorCode())
+	}
+}
+
 // Bucket Tagging Tests
 
 func TestPutBucketTagging(t *testing.T) {
@@ -330,3 +398,98 @@ func TestDeleteBucketTagging(t *testing.T) {
 		assert.Equal(t, "NoSuchTagSet", apiErr.ErrorCode())
 	}
 }
+
+// listObjectsByTag lists the keys of a bucket with the tag-key and tag-value
+// extension parameters using raw HTTP since the AWS SDK does not send them.
+func listObjectsByTag(t *testing.T, ts *testutil.TestServer, bucket string, params url.Values) (int, []string, bool) {
+	t.Helper()
+
+	resp, err := http.Get(ts.Endpoint + "/" + bucket + "?" + params.Encode())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var result struct {
+		IsTruncated bool `xml:"IsTruncated"`
+		Contents    []struct {
+			Key string `xml:"Key"`
+		} `xml:"Contents"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+	}
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, xml.NewDecoder(resp.Body).Decode(&result))
+	}
+	var keys []string
+	for _, obj := range result.Contents {
+		keys = append(keys, obj.Key)
+	}
+	if result.NextContinuationToken != "" {
+		params.Set("continuation-token", result.NextContinuationToken)
+	}
+	return resp.StatusCode, keys, result.IsTruncated
+}
+
+func TestListObjectsByTag(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	for key, tagging := range map[string]string{
+		"app/a.txt":  "env=prod&team=web",
+		"app/b.txt":  "env=dev",
+		"app/c.txt":  "env=prod",
+		"logs/d.txt": "env=prod",
+		"e.txt":      "team=web",
+		"f.txt":      "",
+	} {
+		input := &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+			Body:   strings.NewReader("data"),
+		}
+		if tagging != "" {
+			input.Tagging = aws.String(tagging)
+		}
+		_, err := client.PutObject(ctx, input)
+		require.NoError(t, err)
+	}
+
+	status, keys, _ := listObjectsByTag(t, ts, bucketName, url.Values{"list-type": {"2"}, "tag-key": {"env"}, "tag-value": {"prod"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"app/a.txt", "app/c.txt", "logs/d.txt"}, keys)
+
+	// Without tag-value, any value of the tag matches
+	status, keys, _ = listObjectsByTag(t, ts, bucketName, url.Values{"tag-key": {"env"}, "prefix": {"app/"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"app/a.txt", "app/b.txt", "app/c.txt"}, keys)
+
+	// Pages continue after the last tagged key
+	params := url.Values{"list-type": {"2"}, "tag-key": {"env"}, "tag-value": {"prod"}, "max-keys": {"2"}}
+	status, keys, truncated := listObjectsByTag(t, ts, bucketName, params)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"app/a.txt", "app/c.txt"}, keys)
+	assert.True(t, truncated)
+	status, keys, truncated = listObjectsByTag(t, ts, bucketName, params)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"logs/d.txt"}, keys)
+	assert.False(t, truncated)
+
+	// Retagged objects are listed by their new tags
+	_, err := client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(bucketName),
+		Key:     aws.String("f.txt"),
+		Tagging: &types.Tagging{TagSet: []types.Tag{{Key: aws.String("team"), Value: aws.String("web")}}},
+	})
+	require.NoError(t, err)
+	status, keys, _ = listObjectsByTag(t, ts, bucketName, url.Values{"list-type": {"2"}, "tag-key": {"team"}, "tag-value": {"web"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"app/a.txt", "e.txt", "f.txt"}, keys)
+
+	status, _, _ = listObjectsByTag(t, ts, bucketName, url.Values{"list-type": {"2"}, "tag-value": {"prod"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
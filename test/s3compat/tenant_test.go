@@ -0,0 +1,96 @@
+package s3compat
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	ts := testutil.NewTestServerWithTenants(t,
+		config.TenantConfig{Name: "team-a", AccessKey: "team-a-key", SecretKey: "team-a-secret"},
+		config.TenantConfig{Name: "team-a", AccessKey: "team-a-ci", SecretKey: "team-a-ci-secret"},
+		config.TenantConfig{Name: "team-b", AccessKey: "team-b-key", SecretKey: "team-b-secret"},
+	)
+	defer ts.Cleanup()
+
+	ctx := context.Background()
+	clientA := ts.S3ClientWithCredentials(t, "team-a-key", "team-a-secret")
+	clientA2 := ts.S3ClientWithCredentials(t, "team-a-ci", "team-a-ci-secret")
+	clientB := ts.S3ClientWithCredentials(t, "team-b-key", "team-b-secret")
+	clientDefault := ts.S3Client(t)
+
+	bucketName := testutil.RandomBucketName()
+
+	t.Run("SameBucketNameInEachTenant", func(t *testing.T) {
+		_, err := clientA.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucketName)})
+		require.NoError(t, err)
+		_, err = clientB.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucketName)})
+		require.NoError(t, err)
+	})
+
+	t.Run("ObjectsAreIsolated", func(t *testing.T) {
+		_, err := clientA.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("owner.txt"),
+			Body:   bytes.NewReader([]byte("team-a")),
+		})
+		require.NoError(t, err)
+
+		_, err = clientB.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("owner.txt"),
+		})
+		assert.Error(t, err)
+
+		// Credentials of the same tenant share its namespace
+		output, err := clientA2.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("owner.txt"),
+		})
+		require.NoError(t, err)
+		defer output.Body.Close()
+		body, _ := io.ReadAll(output.Body)
+		assert.Equal(t, "team-a", string(body))
+	})
+
+	t.Run("BucketsAreNotVisibleAcrossTenants", func(t *testing.T) {
+		output, err := clientDefault.ListBuckets(ctx, &s3.ListBucketsInput{})
+		require.NoError(t, err)
+		assert.Empty(t, output.Buckets)
+
+		_, err = clientDefault.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucketName)})
+		assert.Error(t, err)
+
+		output, err = clientB.ListBuckets(ctx, &s3.ListBucketsInput{})
+		require.NoError(t, err)
+		require.Len(t, output.Buckets, 1)
+		assert.Equal(t, bucketName, aws.ToString(output.Buckets[0].Name))
+	})
+	t.Run("TenantsCannotManageServer", func(t *testing.T) {
+		creds := aws.Credentials{AccessKeyID: "team-a-key", SecretAccessKey: "team-a-secret"}
+		resp := signedAdminRequest(t, ts, http.MethodPut, "/_jog/admin/mode", `{"mode":"read-only"}`, creds)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		for _, method := range []string{http.MethodPut, http.MethodDelete} {
+			resp = signedAdminRequest(t, ts, method, "/_jog/admin/faults", `{"enabled":true,"rules":[]}`, creds)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode, method)
+		}
+
+		resp = signedAdminRequest(t, ts, http.MethodGet, "/_jog/admin/mode", "", rootCredentials(ts))
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), `"mode":"normal"`)
+	})
+}
@@ -0,0 +1,91 @@
+package s3compat
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bucketLimitsRequest sends a request to the admin bucket limits endpoint and
+// returns the response body.
+func bucketLimitsRequest(t *testing.T, ts *testutil.TestServer, method, bucket, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.Endpoint+"/_jog/admin/buckets/"+bucket+"/limits", strings.NewReader(body))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestBucketRequestLimits(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	limited := testutil.RandomBucketName()
+	cleanupLimited := ts.CreateTestBucket(t, limited)
+	defer cleanupLimited()
+	other := testutil.RandomBucketName()
+	cleanupOther := ts.CreateTestBucket(t, other)
+	defer cleanupOther()
+
+	t.Run("DefaultLimits", func(t *testing.T) {
+		status, body := bucketLimitsRequest(t, ts, http.MethodGet, limited, "")
+		require.Equal(t, http.StatusOK, status, body)
+		assert.Contains(t, body, `"requestsPerSecond":0`)
+		assert.Contains(t, body, `"default":true`)
+	})
+
+	t.Run("RateLimitRejectsWithSlowDown", func(t *testing.T) {
+		status, body := bucketLimitsRequest(t, ts, http.MethodPut, limited, `{"requestsPerSecond":0.01,"burst":2}`)
+		require.Equal(t, http.StatusOK, status, body)
+		assert.Contains(t, body, `"burst":2`)
+		assert.Contains(t, body, `"default":false`)
+
+		for i := 0; i < 2; i++ {
+			resp := putRawObject(t, ts, limited, "key.txt", "data")
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+
+		resp := putRawObject(t, ts, limited, "key.txt", "data")
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Contains(t, string(data), "<Code>SlowDown</Code>")
+
+		// Other buckets are not limited
+		for i := 0; i < 5; i++ {
+			resp := putRawObject(t, ts, other, "key.txt", "data")
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	})
+
+	t.Run("DeleteRestoresDefault", func(t *testing.T) {
+		status, body := bucketLimitsRequest(t, ts, http.MethodDelete, limited, "")
+		require.Equal(t, http.StatusOK, status, body)
+		assert.Contains(t, body, `"default":true`)
+
+		resp := putRawObject(t, ts, limited, "key.txt", "data")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("InvalidLimits", func(t *testing.T) {
+		status, _ := bucketLimitsRequest(t, ts, http.MethodPut, limited, `{"maxConcurrentRequests":-1}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = bucketLimitsRequest(t, ts, http.MethodPut, limited, `not json`)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
@@ -0,0 +1,47 @@
+package s3compat
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStalledUploadTimesOut(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		BodyIdleTimeout: 200 * time.Millisecond,
+	})
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	// Send part of the announced body, then stall
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write([]byte("partial"))
+
+	req, err := http.NewRequest(http.MethodPut, ts.Endpoint+"/"+bucketName+"/stalled.txt", pr)
+	require.NoError(t, err)
+	req.ContentLength = 1024
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, string(body), "<Code>RequestTimeout</Code>")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// The stalled object is not stored
+	resp = putRaw(t, http.MethodHead, ts.Endpoint+"/"+bucketName+"/stalled.txt", "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
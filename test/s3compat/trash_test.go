@@ -0,0 +1,103 @@
+package s3compat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketTrash(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	bucketURL := ts.Endpoint + "/" + bucketName
+	resp := putRaw(t, http.MethodGet, bucketURL+"?trash", "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodPut, bucketURL+"?trash", "application/xml",
+		`<TrashConfiguration><Status>Enabled</Status><Days>7</Days></TrashConfiguration>`)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodGet, bucketURL+"?trash", "", "")
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "<Days>7</Days>")
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("report.csv"),
+		Body:   strings.NewReader("a,b,c"),
+	})
+	require.NoError(t, err)
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("report.csv"),
+	})
+	require.NoError(t, err)
+
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("report.csv"),
+	})
+	assert.Error(t, err)
+
+	// The deleted object is listed in the trash and can be restored
+	resp, err = http.Get(ts.Endpoint + "/_jog/admin/buckets/" + bucketName + "/trash")
+	require.NoError(t, err)
+	var trash struct {
+		Entries []struct {
+			ID   string `json:"id"`
+			Key  string `json:"key"`
+			Size int64  `json:"size"`
+		} `json:"entries"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&trash)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Len(t, trash.Entries, 1)
+	assert.Equal(t, "report.csv", trash.Entries[0].Key)
+	assert.Equal(t, int64(5), trash.Entries[0].Size)
+
+	restoreURL := ts.Endpoint + "/_jog/admin/buckets/" + bucketName + "/trash/" + trash.Entries[0].ID + "/restore"
+	resp = putRaw(t, http.MethodPost, restoreURL, "", "")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("report.csv"),
+	})
+	require.NoError(t, err)
+	data, err := io.ReadAll(get.Body)
+	get.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "a,b,c", string(data))
+
+	resp = putRaw(t, http.MethodPost, restoreURL, "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodDelete, bucketURL+"?trash", "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
@@ -0,0 +1,204 @@
+package s3compat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/kumasuke/jog/internal/api"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/server"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadValidators(t *testing.T) {
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		UploadValidators: []api.UploadValidator{
+			api.UploadValidatorFunc(func(ctx context.Context, upload *api.Upload) error {
+				if upload.Size > 16 {
+					return &api.S3Error{Code: "EntityTooLarge", Message: "Uploads are limited to 16 bytes.", HTTPStatus: http.StatusBadRequest}
+				}
+				return nil
+			}),
+			api.UploadValidatorFunc(func(ctx context.Context, upload *api.Upload) error {
+				if bytes.HasPrefix(upload.Head, []byte("MZ")) {
+					return errors.New("executables are not allowed")
+				}
+				return nil
+			}),
+		},
+	})
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	put := func(key, body string) error {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(body),
+		})
+		return err
+	}
+
+	require.NoError(t, put("notes.txt", "hello"))
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("notes.txt"),
+	})
+	require.NoError(t, err)
+	data, err := io.ReadAll(get.Body)
+	get.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	var apiErr smithy.APIError
+	err = put("large.txt", strings.Repeat("x", 17))
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "EntityTooLarge", apiErr.ErrorCode())
+	}
+	err = put("tool.exe", "MZ\x90\x00")
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "AccessDenied", apiErr.ErrorCode())
+		assert.Equal(t, "executables are not allowed", apiErr.ErrorMessage())
+	}
+
+	// Rejected uploads are not stored
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("tool.exe"),
+	})
+	assert.Error(t, err)
+}
+
+func TestUploadValidatorsOnAppend(t *testing.T) {
+	var appends []string
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		UploadValidators: []api.UploadValidator{
+			api.UploadValidatorFunc(func(ctx context.Context, upload *api.Upload) error {
+				if strings.HasSuffix(upload.Key, ".exe") || upload.ContentType == "application/x-msdownload" {
+					return errors.New("executables are not allowed")
+				}
+				if upload.Append {
+					appends = append(appends, fmt.Sprintf("%d+%d", upload.Position, upload.Size))
+				}
+				return nil
+			}),
+		},
+	})
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	appendWithType := func(key, position, contentType, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, ts.Endpoint+"/"+bucketName+"/"+key+"?append&position="+position, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Appends that create an object are validated like PutObject
+	assert.Equal(t, http.StatusForbidden, appendWithType("tool.exe", "0", "text/plain", "MZ"))
+	assert.Equal(t, http.StatusForbidden, appendWithType("tool.bin", "0", "application/x-msdownload", "MZ"))
+	for _, key := range []string{"tool.exe", "tool.bin"} {
+		_, err := ts.S3Client(t).HeadObject(context.Background(), &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		assert.Error(t, err, key)
+	}
+
+	// So is data appended to an existing object
+	assert.Equal(t, http.StatusOK, appendWithType("app.log", "0", "text/plain", "line 1\n"))
+	assert.Equal(t, http.StatusOK, appendWithType("app.log", "7", "text/plain", "line 2\n"))
+	assert.Equal(t, http.StatusForbidden, appendWithType("app.log", "14", "application/x-msdownload", "MZ"))
+	assert.Equal(t, []string{"0+7", "7+7"}, appends)
+}
+
+func TestPrePutHook(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(data), "EICAR") {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]string{
+				"code":    "VirusDetected",
+				"message": "The upload of " + r.URL.Query().Get("key") + " contains a virus.",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+
+	validator, err := server.NewPrePutHook(config.PrePutHookConfig{URL: hook.URL, Timeout: 5 * time.Second})
+	require.NoError(t, err)
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		UploadValidators: []api.UploadValidator{validator},
+	})
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("clean.txt"),
+		Body:   strings.NewReader("clean data"),
+	})
+	require.NoError(t, err)
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("infected.txt"),
+		Body:   strings.NewReader("X5O!P%@AP EICAR test file"),
+	})
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "VirusDetected", apiErr.ErrorCode())
+		assert.Equal(t, "The upload of infected.txt contains a virus.", apiErr.ErrorMessage())
+	}
+
+	// Appended data is scanned too
+	resp := appendObject(t, ts, bucketName, "scan.log", "0", "X5O!P%@AP EICAR test file")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	// Without fail_open, uploads are rejected while the hook is down
+	hook.Close()
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("later.txt"),
+		Body:   strings.NewReader("data"),
+	})
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "ServiceUnavailable", apiErr.ErrorCode())
+	}
+}
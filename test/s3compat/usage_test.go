@@ -0,0 +1,80 @@
+package s3compat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminBucketStats(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	for key, body := range map[string]string{"a.txt": "hello", "b.txt": "world!"} {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(body),
+		})
+		require.NoError(t, err)
+	}
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("big.bin"),
+	})
+	require.NoError(t, err)
+	_, err = client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String("big.bin"),
+		UploadId:   upload.UploadId,
+		PartNumber: aws.Int32(1),
+		Body:       strings.NewReader("part"),
+	})
+	require.NoError(t, err)
+	defer client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String("big.bin"),
+		UploadId: upload.UploadId,
+	})
+
+	resp, err := http.Get(ts.Endpoint + "/_jog/admin/buckets/" + bucketName + "/stats")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var stats struct {
+		Bucket           string `json:"bucket"`
+		Objects          int64  `json:"objects"`
+		Bytes            int64  `json:"bytes"`
+		VersionBytes     int64  `json:"versionBytes"`
+		MultipartUploads int64  `json:"multipartUploads"`
+		MultipartBytes   int64  `json:"multipartBytes"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.Equal(t, bucketName, stats.Bucket)
+	assert.Equal(t, int64(2), stats.Objects)
+	assert.Equal(t, int64(11), stats.Bytes)
+	assert.Equal(t, int64(0), stats.VersionBytes)
+	assert.Equal(t, int64(1), stats.MultipartUploads)
+	assert.Equal(t, int64(4), stats.MultipartBytes)
+
+	missing, err := http.Get(ts.Endpoint + "/_jog/admin/buckets/missing-bucket/stats")
+	require.NoError(t, err)
+	missing.Body.Close()
+	assert.Equal(t, http.StatusNotFound, missing.StatusCode)
+}
@@ -0,0 +1,57 @@
+package s3compat
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketVersionRetention(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	bucketURL := ts.Endpoint + "/" + bucketName
+
+	resp := putRaw(t, http.MethodGet, bucketURL+"?version-retention", "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodPut, bucketURL+"?version-retention", "application/xml",
+		`<VersionRetentionConfiguration><MaxNoncurrentVersions>3</MaxNoncurrentVersions></VersionRetentionConfiguration>`)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodGet, bucketURL+"?version-retention", "", "")
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "<MaxNoncurrentVersions>3</MaxNoncurrentVersions>")
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, config := range []string{
+			`<VersionRetentionConfiguration><MaxNoncurrentVersions>-1</MaxNoncurrentVersions></VersionRetentionConfiguration>`,
+			`<VersionRetentionConfiguration></VersionRetentionConfiguration>`,
+		} {
+			resp := putRaw(t, http.MethodPut, bucketURL+"?version-retention", "application/xml", config)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, config)
+		}
+	})
+
+	resp = putRaw(t, http.MethodDelete, bucketURL+"?version-retention", "", "")
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodGet, bucketURL+"?version-retention", "", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
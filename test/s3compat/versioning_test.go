@@ -2,13 +2,17 @@ package s3compat
 
 import (
 	"context"
+	"encoding/xml"
+	"errors"
 	"io"
+	"net/http"
 	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/kumasuke/jog/test/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -100,6 +104,55 @@ func TestGetBucketVersioning(t *testing.T) {
 	assert.Empty(t, result.Status)
 }
 
+func TestGetBucketVersioningResponse(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	getVersioning := func() string {
+		resp, err := http.Get(ts.Endpoint + "/" + bucketName + "?versioning")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		var config struct {
+			XMLName xml.Name `xml:"VersioningConfiguration"`
+		}
+		require.NoError(t, xml.Unmarshal(body, &config))
+		return string(body)
+	}
+
+	// A bucket that was never versioned has an empty configuration
+	body := getVersioning()
+	assert.NotContains(t, body, "<Status>")
+	assert.NotContains(t, body, "<MfaDelete>")
+
+	_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusSuspended,
+		},
+	})
+	require.NoError(t, err)
+	body = getVersioning()
+	assert.Contains(t, body, "<Status>Suspended</Status>")
+	assert.NotContains(t, body, "<MfaDelete>")
+
+	// Buckets cannot become unversioned again
+	resp := putRaw(t, http.MethodPut, ts.Endpoint+"/"+bucketName+"?versioning", "application/xml",
+		`<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></VersioningConfiguration>`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, getVersioning(), "<Status>Suspended</Status>")
+}
+
 func TestPutObjectVersioned(t *testing.T) {
 	ts := testutil.NewTestServer(t)
 	defer ts.Cleanup()
@@ -280,6 +333,186 @@ func TestDeleteObjectVersioned(t *testing.T) {
 	assert.Equal(t, "content", string(body))
 }
 
+func TestDeleteMarkerResponses(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	require.NoError(t, err)
+
+	key := testutil.RandomObjectKey()
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Body:   strings.NewReader("content"),
+	})
+	require.NoError(t, err)
+	deleteResult, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	markerID := aws.ToString(deleteResult.VersionId)
+
+	responseError := func(t *testing.T, err error) *smithyhttp.ResponseError {
+		t.Helper()
+		require.Error(t, err)
+		var respErr *smithyhttp.ResponseError
+		require.True(t, errors.As(err, &respErr), "unexpected error: %v", err)
+		return respErr
+	}
+
+	t.Run("LatestVersion", func(t *testing.T) {
+		_, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		respErr := responseError(t, err)
+		assert.Equal(t, http.StatusNotFound, respErr.HTTPStatusCode())
+		assert.Equal(t, "true", respErr.Response.Header.Get("x-amz-delete-marker"))
+		assert.Equal(t, markerID, respErr.Response.Header.Get("x-amz-version-id"))
+		var noSuchKey *types.NoSuchKey
+		assert.ErrorAs(t, err, &noSuchKey)
+
+		_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		respErr = responseError(t, err)
+		assert.Equal(t, http.StatusNotFound, respErr.HTTPStatusCode())
+		assert.Equal(t, "true", respErr.Response.Header.Get("x-amz-delete-marker"))
+		assert.Equal(t, markerID, respErr.Response.Header.Get("x-amz-version-id"))
+	})
+
+	t.Run("MarkerVersion", func(t *testing.T) {
+		_, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket:    aws.String(bucketName),
+			Key:       aws.String(key),
+			VersionId: aws.String(markerID),
+		})
+		respErr := responseError(t, err)
+		assert.Equal(t, http.StatusMethodNotAllowed, respErr.HTTPStatusCode())
+		assert.Equal(t, "true", respErr.Response.Header.Get("x-amz-delete-marker"))
+		assert.Equal(t, markerID, respErr.Response.Header.Get("x-amz-version-id"))
+		assert.NotEmpty(t, respErr.Response.Header.Get("Last-Modified"))
+
+		_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:    aws.String(bucketName),
+			Key:       aws.String(key),
+			VersionId: aws.String(markerID),
+		})
+		respErr = responseError(t, err)
+		assert.Equal(t, http.StatusMethodNotAllowed, respErr.HTTPStatusCode())
+		assert.Equal(t, "true", respErr.Response.Header.Get("x-amz-delete-marker"))
+		assert.Equal(t, markerID, respErr.Response.Header.Get("x-amz-version-id"))
+	})
+
+	t.Run("ObjectVersion", func(t *testing.T) {
+		versions, err := client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{
+			Bucket: aws.String(bucketName),
+		})
+		require.NoError(t, err)
+		require.Len(t, versions.Versions, 1)
+		versionID := aws.ToString(versions.Versions[0].VersionId)
+
+		output, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:    aws.String(bucketName),
+			Key:       aws.String(key),
+			VersionId: aws.String(versionID),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, versionID, aws.ToString(output.VersionId))
+		assert.Equal(t, int64(len("content")), aws.ToInt64(output.ContentLength))
+	})
+}
+
+func TestDeleteDeleteMarkerRestoresObject(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	require.NoError(t, err)
+
+	key := testutil.RandomObjectKey()
+	var versionIDs []string
+	for _, content := range []string{"first", "second"} {
+		putResult, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(content),
+		})
+		require.NoError(t, err)
+		versionIDs = append(versionIDs, aws.ToString(putResult.VersionId))
+	}
+	deleteResult, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+
+	currentContent := func() string {
+		getResult, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		require.NoError(t, err)
+		defer getResult.Body.Close()
+		body, err := io.ReadAll(getResult.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	// Deleting the delete marker restores the latest version
+	deleteMarkerResult, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(key),
+		VersionId: deleteResult.VersionId,
+	})
+	require.NoError(t, err)
+	assert.True(t, aws.ToBool(deleteMarkerResult.DeleteMarker))
+	assert.Equal(t, "second", currentContent())
+
+	listResult, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+	})
+	require.NoError(t, err)
+	require.Len(t, listResult.Contents, 1)
+	assert.Equal(t, key, aws.ToString(listResult.Contents[0].Key))
+
+	// Deleting the current version makes the previous one current
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionIDs[1]),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "first", currentContent())
+}
+
 func TestListObjectVersions(t *testing.T) {
 	ts := testutil.NewTestServer(t)
 	defer ts.Cleanup()
@@ -339,3 +572,161 @@ func TestListObjectVersions(t *testing.T) {
 		assert.NotEmpty(t, *v.VersionId)
 	}
 }
+
+func TestCopyObjectFromVersion(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	// Enable versioning
+	_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	require.NoError(t, err)
+
+	key := testutil.RandomObjectKey()
+
+	result1, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Body:   strings.NewReader("version 1"),
+	})
+	require.NoError(t, err)
+	version1 := aws.ToString(result1.VersionId)
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Body:   strings.NewReader("version 2"),
+	})
+	require.NoError(t, err)
+
+	// Copy the first version to another key
+	copyResult, err := client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String("copy"),
+		CopySource: aws.String(bucketName + "/" + key + "?versionId=" + version1),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, version1, aws.ToString(copyResult.CopySourceVersionId))
+	assert.NotEmpty(t, aws.ToString(copyResult.VersionId))
+
+	getResult, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("copy"),
+	})
+	require.NoError(t, err)
+	defer getResult.Body.Close()
+	body, _ := io.ReadAll(getResult.Body)
+	assert.Equal(t, "version 1", string(body))
+
+	// Copying an older version onto its own key restores it as a new version
+	restoreResult, err := client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String(key),
+		CopySource: aws.String(bucketName + "/" + key + "?versionId=" + version1),
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, version1, aws.ToString(restoreResult.VersionId))
+
+	getResult, err = client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	defer getResult.Body.Close()
+	body, _ = io.ReadAll(getResult.Body)
+	assert.Equal(t, "version 1", string(body))
+
+	// Unknown versions are not found
+	_, err = client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String("missing"),
+		CopySource: aws.String(bucketName + "/" + key + "?versionId=00000000-0000-0000-0000-000000000000"),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NoSuchKey")
+}
+
+func TestUploadPartCopyFromVersion(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	// Enable versioning
+	_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	require.NoError(t, err)
+
+	srcKey := testutil.RandomObjectKey()
+	result1, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(srcKey),
+		Body:   strings.NewReader("first version content"),
+	})
+	require.NoError(t, err)
+	version1 := aws.ToString(result1.VersionId)
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(srcKey),
+		Body:   strings.NewReader("second version content"),
+	})
+	require.NoError(t, err)
+
+	dstKey := testutil.RandomObjectKey()
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(dstKey),
+	})
+	require.NoError(t, err)
+
+	partResult, err := client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(dstKey),
+		UploadId:        upload.UploadId,
+		PartNumber:      aws.Int32(1),
+		CopySource:      aws.String(bucketName + "/" + srcKey + "?versionId=" + version1),
+		CopySourceRange: aws.String("bytes=0-12"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, version1, aws.ToString(partResult.CopySourceVersionId))
+
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(dstKey),
+		UploadId: upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: []types.CompletedPart{{PartNumber: aws.Int32(1), ETag: partResult.CopyPartResult.ETag}},
+		},
+	})
+	require.NoError(t, err)
+
+	getResult, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(dstKey),
+	})
+	require.NoError(t, err)
+	defer getResult.Body.Close()
+	body, _ := io.ReadAll(getResult.Body)
+	assert.Equal(t, "first version", string(body))
+}
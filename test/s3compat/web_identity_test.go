@@ -0,0 +1,203 @@
+package s3compat
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertErrorCode asserts that a request failed with an error code.
+func assertErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+	var apiErr smithy.APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, code, apiErr.ErrorCode())
+	}
+}
+
+func TestAssumeRoleWithWebIdentity(t *testing.T) {
+	provider := testutil.NewOIDCProvider(t)
+	ts := testutil.NewTestServerWithOptions(t, testutil.TestServerOptions{
+		EnableAuth: true,
+		Policies: []config.PolicyConfig{
+			{Name: "reader", Document: `{
+				"Version": "2012-10-17",
+				"Statement": [{"Effect": "Allow", "Action": ["s3:GetObject", "s3:ListBucket"], "Resource": "*"}]
+			}`},
+			{Name: "uploader", Document: `{
+				"Version": "2012-10-17",
+				"Statement": [{"Effect": "Allow", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::*/uploads/*"}]
+			}`},
+			{Name: "admin", Document: `{
+				"Version": "2012-10-17",
+				"Statement": [{"Effect": "Allow", "Action": "jog:Admin", "Resource": "*"}]
+			}`},
+		},
+		OIDC: config.OIDCConfig{
+			Issuer:      provider.Issuer,
+			ClientID:    provider.ClientID,
+			PolicyClaim: "groups",
+			MaxDuration: 2 * time.Hour,
+		},
+	})
+	defer ts.Cleanup()
+
+	ctx := context.Background()
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+	_, err := ts.S3Client(t).PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("report.csv"),
+		Body:   bytes.NewReader([]byte("report")),
+	})
+	require.NoError(t, err)
+
+	stsClient := ts.STSClient(t)
+	assume := func(t *testing.T, groups []string) (*sts.AssumeRoleWithWebIdentityOutput, *s3.Client) {
+		t.Helper()
+		out, err := stsClient.AssumeRoleWithWebIdentity(ctx, &sts.AssumeRoleWithWebIdentityInput{
+			RoleArn:          aws.String("arn:aws:iam::000000000000:role/analysts"),
+			RoleSessionName:  aws.String("alice"),
+			WebIdentityToken: aws.String(provider.Token(t, "alice", map[string]any{"groups": groups})),
+		})
+		require.NoError(t, err)
+		creds := out.Credentials
+		return out, ts.S3ClientWithSessionToken(t, *creds.AccessKeyId, *creds.SecretAccessKey, *creds.SessionToken)
+	}
+
+	t.Run("LimitedToClaimedPolicies", func(t *testing.T) {
+		out, client := assume(t, []string{"reader", "staff"})
+		assert.Equal(t, "alice", aws.ToString(out.SubjectFromWebIdentityToken))
+		assert.Equal(t, provider.Issuer, aws.ToString(out.Provider))
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *out.Credentials.Expiration, time.Minute)
+
+		obj, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("report.csv"),
+		})
+		require.NoError(t, err)
+		obj.Body.Close()
+
+		_, err = client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("uploads/data.csv"),
+			Body:   bytes.NewReader([]byte("data")),
+		})
+		assertAccessDenied(t, err)
+	})
+
+	t.Run("SeveralPolicies", func(t *testing.T) {
+		_, client := assume(t, []string{"reader", "uploader"})
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("uploads/data.csv"),
+			Body:   bytes.NewReader([]byte("data")),
+		})
+		require.NoError(t, err)
+
+		_, err = client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("report.csv"),
+			Body:   bytes.NewReader([]byte("overwritten")),
+		})
+		assertAccessDenied(t, err)
+	})
+
+	t.Run("PresignedURL", func(t *testing.T) {
+		_, client := assume(t, []string{"reader"})
+		presigned, err := s3.NewPresignClient(client).PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("report.csv"),
+		})
+		require.NoError(t, err)
+
+		resp, err := http.Get(presigned.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "report", string(body))
+	})
+
+	t.Run("SessionTokenIsVerified", func(t *testing.T) {
+		first, _ := assume(t, []string{"reader"})
+		second, _ := assume(t, []string{"reader", "uploader"})
+
+		// The session token of other credentials does not match the access key
+		client := ts.S3ClientWithSessionToken(t, *first.Credentials.AccessKeyId, *first.Credentials.SecretAccessKey, *second.Credentials.SessionToken)
+		_, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucketName)})
+		assertErrorCode(t, err, "InvalidToken")
+
+		client = ts.S3ClientWithSessionToken(t, *first.Credentials.AccessKeyId, *first.Credentials.SecretAccessKey, *first.Credentials.SessionToken+"x")
+		_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucketName)})
+		assertErrorCode(t, err, "InvalidToken")
+	})
+
+	t.Run("Duration", func(t *testing.T) {
+		out, err := stsClient.AssumeRoleWithWebIdentity(ctx, &sts.AssumeRoleWithWebIdentityInput{
+			RoleArn:          aws.String("arn:aws:iam::000000000000:role/analysts"),
+			RoleSessionName:  aws.String("alice"),
+			WebIdentityToken: aws.String(provider.Token(t, "alice", map[string]any{"groups": "reader"})),
+			DurationSeconds:  aws.Int32(900),
+		})
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(15*time.Minute), *out.Credentials.Expiration, time.Minute)
+
+		_, err = stsClient.AssumeRoleWithWebIdentity(ctx, &sts.AssumeRoleWithWebIdentityInput{
+			RoleArn:          aws.String("arn:aws:iam::000000000000:role/analysts"),
+			RoleSessionName:  aws.String("alice"),
+			WebIdentityToken: aws.String(provider.Token(t, "alice", map[string]any{"groups": "reader"})),
+			DurationSeconds:  aws.Int32(3 * 3600),
+		})
+		assertErrorCode(t, err, "ValidationError")
+	})
+
+	t.Run("RejectedTokens", func(t *testing.T) {
+		tests := map[string]struct {
+			token string
+			code  string
+		}{
+			"no known policy": {provider.Token(t, "bob", map[string]any{"groups": []string{"staff"}}), "AccessDenied"},
+			"other audience":  {provider.Token(t, "bob", map[string]any{"groups": "reader", "aud": "other"}), "InvalidIdentityToken"},
+			"expired": {provider.Token(t, "bob", map[string]any{"groups": "reader",
+				"exp": time.Now().Add(-time.Hour).Unix()}), "ExpiredTokenException"},
+			"not a JWT": {"opaque-token", "InvalidIdentityToken"},
+		}
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				_, err := stsClient.AssumeRoleWithWebIdentity(ctx, &sts.AssumeRoleWithWebIdentityInput{
+					RoleArn:          aws.String("arn:aws:iam::000000000000:role/analysts"),
+					RoleSessionName:  aws.String("bob"),
+					WebIdentityToken: aws.String(tt.token),
+				})
+				assertErrorCode(t, err, tt.code)
+			})
+		}
+	})
+
+	t.Run("CannotManageServiceAccounts", func(t *testing.T) {
+		// Service accounts would act as the default namespace beyond the
+		// policies of the credentials
+		first, _ := assume(t, []string{"admin"})
+		resp := signedAdminRequest(t, ts, http.MethodGet, "/_jog/admin/service-accounts", "", aws.Credentials{
+			AccessKeyID:     *first.Credentials.AccessKeyId,
+			SecretAccessKey: *first.Credentials.SecretAccessKey,
+			SessionToken:    *first.Credentials.SessionToken,
+		})
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
@@ -0,0 +1,114 @@
+package s3compat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketWorm(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	client := ts.S3Client(t)
+	ctx := context.Background()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	bucketURL := ts.Endpoint + "/" + bucketName
+	resp := putRaw(t, http.MethodPut, bucketURL+"?worm", "application/xml",
+		`<WormConfiguration><Status>Enabled</Status></WormConfiguration>`)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = putRaw(t, http.MethodGet, bucketURL+"?worm", "", "")
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "<Status>Enabled</Status>")
+
+	// Write-once mode cannot be turned off again
+	resp = putRaw(t, http.MethodPut, bucketURL+"?worm", "application/xml",
+		`<WormConfiguration><Status>Disabled</Status></WormConfiguration>`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("audit/2026-10-16.log"),
+		Body:   strings.NewReader("login alice"),
+	})
+	require.NoError(t, err)
+
+	assertDenied := func(t *testing.T, err error) {
+		t.Helper()
+		var apiErr smithy.APIError
+		require.True(t, errors.As(err, &apiErr), "expected an API error, got %v", err)
+		assert.Equal(t, "AccessDenied", apiErr.ErrorCode())
+	}
+
+	t.Run("Overwrite", func(t *testing.T) {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("audit/2026-10-16.log"),
+			Body:   strings.NewReader("nothing happened"),
+		})
+		assertDenied(t, err)
+	})
+
+	t.Run("CopyOnto", func(t *testing.T) {
+		_, err := client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:            aws.String(bucketName),
+			Key:               aws.String("audit/2026-10-16.log"),
+			CopySource:        aws.String(bucketName + "/audit/2026-10-16.log"),
+			MetadataDirective: types.MetadataDirectiveReplace,
+			Metadata:          map[string]string{"redacted": "true"},
+		})
+		assertDenied(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String("audit/2026-10-16.log"),
+		})
+		assertDenied(t, err)
+	})
+
+	t.Run("DeleteObjects", func(t *testing.T) {
+		out, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &types.Delete{
+				Objects: []types.ObjectIdentifier{{Key: aws.String("audit/2026-10-16.log")}},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, out.Errors, 1)
+		assert.Equal(t, "AccessDenied", aws.ToString(out.Errors[0].Code))
+		assert.Empty(t, out.Deleted)
+	})
+
+	get, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String("audit/2026-10-16.log"),
+	})
+	require.NoError(t, err)
+	data, err := io.ReadAll(get.Body)
+	get.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "login alice", string(data))
+}
@@ -0,0 +1,98 @@
+package s3compat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kumasuke/jog/test/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMalformedXMLBodies(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	bucketURL := ts.Endpoint + "/" + bucketName
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		code   string
+	}{
+		{"DeleteTrailingElement", http.MethodPost, bucketURL + "?delete",
+			`<Delete><Object><Key>a</Key></Object></Delete><Delete></Delete>`, "MalformedXML"},
+		{"DeleteTrailingText", http.MethodPost, bucketURL + "?delete",
+			`<Delete><Object><Key>a</Key></Object></Delete>garbage`, "MalformedXML"},
+		{"DeleteWrongRoot", http.MethodPost, bucketURL + "?delete",
+			`<Remove><Object><Key>a</Key></Object></Remove>`, "MalformedXML"},
+		{"DeleteUnclosed", http.MethodPost, bucketURL + "?delete",
+			`<Delete><Object><Key>a</Key></Object>`, "MalformedXML"},
+		{"DeleteEmpty", http.MethodPost, bucketURL + "?delete", ``, "MalformedXML"},
+		{"CompleteMultipartUploadNotXML", http.MethodPost, bucketURL + "/key?uploadId=missing",
+			`{"parts": []}`, "MalformedXML"},
+		{"CORSDoctype", http.MethodPut, bucketURL + "?cors",
+			`<!DOCTYPE CORSConfiguration [<!ENTITY x "y">]><CORSConfiguration></CORSConfiguration>`, "MalformedXML"},
+		{"CORSUnknownEntity", http.MethodPut, bucketURL + "?cors",
+			`<CORSConfiguration><CORSRule><AllowedMethod>&x;</AllowedMethod></CORSRule></CORSConfiguration>`, "MalformedXML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := putRaw(t, tt.method, tt.url, "application/xml", tt.body)
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Contains(t, string(body), "<Code>"+tt.code+"</Code>")
+		})
+	}
+
+	// Comments, processing instructions and whitespace are still accepted
+	resp := putRaw(t, http.MethodPost, bucketURL+"?delete", "application/xml",
+		"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!-- keys -->\n<Delete><Object><Key>a</Key></Object></Delete>\n")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestXMLBodyTooLarge(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	defer ts.Cleanup()
+
+	bucketName := testutil.RandomBucketName()
+	cleanup := ts.CreateTestBucket(t, bucketName)
+	defer cleanup()
+
+	endpoint, err := url.Parse(ts.Endpoint)
+	require.NoError(t, err)
+
+	// Oversized bodies are rejected by their Content-Length before they are
+	// read, so only the headers are sent
+	for _, target := range []string{"/" + bucketName + "?lifecycle", "/" + bucketName + "?delete"} {
+		method := http.MethodPut
+		if strings.HasSuffix(target, "?delete") {
+			method = http.MethodPost
+		}
+		conn, err := net.Dial("tcp", endpoint.Host)
+		require.NoError(t, err)
+		fmt.Fprintf(conn, "%s %s HTTP/1.1\r\nHost: %s\r\nContent-Type: application/xml\r\nContent-Length: %d\r\n\r\n", method, target, endpoint.Host, 64<<20)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		conn.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, target)
+		assert.Contains(t, string(body), "<Code>MaxMessageLengthExceeded</Code>", target)
+	}
+}
@@ -10,18 +10,32 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
 // S3Client returns an S3 client configured for the test server.
 func (ts *TestServer) S3Client(t *testing.T) *s3.Client {
 	t.Helper()
+	return ts.S3ClientWithCredentials(t, ts.AccessKey, ts.SecretKey)
+}
+
+// S3ClientWithCredentials returns an S3 client for the test server using the given credentials.
+func (ts *TestServer) S3ClientWithCredentials(t *testing.T, accessKey, secretKey string) *s3.Client {
+	t.Helper()
+	return ts.S3ClientWithSessionToken(t, accessKey, secretKey, "")
+}
+
+// S3ClientWithSessionToken returns an S3 client for the test server using
+// temporary credentials.
+func (ts *TestServer) S3ClientWithSessionToken(t *testing.T, accessKey, secretKey, sessionToken string) *s3.Client {
+	t.Helper()
 
 	cfg, err := config.LoadDefaultConfig(context.Background(),
 		config.WithRegion("us-east-1"),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			ts.AccessKey,
-			ts.SecretKey,
-			"",
+			accessKey,
+			secretKey,
+			sessionToken,
 		)),
 	)
 	if err != nil {
@@ -36,6 +50,16 @@ func (ts *TestServer) S3Client(t *testing.T) *s3.Client {
 	return client
 }
 
+// STSClient returns an anonymous STS client for the test server, for
+// AssumeRoleWithWebIdentity.
+func (ts *TestServer) STSClient(t *testing.T) *sts.Client {
+	t.Helper()
+	return sts.New(sts.Options{
+		BaseEndpoint: aws.String(ts.Endpoint),
+		Region:       "us-east-1",
+	})
+}
+
 // CreateTestBucket creates a bucket for testing and returns a cleanup function.
 func (ts *TestServer) CreateTestBucket(t *testing.T, name string) func() {
 	t.Helper()
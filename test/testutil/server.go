@@ -12,6 +12,9 @@ import (
 
 	"github.com/kumasuke/jog/internal/api"
 	"github.com/kumasuke/jog/internal/auth"
+	"github.com/kumasuke/jog/internal/config"
+	"github.com/kumasuke/jog/internal/kms"
+	"github.com/kumasuke/jog/internal/oidc"
 	"github.com/kumasuke/jog/internal/server"
 	"github.com/kumasuke/jog/internal/storage"
 )
@@ -32,6 +35,41 @@ type TestServer struct {
 // TestServerOptions contains options for creating a test server.
 type TestServerOptions struct {
 	EnableAuth bool
+	// Tenants maps additional credentials to isolated namespaces. Requires EnableAuth.
+	Tenants []config.TenantConfig
+	// Regions and Services restrict the credential scopes of signed requests.
+	// Requires EnableAuth.
+	Regions  []string
+	Services []string
+	// PresignCache caches verified presigned URLs. Requires EnableAuth.
+	PresignCache config.PresignCacheConfig
+	// Policies are named identity policies that Users and Tenants refer to.
+	// Users are additional credentials of the default namespace. Both
+	// require EnableAuth.
+	Policies []config.PolicyConfig
+	Users    []config.UserConfig
+	// OIDC exchanges ID tokens of a provider for temporary credentials
+	// limited to Policies. Requires EnableAuth.
+	OIDC config.OIDCConfig
+	// EncryptedETags selects the ETags of objects in buckets with default encryption.
+	EncryptedETags storage.ETagMode
+	// BodyIdleTimeout aborts requests whose body receives no data for this long.
+	BodyIdleTimeout time.Duration
+	// MaxHeaderCount rejects requests with more header fields. Zero means no
+	// limit.
+	MaxHeaderCount int
+	// TrustedProxies are the addresses and CIDR prefixes of proxies whose
+	// X-Forwarded-* headers are applied.
+	TrustedProxies []string
+	// H2C serves HTTP/2 without TLS to clients with prior knowledge.
+	H2C bool
+	// KMS enables encryption at rest with the given provider and default key.
+	KMS *config.KMSConfig
+	// MFADevices maps the serial numbers of MFA devices to their base32
+	// encoded TOTP secrets.
+	MFADevices map[string]string
+	// UploadValidators can reject PutObject uploads before they are stored.
+	UploadValidators []api.UploadValidator
 }
 
 // NewTestServer creates and starts a test server on a random port.
@@ -46,6 +84,19 @@ func NewTestServerWithAuth(t *testing.T) *TestServer {
 	return newTestServerWithOptions(t, TestServerOptions{EnableAuth: true})
 }
 
+// NewTestServerWithOptions creates a test server with the given options.
+func NewTestServerWithOptions(t *testing.T, opts TestServerOptions) *TestServer {
+	t.Helper()
+	return newTestServerWithOptions(t, opts)
+}
+
+// NewTestServerWithTenants creates a test server with authentication enabled and
+// the given tenant credentials.
+func NewTestServerWithTenants(t *testing.T, tenants ...config.TenantConfig) *TestServer {
+	t.Helper()
+	return newTestServerWithOptions(t, TestServerOptions{EnableAuth: true, Tenants: tenants})
+}
+
 // newTestServerWithOptions creates a test server with the given options.
 func newTestServerWithOptions(t *testing.T, opts TestServerOptions) *TestServer {
 	t.Helper()
@@ -61,28 +112,114 @@ func newTestServerWithOptions(t *testing.T, opts TestServerOptions) *TestServer
 	secretKey := "minioadmin"
 
 	// Initialize storage
-	store, err := storage.NewFileSystem(dataDir, metadataDB)
+	var store storage.Storage
+	fs, err := storage.NewFileSystem(dataDir, metadataDB)
 	if err != nil {
 		os.RemoveAll(dataDir)
 		t.Fatalf("failed to create storage: %v", err)
 	}
+	fs.SetEncryptedETagMode(opts.EncryptedETags)
+	if opts.KMS != nil {
+		keys, err := kms.New(*opts.KMS)
+		if err != nil {
+			fs.Close()
+			os.RemoveAll(dataDir)
+			t.Fatalf("failed to create kms: %v", err)
+		}
+		fs.SetKMS(keys, opts.KMS.DefaultKeyID)
+	}
+	store = fs
+	if len(opts.Tenants) > 0 {
+		tenants := make(map[string]storage.Storage)
+		for _, tenant := range opts.Tenants {
+			if _, ok := tenants[tenant.Name]; ok {
+				continue
+			}
+			dir := filepath.Join(dataDir, ".tenants", tenant.Name)
+			tenantStore, err := storage.NewFileSystem(dir, filepath.Join(dir, "metadata.db"))
+			if err != nil {
+				os.RemoveAll(dataDir)
+				t.Fatalf("failed to create tenant storage: %v", err)
+			}
+			tenants[tenant.Name] = tenantStore
+		}
+		store = storage.NewTenants(store, tenants)
+	}
 
 	// Create API handler
 	apiHandler := api.NewHandler(store)
+	mfaDevices, err := api.NewMFADevices(opts.MFADevices)
+	if err != nil {
+		store.Close()
+		os.RemoveAll(dataDir)
+		t.Fatalf("invalid MFA devices: %v", err)
+	}
+	apiHandler.SetMFADevices(mfaDevices)
+	for _, validator := range opts.UploadValidators {
+		apiHandler.AddUploadValidator(validator)
+	}
+	identityPolicies, err := server.IdentityPolicies(config.AuthConfig{
+		AccessKey: accessKey,
+		Tenants:   opts.Tenants,
+		Policies:  opts.Policies,
+		Users:     opts.Users,
+	})
+	if err != nil {
+		store.Close()
+		os.RemoveAll(dataDir)
+		t.Fatalf("invalid policies: %v", err)
+	}
+	apiHandler.Authorizer().SetAdmin(accessKey)
+	for key, docs := range identityPolicies {
+		apiHandler.Authorizer().Attach(key, docs...)
+	}
 
 	// Create auth middleware based on options
 	var authMiddleware auth.Authenticator
 	if opts.EnableAuth {
-		authMiddleware = auth.NewMiddleware(accessKey, secretKey)
+		middleware := auth.NewMiddleware(accessKey, secretKey)
+		middleware.SetCredentialScope(opts.Regions, opts.Services)
+		middleware.SetPresignCache(opts.PresignCache.Size, opts.PresignCache.TTL)
+		for _, tenant := range opts.Tenants {
+			middleware.AddTenantCredential(tenant.AccessKey, tenant.SecretKey, tenant.Name)
+		}
+		for _, user := range opts.Users {
+			middleware.AddTenantCredential(user.AccessKey, user.SecretKey, "")
+		}
+		authMiddleware = middleware
 	} else {
 		authMiddleware = auth.NewDisabledMiddleware()
 	}
 
 	// Create router
 	router := server.NewRouter(apiHandler, authMiddleware)
+	webIdentityProvider, err := oidc.New(opts.OIDC)
+	if err != nil {
+		store.Close()
+		os.RemoveAll(dataDir)
+		t.Fatalf("invalid OIDC provider: %v", err)
+	}
+	if webIdentityProvider != nil {
+		policies, err := server.NamedPolicies(config.AuthConfig{Policies: opts.Policies})
+		if err != nil {
+			store.Close()
+			os.RemoveAll(dataDir)
+			t.Fatalf("invalid policies: %v", err)
+		}
+		router.SetWebIdentity(webIdentityProvider, opts.OIDC, policies)
+	}
 
 	// Wrap with logging and recovery
 	handler := server.LoggingMiddleware(server.RecoveryMiddleware(router))
+	handler = server.BodyTimeoutMiddleware(0, opts.BodyIdleTimeout)(handler)
+	handler = server.HeaderLimitMiddleware(opts.MaxHeaderCount)(handler)
+	trustedProxies, err := server.ParseTrustedProxies(opts.TrustedProxies)
+	if err != nil {
+		store.Close()
+		os.RemoveAll(dataDir)
+		t.Fatalf("invalid trusted proxies: %v", err)
+	}
+	handler = server.TrustedProxyMiddleware(trustedProxies)(handler)
 
 	// Find available port
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
@@ -95,6 +232,9 @@ func newTestServerWithOptions(t *testing.T, opts TestServerOptions) *TestServer
 	srv := &http.Server{
 		Handler: handler,
 	}
+	if opts.H2C {
+		server.ConfigureProtocols(srv, config.ServerConfig{HTTP2: config.HTTP2Config{H2C: true}})
+	}
 
 	ts := &TestServer{
 		t:         t,